@@ -0,0 +1,335 @@
+// Package client is a Go SDK for the poaid JSON-RPC API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"poai/core"
+	"poai/core/header"
+	"poai/rpc/jwt"
+)
+
+// RPCError is an error object returned by the node.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// Client talks to a single poaid node over HTTP (calls) and WebSocket (subscriptions).
+type Client struct {
+	endpoint string
+	http     *http.Client
+	retries  int
+	backoff  time.Duration
+	nextID   uint64
+	token    func() string // bearer credential; nil = none
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient overrides the HTTP client used for calls.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times a call is retried on transport failure,
+// and the initial backoff between attempts (doubled on each retry).
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithBearerToken authenticates calls with a static token, as set on the
+// node with --rpc-token-file.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = func() string { return token } }
+}
+
+// WithJWTSecret authenticates calls with a fresh JWT signed by secret, the
+// node's --rpc-jwt-secret.
+func WithJWTSecret(secret []byte) Option {
+	return func(c *Client) { c.token = func() string { return jwt.New(secret) } }
+}
+
+// authHeader returns the headers that authenticate a request, or nil.
+func (c *Client) authHeader() http.Header {
+	if c.token == nil {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + c.token()}}
+}
+
+// New creates a client for the node at endpoint, e.g. "http://127.0.0.1:8545".
+func New(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint: endpoint,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		retries:  3,
+		backoff:  200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Call invokes an arbitrary RPC method and decodes the result into out (may be nil).
+// Transport errors and 5xx responses are retried; RPC errors are returned immediately.
+func (c *Client) Call(ctx context.Context, method string, out interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	req := request{JSONRPC: "2.0", ID: atomic.AddUint64(&c.nextID, 1), Method: method, Params: params}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	backoff := c.backoff
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		var resp *response
+		resp, lastErr = c.post(ctx, body)
+		if lastErr != nil {
+			continue
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if out == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, out)
+	}
+	return fmt.Errorf("%s: %w", method, lastErr)
+}
+
+func (c *Client) post(ctx context.Context, body []byte) (*response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range c.authHeader() {
+		httpReq.Header[k] = v
+	}
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode >= 500 {
+		io.Copy(io.Discard, httpResp.Body)
+		return nil, fmt.Errorf("server returned %s", httpResp.Status)
+	}
+	var resp response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+// BlockNumber returns the node's current chain height.
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	var h uint64
+	err := c.Call(ctx, "poai_blockNumber", &h)
+	return h, err
+}
+
+// GetBlock returns the canonical block at height.
+func (c *Client) GetBlock(ctx context.Context, height uint64) (*core.Block, error) {
+	var blk core.Block
+	if err := c.Call(ctx, "poai_getBlockByNumber", &blk, height); err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
+
+// GetBlockByHash returns the block with hash h, canonical or on a side
+// branch.
+func (c *Client) GetBlockByHash(ctx context.Context, h [32]byte) (*core.Block, error) {
+	var blk core.Block
+	if err := c.Call(ctx, "poai_getBlockByHash", &blk, hex.EncodeToString(h[:])); err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
+
+// GetHeader returns only the header of the canonical block at height.
+func (c *Client) GetHeader(ctx context.Context, height uint64) (*header.Header, error) {
+	var h header.Header
+	if err := c.Call(ctx, "poai_getHeaderByNumber", &h, height); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetBalance returns the balance of addr at the node's head.
+func (c *Client) GetBalance(ctx context.Context, addr []byte) (*big.Int, error) {
+	var s string
+	if err := c.Call(ctx, "poai_getBalance", &s, hex.EncodeToString(addr)); err != nil {
+		return nil, err
+	}
+	bal, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", s)
+	}
+	return bal, nil
+}
+
+// GetNonce returns the next nonce expected from addr.
+func (c *Client) GetNonce(ctx context.Context, addr []byte) (uint64, error) {
+	var n uint64
+	err := c.Call(ctx, "poai_getNonce", &n, hex.EncodeToString(addr))
+	return n, err
+}
+
+// SendTransaction submits a signed transaction to the node's mempool and
+// returns its hash.
+func (c *Client) SendTransaction(ctx context.Context, tx *core.Transaction) ([]byte, error) {
+	var h string
+	if err := c.Call(ctx, "poai_sendTransaction", &h, tx); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(h)
+}
+
+// SendRawTransaction submits a transaction signed elsewhere, in its RLP
+// encoding (see core.Transaction.Encode), and returns its hash.
+func (c *Client) SendRawTransaction(ctx context.Context, raw []byte) ([]byte, error) {
+	var h string
+	if err := c.Call(ctx, "poai_sendRawTransaction", &h, hex.EncodeToString(raw)); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(h)
+}
+
+// GetTransactionReceipt returns the receipt of a transaction included in
+// the canonical chain.
+func (c *Client) GetTransactionReceipt(ctx context.Context, txHash []byte) (*core.Receipt, error) {
+	var r core.Receipt
+	if err := c.Call(ctx, "poai_getTransactionReceipt", &r, hex.EncodeToString(txHash)); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// TxInfo is a transaction and where it was included. BlockHash is empty
+// and BlockNumber nil while the transaction is still in the mempool.
+type TxInfo struct {
+	core.Transaction
+	BlockHash   string  `json:"blockHash"`
+	BlockNumber *uint64 `json:"blockNumber"`
+	TxIndex     uint32  `json:"transactionIndex"`
+}
+
+// GetTransactionByHash returns a canonical or pending transaction.
+func (c *Client) GetTransactionByHash(ctx context.Context, txHash []byte) (*TxInfo, error) {
+	var tx TxInfo
+	if err := c.Call(ctx, "poai_getTransactionByHash", &tx, hex.EncodeToString(txHash)); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetAddressTransactions returns the canonical transactions sent or
+// received by addr, newest first, skipping offset and returning at most
+// limit of them.
+func (c *Client) GetAddressTransactions(ctx context.Context, addr []byte, offset, limit int) ([]*TxInfo, error) {
+	var txs []*TxInfo
+	if err := c.Call(ctx, "poai_getAddressTransactions", &txs, hex.EncodeToString(addr), offset, limit); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// GetSupply returns the total supply and cumulative issuance at the
+// node's head.
+func (c *Client) GetSupply(ctx context.Context) (*core.Supply, error) {
+	var sup core.Supply
+	if err := c.Call(ctx, "poai_getSupply", &sup); err != nil {
+		return nil, err
+	}
+	return &sup, nil
+}
+
+// GetEmission returns what the canonical block at height issued and
+// burned.
+func (c *Client) GetEmission(ctx context.Context, height uint64) (*core.Emission, error) {
+	var e core.Emission
+	if err := c.Call(ctx, "poai_getEmission", &e, height); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// GetRichList returns up to limit accounts ordered by balance, largest
+// first, skipping offset of them.
+func (c *Client) GetRichList(ctx context.Context, offset, limit int) (*core.RichList, error) {
+	var list core.RichList
+	if err := c.Call(ctx, "poai_getRichList", &list, offset, limit); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetDeployments returns the state of every soft-fork deployment and the
+// signalling in its current window.
+func (c *Client) GetDeployments(ctx context.Context) ([]core.DeploymentStatus, error) {
+	var statuses []core.DeploymentStatus
+	if err := c.Call(ctx, "poai_getDeployments", &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Generate asks a regtest node to mine n blocks right away, paying address
+// (empty = the node's miner address), and returns their hashes.
+func (c *Client) Generate(ctx context.Context, n int, address []byte) ([]string, error) {
+	params := []interface{}{n}
+	if len(address) > 0 {
+		params = append(params, hex.EncodeToString(address))
+	}
+	var hashes []string
+	if err := c.Call(ctx, "miner_generate", &hashes, params...); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallRetriesOnServerError(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var req request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "poai_getBalance" {
+			t.Errorf("unexpected method %q", req.Method)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "1234"})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	bal, err := c.GetBalance(context.Background(), []byte{0xab})
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if bal.String() != "1234" {
+		t.Fatalf("balance = %s, want 1234", bal)
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 attempts, got %d", hits)
+	}
+}
+
+func TestCallReturnsRPCError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).BlockNumber(context.Background())
+	rpcErr, ok := err.(*RPCError)
+	if !ok || rpcErr.Code != -32601 {
+		t.Fatalf("expected RPCError -32601, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"poai/core"
+
+	"github.com/gorilla/websocket"
+)
+
+// HeadEvent is delivered for every new canonical head.
+type HeadEvent struct {
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+	Parent string `json:"parent"`
+}
+
+// ChainEvent is one change to the node's canonical chain: Block is set
+// for blockAdded and blockRemoved, the rest for reorgStarted and
+// reorgFinished. A reorg's events arrive together, removals newest first.
+type ChainEvent struct {
+	Type       core.ChainEventType `json:"type"`
+	Block      *HeadEvent          `json:"block,omitempty"`
+	ForkHeight *uint64             `json:"forkHeight,omitempty"`
+	OldTip     *HeadEvent          `json:"oldTip,omitempty"`
+	NewTip     *HeadEvent          `json:"newTip,omitempty"`
+}
+
+// Subscription is a live WebSocket subscription. Events keep flowing across
+// reconnects until Unsubscribe is called or the parent context is done.
+type Subscription struct {
+	cancel context.CancelFunc
+	errCh  chan error
+	once   sync.Once
+}
+
+// Err returns a channel that receives the error that terminated the subscription.
+func (s *Subscription) Err() <-chan error { return s.errCh }
+
+// Unsubscribe stops the subscription and closes the underlying connection.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.cancel)
+}
+
+type subNotification struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// wsEndpoint derives the WebSocket URL from the HTTP endpoint.
+func (c *Client) wsEndpoint() string {
+	ep := strings.TrimSuffix(c.endpoint, "/")
+	switch {
+	case strings.HasPrefix(ep, "https://"):
+		ep = "wss://" + strings.TrimPrefix(ep, "https://")
+	case strings.HasPrefix(ep, "http://"):
+		ep = "ws://" + strings.TrimPrefix(ep, "http://")
+	}
+	return ep + "/ws"
+}
+
+// SubscribeHeads streams new heads into ch. The connection is re-established
+// with exponential backoff if it drops.
+func (c *Client) SubscribeHeads(ctx context.Context, ch chan<- HeadEvent) (*Subscription, error) {
+	return c.subscribe(ctx, "newHeads", func(raw json.RawMessage) error {
+		var ev HeadEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return err
+		}
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+}
+
+// SubscribeChainEvents streams chain events into ch. Events emitted while
+// the connection was down are lost, so after a reconnect an indexer
+// should compare its tip with the node's.
+func (c *Client) SubscribeChainEvents(ctx context.Context, ch chan<- ChainEvent) (*Subscription, error) {
+	return c.subscribe(ctx, "chainEvents", func(raw json.RawMessage) error {
+		var ev ChainEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return err
+		}
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+}
+
+// SubscribePendingTransactions streams transactions as they enter the node's mempool.
+func (c *Client) SubscribePendingTransactions(ctx context.Context, ch chan<- *core.Transaction) (*Subscription, error) {
+	return c.subscribe(ctx, "pendingTransactions", func(raw json.RawMessage) error {
+		var tx core.Transaction
+		if err := json.Unmarshal(raw, &tx); err != nil {
+			return err
+		}
+		select {
+		case ch <- &tx:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+}
+
+func (c *Client) subscribe(parent context.Context, topic string, deliver func(json.RawMessage) error) (*Subscription, error) {
+	ctx, cancel := context.WithCancel(parent)
+	conn, err := c.dialSubscription(ctx, topic)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	sub := &Subscription{cancel: cancel, errCh: make(chan error, 1)}
+
+	go func() {
+		defer conn.Close()
+		backoff := c.backoff
+		for {
+			err := readNotifications(ctx, conn, deliver)
+			conn.Close()
+			if ctx.Err() != nil {
+				sub.errCh <- ctx.Err()
+				return
+			}
+			log.Printf("[CLIENT] %s subscription dropped: %v; reconnecting", topic, err)
+			for {
+				select {
+				case <-ctx.Done():
+					sub.errCh <- ctx.Err()
+					return
+				case <-time.After(backoff):
+				}
+				if conn, err = c.dialSubscription(ctx, topic); err == nil {
+					backoff = c.backoff
+					break
+				}
+				if backoff < 30*time.Second {
+					backoff *= 2
+				}
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (c *Client) dialSubscription(ctx context.Context, topic string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.wsEndpoint(), c.authHeader())
+	if err != nil {
+		return nil, err
+	}
+	req := request{JSONRPC: "2.0", ID: 1, Method: "poai_subscribe", Params: []interface{}{topic}}
+	if err := conn.WriteJSON(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var resp response
+	if err := conn.ReadJSON(&resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.Error != nil {
+		conn.Close()
+		return nil, resp.Error
+	}
+	return conn, nil
+}
+
+func readNotifications(ctx context.Context, conn *websocket.Conn, deliver func(json.RawMessage) error) error {
+	// Unblock ReadJSON when the subscription is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	for {
+		var n subNotification
+		if err := conn.ReadJSON(&n); err != nil {
+			return err
+		}
+		if n.Method != "poai_subscription" {
+			continue
+		}
+		if err := deliver(n.Params.Result); err != nil {
+			return fmt.Errorf("decode notification: %w", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"flag"
@@ -9,8 +10,13 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"poai/client"
 	"poai/core"
+	"poai/rpc/jwt"
+	"poai/wallet"
 
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -25,10 +31,36 @@ func handleCLICommands() {
 	switch subcommand {
 	case "send":
 		handleSendCommand()
+	case "tx":
+		handleTxCommand()
 	case "balance":
 		handleBalanceCommand()
+	case "generate":
+		handleGenerateCommand()
 	case "generate-key":
 		handleGenerateKeyCommand()
+	case "wallet":
+		handleWalletCommand()
+	case "pool-worker":
+		handlePoolWorkerCommand()
+	case "inference-worker":
+		handleInferenceWorkerCommand()
+	case "corpus":
+		handleCorpusCommand()
+	case "db":
+		handleDBCommand()
+	case "status":
+		handleStatusCommand()
+	case "supply":
+		handleSupplyCommand()
+	case "richlist":
+		handleRichListCommand()
+	case "export-chain":
+		handleExportChainCommand()
+	case "import-chain":
+		handleImportChainCommand()
+	case "verify-chain":
+		handleVerifyChainCommand()
 	case "help":
 		printHelp()
 	default:
@@ -44,23 +76,36 @@ func handleSendCommand() {
 	toAddr := sendCmd.String("to", "", "Recipient address (hex)")
 	amount := sendCmd.String("amount", "", "Amount to send")
 	privKeyHex := sendCmd.String("privkey", "", "Private key (hex)")
+	from := sendCmd.String("from", "", "Sender address (hex) to unlock from the keystore instead of --privkey")
+	keystoreDir := sendCmd.String("keystore", "keystore", "Keystore directory")
+	passwordFile := sendCmd.String("password-file", "", "File holding the keystore passphrase (default: $POAI_PASSWORD or prompt)")
+	rpcURL := sendCmd.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of a running node")
+	nonceFlag := sendCmd.Int64("nonce", -1, "Transaction nonce (-1 = fetch from node)")
 
 	sendCmd.Parse(os.Args[2:])
 
-	if *toAddr == "" || *amount == "" || *privKeyHex == "" {
-		fmt.Println("Usage: poaid send -to=<address> -amount=<amount> -privkey=<private_key>")
+	if *toAddr == "" || *amount == "" || (*privKeyHex == "" && *from == "") {
+		fmt.Println("Usage: poaid send -to=<address> -amount=<amount> (-from=<address> [-keystore=<dir>] | -privkey=<private_key>)")
 		os.Exit(1)
 	}
 
-	// Parse private key
-	privKeyBytes, err := hex.DecodeString(*privKeyHex)
-	if err != nil {
-		log.Fatalf("Invalid private key: %v", err)
-	}
-
-	privKey, err := crypto.ToECDSA(privKeyBytes)
-	if err != nil {
-		log.Fatalf("Invalid private key format: %v", err)
+	var privKey *ecdsa.PrivateKey
+	if *from != "" {
+		pass, err := wallet.ReadPassphrase(*passwordFile, "Passphrase for "+*from+": ")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if privKey, err = wallet.Unlock(*keystoreDir, *from, pass); err != nil {
+			log.Fatalf("Failed to unlock %s: %v", *from, err)
+		}
+	} else {
+		privKeyBytes, err := hex.DecodeString(*privKeyHex)
+		if err != nil {
+			log.Fatalf("Invalid private key: %v", err)
+		}
+		if privKey, err = crypto.ToECDSA(privKeyBytes); err != nil {
+			log.Fatalf("Invalid private key format: %v", err)
+		}
 	}
 
 	// Parse recipient address
@@ -79,8 +124,21 @@ func handleSendCommand() {
 	pubKey := privKey.Public().(*ecdsa.PublicKey)
 	senderAddr := crypto.PubkeyToAddress(*pubKey).Bytes()
 
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	node := newRPCClient(*rpcURL)
+
+	nonce := uint64(*nonceFlag)
+	if *nonceFlag < 0 {
+		n, err := node.GetNonce(ctx, senderAddr)
+		if err != nil {
+			log.Fatalf("Failed to fetch nonce from %s (is the node running?): %v", *rpcURL, err)
+		}
+		nonce = n
+	}
+
 	// Create transaction
-	tx := core.NewTx(senderAddr, toAddrBytes, amountInt, 0) // Nonce will be set by state
+	tx := core.NewTx(senderAddr, toAddrBytes, amountInt, nonce)
 
 	// Sign transaction
 	if err := tx.Sign(privKey); err != nil {
@@ -91,22 +149,26 @@ func handleSendCommand() {
 	fmt.Printf("  From: %s\n", hex.EncodeToString(senderAddr))
 	fmt.Printf("  To: %s\n", *toAddr)
 	fmt.Printf("  Amount: %s\n", amountInt.String())
+	fmt.Printf("  Nonce: %d\n", nonce)
 	fmt.Printf("  Hash: %s\n", hex.EncodeToString(tx.Hash))
-	fmt.Printf("  Signature: %s\n", hex.EncodeToString(tx.Signature))
 
-	// TODO: Send transaction to mempool via RPC or file
-	fmt.Printf("\nTransaction signed successfully. Add to mempool to broadcast.\n")
+	if _, err := node.SendTransaction(ctx, tx); err != nil {
+		fmt.Printf("\n❌ Transaction rejected: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Transaction accepted into the mempool of %s\n", *rpcURL)
 }
 
 func handleBalanceCommand() {
 	balanceCmd := flag.NewFlagSet("balance", flag.ExitOnError)
 	addr := balanceCmd.String("addr", "", "Address to check balance for (hex)")
 	dataDir := balanceCmd.String("data-dir", "data1", "Data directory containing the blockchain state")
+	rpcURL := balanceCmd.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint to ask when a node holds the data directory")
 
 	balanceCmd.Parse(os.Args[2:])
 
 	if *addr == "" {
-		fmt.Println("Usage: poaid balance -addr=<address> [-data-dir=<directory>]")
+		fmt.Println("Usage: poaid balance -addr=<address> [-data-dir=<directory>] [-rpc=<url>]")
 		os.Exit(1)
 	}
 
@@ -115,19 +177,25 @@ func handleBalanceCommand() {
 		log.Fatalf("Invalid address: %v", err)
 	}
 
-	// Try to open BadgerDB
-	store, err := core.OpenBadgerStore(*dataDir)
+	// Read the database directly unless a running node holds it
+	store, err := core.OpenStoreReadOnly(*dataDir)
 	if err != nil {
-		fmt.Printf("❌ Cannot access database: %v\n", err)
-		fmt.Printf("💡 This usually means a mining node is running. Try:\n")
-		fmt.Printf("   1. Stop the mining node first, or\n")
-		fmt.Printf("   2. Use a different data directory\n")
-		os.Exit(1)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		balance, rpcErr := newRPCClient(*rpcURL).GetBalance(ctx, addrBytes)
+		if rpcErr != nil {
+			fmt.Printf("❌ Cannot access database: %v\n", err)
+			fmt.Printf("❌ No node answered at %s: %v\n", *rpcURL, rpcErr)
+			fmt.Printf("💡 Point --data-dir at a chain, or --rpc at the node using it\n")
+			os.Exit(1)
+		}
+		fmt.Printf("💰 Balance for %s: %s POAI (from the node at %s)\n", *addr, balance.String(), *rpcURL)
+		return
 	}
 	defer store.Close()
 
 	// Create state manager
-	state := core.NewState(store.GetDB())
+	state := core.NewState(store.KV())
 
 	// Get balance
 	balance := state.GetBalance(addrBytes)
@@ -135,10 +203,45 @@ func handleBalanceCommand() {
 	fmt.Printf("💰 Balance for %s: %s POAI\n", *addr, balance.String())
 }
 
+func handleGenerateCommand() {
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	count := generateCmd.Int("n", 1, "Number of blocks to mine")
+	address := generateCmd.String("address", "", "Address (hex) paid by the blocks (default: the node's miner address)")
+	rpcURL := generateCmd.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of a running regtest node")
+
+	generateCmd.Parse(os.Args[2:])
+	if generateCmd.NArg() > 0 {
+		// poaid generate 10
+		if _, err := fmt.Sscan(generateCmd.Arg(0), count); err != nil {
+			log.Fatalf("Invalid block count %q", generateCmd.Arg(0))
+		}
+	}
+
+	var addr []byte
+	if *address != "" {
+		var err error
+		if addr, err = hex.DecodeString(strings.TrimPrefix(*address, "0x")); err != nil {
+			log.Fatalf("Invalid address: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	hashes, err := newRPCClient(*rpcURL).Generate(ctx, *count, addr)
+	if err != nil {
+		log.Fatalf("Failed to generate blocks on %s (is it a --regtest node?): %v", *rpcURL, err)
+	}
+	for _, h := range hashes {
+		fmt.Println(h)
+	}
+}
+
 func handleGenerateKeyCommand() {
 	generateCmd := flag.NewFlagSet("generate-key", flag.ExitOnError)
-	saveToFile := generateCmd.Bool("save", false, "Save keys to files")
-	outputDir := generateCmd.String("output-dir", ".", "Directory to save key files")
+	saveToFile := generateCmd.Bool("save", false, "Save the key encrypted to the keystore and the address to files")
+	outputDir := generateCmd.String("output-dir", ".", "Directory to save the address and miner config")
+	keystoreDir := generateCmd.String("keystore", "keystore", "Keystore directory for the encrypted key")
+	passwordFile := generateCmd.String("password-file", "", "File holding the keystore passphrase (default: $POAI_PASSWORD or prompt)")
 
 	generateCmd.Parse(os.Args[2:])
 
@@ -155,9 +258,11 @@ func handleGenerateKeyCommand() {
 
 	fmt.Printf("🔑 Generated new PoAI keypair:\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Printf("📝 Private Key (hex):\n")
-	fmt.Printf("   %s\n", privKeyHex)
-	fmt.Printf("\n🔐 Public Key (hex):\n")
+	if !*saveToFile {
+		fmt.Printf("📝 Private Key (hex):\n")
+		fmt.Printf("   %s\n\n", privKeyHex)
+	}
+	fmt.Printf("🔐 Public Key (hex):\n")
 	fmt.Printf("   %s\n", hex.EncodeToString(crypto.FromECDSAPub(pubKey)))
 	fmt.Printf("\n💰 Miner Address (hex):\n")
 	fmt.Printf("   %s\n", addressHex)
@@ -172,10 +277,17 @@ func handleGenerateKeyCommand() {
 			log.Fatalf("Failed to create output directory: %v", err)
 		}
 
-		// Save private key
-		privKeyFile := filepath.Join(*outputDir, "poai_private_key.txt")
-		if err := os.WriteFile(privKeyFile, []byte(privKeyHex), 0600); err != nil {
-			log.Fatalf("Failed to save private key: %v", err)
+		// Save the private key encrypted, never in plaintext
+		pass, err := wallet.ReadPassphrase(*passwordFile, "Passphrase for the new key: ")
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if pass == "" {
+			log.Fatalf("Refusing to encrypt the key with an empty passphrase")
+		}
+		keyFile, err := wallet.StoreKey(*keystoreDir, privKey, pass)
+		if err != nil {
+			log.Fatalf("Failed to save key to keystore: %v", err)
 		}
 
 		// Save address
@@ -197,12 +309,12 @@ func handleGenerateKeyCommand() {
 		}
 
 		fmt.Printf("\n💾 Keys saved to files:\n")
-		fmt.Printf("   Private Key: %s\n", privKeyFile)
+		fmt.Printf("   Encrypted Key: %s\n", keyFile)
 		fmt.Printf("   Address: %s\n", addressFile)
 		fmt.Printf("   Miner Config: %s\n", minerConfigFile)
 		fmt.Printf("\n⚠️  SECURITY WARNING:\n")
 		fmt.Printf("   • Keep your private key secure and never share it\n")
-		fmt.Printf("   • The private key file has restricted permissions (600)\n")
+		fmt.Printf("   • The key file is encrypted; without the passphrase it cannot be recovered\n")
 		fmt.Printf("   • Use the address for mining and receiving rewards\n")
 	}
 
@@ -210,33 +322,264 @@ func handleGenerateKeyCommand() {
 	fmt.Printf("   ./poaid --miner-address=%s --model-path=models/tinyllama-1.1b-chat-v1.0.Q4_K_M.gguf --target=500\n", addressHex)
 }
 
+// newRPCClient returns a client for the node at url, authenticated with
+// $POAI_RPC_TOKEN or else the JWT secret file named by $POAI_RPC_JWT_SECRET.
+func newRPCClient(url string) *client.Client {
+	if token := os.Getenv("POAI_RPC_TOKEN"); token != "" {
+		return client.New(url, client.WithBearerToken(token))
+	}
+	if path := os.Getenv("POAI_RPC_JWT_SECRET"); path != "" {
+		secret, err := jwt.ReadSecret(path)
+		if err != nil {
+			log.Fatalf("Failed to read $POAI_RPC_JWT_SECRET: %v", err)
+		}
+		return client.New(url, client.WithJWTSecret(secret))
+	}
+	return client.New(url)
+}
+
 func printHelp() {
 	fmt.Println("PoAI Daemon - Proof of AI Blockchain")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  poaid [flags]                    - Run as daemon")
 	fmt.Println("  poaid send [flags]               - Send a transaction")
+	fmt.Println("  poaid tx create [flags]          - Write an unsigned transaction to sign offline")
+	fmt.Println("  poaid tx sign [flags]            - Sign a transaction with a keystore key, without network access")
+	fmt.Println("  poaid tx broadcast [flags]       - Submit a signed transaction to a node")
 	fmt.Println("  poaid balance [flags]            - Check balance")
+	fmt.Println("  poaid status [flags]             - Show a running node's height, sync, peers, mempool and miner")
+	fmt.Println("  poaid supply [flags]             - Show the total supply, issuance and burns, or per-block emission")
+	fmt.Println("  poaid richlist [flags]           - List the largest accounts of a running node")
+	fmt.Println("  poaid generate [flags] [N]       - Mine N blocks now on a regtest node")
 	fmt.Println("  poaid generate-key [flags]       - Generate new keypair")
+	fmt.Println("  poaid wallet new [flags]         - Create an HD wallet with a recovery phrase")
+	fmt.Println("  poaid wallet restore [flags]     - Restore accounts from a recovery phrase")
+	fmt.Println("  poaid wallet derive [flags]      - Derive the account at an index or path")
+	fmt.Println("  poaid pool-worker [flags]        - Mine for a pool server")
+	fmt.Println("  poaid inference-worker [flags]   - Serve the local model to mining nodes over gRPC")
+	fmt.Println("  poaid corpus seal [flags]        - Encrypt a text file into a corpus for this chain")
+	fmt.Println("  poaid db compact [flags]         - Compact a stopped node's database and reclaim space")
+	fmt.Println("  poaid export-chain [flags]       - Write a stopped node's blocks (and state) to a file")
+	fmt.Println("  poaid import-chain [flags]       - Verify and import an export file into a data directory")
+	fmt.Println("  poaid verify-chain [flags]       - Re-verify a stopped node's stored chain, reporting the first bad block")
 	fmt.Println("  poaid help                       - Show this help")
 	fmt.Println()
 	fmt.Println("Daemon Flags:")
 	fmt.Println("  --model-path=<path>              - Path to LLM model")
-	fmt.Println("  --target=<difficulty>            - Mining difficulty target")
-	fmt.Println("  --data-dir=<path>                - Data directory")
-	fmt.Println("  --p2p-port=<port>                - P2P listen port")
-	fmt.Println("  --peer-multiaddr=<addr>          - Peer to connect to")
+	fmt.Println("  --model-sha256=<hex>             - Model hash the chain commits to (checked at startup)")
+	fmt.Println("  --target=<difficulty>            - Mining difficulty target (default: the network's)")
+	fmt.Println("  --data-dir=<path>                - Data directory (a network's chain is kept in <path>/<network>, except devnet)")
+	fmt.Println("  --network=<name>                 - Network preset: mainnet, testnet, regtest or devnet (default devnet)")
+	fmt.Println("  --genesis=<file>                 - genesis.json defining a custom network (instead of --network)")
+	fmt.Println("  --db-engine=<name>               - Storage engine for a new data dir: badger, pebble or leveldb")
+	fmt.Println("  --db-gc-interval=<dur>           - Reclaim stale database space this often (default 10m, 0 = never)")
+	fmt.Println("  --db-gc-discard-ratio=<r>        - Stale share that makes a value-log file worth rewriting (default 0.5)")
+	fmt.Println("  --ephemeral                      - Keep the chain in memory; nothing is written to --data-dir")
+	fmt.Println("  --regtest                        - Same as --network=regtest: trivial target and stub inference; mine with generate")
+	fmt.Println("  --p2p-port=<port>                - P2P listen port (TCP, and UDP for QUIC)")
+	fmt.Println("  --listen-addr=<multiaddr>        - P2P listen address (repeatable, IPv4/IPv6, TCP/QUIC)")
+	fmt.Println("  --quic=<bool>                    - Also listen for QUIC on UDP --p2p-port (default true)")
+	fmt.Println("  --announce-addr=<multiaddr>      - Address advertised to peers (static NAT)")
+	fmt.Println("  --p2p-ws-port=<port>             - WebSocket listen port for browser clients")
+	fmt.Println("  --p2p-webtransport-port=<port>   - WebTransport listen port for browser clients")
+	fmt.Println("  --peer-multiaddr=<addr>          - Peer to keep connected to (same as one --static-peers entry)")
+	fmt.Println("  --bootstrap-peers=<addrs>        - Peers to dial on startup with retry (repeatable)")
+	fmt.Println("  --bootstrap-peers-file=<path>    - File of bootstrap peer multiaddrs, one per line")
+	fmt.Println("  --static-peers=<addrs>           - Peers to keep connected, redialed with backoff when they drop")
+	fmt.Println("  --max-upload-kbps=<n>            - Total P2P upload limit (KB/s)")
+	fmt.Println("  --max-download-kbps=<n>          - Total P2P download limit (KB/s)")
+	fmt.Println("  --peer-max-upload-kbps=<n>       - Per-peer P2P upload limit (KB/s)")
+	fmt.Println("  --peer-max-download-kbps=<n>     - Per-peer P2P download limit (KB/s)")
+	fmt.Println("  --peers-low=<n>                  - Peer count excess connections are trimmed to (default 32)")
+	fmt.Println("  --peers-high=<n>                 - Peer count above which connections are trimmed (default 64)")
+	fmt.Println("  --outbound-peers=<n>             - Outbound connections kept, one per /16 or /32 network (default 8, 0 = off)")
+	fmt.Println("  --outbound-rotation=<dur>        - Replace one outbound connection this often (default 30m, 0 = never)")
 	fmt.Println("  --miner-address=<hex>            - Miner address for block rewards")
+	fmt.Println("  --mine                           - Start mining at launch (default true)")
+	fmt.Println("  --miner-threads=<n>              - Parallel mining workers (default 1)")
+	fmt.Println("  --pool-addr=<host:port>          - Serve pool workers on this TCP address")
+	fmt.Println("  --pool-share-factor=<n>          - How many times easier shares are than blocks (default 16)")
+	fmt.Println("  --inference-workers=<addrs>      - Run inference on these gRPC workers (host:port, repeatable)")
+	fmt.Println("  --inference-timeout=<dur>        - Timeout for one remote inference (default 2m)")
+	fmt.Println("  --inference-ca=<file>            - CA the workers' TLS certificates must chain to (else loopback workers only)")
+	fmt.Println("  --inference-cert=<file>          - Client certificate for workers that require one")
+	fmt.Println("  --inference-key=<file>           - Private key of --inference-cert")
+	fmt.Println("  --corpus=<dir>                   - Encrypted corpus (Σ.idx, Σ.bin) whose records precede quizzes")
+	fmt.Println("  --corpus-hash=<hex>              - SHA3-256 of Σ.idx; fetch missing corpus records from peers")
+	fmt.Println("  --keystore=<dir>                 - Unlock the miner address key from this keystore")
+	fmt.Println("  --password-file=<path>           - Keystore passphrase file")
+	fmt.Println("  --rpc-host=<host>                - JSON-RPC listen host (default 127.0.0.1)")
+	fmt.Println("  --rpc-port=<port>                - JSON-RPC listen port (0 = disabled)")
+	fmt.Println("  --rpc-token-file=<path>          - Bearer token JSON-RPC callers must send")
+	fmt.Println("  --rpc-jwt-secret=<path>          - Hex secret of HS256 JWTs callers may send (created if missing)")
+	fmt.Println("  --rpc-public-readonly            - Serve read-only poai_* methods without credentials")
+	fmt.Println("  --rpc-tls-cert=<path>            - Serve JSON-RPC over HTTPS/WSS with this PEM certificate")
+	fmt.Println("  --rpc-tls-key=<path>             - PEM private key of --rpc-tls-cert")
+	fmt.Println("  --rpc-allowed-origins=<origins>  - Web origins allowed to call JSON-RPC besides its own (* = any)")
+	fmt.Println("  --metrics-addr=<host:port>       - Serve Prometheus metrics (and /healthz, /readyz)")
+	fmt.Println("  --ready-max-lag=<n>              - Blocks behind the best known head that still pass /readyz (default 10)")
+	fmt.Println("  --otlp-endpoint=<host:port>      - Export tracing spans to an OTLP/gRPC collector")
+	fmt.Println("  --otlp-insecure                  - Connect to the collector without TLS")
+	fmt.Println("  --trace-sample-ratio=<r>         - Share of traces exported (default 1)")
+	fmt.Println("  --checkpoint-signers=<hex,...>   - Trusted checkpoint signer addresses")
+	fmt.Println("  --checkpoint-address=<hex>       - Sign checkpoints with this keystore account (needs --keystore)")
+	fmt.Println("  --fast-bootstrap                 - Start from the latest signed checkpoint")
+	fmt.Println("  --finality-epochs=<n>            - Epochs between finalized checkpoints (0 = disabled)")
+	fmt.Println("  --fast-sync                      - Start from a peer state snapshot matching the header chain (pivot agreed by peers from 3 network groups or a signed checkpoint)")
+	fmt.Println("  --relay                          - Run as a non-mining relay/seed node")
+	fmt.Println("  --nat                            - UPnP/NAT-PMP port mapping and AutoNAT (default true)")
+	fmt.Println("  --hole-punching                  - Upgrade relayed connections to direct (default true)")
+	fmt.Println("  --relay-peers=<addrs>            - Circuit relays to use when behind NAT")
+	fmt.Println("  --relay-service                  - Relay connections for peers behind NAT")
+	fmt.Println("  --new-identity                   - Generate a new Peer ID instead of reusing the saved one")
+	fmt.Println("  --verify-blocks                  - Replay PoAI work of peer blocks before import (default true, false with --relay)")
+	fmt.Println("  --verify-workers=<n>             - Parallel block verifications during sync (default: CPUs)")
+	fmt.Println("  --trust-local-blocks             - Skip replay for blocks mined by this node (default true)")
+	fmt.Println("  --log-level=<spec>               - Log level, e.g. info or warn,p2p=debug")
+	fmt.Println("  --log-format=<fmt>               - Log format: text or json")
+	fmt.Println("  --role=<role>                    - Node role: archive, full, pruned, light")
+	fmt.Println("  --prune-depth=<n>                - Blocks (and blocks of state history) kept by a pruned node, at least the retarget window")
+	fmt.Println("  --archive                        - Keep all state history (same as --role=archive)")
+	fmt.Println("  --ancient-depth=<n>              - Move finalized blocks this deep into era files (default 90000, 0 = never)")
+	fmt.Println("  --block-cache=<n>                - Recent blocks kept in memory, older ones read from the database (default 2048)")
+	fmt.Println("  --max-orphans=<n>                - Blocks with unknown parents held at most (default 512)")
+	fmt.Println("  --max-orphan-mb=<n>              - Memory cap of the orphan pool in MB (default 64)")
+	fmt.Println("  --orphan-expiry=<dur>            - Drop orphans whose parent has not arrived in time (default 20m)")
+	fmt.Println("  --max-reorg-depth=<n>            - Refuse deeper reorgs and raise an alert (default 100, 0 = no limit)")
+	fmt.Println("  --reindex                        - Rebuild the transaction indexes and supply counters from the stored blocks")
+	fmt.Println()
+	fmt.Println("Generate Flags:")
+	fmt.Println("  --n=<count>                      - Blocks to mine (default 1, or the N argument)")
+	fmt.Println("  --address=<hex>                  - Address paid by the blocks (default: node's miner address)")
+	fmt.Println("  --rpc=<url>                      - Regtest node RPC endpoint (default http://127.0.0.1:8545)")
 	fmt.Println()
 	fmt.Println("Generate Key Flags:")
-	fmt.Println("  --save                           - Save keys to files")
-	fmt.Println("  --output-dir=<path>              - Directory to save key files")
+	fmt.Println("  --save                           - Save the key encrypted to the keystore")
+	fmt.Println("  --output-dir=<path>              - Directory to save the address and miner config")
+	fmt.Println("  --keystore=<dir>                 - Keystore directory (default keystore)")
+	fmt.Println("  --password-file=<path>           - Keystore passphrase file (default $POAI_PASSWORD or prompt)")
+	fmt.Println()
+	fmt.Println("Wallet Flags:")
+	fmt.Println("  --words=<12|24>                  - Recovery phrase length for wallet new (default 24)")
+	fmt.Println("  --count=<n>                      - Accounts to derive for new/restore (default 1)")
+	fmt.Println("  --index=<n>                      - Account index for wallet derive")
+	fmt.Println("  --path=<path>                    - Derivation path for wallet derive (default m/44'/60'/0'/0/<index>)")
+	fmt.Println("  --mnemonic-file=<path>           - Recovery phrase file (default: prompt)")
+	fmt.Println("  --seed-passphrase=<pass>         - Optional BIP-39 passphrase")
+	fmt.Println("  --save                           - Encrypt derived keys into the keystore")
+	fmt.Println("  --keystore=<dir>                 - Keystore directory (default keystore)")
+	fmt.Println("  --password-file=<path>           - Keystore passphrase file")
+	fmt.Println()
+	fmt.Println("Pool Worker Flags:")
+	fmt.Println("  --pool=<host:port>               - Pool server address (default 127.0.0.1:3333)")
+	fmt.Println("  --name=<name>                    - Worker name (default: hostname)")
+	fmt.Println("  --threads=<n>                    - Parallel inferences (default 1)")
+	fmt.Println("  --model-path=<path>              - GGUF model, same as the pool's")
+	fmt.Println("  --gpu-layers=<n>                 - LLM layers to offload to GPU")
+	fmt.Println()
+	fmt.Println("Inference Worker Flags:")
+	fmt.Println("  --listen=<host:port>             - gRPC listen address (default :50051)")
+	fmt.Println("  --parallel=<n>                   - Inferences run at once (default 1)")
+	fmt.Println("  --model-path=<path>              - GGUF model, the chain's model")
+	fmt.Println("  --model-sha256=<hex>             - Refuse to start unless the model has this hash")
+	fmt.Println("  --gpu-layers=<n>                 - LLM layers to offload to GPU")
+	fmt.Println("  --tls-cert=<file>                - Serve over TLS (else only nodes on this machine connect)")
+	fmt.Println("  --tls-key=<file>                 - Private key of --tls-cert")
+	fmt.Println("  --client-ca=<file>               - Only serve nodes with a client certificate from this CA")
+	fmt.Println()
+	fmt.Println("Corpus Seal Flags:")
+	fmt.Println("  --input=<file>                   - Records separated by blank lines")
+	fmt.Println("  --out=<dir>                      - Output directory (default corpus)")
+	fmt.Println("  --data-dir=<path>                - Chain whose genesis keys the corpus (default data)")
+	fmt.Println("  --network=<name>                 - Network preset of the chain (default devnet)")
+	fmt.Println("  --genesis=<file>                 - genesis.json of a custom network's chain")
+	fmt.Println()
+	fmt.Println("DB Compact Flags:")
+	fmt.Println("  --data-dir=<path>                - Data directory of the chain (default data)")
+	fmt.Println("  --discard-ratio=<r>              - Stale share that makes a value-log file worth rewriting (default 0.5)")
+	fmt.Println()
+	fmt.Println("Status Flags:")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
+	fmt.Println("  --json                           - Print the status as JSON")
+	fmt.Println("  --timeout=<dur>                  - How long to wait for the node (default 10s)")
+	fmt.Println()
+	fmt.Println("Supply Flags:")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
+	fmt.Println("  --height=<n>                     - Show the emission of block n instead of the totals")
+	fmt.Println("  --count=<n>                      - With --height, how many consecutive blocks to show (default 1)")
+	fmt.Println("  --json                           - Print the result as JSON")
+	fmt.Println("  --timeout=<dur>                  - How long to wait for the node (default 30s)")
+	fmt.Println()
+	fmt.Println("Rich List Flags:")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
+	fmt.Println("  --offset=<n>                     - Number of largest accounts to skip (default 0)")
+	fmt.Println("  --limit=<n>                      - Number of accounts to show, at most 1000 (default 20)")
+	fmt.Println("  --json                           - Print the result as JSON")
+	fmt.Println("  --timeout=<dur>                  - How long to wait for the node (default 30s)")
+	fmt.Println()
+	fmt.Println("Export Chain Flags:")
+	fmt.Println("  --data-dir=<path>                - Data directory of the chain (default data)")
+	fmt.Println("  --out=<path>                     - Export file to write (.gz to compress)")
+	fmt.Println("  --from=<n>                       - First block (default 0, or --to with --state)")
+	fmt.Println("  --to=<n>                         - Last block (default tip)")
+	fmt.Println("  --state                          - Include the account state after --from (a checkpoint or the tip)")
+	fmt.Println()
+	fmt.Println("Import Chain Flags:")
+	fmt.Println("  --data-dir=<path>                - Data directory to import into (default data)")
+	fmt.Println("  --in=<path>                      - Export file to read (.gz if compressed)")
+	fmt.Println("  --network=<name>                 - Network preset of the exported chain (default devnet)")
+	fmt.Println("  --genesis=<file>                 - genesis.json of a custom network's exported chain")
+	fmt.Println("  --target=<difficulty>            - Devnet chain target")
+	fmt.Println("  --epoch-blocks=<n>               - Devnet chain blocks per epoch (default 20)")
+	fmt.Println("  --db-engine=<name>               - Storage engine for a new data dir")
+	fmt.Println()
+	fmt.Println("Verify Chain Flags:")
+	fmt.Println("  --data-dir=<path>                - Data directory of the chain (default data)")
+	fmt.Println("  --from=<n>                       - First block (default 0, or the lowest stored block)")
+	fmt.Println("  --to=<n>                         - Last block (default tip)")
+	fmt.Println("  --network=<name>                 - Network preset of the chain (default devnet)")
+	fmt.Println("  --genesis=<file>                 - genesis.json of a custom network's chain")
+	fmt.Println("  --epoch-blocks=<n>               - Devnet chain blocks per epoch (default 20)")
+	fmt.Println("  --verify-work=<ratio>            - Share of blocks whose AI work is replayed, 0 to 1 (default 0)")
+	fmt.Println("  --model-path=<path>              - GGUF model, for --verify-work")
+	fmt.Println("  --gpu-layers=<n>                 - LLM layers to offload to GPU")
+	fmt.Println()
+	fmt.Println("RPC Client Environment (send, tx, balance, generate, status, supply, richlist):")
+	fmt.Println("  POAI_RPC_TOKEN                   - Bearer token for a node with --rpc-token-file")
+	fmt.Println("  POAI_RPC_JWT_SECRET              - JWT secret file for a node with --rpc-jwt-secret")
 	fmt.Println()
 	fmt.Println("Send Flags:")
 	fmt.Println("  --to=<address>                   - Recipient address (hex)")
 	fmt.Println("  --amount=<amount>                - Amount to send")
-	fmt.Println("  --privkey=<private_key>          - Private key (hex)")
+	fmt.Println("  --from=<address>                 - Sender address to unlock from the keystore")
+	fmt.Println("  --keystore=<dir>                 - Keystore directory (default keystore)")
+	fmt.Println("  --password-file=<path>           - Keystore passphrase file")
+	fmt.Println("  --privkey=<private_key>          - Private key (hex), instead of --from")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
+	fmt.Println("  --nonce=<n>                      - Nonce override (default: fetched from node)")
+	fmt.Println()
+	fmt.Println("Tx Create Flags:")
+	fmt.Println("  --from=<address>                 - Sender address (hex)")
+	fmt.Println("  --to=<address>                   - Recipient address (hex)")
+	fmt.Println("  --amount=<amount>                - Amount to send")
+	fmt.Println("  --gas-price=<n>                  - Gas price (default 1)")
+	fmt.Println("  --nonce=<n>                      - Nonce (default: fetched from --rpc)")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
+	fmt.Println("  --out=<path>                     - Unsigned transaction file (default stdout)")
+	fmt.Println()
+	fmt.Println("Tx Sign Flags:")
+	fmt.Println("  --in=<path>                      - Unsigned transaction file from tx create")
+	fmt.Println("  --out=<path>                     - Signed transaction file (default stdout)")
+	fmt.Println("  --keystore=<dir>                 - Keystore directory (default keystore)")
+	fmt.Println("  --password-file=<path>           - Keystore passphrase file")
+	fmt.Println()
+	fmt.Println("Tx Broadcast Flags:")
+	fmt.Println("  --in=<path>                      - Signed transaction file from tx sign (default stdin)")
+	fmt.Println("  --rpc=<url>                      - Node RPC endpoint (default http://127.0.0.1:8545)")
 	fmt.Println()
 	fmt.Println("Balance Flags:")
 	fmt.Println("  --addr=<address>                 - Address to check (hex)")
+	fmt.Println("  --data-dir=<dir>                 - Chain to read, opened read-only (default data1)")
+	fmt.Println("  --rpc=<url>                      - Node asked instead while it holds --data-dir (default http://127.0.0.1:8545)")
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"poai/core"
+	"poai/core/storage"
+)
+
+// handleDBCommand dispatches `poaid db <compact>`.
+func handleDBCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: poaid db compact [flags]")
+		os.Exit(1)
+	}
+	switch os.Args[2] {
+	case "compact":
+		handleDBCompact()
+	default:
+		fmt.Printf("Unknown db command %q (want compact)\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleDBCompact compacts a stopped node's database and collects its
+// value-log garbage.
+func handleDBCompact() {
+	fs := flag.NewFlagSet("db compact", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "data", "Data directory of the chain")
+	ratio := fs.Float64("discard-ratio", storage.DefaultDiscardRatio, "Share of stale data that makes a value-log file worth rewriting")
+	fs.Parse(os.Args[3:])
+
+	engine := storage.Detect(*dataDir)
+	if _, err := os.Stat(filepath.Join(*dataDir, engine)); err != nil {
+		log.Fatalf("No database in %s: %v", *dataDir, err)
+	}
+	store, err := core.OpenStore(*dataDir, engine)
+	if err != nil {
+		log.Fatalf("Open database in %s (stop the node first): %v", *dataDir, err)
+	}
+	defer store.Close()
+	fmt.Printf("Compacting the %s database in %s...\n", engine, *dataDir)
+	if err := store.Compact(); err != nil {
+		log.Fatalf("Compact: %v", err)
+	}
+	n, err := store.CollectGarbage(*ratio)
+	if err != nil {
+		log.Fatalf("Collect garbage: %v", err)
+	}
+	fmt.Printf("✅ Done (%d value-log files rewritten)\n", n)
+}
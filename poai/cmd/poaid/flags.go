@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"poai/core"
+)
+
+// stringList is a repeatable flag that also accepts comma-separated values.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// subcommandGenesis returns the genesis of the chain a subcommand opens,
+// from genesisFile if given or else the network preset, and applies its
+// parameters. A devnet genesis gets target and the current epoch length.
+// Unless fs has --data-dir set, dataDir moves into the network's
+// subdirectory, as the daemon's does.
+func subcommandGenesis(fs *flag.FlagSet, network, genesisFile string, target int64, dataDir *string) *core.Genesis {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if genesisFile != "" {
+		if set["network"] {
+			log.Fatalf("--genesis and --network cannot be combined")
+		}
+		g, err := core.LoadGenesis(genesisFile)
+		if err != nil {
+			log.Fatalf("Genesis: %v", err)
+		}
+		g.Apply()
+		return g
+	}
+	n, err := core.LookupNetwork(network)
+	if err != nil {
+		log.Fatalf("Invalid --network: %v", err)
+	}
+	g := n.Genesis()
+	if n.Name == core.NetworkDevnet {
+		g.Target = target
+	}
+	if sub := n.DataSubdir(); sub != "" && !set["data-dir"] {
+		*dataDir = filepath.Join(*dataDir, sub)
+	}
+	g.Apply()
+	return g
+}
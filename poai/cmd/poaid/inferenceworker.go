@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"poai/inference"
+	"poai/inference/remote"
+)
+
+// handleInferenceWorkerCommand runs `poaid inference-worker`: serve the
+// local model to mining nodes started with --inference-workers.
+func handleInferenceWorkerCommand() {
+	fs := flag.NewFlagSet("inference-worker", flag.ExitOnError)
+	listen := fs.String("listen", ":50051", "gRPC listen address")
+	parallel := fs.Int("parallel", 1, "Inferences run at once; further requests queue")
+	modelPath := fs.String("model-path", "models/qwen2.5-0.5b-instruct-q4k.gguf", "Path to GGUF LLM model file")
+	modelSHA256 := fs.String("model-sha256", "", "Refuse to start unless the model has this SHA-256 (the chain's commitment)")
+	gpuLayers := fs.Int("gpu-layers", 0, "Number of LLM layers to offload to GPU (0=CPU only)")
+	tlsCert := fs.String("tls-cert", "", "PEM certificate to serve over TLS (needs --tls-key); without it only nodes on this machine can connect")
+	tlsKey := fs.String("tls-key", "", "PEM private key of --tls-cert")
+	clientCA := fs.String("client-ca", "", "Only serve nodes presenting a client certificate signed by this PEM CA (needs --tls-cert)")
+	fs.Parse(os.Args[2:])
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatalf("[FATAL] --tls-cert and --tls-key must be given together")
+	}
+	if *clientCA != "" && *tlsCert == "" {
+		log.Fatalf("[FATAL] --client-ca needs --tls-cert")
+	}
+	// Nodes ask for the model hash before sending work, so it is always
+	// computed
+	hash, err := inference.HashModel(*modelPath)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	if want := strings.ToLower(strings.TrimPrefix(*modelSHA256, "0x")); want != "" && hash != want {
+		log.Fatalf("[FATAL] model %s has SHA-256 %s, chain is committed to %s", *modelPath, hash, want)
+	}
+	log.Printf("🔒 Serving model %s (SHA-256 %s)", *modelPath, hash)
+	llm, err := inference.NewLLM(*modelPath, *gpuLayers)
+	if err != nil {
+		log.Fatalf("Failed to load LLM: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	srv := remote.NewServer(llm, hash, *parallel)
+	if *tlsCert != "" {
+		srv.SetTLS(*tlsCert, *tlsKey, *clientCA)
+	}
+	if err := srv.ListenAndServe(ctx, *listen); err != nil {
+		log.Fatalf("Inference worker: %v", err)
+	}
+}
@@ -2,23 +2,41 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"poai/core"
 	"poai/core/config"
+	"poai/core/keyschedule"
+	"poai/core/storage"
+	"poai/dataset"
+	"poai/inference"
+	"poai/inference/remote"
+	"poai/logging"
 	"poai/miner"
 	"poai/net"
+	"poai/pool"
+	"poai/rpc"
+	"poai/rpc/jwt"
+	"poai/tracing"
+	"poai/validator"
+	"poai/wallet"
 
 	"runtime/debug"
 
-	"github.com/libp2p/go-libp2p/core/peer"
-	ma "github.com/multiformats/go-multiaddr"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -29,52 +47,383 @@ func main() {
 	os.Setenv("GGML_LOG_LEVEL", "0")
 
 	var (
-		target        = flag.Int64("target", -1000000000000000000, "Mining difficulty target (more negative = harder)")
-		epochBlocks   = flag.Uint64("epoch-blocks", 20, "Blocks per epoch")
+		target        = flag.Int64("target", dataset.DefaultTarget, "Mining loss target (lower = harder; below 1000000 only fully correct answers count; default: the network's)")
+		epochBlocks   = flag.Uint64("epoch-blocks", 20, "Blocks per epoch of the devnet chain")
 		batchSize     = flag.Int("batch-size", 2, "Records per batch")
 		dataDir       = flag.String("data-dir", "data", "Directory for chain data")
-		pruneDepth    = flag.Uint64("prune-depth", 0, "Blocks to keep (0 = keep all, disables pruning)")
+		dbEngine      = flag.String("db-engine", "", "Storage engine for a new data directory: badger (default), pebble or leveldb; existing directories keep theirs")
+		dbGCInterval  = flag.Duration("db-gc-interval", 10*time.Minute, "How often to reclaim stale database space (Badger value-log GC; 0 = never)")
+		dbGCRatio     = flag.Float64("db-gc-discard-ratio", storage.DefaultDiscardRatio, "Share of stale data that makes a value-log file worth rewriting")
+		ephemeral     = flag.Bool("ephemeral", false, "Keep the chain in memory and everything else in a temporary directory removed on exit; nothing survives a restart")
+		networkName   = flag.String("network", core.NetworkDevnet, "Network preset: mainnet, testnet, regtest or devnet (data in <data-dir>/<network>, except devnet)")
+		genesisFile   = flag.String("genesis", "", "genesis.json with the chain ID, target, epoch/retarget parameters, block spacing, model hash and premine of a custom network (instead of --network)")
+		regtest       = flag.Bool("regtest", false, "Run a local regtest chain: trivial target, stub inference, blocks mined on demand with miner_generate (same as --network=regtest)")
+		pruneDepth    = flag.Uint64("prune-depth", 0, "Blocks to keep for --role=pruned, at least the difficulty retarget window (0 = 1000 or that window if larger)")
+		role          = flag.String("role", "", "Node role: archive, full, pruned or light (default full, or pruned if --prune-depth is set)")
+		archive       = flag.Bool("archive", false, "Keep every block and all state history (same as --role=archive)")
+		ancientDepth  = flag.Uint64("ancient-depth", config.AncientDepth, "Move finalized blocks this far below the head out of the database into flat era files in <data-dir>/ancient (0 = never)")
+		blockCache    = flag.Int("block-cache", config.DefaultBlockCacheSize, "Recent blocks kept in memory; older ones are read from the database")
+		maxOrphans    = flag.Int("max-orphans", config.MaxOrphans, "Blocks with unknown parents held while the parents are fetched")
+		maxOrphanMB   = flag.Int("max-orphan-mb", config.MaxOrphanBytes>>20, "Memory cap of the orphan pool in MB")
+		orphanExpiry  = flag.Duration("orphan-expiry", config.OrphanExpiry, "Drop orphans whose parent has not arrived within this long")
+		maxReorgDepth = flag.Uint64("max-reorg-depth", config.MaxReorgDepth, "Refuse reorgs replacing more canonical blocks than this and raise an alert (0 = no limit)")
+		reindex       = flag.Bool("reindex", false, "Rebuild the transaction indexes and supply counters from the stored blocks before starting")
 		p2pPort       = flag.Int("p2p-port", 4001, "P2P listen port")
-		peerMultiaddr = flag.String("peer-multiaddr", "", "Multiaddr of peer to connect to (optional)")
+		p2pQUIC       = flag.Bool("quic", true, "Also listen for QUIC on UDP --p2p-port (IPv4 and IPv6)")
+		p2pWSPort     = flag.Int("p2p-ws-port", 0, "WebSocket P2P listen port for browser clients (0 = disabled)")
+		p2pWTPort     = flag.Int("p2p-webtransport-port", 0, "WebTransport (UDP) P2P listen port for browser clients (0 = disabled)")
+		maxUpKbps     = flag.Int64("max-upload-kbps", 0, "Total P2P upload limit in KB/s (0 = unlimited)")
+		maxDownKbps   = flag.Int64("max-download-kbps", 0, "Total P2P download limit in KB/s (0 = unlimited)")
+		peerUpKbps    = flag.Int64("peer-max-upload-kbps", 0, "Per-peer P2P upload limit in KB/s (0 = unlimited)")
+		peerDownKbps  = flag.Int64("peer-max-download-kbps", 0, "Per-peer P2P download limit in KB/s (0 = unlimited)")
+		peersLow      = flag.Int("peers-low", net.DefaultPeersLow, "Peer count that excess connections are trimmed down to")
+		peersHigh     = flag.Int("peers-high", net.DefaultPeersHigh, "Peer count above which the least useful connections are closed")
+		outboundPeers = flag.Int("outbound-peers", net.DefaultOutboundPeers, "Outbound connections to keep, each in a distinct /16 (IPv4) or /32 (IPv6), dialed from known peers (0 = only bootstrap and static peers)")
+		outboundRot   = flag.Duration("outbound-rotation", net.DefaultOutboundRotation, "Replace one outbound connection this often, so no peer set is kept for good (0 = never)")
+		peerMultiaddr = flag.String("peer-multiaddr", "", "Multiaddr of peer to keep connected to (optional; same as one --static-peers entry)")
+		bootstrapFile = flag.String("bootstrap-peers-file", "", "File listing bootstrap peer multiaddrs, one per line (# comments allowed)")
 		modelPath     = flag.String("model-path", "models/qwen2.5-0.5b-instruct-q4k.gguf", "Path to GGUF LLM model file")
+		modelSHA256   = flag.String("model-sha256", "", "Hex SHA-256 the chain commits to for the model file (recorded at first start)")
 		gpuLayers     = flag.Int("gpu-layers", 0, "Number of LLM layers to offload to GPU (0=CPU only)")
+		corpusDir     = flag.String("corpus", "", "Directory of the encrypted corpus (Σ.idx, Σ.bin); its records precede every quiz (empty = procedural quizzes only)")
+		corpusHash    = flag.String("corpus-hash", "", "Hex SHA3-256 of the corpus Σ.idx; missing records are fetched from peers")
+		inferTimeout  = flag.Duration("inference-timeout", remote.DefaultTimeout, "Timeout for one inference on a remote worker")
+		inferCA       = flag.String("inference-ca", "", "PEM CA to check remote inference workers' TLS certificates against (without it only workers on this machine are allowed, in plaintext)")
+		inferCert     = flag.String("inference-cert", "", "PEM client certificate for inference workers that require one (needs --inference-key)")
+		inferKey      = flag.String("inference-key", "", "PEM private key of --inference-cert")
+		mine          = flag.Bool("mine", true, "Start mining at launch (toggle at runtime with miner_start/miner_stop)")
+		minerThreads  = flag.Int("miner-threads", 1, "Mining workers searching disjoint nonce ranges in parallel")
+		poolAddr      = flag.String("pool-addr", "", "Serve pool workers on this TCP address, e.g. :3333 (empty = disabled)")
+		poolShares    = flag.Int64("pool-share-factor", pool.DefaultShareFactor, "How many times easier pool shares are than blocks")
 		minerAddress  = flag.String("miner-address", "", "Miner address (hex) for block rewards")
+		keystoreDir   = flag.String("keystore", "", "Keystore directory; the miner address key (default: its only account) and the --checkpoint-address key are unlocked from it")
+		passwordFile  = flag.String("password-file", "", "File holding the keystore passphrase (default: $POAI_PASSWORD or prompt)")
+		rpcHost       = flag.String("rpc-host", "127.0.0.1", "JSON-RPC listen host")
+		rpcPort       = flag.Int("rpc-port", 8545, "JSON-RPC listen port (0 = disabled)")
+		rpcTokenFile  = flag.String("rpc-token-file", "", "File holding a bearer token JSON-RPC callers must send (empty = no token)")
+		rpcJWTSecret  = flag.String("rpc-jwt-secret", "", "Hex secret file for HS256 JWTs JSON-RPC callers may send instead; created if missing (empty = no JWT)")
+		rpcPublicRO   = flag.Bool("rpc-public-readonly", false, "Let JSON-RPC callers without credentials use the read-only poai_* methods")
+		rpcTLSCert    = flag.String("rpc-tls-cert", "", "PEM certificate to serve JSON-RPC over HTTPS/WSS (needs --rpc-tls-key)")
+		rpcTLSKey     = flag.String("rpc-tls-key", "", "PEM private key of --rpc-tls-cert")
+		metricsAddr   = flag.String("metrics-addr", "", "Serve Prometheus metrics on this address, e.g. 127.0.0.1:9100 (empty = disabled)")
+		otlpEndpoint  = flag.String("otlp-endpoint", "", "Export tracing spans to this OTLP/gRPC collector, e.g. 127.0.0.1:4317 (empty = disabled)")
+		otlpInsecure  = flag.Bool("otlp-insecure", false, "Connect to the OTLP collector without TLS")
+		traceSample   = flag.Float64("trace-sample-ratio", 1, "Share of traces exported, 0 to 1")
+		readyMaxLag   = flag.Uint64("ready-max-lag", 10, "Blocks the node may be behind the best known head and still pass /readyz")
+		signerAddress = flag.String("checkpoint-address", "", "Keystore account (hex address) that signs checkpoints, unlocked with --password-file (signer nodes only)")
+		fastBootstrap = flag.Bool("fast-bootstrap", false, "Bootstrap an empty node from the latest signed checkpoint and state snapshot")
+		finalityEps   = flag.Uint64("finality-epochs", config.FinalityEpochs, "Epochs between finalized checkpoints; reorgs below the last one are refused (0 = disabled)")
+		fastSync      = flag.Bool("fast-sync", false, "Sync an empty node from a peer state snapshot verified against the header chain's state root; the snapshot height must be vouched for by a checkpoint or served by peers from 3 network groups")
+		relay         = flag.Bool("relay", false, "Run as a non-mining relay/seed node")
+		natPortMap    = flag.Bool("nat", true, "Map the P2P port on the router via UPnP/NAT-PMP and detect reachability with AutoNAT")
+		holePunching  = flag.Bool("hole-punching", true, "Upgrade relayed connections to direct ones via hole punching")
+		newIdentity   = flag.Bool("new-identity", false, "Discard the saved P2P identity key and generate a new Peer ID")
+		relayService  = flag.Bool("relay-service", false, "Act as a circuit relay for peers behind NAT (default on with --relay)")
+		verifyBlocks  = flag.Bool("verify-blocks", true, "Replay the PoAI work of blocks received from peers before importing them (default false with --relay, which then runs without loading the model)")
+		verifyWorkers = flag.Int("verify-workers", 0, "Parallel block verifications during sync (0 = number of CPUs)")
+		logLevel      = flag.String("log-level", "info", "Log level, globally and/or per module, e.g. warn,p2p=debug (modules: chain, p2p, miner, mempool, rpc, node)")
+		logFormat     = flag.String("log-format", "text", "Log output format: text or json")
+		trustLocal    = flag.Bool("trust-local-blocks", true, "Skip PoAI replay for blocks this node mined itself")
 	)
+	var listenAddrs, announceAddrs stringList
+	flag.Var(&listenAddrs, "listen-addr", "P2P listen multiaddr, repeatable (overrides --p2p-port and --quic), e.g. /ip6/::/tcp/4001 or /ip4/0.0.0.0/udp/4001/quic-v1")
+	var bootstrapPeers stringList
+	flag.Var(&bootstrapPeers, "bootstrap-peers", "Peer multiaddrs to dial on startup with retry, repeatable or comma-separated")
+	var staticPeers stringList
+	flag.Var(&staticPeers, "static-peers", "Peer multiaddrs to keep connected, redialed whenever they drop; repeatable or comma-separated")
+	var checkpointSigners stringList
+	flag.Var(&checkpointSigners, "checkpoint-signers", "Trusted checkpoint signer addresses (hex), repeatable or comma-separated")
+	flag.Var(&announceAddrs, "announce-addr", "Multiaddr advertised to peers instead of detected ones, repeatable (static NAT)")
+	var inferWorkers stringList
+	flag.Var(&inferWorkers, "inference-workers", "Remote inference workers (host:port) to run the model on instead of loading it, repeatable or comma-separated")
+	var rpcOrigins stringList
+	flag.Var(&rpcOrigins, "rpc-allowed-origins", "Web origins (scheme://host:port, or *) allowed to call JSON-RPC besides its own, repeatable or comma-separated")
+	var rpcVHosts stringList
+	flag.Var(&rpcVHosts, "rpc-vhosts", "Host names (or *) JSON-RPC answers requests for, repeatable or comma-separated (default localhost and loopback IPs)")
+	var relayPeers stringList
+	flag.Var(&relayPeers, "relay-peers", "Circuit relay multiaddrs to reserve a slot on when behind NAT, repeatable or comma-separated")
 	flag.Parse()
 
-	// Set config from flags
+	if err := logging.Setup(os.Stderr, *logFormat, *logLevel); err != nil {
+		log.Fatalf("Invalid logging flags: %v", err)
+	}
+	stopTracing, traceErr := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint:    *otlpEndpoint,
+		Insecure:    *otlpInsecure,
+		SampleRatio: *traceSample,
+		Service:     "poaid",
+	})
+	if traceErr != nil {
+		log.Fatalf("Invalid tracing flags: %v", traceErr)
+	}
+	if *otlpEndpoint != "" {
+		log.Printf("🔭 Exporting traces to %s", *otlpEndpoint)
+	}
+
+	// Set config from flags; a genesis file overrides the consensus ones
 	config.EpochBlocks = *epochBlocks
 	config.BatchSize = *batchSize
-	config.PruneDepth = *pruneDepth
+	config.FinalityEpochs = *finalityEps
+
+	for _, s := range checkpointSigners {
+		addr, err := hex.DecodeString(s)
+		if err != nil {
+			log.Fatalf("Invalid --checkpoint-signers entry %q: %v", s, err)
+		}
+		core.CheckpointSigners = append(core.CheckpointSigners, addr)
+	}
+	if *fastBootstrap && len(core.CheckpointSigners) == 0 {
+		log.Fatalf("--fast-bootstrap requires --checkpoint-signers")
+	}
+
+	nodeRole := config.RoleFull
+	if *archive {
+		if *role != "" && *role != string(config.RoleArchive) {
+			log.Fatalf("--archive conflicts with --role=%s", *role)
+		}
+		*role = string(config.RoleArchive)
+	}
+	if *role != "" {
+		r, err := config.ParseNodeRole(*role)
+		if err != nil {
+			log.Fatalf("Invalid --role: %v", err)
+		}
+		nodeRole = r
+	} else if *pruneDepth > 0 {
+		nodeRole = config.RolePruned
+	}
+	config.DBEngine = *dbEngine
+	config.AncientDepth = *ancientDepth
+	config.BlockCacheSize = *blockCache
+	config.MaxOrphans = *maxOrphans
+	config.MaxOrphanBytes = *maxOrphanMB << 20
+	config.OrphanExpiry = *orphanExpiry
+	config.MaxReorgDepth = *maxReorgDepth
+
+	if nodeRole == config.RoleLight {
+		// Light nodes never load the LLM, neither to mine nor to verify
+		*relay = true
+	}
+
+	if *keystoreDir != "" && !*relay {
+		addr, err := unlockMinerKey(*keystoreDir, *minerAddress, *passwordFile)
+		if err != nil {
+			log.Fatalf("Keystore: %v", err)
+		}
+		*minerAddress = addr
+	}
+	var signerKey *ecdsa.PrivateKey
+	if *signerAddress != "" {
+		if *keystoreDir == "" {
+			log.Fatalf("--checkpoint-address needs --keystore")
+		}
+		key, err := unlockKey(*keystoreDir, *signerAddress, *passwordFile, "checkpoint signer")
+		if err != nil {
+			log.Fatalf("Keystore: %v", err)
+		}
+		signerKey = key
+	}
+
+	// The network preset, or a genesis file for a custom network, sets the
+	// consensus parameters; the devnet takes its target and epoch length
+	// from the flags
+	network, err := core.LookupNetwork(*networkName)
+	if err != nil {
+		log.Fatalf("[FATAL] Invalid --network: %v", err)
+	}
+	if *regtest {
+		if flagSet("network") && network.Name != core.NetworkRegtest {
+			log.Fatalf("[FATAL] --regtest conflicts with --network=%s", network.Name)
+		}
+		network, _ = core.LookupNetwork(core.NetworkRegtest)
+	}
+	if *genesisFile != "" && (flagSet("network") || *regtest) {
+		log.Fatalf("[FATAL] --genesis defines its own network and cannot be combined with --network or --regtest")
+	}
+	*regtest = network.Name == core.NetworkRegtest
+	var genesis *core.Genesis
+	source := network.Name
+	if *genesisFile != "" {
+		if genesis, err = core.LoadGenesis(*genesisFile); err != nil {
+			log.Fatalf("[FATAL] Genesis: %v", err)
+		}
+		source = *genesisFile
+	} else {
+		genesis = network.Genesis()
+		core.Deployments = network.Deployments
+		if network.Name == core.NetworkDevnet {
+			genesis.Target = *target
+		}
+		if sub := network.DataSubdir(); sub != "" && !flagSet("data-dir") {
+			*dataDir = filepath.Join(*dataDir, sub)
+		}
+	}
+	genesis.Apply()
+	// Pruning must keep the headers the network's difficulty rule reads
+	if err := config.ApplyRole(nodeRole, *pruneDepth); err != nil {
+		log.Fatalf("Invalid node configuration: %v", err)
+	}
+	if flagSet("epoch-blocks") && *epochBlocks != genesis.EpochBlocks {
+		log.Printf("[WARN] --epoch-blocks=%d ignored; %s sets %d", *epochBlocks, source, genesis.EpochBlocks)
+	}
+	if !flagSet("target") {
+		*target = genesis.Target
+	}
+	if *modelSHA256 == "" {
+		*modelSHA256 = genesis.ModelSHA256
+	} else if genesis.ModelSHA256 != "" && !strings.EqualFold(strings.TrimPrefix(*modelSHA256, "0x"), genesis.ModelSHA256) {
+		log.Fatalf("[FATAL] --model-sha256 differs from the genesis model hash %s", genesis.ModelSHA256)
+	}
+	if *regtest && !flagSet("mine") {
+		*mine = false // blocks come from miner_generate
+	}
+	if *relay && !flagSet("verify-blocks") {
+		*verifyBlocks = false // relays run without the model unless asked
+	}
 
 	log.Printf("Starting POAI daemon...")
-	log.Printf("Config: EpochBlocks=%d, BatchSize=%d, PruneDepth=%d",
-		config.EpochBlocks, config.BatchSize, config.PruneDepth)
-	log.Printf("Mining target: %d", *target)
+	log.Printf("Config: Role=%s, EpochBlocks=%d, BatchSize=%d, PruneDepth=%d, StateHistory=%d",
+		config.Role, config.EpochBlocks, config.BatchSize, config.PruneDepth, config.StateHistory)
+	if *relay {
+		log.Printf("Running as relay node: P2P, sync and block serving only, mining disabled")
+	} else {
+		log.Printf("Mining target: %d", *target)
+	}
 
-	// Open chain
-	chain := core.NewChain(*dataDir, int64(*target))
+	// Open chain; the genesis hash identifies the network
+	var chain *core.Chain
+	if *ephemeral {
+		tmp, tmpErr := os.MkdirTemp("", "poaid-ephemeral-")
+		if tmpErr != nil {
+			log.Fatalf("[FATAL] %v", tmpErr)
+		}
+		defer os.RemoveAll(tmp)
+		*dataDir = tmp
+		log.Printf("🫧 Ephemeral node: chain kept in memory, scratch files in %s", tmp)
+		chain, err = core.NewMemoryChain(genesis)
+	} else {
+		chain, err = core.NewChainFromGenesis(*dataDir, genesis)
+	}
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	log.Printf("🌐 Network %s: chain ID %d, genesis %x, %s difficulty, %ds blocks", source, config.ChainID,
+		chain.BlockByHeight(0).Hash(), config.DifficultyAlgorithm, config.TargetBlockSpacingSec)
 
-	// FULL REINDEX from DB before starting anything else
-	if err := chain.ReindexFromDB(); err != nil {
-		log.Fatalf("[FATAL] Failed to reindex chain from DB: %v", err)
+	// The persisted indexes are trusted unless a reindex is asked for
+	if *reindex {
+		if err := chain.ReindexFromDB(); err != nil {
+			log.Fatalf("[FATAL] Failed to reindex chain from DB: %v", err)
+		}
 	}
 	chain.LogDiagnostics()
 
+	// Consensus depends on the exact model file; refuse to mine or verify
+	// with anything but the committed one
+	committed, err := chain.CommitModel(*modelSHA256)
+	if err != nil {
+		log.Fatalf("[FATAL] Model commitment: %v", err)
+	}
+	config.ModelSHA256 = committed
+	needsModel := !*regtest && nodeRole != config.RoleLight && (!*relay || *verifyBlocks)
+	if needsModel && len(inferWorkers) == 0 {
+		if committed == "" {
+			log.Printf("[WARN] No model commitment configured; nodes with different models will disagree")
+		} else if err := inference.VerifyModel(*modelPath, committed); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		} else {
+			log.Printf("🔒 Model %s matches committed SHA-256 %s", *modelPath, committed)
+		}
+	}
+
+	// One engine runs every inference: mining, pool shares and block
+	// replay. Remote workers must report the committed model before they
+	// get work.
+	var engine inference.Engine
+	if *regtest {
+		engine = inference.Stub{}
+		log.Printf("🧪 Regtest chain: stub inference, mine blocks with miner_generate or `poaid generate`")
+	} else if needsModel {
+		if len(inferWorkers) > 0 {
+			if committed == "" {
+				log.Printf("[WARN] No model commitment configured; remote workers' models are not checked")
+			}
+			client, err := remote.Dial(inferWorkers, remote.Config{
+				Timeout:     *inferTimeout,
+				ModelSHA256: committed,
+				CAFile:      *inferCA,
+				CertFile:    *inferCert,
+				KeyFile:     *inferKey,
+			})
+			if err != nil {
+				log.Fatalf("[FATAL] Remote inference: %v", err)
+			}
+			defer client.Close()
+			engine = client
+			log.Printf("🧠 Running inference on %d remote workers: %v", len(inferWorkers), []string(inferWorkers))
+		} else {
+			llm, err := inference.NewLLM(*modelPath, *gpuLayers)
+			if err != nil {
+				log.Fatalf("[FATAL] Failed to load LLM: %v", err)
+			}
+			engine = llm
+			log.Printf("Loaded LLM model: %s (GPU layers: %d)", *modelPath, *gpuLayers)
+		}
+	}
+
 	// If orphan pool is non-empty after reindex, log and scan
 	if len(chain.OrphanPool) > 0 {
 		log.Printf("[WARN] Orphan pool non-empty after reindex: %d orphans", len(chain.OrphanPool))
 		chain.ScanOrphanPool()
 	}
 
+	// Replay the AI work of incoming blocks with the configured model
+	chain.VerifyWorkers = *verifyWorkers
+	if *verifyBlocks && nodeRole != config.RoleLight {
+		chain.VerifyProof = validator.NewEngineVerifier(engine).Verify
+		log.Printf("🔍 Block verification enabled (model %s)", *modelPath)
+	} else {
+		log.Printf("[WARN] Block PoAI verification disabled; peers' blocks are trusted")
+	}
+
 	// Now start networking, mining, orphan pool scanner, etc.
 	// Initialize local broadcaster
 	blocksDir := filepath.Join(*dataDir, "blocks")
 	broadcaster := core.NewLocalBroadcaster(blocksDir, chain)
+	broadcaster.TrustLocal = *trustLocal
 
-	// Start P2P node
-	ctx := context.Background()
-	node, err := net.NewP2PNode(ctx, *p2pPort, chain)
+	// Start P2P node; cancelling ctx stops networking, sync and mining
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	identity, err := net.LoadOrCreateIdentity(*dataDir, *newIdentity)
+	if err != nil {
+		log.Fatalf("Failed to load P2P identity: %v", err)
+	}
+	node, err := net.NewP2PNode(ctx, net.P2PConfig{
+		ListenAddrs:      listenAddrs,
+		AnnounceAddrs:    announceAddrs,
+		ListenPort:       *p2pPort,
+		QUIC:             *p2pQUIC,
+		WSPort:           *p2pWSPort,
+		WebTransportPort: *p2pWTPort,
+		FastBootstrap:    *fastBootstrap,
+		FastSync:         *fastSync,
+		Identity:         identity,
+		NAT: net.NATConfig{
+			PortMap:      *natPortMap,
+			HolePunching: *holePunching,
+			RelayPeers:   relayPeers,
+			RelayService: *relayService || *relay,
+		},
+		Bandwidth: net.BandwidthLimits{
+			UploadBps:       *maxUpKbps * 1024,
+			DownloadBps:     *maxDownKbps * 1024,
+			PeerUploadBps:   *peerUpKbps * 1024,
+			PeerDownloadBps: *peerDownKbps * 1024,
+		},
+		Peers:    net.PeerLimits{Low: *peersLow, High: *peersHigh},
+		Outbound: net.OutboundConfig{Target: *outboundPeers, Rotation: *outboundRot},
+	}, chain)
 	if err != nil {
 		log.Fatalf("Failed to start P2P node: %v", err)
 	}
@@ -83,36 +432,158 @@ func main() {
 		log.Printf("Listening on: %s/p2p/%s", addr, node.Host.ID())
 	}
 
+	// Corpus chains read their records with the genesis epoch key. With
+	// --corpus-hash, missing or corrupt records are fetched from peers.
+	if *corpusDir != "" {
+		if *corpusHash != "" {
+			want, err := hex.DecodeString(strings.TrimPrefix(*corpusHash, "0x"))
+			if err != nil || len(want) != 32 {
+				log.Fatalf("[FATAL] --corpus-hash must be 32 bytes of hex")
+			}
+			if err := node.FetchCorpus(ctx, *corpusDir, [32]byte(want)); err != nil {
+				log.Fatalf("[FATAL] Failed to fetch corpus: %v", err)
+			}
+		}
+		corpus, err := dataset.OpenCorpus(*corpusDir, keyschedule.EpochKey(0, chain))
+		if err != nil {
+			log.Fatalf("[FATAL] Failed to open corpus: %v", err)
+		}
+		defer corpus.Close()
+		dataset.SetCorpus(corpus)
+		node.ServeCorpus(corpus)
+		log.Printf("📚 Loaded corpus %s: %d records, %d per block", *corpusDir, corpus.Len(), config.BatchSize)
+	}
+
+	// The miner pauses while the node catches up with its peers
+	minerCtl := miner.NewController(*mine)
+
+	// Liveness and readiness probes, served on the RPC and metrics addresses
+	health := rpc.NewHealth()
+	health.AddLiveness("db", chain.CheckDatabase)
+	health.AddLiveness("p2p", node.CheckHost)
+	health.AddReadiness("sync", func() error { return node.CheckSynced(*readyMaxLag) })
+
+	var rpcServer *rpc.Server
+	if *rpcPort > 0 {
+		rpcServer = rpc.NewServer(chain)
+		rpcServer.SetHealth(health)
+		auth := rpc.AuthConfig{PublicReadOnly: *rpcPublicRO}
+		if *rpcTokenFile != "" {
+			if auth.Token, err = rpc.LoadToken(*rpcTokenFile); err != nil {
+				log.Fatalf("Failed to read --rpc-token-file: %v", err)
+			}
+		}
+		if *rpcJWTSecret != "" {
+			if auth.JWTSecret, err = jwt.LoadSecret(*rpcJWTSecret); err != nil {
+				log.Fatalf("Failed to load --rpc-jwt-secret: %v", err)
+			}
+		}
+		if auth.Token == "" && auth.JWTSecret == nil && *rpcHost != "127.0.0.1" && *rpcHost != "localhost" {
+			log.Printf("⚠️ JSON-RPC on %s has no authentication; set --rpc-token-file or --rpc-jwt-secret", *rpcHost)
+		}
+		rpcServer.SetAuth(auth)
+		if (*rpcTLSCert == "") != (*rpcTLSKey == "") {
+			log.Fatalf("--rpc-tls-cert and --rpc-tls-key must be set together")
+		}
+		if *rpcTLSCert != "" {
+			rpcServer.SetTLS(*rpcTLSCert, *rpcTLSKey)
+		}
+		if len(rpcVHosts) > 0 {
+			rpcServer.SetVirtualHosts(rpcVHosts)
+		} else if *rpcHost != "127.0.0.1" && *rpcHost != "localhost" {
+			log.Printf("⚠️ JSON-RPC on %s answers only requests for localhost; list the names clients use with --rpc-vhosts", *rpcHost)
+		}
+		rpcServer.SetAllowedOrigins(rpcOrigins)
+		rpcServer.RegisterAdmin(node)
+		if !*relay {
+			rpcServer.RegisterMiner(minerCtl)
+		}
+		if *regtest && !*relay {
+			rpcServer.RegisterGenerate(func(n int, address string) ([]*core.Block, error) {
+				if address == "" {
+					address = *minerAddress
+				}
+				return miner.Generate(chain, engine, address, n, func(b *core.Block) {
+					_ = node.PublishBlockFromStruct(b)
+				})
+			})
+		}
+		go func() {
+			addr := fmt.Sprintf("%s:%d", *rpcHost, *rpcPort)
+			if err := rpcServer.ListenAndServe(addr); err != nil && err != http.ErrServerClosed {
+				log.Printf("[RPC] server stopped: %v", err)
+			}
+		}()
+	}
+
+	var metricsServer *http.Server
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		health.Register(mux)
+		metricsServer = &http.Server{Addr: *metricsAddr, Handler: mux}
+		go func() {
+			log.Printf("Serving metrics on http://%s/metrics", *metricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("[METRICS] server stopped: %v", err)
+			}
+		}()
+	}
+
+	if signerKey != nil {
+		node.StartCheckpointSigner(ctx, signerKey)
+		log.Printf("Signing checkpoints every %d blocks as %x", config.CheckpointInterval, crypto.PubkeyToAddress(signerKey.PublicKey).Bytes())
+	}
+
 	// Wire up orphan pool parent request callback
 	chain.RequestBlockByHash = node.RequestBlockByHash
 
 	// Start orphan pool auto-cleaner
 	stopScan := make(chan struct{})
 	chain.StartOrphanPoolScanner(30*time.Second, stopScan)
+	if *dbGCInterval > 0 {
+		chain.StartGarbageCollector(*dbGCInterval, *dbGCRatio, stopScan)
+	}
 
-	// Manual peer connect if provided
-	if *peerMultiaddr != "" {
-		log.Printf("[P2P] Attempting to connect to peer: %s", *peerMultiaddr)
-		addr, err := ma.NewMultiaddr(*peerMultiaddr)
+	// Dial bootstrap peers (flags and peer file) with retry
+	peerAddrs := append([]string{}, bootstrapPeers...)
+	if *bootstrapFile != "" {
+		fromFile, err := net.LoadPeerFile(*bootstrapFile)
 		if err != nil {
-			log.Fatalf("Invalid multiaddr: %v", err)
+			log.Fatalf("Failed to read --bootstrap-peers-file: %v", err)
 		}
-		pi, err := peer.AddrInfoFromP2pAddr(addr)
+		peerAddrs = append(peerAddrs, fromFile...)
+	}
+	if len(peerAddrs) == 0 && *genesisFile == "" {
+		peerAddrs = network.BootstrapPeers
+	}
+	if len(peerAddrs) > 0 {
+		infos, err := net.ParsePeerAddrs(peerAddrs)
 		if err != nil {
-			log.Fatalf("Invalid AddrInfo: %v", err)
+			log.Fatalf("Invalid bootstrap peer: %v", err)
 		}
-		if err := node.Host.Connect(ctx, *pi); err != nil {
-			log.Printf("[P2P] Failed to connect to peer: %v", err)
-		} else {
-			log.Printf("[P2P] Connected to peer: %s", pi.ID.String())
+		log.Printf("[P2P] Connecting to %d bootstrap peers", len(infos))
+		node.ConnectBootstrapPeers(ctx, infos)
+	}
+	// Keep static peers (and --peer-multiaddr) connected
+	if *peerMultiaddr != "" {
+		staticPeers = append(staticPeers, *peerMultiaddr)
+	}
+	if len(staticPeers) > 0 {
+		infos, err := net.ParsePeerAddrs(staticPeers)
+		if err != nil {
+			log.Fatalf("Invalid static peer: %v", err)
 		}
+		log.Printf("[P2P] Keeping %d static peers connected", len(infos))
+		node.AddStaticPeers(ctx, infos)
 	}
 
 	// Announce new heads after each block is accepted
-	headCh := chain.SubscribeToHeadChanges()
+	headSub := chain.SubscribeToHeadChanges()
+	defer headSub.Unsubscribe()
 	go func() {
 		var lastHeight uint64 = 0
-		for range headCh {
+		for range headSub.C {
 			h := chain.CurrentHeight()
 			if h == lastHeight {
 				continue // avoid duplicate publish
@@ -135,44 +606,145 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Remove the syncCtl and pause/resume goroutine for now
-	// syncCtl := miner.NewSyncControl()
-	// importing := false
-	// bestKnown := func() uint64 { return chain.CurrentHeight() }
-	// chainHeadCh := chain.SubscribeToHeadChanges()
-	// go func() {
-	// 	for range chainHeadCh {
-	// 		if importing {
-	// 			syncCtl.PauseCh <- true
-	// 			for bestKnown() < chain.CurrentHeight() {
-	// 				time.Sleep(100 * time.Millisecond)
-	// 			}
-	// 			syncCtl.PauseCh <- false
-	// 			importing = false
-	// 		}
-	// 	}
-	// }()
-
 	// Start block processing in a goroutine
+	var workers sync.WaitGroup
+	workers.Add(1)
 	go func() {
-		broadcaster.ProcessBlocks()
+		defer workers.Done()
+		broadcaster.ProcessBlocks(ctx)
 	}()
 
-	// Start mining in a goroutine
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[MINER] PANIC: %v\n%s", r, debug.Stack())
+	// Start mining in a goroutine (relay nodes never mine)
+	if !*relay {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			minerCtl.WatchSync(ctx, node.Syncing, time.Second)
+		}()
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[MINER] PANIC: %v\n%s", r, debug.Stack())
+				}
+			}()
+			miner.WorkLoop(ctx, chain, *target, broadcaster, node, engine, *minerAddress, *minerThreads, minerCtl)
+		}()
+	}
+
+	// Serve pool workers; the pool replays their shares with its own model
+	if *poolAddr != "" && !*relay {
+		cfg := pool.Config{Addr: *poolAddr, MinerAddress: *minerAddress, Target: *target, ShareFactor: *poolShares}
+		poolServer := pool.NewServer(cfg, chain, engine, func(b *core.Block) {
+			if err := broadcaster.BroadcastBlock(b); err != nil {
+				log.Printf("Failed to broadcast block: %v", err)
+			}
+			_ = node.PublishBlockFromStruct(b)
+		})
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := poolServer.ListenAndServe(ctx); err != nil {
+				log.Printf("[POOL] Server error: %v", err)
 			}
 		}()
-		// modelPath and gpuLayers are parsed here for LLM integration in miner/validator
-		_ = modelPath
-		_ = gpuLayers
-		miner.WorkLoop(chain, *target, broadcaster, node, *modelPath, *gpuLayers, *minerAddress)
-	}()
+	}
 
 	// Wait for shutdown signal
 	<-sigChan
 	log.Printf("Shutting down...")
+	go func() {
+		<-sigChan
+		log.Fatalf("Second signal received, exiting immediately")
+	}()
+	shutdown(cancel, stopScan, &workers, rpcServer, metricsServer, node, chain)
+	flushCtx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer done()
+	if err := stopTracing(flushCtx); err != nil {
+		log.Printf("[TRACE] flush: %v", err)
+	}
+}
+
+// unlockMinerKey decrypts the key for address from the keystore, proving the
+// rewards go to an account we control. With no address, the keystore's only
+// account is used. It returns the unlocked address.
+func unlockMinerKey(dir, address, passwordFile string) (string, error) {
+	if address == "" {
+		accounts, err := wallet.Accounts(dir)
+		if err != nil {
+			return "", err
+		}
+		if len(accounts) != 1 {
+			return "", fmt.Errorf("%d accounts in %s; choose one with --miner-address", len(accounts), dir)
+		}
+		address = accounts[0]
+	}
+	key, err := unlockKey(dir, address, passwordFile, "miner")
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()), nil
+}
+
+// unlockKey decrypts the key of keystore account address, used as role,
+// with the passphrase from passwordFile, $POAI_PASSWORD or a prompt, so
+// keys never appear on the command line.
+func unlockKey(dir, address, passwordFile, role string) (*ecdsa.PrivateKey, error) {
+	pass, err := wallet.ReadPassphrase(passwordFile, "Passphrase for "+role+" account "+address+": ")
+	if err != nil {
+		return nil, err
+	}
+	key, err := wallet.Unlock(dir, address, pass)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🔓 Unlocked %s account %x from %s", role, crypto.PubkeyToAddress(key.PublicKey).Bytes(), dir)
+	return key, nil
+}
+
+// shutdownTimeout bounds how long shutdown waits for each subsystem.
+const shutdownTimeout = 30 * time.Second
+
+// shutdown stops the daemon's subsystems in dependency order: producers
+// first (mining, block processing, networking), then the servers, the
+// libp2p host and finally the database.
+func shutdown(cancel context.CancelFunc, stopScan chan struct{}, workers *sync.WaitGroup,
+	rpcServer *rpc.Server, metricsServer *http.Server, node *net.P2PNode, chain *core.Chain) {
+	cancel()
 	close(stopScan)
+
+	ctx, done := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer done()
+	if rpcServer != nil {
+		if err := rpcServer.Shutdown(ctx); err != nil {
+			log.Printf("[RPC] shutdown: %v", err)
+		}
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Printf("[METRICS] shutdown: %v", err)
+		}
+	}
+
+	// The miner finishes its current inference before noticing cancellation
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Printf("[WARN] Miner or block processor did not stop within %v", shutdownTimeout)
+	}
+
+	if err := node.Close(); err != nil {
+		log.Printf("[P2P] close: %v", err)
+	}
+	if err := chain.Close(); err != nil {
+		log.Printf("[ERROR] Failed to close database: %v", err)
+		return
+	}
+	log.Printf("Shutdown complete")
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"poai/inference"
+	"poai/pool"
+)
+
+// poolRetryDelay is the wait before reconnecting to a pool.
+const poolRetryDelay = 5 * time.Second
+
+// handlePoolWorkerCommand runs `poaid pool-worker`: mine jobs from a pool
+// server, reconnecting until interrupted.
+func handlePoolWorkerCommand() {
+	fs := flag.NewFlagSet("pool-worker", flag.ExitOnError)
+	addr := fs.String("pool", "127.0.0.1:3333", "Pool server address (host:port)")
+	name := fs.String("name", "", "Worker name reported to the pool (default: hostname)")
+	threads := fs.Int("threads", 1, "Parallel inferences")
+	modelPath := fs.String("model-path", "models/qwen2.5-0.5b-instruct-q4k.gguf", "Path to GGUF LLM model file")
+	gpuLayers := fs.Int("gpu-layers", 0, "Number of LLM layers to offload to GPU (0=CPU only)")
+	fs.Parse(os.Args[2:])
+
+	if *name == "" {
+		*name, _ = os.Hostname()
+	}
+	llm, err := inference.NewLLM(*modelPath, *gpuLayers)
+	if err != nil {
+		log.Fatalf("Failed to load LLM: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	w := &pool.Worker{Addr: *addr, Name: *name, Threads: *threads, LLM: llm}
+	for {
+		err := w.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[POOL] Disconnected from %s: %v; retrying in %v", *addr, err, poolRetryDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(poolRetryDelay):
+		}
+	}
+}
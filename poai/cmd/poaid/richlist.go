@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// handleRichListCommand prints a page of a running node's largest
+// accounts with their share of the supply.
+func handleRichListCommand() {
+	fs := flag.NewFlagSet("richlist", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of the node")
+	offset := fs.Int("offset", 0, "Number of largest accounts to skip")
+	limit := fs.Int("limit", 20, "Number of accounts to show (at most 1000)")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	timeout := fs.Duration("timeout", 30*time.Second, "How long to wait for the node")
+	fs.Parse(os.Args[2:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	list, err := newRPCClient(*rpcURL).GetRichList(ctx, *offset, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ No rich list from %s: %v\n", *rpcURL, err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		out, _ := json.MarshalIndent(list, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("%d accounts hold %s at #%d\n\n", list.Accounts, list.TotalSupply, list.Height)
+	fmt.Printf("%-6s %-42s %-24s %s\n", "Rank", "Address", "Balance", "Share")
+	for i, h := range list.Holders {
+		share := 0.0
+		if list.TotalSupply.Sign() > 0 {
+			share, _ = new(big.Rat).SetFrac(new(big.Int).Mul(h.Balance, big.NewInt(100)), list.TotalSupply).Float64()
+		}
+		fmt.Printf("%-6d %-42s %-24s %.4f%%\n", list.Offset+i+1, hex.EncodeToString(h.Address), h.Balance, share)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"poai/client"
+	"poai/core"
+	"poai/miner"
+	"poai/rpc"
+)
+
+// nodeStatus is what `poaid status` reports.
+type nodeStatus struct {
+	Height    uint64        `json:"height"`
+	Head      string        `json:"head"`
+	Syncing   bool          `json:"syncing"`
+	BestKnown uint64        `json:"bestKnownHeight"`
+	Peers     int           `json:"peers"`
+	Mempool   int           `json:"mempool"`
+	Queued    int           `json:"queued"`
+	Miner     *miner.Status `json:"miner,omitempty"` // nil if the node has no miner
+	// ReorgAlert is set once the node refused a reorg deeper than
+	// --max-reorg-depth
+	ReorgAlert *core.ReorgAlert `json:"reorgAlert,omitempty"`
+}
+
+// handleStatusCommand prints a one-shot summary of a running node. It
+// exits with status 1 if the node does not answer.
+func handleStatusCommand() {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of the node")
+	asJSON := fs.Bool("json", false, "Print the status as JSON")
+	timeout := fs.Duration("timeout", 10*time.Second, "How long to wait for the node")
+	fs.Parse(os.Args[2:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	st, err := queryStatus(ctx, newRPCClient(*rpcURL))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ No status from %s: %v\n", *rpcURL, err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(st, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	head := st.Head
+	if len(head) > 16 {
+		head = head[:16]
+	}
+	fmt.Printf("Height:   #%d (%s…)\n", st.Height, head)
+	if st.Syncing {
+		fmt.Printf("Sync:     syncing, best known #%d\n", st.BestKnown)
+	} else {
+		fmt.Printf("Sync:     in sync\n")
+	}
+	fmt.Printf("Peers:    %d\n", st.Peers)
+	fmt.Printf("Mempool:  %d pending, %d queued\n", st.Mempool, st.Queued)
+	switch m := st.Miner; {
+	case m == nil:
+		fmt.Printf("Miner:    none\n")
+	case m.Mining:
+		fmt.Printf("Miner:    mining\n")
+	case m.Enabled && m.Syncing:
+		fmt.Printf("Miner:    paused while syncing\n")
+	case m.Enabled:
+		fmt.Printf("Miner:    enabled, idle\n")
+	default:
+		fmt.Printf("Miner:    stopped\n")
+	}
+	if a := st.ReorgAlert; a != nil {
+		fmt.Printf("Alert:    🚨 refused a %d-block reorg forking at #%d (branch tip #%d %.16s…) at %s\n",
+			a.Depth, a.ForkHeight, a.BranchTip, a.BranchHash, a.Time.Local().Format(time.RFC3339))
+	}
+}
+
+// queryStatus collects the node status over JSON-RPC.
+func queryStatus(ctx context.Context, c *client.Client) (*nodeStatus, error) {
+	var info struct {
+		Height     uint64           `json:"height"`
+		Head       string           `json:"head"`
+		Peers      int              `json:"peers"`
+		Syncing    bool             `json:"syncing"`
+		BestKnown  uint64           `json:"bestKnownHeight"`
+		ReorgAlert *core.ReorgAlert `json:"reorgAlert"`
+	}
+	if err := c.Call(ctx, "admin_nodeInfo", &info); err != nil {
+		return nil, err
+	}
+	var pool struct {
+		Size   int `json:"size"`
+		Queued int `json:"queued"`
+	}
+	if err := c.Call(ctx, "poai_mempoolStats", &pool); err != nil {
+		return nil, err
+	}
+	st := &nodeStatus{
+		Height:    info.Height,
+		Head:      info.Head,
+		Syncing:   info.Syncing,
+		BestKnown: info.BestKnown,
+		Peers:     info.Peers,
+		Mempool:   pool.Size,
+		Queued:    pool.Queued,
+
+		ReorgAlert: info.ReorgAlert,
+	}
+	// Relay nodes do not register the miner methods
+	var m miner.Status
+	var rpcErr *client.RPCError
+	switch err := c.Call(ctx, "miner_status", &m); {
+	case err == nil:
+		st.Miner = &m
+	case errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeMethodNotFound:
+	default:
+		return nil, err
+	}
+	return st, nil
+}
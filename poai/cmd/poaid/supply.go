@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// handleSupplyCommand prints a running node's total supply and cumulative
+// issuance, or with --height the emission of blocks, for checking the
+// halving schedule.
+func handleSupplyCommand() {
+	fs := flag.NewFlagSet("supply", flag.ExitOnError)
+	rpcURL := fs.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of the node")
+	height := fs.Int64("height", -1, "Show the emission of this block instead of the totals")
+	count := fs.Uint64("count", 1, "With --height, the number of consecutive blocks to show")
+	asJSON := fs.Bool("json", false, "Print the result as JSON")
+	timeout := fs.Duration("timeout", 30*time.Second, "How long to wait for the node")
+	fs.Parse(os.Args[2:])
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := newRPCClient(*rpcURL)
+
+	if *height < 0 {
+		sup, err := c.GetSupply(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ No supply from %s: %v\n", *rpcURL, err)
+			os.Exit(1)
+		}
+		if *asJSON {
+			out, _ := json.MarshalIndent(sup, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
+		fmt.Printf("Height:        #%d\n", sup.Height)
+		fmt.Printf("Total supply:  %s\n", sup.TotalSupply)
+		fmt.Printf("Issued:        %s (genesis allocation and subsidies)\n", sup.Issued)
+		fmt.Printf("Burned:        %s (unclaimed subsidies and fees)\n", sup.Burned)
+		return
+	}
+
+	if !*asJSON {
+		fmt.Printf("%-10s %-16s %-12s %-16s %s\n", "Height", "Subsidy", "Fees", "Reward", "Burned")
+	}
+	for h := uint64(*height); h < uint64(*height)+*count; h++ {
+		e, err := c.GetEmission(ctx, h)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ No emission for block #%d from %s: %v\n", h, *rpcURL, err)
+			os.Exit(1)
+		}
+		if *asJSON {
+			out, _ := json.Marshal(e)
+			fmt.Println(string(out))
+			continue
+		}
+		fmt.Printf("%-10d %-16s %-12s %-16s %s\n", e.Height, e.Subsidy, e.Fees, e.Reward, e.Burned)
+	}
+}
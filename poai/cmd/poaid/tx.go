@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"poai/core"
+	"poai/wallet"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// unsignedTx is the file `poaid tx create` writes and `poaid tx sign`
+// reads: the signed fields of a transaction in a form that is easy to
+// check by eye on the offline machine.
+type unsignedTx struct {
+	Type     uint8  `json:"type,omitempty"`
+	Data     string `json:"data,omitempty"` // hex
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Amount   string `json:"amount"` // decimal
+	Nonce    uint64 `json:"nonce"`
+	GasLimit uint64 `json:"gasLimit"`
+	GasPrice string `json:"gasPrice"` // decimal
+}
+
+func (u *unsignedTx) transaction() (*core.Transaction, error) {
+	tx := &core.Transaction{Type: u.Type, Nonce: u.Nonce, GasLimit: u.GasLimit}
+	var err error
+	if tx.Data, err = hex.DecodeString(u.Data); err != nil {
+		return nil, fmt.Errorf("data: %v", err)
+	}
+	if tx.From, err = hex.DecodeString(strings.TrimPrefix(u.From, "0x")); err != nil || len(tx.From) == 0 {
+		return nil, fmt.Errorf("invalid from address %q", u.From)
+	}
+	if tx.To, err = hex.DecodeString(strings.TrimPrefix(u.To, "0x")); err != nil {
+		return nil, fmt.Errorf("invalid to address %q", u.To)
+	}
+	var ok bool
+	if tx.Amount, ok = new(big.Int).SetString(u.Amount, 10); !ok || tx.Amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", u.Amount)
+	}
+	if tx.GasPrice, ok = new(big.Int).SetString(u.GasPrice, 10); !ok || tx.GasPrice.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gas price %q", u.GasPrice)
+	}
+	return tx, nil
+}
+
+// handleTxCommand dispatches `poaid tx <create|sign|broadcast>`, which
+// split `poaid send` so the signing key can stay on a machine without
+// network access.
+func handleTxCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: poaid tx <create|sign|broadcast> [flags]")
+		os.Exit(1)
+	}
+	switch os.Args[2] {
+	case "create":
+		handleTxCreate()
+	case "sign":
+		handleTxSign()
+	case "broadcast":
+		handleTxBroadcast()
+	default:
+		fmt.Printf("Unknown tx command %q (want create, sign or broadcast)\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleTxCreate writes an unsigned transfer, asking the node for the
+// sender's nonce unless --nonce is given.
+func handleTxCreate() {
+	fs := flag.NewFlagSet("tx create", flag.ExitOnError)
+	from := fs.String("from", "", "Sender address (hex)")
+	to := fs.String("to", "", "Recipient address (hex)")
+	amount := fs.String("amount", "", "Amount to send")
+	gasPrice := fs.String("gas-price", "1", "Gas price")
+	nonceFlag := fs.Int64("nonce", -1, "Transaction nonce (-1 = fetch from node)")
+	rpcURL := fs.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint asked for the nonce")
+	out := fs.String("out", "-", "File to write the unsigned transaction to (- = stdout)")
+	fs.Parse(os.Args[3:])
+
+	if *from == "" || *to == "" || *amount == "" {
+		fmt.Println("Usage: poaid tx create -from=<address> -to=<address> -amount=<amount> [-nonce=<n>] [-out=<file>]")
+		os.Exit(1)
+	}
+	u := unsignedTx{From: *from, To: *to, Amount: *amount, GasLimit: 21000, GasPrice: *gasPrice}
+	tx, err := u.transaction()
+	if err != nil {
+		log.Fatalf("Invalid transaction: %v", err)
+	}
+	if *nonceFlag >= 0 {
+		u.Nonce = uint64(*nonceFlag)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if u.Nonce, err = newRPCClient(*rpcURL).GetNonce(ctx, tx.From); err != nil {
+			log.Fatalf("Failed to fetch nonce from %s (pass --nonce when offline): %v", *rpcURL, err)
+		}
+	}
+	data, _ := json.MarshalIndent(&u, "", "  ")
+	if err := writeOutput(*out, append(data, '\n')); err != nil {
+		log.Fatalf("Write %s: %v", *out, err)
+	}
+	if *out != "-" {
+		fmt.Printf("📝 Unsigned transaction written to %s; sign it with `poaid tx sign --in=%s`\n", *out, *out)
+	}
+}
+
+// handleTxSign signs an unsigned transaction with a keystore key and
+// writes the hex of its RLP encoding. It makes no network connections.
+func handleTxSign() {
+	fs := flag.NewFlagSet("tx sign", flag.ExitOnError)
+	// Not stdin, which the passphrase prompt reads
+	in := fs.String("in", "", "Unsigned transaction file from `poaid tx create`")
+	out := fs.String("out", "-", "File to write the signed transaction to (- = stdout)")
+	keystoreDir := fs.String("keystore", "keystore", "Keystore directory holding the sender's key")
+	passwordFile := fs.String("password-file", "", "File holding the keystore passphrase (default: $POAI_PASSWORD or prompt)")
+	fs.Parse(os.Args[3:])
+	if *in == "" {
+		fmt.Println("Usage: poaid tx sign -in=<file> [-keystore=<dir>] [-out=<file>]")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("Read %s: %v", *in, err)
+	}
+	var u unsignedTx
+	if err := json.Unmarshal(data, &u); err != nil {
+		log.Fatalf("Invalid unsigned transaction: %v", err)
+	}
+	tx, err := u.transaction()
+	if err != nil {
+		log.Fatalf("Invalid unsigned transaction: %v", err)
+	}
+	// The summary goes to stderr so stdout carries only the signed hex
+	fmt.Fprintf(os.Stderr, "Signing transaction:\n")
+	printTx(os.Stderr, tx)
+
+	pass, err := wallet.ReadPassphrase(*passwordFile, "Passphrase for "+u.From+": ")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	key, err := wallet.Unlock(*keystoreDir, hex.EncodeToString(tx.From), pass)
+	if err != nil {
+		log.Fatalf("Failed to unlock %s: %v", u.From, err)
+	}
+	if addr := crypto.PubkeyToAddress(key.PublicKey).Bytes(); !bytes.Equal(addr, tx.From) {
+		log.Fatalf("Keystore key is for %x, not the sender %x", addr, tx.From)
+	}
+	if err := tx.Sign(key); err != nil {
+		log.Fatalf("Failed to sign transaction: %v", err)
+	}
+	raw, err := tx.Encode()
+	if err != nil {
+		log.Fatalf("Encode transaction: %v", err)
+	}
+	if err := writeOutput(*out, []byte(hex.EncodeToString(raw)+"\n")); err != nil {
+		log.Fatalf("Write %s: %v", *out, err)
+	}
+	fmt.Fprintf(os.Stderr, "✍️  Signed transaction %s\n", hex.EncodeToString(tx.Hash))
+}
+
+// handleTxBroadcast submits a signed transaction to a node.
+func handleTxBroadcast() {
+	fs := flag.NewFlagSet("tx broadcast", flag.ExitOnError)
+	in := fs.String("in", "-", "Signed transaction file from `poaid tx sign` (- = stdin)")
+	rpcURL := fs.String("rpc", "http://127.0.0.1:8545", "JSON-RPC endpoint of a running node")
+	fs.Parse(os.Args[3:])
+
+	data, err := readInput(*in)
+	if err != nil {
+		log.Fatalf("Read %s: %v", *in, err)
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil {
+		log.Fatalf("Signed transaction is not hex: %v", err)
+	}
+	tx, err := core.DecodeTransaction(raw)
+	if err != nil {
+		log.Fatalf("Invalid signed transaction: %v", err)
+	}
+	if err := tx.Verify(); err != nil || tx.IsCoinbase() {
+		log.Fatalf("Transaction is not signed by its sender: %v", err)
+	}
+	fmt.Printf("Broadcasting transaction:\n")
+	printTx(os.Stdout, tx)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := newRPCClient(*rpcURL).SendRawTransaction(ctx, raw); err != nil {
+		fmt.Printf("\n❌ Transaction rejected: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Transaction accepted into the mempool of %s\n", *rpcURL)
+}
+
+func printTx(w io.Writer, tx *core.Transaction) {
+	fmt.Fprintf(w, "  From: %s\n", hex.EncodeToString(tx.From))
+	fmt.Fprintf(w, "  To: %s\n", hex.EncodeToString(tx.To))
+	fmt.Fprintf(w, "  Amount: %s\n", tx.Amount)
+	fmt.Fprintf(w, "  Nonce: %d\n", tx.Nonce)
+	fmt.Fprintf(w, "  Gas: %d at %s\n", tx.GasLimit, tx.GasPrice)
+	if len(tx.Hash) > 0 {
+		fmt.Fprintf(w, "  Hash: %s\n", hex.EncodeToString(tx.Hash))
+	}
+}
+
+// readInput reads path, or stdin for "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes data to path, or stdout for "-".
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
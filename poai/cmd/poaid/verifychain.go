@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/dataset"
+	"poai/validator"
+)
+
+// handleVerifyChainCommand re-verifies a stopped node's stored chain and
+// reports the first inconsistency found.
+func handleVerifyChainCommand() {
+	fs := flag.NewFlagSet("verify-chain", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "data", "Data directory of the chain")
+	from := fs.Uint64("from", 0, "First block to verify")
+	to := fs.Uint64("to", 0, "Last block to verify (0 = tip)")
+	network := fs.String("network", core.NetworkDevnet, "Network preset of the chain: mainnet, testnet, regtest or devnet")
+	genesisFile := fs.String("genesis", "", "genesis.json of a custom network's chain (instead of --network)")
+	epochBlocks := fs.Uint64("epoch-blocks", 20, "Blocks per epoch of the devnet chain")
+	verifyWork := fs.Float64("verify-work", 0, "Share of blocks whose AI work is replayed, 0 to 1")
+	modelPath := fs.String("model-path", "models/qwen2.5-0.5b-instruct-q4k.gguf", "Path to GGUF LLM model file, for --verify-work")
+	gpuLayers := fs.Int("gpu-layers", 0, "Number of LLM layers to offload to GPU (0=CPU only)")
+	fs.Parse(os.Args[2:])
+	if *verifyWork < 0 || *verifyWork > 1 {
+		log.Fatalf("--verify-work must be between 0 and 1")
+	}
+
+	config.EpochBlocks = *epochBlocks
+	subcommandGenesis(fs, *network, *genesisFile, dataset.DefaultTarget, dataDir)
+
+	store, err := core.OpenStoreReadOnly(*dataDir)
+	if err != nil {
+		log.Fatalf("Open database in %s (stop the node first): %v", *dataDir, err)
+	}
+	defer store.Close()
+
+	opts := core.VerifyChainOptions{From: *from, To: *to, ProofSample: *verifyWork}
+	if *verifyWork > 0 {
+		v, err := validator.NewVerifier(*modelPath, *gpuLayers)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		opts.VerifyProof = v.Verify
+		log.Printf("🔍 Replaying the AI work of %.0f%% of blocks (model %s)", *verifyWork*100, *modelPath)
+	}
+	start, last := time.Now(), time.Now()
+	opts.Progress = func(h uint64) {
+		if time.Since(last) >= 10*time.Second {
+			last = time.Now()
+			log.Printf("[VERIFY] Block #%d (%s)", h, time.Since(start).Round(time.Second))
+		}
+	}
+
+	res, err := store.VerifyChain(opts)
+	var bad *core.ChainInconsistency
+	if errors.As(err, &bad) {
+		fmt.Printf("❌ %v\n", bad)
+		fmt.Printf("   %d blocks from #%d verified before it\n", res.Blocks, res.From)
+		store.Close()
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatalf("Verify: %v", err)
+	}
+	fmt.Printf("✅ Verified blocks #%d-#%d (%d blocks, %d proofs replayed) in %s\n",
+		res.From, res.To, res.Blocks, res.Proofs, time.Since(start).Round(time.Millisecond))
+	if res.SkippedTarget > 0 {
+		fmt.Printf("   Targets of %d blocks not checked: their retarget window is pruned\n", res.SkippedTarget)
+	}
+}
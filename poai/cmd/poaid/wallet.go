@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"poai/wallet"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// handleWalletCommand dispatches `poaid wallet <new|restore|derive>`.
+func handleWalletCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: poaid wallet <new|restore|derive> [flags]")
+		os.Exit(1)
+	}
+	switch os.Args[2] {
+	case "new":
+		handleWalletNew()
+	case "restore":
+		handleWalletRestore()
+	case "derive":
+		handleWalletDerive()
+	default:
+		fmt.Printf("Unknown wallet command %q (want new, restore or derive)\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// walletFlags are shared by the wallet subcommands.
+type walletFlags struct {
+	keystoreDir  *string
+	passwordFile *string
+	seedPass     *string
+	save         *bool
+}
+
+func newWalletFlags(fs *flag.FlagSet) walletFlags {
+	return walletFlags{
+		keystoreDir:  fs.String("keystore", "keystore", "Keystore directory for saved accounts"),
+		passwordFile: fs.String("password-file", "", "File holding the keystore passphrase (default: $POAI_PASSWORD or prompt)"),
+		seedPass:     fs.String("seed-passphrase", "", "Optional BIP-39 passphrase (\"25th word\")"),
+		save:         fs.Bool("save", false, "Encrypt the derived keys into the keystore"),
+	}
+}
+
+func handleWalletNew() {
+	fs := flag.NewFlagSet("wallet new", flag.ExitOnError)
+	words := fs.Int("words", 24, "Mnemonic length: 12 or 24 words")
+	count := fs.Int("count", 1, "Number of accounts to derive")
+	wf := newWalletFlags(fs)
+	fs.Parse(os.Args[3:])
+
+	bits := 256
+	switch *words {
+	case 12:
+		bits = 128
+	case 24:
+	default:
+		log.Fatalf("--words must be 12 or 24")
+	}
+	mnemonic, err := wallet.NewMnemonic(bits)
+	if err != nil {
+		log.Fatalf("Failed to generate mnemonic: %v", err)
+	}
+
+	fmt.Printf("🌱 Recovery phrase (write it down and keep it offline):\n")
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   %s\n", mnemonic)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("⚠️  Anyone with this phrase controls every account derived from it.\n\n")
+	deriveAccounts(mnemonic, 0, *count, wf)
+}
+
+func handleWalletRestore() {
+	fs := flag.NewFlagSet("wallet restore", flag.ExitOnError)
+	mnemonicFile := fs.String("mnemonic-file", "", "File holding the recovery phrase (default: prompt)")
+	count := fs.Int("count", 1, "Number of accounts to restore")
+	wf := newWalletFlags(fs)
+	fs.Parse(os.Args[3:])
+
+	deriveAccounts(readMnemonic(*mnemonicFile), 0, *count, wf)
+}
+
+func handleWalletDerive() {
+	fs := flag.NewFlagSet("wallet derive", flag.ExitOnError)
+	mnemonicFile := fs.String("mnemonic-file", "", "File holding the recovery phrase (default: prompt)")
+	index := fs.Uint("index", 0, "Account index under "+wallet.DefaultBasePath)
+	path := fs.String("path", "", "Full derivation path, overriding --index (e.g. m/44'/60'/0'/0/5)")
+	wf := newWalletFlags(fs)
+	fs.Parse(os.Args[3:])
+
+	mnemonic := readMnemonic(*mnemonicFile)
+	if *path != "" {
+		seed, err := wallet.SeedFromMnemonic(mnemonic, *wf.seedPass)
+		if err != nil {
+			log.Fatalf("Invalid recovery phrase: %v", err)
+		}
+		key, err := wallet.DeriveKey(seed, *path)
+		if err != nil {
+			log.Fatalf("Derivation failed: %v", err)
+		}
+		printAndSave(*path, key, wf, walletPassphrase(wf))
+		return
+	}
+	deriveAccounts(mnemonic, uint32(*index), 1, wf)
+}
+
+// deriveAccounts prints (and with --save stores) count accounts starting at
+// index first.
+func deriveAccounts(mnemonic string, first uint32, count int, wf walletFlags) {
+	seed, err := wallet.SeedFromMnemonic(mnemonic, *wf.seedPass)
+	if err != nil {
+		log.Fatalf("Invalid recovery phrase: %v", err)
+	}
+	pass := walletPassphrase(wf)
+	for i := 0; i < count; i++ {
+		path := wallet.AccountPath(first + uint32(i))
+		key, err := wallet.DeriveKey(seed, path)
+		if err != nil {
+			log.Fatalf("Derivation of %s failed: %v", path, err)
+		}
+		printAndSave(path, key, wf, pass)
+	}
+}
+
+// walletPassphrase reads the keystore passphrase when keys will be saved.
+func walletPassphrase(wf walletFlags) string {
+	if !*wf.save {
+		return ""
+	}
+	pass, err := wallet.ReadPassphrase(*wf.passwordFile, "Keystore passphrase: ")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if pass == "" {
+		log.Fatalf("Refusing to encrypt keys with an empty passphrase")
+	}
+	return pass
+}
+
+// printAndSave prints the address at path and, with --save, encrypts the
+// key into the keystore.
+func printAndSave(path string, key *ecdsa.PrivateKey, wf walletFlags, pass string) {
+	addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	fmt.Printf("💰 %s  %s\n", path, addr)
+	if !*wf.save {
+		return
+	}
+	file, err := wallet.StoreKey(*wf.keystoreDir, key, pass)
+	if err != nil {
+		log.Fatalf("Failed to save key to keystore: %v", err)
+	}
+	fmt.Printf("   💾 Saved to %s\n", file)
+}
+
+// readMnemonic reads the recovery phrase from file, or prompts for it.
+func readMnemonic(file string) string {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read --mnemonic-file: %v", err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	fmt.Fprint(os.Stderr, "Recovery phrase: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read recovery phrase: %v", err)
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+// Blocks deep enough that they can no longer be reorged are frozen: moved
+// out of the key-value engine into the ancient store (see storage.Ancient),
+// one item per height. block:<height> keeps mapping the canonical height to
+// the block hash, and ancient:<hash> maps the hash back to the height, so
+// lookups work either way; the ancient store holds the encoded block.
+
+// ancientDir is the ancient store's subdirectory of the data directory.
+const ancientDir = "ancient"
+
+// frozenKey holds the number of blocks frozen, which may be fewer than the
+// ancient store holds after a crash or a rewind; the rest are dropped.
+var frozenKey = []byte("meta:frozen")
+
+// freezeBatch caps the blocks frozen per call, so that freezing the
+// history of an existing node does not stall an import.
+const freezeBatch = 1024
+
+func ancientKey(h [32]byte) []byte {
+	return append([]byte("ancient:"), h[:]...)
+}
+
+// openAncient opens the ancient store in dataDir and drops the blocks it
+// holds beyond the frozen count. In read-only mode a missing store is
+// left nil.
+func (s *Store) openAncient(dataDir string, readOnly bool) error {
+	dir := filepath.Join(dataDir, ancientDir)
+	var err error
+	if readOnly {
+		s.ancient, err = storage.OpenAncientReadOnly(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	} else {
+		s.ancient, err = storage.OpenAncient(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(frozenKey)
+		if err == storage.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.frozen, err = strconv.ParseUint(string(val), 10, 64)
+		return err
+	}); err != nil {
+		return err
+	}
+	if items := s.ancient.Items(); items < s.frozen {
+		return fmt.Errorf("ancient store in %s holds %d blocks, the database expects %d", dir, items, s.frozen)
+	}
+	if readOnly {
+		return nil
+	}
+	return s.ancient.Truncate(s.frozen)
+}
+
+// Frozen returns the number of blocks, from genesis up, kept in the
+// ancient store.
+func (s *Store) Frozen() uint64 {
+	return s.frozen
+}
+
+// getAncient returns the encoded frozen block with hash h.
+func (s *Store) getAncient(h [32]byte) ([]byte, error) {
+	if s.ancient == nil {
+		return nil, storage.ErrNotFound
+	}
+	var height uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(ancientKey(h))
+		if err != nil {
+			return err
+		}
+		if len(val) != 8 {
+			return fmt.Errorf("ancient index entry of %x is %d bytes", h[:8], len(val))
+		}
+		height = binary.BigEndian.Uint64(val)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if height >= s.frozen {
+		return nil, storage.ErrNotFound
+	}
+	return s.ancient.Get(height)
+}
+
+// Freeze moves the canonical blocks below height that are not frozen yet
+// into the ancient store, at most freezeBatch of them, and returns how many
+// it moved. The ancient store is synced before the blocks are deleted from
+// the database.
+func (s *Store) Freeze(height uint64) (int, error) {
+	if s.ancient == nil || height <= s.frozen {
+		return 0, nil
+	}
+	if s.ancient.Items() > s.frozen {
+		if err := s.ancient.Truncate(s.frozen); err != nil {
+			return 0, err
+		}
+	}
+	height = min(height, s.frozen+freezeBatch)
+	hashes := make([][32]byte, 0, height-s.frozen)
+	for h := s.frozen; h < height; h++ {
+		var hash [32]byte
+		err := s.db.View(func(txn storage.Txn) error {
+			val, err := txn.Get(canonKey(h))
+			if err != nil {
+				return err
+			}
+			copy(hash[:], val)
+			val, err = txn.Get(hashKey(hash))
+			if err != nil {
+				return err
+			}
+			return s.ancient.Append(h, val)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("freeze block #%d: %w", h, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := s.ancient.Sync(); err != nil {
+		return 0, err
+	}
+
+	// The count goes first: should the batch be cut short, the blocks are
+	// frozen and some merely left behind in the database as well
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	if err := wb.Set(frozenKey, []byte(strconv.FormatUint(height, 10))); err != nil {
+		return 0, err
+	}
+	for i, hash := range hashes {
+		var val [8]byte
+		binary.BigEndian.PutUint64(val[:], s.frozen+uint64(i))
+		if err := wb.Set(ancientKey(hash), val[:]); err != nil {
+			return 0, err
+		}
+		if err := wb.Delete(hashKey(hash)); err != nil {
+			return 0, err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	s.frozen = height
+	return len(hashes), nil
+}
+
+// freezeBlocks moves blocks more than AncientDepth below the head, and
+// below the finalized checkpoint, into the ancient store. Pruned nodes
+// delete old blocks instead.
+func (c *Chain) freezeBlocks() {
+	depth := config.AncientDepth
+	if depth == 0 || config.PruneDepth > 0 || c.head < depth {
+		return
+	}
+	below := c.head - depth + 1
+	if config.FinalityInterval() > 0 && below > c.finalized+1 {
+		below = c.finalized + 1
+	}
+	n, err := c.store.Freeze(below)
+	if err != nil {
+		log.Printf("[ANCIENT] Failed to freeze blocks below #%d: %v", below, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("🧊 Moved %d blocks to the ancient store (%d frozen)", n, c.store.Frozen())
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+func TestFreezeBlocks(t *testing.T) {
+	defer func(depth, prune, epochs uint64) {
+		config.AncientDepth, config.PruneDepth, config.FinalityEpochs = depth, prune, epochs
+	}(config.AncientDepth, config.PruneDepth, config.FinalityEpochs)
+	config.AncientDepth, config.PruneDepth, config.FinalityEpochs = 2, 0, 0
+
+	dir := t.TempDir()
+	c := NewChain(dir, 1000)
+	importCoinbaseBlocks(t, c, bytes.Repeat([]byte{9}, 20), 5)
+	if got := c.store.Frozen(); got != 4 {
+		t.Fatalf("%d blocks frozen, want 4", got)
+	}
+	hashes := make([][32]byte, 6)
+	for h := range hashes {
+		hashes[h] = c.BlockByHeight(uint64(h)).Hash()
+	}
+	c.Close()
+
+	check := func(s *Store, frozen uint64) {
+		t.Helper()
+		for h, hash := range hashes {
+			if blk, err := s.GetBlock(uint64(h)); err != nil || blk.Hash() != hash {
+				t.Fatalf("block #%d = %v, %v", h, blk, err)
+			}
+			if blk, err := s.GetBlockByHash(hash); err != nil || blk.Header.Height != uint64(h) {
+				t.Fatalf("block %x = %v, %v", hash[:4], blk, err)
+			}
+			// Frozen blocks are gone from the database
+			err := s.KV().View(func(txn storage.Txn) error {
+				_, err := txn.Get(hashKey(hash))
+				return err
+			})
+			if (uint64(h) < frozen) != (err == storage.ErrNotFound) {
+				t.Fatalf("block #%d in the database: %v", h, err)
+			}
+		}
+	}
+	s, err := OpenStore(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(s, 4)
+
+	// Rewinding into frozen blocks unfreezes them
+	if err := s.Rewind(2, 5); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if s, err = OpenStore(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	hashes = hashes[:3]
+	check(s, 3)
+	if _, err := s.GetBlock(3); err == nil {
+		t.Fatal("rewound block still stored")
+	}
+	if s.Frozen() != 3 || s.ancient.Items() != 3 {
+		t.Fatalf("%d frozen, %d in the ancient store", s.Frozen(), s.ancient.Items())
+	}
+}
@@ -2,14 +2,11 @@
 package core
 
 import (
-	"encoding/json"
 	"math/big"
 	"time"
 
 	"poai/core/header"
 	"testing"
-
-	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // Constants for block subsidies
@@ -24,17 +21,19 @@ type Block struct {
 	Transactions []*Transaction `json:"transactions"`
 	MerkleRoot   []byte         `json:"merkleRoot"`
 	Time         time.Time      `json:"time"`
-	Receipts     []byte         `json:"receipts"` // Placeholder for receipts
+	// Records are the corpus records whose text preceded the quiz; they
+	// must equal dataset.Indexes(ParentHash) on corpus chains.
+	Records []uint64 `json:"records,omitempty"`
 }
 
 // NewBlock creates a new block with the given parameters.
-func NewBlock(height uint64, parentHash [32]byte, loss int64, parentBits *big.Int, txs []*Transaction, nonce uint64) *Block {
+func NewBlock(height uint64, parentHash [32]byte, loss int64, target *big.Int, txs []*Transaction, nonce uint64) *Block {
 	block := &Block{
 		Header: header.Header{
 			Height:     height,
 			ParentHash: parentHash,
 			Lhat:       loss,
-			Bits:       new(big.Int).Set(parentBits), // always non-nil
+			Bits:       header.BigToCompact(target),
 			Timestamp:  time.Now(),
 			Nonce:      nonce,
 		},
@@ -42,8 +41,9 @@ func NewBlock(height uint64, parentHash [32]byte, loss int64, parentBits *big.In
 		Time:         time.Now(),
 	}
 
-	// Calculate merkle root for transactions
+	// Calculate merkle root for transactions; the header commits to it
 	block.MerkleRoot = block.CalculateMerkleRoot()
+	copy(block.Header.TxRoot[:], block.MerkleRoot)
 
 	return block
 }
@@ -53,23 +53,26 @@ func (b *Block) Hash() [32]byte {
 	return b.Header.Hash()
 }
 
-// CalculateMerkleRoot calculates the merkle root of all transactions
+// CalculateMerkleRoot calculates the binary Merkle root of all transaction hashes
 func (b *Block) CalculateMerkleRoot() []byte {
-	if len(b.Transactions) == 0 {
-		return []byte{} // Empty merkle root for blocks with no transactions
-	}
+	return merkleRoot(b.txLeaves())
+}
 
-	// Simple merkle root: concatenate all transaction hashes and hash the result
-	var hashes []byte
-	for _, tx := range b.Transactions {
+// txLeaves returns the transaction hashes used as Merkle leaves.
+func (b *Block) txLeaves() [][]byte {
+	leaves := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
 		if len(tx.Hash) == 0 {
 			tx.Hash = tx.CalculateHash()
 		}
-		hashes = append(hashes, tx.Hash...)
+		leaves[i] = tx.Hash
 	}
+	return leaves
+}
 
-	// Use keccak256 for EVM compatibility
-	return crypto.Keccak256(hashes)
+// TxProof returns the Merkle sibling path for the transaction at index.
+func (b *Block) TxProof(index int) ([][]byte, error) {
+	return merkleProof(b.txLeaves(), index)
 }
 
 // GetSubsidy calculates the block subsidy for a given height
@@ -84,16 +87,14 @@ func GetSubsidy(height uint64) *big.Int {
 	return subsidy
 }
 
-// Encode serializes the block to JSON for storage/transmission.
-func (b *Block) Encode() ([]byte, error) {
-	return json.Marshal(b)
-}
-
-// DecodeBlock deserializes a block from JSON.
-func DecodeBlock(data []byte) (*Block, error) {
-	var block Block
-	err := json.Unmarshal(data, &block)
-	return &block, err
+// BlockReward is what a coinbase at height may claim: the subsidy plus
+// the fees of txs. Fees a block leaves unclaimed are burned.
+func BlockReward(height uint64, txs []*Transaction) *big.Int {
+	reward := GetSubsidy(height)
+	for _, tx := range txs {
+		reward.Add(reward, tx.Fee())
+	}
+	return reward
 }
 
 // Unit test: round-trip block encode/decode preserves Bits
@@ -103,7 +104,7 @@ func TestBlockBitsRoundTrip(t *testing.T) {
 			Height:     42,
 			ParentHash: [32]byte{1, 2, 3},
 			Lhat:       123,
-			Bits:       big.NewInt(987654321),
+			Bits:       header.BigToCompact(big.NewInt(987654321)),
 			Timestamp:  time.Now(),
 			Nonce:      12345,
 		},
@@ -117,7 +118,7 @@ func TestBlockBitsRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if b2.Header.Bits == nil || b2.Header.Bits.Cmp(b.Header.Bits) != 0 {
+	if b2.Header.Bits != b.Header.Bits {
 		t.Fatalf("Bits did not survive round-trip: got %v, want %v", b2.Header.Bits, b.Header.Bits)
 	}
 }
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"sync"
+
+	"poai/core/config"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// blockCache holds the most recently used canonical blocks, by height and
+// by hash; the rest are read from the database on demand. Genesis is kept
+// for good, since pruned nodes delete it from the database. It has its own
+// lock, since readers holding only c.mu.RLock fill it.
+type blockCache struct {
+	mu       sync.Mutex
+	byHeight *lru.Cache[uint64, *Block]
+	byHash   *lru.Cache[[32]byte, *Block] // the blocks in byHeight
+	genesis  *Block
+}
+
+func newBlockCache(size int) *blockCache {
+	if size <= 0 {
+		size = config.DefaultBlockCacheSize
+	}
+	c := &blockCache{}
+	c.byHash, _ = lru.New[[32]byte, *Block](size) // size is positive
+	c.byHeight, _ = lru.NewWithEvict(size, func(_ uint64, blk *Block) {
+		c.byHash.Remove(blk.Hash())
+	})
+	return c
+}
+
+// add caches blk as the canonical block at its height, replacing the one
+// there before.
+func (c *blockCache) add(blk *Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	height := blk.Header.Height
+	if height == 0 {
+		c.genesis = blk
+	}
+	if old, ok := c.byHeight.Peek(height); ok && old != blk {
+		c.byHash.Remove(old.Hash())
+	}
+	c.byHeight.Add(height, blk)
+	c.byHash.Add(blk.Hash(), blk)
+}
+
+// get returns the cached block at height.
+func (c *blockCache) get(height uint64) *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height == 0 && c.genesis != nil {
+		return c.genesis
+	}
+	blk, _ := c.byHeight.Get(height)
+	return blk
+}
+
+// getByHash returns the cached canonical block with hash h.
+func (c *blockCache) getByHash(h [32]byte) *Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blk, _ := c.byHash.Get(h)
+	return blk
+}
+
+// remove drops the block at height, which is no longer canonical.
+func (c *blockCache) remove(height uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height == 0 {
+		c.genesis = nil
+	}
+	c.byHeight.Remove(height) // the eviction callback drops it from byHash
+}
+
+// len returns the number of cached blocks.
+func (c *blockCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byHeight.Len()
+}
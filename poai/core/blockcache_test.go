@@ -0,0 +1,103 @@
+package core
+
+import (
+	"sync"
+	"testing"
+
+	"poai/core/config"
+)
+
+func TestBlockCacheBoundsMemory(t *testing.T) {
+	defer func(size int) { config.BlockCacheSize = size }(config.BlockCacheSize)
+	config.BlockCacheSize = 4
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	blocks := testBatch(t, c, 10)
+	if _, err := c.ImportBlocks(blocks); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.blocks.len(); n > 4 {
+		t.Fatalf("%d blocks cached, want at most 4", n)
+	}
+	// Evicted blocks come back from the database
+	for _, b := range blocks {
+		if got := c.BlockByHeight(b.Header.Height); got == nil || got.Hash() != b.Hash() {
+			t.Fatalf("block #%d not found", b.Header.Height)
+		}
+		if c.BlockByHash(b.Hash()) == nil {
+			t.Fatalf("block #%d not found by hash", b.Header.Height)
+		}
+	}
+	if c.blocks.get(0) == nil {
+		t.Fatal("genesis evicted")
+	}
+}
+
+func TestBlockCacheReplace(t *testing.T) {
+	cache := newBlockCache(2)
+	a := NewBlock(1, [32]byte{1}, 0, nil, nil, 1)
+	b := NewBlock(1, [32]byte{2}, 0, nil, nil, 2)
+	cache.add(a)
+	cache.add(b)
+	if cache.getByHash(a.Hash()) != nil || cache.get(1) != b {
+		t.Fatal("replaced block still cached")
+	}
+	cache.add(NewBlock(2, b.Hash(), 0, nil, nil, 3))
+	cache.add(NewBlock(3, b.Hash(), 0, nil, nil, 4))
+	if cache.getByHash(b.Hash()) != nil {
+		t.Fatal("evicted block still found by hash")
+	}
+}
+
+func TestBlockCacheConcurrentReaders(t *testing.T) {
+	defer func(size int) { config.BlockCacheSize = size }(config.BlockCacheSize)
+	config.BlockCacheSize = 4
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	blocks := testBatch(t, c, 16)
+	if _, err := c.ImportBlocks(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers under the read lock fill the cache; with go test -race this
+	// checks they do not race each other
+	var wg sync.WaitGroup
+	genesis := c.blocks.get(0)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				c.blocks.add(genesis)
+				c.blocks.get(0)
+			}
+		}()
+	}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				b := blocks[(g*7+i)%len(blocks)]
+				if hdr := c.HeaderByHeight(b.Header.Height); hdr == nil || hdr.Hash() != b.Header.Hash() {
+					t.Errorf("wrong header at #%d", b.Header.Height)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Every block cached by hash is still cached at its height
+	for _, b := range blocks {
+		if blk := c.blocks.getByHash(b.Hash()); blk != nil && c.blocks.get(b.Header.Height) != blk {
+			t.Fatalf("stale hash entry for #%d", b.Header.Height)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"poai/core/header"
+	"poai/core/storage"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BridgeEscrowAddress holds all funds locked for the bridge.
+var BridgeEscrowAddress = crypto.Keccak256([]byte("poai/bridge/escrow"))[12:]
+
+// BridgeAuthority is the address allowed to sign unlock transactions
+// (the relayer multisig/operator). Set from the genesis by Genesis.Apply,
+// so every node of a network agrees on it; nil disables unlocks.
+var BridgeAuthority []byte
+
+// NewBridgeLockTx locks amount in escrow for minting to recipient (a 20-byte
+// EVM address) on the destination chain.
+func NewBridgeLockTx(from []byte, recipient []byte, amount *big.Int, nonce uint64) *Transaction {
+	tx := NewTx(from, BridgeEscrowAddress, amount, nonce)
+	tx.Type = TxBridgeLock
+	tx.Data = recipient
+	return tx
+}
+
+// NewBridgeUnlockTx releases amount from escrow to `to`. burnID identifies the
+// burn on the other chain (e.g. its tx hash) and may only be used once.
+func NewBridgeUnlockTx(authority []byte, to []byte, amount *big.Int, burnID []byte, nonce uint64) *Transaction {
+	tx := NewTx(authority, to, amount, nonce)
+	tx.Type = TxBridgeUnlock
+	tx.Data = burnID
+	return tx
+}
+
+// BridgeLockEvent is emitted for each executed lock transaction; relayers
+// watch for these to mint on the destination chain.
+type BridgeLockEvent struct {
+	TxHash    []byte   `json:"txHash"`
+	Height    uint64   `json:"height"`
+	From      []byte   `json:"from"`
+	Recipient []byte   `json:"recipient"`
+	Amount    *big.Int `json:"amount"`
+}
+
+// validateBridgeTx checks the type-specific rules of a bridge transaction.
+func (s *State) validateBridgeTx(tx *Transaction) error {
+	switch tx.Type {
+	case TxBridgeLock:
+		if !bytes.Equal(tx.To, BridgeEscrowAddress) {
+			return fmt.Errorf("bridge lock must pay the escrow address")
+		}
+		if len(tx.Data) != 20 {
+			return fmt.Errorf("bridge lock recipient must be a 20-byte address")
+		}
+	case TxBridgeUnlock:
+		if BridgeAuthority == nil || !bytes.Equal(tx.From, BridgeAuthority) {
+			return fmt.Errorf("bridge unlock not signed by the bridge authority")
+		}
+		if len(tx.Data) == 0 {
+			return fmt.Errorf("bridge unlock missing burn id")
+		}
+		if s.bridgeUnlockUsed(tx.Data) {
+			return fmt.Errorf("bridge burn %x already unlocked", tx.Data)
+		}
+		if s.GetBalance(BridgeEscrowAddress).Cmp(tx.Amount) < 0 {
+			return fmt.Errorf("bridge escrow has insufficient funds")
+		}
+	default:
+		return fmt.Errorf("unknown transaction type %d", tx.Type)
+	}
+	return nil
+}
+
+func bridgeUnlockKey(burnID []byte) []byte {
+	return append([]byte("bridge:unlocked:"), burnID...)
+}
+
+func (s *State) bridgeUnlockUsed(burnID []byte) bool {
+	err := s.db.View(func(txn storage.Txn) error {
+		_, err := txn.Get(bridgeUnlockKey(burnID))
+		return err
+	})
+	return err == nil
+}
+
+// executeBridgeUnlock moves escrowed funds to the recipient; the authority
+// pays gas like a normal sender.
+func (s *State) executeBridgeUnlock(tx *Transaction, gasCost *big.Int) error {
+	if err := s.SubBalance(tx.From, gasCost); err != nil {
+		return err
+	}
+	if err := s.SubBalance(BridgeEscrowAddress, tx.Amount); err != nil {
+		return err
+	}
+	if err := s.AddBalance(tx.To, tx.Amount); err != nil {
+		return err
+	}
+	return s.db.Update(func(txn storage.Txn) error {
+		return txn.Set(bridgeUnlockKey(tx.Data), []byte{1})
+	})
+}
+
+func bridgeLockKey(txHash []byte) []byte {
+	return []byte("bridge:lock:" + hex.EncodeToString(txHash))
+}
+
+// recordBridgeEvents stores a BridgeLockEvent for every lock in the block.
+func (s *Store) recordBridgeEvents(b *Block) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		for _, tx := range b.Transactions {
+			if tx.Type != TxBridgeLock {
+				continue
+			}
+			ev := BridgeLockEvent{TxHash: tx.Hash, Height: b.Header.Height, From: tx.From, Recipient: tx.Data, Amount: tx.Amount}
+			val, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(bridgeLockKey(tx.Hash), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeBridgeEvents deletes the lock events recordBridgeEvents stored for
+// b, once b leaves the canonical chain.
+func (s *Store) removeBridgeEvents(b *Block) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		for _, tx := range b.Transactions {
+			if tx.Type != TxBridgeLock {
+				continue
+			}
+			if err := txn.Delete(bridgeLockKey(tx.Hash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetBridgeLockEvent looks up the lock event for a transaction hash.
+func (s *Store) GetBridgeLockEvent(txHash []byte) (*BridgeLockEvent, error) {
+	var ev BridgeLockEvent
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(bridgeLockKey(txHash))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(val, &ev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// DepositProof lets a relayer (or a contract on the other chain) check that a
+// lock transaction was included in a POAI block.
+type DepositProof struct {
+	Header     header.Header    `json:"header"`
+	MerkleRoot []byte           `json:"merkleRoot"`
+	Event      *BridgeLockEvent `json:"event"`
+	Tx         *Transaction     `json:"tx"`
+	Index      int              `json:"index"`
+	Siblings   [][]byte         `json:"siblings"`
+}
+
+// BridgeDepositProof builds the inclusion proof for a lock transaction.
+func (c *Chain) BridgeDepositProof(txHash []byte) (*DepositProof, error) {
+	ev, err := c.store.GetBridgeLockEvent(txHash)
+	if err != nil {
+		return nil, fmt.Errorf("no bridge lock with hash %x: %w", txHash, err)
+	}
+	blk := c.BlockByHeight(ev.Height)
+	if blk == nil {
+		if blk, err = c.store.GetBlock(ev.Height); err != nil {
+			return nil, fmt.Errorf("block %d unavailable: %w", ev.Height, err)
+		}
+	}
+	for i, tx := range blk.Transactions {
+		if !bytes.Equal(tx.Hash, txHash) {
+			continue
+		}
+		siblings, err := blk.TxProof(i)
+		if err != nil {
+			return nil, err
+		}
+		return &DepositProof{
+			Header:     blk.Header,
+			MerkleRoot: blk.MerkleRoot,
+			Event:      ev,
+			Tx:         tx,
+			Index:      i,
+			Siblings:   siblings,
+		}, nil
+	}
+	return nil, fmt.Errorf("lock %x not found in block %d", txHash, ev.Height)
+}
+
+// VerifyDepositProof checks a proof's internal consistency: the transaction is
+// a lock matching the event and is included under MerkleRoot. Callers must
+// separately check that Header belongs to the canonical chain.
+func VerifyDepositProof(p *DepositProof) error {
+	if p.Tx == nil || p.Event == nil {
+		return fmt.Errorf("incomplete proof")
+	}
+	if p.Tx.Type != TxBridgeLock {
+		return fmt.Errorf("transaction is not a bridge lock")
+	}
+	hash := p.Tx.CalculateHash()
+	if !bytes.Equal(hash, p.Event.TxHash) || !bytes.Equal(p.Tx.Data, p.Event.Recipient) || p.Tx.Amount.Cmp(p.Event.Amount) != 0 {
+		return fmt.Errorf("event does not match transaction")
+	}
+	if p.Event.Height != p.Header.Height {
+		return fmt.Errorf("event height %d does not match header %d", p.Event.Height, p.Header.Height)
+	}
+	if err := p.Tx.Verify(); err != nil {
+		return err
+	}
+	if !bytes.Equal(p.MerkleRoot, p.Header.TxRoot[:]) {
+		return fmt.Errorf("merkle root does not match header tx root")
+	}
+	if !VerifyMerkleProof(p.MerkleRoot, hash, p.Index, p.Siblings) {
+		return fmt.Errorf("merkle proof does not match root")
+	}
+	return nil
+}
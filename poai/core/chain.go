@@ -1,133 +1,287 @@
 package core
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"os"
 	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
 
 	"poai/core/config"
 	"poai/core/header"
-	"runtime"
+	"poai/tracing"
 	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Chain manages the local blockchain state.
 type Chain struct {
-	mu             sync.RWMutex
-	blocks         map[uint64]*Block
-	blockHashIndex map[[32]byte]*Block // NEW: fast hash lookup
-	head           uint64
-	dataDir        string
+	mu      sync.RWMutex
+	blocks  *blockCache // recent canonical blocks; see blockAt
+	head    uint64
+	dataDir string
 
-	store         *BadgerStore // Persistent storage
-	state         *State       // Account state and transaction execution
-	Mempool       *Mempool     // Pending transactions (exported for mining)
-	genesisTarget int64        // Store the initial mining target for genesis
+	store   *Store   // Persistent storage
+	state   *State   // Account state and transaction execution
+	Mempool *Mempool // Pending transactions (exported for mining)
+	genesis *Genesis // network parameters and premine
 
 	// Head change notifications
 	headChangeCh chan struct{}
-	subscribers  []chan struct{}
+	subscribers  map[*HeadSubscription]struct{}
+	eventSubs    map[*EventSubscription]struct{}
 	subMu        sync.RWMutex
 
 	// Orphan pool for blocks with missing parents
-	OrphanPool map[[32]byte][]*Block // parentHash -> slice of orphans (exported)
-	OrphanMu   sync.RWMutex          // exported
+	OrphanPool  map[[32]byte][]*Block // parentHash -> slice of orphans (exported)
+	OrphanMu    sync.RWMutex          // exported
+	orphans     map[[32]byte]orphanEntry
+	orphanBytes int
 
 	// Side branches for blocks that extend a different parent hash
 	sideBranches map[[32]byte][]*Block // fork tip hash -> branch blocks
 
 	// Callback to request a block by parent hash from P2P
 	RequestBlockByHash func(parentHash [32]byte)
+
+	// Optional PoAI proof check used during batch pre-verification
+	VerifyProof ProofVerifier
+	// VerifyWorkers bounds parallel batch verification (0 = GOMAXPROCS)
+	VerifyWorkers int
+	// FlushEvery is how many blocks ImportBlocks keeps in memory between
+	// database writes (0 = DefaultFlushEvery, 1 = write every block)
+	FlushEvery int
+
+	// Soft-fork deployment states by signalling window, see deploymentState
+	vbMu    sync.Mutex
+	vbCache map[versionBitsKey]ThresholdState
+
+	finalized  uint64      // last finalized checkpoint height; no reorgs below it
+	reorgAlert *ReorgAlert // last reorg refused for its depth
+	closed     bool        // set by Close; imports are refused afterwards
 }
 
-// NewChain creates a new chain instance.
+// ErrChainClosed is returned for imports after Close.
+var ErrChainClosed = errors.New("chain is closed")
+
+// ErrLegacyHeaderHash is returned when opening a database whose blocks were
+// hashed over height, parent hash and nonce only, or encoded before compact
+// bits, so that blocks no longer hash to their children's parent hashes.
+var ErrLegacyHeaderHash = errors.New("database predates the current header encoding; move the data directory aside and resync from genesis")
+
+// NewChain creates a new chain instance on the development genesis.
 func NewChain(dataDir string, genesisTarget int64) *Chain {
+	chain, err := NewChainFromGenesis(dataDir, DefaultGenesis(genesisTarget))
+	if err != nil {
+		log.Fatalf("Failed to open chain: %v", err)
+	}
+	return chain
+}
+
+// NewChainFromGenesis opens the chain in dataDir, creating it from g if
+// empty. An existing chain must have been created from the same genesis.
+func NewChainFromGenesis(dataDir string, g *Genesis) (*Chain, error) {
 	os.MkdirAll(dataDir, 0755)
-	store, err := OpenBadgerStore(dataDir)
+	store, err := OpenStore(dataDir, config.DBEngine)
 	if err != nil {
-		log.Fatalf("Failed to open BadgerDB: %v", err)
+		return nil, fmt.Errorf("open database: %v", err)
 	}
+	return openChain(store, dataDir, g)
+}
+
+// NewMemoryChain creates a chain from g that is kept in memory only and
+// lost when closed.
+func NewMemoryChain(g *Genesis) (*Chain, error) {
+	store, err := OpenMemoryStore()
+	if err != nil {
+		return nil, fmt.Errorf("open in-memory store: %v", err)
+	}
+	return openChain(store, "", g)
+}
 
+// openChain loads the chain held by store, creating it from g if empty.
+// dataDir only names the store in errors; the store is closed on failure.
+func openChain(store *Store, dataDir string, g *Genesis) (*Chain, error) {
+	var err error
 	chain := &Chain{
-		blocks:         make(map[uint64]*Block),
-		blockHashIndex: make(map[[32]byte]*Block), // NEW
-		dataDir:        dataDir,
-		store:          store,
-		genesisTarget:  genesisTarget,
-		headChangeCh:   make(chan struct{}, 16), // Buffered channel
-		subscribers:    make([]chan struct{}, 0),
-		OrphanPool:     make(map[[32]byte][]*Block),
-		sideBranches:   make(map[[32]byte][]*Block),
+		blocks:       newBlockCache(config.BlockCacheSize),
+		dataDir:      dataDir,
+		store:        store,
+		genesis:      g,
+		headChangeCh: make(chan struct{}, 16), // Buffered channel
+		subscribers:  make(map[*HeadSubscription]struct{}),
+		eventSubs:    make(map[*EventSubscription]struct{}),
+		OrphanPool:   make(map[[32]byte][]*Block),
+		orphans:      make(map[[32]byte]orphanEntry),
+		sideBranches: make(map[[32]byte][]*Block),
+		vbCache:      make(map[versionBitsKey]ThresholdState),
 	}
 
 	// Initialize state and mempool
 	chain.state = NewState(store.db)
 	chain.Mempool = NewMempool(chain.state)
 
-	// Load existing blocks from BadgerDB
+	// The head is the highest stored block up to the tip; blocks are read
+	// from the database as they are needed
 	tip, err := store.GetTipHeight()
 	if err == nil {
-		for h := uint64(0); h <= tip; h++ {
-			blk, err := store.GetBlock(h)
-			if err == nil && blk != nil {
-				chain.blocks[h] = blk
-				chain.blockHashIndex[blk.Hash()] = blk // NEW
-				if h > chain.head {
-					chain.head = h
-				}
-			}
+		chain.head = tip
+		for chain.head > 0 && chain.blockAt(chain.head) == nil {
+			chain.head--
 		}
 	}
 
+	if chain.finalized, err = store.GetFinalized(); err != nil {
+		log.Printf("[WARN] Failed to load finalized height: %v", err)
+	}
+
 	// Initialize genesis if empty
-	if len(chain.blocks) == 0 {
+	if chain.head == 0 && chain.blockAt(0) == nil {
+		// Initialize genesis state first so the genesis header commits to
+		// it, and write both at once
+		store.BeginBatch()
+		if err := chain.state.initializeGenesisState(g); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("initialize genesis state: %v", err)
+		}
 		chain.createGenesis()
-		// Initialize genesis state
-		if err := chain.state.InitializeGenesisState(); err != nil {
-			log.Printf("[WARN] Failed to initialize genesis state: %v", err)
+		if err := store.EndBatch(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("write genesis: %v", err)
+		}
+	} else if gen := chain.blockAt(0); gen != nil && gen.Header.ParentHash != g.Hash() {
+		if gen.Header.ParentHash != ([32]byte{}) {
+			store.Close()
+			return nil, fmt.Errorf("%s holds a chain whose genesis file hashes to %x, not %x", dataDir, gen.Header.ParentHash, g.Hash())
 		}
+		log.Printf("[WARN] Chain predates genesis files; cannot check it against the configured genesis")
 	}
 
-	return chain
+	// Blocks written before headers were hashed in full, or before compact
+	// bits, no longer link to their parent's hash; such chains have to be
+	// restarted from genesis. The first two consecutive blocks tell (pruned
+	// nodes may lack block 1).
+	for h := uint64(0); h < chain.head; h++ {
+		parent, child := chain.blockAt(h), chain.blockAt(h+1)
+		if parent == nil || child == nil {
+			continue
+		}
+		if child.Header.ParentHash != parent.Hash() {
+			store.Close()
+			return nil, ErrLegacyHeaderHash
+		}
+		break
+	}
+
+	if tip, err := store.GetTipHeight(); err == nil && tip > 0 {
+		if err := chain.checkConsistency(); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("consistency check: %w", err)
+		}
+	}
+	chain.loadSideBranches()
+
+	return chain, nil
+}
+
+// Genesis returns the genesis the chain was opened with.
+func (c *Chain) Genesis() *Genesis {
+	return c.genesis
+}
+
+// Close waits for in-flight imports, refuses new ones and flushes and
+// closes the database.
+func (c *Chain) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if err := c.store.Sync(); err != nil {
+		log.Printf("[WARN] Failed to sync database: %v", err)
+	}
+	return c.store.Close()
+}
+
+// CheckDatabase reports whether the database still answers reads. It does
+// not wait for imports holding the chain lock.
+func (c *Chain) CheckDatabase() error {
+	if _, err := c.store.GetTipHeight(); err != nil {
+		return fmt.Errorf("read tip: %w", err)
+	}
+	return nil
 }
 
 // createGenesis creates the genesis block.
 func (c *Chain) createGenesis() {
-	genesis := &Block{
-		Header: header.Header{
-			Height:     0,
-			ParentHash: [32]byte{}, // Zero hash for genesis
-			Lhat:       0,
-			Bits:       big.NewInt(c.genesisTarget), // Use the passed-in target
-			Timestamp:  time.Now(),
-			Nonce:      0, // Genesis nonce
-		},
-		Time: time.Now(),
-	}
-
-	c.blocks[0] = genesis
-	c.blockHashIndex[genesis.Hash()] = genesis // NEW
+	var root [32]byte
+	if r, err := c.state.Root(); err == nil {
+		root = r
+	}
+	genesis := c.genesis.Block(root)
+
+	c.blocks.add(genesis)
 	c.head = 0
-	// Persist genesis block to BadgerDB
+	// Persist genesis block to the database
 	if err := c.store.PutBlock(0, genesis); err != nil {
-		log.Printf("[ERROR] Failed to persist genesis block to BadgerDB: %v", err)
+		log.Printf("[ERROR] Failed to persist genesis block to the database: %v", err)
 	} else {
-		log.Printf("🗄️  Genesis block persisted to BadgerDB")
+		log.Printf("🗄️  Genesis block persisted to the database")
 	}
-	log.Printf("📗 Created genesis block at height 0 with target=%d", c.genesisTarget)
+	log.Printf("📗 Created genesis block %x at height 0 with target=%d (chain ID %d, %d accounts funded)",
+		genesis.Hash(), c.genesis.Target, c.genesis.ChainID, len(c.genesis.Alloc))
 }
 
-// ImportBlock validates and imports a new block.
+// ImportBlock validates and imports a new block. If a ProofVerifier is
+// configured, the block's PoAI work is replayed before anything else.
 func (c *Chain) ImportBlock(block *Block) error {
-	return c.importBlockInternal(block, true)
+	return c.ImportBlockContext(context.Background(), block)
+}
+
+// ImportBlockContext is ImportBlock with a context for tracing; it is
+// traced as a child of the span in ctx.
+func (c *Chain) ImportBlockContext(ctx context.Context, block *Block) (err error) {
+	hash := block.Hash()
+	ctx, span := tracer.Start(ctx, "chain.ImportBlock", trace.WithAttributes(
+		attribute.Int64("block.height", int64(block.Header.Height)),
+		attribute.String("block.hash", hex.EncodeToString(hash[:])),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	if err := CheckBlockLimits(block); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlock, err)
+	}
+	if c.VerifyProof != nil {
+		_, vspan := tracer.Start(ctx, "chain.VerifyProof")
+		err := c.VerifyProof(block)
+		tracing.End(vspan, err)
+		if err != nil {
+			log.Printf("❌ Block #%d failed PoAI verification: %v", block.Header.Height, err)
+			return proofError(err)
+		}
+	}
+	return c.importBlockInternal(ctx, block, true)
+}
+
+// ImportTrustedBlock imports a block without replaying its PoAI work. Use it
+// only for blocks mined locally or already verified (e.g. orphans that
+// passed ImportBlock before their parent arrived).
+func (c *Chain) ImportTrustedBlock(block *Block) error {
+	return c.importBlockInternal(context.Background(), block, true)
 }
 
 // importBlockInternal allows disabling orphan pool scan to avoid recursion.
-func (c *Chain) importBlockInternal(block *Block, scanOrphans bool) error {
+// ctx only carries the trace.
+func (c *Chain) importBlockInternal(ctx context.Context, block *Block, scanOrphans bool) error {
 	c.mu.Lock()
 	// *** do NOT defer yet ***
 	unlocked := false
@@ -137,50 +291,37 @@ func (c *Chain) importBlockInternal(block *Block, scanOrphans bool) error {
 		}
 	}()
 
+	if c.closed {
+		return ErrChainClosed
+	}
+	if err := c.checkFinalized(block); err != nil {
+		log.Printf("🔒 Rejected block #%d: %v", block.Header.Height, err)
+		return err
+	}
+
 	// Check if block already exists
-	if existing, exists := c.blocks[block.Header.Height]; exists {
+	if existing := c.blockAt(block.Header.Height); existing != nil {
 		// If the incoming block is not identical, and its parent is not our head, treat as side branch
-		if existing.Hash() != block.Hash() && block.Header.ParentHash != c.blocks[c.head].Hash() {
-			parentHash := block.Header.ParentHash
-			localHeadHash := c.blocks[c.head].Hash()
-			c.addToSideBranch(block)
-			log.Printf("🌿 Block #%d from peer added to side branch (parent %x, local head %x)", block.Header.Height, parentHash[:8], localHeadHash[:8])
-			c.checkReorg()
-			return nil
+		if existing.Hash() != block.Hash() && block.Header.ParentHash != c.blockAt(c.head).Hash() {
+			return c.sideOrOrphan(ctx, block)
 		}
 		return fmt.Errorf("block at height %d already exists", block.Header.Height)
 	}
 
-	// Check if parent exists anywhere in the chain
-	var parent *Block
-	parentFound := false
-	for _, b := range c.blocks {
-		if b.Hash() == block.Header.ParentHash {
-			parent = b
-			parentFound = true
-			break
-		}
-	}
-
-	if !parentFound {
-		// Add to orphan pool instead of returning error
-		c.addToOrphanPool(block)
-		log.Printf("🧩 Block #%d added to orphan pool (parent %x not found in chain)", block.Header.Height, block.Header.ParentHash[:8])
-		return fmt.Errorf("parent block with hash %x not found, queued in orphan pool", block.Header.ParentHash)
+	parent := c.getBlockByHash(block.Header.ParentHash)
+	if parent == nil {
+		// A side branch tip or an orphan
+		return c.sideOrOrphan(ctx, block)
 	}
 
-	// If parent is not at height-1, treat as side branch
+	// A parent that is not at height-1 cannot be linked to
 	if parent.Header.Height != block.Header.Height-1 {
-		c.addToSideBranch(block)
-		log.Printf("🌿 Block #%d added to side branch (parent at height %d, block height %d)", block.Header.Height, parent.Header.Height, block.Header.Height)
-		return fmt.Errorf("parent at height %d, block at %d: side branch", parent.Header.Height, block.Header.Height)
+		return fmt.Errorf("%w: parent at height %d, block at %d", ErrInvalidBlock, parent.Header.Height, block.Header.Height)
 	}
 
-	// Validate parent hash (should always match here)
-	if block.Header.ParentHash != parent.Hash() {
-		c.addToSideBranch(block)
-		log.Printf("🌿 Block #%d added to side branch (parent hash mismatch)", block.Header.Height)
-		return fmt.Errorf("parent hash mismatch: expected %x, got %x (side branch)", parent.Hash(), block.Header.ParentHash)
+	if err := checkTimestamp(lockedReader{c}, &block.Header); err != nil {
+		log.Printf("⏰ Rejected block #%d: %v", block.Header.Height, err)
+		return err
 	}
 
 	// Validate block hash
@@ -189,59 +330,71 @@ func (c *Chain) importBlockInternal(block *Block, scanOrphans bool) error {
 		return fmt.Errorf("block hash mismatch")
 	}
 
-	// Check if this is a retarget block and adjust difficulty
-	if block.Header.Height%uint64(config.RetargetInterval) == 0 && block.Header.Height > 0 {
-		log.Printf("🔧 Attempting difficulty retarget at height %d", block.Header.Height)
-
-		// Temporarily release the lock to avoid deadlock during difficulty adjustment
-		c.mu.Unlock()
-		newTarget, err := Adjust(c, &block.Header)
-		c.mu.Lock() // Re-acquire the lock
-
-		if err != nil {
-			log.Printf("❌ Difficulty adjustment failed: %v", err)
-			return fmt.Errorf("difficulty adjustment failed: %w", err)
-		}
-		block.Header.Bits = newTarget
-		log.Printf("🎯 Difficulty retarget at height %d: new target = %d", block.Header.Height, newTarget)
-	} else {
-		// Use parent's target for non-retarget blocks
-		block.Header.Bits = parent.Header.Bits
+	// The block must carry the target the chain prescribes; it is never
+	// overwritten, since the block hash and the miner's quiz tier commit to it
+	want, err := NextTarget(lockedReader{c}, &parent.Header)
+	if err != nil {
+		log.Printf("❌ Difficulty adjustment failed: %v", err)
+		return fmt.Errorf("difficulty adjustment failed: %w", err)
+	}
+	if block.Header.Bits != header.BigToCompact(want) {
+		log.Printf("❌ Block #%d carries target %v, expected %v", block.Header.Height, block.Header.Target(), want)
+		return fmt.Errorf("%w: target %v, expected %v", ErrInvalidBlock, block.Header.Target(), want)
+	}
+	if config.DifficultyAlgorithm == config.DifficultyBitcoin && block.Header.Height%config.RetargetInterval == 0 {
+		log.Printf("🎯 Difficulty retarget at height %d: new target = %d", block.Header.Height, want)
 	}
 
 	// Execute transactions in the block
 	if len(block.Transactions) > 0 {
 		log.Printf("💰 Executing %d transactions in block #%d", len(block.Transactions), block.Header.Height)
-		for i, tx := range block.Transactions {
-			if err := c.state.ExecuteTransaction(tx); err != nil {
-				log.Printf("❌ Transaction %d execution failed: %v", i, err)
-				return fmt.Errorf("transaction execution failed: %w", err)
-			}
-		}
-		// Remove executed transactions from mempool
-		c.Mempool.RemoveTransactions(block.Transactions)
 	}
+	// The block, its state changes, undo record and indexes and the new tip
+	// are written in one transaction, so a crash cannot leave the tip on a
+	// block whose state was never applied. The head and the cache move only
+	// once it is written; if it cannot be, its writes are dropped and the
+	// chain stays where it was
+	c.store.BeginBatch()
+	if err := c.applyBlockState(block); err != nil {
+		log.Printf("❌ Block #%d execution failed: %v", block.Header.Height, err)
+		c.store.DiscardBatch()
+		return err
+	}
+	if err := c.store.PutBlock(block.Header.Height, block); err != nil {
+		c.store.DiscardBatch()
+		c.reinjectTransactions([]*Block{block}, nil)
+		log.Printf("❌ Failed to persist block #%d: %v", block.Header.Height, err)
+		return fmt.Errorf("persist block #%d: %w", block.Header.Height, err)
+	}
+	if err := c.store.CommitBatch(); err != nil {
+		c.reinjectTransactions([]*Block{block}, nil)
+		log.Printf("❌ Failed to persist block #%d: %v", block.Header.Height, err)
+		return fmt.Errorf("persist block #%d: %w", block.Header.Height, err)
+	}
+	log.Printf("🗄️  Block #%d persisted to the database", block.Header.Height)
 
 	// Import the block
-	c.blocks[block.Header.Height] = block
-	c.blockHashIndex[block.Hash()] = block // NEW
+	c.blocks.add(block)
 	c.head = block.Header.Height
-	if err := c.store.PutBlock(block.Header.Height, block); err != nil {
-		log.Printf("Failed to persist block %d: %v", block.Header.Height, err)
-	} else {
-		log.Printf("🗄️  Block #%d persisted to BadgerDB", block.Header.Height)
-	}
 
-	// Prune old blocks (if enabled)
+	// Prune or freeze old blocks and prune state history (if enabled)
 	if config.PruneDepth > 0 {
 		if err := c.store.PruneBlocks(config.PruneDepth, c.head); err == nil {
 			log.Printf("🧹 Pruned blocks below height %d", int64(c.head)-int64(config.PruneDepth)+1)
 		}
 	}
+	c.pruneState()
+	c.freezeBlocks()
 
-	log.Printf("📗 Accepted block #%d loss=%d target=%d", block.Header.Height, block.Header.Lhat, block.Header.Bits)
+	log.Printf("📗 Accepted block #%d loss=%d target=%d", block.Header.Height, block.Header.Lhat, block.Header.Target())
+
+	if config.CheckpointInterval > 0 && block.Header.Height%config.CheckpointInterval == 0 {
+		c.captureSnapshot(block.Header.Height)
+	}
+	c.updateFinality()
 
 	// Notify subscribers of head change
+	c.publishEvents(ChainEvent{Type: EventBlockAdded, Block: block})
 	c.notifyHeadChange()
 
 	var importOrphansFor *[32]byte
@@ -251,7 +404,7 @@ func (c *Chain) importBlockInternal(block *Block, scanOrphans bool) error {
 	*importOrphansFor = block.Hash()
 
 	// After importing, check if any side branch is now longer than main chain
-	c.checkReorg()
+	c.checkReorg(ctx)
 
 	// Remove scanOrphanPool call to avoid repeated full orphan pool scans
 	// if scanOrphans {
@@ -285,10 +438,8 @@ func (c *Chain) tryImportOrphans(parentHash [32]byte) {
 	var toSideBranch []*Block
 
 	c.OrphanMu.Lock()
-	orphans, exists := c.OrphanPool[parentHash]
-	if exists {
-		delete(c.OrphanPool, parentHash)
-	}
+	orphans := c.takeOrphansLocked(parentHash)
+	exists := len(orphans) > 0
 	c.OrphanMu.Unlock()
 
 	if exists {
@@ -308,72 +459,70 @@ func (c *Chain) tryImportOrphans(parentHash [32]byte) {
 
 	// Import orphans and promote to side branch OUTSIDE the lock
 	for _, orphan := range toImport {
-		if err := c.ImportBlock(orphan); err != nil {
+		if err := c.ImportTrustedBlock(orphan); err != nil {
 			log.Printf("Failed to import orphan block #%d: %v", orphan.Header.Height, err)
 		} else {
 			log.Printf("✅ Orphan block #%d imported by tryImportOrphans", orphan.Header.Height)
 		}
 	}
-	for _, orphan := range toSideBranch {
-		c.addToSideBranch(orphan)
+	if len(toSideBranch) > 0 {
+		c.mu.Lock()
+		for _, orphan := range toSideBranch {
+			c.addToSideBranch(orphan)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// sideOrOrphan keeps a block that does not extend the head on a side
+// branch and checks for a reorg, or pools it as an orphan if its parent is
+// unknown. Caller holds c.mu.
+func (c *Chain) sideOrOrphan(ctx context.Context, block *Block) error {
+	err := c.addToSideBranch(block)
+	if errors.Is(err, errSideParentUnknown) {
+		c.addToOrphanPool(block, peerFrom(ctx))
+		log.Printf("🧩 Block #%d added to orphan pool (parent %x not found in chain)", block.Header.Height, block.Header.ParentHash[:8])
+		return fmt.Errorf("parent block with hash %x not found, queued in orphan pool", block.Header.ParentHash)
+	}
+	if err != nil {
+		return err
 	}
+	c.checkReorg(ctx)
+	return nil
 }
 
-// addToOrphanPool adds a block to the orphan pool when its parent is missing
-func (c *Chain) addToOrphanPool(block *Block) {
-	log.Printf("[WATCHDOG] addToOrphanPool: about to lock OrphanMu (goroutine)")
+// addToOrphanPool adds a block from peer to the orphan pool when its
+// parent is missing
+func (c *Chain) addToOrphanPool(block *Block, peer string) {
 	c.OrphanMu.Lock()
-	log.Printf("[WATCHDOG] addToOrphanPool: OrphanMu locked (goroutine)")
-	watchdogDone := make(chan struct{})
-	go func() {
-		select {
-		case <-watchdogDone:
-			return
-		case <-time.After(5 * time.Second):
-			buf := make([]byte, 1<<16)
-			runtime.Stack(buf, true)
-			log.Printf("[WATCHDOG][WARN] addToOrphanPool: OrphanMu held >5s!\n%s", buf)
-		}
-	}()
-	defer func() {
-		close(watchdogDone)
-		log.Printf("[WATCHDOG] addToOrphanPool: unlocking OrphanMu (goroutine)")
+	if !c.addOrphanLocked(block, peer, time.Now()) {
 		c.OrphanMu.Unlock()
-		log.Printf("[WATCHDOG] addToOrphanPool: OrphanMu unlocked (goroutine)")
-	}()
-
-	log.Printf("[DEBUG] addToOrphanPool: about to add to OrphanPool")
-	// Append to the slice for this parentHash
-	c.OrphanPool[block.Header.ParentHash] = append(c.OrphanPool[block.Header.ParentHash], block)
-	log.Printf("📦 Added block #%d to orphan pool (parent: %x)", block.Header.Height, block.Header.ParentHash[:8])
-	log.Printf("[DEBUG] Orphan pool length after add: %d", len(c.OrphanPool))
-	for k := range c.OrphanPool {
-		log.Printf("[DEBUG] Orphan pool key after add: %x", k[:8])
+		return
 	}
+	log.Printf("📦 Added block #%d to orphan pool (parent: %x, %d orphans)", block.Header.Height, block.Header.ParentHash[:8], len(c.orphans))
+	c.OrphanMu.Unlock()
 
-	// Save parent hash for callback
-	var parentHash [32]byte
-	callCallback := false
+	// Ask for the parent outside the lock
 	if c.RequestBlockByHash != nil {
-		parentHash = block.Header.ParentHash
-		callCallback = true
-	}
-
-	log.Printf("[DEBUG] addToOrphanPool: function end reached")
-
-	// Call the callback OUTSIDE the lock
-	if callCallback {
-		log.Printf("[DEBUG] addToOrphanPool: calling RequestBlockByHash OUTSIDE lock")
-		go c.RequestBlockByHash(parentHash)
+		go c.RequestBlockByHash(block.Header.ParentHash)
 	}
 }
 
-// addToSideBranch stores a block in the sideBranches map.
-func (c *Chain) addToSideBranch(block *Block) {
-	branch := c.sideBranches[block.Header.ParentHash]
-	c.sideBranches[block.Header.ParentHash] = append(branch, block)
-	log.Printf("🌿 Added block #%d to side branch (parent: %x, branch len: %d)", block.Header.Height, block.Header.ParentHash[:8], len(c.sideBranches[block.Header.ParentHash]))
+// addToSideBranch keeps block on a side branch if attachSideBlock accepts
+// it, and stores it so the branch survives a restart. Caller holds c.mu.
+func (c *Chain) addToSideBranch(block *Block) error {
+	if err := c.attachSideBlock(block); err != nil {
+		if !errors.Is(err, errSideParentUnknown) {
+			log.Printf("🌿 Block #%d not kept on a side branch: %v", block.Header.Height, err)
+		}
+		return err
+	}
+	if err := c.store.PutSideBlock(block); err != nil {
+		log.Printf("[WARN] Failed to persist side block #%d: %v", block.Header.Height, err)
+	}
+	log.Printf("🌿 Added block #%d to side branch (parent: %x)", block.Header.Height, block.Header.ParentHash[:8])
 	c.logSideBranches()
+	return nil
 }
 
 // logSideBranches prints the current state of all side branches.
@@ -386,20 +535,61 @@ func (c *Chain) logSideBranches() {
 	}
 }
 
-// checkReorg checks if any side branch is now longer than the main chain and performs a reorg if needed.
-func (c *Chain) checkReorg() {
+// checkReorg checks if any side branch is now longer than the main chain
+// and, once the branch passes validateBranch, reorgs to it. Invalid
+// branches are dropped. Caller holds c.mu, which is released while a
+// branch is verified.
+func (c *Chain) checkReorg(ctx context.Context) {
 	log.Printf("🔎 Checking for reorgs. Main head: %d", c.head)
-	for parentHash, branch := range c.sideBranches {
+	// c.sideBranches may change while c.mu is released
+	parents := make([][32]byte, 0, len(c.sideBranches))
+	for parentHash := range c.sideBranches {
+		parents = append(parents, parentHash)
+	}
+	for _, parentHash := range parents {
+		branch := append([]*Block(nil), c.sideBranches[parentHash]...)
 		if len(branch) == 0 {
 			continue
 		}
 		branchTip := branch[len(branch)-1]
 		log.Printf("🔎 Considering side branch (parent: %x) tipHeight=%d mainHead=%d", parentHash[:8], branchTip.Header.Height, c.head)
+		if forkHeight := branch[0].Header.Height - 1; forkHeight < c.finalized {
+			log.Printf("🔒 Dropping side branch forking at #%d, below finalized checkpoint #%d", forkHeight, c.finalized)
+			c.dropSideBranch(parentHash)
+			continue
+		} else if forkHeight+sideDepth() < c.head {
+			log.Printf("🌿 Dropping side branch forking at #%d, more than %d blocks below the head", forkHeight, sideDepth())
+			c.dropSideBranch(parentHash)
+			continue
+		}
 		if branchTip.Header.Height > c.head {
+			if !c.checkReorgDepth(branch[0].Header.Height-1, branchTip) {
+				c.dropSideBranch(parentHash)
+				continue
+			}
+			head := c.blockAt(c.head).Hash()
+			err := c.validateBranch(parentHash, branch)
+			if c.sideBranchMoved(parentHash, branch, head) {
+				// Whoever moved the head or the branch checks for a
+				// reorg again once done
+				log.Printf("⏳ Side branch (parent: %x) or head changed while it was verified", parentHash[:8])
+				if c.closed {
+					return
+				}
+				continue
+			}
+			if errors.Is(err, ErrProofUnavailable) {
+				log.Printf("⏳ Side branch (parent: %x) cannot be verified yet: %v", parentHash[:8], err)
+				continue
+			} else if err != nil {
+				log.Printf("❌ Dropping invalid side branch (parent: %x): %v", parentHash[:8], err)
+				c.dropSideBranch(parentHash)
+				continue
+			}
 			hash := branchTip.Hash()
 			log.Printf("🔀 Reorg: switching to side branch at height %d (tip %x)", branchTip.Header.Height, hash[0:8])
-			c.reorgToBranch(parentHash, branch)
-			delete(c.sideBranches, parentHash)
+			c.reorgToBranch(ctx, parentHash, branch)
+			c.dropSideBranch(parentHash)
 		} else {
 			log.Printf("❌ No reorg: side branch tipHeight=%d <= mainHead=%d", branchTip.Header.Height, c.head)
 		}
@@ -407,94 +597,159 @@ func (c *Chain) checkReorg() {
 }
 
 // reorgToBranch rolls back to the fork point and applies the new branch blocks.
-func (c *Chain) reorgToBranch(parentHash [32]byte, branch []*Block) {
-	// Roll back to fork point (parentHash)
+// State changes of the abandoned blocks are reverted via their undo records
+// and the branch blocks are executed, so state always matches the canonical
+// chain. If a branch block fails to execute, the old chain is restored.
+// Either outcome reaches the database atomically.
+func (c *Chain) reorgToBranch(ctx context.Context, parentHash [32]byte, branch []*Block) {
+	_, span := tracer.Start(ctx, "chain.Reorg", trace.WithAttributes(
+		attribute.Int64("reorg.fork_height", int64(branch[0].Header.Height-1)),
+		attribute.Int64("reorg.depth", int64(c.head-(branch[0].Header.Height-1))),
+		attribute.Int("reorg.branch_length", len(branch)),
+	))
+	defer span.End()
+
+	// The whole reorg is written in one transaction. If any step fails the
+	// batch is dropped, so the database never sees a half-done reorg, and
+	// the in-memory head and cache are put back to match
+	c.store.BeginBatch()
+
 	forkHeight := branch[0].Header.Height - 1
+	oldHead := c.head
+	var abandoned []*Block
+	for h := forkHeight + 1; h <= oldHead; h++ {
+		abandoned = append(abandoned, c.blockAt(h))
+	}
+	newHead := branch[len(branch)-1].Header.Height
+	abort := func(discard bool, applied []*Block) {
+		if discard {
+			c.store.DiscardBatch()
+		}
+		for h := forkHeight + 1; h <= max(oldHead, newHead); h++ {
+			c.blocks.remove(h)
+		}
+		for _, blk := range abandoned {
+			c.blocks.add(blk)
+		}
+		c.head = oldHead
+		c.reinjectTransactions(applied, nil)
+	}
+
+	// Roll back to fork point (parentHash), newest block first
+	for h := oldHead; h > forkHeight; h-- {
+		if err := c.revertBlockState(h); err != nil {
+			log.Printf("[REORG][ERROR] Failed to revert state of block #%d: %v; aborting reorg", h, err)
+			abort(true, nil)
+			return
+		}
+	}
 	c.head = forkHeight
 	log.Printf("↩️  Rolled back to fork height %d", forkHeight)
+
 	// Apply new branch blocks
-	for _, blk := range branch {
-		c.blocks[blk.Header.Height] = blk
-		c.head = blk.Header.Height
-		if err := c.store.PutBlock(blk.Header.Height, blk); err != nil {
-			log.Printf("Failed to persist block %d during reorg: %v", blk.Header.Height, err)
+	for i, blk := range branch {
+		err := c.applyBlockState(blk)
+		if err == nil {
+			err = c.store.PutBlock(blk.Header.Height, blk)
 		}
+		if err != nil {
+			log.Printf("[REORG][ERROR] Branch block #%d failed to execute: %v; restoring old chain", blk.Header.Height, err)
+			abort(true, branch[:i])
+			return
+		}
+		c.blocks.add(blk)
+		c.head = blk.Header.Height
 		log.Printf("🔗 Reorg applied block #%d", blk.Header.Height)
 	}
+	if err := c.store.CommitBatch(); err != nil {
+		log.Printf("[REORG][ERROR] Failed to persist reorg: %v; restoring old chain", err)
+		abort(false, branch)
+		return
+	}
 	log.Printf("✅ Reorg complete. New head: %d", c.head)
+	reorgsTotal.Inc()
+	c.reinjectTransactions(abandoned, branch)
+	c.updateFinality()
+	c.publishEvents(reorgEvents(forkHeight, abandoned, branch)...)
+	c.notifyHeadChange()
 }
 
-// ScanOrphanPool scans all orphans and tries to import or promote them if their parent is now present.
+// reinjectTransactions returns the transactions of blocks dropped by a
+// reorg to the mempool, unless the new branch includes them. Those the new
+// state makes invalid (spent nonce, balance gone) are rejected as usual.
+func (c *Chain) reinjectTransactions(abandoned, branch []*Block) {
+	included := make(map[string]bool)
+	for _, blk := range branch {
+		for _, tx := range blk.Transactions {
+			included[string(tx.CalculateHash())] = true
+		}
+	}
+	var readded, dropped int
+	for _, blk := range abandoned {
+		for _, tx := range blk.Transactions {
+			if tx.IsCoinbase() || included[string(tx.CalculateHash())] {
+				continue
+			}
+			if err := c.Mempool.AddTransaction(tx); err != nil {
+				dropped++
+				continue
+			}
+			readded++
+		}
+	}
+	if readded+dropped > 0 {
+		log.Printf("♻️  Returned %d transactions of abandoned blocks to the mempool (%d no longer valid)", readded, dropped)
+	}
+}
+
+// ScanOrphanPool drops expired orphans and imports or promotes to a side
+// branch those whose parent is now present.
 func (c *Chain) ScanOrphanPool() {
-	log.Printf("[WATCHDOG] scanOrphanPool: about to lock OrphanMu (goroutine)")
 	c.OrphanMu.Lock()
-	log.Printf("[WATCHDOG] scanOrphanPool: OrphanMu locked (goroutine)")
-	watchdogDone := make(chan struct{})
-	go func() {
-		select {
-		case <-watchdogDone:
-			return
-		case <-time.After(5 * time.Second):
-			buf := make([]byte, 1<<16)
-			runtime.Stack(buf, true)
-			log.Printf("[WATCHDOG][WARN] scanOrphanPool: OrphanMu held >5s!\n%s", buf)
-		}
-	}()
-	defer func() {
-		close(watchdogDone)
-		log.Printf("[WATCHDOG] scanOrphanPool: unlocking OrphanMu (goroutine)")
-		c.OrphanMu.Unlock()
-		log.Printf("[WATCHDOG] scanOrphanPool: OrphanMu unlocked (goroutine)")
-	}()
+	defer c.OrphanMu.Unlock()
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[ERROR] Panic in scanOrphanPool: %v", r)
 			debug.PrintStack()
 		}
 	}()
-	log.Printf("[DEBUG] scanOrphanPool called")
-	log.Printf("[DEBUG] scanOrphanPool: attempting to acquire lock")
 	if len(c.OrphanPool) == 0 {
-		log.Printf("[DEBUG] Orphan pool empty, returning early")
-		log.Printf("[DEBUG] scanOrphanPool: lock released, returning")
 		return
 	}
-	log.Printf("[DEBUG] Orphan pool keys at scan:")
-	for k := range c.OrphanPool {
-		log.Printf("[DEBUG] Orphan pool key: %x", k[:8])
-	}
-	log.Printf("🔍 Scanning orphan pool (%d orphans)", len(c.OrphanPool))
-	// Copy orphans to process after releasing lock
-	orphansToProcess := make(map[[32]byte][]*Block)
-	for parentHash, orphans := range c.OrphanPool {
-		orphansCopy := make([]*Block, len(orphans))
-		copy(orphansCopy, orphans)
-		orphansToProcess[parentHash] = orphansCopy
+	c.expireOrphansLocked(time.Now())
+	log.Printf("🔍 Scanning orphan pool (%d orphans)", len(c.orphans))
+	// Take out the orphans whose parent has arrived; the others wait for
+	// it until they expire
+	var ready []*Block
+	for parentHash := range c.OrphanPool {
+		if c.getBlockByHash(parentHash) != nil {
+			ready = append(ready, c.takeOrphansLocked(parentHash)...)
+		}
 	}
-	// Remove all orphans from the pool
-	c.OrphanPool = make(map[[32]byte][]*Block)
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Header.Height < ready[j].Header.Height })
 	go func() {
 		// Wait for the lock to be released
 		time.Sleep(10 * time.Millisecond)
-		for _, orphans := range orphansToProcess {
-			c.mu.RLock()
-			for _, orphan := range orphans {
-				parent := c.getBlockByHash(orphan.Header.ParentHash)
-				parentFound := parent != nil
-				if parentFound && parent.Header.Height == orphan.Header.Height-1 {
-					if err := c.ImportBlock(orphan); err != nil {
-						log.Printf("Failed to import orphan block #%d during scan: %v", orphan.Header.Height, err)
-					} else {
-						log.Printf("✅ Orphan block #%d imported during scan", orphan.Header.Height)
-					}
-				} else if parentFound {
-					c.addToSideBranch(orphan)
+		for _, orphan := range ready {
+			parent := c.getBlockByHash(orphan.Header.ParentHash)
+			if parent == nil {
+				continue // reorged away meanwhile
+			}
+			if parent.Header.Height == orphan.Header.Height-1 {
+				if err := c.ImportTrustedBlock(orphan); err != nil {
+					log.Printf("Failed to import orphan block #%d during scan: %v", orphan.Header.Height, err)
+				} else {
+					log.Printf("✅ Orphan block #%d imported during scan", orphan.Header.Height)
+				}
+			} else {
+				c.mu.Lock()
+				err := c.addToSideBranch(orphan)
+				c.mu.Unlock()
+				if err == nil {
 					log.Printf("🌿 Orphan block #%d promoted to side branch (parent at height %d, block height %d)", orphan.Header.Height, parent.Header.Height, orphan.Header.Height)
 				}
 			}
-			c.mu.RUnlock()
 		}
-		log.Printf("[DEBUG] scanOrphanPool completed")
 	}()
 }
 
@@ -514,6 +769,28 @@ func (c *Chain) StartOrphanPoolScanner(interval time.Duration, stopCh <-chan str
 	}()
 }
 
+// StartGarbageCollector reclaims stale database space every interval (see
+// Store.CollectGarbage) until stopCh is closed.
+func (c *Chain) StartGarbageCollector(interval time.Duration, discardRatio float64, stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := c.store.CollectGarbage(discardRatio)
+				if err != nil {
+					log.Printf("[DB] Garbage collection failed: %v", err)
+				} else if n > 0 {
+					log.Printf("🧹 Garbage collection rewrote %d value-log files", n)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
 // CurrentHeight returns the current chain height.
 func (c *Chain) CurrentHeight() uint64 {
 	c.mu.RLock()
@@ -531,40 +808,57 @@ func (c *Chain) HeaderByHeight(height uint64) *header.Header {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if blk, ok := c.blocks[height]; ok {
-		if blk.Header.Bits == nil || blk.Header.Bits.Sign() == 0 {
-			blk.Header.Bits = big.NewInt(-1000000000000000000) // Use a reasonable negative target
-		}
+	if blk := c.blockAt(height); blk != nil {
 		return &blk.Header
 	}
-	// Try to load from BadgerDB if not in memory
-	blk, err := c.store.GetBlock(height)
-	if err == nil && blk != nil {
-		if blk.Header.Bits == nil || blk.Header.Bits.Sign() == 0 {
-			blk.Header.Bits = big.NewInt(-1000000000000000000) // Use a reasonable negative target
-		}
-		c.blocks[height] = blk
+	return nil
+}
+
+// lockedReader is a ChainReader for callers that already hold c.mu.
+type lockedReader struct{ c *Chain }
+
+func (r lockedReader) HeaderByHeight(height uint64) *header.Header {
+	if blk := r.c.blockAt(height); blk != nil {
 		return &blk.Header
 	}
 	return nil
 }
 
+func (r lockedReader) Height() uint64 { return r.c.head }
+
 // BlockByHeight returns the block at the given height, or nil if not found.
 func (c *Chain) BlockByHeight(height uint64) *Block {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.blocks[height]
+	return c.blockAt(height)
+}
+
+// blockAt returns the canonical block at height from the block cache, or
+// the database for blocks not held in memory, or nil if there is none.
+// The caller holds c.mu.
+func (c *Chain) blockAt(height uint64) *Block {
+	if blk := c.blocks.get(height); blk != nil {
+		return blk
+	}
+	blk, err := c.store.GetBlock(height)
+	if err != nil || blk == nil {
+		return nil
+	}
+	c.blocks.add(blk)
+	return blk
 }
 
-// PreseedHeaders pre-populates the chain with dummy headers up to the given height (inclusive).
+// PreseedHeaders pre-populates the chain with dummy headers up to the given
+// height (inclusive). They are not persisted, so they only last while they
+// stay in the block cache.
 func (c *Chain) PreseedHeaders(upTo uint64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for h := uint64(1); h <= upTo; h++ {
-		if _, exists := c.blocks[h]; exists {
+		if c.blockAt(h) != nil {
 			continue // Don't overwrite real blocks
 		}
-		parent := c.blocks[h-1]
+		parent := c.blockAt(h - 1)
 		b := &Block{
 			Header: header.Header{
 				Height:     h,
@@ -575,7 +869,7 @@ func (c *Chain) PreseedHeaders(upTo uint64) {
 			},
 			Time: time.Now(),
 		}
-		c.blocks[h] = b
+		c.blocks.add(b)
 		if h > c.head {
 			c.head = h
 		}
@@ -584,87 +878,59 @@ func (c *Chain) PreseedHeaders(upTo uint64) {
 	log.Printf("📗 Pre-seeded headers up to height %d", upTo)
 }
 
-// SubscribeToHeadChanges returns a channel that receives notifications when the chain head changes.
-func (c *Chain) SubscribeToHeadChanges() chan struct{} {
-	c.subMu.Lock()
-	defer c.subMu.Unlock()
-
-	ch := make(chan struct{}, 1)
-	c.subscribers = append(c.subscribers, ch)
-	return ch
-}
-
-// notifyHeadChange notifies all subscribers that the head has changed.
-func (c *Chain) notifyHeadChange() {
-	c.subMu.RLock()
-	defer c.subMu.RUnlock()
-
-	for _, ch := range c.subscribers {
-		select {
-		case ch <- struct{}{}:
-		default:
-			// Channel is full, skip this notification
-		}
-	}
-}
-
-// Diagnostic: Log chain state (head, blocks, orphans, side branches)
+// Diagnostic: Log chain state (head, blocks, orphans, side branches). Each
+// orphan and side branch is logged at debug level only.
 func (c *Chain) LogDiagnostics() {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	log.Printf("[DIAG] Chain head: %d", c.head)
-	var heights []uint64
-	for h := range c.blocks {
-		heights = append(heights, h)
-	}
+	log.Printf("[DIAG] Chain head: %d, blocks cached: %d", c.head, c.blocks.len())
 	log.Printf("[DIAG] Orphan pool size: %d", len(c.OrphanPool))
 	for k, orphan := range c.OrphanPool {
-		log.Printf("[DIAG] Orphan: parentHash=%x height=%d", k[:8], orphan[0].Header.Height) // Assuming all orphans for a parent have the same height
+		log.Printf("[DEBUG] Orphan: parentHash=%x height=%d", k[:8], orphan[0].Header.Height) // Assuming all orphans for a parent have the same height
 	}
 	for parentHash, branch := range c.sideBranches {
 		if len(branch) == 0 {
 			continue
 		}
 		tip := branch[len(branch)-1]
-		log.Printf("[DIAG] Side branch: parent=%x tipHeight=%d len=%d", parentHash[:8], tip.Header.Height, len(branch))
+		log.Printf("[DEBUG] Side branch: parent=%x tipHeight=%d len=%d", parentHash[:8], tip.Header.Height, len(branch))
 	}
 }
 
-// Reindex: Rebuild in-memory block index from BadgerDB
-func (c *Chain) ReindexFromDB() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	log.Printf("[REINDEX] Rebuilding in-memory block index from BadgerDB...")
-	c.blocks = make(map[uint64]*Block)
-	c.blockHashIndex = make(map[[32]byte]*Block)
-	tip, err := c.store.GetTipHeight()
+// getBlockByHash returns the canonical block with hash h from the block
+// cache, or the database for blocks not held in memory. It does not take
+// c.mu, so callers may hold it.
+func (c *Chain) getBlockByHash(h [32]byte) *Block {
+	if b := c.blocks.getByHash(h); b != nil {
+		return b
+	}
+	b, err := c.store.GetBlockByHash(h)
 	if err != nil {
-		if err.Error() == "Key not found" {
-			log.Printf("[REINDEX][WARN] No blocks found in DB (empty chain). Will start fresh.")
-			return nil
-		}
-		return err
+		return nil
 	}
-	for h := uint64(0); h <= tip; h++ {
-		blk, err := c.store.GetBlock(h)
-		if err == nil && blk != nil {
-			c.blocks[h] = blk
-			c.blockHashIndex[blk.Hash()] = blk
-			if h > c.head {
-				c.head = h
-			}
-		}
+	// Side-branch blocks are stored by hash as well
+	if canon, err := c.store.GetCanonicalHash(b.Header.Height); err != nil || canon != h {
+		return nil
 	}
-	log.Printf("[REINDEX] Done. Head: %d, blocks loaded: %d", c.head, len(c.blocks))
-	return nil
+	return b
 }
 
-// getBlockByHash safely reads blockHashIndex with lock
-func (c *Chain) getBlockByHash(h [32]byte) *Block {
+// BlockByHash returns the block with hash h, canonical, stored or on a
+// side branch, or nil if the chain does not have it.
+func (c *Chain) BlockByHash(h [32]byte) *Block {
+	if b := c.getBlockByHash(h); b != nil {
+		return b
+	}
 	c.mu.RLock()
-	b := c.blockHashIndex[h]
-	c.mu.RUnlock()
-	return b
+	defer c.mu.RUnlock()
+	for _, branch := range c.sideBranches {
+		for _, b := range branch {
+			if b.Hash() == h {
+				return b
+			}
+		}
+	}
+	return nil
 }
 
 // GetBalance returns the balance for an address without opening a separate database connection.
@@ -674,3 +940,18 @@ func (c *Chain) GetBalance(addr []byte) *big.Int {
 	defer c.mu.RUnlock()
 	return c.state.GetBalance(addr)
 }
+
+// GetNonce returns the next expected nonce for an address.
+func (c *Chain) GetNonce(addr []byte) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state.GetNonce(addr)
+}
+
+// GetPendingNonce returns the next nonce for an address after its
+// transactions in the mempool.
+func (c *Chain) GetPendingNonce(addr []byte) uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Mempool.PendingNonce(addr)
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// CheckpointSigners are the addresses whose checkpoints are trusted for fast
+// bootstrap. Injected at startup from --checkpoint-signers.
+var CheckpointSigners [][]byte
+
+// Checkpoint attests that BlockHash at Height has state StateRoot.
+type Checkpoint struct {
+	Height    uint64   `json:"height"`
+	BlockHash [32]byte `json:"blockHash"`
+	StateRoot [32]byte `json:"stateRoot"`
+	Signature []byte   `json:"signature"`
+}
+
+// SigningHash is the digest covered by the signature.
+func (cp *Checkpoint) SigningHash() []byte {
+	var buf [8 + 32 + 32]byte
+	binary.BigEndian.PutUint64(buf[:8], cp.Height)
+	copy(buf[8:40], cp.BlockHash[:])
+	copy(buf[40:], cp.StateRoot[:])
+	return crypto.Keccak256([]byte("poai-checkpoint"), buf[:])
+}
+
+// Sign signs the checkpoint with a signer key.
+func (cp *Checkpoint) Sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(cp.SigningHash(), key)
+	if err != nil {
+		return err
+	}
+	cp.Signature = sig
+	return nil
+}
+
+// Signer recovers the address that signed the checkpoint.
+func (cp *Checkpoint) Signer() ([]byte, error) {
+	pub, err := crypto.SigToPub(cp.SigningHash(), cp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint signature: %v", err)
+	}
+	return crypto.PubkeyToAddress(*pub).Bytes(), nil
+}
+
+// VerifyCheckpoint checks that the checkpoint is signed by a trusted signer.
+func VerifyCheckpoint(cp *Checkpoint) error {
+	signer, err := cp.Signer()
+	if err != nil {
+		return err
+	}
+	for _, trusted := range CheckpointSigners {
+		if bytes.Equal(signer, trusted) {
+			return nil
+		}
+	}
+	return fmt.Errorf("checkpoint signed by untrusted key %x", signer)
+}
+
+// NewCheckpoint builds an unsigned checkpoint for the stored snapshot at height.
+func (c *Chain) NewCheckpoint(height uint64) (*Checkpoint, error) {
+	blk := c.BlockByHeight(height)
+	if blk == nil {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	snap, err := c.Snapshot(height)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{Height: height, BlockHash: blk.Hash(), StateRoot: snap.Root()}, nil
+}
+
+// BootstrapFromCheckpoint seeds an empty chain with a checkpointed block and
+// its state snapshot, so sync can continue from there instead of genesis.
+func (c *Chain) BootstrapFromCheckpoint(cp *Checkpoint, blk *Block, snap *StateSnapshot) error {
+	if err := VerifyCheckpoint(cp); err != nil {
+		return err
+	}
+	if blk.Header.Height != cp.Height || blk.Hash() != cp.BlockHash {
+		return fmt.Errorf("block does not match checkpoint")
+	}
+	if snap.Height != cp.Height || snap.Root() != cp.StateRoot {
+		return fmt.Errorf("snapshot does not match checkpoint state root")
+	}
+
+	return c.bootstrapFromSnapshot(blk, snap)
+}
+
+// BootstrapFromSnapshot seeds an empty chain with a block and a state
+// snapshot whose root matches that block's StateRoot. The block must come
+// from a header chain the caller has already validated; its PoAI work is
+// replayed here if a ProofVerifier is configured.
+func (c *Chain) BootstrapFromSnapshot(blk *Block, snap *StateSnapshot) error {
+	if snap.Height != blk.Header.Height {
+		return fmt.Errorf("snapshot height %d does not match block %d", snap.Height, blk.Header.Height)
+	}
+	if blk.Header.StateRoot == ([32]byte{}) || snap.Root() != blk.Header.StateRoot {
+		return fmt.Errorf("snapshot does not match block state root")
+	}
+	if err := CheckBlockLimits(blk); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlock, err)
+	}
+	if c.VerifyProof != nil {
+		if err := c.VerifyProof(blk); err != nil {
+			return proofError(err)
+		}
+	}
+	return c.bootstrapFromSnapshot(blk, snap)
+}
+
+func (c *Chain) bootstrapFromSnapshot(blk *Block, snap *StateSnapshot) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	height := blk.Header.Height
+	if c.head >= height {
+		return fmt.Errorf("local head %d already at or past snapshot %d", c.head, height)
+	}
+	if err := c.state.RestoreSnapshot(snap); err != nil {
+		return fmt.Errorf("restore snapshot: %w", err)
+	}
+	if err := c.store.PutBlock(height, blk); err != nil {
+		return fmt.Errorf("persist snapshot block: %w", err)
+	}
+	if err := c.store.PutSnapshot(snap, snap.Height); err != nil {
+		log.Printf("[SNAPSHOT] Failed to keep bootstrap snapshot: %v", err)
+	}
+	c.blocks.add(blk)
+	c.head = height
+	h := blk.Hash()
+	log.Printf("🚀 Bootstrapped from state snapshot #%d (%x)", height, h[:8])
+	c.publishEvents(ChainEvent{Type: EventBlockAdded, Block: blk})
+	c.notifyHeadChange()
+	return nil
+}
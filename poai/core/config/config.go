@@ -1,11 +1,14 @@
 package config
 
 import (
+	"fmt"
+	"math"
 	"math/big"
+	"time"
 )
 
-// EpochBlocks is injected at program startup from TOML.
-// Default for unit tests = 20 (Testnet-0).
+// EpochBlocks is the number of blocks per epoch, set at startup from the
+// network preset or genesis.json. Default for unit tests = 20.
 var EpochBlocks uint64 = 20
 
 // CorpusSize is no longer used with procedural generation
@@ -16,15 +19,204 @@ var CorpusSize uint64 = 0
 // Default for unit tests = 2 (Testnet-0).
 var BatchSize int = 2
 
+// ChainID identifies the network, set at startup from the network preset
+// or genesis.json. 0 is the development chain.
+var ChainID uint64
+
+// RetargetInterval is the number of blocks between difficulty
+// adjustments, set at startup from the network preset or genesis.json.
+var RetargetInterval uint64 = 2016
+
+// Difficulty algorithms a genesis.json can select
+const (
+	DifficultyBitcoin = "bitcoin" // scale the target every RetargetInterval blocks
+	DifficultyLWMA    = "lwma"    // linearly weighted moving average, every block
+	DifficultyASERT   = "asert"   // exponential in the schedule deviation, every block
+)
+
+// DifficultyAlgorithm is the retarget rule, set at startup from the
+// network preset or genesis.json.
+var DifficultyAlgorithm = DifficultyBitcoin
+
+// DefaultBlockSpacingSec is the block spacing of networks whose genesis
+// does not set one (10 minutes).
+const DefaultBlockSpacingSec = 600
+
+// TargetBlockSpacingSec is the desired seconds per block, set at startup
+// from the network preset or genesis.json.
+var TargetBlockSpacingSec int64 = DefaultBlockSpacingSec
+
 // Difficulty retarget parameters
 const (
-	RetargetInterval      = 2016 // # of blocks between adjustments
-	TargetBlockSpacingSec = 600  // desired seconds per block (10 minutes)
-	MaxAdjustmentFactor   = 4    // clamp A / B to [1/4, 4×]
+	LWMAWindow          = 45   // blocks averaged by LWMA
+	ASERTHalfLifeSec    = 3600 // schedule deviation that doubles or halves the ASERT target
+	MaxAdjustmentFactor = 4    // clamp A / B to [1/4, 4×]
+)
+
+// Block limits, enforced in consensus
+const (
+	MaxBlockGas  = 10_000_000 // sum of the transactions' gas limits
+	MaxBlockSize = 256 * 1024 // RLP-encoded block, in bytes
+)
+
+// Block timestamp rules, enforced on import
+const (
+	MedianTimeBlocks      = 11  // a block must be later than the median of this many ancestors
+	MaxFutureBlockTimeSec = 300 // and at most this far ahead of the local clock
 )
 
 // MaximumTarget is the easiest possible target (highest value)
 var MaximumTarget = new(big.Int).Lsh(big.NewInt(1), 256).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
 
+// DBEngine is the storage engine of the chain database (badger, pebble or
+// leveldb), injected at startup from --db-engine. Empty keeps the engine
+// the data directory already uses, Badger for a new one.
+var DBEngine string
+
 // PruneDepth controls how many blocks to keep (0 = keep all, i.e., archival node)
 var PruneDepth uint64 = 100
+
+// DefaultBlockCacheSize covers a Bitcoin-rule retarget window, whose first
+// header every retarget reads.
+const DefaultBlockCacheSize = 2048
+
+// BlockCacheSize is how many recent blocks the chain keeps in memory,
+// injected at startup from --block-cache; older ones are read from the
+// database (0 = DefaultBlockCacheSize).
+var BlockCacheSize = DefaultBlockCacheSize
+
+// AncientDepth is how far below the head blocks move from the database to
+// the flat era files of the ancient store, injected at startup from
+// --ancient-depth (0 = never). Only blocks below the finalized checkpoint
+// move, and pruned nodes delete old blocks instead.
+var AncientDepth uint64 = 90000
+
+// Orphan pool limits, injected at startup from --max-orphans,
+// --max-orphan-mb and --orphan-expiry. A full pool evicts the oldest orphan
+// of the peer holding the most; orphans whose parent has not arrived
+// within OrphanExpiry are dropped.
+var (
+	MaxOrphans     = 512
+	MaxOrphanBytes = 64 << 20
+	OrphanExpiry   = 20 * time.Minute
+)
+
+// MaxReorgDepth is the most canonical blocks a reorg may replace, injected
+// at startup from --max-reorg-depth (0 = no limit). Deeper reorgs are
+// refused and reported as an alert for the operator.
+var MaxReorgDepth uint64 = 100
+
+// NodeRole selects how much history a node retains and serves to peers.
+type NodeRole string
+
+const (
+	RoleArchive NodeRole = "archive" // keep every block and all historical state, serve everything
+	RoleFull    NodeRole = "full"    // keep every block and the latest state
+	RolePruned  NodeRole = "pruned"  // keep only the last PruneDepth blocks
+	RoleLight   NodeRole = "light"   // no mining and no block serving
+)
+
+// Role is injected at program startup from the --role flag.
+var Role NodeRole = RoleFull
+
+// StateHistory is how many recent blocks keep their undo records, the state
+// history needed to revert them in a reorg (0 = keep all). Derived from the
+// role by ApplyRole.
+var StateHistory uint64
+
+// DefaultPrunedDepth is the number of blocks a pruned node keeps when
+// --prune-depth is not given explicitly, unless MinPruneDepth is more.
+const DefaultPrunedDepth = 1000
+
+// MinPruneDepth returns the fewest blocks a pruned node may keep: the
+// target of the next block is computed from headers that far back (a
+// Bitcoin-rule retarget window or the LWMA window). ASERT reads its anchor,
+// block 1, for ever, so no depth is enough and ok is false.
+func MinPruneDepth() (depth uint64, ok bool) {
+	switch DifficultyAlgorithm {
+	case DifficultyASERT:
+		return 0, false
+	case DifficultyLWMA:
+		depth = LWMAWindow + 1
+	default:
+		depth = RetargetInterval
+		if depth == math.MaxUint64 { // never retargets
+			depth = 0
+		}
+	}
+	return max(depth, MedianTimeBlocks), true
+}
+
+// ParseNodeRole converts a flag value into a NodeRole.
+func ParseNodeRole(s string) (NodeRole, error) {
+	switch r := NodeRole(s); r {
+	case RoleArchive, RoleFull, RolePruned, RoleLight:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown node role %q (want archive, full, pruned or light)", s)
+}
+
+// ApplyRole sets Role and derives PruneDepth and StateHistory from it.
+// pruneDepth is the value of --prune-depth; it is only honoured for pruned
+// nodes, which keep as much state history as blocks, and must be at least
+// MinPruneDepth. Archive nodes keep all of it and the others
+// DefaultPrunedDepth blocks' worth. Call it once the difficulty rule is set.
+func ApplyRole(role NodeRole, pruneDepth uint64) error {
+	switch role {
+	case RolePruned:
+		least, ok := MinPruneDepth()
+		if !ok {
+			return fmt.Errorf("the %s difficulty rule reads the whole chain; run a full node", DifficultyAlgorithm)
+		}
+		if pruneDepth == 0 {
+			pruneDepth = max(DefaultPrunedDepth, least)
+		} else if pruneDepth < least {
+			return fmt.Errorf("--prune-depth %d is below the %d blocks difficulty adjustment reads back", pruneDepth, least)
+		}
+	default:
+		if pruneDepth > 0 {
+			return fmt.Errorf("--prune-depth requires --role=pruned (got role %q)", role)
+		}
+	}
+	Role = role
+	PruneDepth = pruneDepth
+	switch role {
+	case RoleArchive:
+		StateHistory = 0
+	case RolePruned:
+		StateHistory = pruneDepth
+	default:
+		StateHistory = DefaultPrunedDepth
+	}
+	return nil
+}
+
+// ServesBlocks reports whether nodes in this role answer block requests.
+func (r NodeRole) ServesBlocks() bool {
+	return r != RoleLight
+}
+
+// KeepsFullHistory reports whether nodes in this role retain every block.
+func (r NodeRole) KeepsFullHistory() bool {
+	return r == RoleArchive || r == RoleFull
+}
+
+// CheckpointInterval is the number of blocks between state snapshots and
+// signed checkpoints.
+var CheckpointInterval uint64 = 1000
+
+// FinalityEpochs is the number of epochs between finalized checkpoints.
+// A checkpoint becomes final once another full interval is built on top of
+// it; no reorg may fork below it. 0 disables finality.
+var FinalityEpochs uint64 = 10
+
+// FinalityInterval returns the finalized checkpoint spacing in blocks.
+func FinalityInterval() uint64 {
+	return FinalityEpochs * EpochBlocks
+}
+
+// ModelSHA256 is the hex SHA-256 of the GGUF model consensus runs on,
+// injected at startup from --model-sha256. The chain records it at genesis
+// and nodes refuse to mine or verify with a different file. Empty means
+// no commitment (development chains).
+var ModelSHA256 string
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyRoleKeepsRetargetWindow(t *testing.T) {
+	defer func(algo string, interval uint64) {
+		DifficultyAlgorithm, RetargetInterval = algo, interval
+		ApplyRole(RoleFull, 0)
+	}(DifficultyAlgorithm, RetargetInterval)
+
+	DifficultyAlgorithm, RetargetInterval = DifficultyBitcoin, 2016
+	if err := ApplyRole(RolePruned, 0); err != nil || PruneDepth != 2016 {
+		t.Fatalf("default depth %d, err %v; want the 2016-block window", PruneDepth, err)
+	}
+	if err := ApplyRole(RolePruned, 2015); err == nil {
+		t.Fatal("depth below the retarget window accepted")
+	}
+
+	DifficultyAlgorithm = DifficultyLWMA
+	if err := ApplyRole(RolePruned, 0); err != nil || PruneDepth != DefaultPrunedDepth {
+		t.Fatalf("LWMA default depth %d, err %v", PruneDepth, err)
+	}
+	if err := ApplyRole(RolePruned, LWMAWindow); err == nil {
+		t.Fatal("depth below the LWMA window accepted")
+	}
+
+	DifficultyAlgorithm, RetargetInterval = DifficultyBitcoin, math.MaxUint64
+	if err := ApplyRole(RolePruned, 100); err != nil {
+		t.Fatalf("chain that never retargets: %v", err)
+	}
+
+	DifficultyAlgorithm = DifficultyASERT
+	if err := ApplyRole(RolePruned, 0); err == nil {
+		t.Fatal("pruned ASERT node accepted")
+	}
+}
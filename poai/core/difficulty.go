@@ -16,6 +16,112 @@ type ChainReader interface {
 	Height() uint64
 }
 
+// NextTarget returns the target a block extending parent must carry, as
+// set by config.DifficultyAlgorithm. With the Bitcoin rule it is the
+// parent's, except that the first block of each retarget interval gets
+// Adjust(chain, parent); LWMA and ASERT retarget every block.
+func NextTarget(chain ChainReader, parent *header.Header) (*big.Int, error) {
+	if parent == nil {
+		return nil, fmt.Errorf("NextTarget: missing parent target")
+	}
+	var target *big.Int
+	var err error
+	switch config.DifficultyAlgorithm {
+	case config.DifficultyLWMA:
+		target, err = lwmaTarget(chain, parent)
+	case config.DifficultyASERT:
+		target, err = asertTarget(chain, parent)
+	default:
+		if (parent.Height+1)%config.RetargetInterval != 0 || parent.Height == 0 {
+			return parent.Target(), nil
+		}
+		target, err = Adjust(chain, parent)
+	}
+	// Headers carry compact bits, so round the same way
+	return header.CompactToBig(header.BigToCompact(target)), err
+}
+
+// clampTarget bounds a retarget to [1, config.MaximumTarget].
+func clampTarget(t *big.Int) *big.Int {
+	if t.Sign() <= 0 {
+		return big.NewInt(1)
+	}
+	if t.Cmp(config.MaximumTarget) > 0 {
+		return new(big.Int).Set(config.MaximumTarget)
+	}
+	return t
+}
+
+// lwmaTarget is the LWMA-1 rule: the average target of the last
+// config.LWMAWindow blocks, scaled by their solve times weighted linearly
+// towards the most recent. Solve times are clamped to [1, 6×spacing] so a
+// single bad timestamp cannot swing it. The genesis timestamp is not
+// meaningful, so the window starts at block 1 and the chain keeps the
+// parent's target until block 2.
+func lwmaTarget(chain ChainReader, parent *header.Header) (*big.Int, error) {
+	if parent.Height < 2 {
+		return parent.Target(), nil
+	}
+	first := uint64(2)
+	if parent.Height >= config.LWMAWindow+1 {
+		first = parent.Height - config.LWMAWindow + 1
+	}
+	prev := chain.HeaderByHeight(first - 1)
+	if prev == nil {
+		return parent.Target(), fmt.Errorf("lwma: missing header at height %d", first-1)
+	}
+	sumTarget := new(big.Int)
+	var weighted, weight int64
+	for h := first; h <= parent.Height; h++ {
+		hdr := chain.HeaderByHeight(h)
+		if hdr == nil {
+			return parent.Target(), fmt.Errorf("lwma: missing header at height %d", h)
+		}
+		solve := hdr.Timestamp.Unix() - prev.Timestamp.Unix()
+		solve = max(1, min(solve, 6*config.TargetBlockSpacingSec))
+		weight++
+		weighted += weight * solve
+		sumTarget.Add(sumTarget, hdr.Target())
+		prev = hdr
+	}
+	// next = (sumTarget / n) × weighted / (n(n+1)/2 × spacing)
+	n := weight
+	next := new(big.Int).Mul(sumTarget, big.NewInt(2*weighted))
+	next.Quo(next, big.NewInt(n*n*(n+1)*config.TargetBlockSpacingSec))
+	return clampTarget(next), nil
+}
+
+// asertTarget is the aserti3-2d rule: block 1 is the anchor and every
+// later target is the anchor's times 2^(deviation / half-life), where the
+// deviation is how far the parent's timestamp is behind (positive) or
+// ahead of the schedule. The power of two is evaluated in 16.16 fixed
+// point with the cubic approximation of the reference implementation, so
+// every node computes the same bits.
+func asertTarget(chain ChainReader, parent *header.Header) (*big.Int, error) {
+	if parent.Height < 1 {
+		return parent.Target(), nil
+	}
+	anchor := chain.HeaderByHeight(1)
+	if anchor == nil {
+		return parent.Target(), fmt.Errorf("asert: missing anchor header at height 1")
+	}
+	timeDelta := parent.Timestamp.Unix() - anchor.Timestamp.Unix()
+	heightDelta := int64(parent.Height - anchor.Height)
+	exponent := (timeDelta - config.TargetBlockSpacingSec*heightDelta) * 65536 / config.ASERTHalfLifeSec
+	shifts := exponent >> 16
+	frac := uint64(uint16(exponent))
+	factor := 65536 + ((195766423245049*frac + 971821376*frac*frac + 5127*frac*frac*frac + 1<<47) >> 48)
+
+	next := new(big.Int).Mul(anchor.Target(), new(big.Int).SetUint64(factor))
+	shifts -= 16
+	if shifts < 0 {
+		next.Rsh(next, uint(-shifts))
+	} else {
+		next.Lsh(next, uint(min(shifts, 512)))
+	}
+	return clampTarget(next), nil
+}
+
 // Always use new(big.Int) or big.NewInt(0) for any *big.Int you intend to mutate.
 // Never declare var x *big.Int and then call x.Set(...), as this will panic.
 // Defensive: always return a non-nil *big.Int on error.
@@ -23,13 +129,10 @@ func Adjust(chain ChainReader, tip *header.Header) (*big.Int, error) {
 	if tip == nil {
 		return big.NewInt(1), fmt.Errorf("Adjust: nil header")
 	}
-	if tip.Bits == nil {
-		return big.NewInt(1), fmt.Errorf("Adjust: header Bits nil at height %d", tip.Height)
-	}
 	interval := uint64(config.RetargetInterval)
-	if tip.Height < interval {
+	if tip.Height+1 < interval {
 		// Not enough history yet; return genesis target unmodified.
-		return new(big.Int).Set(tip.Bits), nil
+		return tip.Target(), nil
 	}
 
 	// 1) Locate the first header in this window
@@ -37,12 +140,12 @@ func Adjust(chain ChainReader, tip *header.Header) (*big.Int, error) {
 	first := chain.HeaderByHeight(firstHeight)
 	if first == nil {
 		// If we can't find the required header, just return unchanged target
-		return new(big.Int).Set(tip.Bits), fmt.Errorf("Adjust: missing header at height %d", firstHeight)
+		return tip.Target(), fmt.Errorf("Adjust: missing header at height %d", firstHeight)
 	}
 
 	// 2) Compute actual timespan
 	actual := tip.Timestamp.Sub(first.Timestamp)
-	expected := time.Duration(interval) * time.Second * config.TargetBlockSpacingSec
+	expected := time.Duration(interval) * time.Duration(config.TargetBlockSpacingSec) * time.Second
 
 	// 3) Clamp actual to [expected/MaxFactor, expected×MaxFactor]
 	minSpan := expected / config.MaxAdjustmentFactor
@@ -55,7 +158,7 @@ func Adjust(chain ChainReader, tip *header.Header) (*big.Int, error) {
 
 	// 4) Scale the previous target
 	// newT = oldT × actual / expected
-	oldT := new(big.Int).Set(tip.Bits)
+	oldT := tip.Target()
 	expectedSeconds := int64(expected.Seconds())
 	if expectedSeconds == 0 {
 		// Avoid division by zero - use a minimum of 1 second
@@ -64,18 +167,7 @@ func Adjust(chain ChainReader, tip *header.Header) (*big.Int, error) {
 	newT := new(big.Int).Mul(oldT, big.NewInt(int64(actual.Seconds())))
 	newT = newT.Div(newT, big.NewInt(expectedSeconds))
 
-	// 5) Enforce some sanity bounds for negative targets
-	// For PoAI, we use negative targets where more negative = harder
-	// Set minimum target (easiest) to -1, maximum target (hardest) to -2^63
-	minTarget := big.NewInt(-1)
-	maxTarget := new(big.Int).Lsh(big.NewInt(1), 63).Neg(new(big.Int).Lsh(big.NewInt(1), 63)) // -2^63
-
-	if newT.Cmp(minTarget) > 0 {
-		newT.Set(minTarget) // Don't allow positive targets
-	}
-	if newT.Cmp(maxTarget) < 0 {
-		newT.Set(maxTarget) // Don't allow targets more negative than -2^63
-	}
-
-	return newT, nil
+	// 5) Keep the target within [1, config.MaximumTarget], as the per-block
+	// rules do
+	return clampTarget(newT), nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"poai/core/config"
 	"poai/core/header"
 )
 
@@ -37,7 +38,7 @@ func TestDifficultyAdjust(t *testing.T) {
 		blockTime := baseTime.Add(time.Duration(i) * 5 * time.Minute)
 		chain.headers[i] = &header.Header{
 			Height:    i,
-			Bits:      big.NewInt(1000), // Initial target
+			Bits:      header.BigToCompact(big.NewInt(1000)), // Initial target
 			Timestamp: blockTime,
 		}
 	}
@@ -71,7 +72,7 @@ func TestDifficultyAdjustClamping(t *testing.T) {
 		blockTime := baseTime.Add(time.Duration(i) * time.Second)
 		chain.headers[i] = &header.Header{
 			Height:    i,
-			Bits:      big.NewInt(1000),
+			Bits:      header.BigToCompact(big.NewInt(1000)),
 			Timestamp: blockTime,
 		}
 	}
@@ -103,7 +104,7 @@ func TestDifficultyAdjustInsufficientHistory(t *testing.T) {
 		blockTime := baseTime.Add(time.Duration(i) * 10 * time.Minute)
 		chain.headers[i] = &header.Header{
 			Height:    i,
-			Bits:      big.NewInt(1000),
+			Bits:      header.BigToCompact(big.NewInt(1000)),
 			Timestamp: blockTime,
 		}
 	}
@@ -121,3 +122,142 @@ func TestDifficultyAdjustInsufficientHistory(t *testing.T) {
 
 	t.Logf("Unchanged target: %d", newTarget)
 }
+
+func TestNextTargetRetargetsOnIntervalBoundary(t *testing.T) {
+	chain := &mockChain{headers: make(map[uint64]*header.Header), height: 4031}
+	baseTime := time.Now()
+	for i := uint64(0); i <= 4031; i++ {
+		chain.headers[i] = &header.Header{
+			Height:    i,
+			Bits:      header.BigToCompact(big.NewInt(1_000_000)),
+			Timestamp: baseTime.Add(time.Duration(i) * 20 * time.Minute),
+		}
+	}
+
+	// Off the boundary the parent's target carries over
+	got, err := NextTarget(chain, chain.headers[4030])
+	if err != nil || got.Cmp(big.NewInt(1_000_000)) != 0 {
+		t.Fatalf("NextTarget off boundary = %v, %v; want 1000000", got, err)
+	}
+	// The first block of every interval, the first one included, gets
+	// Adjust of its parent; blocks twice as slow as scheduled about double it
+	for _, parent := range []uint64{config.RetargetInterval - 1, 2*config.RetargetInterval - 1} {
+		want, _ := Adjust(chain, chain.headers[parent])
+		got, err = NextTarget(chain, chain.headers[parent])
+		if err != nil || got.Cmp(want) != 0 {
+			t.Fatalf("NextTarget after #%d = %v, %v; want %v", parent, got, err, want)
+		}
+		if want.Cmp(big.NewInt(1_990_000)) < 0 || want.Cmp(big.NewInt(2_000_000)) > 0 {
+			t.Fatalf("retarget after #%d = %v, want about 2000000", parent, want)
+		}
+	}
+}
+
+func TestAdjustKeepsTargetsPositive(t *testing.T) {
+	chain := &mockChain{headers: make(map[uint64]*header.Header), height: 4031}
+	baseTime := time.Now()
+	spacing := time.Duration(config.TargetBlockSpacingSec) * time.Second
+	for i := uint64(0); i <= 4031; i++ {
+		chain.headers[i] = &header.Header{
+			Height:    i,
+			Bits:      header.BigToCompact(big.NewInt(999999)),
+			Timestamp: baseTime.Add(time.Duration(i) * spacing),
+		}
+	}
+	// On schedule the default target survives a retarget and stays minable
+	got, err := NextTarget(chain, chain.headers[4031])
+	if err != nil || got.Sign() <= 0 || !MeetsTarget(0, got) {
+		t.Fatalf("on-schedule retarget = %v, %v", got, err)
+	}
+
+	// and a tiny target never drops to zero
+	chain.headers[4031].Bits = header.BigToCompact(big.NewInt(1))
+	for i := uint64(2016); i <= 4031; i++ {
+		chain.headers[i].Timestamp = baseTime.Add(time.Duration(i) * time.Second)
+	}
+	if got, _ = Adjust(chain, chain.headers[4031]); got.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("fast retarget of target 1 = %v, want 1", got)
+	}
+}
+
+// scheduleChain builds headers 0..n with target 1000000 whose solve times
+// (from block 1 on) come from solve.
+func scheduleChain(n uint64, solve func(h uint64) time.Duration) *mockChain {
+	chain := &mockChain{headers: make(map[uint64]*header.Header), height: n}
+	ts := time.Unix(1760000000, 0)
+	for h := uint64(0); h <= n; h++ {
+		if h > 1 {
+			ts = ts.Add(solve(h))
+		}
+		chain.headers[h] = &header.Header{Height: h, Bits: header.BigToCompact(big.NewInt(1_000_000)), Timestamp: ts}
+	}
+	return chain
+}
+
+func withDifficulty(t *testing.T, algorithm string) {
+	old := config.DifficultyAlgorithm
+	config.DifficultyAlgorithm = algorithm
+	t.Cleanup(func() { config.DifficultyAlgorithm = old })
+}
+
+func TestASERTVectors(t *testing.T) {
+	withDifficulty(t, config.DifficultyASERT)
+	spacing := time.Duration(config.TargetBlockSpacingSec) * time.Second
+	halfLife := config.ASERTHalfLifeSec * time.Second
+	for _, tc := range []struct {
+		name  string
+		delay time.Duration // added to the last solve time
+		want  int64
+	}{
+		{"on schedule", 0, 1_000_000},
+		{"one half-life behind", halfLife, 2_000_000},
+		{"one half-life ahead", -halfLife, 500_000},
+		{"half a half-life behind", halfLife / 2, 1_414_093},
+		{"half a half-life ahead", -halfLife / 2, 707_046},
+	} {
+		chain := scheduleChain(10, func(h uint64) time.Duration {
+			if h == 10 {
+				return spacing + tc.delay
+			}
+			return spacing
+		})
+		got, err := NextTarget(chain, chain.headers[10])
+		if err != nil || got.Int64() != tc.want {
+			t.Errorf("%s: target %v, %v; want %d", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestLWMAVectors(t *testing.T) {
+	withDifficulty(t, config.DifficultyLWMA)
+	spacing := time.Duration(config.TargetBlockSpacingSec) * time.Second
+	for _, tc := range []struct {
+		name  string
+		solve time.Duration
+		want  int64
+	}{
+		{"on schedule", spacing, 1_000_000},
+		{"twice as slow", 2 * spacing, 2_000_000},
+		{"twice as fast", spacing / 2, 500_000},
+		{"stalled, clamped at 6x", 60 * spacing, 6_000_000},
+	} {
+		chain := scheduleChain(config.LWMAWindow+5, func(uint64) time.Duration { return tc.solve })
+		got, err := NextTarget(chain, chain.headers[chain.height])
+		if err != nil || got.Int64() != tc.want {
+			t.Errorf("%s: target %v, %v; want %d", tc.name, got, err, tc.want)
+		}
+	}
+
+	// Only the most recent solve time was slow; it weighs n/(n(n+1)/2)
+	n := int64(config.LWMAWindow)
+	chain := scheduleChain(config.LWMAWindow+5, func(h uint64) time.Duration {
+		if h == config.LWMAWindow+5 {
+			return 2 * spacing
+		}
+		return spacing
+	})
+	got, _ := NextTarget(chain, chain.headers[chain.height])
+	if want := 1_000_000 + 1_000_000*2/(n+1); got.Int64() != want {
+		t.Errorf("one slow block: target %v, want %d", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"poai/core/header"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Blocks and transactions use RLP as their canonical encoding for hashing,
+// storage and the wire. JSON remains the RPC representation; DecodeBlock
+// still accepts the JSON blocks written by older nodes.
+
+// txSigningFields is the RLP list hashed to produce a transaction hash.
+type txSigningFields struct {
+	Type     uint8
+	Data     []byte
+	From     []byte
+	To       []byte
+	Amount   *big.Int
+	Nonce    uint64
+	GasLimit uint64
+	GasPrice *big.Int
+}
+
+// rlpTx is the encoded transaction: the signing fields plus the signature.
+// The hash is not encoded; it is recomputed on decode.
+type rlpTx struct {
+	Type      uint8
+	Data      []byte
+	From      []byte
+	To        []byte
+	Amount    *big.Int
+	Nonce     uint64
+	GasLimit  uint64
+	GasPrice  *big.Int
+	Signature []byte
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (tx *Transaction) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, &rlpTx{
+		Type:      tx.Type,
+		Data:      tx.Data,
+		From:      tx.From,
+		To:        tx.To,
+		Amount:    tx.Amount,
+		Nonce:     tx.Nonce,
+		GasLimit:  tx.GasLimit,
+		GasPrice:  tx.GasPrice,
+		Signature: tx.Signature,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
+	var enc rlpTx
+	if err := s.Decode(&enc); err != nil {
+		return err
+	}
+	*tx = Transaction{
+		Type:      enc.Type,
+		Data:      enc.Data,
+		From:      enc.From,
+		To:        enc.To,
+		Amount:    enc.Amount,
+		Nonce:     enc.Nonce,
+		GasLimit:  enc.GasLimit,
+		GasPrice:  enc.GasPrice,
+		Signature: enc.Signature,
+	}
+	tx.Hash = tx.CalculateHash()
+	return nil
+}
+
+// rlpBlock is the encoded block. Time is Unix nanoseconds (0 for the zero
+// time), as in the header.
+type rlpBlock struct {
+	Header       *header.Header
+	Transactions []*Transaction
+	MerkleRoot   []byte
+	Time         uint64
+	Receipts     []byte   // unused; receipts are stored separately
+	Records      []uint64 `rlp:"optional"`
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (b *Block) EncodeRLP(w io.Writer) error {
+	var t uint64
+	if !b.Time.IsZero() {
+		t = uint64(b.Time.UnixNano())
+	}
+	return rlp.Encode(w, &rlpBlock{
+		Header:       &b.Header,
+		Transactions: b.Transactions,
+		MerkleRoot:   b.MerkleRoot,
+		Time:         t,
+		Records:      b.Records,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (b *Block) DecodeRLP(s *rlp.Stream) error {
+	var enc rlpBlock
+	if err := s.Decode(&enc); err != nil {
+		return err
+	}
+	*b = Block{
+		Header:       *enc.Header,
+		Transactions: enc.Transactions,
+		MerkleRoot:   enc.MerkleRoot,
+		Records:      enc.Records,
+	}
+	if enc.Time != 0 {
+		b.Time = time.Unix(0, int64(enc.Time))
+	}
+	return nil
+}
+
+// Encode serializes the block to its canonical RLP form for
+// storage/transmission.
+func (b *Block) Encode() ([]byte, error) {
+	return rlp.EncodeToBytes(b)
+}
+
+// DecodeBlock deserializes a block from RLP, or from the legacy JSON
+// encoding.
+func DecodeBlock(data []byte) (*Block, error) {
+	var block Block
+	if isLegacyJSON(data) {
+		err := json.Unmarshal(data, &block)
+		return &block, err
+	}
+	if err := rlp.DecodeBytes(data, &block); err != nil {
+		return nil, fmt.Errorf("decode block: %w", err)
+	}
+	return &block, nil
+}
+
+// isLegacyJSON reports whether data is a JSON object rather than an RLP
+// list (which always starts with a byte >= 0xc0).
+func isLegacyJSON(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// Encode serializes the transaction to its canonical RLP form.
+func (tx *Transaction) Encode() ([]byte, error) {
+	return rlp.EncodeToBytes(tx)
+}
+
+// DecodeTransaction deserializes a transaction from RLP.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var tx Transaction
+	if err := rlp.DecodeBytes(data, &tx); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %v", err)
+	}
+	return &tx, nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func signedTestBlock(t *testing.T) *Block {
+	t.Helper()
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	tx := NewTx(from, make([]byte, 20), big.NewInt(42), 3)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	coinbase := NewCoinbaseTx(make([]byte, 20), GetSubsidy(1))
+	b := NewBlock(1, [32]byte{9}, -5, big.NewInt(1000), []*Transaction{coinbase, tx}, 77)
+	b.Header.StateRoot = [32]byte{1}
+	return b
+}
+
+func TestBlockRLPRoundTrip(t *testing.T) {
+	b := signedTestBlock(t)
+	b.Header.Version = VersionBitsTopBits | 1<<3
+	data, err := b.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash() != b.Hash() || got.Header.Lhat != -5 || got.Header.Bits != b.Header.Bits ||
+		got.Header.StateRoot != b.Header.StateRoot || !got.Header.Timestamp.Equal(b.Header.Timestamp) ||
+		got.Header.Version != b.Header.Version {
+		t.Fatalf("header changed: %+v", got.Header)
+	}
+	if len(got.Transactions) != 2 || !bytes.Equal(got.MerkleRoot, b.MerkleRoot) {
+		t.Fatalf("body changed: %d txs", len(got.Transactions))
+	}
+	if err := got.Transactions[1].Verify(); err != nil {
+		t.Fatalf("decoded transaction does not verify: %v", err)
+	}
+	if !bytes.Equal(got.CalculateMerkleRoot(), b.MerkleRoot) {
+		t.Error("merkle root differs after decode")
+	}
+
+	// The encoding is canonical: re-encoding gives identical bytes
+	again, _ := got.Encode()
+	if !bytes.Equal(again, data) {
+		t.Error("re-encoding is not byte-identical")
+	}
+}
+
+func TestDecodeBlockAcceptsLegacyJSON(t *testing.T) {
+	b := signedTestBlock(t)
+	legacy, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeBlock(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash() != b.Hash() || len(got.Transactions) != 2 {
+		t.Fatalf("legacy block decoded as %+v", got)
+	}
+}
+
+func TestHeaderHashCommitsToAllFields(t *testing.T) {
+	b := signedTestBlock(t)
+	base := b.Hash()
+	if b.Header.TxRoot == ([32]byte{}) {
+		t.Fatal("NewBlock left TxRoot empty")
+	}
+	edits := map[string]func(h *Block){
+		"lhat":         func(h *Block) { h.Header.Lhat++ },
+		"bits":         func(h *Block) { h.Header.Bits++ },
+		"timestamp":    func(h *Block) { h.Header.Timestamp = h.Header.Timestamp.Add(1) },
+		"stateRoot":    func(h *Block) { h.Header.StateRoot[0]++ },
+		"receiptsRoot": func(h *Block) { h.Header.ReceiptsRoot[0]++ },
+		"txRoot":       func(h *Block) { h.Header.TxRoot[0]++ },
+		"version":      func(h *Block) { h.Header.Version++ },
+	}
+	for name, edit := range edits {
+		c := *b
+		edit(&c)
+		if c.Hash() == base {
+			t.Errorf("changing %s keeps the hash", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// ChainEventType names what a ChainEvent reports.
+type ChainEventType string
+
+const (
+	EventBlockAdded    ChainEventType = "blockAdded"    // Block became canonical
+	EventBlockRemoved  ChainEventType = "blockRemoved"  // Block was reorged out, newest first
+	EventReorgStarted  ChainEventType = "reorgStarted"  // blockRemoved and blockAdded events follow
+	EventReorgFinished ChainEventType = "reorgFinished" // the reorg's events are complete
+)
+
+// ChainEvent is one change to the canonical chain. A reorg is reported
+// as reorgStarted, a blockRemoved for every abandoned block from the old
+// tip down, a blockAdded for every branch block from the fork up and
+// reorgFinished, all delivered together; a reorg that fails and restores
+// the old chain is not reported.
+type ChainEvent struct {
+	Type  ChainEventType
+	Block *Block // blockAdded, blockRemoved
+
+	// reorgStarted, reorgFinished
+	ForkHeight uint64
+	OldTip     *Block
+	NewTip     *Block
+}
+
+// EventSubscription delivers chain events on C until Unsubscribe is
+// called. A subscriber that falls more than its buffer behind is
+// disconnected rather than skipping events, so it knows to resync; C is
+// closed when the subscription ends.
+type EventSubscription struct {
+	C <-chan ChainEvent
+
+	ch         chan ChainEvent
+	chain      *Chain
+	once       sync.Once
+	overflowed atomic.Bool
+}
+
+// Unsubscribe removes the subscription and closes C. It is safe to call
+// more than once.
+func (s *EventSubscription) Unsubscribe() {
+	s.chain.subMu.Lock()
+	defer s.chain.subMu.Unlock()
+	s.closeLocked()
+}
+
+// Overflowed reports whether the subscription ended because the
+// subscriber fell behind, rather than by Unsubscribe.
+func (s *EventSubscription) Overflowed() bool {
+	return s.overflowed.Load()
+}
+
+// closeLocked must be called with chain.subMu held.
+func (s *EventSubscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.chain.eventSubs, s)
+		close(s.ch)
+	})
+}
+
+// SubscribeChainEvents returns a subscription to chain events that queues
+// up to buffer of them.
+func (c *Chain) SubscribeChainEvents(buffer int) *EventSubscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan ChainEvent, buffer)
+	sub := &EventSubscription{C: ch, ch: ch, chain: c}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.eventSubs == nil {
+		c.eventSubs = make(map[*EventSubscription]struct{})
+	}
+	c.eventSubs[sub] = struct{}{}
+	return sub
+}
+
+// publishEvents delivers events, in order, to every event subscriber.
+func (c *Chain) publishEvents(events ...ChainEvent) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for sub := range c.eventSubs {
+		for _, ev := range events {
+			select {
+			case sub.ch <- ev:
+				continue
+			default:
+			}
+			log.Printf("[CHAIN] Closing slow chain event subscriber")
+			sub.overflowed.Store(true)
+			sub.closeLocked()
+			break
+		}
+	}
+}
+
+// reorgEvents returns the events reporting a reorg from the abandoned
+// blocks to the branch ones, both oldest first.
+func reorgEvents(forkHeight uint64, abandoned, branch []*Block) []ChainEvent {
+	var oldTip *Block
+	if len(abandoned) > 0 {
+		oldTip = abandoned[len(abandoned)-1]
+	}
+	newTip := branch[len(branch)-1]
+	events := make([]ChainEvent, 0, len(abandoned)+len(branch)+2)
+	events = append(events, ChainEvent{Type: EventReorgStarted, ForkHeight: forkHeight, OldTip: oldTip, NewTip: newTip})
+	for i := len(abandoned) - 1; i >= 0; i-- {
+		if abandoned[i] != nil {
+			events = append(events, ChainEvent{Type: EventBlockRemoved, Block: abandoned[i]})
+		}
+	}
+	for _, blk := range branch {
+		events = append(events, ChainEvent{Type: EventBlockAdded, Block: blk})
+	}
+	return append(events, ChainEvent{Type: EventReorgFinished, ForkHeight: forkHeight, OldTip: oldTip, NewTip: newTip})
+}
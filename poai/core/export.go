@@ -0,0 +1,266 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// An export file holds a run of consecutive canonical blocks, optionally
+// preceded by the account state after the first of them, so a new node can
+// start there instead of at genesis. It starts with exportMagic and is a
+// sequence of records: a kind byte, the uvarint payload length and the
+// payload. The first record is the ExportHeader (JSON), then the state
+// snapshot (JSON) if the header says so, then the blocks (RLP) in height
+// order.
+var exportMagic = []byte("POAIEXP1")
+
+const (
+	recordHeader   = 'h'
+	recordSnapshot = 's'
+	recordBlock    = 'b'
+)
+
+// maxExportRecord bounds the records read, so a corrupt length cannot
+// exhaust memory.
+const maxExportRecord = 1 << 30
+
+// ExportHeader describes the contents of an export file.
+type ExportHeader struct {
+	// Genesis is the hex hash of the genesis the chain was created from,
+	// empty if the exporting node no longer had the genesis block.
+	Genesis string `json:"genesis,omitempty"`
+	From    uint64 `json:"from"`
+	To      uint64 `json:"to"`
+	// State is set when the blocks are preceded by the account state after
+	// block From.
+	State bool `json:"state"`
+}
+
+// ErrExportMismatch is returned when an export file does not fit the local
+// chain: another genesis, or blocks that differ from the local ones.
+var ErrExportMismatch = errors.New("export does not match the local chain")
+
+func writeRecord(w io.Writer, kind byte, payload []byte) error {
+	buf := make([]byte, 1, 1+binary.MaxVarintLen64)
+	buf[0] = kind
+	buf = binary.AppendUvarint(buf, uint64(len(payload)))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readRecord reads the next record; io.EOF means there are no more.
+func readRecord(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	if n > maxExportRecord {
+		return 0, nil, fmt.Errorf("record of %d bytes", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return kind, payload, nil
+}
+
+// ExportChain writes the canonical blocks from..to to w. With state, the
+// account state after block from is written first; it comes from the
+// snapshot stored at from, or the current state if from is the tip.
+func (s *Store) ExportChain(w io.Writer, from, to uint64, state bool) (*ExportHeader, error) {
+	tip, err := s.GetTipHeight()
+	if err != nil {
+		return nil, fmt.Errorf("read tip: %w", err)
+	}
+	if to > tip || from > to {
+		return nil, fmt.Errorf("cannot export blocks #%d-#%d of a chain at #%d", from, to, tip)
+	}
+	hdr := &ExportHeader{From: from, To: to, State: state}
+	if gen, err := s.GetBlock(0); err == nil {
+		hdr.Genesis = hex.EncodeToString(gen.Header.ParentHash[:])
+	}
+	var snap *StateSnapshot
+	if state {
+		if snap, err = s.GetSnapshot(from); err != nil {
+			if from != tip {
+				return nil, fmt.Errorf("no state snapshot at #%d (snapshots are kept at checkpoints; export from one or from the tip): %w", from, err)
+			}
+			if snap, err = NewState(s.db).Snapshot(from); err != nil {
+				return nil, fmt.Errorf("snapshot state: %w", err)
+			}
+		}
+	}
+
+	if _, err := w.Write(exportMagic); err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeRecord(w, recordHeader, payload); err != nil {
+		return nil, err
+	}
+	if snap != nil {
+		if payload, err = json.Marshal(snap); err != nil {
+			return nil, err
+		}
+		if err := writeRecord(w, recordSnapshot, payload); err != nil {
+			return nil, err
+		}
+	}
+	for h := from; h <= to; h++ {
+		blk, err := s.GetBlock(h)
+		if err != nil {
+			return nil, fmt.Errorf("read block #%d: %w", h, err)
+		}
+		if payload, err = blk.Encode(); err != nil {
+			return nil, fmt.Errorf("encode block #%d: %w", h, err)
+		}
+		if err := writeRecord(w, recordBlock, payload); err != nil {
+			return nil, err
+		}
+	}
+	return hdr, nil
+}
+
+// ImportChain imports an export file into the chain. Blocks the chain
+// already has are checked against it and skipped; the rest go through
+// ImportBlocks, so they are verified like blocks from peers. A state
+// snapshot in the file seeds an empty chain (see BootstrapFromSnapshot)
+// and is otherwise skipped. It returns the header and the number of
+// blocks imported.
+func (c *Chain) ImportChain(r io.Reader) (*ExportHeader, int, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != string(exportMagic) {
+		return nil, 0, fmt.Errorf("not a chain export")
+	}
+	kind, payload, err := readRecord(br)
+	if err != nil || kind != recordHeader {
+		return nil, 0, fmt.Errorf("read export header: %v", err)
+	}
+	var hdr ExportHeader
+	if err := json.Unmarshal(payload, &hdr); err != nil {
+		return nil, 0, fmt.Errorf("decode export header: %w", err)
+	}
+	if want := c.genesis.Hash(); hdr.Genesis != "" && hdr.Genesis != hex.EncodeToString(want[:]) {
+		return nil, 0, fmt.Errorf("%w: exported from genesis %s, local genesis is %x", ErrExportMismatch, hdr.Genesis, want)
+	}
+
+	var snap *StateSnapshot
+	if hdr.State {
+		if kind, payload, err = readRecord(br); err != nil || kind != recordSnapshot {
+			return &hdr, 0, fmt.Errorf("read state snapshot: %v", err)
+		}
+		snap = new(StateSnapshot)
+		if err := json.Unmarshal(payload, snap); err != nil {
+			return &hdr, 0, fmt.Errorf("decode state snapshot: %w", err)
+		}
+	}
+
+	if snap == nil && hdr.From > c.Height()+1 {
+		return &hdr, 0, fmt.Errorf("export starts at block #%d, past the local tip #%d", hdr.From, c.Height())
+	}
+
+	imported := 0
+	batch := make([]*Block, 0, c.flushEvery())
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.ImportBlocks(batch)
+		imported += n
+		if err != nil {
+			return err
+		}
+		last := batch[len(batch)-1]
+		if c.Height() < last.Header.Height {
+			return fmt.Errorf("block #%d was not imported", last.Header.Height)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	next := hdr.From
+	for {
+		kind, payload, err := readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &hdr, imported, fmt.Errorf("read block #%d: %w", next, err)
+		}
+		if kind != recordBlock {
+			return &hdr, imported, fmt.Errorf("unexpected record %q before block #%d", kind, next)
+		}
+		blk, err := DecodeBlock(payload)
+		if err != nil {
+			return &hdr, imported, fmt.Errorf("decode block #%d: %w", next, err)
+		}
+		if blk.Header.Height != next || next > hdr.To {
+			return &hdr, imported, fmt.Errorf("found block #%d, want #%d", blk.Header.Height, next)
+		}
+		next++
+
+		if snap != nil {
+			if err := c.bootstrapFromExport(blk, snap); err != nil {
+				return &hdr, imported, err
+			}
+			snap = nil
+			continue
+		}
+		if blk.Header.Height <= c.Height() {
+			if have := c.BlockByHeight(blk.Header.Height); have != nil && have.Hash() != blk.Hash() {
+				return &hdr, imported, fmt.Errorf("%w: block #%d differs", ErrExportMismatch, blk.Header.Height)
+			}
+			continue
+		}
+		batch = append(batch, blk)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return &hdr, imported, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return &hdr, imported, err
+	}
+	if next != hdr.To+1 {
+		return &hdr, imported, fmt.Errorf("export ends at block #%d, want #%d", next-1, hdr.To)
+	}
+	return &hdr, imported, nil
+}
+
+// bootstrapFromExport starts an empty chain from an exported block and the
+// state after it, or checks the block against the chain if it already has
+// it.
+func (c *Chain) bootstrapFromExport(blk *Block, snap *StateSnapshot) error {
+	height := blk.Header.Height
+	if height <= c.Height() {
+		if have := c.BlockByHeight(height); have != nil && have.Hash() != blk.Hash() {
+			return fmt.Errorf("%w: block #%d differs", ErrExportMismatch, height)
+		}
+		return nil
+	}
+	if c.Height() > 0 {
+		return fmt.Errorf("local chain at #%d is behind the exported state at #%d; import it into a new data directory", c.Height(), height)
+	}
+	if err := c.BootstrapFromSnapshot(blk, snap); err != nil {
+		return fmt.Errorf("bootstrap from exported state: %w", err)
+	}
+	log.Printf("📦 Imported the state at #%d (%d accounts)", height, len(snap.Accounts))
+	return nil
+}
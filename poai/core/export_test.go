@@ -0,0 +1,62 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExportImportChain(t *testing.T) {
+	c := NewChain(t.TempDir(), 1000)
+	defer c.Close()
+	miner := bytes.Repeat([]byte{9}, 20)
+	importCoinbaseBlocks(t, c, miner, 4)
+
+	// Full history into a new chain
+	var full bytes.Buffer
+	if _, err := c.store.ExportChain(&full, 0, 4, false); err != nil {
+		t.Fatal(err)
+	}
+	fresh := func() *Chain {
+		d, err := NewMemoryChain(DefaultGenesis(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { d.Close() })
+		return d
+	}
+	d := fresh()
+	if _, n, err := d.ImportChain(bytes.NewReader(full.Bytes())); err != nil || n != 4 {
+		t.Fatalf("imported %d blocks: %v", n, err)
+	}
+	if d.Height() != 4 || d.BlockByHeight(4).Hash() != c.BlockByHeight(4).Hash() || d.GetBalance(miner).Cmp(c.GetBalance(miner)) != 0 {
+		t.Fatalf("imported chain at #%d, miner balance %v", d.Height(), d.GetBalance(miner))
+	}
+	// Importing it again changes nothing
+	if _, n, err := d.ImportChain(bytes.NewReader(full.Bytes())); err != nil || n != 0 {
+		t.Fatalf("re-imported %d blocks: %v", n, err)
+	}
+
+	// The state at the tip alone
+	var tip bytes.Buffer
+	if _, err := c.store.ExportChain(&tip, 4, 4, true); err != nil {
+		t.Fatal(err)
+	}
+	d = fresh()
+	if _, _, err := d.ImportChain(&tip); err != nil {
+		t.Fatal(err)
+	}
+	if d.Height() != 4 || d.GetBalance(miner).Cmp(c.GetBalance(miner)) != 0 {
+		t.Fatalf("bootstrapped chain at #%d, miner balance %v", d.Height(), d.GetBalance(miner))
+	}
+
+	// Another genesis is refused
+	other, err := NewMemoryChain(DefaultGenesis(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, _, err := other.ImportChain(bytes.NewReader(full.Bytes())); !errors.Is(err, ErrExportMismatch) {
+		t.Fatalf("imported into another chain: %v", err)
+	}
+}
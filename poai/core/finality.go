@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+var finalizedKey = []byte("chain:finalized")
+
+// PutFinalized persists the last finalized height.
+func (s *Store) PutFinalized(height uint64) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		return txn.Set(finalizedKey, []byte(strconv.FormatUint(height, 10)))
+	})
+}
+
+// GetFinalized returns the last finalized height, 0 if none.
+func (s *Store) GetFinalized() (uint64, error) {
+	var height uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(finalizedKey)
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseUint(string(val), 10, 64)
+		return err
+	})
+	if err == storage.ErrNotFound {
+		return 0, nil
+	}
+	return height, err
+}
+
+// FinalizedHeight returns the height below which the chain cannot reorg.
+func (c *Chain) FinalizedHeight() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.finalized
+}
+
+// updateFinality finalizes the newest checkpoint that has a full interval
+// of blocks on top of it. c.mu must be held.
+func (c *Chain) updateFinality() {
+	interval := config.FinalityInterval()
+	if interval == 0 || c.head < interval {
+		return
+	}
+	cp := (c.head - interval) / interval * interval
+	if cp <= c.finalized {
+		return
+	}
+	if err := c.store.PutFinalized(cp); err != nil {
+		log.Printf("[FINALITY] Failed to persist finalized height %d: %v", cp, err)
+		return
+	}
+	c.finalized = cp
+	if blk := c.blockAt(cp); blk != nil {
+		h := blk.Hash()
+		log.Printf("🔒 Finalized checkpoint #%d (%x)", cp, h[:8])
+	}
+}
+
+// checkFinalized rejects blocks that would replace finalized history.
+// c.mu must be held.
+func (c *Chain) checkFinalized(block *Block) error {
+	if c.finalized == 0 || block.Header.Height > c.finalized {
+		return nil
+	}
+	if existing := c.blockAt(block.Header.Height); existing != nil && existing.Hash() == block.Hash() {
+		return nil
+	}
+	return fmt.Errorf("block #%d conflicts with finalized checkpoint #%d", block.Header.Height, c.finalized)
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"poai/core/config"
+	"poai/core/header"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Genesis is the genesis.json a network is defined by: its consensus
+// parameters and premine. Its hash becomes the genesis block's parent
+// hash, so nodes started from different files never share a block.
+type Genesis struct {
+	ChainID          uint64            `json:"chainId"`
+	Timestamp        int64             `json:"timestamp"` // Unix seconds, 0 = none
+	Target           int64             `json:"target"`
+	EpochBlocks      uint64            `json:"epochBlocks"`
+	RetargetInterval uint64            `json:"retargetInterval"`
+	Difficulty       string            `json:"difficulty,omitempty"`   // config.Difficulty*, "" = bitcoin
+	BlockSpacing     int64             `json:"blockSpacing,omitempty"` // target seconds per block, 0 = config.DefaultBlockSpacingSec
+	ModelSHA256      string            `json:"modelSha256,omitempty"`
+	BridgeAuthority  string            `json:"bridgeAuthority,omitempty"` // hex address signing bridge unlocks, "" = unlocks disabled
+	Alloc            map[string]string `json:"alloc,omitempty"`           // hex address -> decimal balance
+}
+
+// genesisTestAccount is funded by DefaultGenesis for development chains.
+var genesisTestAccount = []byte("test-account-12345678901234567890123456789012")
+
+// DefaultGenesis returns the development genesis used without a
+// genesis.json: chain ID 0, the current epoch and retarget settings and
+// 1000 POAI for the test account.
+func DefaultGenesis(target int64) *Genesis {
+	return &Genesis{
+		Target:           target,
+		EpochBlocks:      config.EpochBlocks,
+		RetargetInterval: config.RetargetInterval,
+		Alloc:            map[string]string{hex.EncodeToString(genesisTestAccount): "1000"},
+	}
+}
+
+// RegtestChainID identifies regtest chains.
+const RegtestChainID = 31337
+
+// RegtestGenesis returns the genesis of --regtest: every loss meets the
+// target, the target never retargets and the test account is funded as on
+// development chains, so blocks can be generated on demand.
+func RegtestGenesis() *Genesis {
+	g := DefaultGenesis(math.MaxInt64)
+	g.ChainID = RegtestChainID
+	g.RetargetInterval = math.MaxUint64
+	return g
+}
+
+// LoadGenesis reads and validates a genesis.json.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var g Genesis
+	if err := dec.Decode(&g); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &g, nil
+}
+
+// Validate checks the parameters and normalises hex fields to lower case
+// without a 0x prefix, so equal files hash equally.
+func (g *Genesis) Validate() error {
+	if g.Target <= 0 {
+		return fmt.Errorf("target must be positive")
+	}
+	if g.EpochBlocks == 0 {
+		return fmt.Errorf("epochBlocks must be positive")
+	}
+	if g.RetargetInterval == 0 {
+		return fmt.Errorf("retargetInterval must be positive")
+	}
+	if g.BlockSpacing < 0 {
+		return fmt.Errorf("blockSpacing must not be negative")
+	}
+	if g.BlockSpacing == config.DefaultBlockSpacingSec {
+		g.BlockSpacing = 0 // the default, so spelling it out hashes the same
+	}
+	switch g.Difficulty = strings.ToLower(g.Difficulty); g.Difficulty {
+	case config.DifficultyBitcoin:
+		g.Difficulty = "" // the default, so spelling it out hashes the same
+	case "", config.DifficultyLWMA, config.DifficultyASERT:
+	default:
+		return fmt.Errorf("difficulty %q is not one of %s, %s or %s", g.Difficulty,
+			config.DifficultyBitcoin, config.DifficultyLWMA, config.DifficultyASERT)
+	}
+	g.ModelSHA256 = strings.ToLower(strings.TrimPrefix(g.ModelSHA256, "0x"))
+	if g.ModelSHA256 != "" {
+		if b, err := hex.DecodeString(g.ModelSHA256); err != nil || len(b) != 32 {
+			return fmt.Errorf("modelSha256 %q is not a hex SHA-256", g.ModelSHA256)
+		}
+	}
+	g.BridgeAuthority = strings.ToLower(strings.TrimPrefix(g.BridgeAuthority, "0x"))
+	if g.BridgeAuthority != "" {
+		if b, err := hex.DecodeString(g.BridgeAuthority); err != nil || len(b) != 20 {
+			return fmt.Errorf("bridgeAuthority %q is not a hex address", g.BridgeAuthority)
+		}
+	}
+	alloc := make(map[string]string, len(g.Alloc))
+	for addr, bal := range g.Alloc {
+		key := strings.ToLower(strings.TrimPrefix(addr, "0x"))
+		if b, err := hex.DecodeString(key); err != nil || len(b) == 0 {
+			return fmt.Errorf("alloc address %q is not hex", addr)
+		}
+		amount, ok := new(big.Int).SetString(bal, 10)
+		if !ok || amount.Sign() < 0 {
+			return fmt.Errorf("alloc balance %q for %s is not a non-negative integer", bal, addr)
+		}
+		if _, dup := alloc[key]; dup {
+			return fmt.Errorf("alloc address %s listed twice", key)
+		}
+		alloc[key] = amount.String()
+	}
+	g.Alloc = alloc
+	return nil
+}
+
+// Hash is the SHA3-256 of the canonical JSON encoding.
+func (g *Genesis) Hash() [32]byte {
+	data, _ := json.Marshal(g) // map keys are sorted, so this is canonical
+	return sha3.Sum256(data)
+}
+
+// Apply sets the consensus parameters the genesis carries.
+func (g *Genesis) Apply() {
+	config.ChainID = g.ChainID
+	config.EpochBlocks = g.EpochBlocks
+	config.RetargetInterval = g.RetargetInterval
+	config.DifficultyAlgorithm = config.DifficultyBitcoin
+	if g.Difficulty != "" {
+		config.DifficultyAlgorithm = g.Difficulty
+	}
+	config.TargetBlockSpacingSec = config.DefaultBlockSpacingSec
+	if g.BlockSpacing != 0 {
+		config.TargetBlockSpacingSec = g.BlockSpacing
+	}
+	BridgeAuthority = nil
+	if g.BridgeAuthority != "" {
+		BridgeAuthority, _ = hex.DecodeString(g.BridgeAuthority)
+	}
+}
+
+// Block builds the genesis block on top of the given state root.
+func (g *Genesis) Block(stateRoot [32]byte) *Block {
+	var ts time.Time // 0 stays the zero time, as RLP encodes it
+	if g.Timestamp != 0 {
+		ts = time.Unix(g.Timestamp, 0)
+	}
+	return &Block{
+		Header: header.Header{
+			Height:     0,
+			ParentHash: g.Hash(),
+			Bits:       header.BigToCompact(big.NewInt(g.Target)),
+			Timestamp:  ts,
+			StateRoot:  stateRoot,
+		},
+		Time: ts,
+	}
+}
+
+// AllocTotal returns the sum of the premine. Balances that do not parse
+// count as zero; Validate rejects them.
+func (g *Genesis) AllocTotal() *big.Int {
+	total := new(big.Int)
+	for _, bal := range g.Alloc {
+		if amount, ok := new(big.Int).SetString(bal, 10); ok {
+			total.Add(total, amount)
+		}
+	}
+	return total
+}
+
+// initializeGenesisState credits the premine and counts it as issued.
+func (s *State) initializeGenesisState(g *Genesis) error {
+	addrs := make([]string, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		raw, err := hex.DecodeString(strings.TrimPrefix(addr, "0x"))
+		if err != nil {
+			return fmt.Errorf("alloc address %q: %v", addr, err)
+		}
+		amount, ok := new(big.Int).SetString(g.Alloc[addr], 10)
+		if !ok {
+			return fmt.Errorf("alloc balance %q for %s", g.Alloc[addr], addr)
+		}
+		if err := s.SetBalance(raw, amount); err != nil {
+			return err
+		}
+	}
+	return s.addSupply(g.AllocTotal(), new(big.Int))
+}
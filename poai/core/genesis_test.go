@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"poai/core/config"
+)
+
+func TestGenesisFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	os.WriteFile(path, []byte(`{
+		"chainId": 7, "timestamp": 1760000000, "target": 5000,
+		"epochBlocks": 20, "retargetInterval": 2016,
+		"alloc": {"0xABCD": "250"}
+	}`), 0o644)
+	g, err := LoadGenesis(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Alloc["abcd"] != "250" {
+		t.Fatalf("alloc not normalised: %v", g.Alloc)
+	}
+
+	dir := t.TempDir()
+	c, err := NewChainFromGenesis(dir, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := c.BlockByHeight(0)
+	if gen.Header.ParentHash != g.Hash() || gen.Header.Target().Int64() != 5000 || gen.Header.Timestamp.Unix() != 1760000000 {
+		t.Fatalf("genesis header does not match the file: %+v", gen.Header)
+	}
+	if bal := c.GetBalance([]byte{0xab, 0xcd}); bal.Int64() != 250 {
+		t.Fatalf("premine balance %s", bal)
+	}
+	c.Close()
+
+	// Reopening needs the same genesis
+	other := *g
+	other.ChainID = 8
+	if other.Hash() == g.Hash() {
+		t.Fatal("chain ID not part of the genesis hash")
+	}
+	if c, err := NewChainFromGenesis(dir, &other); err == nil {
+		c.Close()
+		t.Fatal("chain opened with a different genesis")
+	}
+	c, err = NewChainFromGenesis(dir, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	os.WriteFile(path, []byte(`{"chainId": 1, "target": 0, "epochBlocks": 20, "retargetInterval": 2016}`), 0o644)
+	if _, err := LoadGenesis(path); err == nil {
+		t.Fatal("zero target accepted")
+	}
+
+	// The default difficulty algorithm may be spelled out without changing the hash
+	explicit := *g
+	explicit.Difficulty = "Bitcoin"
+	if err := explicit.Validate(); err != nil || explicit.Hash() != g.Hash() {
+		t.Fatalf("explicit bitcoin difficulty: %v, hash changed %v", err, explicit.Hash() != g.Hash())
+	}
+	explicit.Difficulty = "ASERT"
+	if err := explicit.Validate(); err != nil || explicit.Difficulty != "asert" || explicit.Hash() == g.Hash() {
+		t.Fatalf("asert difficulty: %q, %v", explicit.Difficulty, err)
+	}
+	explicit.Difficulty = "dgw"
+	if err := explicit.Validate(); err == nil {
+		t.Fatal("unknown difficulty algorithm accepted")
+	}
+
+	// The bridge authority is a consensus parameter, set by Apply
+	bridged := *g
+	bridged.BridgeAuthority = "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	if err := bridged.Validate(); err != nil || bridged.Hash() == g.Hash() {
+		t.Fatalf("bridge authority: %v, hash changed %v", err, bridged.Hash() != g.Hash())
+	}
+	defer (&Genesis{EpochBlocks: config.EpochBlocks, RetargetInterval: config.RetargetInterval}).Apply()
+	bridged.Apply()
+	if hex.EncodeToString(BridgeAuthority) != strings.Repeat("aa", 20) {
+		t.Fatalf("bridge authority applied as %x", BridgeAuthority)
+	}
+	g.Apply()
+	if BridgeAuthority != nil {
+		t.Fatalf("bridge authority %x kept by a genesis without one", BridgeAuthority)
+	}
+	bridged.BridgeAuthority = "abcd"
+	if err := bridged.Validate(); err == nil {
+		t.Fatal("short bridge authority accepted")
+	}
+}
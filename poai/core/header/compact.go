@@ -0,0 +1,57 @@
+package header
+
+import "math/big"
+
+// Targets are carried in headers in Bitcoin's compact form: the top byte is
+// the length in bytes of the magnitude, the low 23 bits its leading bytes
+// and bit 23 the sign, so the negative loss targets of hard chains encode
+// too. Only the leading 23 bits of a target survive.
+
+// CompactToBig expands a compact target.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := int64(compact & 0x007fffff)
+	negative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var n *big.Int
+	if exponent <= 3 {
+		n = big.NewInt(mantissa >> (8 * (3 - exponent)))
+	} else {
+		n = new(big.Int).Lsh(big.NewInt(mantissa), 8*(exponent-3))
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return n
+}
+
+// BigToCompact encodes a target in compact form, truncating its magnitude
+// to the leading 23 bits. A nil target encodes as 0.
+func BigToCompact(n *big.Int) uint32 {
+	if n == nil || n.Sign() == 0 {
+		return 0
+	}
+	abs := new(big.Int).Abs(n)
+	exponent := uint(len(abs.Bytes()))
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(abs.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(abs, 8*(exponent-3)).Uint64())
+	}
+	// Keep bit 23 free for the sign
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+	return compact
+}
+
+// Target returns the header's target, expanded from Bits.
+func (h *Header) Target() *big.Int {
+	return CompactToBig(h.Bits)
+}
@@ -0,0 +1,62 @@
+package header
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestCompactGoldenVectors(t *testing.T) {
+	for _, tc := range []struct {
+		compact uint32
+		target  string
+	}{
+		{0x00000000, "0"},
+		{0x02008000, "128"}, // bit 23 is the sign, so 0x80 takes two bytes
+		{0x0203e800, "1000"},
+		{0x0283e800, "-1000"},
+		{0x030f423f, "999999"},
+		{0x04123456, "305419776"},
+		{0x1d00ffff, "26959535291011309493156476344723991336010898738574164086137773096960"},
+	} {
+		want, _ := new(big.Int).SetString(tc.target, 10)
+		if got := CompactToBig(tc.compact); got.Cmp(want) != 0 {
+			t.Errorf("CompactToBig(%08x) = %v, want %v", tc.compact, got, want)
+		}
+		if got := BigToCompact(want); got != tc.compact {
+			t.Errorf("BigToCompact(%v) = %08x, want %08x", want, got, tc.compact)
+		}
+	}
+	if BigToCompact(nil) != 0 {
+		t.Error("nil target is not 0")
+	}
+}
+
+func TestCompactTruncatesToLeadingBits(t *testing.T) {
+	n := big.NewInt(1<<62 + 12345)
+	if got := CompactToBig(BigToCompact(n)); got.Cmp(big.NewInt(1<<62)) != 0 {
+		t.Errorf("%v encodes as %v, want %v", n, got, int64(1<<62))
+	}
+	n.Neg(n)
+	if got := CompactToBig(BigToCompact(n)); got.Cmp(big.NewInt(-1<<62)) != 0 {
+		t.Errorf("%v encodes as %v, want %v", n, got, int64(-1<<62))
+	}
+}
+
+func TestHeaderJSONAcceptsLegacyBits(t *testing.T) {
+	var h Header
+	if err := json.Unmarshal([]byte(`{"Height":3,"bits":"1000"}`), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Height != 3 || h.Target().Int64() != 1000 {
+		t.Fatalf("legacy header decoded as %+v", h)
+	}
+	data, err := json.Marshal(&h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Header
+	if err := json.Unmarshal(data, &again); err != nil || again.Bits != h.Bits {
+		t.Fatalf("round trip via %s: %+v, %v", data, again, err)
+	}
+}
@@ -2,12 +2,15 @@
 package header
 
 import (
-	"crypto/sha3"
-	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/sha3"
 )
 
 // Header is a *minimal* canonical representation.
@@ -16,36 +19,37 @@ type Header struct {
 	Height     uint64
 	ParentHash [32]byte
 	Lhat       int64
-	Bits       *big.Int `json:"bits,string"`
+	Bits       uint32 `json:"bits"` // compact target, see CompactToBig
 	Timestamp  time.Time
-	StateRoot  [32]byte // Placeholder for state trie root
+	StateRoot  [32]byte // Merkle root over accounts after executing the block
 	Nonce      uint64   `json:"nonce"` // Mining nonce for probabilistic search
-	// Add real fields here…
+	// ReceiptsRoot is the Merkle root over the block's receipt hashes
+	ReceiptsRoot [32]byte `json:"receiptsRoot"`
+	// TxRoot is the Merkle root over the block's transaction hashes
+	TxRoot [32]byte `json:"txRoot"`
+	// Version carries the miner's soft-fork signals (see core.Deployment);
+	// 0 for headers that signal nothing
+	Version uint32 `json:"version"`
 }
 
-// MarshalJSON ensures Bits is encoded as a string
+// MarshalJSON adds the expanded target next to the compact Bits.
 func (h *Header) MarshalJSON() ([]byte, error) {
 	type Alias Header
 	return json.Marshal(&struct {
-		Bits string `json:"bits"`
 		*Alias
+		Target string `json:"target"`
 	}{
-		Bits: func() string {
-			if h.Bits != nil {
-				return h.Bits.String()
-			} else {
-				return "0"
-			}
-		}(),
-		Alias: (*Alias)(h),
+		Alias:  (*Alias)(h),
+		Target: h.Target().String(),
 	})
 }
 
-// UnmarshalJSON ensures Bits is decoded from a string
+// UnmarshalJSON also accepts the decimal target string headers were
+// written with before compact bits.
 func (h *Header) UnmarshalJSON(data []byte) error {
 	type Alias Header
 	temp := &struct {
-		Bits string `json:"bits"`
+		Bits json.RawMessage `json:"bits"`
 		*Alias
 	}{
 		Alias: (*Alias)(h),
@@ -53,12 +57,82 @@ func (h *Header) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &temp); err != nil {
 		return err
 	}
-	if temp.Bits != "" {
-		bi := new(big.Int)
-		bi.SetString(temp.Bits, 10)
-		h.Bits = bi
-	} else {
-		h.Bits = big.NewInt(0)
+	h.Bits = 0
+	if len(temp.Bits) == 0 {
+		return nil
+	}
+	if temp.Bits[0] != '"' {
+		return json.Unmarshal(temp.Bits, &h.Bits)
+	}
+	var legacy string
+	if err := json.Unmarshal(temp.Bits, &legacy); err != nil {
+		return err
+	}
+	target, ok := new(big.Int).SetString(legacy, 10)
+	if !ok {
+		return fmt.Errorf("header bits %q is not a decimal target", legacy)
+	}
+	h.Bits = BigToCompact(target)
+	return nil
+}
+
+// rlpHeader is the canonical RLP layout of a header. Lhat is carried as its
+// two's-complement uint64 and Timestamp as Unix nanoseconds (0 for the zero
+// time).
+type rlpHeader struct {
+	Height     uint64
+	ParentHash [32]byte
+	Lhat       uint64
+	Bits       uint32
+	Timestamp  uint64
+	StateRoot  [32]byte
+	Nonce      uint64
+	// Headers written before receipts end at Nonce
+	ReceiptsRoot [32]byte `rlp:"optional"`
+	TxRoot       [32]byte `rlp:"optional"`
+	// Version 0 is left out, so headers signalling nothing hash as before
+	Version uint32 `rlp:"optional"`
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (h *Header) EncodeRLP(w io.Writer) error {
+	var ts uint64
+	if !h.Timestamp.IsZero() {
+		ts = uint64(h.Timestamp.UnixNano())
+	}
+	return rlp.Encode(w, &rlpHeader{
+		Height:       h.Height,
+		ParentHash:   h.ParentHash,
+		Lhat:         uint64(h.Lhat),
+		Bits:         h.Bits,
+		Timestamp:    ts,
+		StateRoot:    h.StateRoot,
+		Nonce:        h.Nonce,
+		ReceiptsRoot: h.ReceiptsRoot,
+		TxRoot:       h.TxRoot,
+		Version:      h.Version,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (h *Header) DecodeRLP(s *rlp.Stream) error {
+	var enc rlpHeader
+	if err := s.Decode(&enc); err != nil {
+		return err
+	}
+	*h = Header{
+		Height:       enc.Height,
+		ParentHash:   enc.ParentHash,
+		Lhat:         int64(enc.Lhat),
+		Bits:         enc.Bits,
+		StateRoot:    enc.StateRoot,
+		Nonce:        enc.Nonce,
+		ReceiptsRoot: enc.ReceiptsRoot,
+		TxRoot:       enc.TxRoot,
+		Version:      enc.Version,
+	}
+	if enc.Timestamp != 0 {
+		h.Timestamp = time.Unix(0, int64(enc.Timestamp))
 	}
 	return nil
 }
@@ -68,18 +142,19 @@ type Block struct {
 	// Add real fields here…
 }
 
-// Hash returns the Keccak-256 of the RLP-encoded header.
-// For now we hash Height, ParentHash, and Nonce; swap in full RLP once ready.
+// Hash returns the SHA3-256 of the RLP-encoded header, so it commits to
+// every consensus field: the loss, target, timestamp and all three roots.
 func (h *Header) Hash() [32]byte {
 	if h == nil {
 		log.Printf("[ERROR] Header.Hash() called on nil header, returning zero hash")
 		return [32]byte{}
 	}
-	var buf [48]byte // 8 bytes height + 32 bytes parent hash + 8 bytes nonce
-	binary.LittleEndian.PutUint64(buf[:8], h.Height)
-	copy(buf[8:40], h.ParentHash[:])
-	binary.LittleEndian.PutUint64(buf[40:], h.Nonce)
-	return sha3.Sum256(buf[:])
+	data, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		log.Printf("[ERROR] Header.Hash() cannot encode header #%d: %v", h.Height, err)
+		return [32]byte{}
+	}
+	return sha3.Sum256(data)
 }
 
 // ... header logic will go here ...
@@ -1,30 +1,204 @@
 package core
 
 import (
+	"container/heap"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"math/big"
 	"sync"
 	"time"
+
+	"poai/core/config"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
-// Mempool manages pending transactions
+// Mempool manages pending transactions. Transactions whose nonce follows
+// the sender's state nonce without gaps are pending (executable) and can be
+// mined; later ones wait in a per-sender queue until the gap closes.
 type Mempool struct {
-	txs   map[string]*Transaction // Key: transaction hash hex
+	txs   map[string]*Transaction // pending, key: transaction hash hex
 	mu    sync.RWMutex
 	state *State
+
+	// Priority queue by effective gas price, kept in sync with txs
+	priced  txPriceHeap
+	entries map[string]*pricedTx
+	seq     uint64
+
+	accounts map[string]map[uint64]*Transaction // sender hex -> nonce -> tx, pending and queued
+	queued   map[string]*Transaction            // future transactions by hash hex
+
+	subMu       sync.Mutex
+	subscribers map[*TxSubscription]struct{}
+}
+
+const (
+	// maxNonceGap bounds how far ahead of the state nonce a queued
+	// transaction may be.
+	maxNonceGap = 64
+	// priceBumpPercent is the gas price increase needed to replace a
+	// transaction with the same sender and nonce.
+	priceBumpPercent = 10
+)
+
+// TxSubscription delivers transactions as they enter the mempool. Slow
+// consumers miss transactions rather than blocking the mempool.
+type TxSubscription struct {
+	C <-chan *Transaction
+
+	ch   chan *Transaction
+	mp   *Mempool
+	once sync.Once
+}
+
+// Unsubscribe stops delivery and closes C.
+func (s *TxSubscription) Unsubscribe() {
+	s.mp.subMu.Lock()
+	defer s.mp.subMu.Unlock()
+	s.once.Do(func() {
+		delete(s.mp.subscribers, s)
+		close(s.ch)
+	})
+}
+
+// SubscribeNewTransactions returns a subscription to newly added transactions.
+func (mp *Mempool) SubscribeNewTransactions(buffer int) *TxSubscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan *Transaction, buffer)
+	sub := &TxSubscription{C: ch, ch: ch, mp: mp}
+	mp.subMu.Lock()
+	defer mp.subMu.Unlock()
+	if mp.subscribers == nil {
+		mp.subscribers = make(map[*TxSubscription]struct{})
+	}
+	mp.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (mp *Mempool) notifyNewTransaction(tx *Transaction) {
+	mp.subMu.Lock()
+	defer mp.subMu.Unlock()
+	for sub := range mp.subscribers {
+		select {
+		case sub.ch <- tx:
+		default:
+		}
+	}
 }
 
 // NewMempool creates a new mempool
 func NewMempool(state *State) *Mempool {
 	return &Mempool{
-		txs:   make(map[string]*Transaction),
-		state: state,
+		txs:      make(map[string]*Transaction),
+		state:    state,
+		entries:  make(map[string]*pricedTx),
+		accounts: make(map[string]map[uint64]*Transaction),
+		queued:   make(map[string]*Transaction),
+	}
+}
+
+// insertLocked adds tx to the pending set and the priority queue; mp.mu
+// must be held.
+func (mp *Mempool) insertLocked(txHash string, tx *Transaction) {
+	mp.txs[txHash] = tx
+	mp.seq++
+	e := &pricedTx{tx: tx, seq: mp.seq}
+	mp.entries[txHash] = e
+	heap.Push(&mp.priced, e)
+}
+
+// deleteLocked removes a transaction from the pending set and the priority
+// queue; mp.mu must be held. It reports whether the transaction was pending.
+func (mp *Mempool) deleteLocked(txHash string) bool {
+	if _, ok := mp.txs[txHash]; !ok {
+		return false
+	}
+	delete(mp.txs, txHash)
+	if e, ok := mp.entries[txHash]; ok {
+		heap.Remove(&mp.priced, e.index)
+		delete(mp.entries, txHash)
+	}
+	return true
+}
+
+// lookupLocked returns the pooled transaction with the given hash, pending
+// or queued.
+func (mp *Mempool) lookupLocked(txHash string) *Transaction {
+	if tx, ok := mp.txs[txHash]; ok {
+		return tx
+	}
+	return mp.queued[txHash]
+}
+
+// dropLocked removes a pooled transaction entirely; mp.mu must be held.
+func (mp *Mempool) dropLocked(tx *Transaction) {
+	txHash := hex.EncodeToString(tx.Hash)
+	mp.deleteLocked(txHash)
+	delete(mp.queued, txHash)
+	from := hex.EncodeToString(tx.From)
+	if list := mp.accounts[from]; list != nil && list[tx.Nonce] == tx {
+		delete(list, tx.Nonce)
+		if len(list) == 0 {
+			delete(mp.accounts, from)
+		}
+	}
+}
+
+// promoteLocked re-sorts a sender's transactions against its state nonce:
+// stale ones are dropped, the gapless run from base becomes pending and
+// anything after a gap is queued; mp.mu must be held.
+func (mp *Mempool) promoteLocked(from string, base uint64) {
+	list := mp.accounts[from]
+	for n, tx := range list {
+		if n < base {
+			mp.dropLocked(tx)
+		}
+	}
+	next := base
+	for {
+		tx, ok := list[next]
+		if !ok {
+			break
+		}
+		txHash := hex.EncodeToString(tx.Hash)
+		if _, queued := mp.queued[txHash]; queued {
+			delete(mp.queued, txHash)
+			mp.insertLocked(txHash, tx)
+			mp.notifyNewTransaction(tx)
+		}
+		next++
+	}
+	for n, tx := range list {
+		if n > next {
+			txHash := hex.EncodeToString(tx.Hash)
+			if mp.deleteLocked(txHash) {
+				mp.queued[txHash] = tx
+			}
+		}
+	}
+	if len(list) == 0 {
+		delete(mp.accounts, from)
+	}
+}
+
+// replaces reports whether tx pays enough more than old to replace it.
+func replaces(tx, old *Transaction) bool {
+	min := new(big.Int).Mul(gasPrice(old), big.NewInt(100+priceBumpPercent))
+	min.Div(min, big.NewInt(100))
+	if min.Cmp(gasPrice(old)) == 0 {
+		min.Add(min, big.NewInt(1)) // always require a strict increase
 	}
+	return gasPrice(tx).Cmp(min) >= 0
 }
 
-// AddTransaction adds a transaction to the mempool
+// AddTransaction adds a transaction to the mempool. Transactions may be
+// submitted ahead of the sender's next nonce; they are queued until the
+// nonces before them arrive. A transaction with the same sender and nonce
+// as a pooled one replaces it if it pays priceBumpPercent more gas.
 func (mp *Mempool) AddTransaction(tx *Transaction) error {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
@@ -36,78 +210,175 @@ func (mp *Mempool) AddTransaction(tx *Transaction) error {
 
 	// Check if transaction already exists
 	txHash := hex.EncodeToString(tx.Hash)
-	if _, exists := mp.txs[txHash]; exists {
+	if mp.lookupLocked(txHash) != nil {
 		return fmt.Errorf("transaction already in mempool")
 	}
 
-	// Validate transaction
-	if err := mp.state.ValidateTransaction(tx); err != nil {
+	if err := tx.Verify(); err != nil {
+		return fmt.Errorf("transaction validation failed: transaction verification failed: %v", err)
+	}
+	// A transaction that cannot fit in any block would never leave the pool
+	if tx.GasLimit > config.MaxBlockGas {
+		return fmt.Errorf("transaction validation failed: gas limit %d exceeds block gas limit %d", tx.GasLimit, config.MaxBlockGas)
+	}
+	if data, err := rlp.EncodeToBytes(tx); err != nil || len(data) > config.MaxBlockSize-blockOverhead {
+		return fmt.Errorf("transaction validation failed: too large for a block")
+	}
+	if tx.IsCoinbase() {
+		mp.insertLocked(txHash, tx)
+		log.Printf("[MEMPOOL] Added transaction %s: %s", txHash[:8], tx.String())
+		mp.notifyNewTransaction(tx)
+		return nil
+	}
+
+	from := hex.EncodeToString(tx.From)
+	base := mp.state.GetNonce(tx.From)
+	if tx.Nonce < base {
+		return fmt.Errorf("transaction validation failed: nonce too low: expected at least %d, got %d", base, tx.Nonce)
+	}
+	if tx.Nonce >= base+maxNonceGap {
+		return fmt.Errorf("transaction validation failed: nonce too far ahead: next is %d, got %d", base, tx.Nonce)
+	}
+	list := mp.accounts[from]
+	old := list[tx.Nonce]
+	if old != nil && !replaces(tx, old) {
+		return fmt.Errorf("replacement transaction underpriced: gas price %s does not exceed %s by %d%%",
+			gasPrice(tx), gasPrice(old), priceBumpPercent)
+	}
+
+	// The sender must afford this transaction after its earlier ones
+	cost := tx.Cost()
+	for n, other := range list {
+		if n < tx.Nonce {
+			cost.Add(cost, other.Cost())
+		}
+	}
+	if err := mp.state.validateSpend(tx, cost); err != nil {
 		return fmt.Errorf("transaction validation failed: %v", err)
 	}
 
-	// Add to mempool
-	mp.txs[txHash] = tx
-	log.Printf("[MEMPOOL] Added transaction %s: %s", txHash[:8], tx.String())
+	if old != nil {
+		mp.dropLocked(old)
+		log.Printf("[MEMPOOL] Replacing transaction %x with higher gas price", old.Hash[:4])
+	}
+	if mp.accounts[from] == nil {
+		mp.accounts[from] = make(map[uint64]*Transaction)
+	}
+	mp.accounts[from][tx.Nonce] = tx
+	mp.queued[txHash] = tx
+	mp.promoteLocked(from, base)
 
+	if _, pending := mp.txs[txHash]; pending {
+		log.Printf("[MEMPOOL] Added transaction %s: %s", txHash[:8], tx.String())
+	} else {
+		log.Printf("[MEMPOOL] Queued transaction %s until nonce %d: %s", txHash[:8], mp.PendingNonceLocked(tx.From), tx.String())
+	}
 	return nil
 }
 
-// GetTransaction returns a transaction by hash
+// PendingNonce returns the next nonce for addr after its pending
+// transactions, i.e. the nonce a new transaction should use.
+func (mp *Mempool) PendingNonce(addr []byte) uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.PendingNonceLocked(addr)
+}
+
+// PendingNonceLocked is PendingNonce for callers holding mp.mu.
+func (mp *Mempool) PendingNonceLocked(addr []byte) uint64 {
+	next := mp.state.GetNonce(addr)
+	list := mp.accounts[hex.EncodeToString(addr)]
+	for {
+		if _, ok := list[next]; !ok {
+			return next
+		}
+		next++
+	}
+}
+
+// GetTransaction returns a pending or queued transaction by hash
 func (mp *Mempool) GetTransaction(hash []byte) *Transaction {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 
-	txHash := hex.EncodeToString(hash)
-	return mp.txs[txHash]
+	return mp.lookupLocked(hex.EncodeToString(hash))
 }
 
-// GetTransactionsForBlock returns transactions to include in a block
+// blockOverhead is the block space kept free for the header and coinbase
+// when filling a block from the mempool.
+const blockOverhead = 4096
+
+// GetTransactionsForBlock returns up to maxTxs transactions to include in a
+// block, highest effective gas price first and in nonce order per sender,
+// cut off before they exceed the block gas or size limit.
 func (mp *Mempool) GetTransactionsForBlock(maxTxs int) []*Transaction {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
-
-	var txs []*Transaction
-	for _, tx := range mp.txs {
-		txs = append(txs, tx)
-		if len(txs) >= maxTxs {
-			break
+	txs := selectByPrice(mp.priced, maxTxs)
+	var gas uint64
+	size := blockOverhead
+	for i, tx := range txs {
+		data, err := rlp.EncodeToBytes(tx)
+		if err != nil || tx.GasLimit > config.MaxBlockGas-gas || size+len(data) > config.MaxBlockSize {
+			// Any prefix keeps each sender's nonces contiguous
+			return txs[:i]
 		}
+		gas += tx.GasLimit
+		size += len(data)
 	}
-
 	return txs
 }
 
-// RemoveTransaction removes a transaction from the mempool
+// RemoveTransaction removes a transaction from the mempool. The sender's
+// later transactions go back to the queue until the nonce is filled again.
 func (mp *Mempool) RemoveTransaction(hash []byte) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
 	txHash := hex.EncodeToString(hash)
-	if tx, exists := mp.txs[txHash]; exists {
-		delete(mp.txs, txHash)
+	if tx := mp.lookupLocked(txHash); tx != nil {
+		mp.dropLocked(tx)
+		mp.promoteLocked(hex.EncodeToString(tx.From), mp.state.GetNonce(tx.From))
 		log.Printf("[MEMPOOL] Removed transaction %s: %s", txHash[:8], tx.String())
 	}
 }
 
-// RemoveTransactions removes multiple transactions from the mempool
+// RemoveTransactions removes transactions included in a block and promotes
+// queued transactions whose nonce gap the block closed.
 func (mp *Mempool) RemoveTransactions(txs []*Transaction) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
+	senders := make(map[string][]byte)
 	for _, tx := range txs {
-		txHash := hex.EncodeToString(tx.Hash)
-		delete(mp.txs, txHash)
+		if pooled := mp.lookupLocked(hex.EncodeToString(tx.Hash)); pooled != nil {
+			mp.dropLocked(pooled)
+		}
+		if !tx.IsCoinbase() {
+			senders[hex.EncodeToString(tx.From)] = tx.From
+		}
+	}
+	for from, addr := range senders {
+		mp.promoteLocked(from, mp.state.GetNonce(addr))
 	}
 }
 
-// Size returns the number of transactions in the mempool
+// Size returns the number of pending transactions in the mempool
 func (mp *Mempool) Size() int {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 	return len(mp.txs)
 }
 
-// GetAllTransactions returns all transactions in the mempool
+// QueuedSize returns the number of transactions waiting for a nonce gap.
+func (mp *Mempool) QueuedSize() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return len(mp.queued)
+}
+
+// GetAllTransactions returns all pending transactions followed by the
+// queued ones
 func (mp *Mempool) GetAllTransactions() []*Transaction {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
@@ -116,24 +387,36 @@ func (mp *Mempool) GetAllTransactions() []*Transaction {
 	for _, tx := range mp.txs {
 		txs = append(txs, tx)
 	}
+	for _, tx := range mp.queued {
+		txs = append(txs, tx)
+	}
 	return txs
 }
 
-// Cleanup removes invalid transactions from the mempool
+// Cleanup drops stale and unaffordable transactions and re-sorts every
+// sender's transactions into pending and queued.
 func (mp *Mempool) Cleanup() {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	var toRemove []string
-	for txHash, tx := range mp.txs {
-		if err := mp.state.ValidateTransaction(tx); err != nil {
-			log.Printf("[MEMPOOL] Removing invalid transaction %s: %v", txHash[:8], err)
-			toRemove = append(toRemove, txHash)
+	for from, list := range mp.accounts {
+		addr, _ := hex.DecodeString(from)
+		base := mp.state.GetNonce(addr)
+		mp.promoteLocked(from, base)
+		cost := new(big.Int)
+		for n := base; ; n++ {
+			tx, ok := list[n]
+			if !ok {
+				break
+			}
+			cost.Add(cost, tx.Cost())
+			if err := mp.state.validateSpend(tx, cost); err != nil {
+				log.Printf("[MEMPOOL] Removing invalid transaction %x: %v", tx.Hash[:4], err)
+				mp.dropLocked(tx)
+				break
+			}
 		}
-	}
-
-	for _, txHash := range toRemove {
-		delete(mp.txs, txHash)
+		mp.promoteLocked(from, base)
 	}
 }
 
@@ -161,8 +444,33 @@ func (mp *Mempool) GetStats() map[string]interface{} {
 		}
 	}
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"size":        len(mp.txs),
+		"queued":      len(mp.queued),
 		"total_value": totalValue.String(),
 	}
+	if len(mp.priced) == 0 {
+		return stats
+	}
+	// Queue order by gas price, as a miner would pick them
+	order := mp.priced.sorted()
+	stats["max_gas_price"] = gasPrice(order[0].tx).String()
+	stats["min_gas_price"] = gasPrice(order[len(order)-1].tx).String()
+	top := make([]map[string]interface{}, 0, statsQueueLen)
+	for _, e := range order {
+		if len(top) == statsQueueLen {
+			break
+		}
+		top = append(top, map[string]interface{}{
+			"hash":     hex.EncodeToString(e.tx.Hash),
+			"from":     hex.EncodeToString(e.tx.From),
+			"nonce":    e.tx.Nonce,
+			"gasPrice": gasPrice(e.tx).String(),
+		})
+	}
+	stats["queue"] = top
+	return stats
 }
+
+// statsQueueLen is how many queued transactions GetStats lists.
+const statsQueueLen = 20
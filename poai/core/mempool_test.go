@@ -0,0 +1,160 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"poai/core/config"
+	"poai/core/storage"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMempoolOrdersByGasPrice(t *testing.T) {
+	db, err := storage.OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewState(db)
+	mp := NewMempool(s)
+
+	to := make([]byte, 20)
+	prices := []int64{5, 50, 1, 20}
+	for _, price := range prices {
+		priv, _ := crypto.GenerateKey()
+		from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+		s.SetBalance(from, big.NewInt(10_000_000))
+		tx := NewTx(from, to, big.NewInt(1), 0)
+		tx.GasPrice = big.NewInt(price)
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		if err := mp.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := mp.GetTransactionsForBlock(3)
+	want := []int64{50, 20, 5}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(got), len(want))
+	}
+	for i, tx := range got {
+		if tx.GasPrice.Int64() != want[i] {
+			t.Errorf("tx %d gas price = %v, want %d", i, tx.GasPrice, want[i])
+		}
+	}
+
+	mp.RemoveTransactions(got[:1])
+	if best := mp.GetTransactionsForBlock(1); best[0].GasPrice.Int64() != 20 {
+		t.Errorf("after removal best gas price = %v, want 20", best[0].GasPrice)
+	}
+	if stats := mp.GetStats(); stats["max_gas_price"] != "20" || stats["min_gas_price"] != "1" {
+		t.Errorf("stats = %v", stats)
+	}
+}
+
+func TestMempoolQueuesFutureNonces(t *testing.T) {
+	db, err := storage.OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewState(db)
+	mp := NewMempool(s)
+
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	s.SetBalance(from, big.NewInt(10_000_000))
+	to := make([]byte, 20)
+	newTx := func(nonce uint64, price int64) *Transaction {
+		tx := NewTx(from, to, big.NewInt(1), nonce)
+		tx.GasPrice = big.NewInt(price)
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	if err := mp.AddTransaction(newTx(1, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if mp.Size() != 0 || mp.QueuedSize() != 1 {
+		t.Fatalf("after nonce 1: pending %d queued %d, want 0/1", mp.Size(), mp.QueuedSize())
+	}
+	if n := mp.PendingNonce(from); n != 0 {
+		t.Errorf("pending nonce = %d, want 0", n)
+	}
+
+	if err := mp.AddTransaction(newTx(0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if mp.Size() != 2 || mp.QueuedSize() != 0 {
+		t.Fatalf("after nonce 0: pending %d queued %d, want 2/0", mp.Size(), mp.QueuedSize())
+	}
+	if n := mp.PendingNonce(from); n != 2 {
+		t.Errorf("pending nonce = %d, want 2", n)
+	}
+	if txs := mp.GetTransactionsForBlock(10); len(txs) != 2 || txs[0].Nonce != 0 {
+		t.Errorf("block template out of nonce order: %v", txs)
+	}
+
+	if err := mp.AddTransaction(newTx(0, 10)); err == nil {
+		t.Error("duplicate accepted")
+	}
+	if err := mp.AddTransaction(newTx(1, 10)); err == nil {
+		t.Error("replacement without price bump accepted")
+	}
+	if err := mp.AddTransaction(newTx(1, 11)); err != nil {
+		t.Errorf("replacement with price bump rejected: %v", err)
+	}
+	if err := mp.AddTransaction(newTx(maxNonceGap, 10)); err == nil {
+		t.Error("nonce beyond the gap limit accepted")
+	}
+}
+
+func TestBlockGasLimit(t *testing.T) {
+	db, err := storage.OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewState(db)
+	mp := NewMempool(s)
+
+	// Three transactions of 40% of the block gas each: only two fit
+	gasLimit := uint64(config.MaxBlockGas * 2 / 5)
+	var all []*Transaction
+	for i := 0; i < 3; i++ {
+		priv, _ := crypto.GenerateKey()
+		from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+		s.SetBalance(from, big.NewInt(100_000_000))
+		tx := NewTx(from, make([]byte, 20), big.NewInt(1), 0)
+		tx.GasLimit = gasLimit
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		if err := mp.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, tx)
+	}
+	if got := mp.GetTransactionsForBlock(100); len(got) != 2 {
+		t.Fatalf("selected %d transactions, want 2 within the gas limit", len(got))
+	}
+	if err := CheckBlockLimits(&Block{Transactions: all[:2]}); err != nil {
+		t.Fatalf("block within limits: %v", err)
+	}
+	if err := CheckBlockLimits(&Block{Transactions: all}); err == nil {
+		t.Fatal("block over the gas limit accepted")
+	}
+
+	priv, _ := crypto.GenerateKey()
+	huge := NewTx(crypto.PubkeyToAddress(priv.PublicKey).Bytes(), make([]byte, 20), big.NewInt(1), 0)
+	huge.GasLimit = config.MaxBlockGas + 1
+	huge.Sign(priv)
+	if err := mp.AddTransaction(huge); err == nil {
+		t.Fatal("transaction over the block gas limit accepted into the mempool")
+	}
+}
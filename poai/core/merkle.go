@@ -0,0 +1,75 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// merkleParent hashes two child nodes.
+func merkleParent(left, right []byte) []byte {
+	return crypto.Keccak256(left, right)
+}
+
+// merkleRoot builds a binary keccak Merkle tree over leaves, duplicating the
+// last node of odd-length levels (Bitcoin style).
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return []byte{}
+	}
+	level := leaves
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, merkleParent(level[i], right))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// merkleProof returns the sibling path from leaf index up to the root.
+func merkleProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf index %d out of range", index)
+	}
+	var proof [][]byte
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index // odd level: node paired with itself
+		}
+		proof = append(proof, level[sibling])
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, merkleParent(level[i], right))
+		}
+		level = next
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that leaf sits at index under root.
+func VerifyMerkleProof(root, leaf []byte, index int, proof [][]byte) bool {
+	node := leaf
+	for _, sibling := range proof {
+		if index%2 == 0 {
+			node = merkleParent(node, sibling)
+		} else {
+			node = merkleParent(sibling, node)
+		}
+		index /= 2
+	}
+	return bytes.Equal(node, root)
+}
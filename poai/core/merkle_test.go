@@ -0,0 +1,30 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMerkleProofRoundTrip(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, crypto.Keccak256([]byte(fmt.Sprintf("tx-%d", i))))
+		}
+		root := merkleRoot(leaves)
+		for i := range leaves {
+			proof, err := merkleProof(leaves, i)
+			if err != nil {
+				t.Fatalf("n=%d i=%d: %v", n, i, err)
+			}
+			if !VerifyMerkleProof(root, leaves[i], i, proof) {
+				t.Fatalf("n=%d i=%d: proof did not verify", n, i)
+			}
+			if VerifyMerkleProof(root, crypto.Keccak256([]byte("other")), i, proof) {
+				t.Fatalf("n=%d i=%d: proof verified for wrong leaf", n, i)
+			}
+		}
+	}
+}
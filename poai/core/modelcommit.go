@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"poai/core/storage"
+)
+
+var modelKey = []byte("chain:modelsha256")
+
+// PutModelCommitment persists the model hash the chain is committed to.
+func (s *Store) PutModelCommitment(hash string) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		return txn.Set(modelKey, []byte(hash))
+	})
+}
+
+// GetModelCommitment returns the committed model hash, "" if none.
+func (s *Store) GetModelCommitment() (string, error) {
+	var hash string
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(modelKey)
+		hash = string(val)
+		return err
+	})
+	if err == storage.ErrNotFound {
+		return "", nil
+	}
+	return hash, err
+}
+
+// CommitModel reconciles the configured model hash with the one recorded
+// in the database and returns the hash the node must run. The first hash
+// configured for a chain is recorded; later starts may omit it but not
+// change it.
+func (c *Chain) CommitModel(configured string) (string, error) {
+	configured = strings.ToLower(strings.TrimPrefix(configured, "0x"))
+	if configured != "" {
+		if b, err := hex.DecodeString(configured); err != nil || len(b) != 32 {
+			return "", fmt.Errorf("model hash %q is not a hex SHA-256", configured)
+		}
+	}
+	stored, err := c.store.GetModelCommitment()
+	if err != nil {
+		return "", fmt.Errorf("load model commitment: %v", err)
+	}
+	switch {
+	case stored == "":
+		if configured != "" {
+			if err := c.store.PutModelCommitment(configured); err != nil {
+				return "", fmt.Errorf("store model commitment: %v", err)
+			}
+		}
+		return configured, nil
+	case configured == "" || configured == stored:
+		return stored, nil
+	}
+	return "", fmt.Errorf("chain is committed to model %s, configured %s", stored, configured)
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommitModel(t *testing.T) {
+	c := NewChain(t.TempDir(), 1000)
+	defer c.Close()
+	a, b := strings.Repeat("ab", 32), strings.Repeat("cd", 32)
+
+	if got, err := c.CommitModel(""); err != nil || got != "" {
+		t.Fatalf("uncommitted chain: got %q, %v", got, err)
+	}
+	if got, err := c.CommitModel("0x" + strings.ToUpper(a)); err != nil || got != a {
+		t.Fatalf("first commitment: got %q, %v", got, err)
+	}
+	if got, err := c.CommitModel(""); err != nil || got != a {
+		t.Fatalf("omitted hash should keep the commitment: got %q, %v", got, err)
+	}
+	if _, err := c.CommitModel(b); err == nil {
+		t.Fatal("changed model hash accepted")
+	}
+	if _, err := c.CommitModel("nothex"); err == nil {
+		t.Fatal("malformed model hash accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"poai/core/config"
+	"poai/dataset"
+)
+
+// Network presets selectable with --network
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkRegtest = "regtest"
+	NetworkDevnet  = "devnet"
+)
+
+// Network is a named network preset: the genesis that defines its chain
+// and consensus parameters (target, epoch length, retarget rule and block
+// spacing), its soft-fork deployments and the peers a new node joins it
+// through.
+type Network struct {
+	Name           string
+	BootstrapPeers []string // multiaddrs with /p2p/ peer IDs
+	Deployments    []Deployment
+
+	genesis func() *Genesis
+}
+
+// Genesis returns a fresh copy of the network's genesis.
+func (n *Network) Genesis() *Genesis {
+	return n.genesis()
+}
+
+// DataSubdir is the directory under --data-dir the network's chain is kept
+// in, so that switching networks never opens another network's database.
+// The development network uses --data-dir itself.
+func (n *Network) DataSubdir() string {
+	if n.Name == NetworkDevnet {
+		return ""
+	}
+	return n.Name
+}
+
+// networks are the presets. Their genesis files are in canonical form, as
+// Genesis.Validate leaves them. Seed nodes are listed in BootstrapPeers as
+// they are published; until then nodes join with --bootstrap-peers.
+var networks = []*Network{
+	{
+		Name: NetworkMainnet,
+		genesis: func() *Genesis {
+			return &Genesis{
+				ChainID:          1,
+				Timestamp:        1767225600, // 2026-01-01 00:00 UTC
+				Target:           dataset.DefaultTarget,
+				EpochBlocks:      20,
+				RetargetInterval: 2016,
+				Difficulty:       config.DifficultyASERT,
+			}
+		},
+	},
+	{
+		Name: NetworkTestnet,
+		genesis: func() *Genesis {
+			return &Genesis{
+				ChainID:          2,
+				Timestamp:        1760000000,
+				Target:           dataset.DefaultTarget,
+				EpochBlocks:      20,
+				RetargetInterval: 2016,
+				Difficulty:       config.DifficultyLWMA,
+				BlockSpacing:     120,
+				Alloc:            map[string]string{hex.EncodeToString(genesisTestAccount): "1000"},
+			}
+		},
+		Deployments: []Deployment{testDummy(2016, 1512)},
+	},
+	{
+		Name:        NetworkRegtest,
+		genesis:     RegtestGenesis,
+		Deployments: []Deployment{testDummy(144, 108)},
+	},
+	{
+		Name:        NetworkDevnet,
+		genesis:     func() *Genesis { return DefaultGenesis(dataset.DefaultTarget) },
+		Deployments: []Deployment{testDummy(144, 108)},
+	},
+}
+
+// NetworkNames lists the network presets.
+func NetworkNames() []string {
+	names := make([]string, len(networks))
+	for i, n := range networks {
+		names[i] = n.Name
+	}
+	return names
+}
+
+// LookupNetwork returns the network preset called name.
+func LookupNetwork(name string) (*Network, error) {
+	for _, n := range networks {
+		if strings.EqualFold(n.Name, name) {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown network %q (want %s)", name, strings.Join(NetworkNames(), ", "))
+}
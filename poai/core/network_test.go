@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+
+	"poai/core/config"
+	"poai/dataset"
+)
+
+func TestNetworkPresets(t *testing.T) {
+	defer (&Genesis{EpochBlocks: config.EpochBlocks, RetargetInterval: config.RetargetInterval}).Apply()
+
+	seen := make(map[[32]byte]string)
+	for _, name := range NetworkNames() {
+		n, err := LookupNetwork(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := n.Genesis()
+		hash := g.Hash()
+		if err := g.Validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if g.Hash() != hash {
+			t.Fatalf("%s genesis is not in canonical form", name)
+		}
+		if other, dup := seen[hash]; dup {
+			t.Fatalf("%s and %s share a genesis", name, other)
+		}
+		seen[hash] = name
+	}
+
+	// Development data directories keep opening with the devnet preset
+	devnet, _ := LookupNetwork("DEVNET")
+	if devnet.Genesis().Hash() != DefaultGenesis(dataset.DefaultTarget).Hash() || devnet.DataSubdir() != "" {
+		t.Fatal("devnet preset differs from the development chain")
+	}
+	if _, err := LookupNetwork("moonnet"); err == nil {
+		t.Fatal("unknown network accepted")
+	}
+
+	testnet, _ := LookupNetwork(NetworkTestnet)
+	testnet.Genesis().Apply()
+	if config.TargetBlockSpacingSec != 120 || config.DifficultyAlgorithm != config.DifficultyLWMA || config.ChainID != 2 {
+		t.Fatalf("testnet applied spacing %d, %s difficulty, chain ID %d",
+			config.TargetBlockSpacingSec, config.DifficultyAlgorithm, config.ChainID)
+	}
+	if testnet.DataSubdir() != NetworkTestnet {
+		t.Fatalf("testnet data in %q", testnet.DataSubdir())
+	}
+}
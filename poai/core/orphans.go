@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"poai/core/config"
+)
+
+// orphanEntry is what the orphan pool knows about a block besides the
+// block itself.
+type orphanEntry struct {
+	parent [32]byte
+	peer   string // who sent it; "" for local or unknown
+	added  time.Time
+	size   int
+}
+
+type peerKey struct{}
+
+// WithPeer returns a context recording that the blocks imported with it
+// came from peer, so that a full orphan pool evicts the orphans of the
+// peer holding the most.
+func WithPeer(ctx context.Context, peer string) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+func peerFrom(ctx context.Context) string {
+	peer, _ := ctx.Value(peerKey{}).(string)
+	return peer
+}
+
+// addOrphanLocked adds block to the orphan pool, dropping expired orphans
+// and evicting others to stay within config.MaxOrphans and
+// config.MaxOrphanBytes. It reports false if the block is pooled already
+// or too large to pool at all. The caller holds OrphanMu.
+func (c *Chain) addOrphanLocked(block *Block, peer string, now time.Time) bool {
+	hash := block.Hash()
+	if _, ok := c.orphans[hash]; ok {
+		return false
+	}
+	size := config.MaxBlockSize
+	if data, err := block.Encode(); err == nil {
+		size = len(data)
+	}
+	if config.MaxOrphans <= 0 || size > config.MaxOrphanBytes {
+		return false
+	}
+	c.expireOrphansLocked(now)
+	for len(c.orphans) >= config.MaxOrphans || c.orphanBytes+size > config.MaxOrphanBytes {
+		c.evictOrphanLocked()
+	}
+	parent := block.Header.ParentHash
+	c.OrphanPool[parent] = append(c.OrphanPool[parent], block)
+	c.orphans[hash] = orphanEntry{parent: parent, peer: peer, added: now, size: size}
+	c.orphanBytes += size
+	return true
+}
+
+// removeOrphanLocked drops the orphan with hash h from the pool.
+func (c *Chain) removeOrphanLocked(h [32]byte) {
+	e, ok := c.orphans[h]
+	if !ok {
+		return
+	}
+	delete(c.orphans, h)
+	c.orphanBytes -= e.size
+	siblings := c.OrphanPool[e.parent]
+	for i, blk := range siblings {
+		if blk.Hash() == h {
+			siblings = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(c.OrphanPool, e.parent)
+	} else {
+		c.OrphanPool[e.parent] = siblings
+	}
+}
+
+// takeOrphansLocked removes and returns the orphans waiting for parent.
+func (c *Chain) takeOrphansLocked(parent [32]byte) []*Block {
+	orphans := c.OrphanPool[parent]
+	for _, blk := range orphans {
+		h := blk.Hash()
+		c.orphanBytes -= c.orphans[h].size
+		delete(c.orphans, h)
+	}
+	delete(c.OrphanPool, parent)
+	return orphans
+}
+
+// expireOrphansLocked drops the orphans whose parent has not arrived
+// within config.OrphanExpiry.
+func (c *Chain) expireOrphansLocked(now time.Time) {
+	expired := 0
+	for h, e := range c.orphans {
+		if now.Sub(e.added) > config.OrphanExpiry {
+			c.removeOrphanLocked(h)
+			expired++
+		}
+	}
+	if expired > 0 {
+		log.Printf("🧩 Expired %d orphans older than %s", expired, config.OrphanExpiry)
+	}
+}
+
+// evictOrphanLocked makes room in a full orphan pool by dropping the
+// oldest orphan of the peer holding the most, so one peer flooding the
+// pool cannot push out the orphans of the others.
+func (c *Chain) evictOrphanLocked() {
+	held := make(map[string]int)
+	for _, e := range c.orphans {
+		held[e.peer]++
+	}
+	var victim [32]byte
+	var oldest orphanEntry
+	found := false
+	for h, e := range c.orphans {
+		if n, m := held[e.peer], held[oldest.peer]; !found || n > m || n == m && e.added.Before(oldest.added) {
+			victim, oldest, found = h, e, true
+		}
+	}
+	if !found {
+		return
+	}
+	c.removeOrphanLocked(victim)
+	log.Printf("🧩 Orphan pool full: evicted an orphan from peer %q (%d pooled from it)", oldest.peer, held[oldest.peer])
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"poai/core/config"
+)
+
+func TestOrphanPoolEvictsFromBusiestPeer(t *testing.T) {
+	defer func(n int) { config.MaxOrphans = n }(config.MaxOrphans)
+	config.MaxOrphans = 4
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	orphan := func(nonce uint64) *Block {
+		return NewBlock(10, [32]byte{byte(nonce)}, -1, big.NewInt(1000), nil, nonce)
+	}
+	now := time.Now()
+	honest := orphan(1)
+	c.addOrphanLocked(honest, "honest", now)
+	var last *Block
+	for i := uint64(2); i <= 6; i++ {
+		last = orphan(i)
+		if !c.addOrphanLocked(last, "flooder", now.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("orphan %d refused", i)
+		}
+	}
+	if len(c.orphans) != 4 || len(c.OrphanPool) != 4 {
+		t.Fatalf("%d orphans under %d parents, want 4", len(c.orphans), len(c.OrphanPool))
+	}
+	if _, ok := c.orphans[honest.Hash()]; !ok {
+		t.Fatal("honest peer's orphan evicted")
+	}
+	if c.addOrphanLocked(last, "flooder", now) {
+		t.Fatal("pooled orphan added twice")
+	}
+
+	// Past the expiry the next add clears out everything older
+	c.addOrphanLocked(orphan(7), "honest", now.Add(config.OrphanExpiry+time.Minute))
+	if len(c.orphans) != 1 || len(c.OrphanPool) != 1 {
+		t.Fatalf("%d orphans after expiry, want 1", len(c.orphans))
+	}
+	if got := c.takeOrphansLocked([32]byte{7}); len(got) != 1 || c.orphanBytes != 0 || len(c.orphans) != 0 {
+		t.Fatalf("take: %d blocks, %d bytes left", len(got), c.orphanBytes)
+	}
+}
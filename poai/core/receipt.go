@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"poai/core/storage"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Receipt statuses. A transaction that cannot execute makes its whole
+// block invalid, so every receipt in the chain is currently a success.
+const (
+	ReceiptFailed  uint8 = 0
+	ReceiptSuccess uint8 = 1
+)
+
+// Log topics emitted by bridge transactions.
+var (
+	TopicBridgeLock   = crypto.Keccak256([]byte("BridgeLock(address,bytes,uint256)"))
+	TopicBridgeUnlock = crypto.Keccak256([]byte("BridgeUnlock(address,bytes,uint256)"))
+)
+
+// Log is an event emitted while executing a transaction.
+type Log struct {
+	Address []byte   `json:"address"`
+	Topics  [][]byte `json:"topics"`
+	Data    []byte   `json:"data"`
+}
+
+// Receipt records the outcome of one transaction. Only the fields without
+// an rlp:"-" tag are committed to by the header's ReceiptsRoot; the rest
+// locate the transaction and are filled in when stored.
+type Receipt struct {
+	TxHash            []byte `json:"transactionHash"`
+	Status            uint8  `json:"status"`
+	GasUsed           uint64 `json:"gasUsed"`
+	CumulativeGasUsed uint64 `json:"cumulativeGasUsed"`
+	Logs              []*Log `json:"logs"`
+
+	BlockHash   []byte `json:"blockHash" rlp:"-"`
+	BlockNumber uint64 `json:"blockNumber" rlp:"-"`
+	TxIndex     uint64 `json:"transactionIndex" rlp:"-"`
+}
+
+// newReceipt builds the receipt for a successfully executed tx.
+// cumulative is the gas used by the block's earlier transactions.
+func newReceipt(tx *Transaction, cumulative uint64) *Receipt {
+	if len(tx.Hash) == 0 {
+		tx.Hash = tx.CalculateHash()
+	}
+	r := &Receipt{TxHash: tx.Hash, Status: ReceiptSuccess, Logs: []*Log{}}
+	if !tx.IsCoinbase() {
+		r.GasUsed = tx.GasLimit // the whole limit is charged
+	}
+	r.CumulativeGasUsed = cumulative + r.GasUsed
+	var amount [32]byte
+	if tx.Amount != nil {
+		tx.Amount.FillBytes(amount[:])
+	}
+	switch tx.Type {
+	case TxBridgeLock:
+		r.Logs = append(r.Logs, &Log{Address: BridgeEscrowAddress, Topics: [][]byte{TopicBridgeLock, tx.From, tx.Data}, Data: amount[:]})
+	case TxBridgeUnlock:
+		r.Logs = append(r.Logs, &Log{Address: BridgeEscrowAddress, Topics: [][]byte{TopicBridgeUnlock, tx.To, tx.Data}, Data: amount[:]})
+	}
+	return r
+}
+
+// Hash is the keccak256 of the receipt's consensus fields.
+func (r *Receipt) Hash() []byte {
+	data, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		return nil
+	}
+	return crypto.Keccak256(data)
+}
+
+// ReceiptsRoot is the Merkle root over the receipts' hashes, zero for a
+// block without transactions.
+func ReceiptsRoot(receipts []*Receipt) [32]byte {
+	leaves := make([][]byte, len(receipts))
+	for i, r := range receipts {
+		leaves[i] = r.Hash()
+	}
+	var root [32]byte
+	copy(root[:], merkleRoot(leaves))
+	return root
+}
+
+func receiptKey(txHash []byte) []byte {
+	return []byte("receipt:" + hex.EncodeToString(txHash))
+}
+
+// PutReceipts stores the receipts of block, keyed by transaction hash.
+func (s *Store) PutReceipts(block *Block, receipts []*Receipt) error {
+	hash := block.Hash()
+	return s.db.Update(func(txn storage.Txn) error {
+		for i, r := range receipts {
+			r.BlockHash = hash[:]
+			r.BlockNumber = block.Header.Height
+			r.TxIndex = uint64(i)
+			val, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(receiptKey(r.TxHash), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetReceipt loads the stored receipt for a transaction hash.
+func (s *Store) GetReceipt(txHash []byte) (*Receipt, error) {
+	var r Receipt
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(receiptKey(txHash))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(val, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// TransactionReceipt returns the receipt of a transaction on the canonical
+// chain. Receipts left behind by blocks that were reorganised away are
+// not returned.
+func (c *Chain) TransactionReceipt(txHash []byte) (*Receipt, error) {
+	r, err := c.store.GetReceipt(txHash)
+	if err != nil {
+		return nil, fmt.Errorf("no receipt for %x: %w", txHash, err)
+	}
+	blk := c.BlockByHeight(r.BlockNumber)
+	if blk == nil {
+		return nil, fmt.Errorf("block %d of receipt %x not found", r.BlockNumber, txHash)
+	}
+	if hash := blk.Hash(); !bytes.Equal(hash[:], r.BlockHash) {
+		return nil, fmt.Errorf("transaction %x is not in the canonical chain", txHash)
+	}
+	return r, nil
+}
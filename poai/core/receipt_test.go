@@ -0,0 +1,57 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBlockReceipts(t *testing.T) {
+	c := NewChain(t.TempDir(), 1000)
+	defer c.Close()
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	c.state.SetBalance(from, big.NewInt(1_000_000))
+
+	lock := NewBridgeLockTx(from, make([]byte, 20), big.NewInt(500), 0)
+	if err := lock.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	txs := []*Transaction{NewCoinbaseTx(make([]byte, 20), GetSubsidy(1)), lock}
+
+	// A wrong receipts root is rejected before anything is stored
+	parent := c.HeaderByHeight(0)
+	bad := NewBlock(1, parent.Hash(), -1, parent.Target(), txs, 0)
+	bad.Header.ReceiptsRoot = [32]byte{1}
+	if err := c.ImportTrustedBlock(bad); err == nil {
+		t.Fatal("block with a wrong receipts root imported")
+	}
+
+	b := NewBlock(1, parent.Hash(), -1, parent.Target(), txs, 1)
+	if b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(txs); b.Header.ReceiptsRoot == ([32]byte{}) {
+		t.Fatal("no receipts root computed")
+	}
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.TransactionReceipt(lock.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := b.Hash()
+	if r.Status != ReceiptSuccess || r.TxIndex != 1 || r.BlockNumber != 1 || !bytes.Equal(r.BlockHash, hash[:]) {
+		t.Fatalf("receipt location: %+v", r)
+	}
+	if r.GasUsed != lock.GasLimit || r.CumulativeGasUsed != lock.GasLimit {
+		t.Fatalf("gas used %d, cumulative %d", r.GasUsed, r.CumulativeGasUsed)
+	}
+	if len(r.Logs) != 1 || !bytes.Equal(r.Logs[0].Topics[0], TopicBridgeLock) {
+		t.Fatalf("lock log missing: %+v", r.Logs)
+	}
+	if _, err := c.TransactionReceipt([]byte{1, 2, 3}); err == nil {
+		t.Fatal("receipt for unknown transaction")
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"poai/core/config"
+)
+
+// ErrStateCorrupt is returned when opening a database whose account state
+// does not match its tip and cannot be repaired from undo records or a
+// snapshot.
+var ErrStateCorrupt = errors.New("account state does not match the chain tip and cannot be repaired; move the data directory aside and resync")
+
+// checkConsistency runs at startup, before the chain is used. It makes sure
+// the tip block exists and that blocks link back to the finalized
+// checkpoint, rewinding the chain to the last good block otherwise, and
+// that the account state matches the tip's StateRoot, repairing it from
+// undo records or the latest snapshot. The repair is written atomically.
+func (c *Chain) checkConsistency() (err error) {
+	c.store.BeginBatch()
+	defer func() {
+		if ferr := c.store.EndBatch(); ferr != nil && err == nil {
+			err = fmt.Errorf("write repairs: %w", ferr)
+		}
+	}()
+
+	tip, err := c.store.GetTipHeight()
+	if err != nil {
+		return fmt.Errorf("read tip: %w", err)
+	}
+	if c.blockAt(tip) == nil {
+		log.Printf("🩺 Tip block #%d is missing from the database", tip)
+	}
+
+	// Blocks below the finalized checkpoint, or the lowest one kept by
+	// pruning, are trusted
+	bottom := c.head
+	for bottom > 0 && c.blockAt(bottom-1) != nil {
+		bottom--
+	}
+	if c.finalized > bottom && c.finalized <= c.head && c.blockAt(c.finalized) != nil {
+		bottom = c.finalized
+	}
+	good := bottom
+	for h := bottom + 1; h <= c.head; h++ {
+		if c.blockAt(h).Header.ParentHash != c.blockAt(h-1).Hash() {
+			log.Printf("🩺 Block #%d does not link to block #%d", h, h-1)
+			break
+		}
+		good = h
+	}
+	if good != tip {
+		if err := c.rewindTo(good, tip); err != nil {
+			return err
+		}
+	}
+	return c.repairState()
+}
+
+// rewindTo makes height the tip, dropping the blocks above it up to tip.
+// Their state changes are left to repairState, which checks them against
+// the new tip's StateRoot before undoing them.
+func (c *Chain) rewindTo(height, tip uint64) error {
+	log.Printf("🩺 Rewinding the chain from #%d to #%d", tip, height)
+	for h := tip; h > height; h-- {
+		if blk := c.blockAt(h); blk != nil {
+			if err := c.store.UnindexBlockTxs(blk); err != nil {
+				return fmt.Errorf("unindex block #%d: %w", h, err)
+			}
+			c.blocks.remove(h)
+		}
+	}
+	if err := c.store.Rewind(height, tip); err != nil {
+		return fmt.Errorf("rewind to #%d: %w", height, err)
+	}
+	c.head = height
+	if c.finalized > height {
+		log.Printf("[WARN] Rewound below finalized checkpoint #%d", c.finalized)
+		c.finalized = height - height%max(config.FinalityInterval(), 1)
+		if err := c.store.PutFinalized(c.finalized); err != nil {
+			return fmt.Errorf("write finalized height: %w", err)
+		}
+	}
+	return nil
+}
+
+// repairState checks the account state against the tip's StateRoot. If it
+// differs, the state is assumed to run ahead of the tip, as after a crash
+// between applying blocks and moving the tip, and the undo records above
+// the tip are tried; failing that, the latest snapshot that matches its
+// block is restored and the blocks after it are replayed.
+func (c *Chain) repairState() error {
+	want := c.blockAt(c.head).Header.StateRoot
+	root, err := c.state.Root()
+	if err != nil {
+		return fmt.Errorf("compute state root: %w", err)
+	}
+	if root == want {
+		return nil
+	}
+	if want == ([32]byte{}) {
+		log.Printf("[WARN] Tip #%d predates state roots; cannot check the state against it", c.head)
+		return nil
+	}
+	log.Printf("🩺 State root %x does not match tip #%d (%x)", root[:8], c.head, want[:8])
+
+	pre, err := c.state.Snapshot(c.head)
+	if err != nil {
+		return fmt.Errorf("snapshot state: %w", err)
+	}
+	n, err := c.undoAbove(c.head)
+	if err != nil {
+		return err
+	}
+	if root, err = c.state.Root(); n > 0 && err == nil && root == want {
+		log.Printf("🩺 Undid the state changes of %d blocks above the tip", n)
+		return nil
+	}
+
+	if interval := config.CheckpointInterval; interval > 0 {
+		for cp := c.head - c.head%interval; ; cp -= interval {
+			if c.replayFromSnapshot(cp) {
+				log.Printf("🩺 Restored the state snapshot at #%d and replayed %d blocks", cp, c.head-cp)
+				return nil
+			}
+			if cp < interval {
+				break
+			}
+		}
+	}
+	// Leave the state as it was found
+	if err := c.state.RestoreSnapshot(pre); err != nil {
+		log.Printf("🩺 Failed to put the state back: %v", err)
+	}
+	return ErrStateCorrupt
+}
+
+// undoAbove applies the undo records stored for the heights above height,
+// newest first, and returns how many there were.
+func (c *Chain) undoAbove(height uint64) (int, error) {
+	top := height
+	for {
+		if _, err := c.store.GetUndo(top + 1); err != nil {
+			break
+		}
+		top++
+	}
+	for h := top; h > height; h-- {
+		undo, err := c.store.GetUndo(h)
+		if err != nil {
+			return 0, fmt.Errorf("read undo record #%d: %w", h, err)
+		}
+		if err := c.state.applyUndo(undo); err != nil {
+			return 0, fmt.Errorf("undo block #%d: %w", h, err)
+		}
+	}
+	return int(top - height), nil
+}
+
+// replayFromSnapshot restores the snapshot stored at height, if it matches
+// its block, and re-executes the blocks from there to the head. It reports
+// whether the state then matches the head.
+func (c *Chain) replayFromSnapshot(height uint64) bool {
+	blk := c.blockAt(height)
+	snap, err := c.store.GetSnapshot(height)
+	if blk == nil || err != nil || snap.Root() != blk.Header.StateRoot {
+		return false
+	}
+	for h := height + 1; h <= c.head; h++ {
+		if c.blockAt(h) == nil {
+			return false
+		}
+	}
+	if err := c.state.RestoreSnapshot(snap); err != nil {
+		log.Printf("🩺 Failed to restore the state snapshot at #%d: %v", height, err)
+		return false
+	}
+	for h := height + 1; h <= c.head; h++ {
+		if err := c.applyBlockState(c.blockAt(h)); err != nil {
+			log.Printf("🩺 Failed to replay block #%d on the snapshot at #%d: %v", h, height, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+// importCoinbaseBlocks extends the chain by n blocks paying miner.
+func importCoinbaseBlocks(t *testing.T, c *Chain, miner []byte, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		parent := c.HeaderByHeight(c.Height())
+		h := parent.Height + 1
+		txs := []*Transaction{NewCoinbaseTx(miner, BlockReward(h, nil))}
+		b := NewBlock(h, parent.Hash(), -1, parent.Target(), txs, h)
+		b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(txs)
+		if err := c.ImportTrustedBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tamper closes c and runs fn on its database.
+func tamper(t *testing.T, c *Chain, dir string, fn func(s *Store)) {
+	t.Helper()
+	c.Close()
+	s, err := OpenStore(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn(s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoveryUndoesStateAboveTip(t *testing.T) {
+	dir := t.TempDir()
+	c := NewChain(dir, 1000)
+	miner := bytes.Repeat([]byte{9}, 20)
+	importCoinbaseBlocks(t, c, miner, 3)
+	// As if the node crashed after applying block 3 but before moving the tip
+	tamper(t, c, dir, func(s *Store) {
+		if err := s.Rewind(2, 3); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	c, err := NewChainFromGenesis(dir, DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	want := new(big.Int).Add(BlockReward(1, nil), BlockReward(2, nil))
+	if c.Height() != 2 || c.GetBalance(miner).Cmp(want) != 0 {
+		t.Fatalf("height %d, miner balance %v, want 2 and %v", c.Height(), c.GetBalance(miner), want)
+	}
+}
+
+func TestRecoveryRewindsBrokenLinks(t *testing.T) {
+	dir := t.TempDir()
+	c := NewChain(dir, 1000)
+	miner := bytes.Repeat([]byte{9}, 20)
+	importCoinbaseBlocks(t, c, miner, 3)
+	bad := *c.BlockByHeight(2)
+	bad.Header.ParentHash = [32]byte{1}
+	tamper(t, c, dir, func(s *Store) {
+		s.PutBlock(2, &bad)
+		s.KV().Update(func(txn storage.Txn) error {
+			return txn.Set([]byte("chain:tip"), []byte(strconv.Itoa(3)))
+		})
+	})
+
+	c, err := NewChainFromGenesis(dir, DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Height() != 1 || c.GetBalance(miner).Cmp(BlockReward(1, nil)) != 0 {
+		t.Fatalf("height %d, miner balance %v", c.Height(), c.GetBalance(miner))
+	}
+	if tip, _ := c.store.GetTipHeight(); tip != 1 {
+		t.Fatalf("stored tip %d", tip)
+	}
+}
+
+func TestRecoveryRestoresSnapshot(t *testing.T) {
+	defer func(interval uint64) { config.CheckpointInterval = interval }(config.CheckpointInterval)
+	config.CheckpointInterval = 2
+
+	dir := t.TempDir()
+	c := NewChain(dir, 1000)
+	miner := bytes.Repeat([]byte{9}, 20)
+	importCoinbaseBlocks(t, c, miner, 3)
+	balance := c.GetBalance(miner)
+	corrupt := func(s *Store) {
+		NewState(s.KV()).SetBalance(miner, big.NewInt(1))
+	}
+	tamper(t, c, dir, corrupt)
+
+	c, err := NewChainFromGenesis(dir, DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Height() != 3 || c.GetBalance(miner).Cmp(balance) != 0 {
+		t.Fatalf("height %d, miner balance %v, want 3 and %v", c.Height(), c.GetBalance(miner), balance)
+	}
+
+	// Without a snapshot the state cannot be repaired
+	tamper(t, c, dir, func(s *Store) {
+		s.KV().Update(func(txn storage.Txn) error { return txn.Delete(snapshotKey(2)) })
+		corrupt(s)
+	})
+	if _, err := NewChainFromGenesis(dir, DefaultGenesis(1000)); !errors.Is(err, ErrStateCorrupt) {
+		t.Fatalf("opened a corrupt chain: %v", err)
+	}
+}
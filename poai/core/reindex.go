@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+// reindexFlushEvery is how many blocks ReindexFromDB indexes between
+// database writes.
+const reindexFlushEvery = 1024
+
+// ReindexFromDB rebuilds what the node derives from its stored blocks: the
+// block cache, the transaction and address indexes and, if the blocks
+// reach back to genesis, the supply counters. It reads every stored block,
+// so the node only runs it when started with --reindex; otherwise it
+// trusts the persisted indexes.
+func (c *Chain) ReindexFromDB() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tip, err := c.store.GetTipHeight()
+	if err == storage.ErrNotFound {
+		log.Printf("[REINDEX][WARN] No blocks found in DB (empty chain). Will start fresh.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.blocks = newBlockCache(config.BlockCacheSize)
+	c.head = tip
+	for c.head > 0 && c.blockAt(c.head) == nil {
+		c.head--
+	}
+	bottom := c.head
+	for bottom > 0 {
+		if _, err := c.store.GetCanonicalHash(bottom - 1); err != nil {
+			break // pruned below
+		}
+		bottom--
+	}
+	log.Printf("[REINDEX] Reindexing blocks #%d to #%d...", bottom, c.head)
+
+	if err := c.store.dropTxIndex(); err != nil {
+		return fmt.Errorf("drop transaction index: %w", err)
+	}
+	c.store.BeginBatch()
+	issued, burned := c.genesis.AllocTotal(), new(big.Int)
+	start, total, reported := time.Now(), c.head-bottom+1, uint64(0)
+	var parent *Block
+	for h := bottom; h <= c.head; h++ {
+		blk := c.blockAt(h)
+		if blk == nil {
+			c.store.EndBatch()
+			return fmt.Errorf("block #%d missing from the database", h)
+		}
+		if parent != nil && blk.Header.ParentHash != parent.Hash() {
+			c.store.EndBatch()
+			return fmt.Errorf("block #%d does not link to block #%d", h, h-1)
+		}
+		parent = blk
+		if err := c.store.IndexBlockTxs(blk); err != nil {
+			c.store.EndBatch()
+			return fmt.Errorf("index block #%d: %w", h, err)
+		}
+		if h > 0 {
+			e := blockEmission(blk)
+			issued.Add(issued, e.Subsidy)
+			burned.Add(burned, e.Burned)
+		}
+		done := h - bottom + 1
+		if done%reindexFlushEvery == 0 {
+			if err := c.store.FlushBatch(); err != nil {
+				c.store.EndBatch()
+				return fmt.Errorf("write indexes: %w", err)
+			}
+		}
+		if pct := done * 100 / total; pct >= reported+10 || done == total {
+			reported = pct - pct%10
+			log.Printf("[REINDEX] %d%% (block #%d of #%d, %s)", pct, h, c.head, time.Since(start).Round(time.Second))
+		}
+	}
+	if bottom == 0 {
+		if err := c.state.setSupply(issued, burned); err != nil {
+			c.store.EndBatch()
+			return fmt.Errorf("write supply counters: %w", err)
+		}
+	} else {
+		log.Printf("[REINDEX][WARN] Blocks below #%d are pruned; supply counters left as they are", bottom)
+	}
+	if err := c.store.EndBatch(); err != nil {
+		return fmt.Errorf("write indexes: %w", err)
+	}
+	log.Printf("[REINDEX] Done in %s. Head: %d, blocks cached: %d", time.Since(start).Round(time.Millisecond), c.head, c.blocks.len())
+	return nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestReindexRebuildsIndexesAndSupply(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	c, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tx := NewTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(500), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, c, bytes.Repeat([]byte{1}, 20), 1, tx)
+	mineTestBlock(t, c, bytes.Repeat([]byte{1}, 20), 2)
+	want, err := c.Supply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A database from before the indexes and supply counters
+	if err := c.store.dropTxIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.state.setSupply(new(big.Int), new(big.Int)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.TransactionByHash(tx.Hash); err == nil {
+		t.Fatal("transaction still indexed")
+	}
+
+	if err := c.ReindexFromDB(); err != nil {
+		t.Fatal(err)
+	}
+	if _, loc, err := c.TransactionByHash(tx.Hash); err != nil || loc.Height != 1 {
+		t.Fatalf("transaction after reindex: %v, %v", loc, err)
+	}
+	if locs, _ := c.AddressTransactions(from, 0, 10); len(locs) != 1 {
+		t.Fatalf("%d address transactions after reindex, want 1", len(locs))
+	}
+	got, err := c.Supply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Issued.Cmp(want.Issued) != 0 || got.Burned.Cmp(want.Burned) != 0 || c.CurrentHeight() != 2 {
+		t.Fatalf("supply after reindex %+v, want %+v", got, want)
+	}
+}
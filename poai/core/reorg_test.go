@@ -0,0 +1,495 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"poai/core/config"
+	"poai/core/header"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mineTestBlock imports an empty-work block on c's head paying miner and
+// returns it.
+func mineTestBlock(t *testing.T, c *Chain, miner []byte, nonce uint64, txs ...*Transaction) *Block {
+	t.Helper()
+	parent := c.HeaderByHeight(c.CurrentHeight())
+	height := parent.Height + 1
+	txs = append([]*Transaction{NewCoinbaseTx(miner, BlockReward(height, txs))}, txs...)
+	b := NewBlock(height, parent.Hash(), -1, parent.Target(), txs, nonce)
+	var err error
+	if b.Header.StateRoot, b.Header.ReceiptsRoot, err = c.ComputeRoots(txs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReorgReinjectsTransactions(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	tx := NewTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(500), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1, tx)
+	if a.Mempool.GetTransaction(tx.Hash) != nil {
+		t.Fatal("included transaction still pooled")
+	}
+
+	// A longer branch without tx replaces a's block
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+	}
+	// Block #2 of the branch would be orphaned on import (its parent is
+	// not canonical), so hand a the branch the way checkReorg sees it
+	a.mu.Lock()
+	for _, blk := range branch {
+		a.sideBranches[branch[0].Header.ParentHash] = append(a.sideBranches[branch[0].Header.ParentHash], blk)
+	}
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+	if a.CurrentHeight() != 2 || a.BlockByHeight(2).Hash() != branch[1].Hash() {
+		t.Fatalf("no reorg: head #%d", a.CurrentHeight())
+	}
+	if a.Mempool.GetTransaction(tx.Hash) == nil {
+		t.Fatal("transaction of the abandoned block not returned to the mempool")
+	}
+}
+
+func TestReorgRemovesBridgeLockEvents(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	lock := NewBridgeLockTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(500), 0)
+	if err := lock.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1, lock)
+	if _, err := a.store.GetBridgeLockEvent(lock.Hash); err != nil {
+		t.Fatalf("lock event not recorded: %v", err)
+	}
+
+	// A longer branch without the lock replaces its block
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+	}
+	a.mu.Lock()
+	a.sideBranches[branch[0].Header.ParentHash] = branch
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+	if a.CurrentHeight() != 2 || a.BlockByHeight(2).Hash() != branch[1].Hash() {
+		t.Fatalf("no reorg: head #%d", a.CurrentHeight())
+	}
+	if _, err := a.store.GetBridgeLockEvent(lock.Hash); err == nil {
+		t.Fatal("lock event of the abandoned block still served")
+	}
+	if _, err := a.BridgeDepositProof(lock.Hash); err == nil {
+		t.Fatal("deposit proof built for the abandoned lock")
+	}
+}
+
+func TestSideBranchSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	g := DefaultGenesis(1000)
+	a, err := NewChainFromGenesis(dir, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+	side := mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2)
+	if err := a.ImportTrustedBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	if a, err = NewChainFromGenesis(dir, g); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if branch := a.sideBranches[side.Header.ParentHash]; len(branch) != 1 || branch[0].Hash() != side.Hash() {
+		t.Fatalf("side branch after restart: %v", branch)
+	}
+	if a.getBlockByHash(side.Hash()) != nil {
+		t.Fatal("side block taken for a canonical one")
+	}
+
+	// The reloaded branch still wins a reorg once it is longer
+	next := mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3)
+	a.mu.Lock()
+	a.sideBranches[side.Header.ParentHash] = append(a.sideBranches[side.Header.ParentHash], next)
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+	if a.CurrentHeight() != 2 || a.BlockByHeight(1).Hash() != side.Hash() {
+		t.Fatalf("no reorg: head #%d", a.CurrentHeight())
+	}
+	if blocks, _ := a.store.SideBlocks(); len(blocks) != 0 {
+		t.Fatalf("%d side blocks still stored after the reorg", len(blocks))
+	}
+	if a.getBlockByHash(side.Hash()) == nil {
+		t.Fatal("adopted side block not canonical")
+	}
+}
+
+func TestReorgValidatesSideBranch(t *testing.T) {
+	g := DefaultGenesis(1000)
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+	}
+	fork := branch[0].Header.ParentHash
+	reorg := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.sideBranches[fork] = append([]*Block{}, branch...)
+		a.checkReorg(context.Background())
+	}
+	// Proof checks may still be running when a failed validation returns,
+	// so the verifier is switched through an atomic rather than replaced
+	const (
+		proofsOK = iota
+		proofsUnavailable
+		proofTipBad
+	)
+	var proofs atomic.Int32
+	a.VerifyProof = func(blk *Block) error {
+		switch proofs.Load() {
+		case proofsUnavailable:
+			return ErrProofUnavailable
+		case proofTipBad:
+			if blk.Hash() == branch[1].Hash() {
+				return errors.New("loss does not match")
+			}
+		}
+		return nil
+	}
+
+	// Work that cannot be checked yet leaves the branch for later
+	proofs.Store(proofsUnavailable)
+	reorg()
+	if a.CurrentHeight() != 1 || len(a.sideBranches[fork]) != 2 {
+		t.Fatalf("head #%d, %d branch blocks kept", a.CurrentHeight(), len(a.sideBranches[fork]))
+	}
+
+	// Bad work in the branch tip keeps the old chain and drops the branch
+	proofs.Store(proofTipBad)
+	reorg()
+	if a.CurrentHeight() != 1 || a.BlockByHeight(1).Hash() == branch[0].Hash() || len(a.sideBranches[fork]) != 0 {
+		t.Fatalf("reorged to an invalid branch: head #%d", a.CurrentHeight())
+	}
+
+	proofs.Store(proofsOK)
+	reorg()
+	if a.CurrentHeight() != 2 || a.BlockByHeight(2).Hash() != branch[1].Hash() {
+		t.Fatalf("no reorg to the valid branch: head #%d", a.CurrentHeight())
+	}
+}
+
+func TestReorgVerifiesBranchWithoutLock(t *testing.T) {
+	g := DefaultGenesis(1000)
+	chains := make([]*Chain, 3)
+	for i := range chains {
+		c, err := NewMemoryChain(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		chains[i] = c
+	}
+	a, main, b := chains[0], chains[1], chains[2]
+	if err := a.ImportTrustedBlock(mineTestBlock(t, main, bytes.Repeat([]byte{1}, 20), 1)); err != nil {
+		t.Fatal(err)
+	}
+	next := mineTestBlock(t, main, bytes.Repeat([]byte{1}, 20), 2)
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 4),
+	}
+	fork := branch[0].Header.ParentHash
+
+	// The head moves on while the branch is verified
+	var once sync.Once
+	imported := make(chan error, 1)
+	a.VerifyProof = func(*Block) error {
+		once.Do(func() {
+			go func() { imported <- a.ImportTrustedBlock(next) }()
+			select {
+			case err := <-imported:
+				imported <- err
+			case <-time.After(5 * time.Second):
+			}
+		})
+		return nil
+	}
+	a.mu.Lock()
+	a.sideBranches[fork] = append([]*Block{}, branch...)
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+
+	select {
+	case err := <-imported:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatal("import blocked while the branch was verified")
+	}
+	if a.CurrentHeight() != 2 || a.BlockByHeight(2).Hash() != next.Hash() {
+		t.Fatalf("reorged although the head moved: head #%d", a.CurrentHeight())
+	}
+	if len(a.sideBranches[fork]) != 2 {
+		t.Fatal("side branch dropped")
+	}
+}
+
+func TestFailedReorgRestoresLongerChain(t *testing.T) {
+	g := DefaultGenesis(1000)
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	old := mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+
+	// A branch longer than a's chain whose last block does not execute
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+	}
+	parent := branch[1].Header
+	txs := []*Transaction{NewCoinbaseTx(bytes.Repeat([]byte{2}, 20), BlockReward(3, nil))}
+	bad := NewBlock(3, parent.Hash(), -1, parent.Target(), txs, 4)
+	bad.Header.StateRoot = [32]byte{1}
+	branch = append(branch, bad)
+
+	a.mu.Lock()
+	a.reorgToBranch(context.Background(), branch[0].Header.ParentHash, branch)
+	a.mu.Unlock()
+
+	if tip, err := a.store.GetTipHeight(); err != nil || tip != 1 {
+		t.Fatalf("stored tip %d (%v), want 1", tip, err)
+	}
+	if a.CurrentHeight() != 1 || a.BlockByHeight(1).Hash() != old.Hash() {
+		t.Fatalf("head #%d after the failed reorg", a.CurrentHeight())
+	}
+	for h := uint64(2); h <= 3; h++ {
+		if hdr := a.HeaderByHeight(h); hdr != nil {
+			t.Fatalf("branch header #%d still canonical", h)
+		}
+	}
+	if root, err := a.state.Root(); err != nil || root != old.Header.StateRoot {
+		t.Fatalf("state root %x (%v), want %x", root[:8], err, old.Header.StateRoot[:8])
+	}
+}
+
+func TestReorgDepthLimit(t *testing.T) {
+	defer func(d uint64) { config.MaxReorgDepth = d }(config.MaxReorgDepth)
+	config.MaxReorgDepth = 1
+	g := DefaultGenesis(1000)
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 2)
+	var branch []*Block
+	for i := uint64(3); i <= 5; i++ {
+		branch = append(branch, mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), i))
+	}
+	fork := branch[0].Header.ParentHash
+
+	a.mu.Lock()
+	a.sideBranches[fork] = branch
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+	if a.CurrentHeight() != 2 || len(a.sideBranches[fork]) != 0 {
+		t.Fatalf("2-block reorg not refused: head #%d", a.CurrentHeight())
+	}
+	alert := a.ReorgAlert()
+	if alert == nil || alert.Depth != 2 || alert.ForkHeight != 0 || alert.BranchTip != 3 {
+		t.Fatalf("alert: %+v", alert)
+	}
+}
+
+func TestReorgChainEvents(t *testing.T) {
+	g := DefaultGenesis(1000)
+	a, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	sub := a.SubscribeChainEvents(16)
+	defer sub.Unsubscribe()
+
+	old := mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+	branch := []*Block{
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 2),
+		mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3),
+	}
+	a.mu.Lock()
+	a.sideBranches[branch[0].Header.ParentHash] = branch
+	a.checkReorg(context.Background())
+	a.mu.Unlock()
+
+	want := []struct {
+		typ ChainEventType
+		blk *Block
+	}{
+		{EventBlockAdded, old},
+		{EventReorgStarted, nil},
+		{EventBlockRemoved, old},
+		{EventBlockAdded, branch[0]},
+		{EventBlockAdded, branch[1]},
+		{EventReorgFinished, nil},
+	}
+	for i, w := range want {
+		ev := <-sub.C
+		if ev.Type != w.typ || w.blk != nil && ev.Block.Hash() != w.blk.Hash() {
+			t.Fatalf("event %d: %s #%v, want %s", i, ev.Type, ev.Block, w.typ)
+		}
+		if ev.Type == EventReorgFinished && (ev.ForkHeight != 0 || ev.OldTip.Hash() != old.Hash() || ev.NewTip.Hash() != branch[1].Hash()) {
+			t.Fatalf("reorgFinished: fork #%d", ev.ForkHeight)
+		}
+	}
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("unexpected %s event", ev.Type)
+	default:
+	}
+}
+
+func TestSideBranchesStoreOnlyLinkedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	g := DefaultGenesis(1000)
+	a, err := NewChainFromGenesis(dir, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 1)
+	mineTestBlock(t, a, bytes.Repeat([]byte{1}, 20), 2)
+
+	// A block with an unknown parent is pooled, not stored
+	stray := NewBlock(2, [32]byte{9}, -1, big.NewInt(1000), nil, 1)
+	if err := a.ImportTrustedBlock(stray); err == nil {
+		t.Fatal("block with an unknown parent accepted")
+	}
+	// and one that breaks the difficulty rule is refused
+	side := mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 3)
+	bad := *side
+	bad.Header.Bits = header.BigToCompact(big.NewInt(5000))
+	if err := a.ImportTrustedBlock(&bad); !errors.Is(err, ErrInvalidBlock) {
+		t.Fatalf("side block with a wrong target: %v", err)
+	}
+	if blocks, _ := a.store.SideBlocks(); len(blocks) != 0 {
+		t.Fatalf("%d side blocks stored", len(blocks))
+	}
+
+	// A branch grows block by block and takes over once it is longer
+	if err := a.ImportTrustedBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	next := mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 4)
+	if err := a.ImportTrustedBlock(next); err != nil {
+		t.Fatal(err)
+	}
+	if blocks, _ := a.store.SideBlocks(); len(blocks) != 2 {
+		t.Fatalf("%d side blocks stored, want 2", len(blocks))
+	}
+	if err := a.ImportTrustedBlock(mineTestBlock(t, b, bytes.Repeat([]byte{2}, 20), 5)); err != nil {
+		t.Fatal(err)
+	}
+	if a.CurrentHeight() != 3 || a.BlockByHeight(1).Hash() != side.Hash() {
+		t.Fatalf("no reorg: head #%d", a.CurrentHeight())
+	}
+
+	// A stored block that no longer attaches is pruned on load
+	if err := a.store.PutSideBlock(stray); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	if a, err = NewChainFromGenesis(dir, g); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if n := a.sideBlockCount(); n != 0 {
+		t.Fatalf("%d side blocks loaded", n)
+	}
+	if blocks, _ := a.store.SideBlocks(); len(blocks) != 0 {
+		t.Fatalf("%d side blocks still stored", len(blocks))
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"encoding/hex"
+	"log"
+	"time"
+
+	"poai/core/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	reorgsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poai_reorgs_total",
+		Help: "Reorgs to a longer side branch.",
+	})
+	reorgsRefused = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "poai_reorgs_refused_total",
+		Help: "Reorgs refused for exceeding --max-reorg-depth; any increase needs an operator's attention.",
+	})
+	reorgRefusedDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "poai_reorg_refused_depth",
+		Help: "Depth of the last refused reorg, 0 if none.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(reorgsTotal, reorgsRefused, reorgRefusedDepth)
+}
+
+// ReorgAlert describes the last reorg refused for exceeding
+// config.MaxReorgDepth. A branch that long rewriting history points to an
+// attack or a network split, which an operator should look into.
+type ReorgAlert struct {
+	Time       time.Time `json:"time"`
+	ForkHeight uint64    `json:"forkHeight"`
+	Depth      uint64    `json:"depth"` // canonical blocks the branch would replace
+	Head       uint64    `json:"head"`
+	BranchTip  uint64    `json:"branchTip"`
+	BranchHash string    `json:"branchHash"`
+}
+
+// checkReorgDepth refuses a reorg from the head back to forkHeight deeper
+// than config.MaxReorgDepth, recording and loudly logging an alert.
+// Caller holds c.mu.
+func (c *Chain) checkReorgDepth(forkHeight uint64, tip *Block) bool {
+	depth := c.head - forkHeight
+	if config.MaxReorgDepth == 0 || depth <= config.MaxReorgDepth {
+		return true
+	}
+	hash := tip.Hash()
+	c.reorgAlert = &ReorgAlert{
+		Time:       time.Now(),
+		ForkHeight: forkHeight,
+		Depth:      depth,
+		Head:       c.head,
+		BranchTip:  tip.Header.Height,
+		BranchHash: hex.EncodeToString(hash[:]),
+	}
+	reorgsRefused.Inc()
+	reorgRefusedDepth.Set(float64(depth))
+	log.Printf("🚨🚨 REORG REFUSED: a branch forking at #%d (tip #%d %x) would replace %d blocks, more than --max-reorg-depth=%d. "+
+		"This may be an attack or a network split; check peers and block explorers before acting.",
+		forkHeight, tip.Header.Height, hash[:8], depth, config.MaxReorgDepth)
+	return false
+}
+
+// ReorgAlert returns the last reorg refused for its depth, or nil if none
+// was since the node started.
+func (c *Chain) ReorgAlert() *ReorgAlert {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.reorgAlert == nil {
+		return nil
+	}
+	alert := *c.reorgAlert
+	return &alert
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"poai/core/storage"
+)
+
+// Holder is an account with a non-zero balance.
+type Holder struct {
+	Address []byte   `json:"address"`
+	Balance *big.Int `json:"balance"`
+}
+
+// RichList is a page of the accounts ordered by balance, largest first
+// (ties by address).
+type RichList struct {
+	Height      uint64   `json:"height"`
+	Accounts    int      `json:"accounts"` // with a non-zero balance
+	TotalSupply *big.Int `json:"totalSupply"`
+	Offset      int      `json:"offset"`
+	Holders     []Holder `json:"holders"`
+}
+
+// holders returns every account with a non-zero balance, in rich-list
+// order.
+func (s *State) holders() ([]Holder, error) {
+	var holders []Holder
+	prefix := []byte("balance:")
+	err := s.db.View(func(txn storage.Txn) error {
+		return txn.Iterate(prefix, false, func(key, val []byte) bool {
+			bal := new(big.Int).SetBytes(val)
+			if bal.Sign() > 0 {
+				holders = append(holders, Holder{Address: append([]byte{}, key[len(prefix):]...), Balance: bal})
+			}
+			return true
+		})
+	})
+	sort.Slice(holders, func(i, j int) bool {
+		if c := holders[i].Balance.Cmp(holders[j].Balance); c != 0 {
+			return c > 0
+		}
+		return bytes.Compare(holders[i].Address, holders[j].Address) < 0
+	})
+	return holders, err
+}
+
+// RichList returns up to limit holders at the head, skipping the offset
+// largest. It reads every balance, so explorers should cache the result.
+func (c *Chain) RichList(offset, limit int) (*RichList, error) {
+	c.mu.RLock()
+	holders, err := c.state.holders()
+	head := c.head
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	list := &RichList{Height: head, Accounts: len(holders), TotalSupply: new(big.Int), Offset: offset, Holders: []Holder{}}
+	for _, h := range holders {
+		list.TotalSupply.Add(list.TotalSupply, h.Balance)
+	}
+	if offset < len(holders) {
+		list.Holders = holders[offset:min(offset+limit, len(holders))]
+	}
+	return list, nil
+}
@@ -0,0 +1,35 @@
+package core
+
+import "testing"
+
+func TestRichListOrderAndPaging(t *testing.T) {
+	g := DefaultGenesis(1000)
+	g.Alloc = map[string]string{
+		"01": "300",
+		"02": "500",
+		"03": "300",
+		"04": "0",
+	}
+	c, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	list, err := c.RichList(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Accounts != 3 || list.TotalSupply.Int64() != 1100 || len(list.Holders) != 2 {
+		t.Fatalf("first page: %+v", list)
+	}
+	if list.Holders[0].Address[0] != 2 || list.Holders[1].Address[0] != 1 {
+		t.Fatalf("order: %x, %x", list.Holders[0].Address, list.Holders[1].Address)
+	}
+	if list, _ = c.RichList(2, 2); len(list.Holders) != 1 || list.Holders[0].Address[0] != 3 {
+		t.Fatalf("second page: %+v", list.Holders)
+	}
+	if list, _ = c.RichList(5, 2); len(list.Holders) != 0 {
+		t.Fatalf("past the end: %+v", list.Holders)
+	}
+}
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"poai/core/config"
+	"poai/core/header"
+	"poai/core/storage"
+)
+
+// Side branches are bounded so that peers cannot fill the disk with blocks
+// that never become canonical: at most maxSideBlocks are kept, and a branch
+// forking more than sideDepth blocks below the head is dropped.
+const (
+	maxSideBlocks = 1024
+	maxSideDepth  = 1000
+)
+
+// sideDepth is how far below the head a side branch may fork: maxSideDepth,
+// or --max-reorg-depth if that is deeper, so that branches deep enough to
+// raise a reorg alert are still seen.
+func sideDepth() uint64 {
+	return max(maxSideDepth, config.MaxReorgDepth)
+}
+
+// errSideParentUnknown is returned by attachSideBlock for a block whose
+// parent is neither canonical nor a side branch tip.
+var errSideParentUnknown = errors.New("parent is not canonical or a side branch tip")
+
+// sidePrefix marks stored blocks that sit on a side branch; the value is
+// the block height, the block itself is stored under its hash key.
+var sidePrefix = []byte("side:")
+
+func sideKey(h [32]byte) []byte {
+	return append(append([]byte{}, sidePrefix...), h[:]...)
+}
+
+// PutSideBlock stores a block by hash without making it canonical, and
+// marks it as a side-branch block so it survives a restart.
+func (s *Store) PutSideBlock(block *Block) error {
+	val, err := block.Encode()
+	if err != nil {
+		return err
+	}
+	h := block.Hash()
+	return s.db.Update(func(txn storage.Txn) error {
+		if err := txn.Set(hashKey(h), val); err != nil {
+			return err
+		}
+		return txn.Set(sideKey(h), binary.BigEndian.AppendUint64(nil, block.Header.Height))
+	})
+}
+
+// DeleteSideBlock forgets a side-branch block. Its data is deleted too
+// unless a reorg made it canonical.
+func (s *Store) DeleteSideBlock(h [32]byte) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		if _, err := txn.Get(sideKey(h)); err == storage.ErrNotFound {
+			return nil // canonical now, or never stored
+		} else if err != nil {
+			return err
+		}
+		if err := txn.Delete(hashKey(h)); err != nil {
+			return err
+		}
+		return txn.Delete(sideKey(h))
+	})
+}
+
+// SideBlocks returns the stored side-branch blocks, lowest first.
+func (s *Store) SideBlocks() ([]*Block, error) {
+	var hashes [][32]byte
+	var heights []uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		return txn.Iterate(sidePrefix, false, func(key, val []byte) bool {
+			var h [32]byte
+			copy(h[:], key[len(sidePrefix):])
+			hashes = append(hashes, h)
+			heights = append(heights, binary.BigEndian.Uint64(val))
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*Block, 0, len(hashes))
+	for i, h := range hashes {
+		blk, err := s.GetBlockByHash(h)
+		if err != nil {
+			return nil, fmt.Errorf("side block %x at #%d: %w", h[:8], heights[i], err)
+		}
+		blocks = append(blocks, blk)
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Header.Height < blocks[j].Header.Height })
+	return blocks, nil
+}
+
+// loadSideBranches rebuilds the side branches from the stored side-branch
+// blocks, so a competing branch is not lost across a restart. Blocks
+// attachSideBlock no longer accepts, e.g. because the head moved too far
+// past their fork, are deleted.
+func (c *Chain) loadSideBranches() {
+	blocks, err := c.store.SideBlocks()
+	if err != nil {
+		log.Printf("[WARN] Failed to load side branches: %v", err)
+		return
+	}
+	loaded := 0
+	for _, blk := range blocks {
+		if err := c.attachSideBlock(blk); err != nil {
+			if err := c.store.DeleteSideBlock(blk.Hash()); err != nil {
+				log.Printf("[WARN] Failed to delete side block #%d: %v", blk.Header.Height, err)
+			}
+			continue
+		}
+		loaded++
+	}
+	if len(blocks) > 0 {
+		log.Printf("🌿 Loaded %d side-branch blocks from the database, pruned %d", loaded, len(blocks)-loaded)
+	}
+}
+
+// attachSideBlock adds block to the side branch whose tip is its parent,
+// or starts a branch at a canonical parent that has none. The block must
+// link to its parent and carry the target and timestamp the branch up to
+// it prescribes; its PoAI work is checked by validateBranch before a reorg.
+// Caller holds c.mu.
+func (c *Chain) attachSideBlock(block *Block) error {
+	hash, parentHash := block.Hash(), block.Header.ParentHash
+	key := parentHash
+	var branch []*Block
+	fork := c.getBlockByHash(parentHash)
+	if fork == nil {
+		for k, b := range c.sideBranches {
+			if len(b) > 0 && b[len(b)-1].Hash() == parentHash {
+				key, branch = k, b
+				fork = c.getBlockByHash(k)
+				break
+			}
+		}
+		if fork == nil {
+			return errSideParentUnknown
+		}
+	} else if len(c.sideBranches[key]) > 0 {
+		return fmt.Errorf("a side branch already grows from #%d", fork.Header.Height)
+	}
+	for _, b := range branch {
+		if b.Hash() == hash {
+			return fmt.Errorf("block already on a side branch")
+		}
+	}
+	if forkHeight := fork.Header.Height; forkHeight < c.finalized || forkHeight+sideDepth() < c.head {
+		return fmt.Errorf("forks at #%d, too far below the head #%d", forkHeight, c.head)
+	}
+
+	r := branchReader{c: c, fork: fork.Header.Height, branch: branch}
+	parent := &fork.Header
+	if len(branch) > 0 {
+		parent = &branch[len(branch)-1].Header
+	}
+	if err := VerifyHeaderLink(&block.Header, parent); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlock, err)
+	}
+	if err := VerifyHeaderTarget(r, &block.Header, parent); err != nil {
+		return err
+	}
+	if err := checkTimestamp(r, &block.Header); err != nil {
+		return err
+	}
+	if c.sideBlockCount() >= maxSideBlocks && !c.evictSideBranch(key, block.Header.Height) {
+		return fmt.Errorf("side branches full")
+	}
+	c.sideBranches[key] = append(branch, block)
+	return nil
+}
+
+// sideBlockCount returns how many blocks the side branches hold.
+func (c *Chain) sideBlockCount() int {
+	n := 0
+	for _, branch := range c.sideBranches {
+		n += len(branch)
+	}
+	return n
+}
+
+// evictSideBranch drops the side branch with the lowest tip, other than
+// the one growing from keep, if that tip is below height. It reports
+// whether a branch was dropped.
+func (c *Chain) evictSideBranch(keep [32]byte, height uint64) bool {
+	var victim [32]byte
+	lowest := height
+	for k, branch := range c.sideBranches {
+		if k == keep || len(branch) == 0 {
+			continue
+		}
+		if tip := branch[len(branch)-1].Header.Height; tip < lowest {
+			victim, lowest = k, tip
+		}
+	}
+	if lowest == height {
+		return false
+	}
+	c.dropSideBranch(victim)
+	return true
+}
+
+// dropSideBranch forgets the side branch growing from parentHash; blocks
+// a reorg made canonical stay stored.
+func (c *Chain) dropSideBranch(parentHash [32]byte) {
+	for _, blk := range c.sideBranches[parentHash] {
+		if err := c.store.DeleteSideBlock(blk.Hash()); err != nil {
+			log.Printf("[WARN] Failed to delete side block #%d: %v", blk.Header.Height, err)
+		}
+	}
+	delete(c.sideBranches, parentHash)
+}
+
+// branchReader is a ChainReader over the canonical chain up to fork and a
+// side branch growing from it. Caller holds c.mu.
+type branchReader struct {
+	c      *Chain
+	fork   uint64
+	branch []*Block
+}
+
+func (r branchReader) HeaderByHeight(height uint64) *header.Header {
+	if height <= r.fork {
+		return lockedReader{r.c}.HeaderByHeight(height)
+	}
+	if i := height - r.fork - 1; i < uint64(len(r.branch)) {
+		return &r.branch[i].Header
+	}
+	return nil
+}
+
+func (r branchReader) Height() uint64 { return r.fork + uint64(len(r.branch)) }
+
+// validateBranch checks a side branch the way its blocks would have been
+// checked extending the canonical chain: it must grow from a canonical
+// block, each block must link to the one before and carry the target and
+// a timestamp the chain up to it prescribes, and the blocks must pass the
+// limits, signature and PoAI checks. Their transactions are executed by
+// the reorg itself, which restores the old chain if one fails. Caller
+// holds c.mu; it is released while the proofs are checked, so the caller
+// must see whether the chain moved meanwhile (see sideBranchMoved).
+func (c *Chain) validateBranch(parentHash [32]byte, branch []*Block) error {
+	fork := c.getBlockByHash(parentHash)
+	if fork == nil {
+		return fmt.Errorf("fork point %x is not canonical", parentHash[:8])
+	}
+	r := branchReader{c: c, fork: fork.Header.Height, branch: branch}
+	parent := &fork.Header
+	for _, blk := range branch {
+		if err := VerifyHeaderLink(&blk.Header, parent); err != nil {
+			return fmt.Errorf("%w: block #%d: %v", ErrInvalidBlock, blk.Header.Height, err)
+		}
+		want, err := NextTarget(r, parent)
+		if err != nil {
+			return fmt.Errorf("block #%d: difficulty adjustment failed: %w", blk.Header.Height, err)
+		}
+		if blk.Header.Bits != header.BigToCompact(want) {
+			return fmt.Errorf("%w: block #%d: target %v, expected %v", ErrInvalidBlock, blk.Header.Height, blk.Header.Target(), want)
+		}
+		if err := checkTimestamp(r, &blk.Header); err != nil {
+			return fmt.Errorf("block #%d: %w", blk.Header.Height, err)
+		}
+		parent = &blk.Header
+	}
+
+	// Replaying the proofs of a long branch takes a while; imports and
+	// reads go on meanwhile
+	c.mu.Unlock()
+	defer c.mu.Lock()
+
+	// Every result is awaited, even after a failure, so that no check is
+	// still reading the blocks when a later reorg executes them
+	stop := make(chan struct{})
+	defer close(stop)
+	var first error
+	for i, res := range c.verifyPipeline(branch, stop) {
+		if err := <-res; err != nil && first == nil {
+			first = fmt.Errorf("block #%d: %w", branch[i].Header.Height, err)
+		}
+	}
+	return first
+}
+
+// sideBranchMoved reports whether the head is no longer the block with
+// hash head or the side branch from parentHash is no longer branch, as
+// when blocks were imported while validateBranch had c.mu released.
+// Caller holds c.mu.
+func (c *Chain) sideBranchMoved(parentHash [32]byte, branch []*Block, head [32]byte) bool {
+	if c.closed || c.blockAt(c.head).Hash() != head {
+		return true
+	}
+	cur := c.sideBranches[parentHash]
+	return len(cur) != len(branch) || cur[len(cur)-1] != branch[len(branch)-1]
+}
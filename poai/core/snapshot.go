@@ -0,0 +1,260 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"poai/core/config"
+	"poai/core/storage"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AccountState is one account entry of a state snapshot.
+type AccountState struct {
+	Address []byte   `json:"address"`
+	Balance *big.Int `json:"balance"`
+	Nonce   uint64   `json:"nonce"`
+}
+
+// StateSnapshot is the full account state at a given height.
+type StateSnapshot struct {
+	Height   uint64         `json:"height"`
+	Accounts []AccountState `json:"accounts"` // sorted by address
+}
+
+// leaf returns the Merkle leaf committing to one account.
+func (a *AccountState) leaf() []byte {
+	var buf bytes.Buffer
+	bal := a.Balance.Bytes()
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(a.Address)))
+	buf.Write(n[:])
+	buf.Write(a.Address)
+	binary.BigEndian.PutUint64(n[:], uint64(len(bal)))
+	buf.Write(n[:])
+	buf.Write(bal)
+	binary.BigEndian.PutUint64(n[:], a.Nonce)
+	buf.Write(n[:])
+	return crypto.Keccak256(buf.Bytes())
+}
+
+func (s *StateSnapshot) leaves() [][]byte {
+	leaves := make([][]byte, len(s.Accounts))
+	for i := range s.Accounts {
+		leaves[i] = s.Accounts[i].leaf()
+	}
+	return leaves
+}
+
+// Root returns the Merkle root over the snapshot's accounts, in address
+// order. An empty state has the zero root.
+func (s *StateSnapshot) Root() [32]byte {
+	var root [32]byte
+	copy(root[:], merkleRoot(s.leaves()))
+	return root
+}
+
+// AccountProof shows that an account is part of a state root.
+type AccountProof struct {
+	Account AccountState `json:"account"`
+	Index   int          `json:"index"`
+	Proof   [][]byte     `json:"proof"`
+}
+
+// AccountProof returns a Merkle proof for addr, or an error if the account
+// does not exist in the snapshot.
+func (s *StateSnapshot) AccountProof(addr []byte) (*AccountProof, error) {
+	i := sort.Search(len(s.Accounts), func(i int) bool {
+		return bytes.Compare(s.Accounts[i].Address, addr) >= 0
+	})
+	if i == len(s.Accounts) || !bytes.Equal(s.Accounts[i].Address, addr) {
+		return nil, fmt.Errorf("account %x not in state", addr)
+	}
+	proof, err := merkleProof(s.leaves(), i)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountProof{Account: s.Accounts[i], Index: i, Proof: proof}, nil
+}
+
+// VerifyAccountProof checks an account proof against a header's StateRoot.
+func VerifyAccountProof(root [32]byte, p *AccountProof) bool {
+	if p == nil || p.Account.Balance == nil {
+		return false
+	}
+	return VerifyMerkleProof(root[:], p.Account.leaf(), p.Index, p.Proof)
+}
+
+// Snapshot collects every account's balance and nonce.
+func (s *State) Snapshot(height uint64) (*StateSnapshot, error) {
+	accounts := make(map[string]*AccountState)
+	get := func(addr []byte) *AccountState {
+		a, ok := accounts[string(addr)]
+		if !ok {
+			a = &AccountState{Address: append([]byte{}, addr...), Balance: big.NewInt(0)}
+			accounts[string(addr)] = a
+		}
+		return a
+	}
+	err := s.db.View(func(txn storage.Txn) error {
+		for _, prefix := range [][]byte{[]byte("balance:"), []byte("nonce:")} {
+			err := txn.Iterate(prefix, false, func(key, val []byte) bool {
+				addr := key[len(prefix):]
+				if bytes.Equal(prefix, []byte("balance:")) {
+					get(addr).Balance = new(big.Int).SetBytes(val)
+				} else {
+					get(addr).Nonce = decodeNonce(val)
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	snap := &StateSnapshot{Height: height}
+	for _, a := range accounts {
+		snap.Accounts = append(snap.Accounts, *a)
+	}
+	sort.Slice(snap.Accounts, func(i, j int) bool {
+		return bytes.Compare(snap.Accounts[i].Address, snap.Accounts[j].Address) < 0
+	})
+	return snap, nil
+}
+
+// Root returns the commitment to the current account state.
+func (s *State) Root() ([32]byte, error) {
+	snap, err := s.Snapshot(0)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return snap.Root(), nil
+}
+
+// decodeNonce reads the little-endian nonce encoding used by SetNonce.
+func decodeNonce(val []byte) uint64 {
+	var nonce uint64
+	for i, b := range val {
+		if i >= 8 {
+			break
+		}
+		nonce |= uint64(b) << (i * 8)
+	}
+	return nonce
+}
+
+// RestoreSnapshot replaces all account state with the snapshot contents.
+func (s *State) RestoreSnapshot(snap *StateSnapshot) error {
+	var stale [][]byte
+	err := s.db.View(func(txn storage.Txn) error {
+		for _, prefix := range [][]byte{[]byte("balance:"), []byte("nonce:")} {
+			err := txn.Iterate(prefix, false, func(key, _ []byte) bool {
+				stale = append(stale, append([]byte{}, key...))
+				return true
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	for _, k := range stale {
+		if err := wb.Delete(k); err != nil {
+			return err
+		}
+	}
+	for _, a := range snap.Accounts {
+		if err := wb.Set(append([]byte("balance:"), a.Address...), a.Balance.Bytes()); err != nil {
+			return err
+		}
+		val := make([]byte, 8)
+		for i := 0; i < 8; i++ {
+			val[i] = byte(a.Nonce >> (i * 8))
+		}
+		if err := wb.Set(append([]byte("nonce:"), a.Address...), val); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func snapshotKey(height uint64) []byte {
+	return []byte("snapshot:" + strconv.FormatUint(height, 10))
+}
+
+// PutSnapshot persists a state snapshot and drops the one before it.
+func (s *Store) PutSnapshot(snap *StateSnapshot, previous uint64) error {
+	val, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn storage.Txn) error {
+		if previous != snap.Height {
+			if err := txn.Delete(snapshotKey(previous)); err != nil {
+				return err
+			}
+		}
+		return txn.Set(snapshotKey(snap.Height), val)
+	})
+}
+
+// GetSnapshot loads the snapshot stored for height.
+func (s *Store) GetSnapshot(height uint64) (*StateSnapshot, error) {
+	var snap StateSnapshot
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(snapshotKey(height))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(val, &snap)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// captureSnapshot stores the state at a checkpoint height so peers can
+// fast-bootstrap from it.
+func (c *Chain) captureSnapshot(height uint64) {
+	snap, err := c.state.Snapshot(height)
+	if err != nil {
+		log.Printf("[SNAPSHOT] Failed to snapshot state at #%d: %v", height, err)
+		return
+	}
+	var previous uint64
+	if height >= config.CheckpointInterval {
+		previous = height - config.CheckpointInterval
+	}
+	if err := c.store.PutSnapshot(snap, previous); err != nil {
+		log.Printf("[SNAPSHOT] Failed to persist snapshot at #%d: %v", height, err)
+		return
+	}
+	root := snap.Root()
+	log.Printf("📸 State snapshot at #%d (%d accounts, root %x)", height, len(snap.Accounts), root[:8])
+}
+
+// Snapshot returns the stored state snapshot at a checkpoint height.
+func (c *Chain) Snapshot(height uint64) (*StateSnapshot, error) {
+	snap, err := c.store.GetSnapshot(height)
+	if err != nil {
+		return nil, fmt.Errorf("no snapshot at height %d: %w", height, err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestAccountProof(t *testing.T) {
+	snap := &StateSnapshot{}
+	for i := byte(1); i <= 5; i++ {
+		snap.Accounts = append(snap.Accounts, AccountState{Address: []byte{i}, Balance: big.NewInt(int64(i) * 100), Nonce: uint64(i)})
+	}
+	root := snap.Root()
+	for i := byte(1); i <= 5; i++ {
+		p, err := snap.AccountProof([]byte{i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyAccountProof(root, p) {
+			t.Fatalf("proof for account %d did not verify", i)
+		}
+		p.Account.Balance = big.NewInt(1)
+		if VerifyAccountProof(root, p) {
+			t.Fatalf("tampered proof for account %d verified", i)
+		}
+	}
+	if _, err := snap.AccountProof([]byte{9}); err == nil {
+		t.Fatal("expected error for missing account")
+	}
+}
@@ -5,30 +5,27 @@ import (
 	"log"
 	"math/big"
 
-	"github.com/dgraph-io/badger/v4"
+	"poai/core/storage"
 )
 
 // State manages account balances and transaction execution
 type State struct {
-	db *badger.DB
+	db storage.KV
 }
 
 // NewState creates a new state manager
-func NewState(db *badger.DB) *State {
+func NewState(db storage.KV) *State {
 	return &State{db: db}
 }
 
 // GetBalance returns the balance for the given address
 func (s *State) GetBalance(addr []byte) *big.Int {
 	balance := big.NewInt(0)
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn storage.Txn) error {
 		key := append([]byte("balance:"), addr...)
-		item, err := txn.Get(key)
+		val, err := txn.Get(key)
 		if err == nil {
-			return item.Value(func(val []byte) error {
-				balance.SetBytes(val)
-				return nil
-			})
+			balance.SetBytes(val)
 		}
 		return nil
 	})
@@ -40,7 +37,7 @@ func (s *State) GetBalance(addr []byte) *big.Int {
 
 // SetBalance sets the balance for the given address
 func (s *State) SetBalance(addr []byte, amount *big.Int) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn storage.Txn) error {
 		key := append([]byte("balance:"), addr...)
 		return txn.Set(key, amount.Bytes())
 	})
@@ -66,20 +63,17 @@ func (s *State) SubBalance(addr []byte, amount *big.Int) error {
 // GetNonce returns the current nonce for the given address
 func (s *State) GetNonce(addr []byte) uint64 {
 	var nonce uint64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn storage.Txn) error {
 		key := append([]byte("nonce:"), addr...)
-		item, err := txn.Get(key)
+		val, err := txn.Get(key)
 		if err == nil {
-			return item.Value(func(val []byte) error {
-				// Simple conversion from bytes to uint64
-				for i, b := range val {
-					if i >= 8 {
-						break
-					}
-					nonce |= uint64(b) << (i * 8)
+			// Simple conversion from bytes to uint64
+			for i, b := range val {
+				if i >= 8 {
+					break
 				}
-				return nil
-			})
+				nonce |= uint64(b) << (i * 8)
+			}
 		}
 		return nil
 	})
@@ -91,7 +85,7 @@ func (s *State) GetNonce(addr []byte) uint64 {
 
 // SetNonce sets the nonce for the given address
 func (s *State) SetNonce(addr []byte, nonce uint64) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn storage.Txn) error {
 		key := append([]byte("nonce:"), addr...)
 		// Convert uint64 to bytes
 		val := make([]byte, 8)
@@ -126,11 +120,24 @@ func (s *State) ExecuteTransaction(tx *Transaction) error {
 		return fmt.Errorf("invalid nonce: expected %d, got %d", expectedNonce, tx.Nonce)
 	}
 
-	// Calculate gas cost (simplified)
-	gasCost := big.NewInt(int64(tx.GasLimit))
-	gasCost.Mul(gasCost, tx.GasPrice)
+	if tx.Type != TxTransfer {
+		if err := s.validateBridgeTx(tx); err != nil {
+			return err
+		}
+	}
+
+	// The fee leaves the sender here; the coinbase pays it to the miner
+	gasCost := tx.Fee()
 	totalCost := new(big.Int).Add(tx.Amount, gasCost)
 
+	if tx.Type == TxBridgeUnlock {
+		// Funds come from escrow; the authority only pays gas
+		if err := s.executeBridgeUnlock(tx, gasCost); err != nil {
+			return fmt.Errorf("bridge unlock failed: %v", err)
+		}
+		return s.IncrementNonce(tx.From)
+	}
+
 	// Check balance
 	balance := s.GetBalance(tx.From)
 	if balance.Cmp(totalCost) < 0 {
@@ -172,26 +179,23 @@ func (s *State) ValidateTransaction(tx *Transaction) error {
 		return fmt.Errorf("invalid nonce: expected %d, got %d", expectedNonce, tx.Nonce)
 	}
 
-	// Calculate gas cost
-	gasCost := big.NewInt(int64(tx.GasLimit))
-	gasCost.Mul(gasCost, tx.GasPrice)
-	totalCost := new(big.Int).Add(tx.Amount, gasCost)
+	return s.validateSpend(tx, tx.Cost())
+}
+
+// validateSpend checks type-specific rules and that the sender's balance
+// covers cost, which may include the sender's earlier pending transactions.
+func (s *State) validateSpend(tx *Transaction, cost *big.Int) error {
+	if tx.Type != TxTransfer {
+		if err := s.validateBridgeTx(tx); err != nil {
+			return err
+		}
+	}
 
 	// Check balance
 	balance := s.GetBalance(tx.From)
-	if balance.Cmp(totalCost) < 0 {
-		return fmt.Errorf("insufficient balance: have %s, need %s", balance.String(), totalCost.String())
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("insufficient balance: have %s, need %s", balance.String(), cost.String())
 	}
 
 	return nil
 }
-
-// InitializeGenesisState sets up initial balances for genesis
-func (s *State) InitializeGenesisState() error {
-	// Create a test account with some initial balance
-	testAddr := []byte("test-account-12345678901234567890123456789012")
-	initialBalance := big.NewInt(1000) // 1000 POAI for testing
-
-	log.Printf("[STATE] Initializing genesis state with test account balance: %s", initialBalance.String())
-	return s.SetBalance(testAddr, initialBalance)
-}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EraItems is the number of items per era file.
+const EraItems = 8192
+
+// indexEntrySize is the size of an index entry: the big-endian offset of
+// the end of the item in the data file and the CRC-32 of the item.
+const indexEntrySize = 12
+
+// ErrAncientCorrupt is returned by Ancient.Get when an item does not match
+// its checksum.
+var ErrAncientCorrupt = errors.New("ancient item fails its checksum")
+
+// Ancient is an append-only store of items numbered from 0, kept in flat
+// era files of EraItems items each: era-NNNNN.dat holds the items back to
+// back and era-NNNNN.idx an index entry per item. The chain moves old
+// blocks here, out of the key-value engine, since they never change.
+//
+// Items are written to the data file before the index, so a crash at worst
+// leaves unindexed bytes, which the next open truncates.
+type Ancient struct {
+	dir      string
+	readOnly bool
+
+	mu       sync.RWMutex
+	eras     []*era
+	items    uint64
+	unsynced int // first era written to since the last Sync
+}
+
+// era is one pair of data and index files.
+type era struct {
+	dat, idx *os.File
+	size     uint64 // data file bytes in use
+	items    uint64
+}
+
+// OpenAncient opens or creates the ancient store in dir.
+func OpenAncient(dir string) (*Ancient, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return openAncient(dir, false)
+}
+
+// OpenAncientReadOnly opens the existing ancient store in dir for reading.
+func OpenAncientReadOnly(dir string) (*Ancient, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	return openAncient(dir, true)
+}
+
+func openAncient(dir string, readOnly bool) (*Ancient, error) {
+	a := &Ancient{dir: dir, readOnly: readOnly}
+	for n := 0; ; n++ {
+		if _, err := os.Stat(a.path(n, "idx")); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		e, err := a.openEra(n)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.eras = append(a.eras, e)
+		a.items += e.items
+		if e.items < EraItems {
+			break
+		}
+	}
+	a.unsynced = max(len(a.eras)-1, 0)
+	return a, nil
+}
+
+func (a *Ancient) path(n int, ext string) string {
+	return filepath.Join(a.dir, fmt.Sprintf("era-%05d.%s", n, ext))
+}
+
+// openEra opens era n, dropping index entries whose data is missing and
+// data past the last index entry.
+func (a *Ancient) openEra(n int) (*era, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if a.readOnly {
+		flag = os.O_RDONLY
+	}
+	dat, err := os.OpenFile(a.path(n, "dat"), flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := os.OpenFile(a.path(n, "idx"), flag, 0o644)
+	if err != nil {
+		dat.Close()
+		return nil, err
+	}
+	e := &era{dat: dat, idx: idx}
+	datInfo, err := dat.Stat()
+	if err != nil {
+		e.close()
+		return nil, err
+	}
+	idxInfo, err := idx.Stat()
+	if err != nil {
+		e.close()
+		return nil, err
+	}
+	e.items = uint64(idxInfo.Size()) / indexEntrySize
+	if e.items > EraItems {
+		e.items = EraItems
+	}
+	for e.items > 0 {
+		end, _, err := e.entry(e.items - 1)
+		if err != nil {
+			e.close()
+			return nil, err
+		}
+		if end <= uint64(datInfo.Size()) {
+			e.size = end
+			break
+		}
+		e.items--
+	}
+	if a.readOnly {
+		return e, nil
+	}
+	if err := idx.Truncate(int64(e.items * indexEntrySize)); err != nil {
+		e.close()
+		return nil, err
+	}
+	if err := dat.Truncate(int64(e.size)); err != nil {
+		e.close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// entry reads the index entry of item i of the era.
+func (e *era) entry(i uint64) (end uint64, sum uint32, err error) {
+	var buf [indexEntrySize]byte
+	if _, err := e.idx.ReadAt(buf[:], int64(i*indexEntrySize)); err != nil {
+		return 0, 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:8]), binary.BigEndian.Uint32(buf[8:]), nil
+}
+
+func (e *era) close() error {
+	err := e.dat.Close()
+	if ierr := e.idx.Close(); err == nil {
+		err = ierr
+	}
+	return err
+}
+
+// Items returns the number of items stored, which is also the number the
+// next Append takes.
+func (a *Ancient) Items() uint64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.items
+}
+
+// Get returns item n.
+func (a *Ancient) Get(n uint64) ([]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if n >= a.items {
+		return nil, ErrNotFound
+	}
+	e := a.eras[n/EraItems]
+	i := n % EraItems
+	var start uint64
+	if i > 0 {
+		var err error
+		if start, _, err = e.entry(i - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, sum, err := e.entry(i)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		return nil, fmt.Errorf("ancient item %d: %w", n, ErrAncientCorrupt)
+	}
+	val := make([]byte, end-start)
+	if _, err := e.dat.ReadAt(val, int64(start)); err != nil && !(err == io.EOF && len(val) == 0) {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(val) != sum {
+		return nil, fmt.Errorf("ancient item %d: %w", n, ErrAncientCorrupt)
+	}
+	return val, nil
+}
+
+// Append stores val as item n, which must be Items().
+func (a *Ancient) Append(n uint64, val []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.readOnly {
+		return errReadOnlyTxn
+	}
+	if n != a.items {
+		return fmt.Errorf("append ancient item %d, want %d", n, a.items)
+	}
+	if n%EraItems == 0 && int(n/EraItems) == len(a.eras) {
+		e, err := a.openEra(len(a.eras))
+		if err != nil {
+			return err
+		}
+		a.eras = append(a.eras, e)
+	}
+	e := a.eras[len(a.eras)-1]
+	if _, err := e.dat.WriteAt(val, int64(e.size)); err != nil {
+		return err
+	}
+	var buf [indexEntrySize]byte
+	binary.BigEndian.PutUint64(buf[:8], e.size+uint64(len(val)))
+	binary.BigEndian.PutUint32(buf[8:], crc32.ChecksumIEEE(val))
+	if _, err := e.idx.WriteAt(buf[:], int64(e.items*indexEntrySize)); err != nil {
+		return err
+	}
+	e.size += uint64(len(val))
+	e.items++
+	a.items++
+	return nil
+}
+
+// Truncate drops the items from n on.
+func (a *Ancient) Truncate(n uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n >= a.items {
+		return nil
+	}
+	if a.readOnly {
+		return errReadOnlyTxn
+	}
+	keep := int((n + EraItems - 1) / EraItems)
+	for i := len(a.eras) - 1; i >= keep; i-- {
+		a.eras[i].close()
+		if err := os.Remove(a.path(i, "idx")); err != nil {
+			return err
+		}
+		if err := os.Remove(a.path(i, "dat")); err != nil {
+			return err
+		}
+		a.eras = a.eras[:i]
+	}
+	a.items = n
+	a.unsynced = min(a.unsynced, max(len(a.eras)-1, 0))
+	if keep == 0 || n%EraItems == 0 {
+		return nil
+	}
+	e := a.eras[keep-1]
+	e.items = n % EraItems
+	end, _, err := e.entry(e.items - 1)
+	if err != nil {
+		return err
+	}
+	e.size = end
+	if err := e.idx.Truncate(int64(e.items * indexEntrySize)); err != nil {
+		return err
+	}
+	return e.dat.Truncate(int64(e.size))
+}
+
+// Sync flushes the items appended so far to stable storage.
+func (a *Ancient) Sync() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.readOnly {
+		return nil
+	}
+	for _, e := range a.eras[min(a.unsynced, len(a.eras)):] {
+		if err := e.dat.Sync(); err != nil {
+			return err
+		}
+		if err := e.idx.Sync(); err != nil {
+			return err
+		}
+	}
+	a.unsynced = max(len(a.eras)-1, 0)
+	return nil
+}
+
+// Close closes the era files.
+func (a *Ancient) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var err error
+	for _, e := range a.eras {
+		if cerr := e.close(); err == nil {
+			err = cerr
+		}
+	}
+	a.eras = nil
+	return err
+}
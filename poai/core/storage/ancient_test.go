@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAncient(t *testing.T) {
+	dir := t.TempDir()
+	a, err := OpenAncient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := func(n uint64) string { return fmt.Sprintf("item %d", n) }
+	const n = EraItems + 10
+	for i := uint64(0); i < n; i++ {
+		if err := a.Append(i, []byte(item(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Append(n+1, nil); err == nil {
+		t.Fatal("appended out of order")
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	check := func(a *Ancient, items uint64) {
+		t.Helper()
+		if a.Items() != items {
+			t.Fatalf("%d items, want %d", a.Items(), items)
+		}
+		for _, i := range []uint64{0, EraItems - 1, EraItems, items - 1} {
+			if val, err := a.Get(i); err != nil || string(val) != item(i) {
+				t.Fatalf("item %d = %q, %v", i, val, err)
+			}
+		}
+		if _, err := a.Get(items); err != ErrNotFound {
+			t.Fatalf("item past the end: %v", err)
+		}
+	}
+	check(a, n)
+	a.Close()
+
+	// A torn write, data without its index entry, is dropped on open
+	f, err := os.OpenFile(filepath.Join(dir, "era-00001.dat"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("torn"))
+	f.Close()
+	if a, err = OpenAncient(dir); err != nil {
+		t.Fatal(err)
+	}
+	check(a, n)
+	if err := a.Append(n, []byte(item(n))); err != nil {
+		t.Fatal(err)
+	}
+	check(a, n+1)
+
+	if err := a.Truncate(EraItems + 1); err != nil {
+		t.Fatal(err)
+	}
+	check(a, EraItems+1)
+	if err := a.Truncate(EraItems); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "era-00001.dat")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("emptied era kept: %v", err)
+	}
+	a.Close()
+
+	ro, err := OpenAncientReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if ro.Items() != EraItems {
+		t.Fatalf("%d items after reopen", ro.Items())
+	}
+	if err := ro.Append(EraItems, nil); err == nil {
+		t.Fatal("appended to a read-only store")
+	}
+}
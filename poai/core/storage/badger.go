@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Badger is the default engine.
+type Badger struct {
+	db *badger.DB
+}
+
+// OpenBadger opens or creates a Badger database in dir.
+func OpenBadger(dir string) (*Badger, error) {
+	return openBadger(badger.DefaultOptions(dir))
+}
+
+// OpenBadgerReadOnly opens an existing Badger database in dir for reading.
+func OpenBadgerReadOnly(dir string) (*Badger, error) {
+	return openBadger(badger.DefaultOptions(dir).WithReadOnly(true))
+}
+
+// OpenBadgerMemory opens an empty Badger database held in memory only.
+// Nothing touches disk, so it needs no directory lock and is gone once
+// closed.
+func OpenBadgerMemory() (*Badger, error) {
+	return openBadger(badger.DefaultOptions("").WithInMemory(true))
+}
+
+func openBadger(opts badger.Options) (*Badger, error) {
+	db, err := badger.Open(opts.WithLogger(nil))
+	if err != nil {
+		return nil, err
+	}
+	return &Badger{db: db}, nil
+}
+
+// DB returns the underlying Badger instance for engine-specific work.
+func (b *Badger) DB() *badger.DB {
+	return b.db
+}
+
+func (b *Badger) View(fn func(Txn) error) error {
+	return b.db.View(func(txn *badger.Txn) error { return fn(badgerTxn{txn}) })
+}
+
+func (b *Badger) Update(fn func(Txn) error) error {
+	return b.db.Update(func(txn *badger.Txn) error { return fn(badgerTxn{txn}) })
+}
+
+func (b *Badger) NewBatch() Batch {
+	return b.db.NewWriteBatch()
+}
+
+// Sync flushes the value log. In-memory and read-only databases have
+// nothing to flush.
+func (b *Badger) Sync() error {
+	if opts := b.db.Opts(); opts.InMemory || opts.ReadOnly {
+		return nil
+	}
+	return b.db.Sync()
+}
+
+// Compact merges the LSM tree into a single level. Value-log files are
+// reclaimed by CollectGarbage.
+func (b *Badger) Compact() error {
+	if b.db.Opts().ReadOnly {
+		return nil
+	}
+	return b.db.Flatten(runtime.GOMAXPROCS(0))
+}
+
+// CollectGarbage runs value-log GC until no file is at least discardRatio
+// stale. In-memory and read-only databases have no value log to collect.
+func (b *Badger) CollectGarbage(discardRatio float64) (int, error) {
+	if opts := b.db.Opts(); opts.InMemory || opts.ReadOnly {
+		return 0, nil
+	}
+	n := 0
+	for {
+		err := b.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func (b *Badger) Close() error {
+	return b.db.Close()
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t badgerTxn) Set(key, value []byte) error {
+	return badgerWriteErr(t.txn.Set(key, value))
+}
+
+func (t badgerTxn) Delete(key []byte) error {
+	return badgerWriteErr(t.txn.Delete(key))
+}
+
+func badgerWriteErr(err error) error {
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		return ErrTxnTooBig
+	}
+	return err
+}
+
+func (t badgerTxn) Iterate(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = reverse
+	end := prefixEnd(prefix)
+	if !reverse {
+		// Badger treats keys outside the prefix as invalid, including the
+		// one a reverse seek to end may land on
+		opts.Prefix = prefix
+	}
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+	switch {
+	case !reverse:
+		it.Seek(prefix)
+	case end == nil:
+		it.Rewind()
+	default:
+		// Reverse seeks land on the last key <= end, which may be end
+		it.Seek(end)
+		if it.Valid() && bytes.Equal(it.Item().Key(), end) {
+			it.Next()
+		}
+	}
+	for ; it.ValidForPrefix(prefix); it.Next() {
+		var cont bool
+		err := it.Item().Value(func(val []byte) error {
+			cont = fn(it.Item().Key(), val)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !cont {
+			return nil
+		}
+	}
+	return nil
+}
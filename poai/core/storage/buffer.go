@@ -0,0 +1,423 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Buffer wraps a KV so that related writes can be kept in memory and
+// written out together. Outside Begin and End it passes every call straight
+// through; inside, reads see the buffered writes first and Flush writes
+// them out in one transaction, so they reach the database all or not at
+// all. A failed flush keeps the writes buffered for the next one, unless
+// Commit made it: then the writes since its Begin are dropped.
+type Buffer struct {
+	base KV
+
+	mu sync.RWMutex
+	// Buffered writes: layers[0] holds those left by a failed flush, and
+	// every open Begin adds a layer on top for the writes made since it
+	layers []*writeSet
+}
+
+// NewBuffer wraps base.
+func NewBuffer(base KV) *Buffer {
+	return &Buffer{base: base, layers: []*writeSet{newWriteSet()}}
+}
+
+// Base returns the wrapped database.
+func (b *Buffer) Base() KV {
+	return b.base
+}
+
+// Begin starts buffering writes. Calls nest; buffering lasts until the
+// matching End, Commit or Discard.
+func (b *Buffer) Begin() {
+	b.mu.Lock()
+	b.layers = append(b.layers, newWriteSet())
+	b.mu.Unlock()
+}
+
+// End closes a Begin and, for the outermost one, flushes the buffer.
+func (b *Buffer) End() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if top := b.pop(); top != nil {
+		b.top().merge(top)
+	}
+	if b.depth() > 0 {
+		return nil
+	}
+	return b.flushLocked()
+}
+
+// Commit closes a Begin like End, but if the flush fails it drops the
+// writes made since the Begin rather than keeping them for the next one,
+// so that the caller can undo what it changed in memory to match.
+func (b *Buffer) Commit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	top := b.pop()
+	if top == nil {
+		return b.flushLocked()
+	}
+	if b.depth() > 0 {
+		b.top().merge(top)
+		return nil
+	}
+	b.layers = append(b.layers, top)
+	err := b.flushLocked()
+	if err != nil {
+		b.pop()
+	}
+	return err
+}
+
+// Discard closes a Begin, dropping the writes made since it. Writes an
+// earlier Flush wrote out stay written.
+func (b *Buffer) Discard() {
+	b.mu.Lock()
+	b.pop()
+	b.mu.Unlock()
+}
+
+// Flush writes the buffered writes to the base database.
+func (b *Buffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLocked()
+}
+
+// Buffered returns the number of keys waiting for Flush.
+func (b *Buffer) Buffered() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.buffered()
+}
+
+func (b *Buffer) depth() int {
+	return len(b.layers) - 1
+}
+
+func (b *Buffer) top() *writeSet {
+	return b.layers[len(b.layers)-1]
+}
+
+// pop removes the layer of the innermost open Begin, if any.
+func (b *Buffer) pop() *writeSet {
+	if b.depth() == 0 {
+		return nil
+	}
+	top := b.top()
+	b.layers = b.layers[:len(b.layers)-1]
+	return top
+}
+
+func (b *Buffer) buffered() int {
+	n := 0
+	for _, l := range b.layers {
+		n += len(l.keys)
+	}
+	return n
+}
+
+func (b *Buffer) writeTo(dst writer) error {
+	for _, l := range b.layers {
+		if err := l.writeTo(dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Buffer) flushLocked() error {
+	if b.buffered() == 0 {
+		return nil
+	}
+	err := b.base.Update(func(txn Txn) error {
+		return b.writeTo(txn)
+	})
+	if errors.Is(err, ErrTxnTooBig) {
+		// Too big to commit atomically, which only bulk imports get to
+		batch := b.base.NewBatch()
+		defer batch.Cancel()
+		if err = b.writeTo(batch); err == nil {
+			err = batch.Flush()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for i := range b.layers {
+		b.layers[i] = newWriteSet()
+	}
+	return nil
+}
+
+func (b *Buffer) View(fn func(Txn) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.buffered() == 0 {
+		return b.base.View(fn)
+	}
+	return b.base.View(func(txn Txn) error {
+		return fn(&overlayTxn{base: txn, layers: b.layers, readOnly: true})
+	})
+}
+
+func (b *Buffer) Update(fn func(Txn) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.depth() == 0 && b.buffered() == 0 {
+		return b.base.Update(fn)
+	}
+	// Writes are staged so that a failing fn leaves the buffer untouched
+	staged := newWriteSet()
+	layers := append(append([]*writeSet{}, b.layers...), staged)
+	err := b.base.View(func(txn Txn) error {
+		return fn(&overlayTxn{base: txn, layers: layers, writes: staged})
+	})
+	if err != nil {
+		return err
+	}
+	b.top().merge(staged)
+	if b.depth() == 0 {
+		return b.flushLocked()
+	}
+	return nil
+}
+
+// NewBatch returns a batch whose Flush adds its writes to the buffer while
+// buffering, or writes them to the base database otherwise.
+func (b *Buffer) NewBatch() Batch {
+	return &bufferBatch{buf: b, writes: newWriteSet()}
+}
+
+// Sync flushes the buffer and syncs the base database.
+func (b *Buffer) Sync() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.base.Sync()
+}
+
+// Compact flushes the buffer and compacts the base database.
+func (b *Buffer) Compact() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.base.Compact()
+}
+
+// Close flushes the buffer and closes the base database.
+func (b *Buffer) Close() error {
+	flushErr := b.Flush()
+	if err := b.base.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+type bufferBatch struct {
+	buf    *Buffer
+	writes *writeSet
+}
+
+func (bb *bufferBatch) Set(key, value []byte) error {
+	bb.writes.set(string(key), append([]byte{}, value...))
+	return nil
+}
+
+func (bb *bufferBatch) Delete(key []byte) error {
+	bb.writes.set(string(key), nil)
+	return nil
+}
+
+func (bb *bufferBatch) Flush() error {
+	b := bb.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.top().merge(bb.writes)
+	bb.writes = newWriteSet()
+	if b.depth() == 0 {
+		return b.flushLocked()
+	}
+	return nil
+}
+
+func (bb *bufferBatch) Cancel() {
+	bb.writes = newWriteSet()
+}
+
+// writeSet holds uncommitted writes, nil values being deletions. Prefix
+// scans need the written keys in order, so within sorts keys written since
+// the last scan and merges them in.
+type writeSet struct {
+	vals map[string][]byte
+
+	mu     sync.Mutex // guards sorting in within, which runs under read locks
+	keys   []string   // written keys, the first sorted of them in order
+	sorted int
+}
+
+func newWriteSet() *writeSet {
+	return &writeSet{vals: make(map[string][]byte)}
+}
+
+func (w *writeSet) set(key string, value []byte) {
+	if _, ok := w.vals[key]; !ok {
+		w.keys = append(w.keys, key)
+	}
+	w.vals[key] = value
+}
+
+// writer is what a Txn and a Batch have in common.
+type writer interface {
+	Set(key, value []byte) error
+	Delete(key []byte) error
+}
+
+func (w *writeSet) writeTo(dst writer) error {
+	for _, k := range w.keys {
+		var err error
+		if v := w.vals[k]; v == nil {
+			err = dst.Delete([]byte(k))
+		} else {
+			err = dst.Set([]byte(k), v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writeSet) merge(o *writeSet) {
+	for _, k := range o.keys {
+		w.set(k, o.vals[k])
+	}
+}
+
+// within returns the written keys starting with prefix, in order.
+func (w *writeSet) within(prefix []byte) []string {
+	w.mu.Lock()
+	if w.sorted < len(w.keys) {
+		head, tail := w.keys[:w.sorted], w.keys[w.sorted:]
+		sort.Strings(tail)
+		keys := make([]string, 0, len(w.keys))
+		for len(head) > 0 && len(tail) > 0 {
+			if head[0] < tail[0] {
+				keys, head = append(keys, head[0]), head[1:]
+			} else {
+				keys, tail = append(keys, tail[0]), tail[1:]
+			}
+		}
+		keys = append(append(keys, head...), tail...)
+		w.keys, w.sorted = keys, len(keys)
+	}
+	keys := w.keys
+	w.mu.Unlock()
+
+	p := string(prefix)
+	i := sort.SearchStrings(keys, p)
+	j := i
+	for j < len(keys) && strings.HasPrefix(keys[j], p) {
+		j++
+	}
+	return keys[i:j]
+}
+
+// overlayTxn reads through layers of uncommitted writes (later layers win)
+// to base, and writes to the writes layer.
+type overlayTxn struct {
+	base     Txn
+	layers   []*writeSet
+	writes   *writeSet
+	readOnly bool
+}
+
+func (t *overlayTxn) Get(key []byte) ([]byte, error) {
+	for i := len(t.layers) - 1; i >= 0; i-- {
+		if val, ok := t.layers[i].vals[string(key)]; ok {
+			if val == nil {
+				return nil, ErrNotFound
+			}
+			return append([]byte{}, val...), nil
+		}
+	}
+	return t.base.Get(key)
+}
+
+func (t *overlayTxn) Set(key, value []byte) error {
+	if t.readOnly {
+		return errReadOnlyTxn
+	}
+	t.writes.set(string(key), append([]byte{}, value...))
+	return nil
+}
+
+func (t *overlayTxn) Delete(key []byte) error {
+	if t.readOnly {
+		return errReadOnlyTxn
+	}
+	t.writes.set(string(key), nil)
+	return nil
+}
+
+func (t *overlayTxn) Iterate(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	// Collect the layers' writes under prefix, later layers winning
+	var keys []string
+	vals := make(map[string][]byte)
+	for _, layer := range t.layers {
+		for _, k := range layer.within(prefix) {
+			if _, ok := vals[k]; !ok {
+				keys = append(keys, k)
+			}
+			vals[k] = layer.vals[k]
+		}
+	}
+	if len(keys) == 0 {
+		return t.base.Iterate(prefix, reverse, fn)
+	}
+	sort.Strings(keys)
+	if reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	// Merge them into base's keys as they stream past
+	next, stopped := 0, false
+	emit := func(k string) bool {
+		if vals[k] == nil {
+			return true
+		}
+		stopped = !fn([]byte(k), vals[k])
+		return !stopped
+	}
+	err := t.base.Iterate(prefix, reverse, func(key, value []byte) bool {
+		for next < len(keys) && (!reverse && keys[next] < string(key) || reverse && keys[next] > string(key)) {
+			if !emit(keys[next]) {
+				return false
+			}
+			next++
+		}
+		if next < len(keys) && keys[next] == string(key) {
+			next++
+			return emit(keys[next-1])
+		}
+		stopped = !fn(key, value)
+		return !stopped
+	})
+	if err != nil || stopped {
+		return err
+	}
+	for ; next < len(keys); next++ {
+		if !emit(keys[next]) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	base, err := OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := NewBuffer(base)
+	defer buf.Close()
+	get := func(db KV, key string) string {
+		var val []byte
+		db.View(func(txn Txn) error {
+			val, _ = txn.Get([]byte(key))
+			return nil
+		})
+		return string(val)
+	}
+
+	// Buffered writes stay out of the database until the outermost End
+	buf.Begin()
+	buf.Begin()
+	buf.Update(func(txn Txn) error { return txn.Set([]byte("c"), []byte("buffered")) })
+	if got := get(buf, "c"); got != "buffered" {
+		t.Fatalf("buffer reads c = %q", got)
+	}
+	if err := buf.End(); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(base, "c"); got != "" || buf.Buffered() != 1 {
+		t.Fatalf("inner End wrote c = %q, %d buffered", got, buf.Buffered())
+	}
+	if err := buf.End(); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(base, "c"); got != "buffered" || buf.Buffered() != 0 {
+		t.Fatalf("outer End wrote c = %q, %d buffered", got, buf.Buffered())
+	}
+
+	// Reads merge buffered writes and deletions into stored keys
+	base.Update(func(txn Txn) error {
+		txn.Delete([]byte("c"))
+		txn.Set([]byte("a:2"), []byte("stale"))
+		return txn.Set([]byte("gone"), []byte("x"))
+	})
+	buf.Begin()
+	testKV(t, buf)
+	if err := buf.End(); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(base, "b:1"); got != "batched" {
+		t.Fatalf("b:1 = %q", got)
+	}
+}
+
+// failingKV fails Update writes to key with err.
+type failingKV struct {
+	KV
+	key string
+	err error
+}
+
+func (f *failingKV) Update(fn func(Txn) error) error {
+	return f.KV.Update(func(txn Txn) error {
+		return fn(failingTxn{txn, f})
+	})
+}
+
+type failingTxn struct {
+	Txn
+	f *failingKV
+}
+
+func (t failingTxn) Set(key, value []byte) error {
+	if string(key) == t.f.key && t.f.err != nil {
+		return t.f.err
+	}
+	return t.Txn.Set(key, value)
+}
+
+func TestBufferFlushIsAtomic(t *testing.T) {
+	mem, err := OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &failingKV{KV: mem, key: "tip", err: errors.New("disk full")}
+	buf := NewBuffer(base)
+	defer buf.Close()
+	stored := func(key string) bool {
+		return mem.View(func(txn Txn) error {
+			_, err := txn.Get([]byte(key))
+			return err
+		}) == nil
+	}
+
+	buf.Begin()
+	for _, key := range []string{"block", "state", "tip"} {
+		buf.Update(func(txn Txn) error { return txn.Set([]byte(key), []byte("x")) })
+	}
+	if err := buf.End(); err == nil {
+		t.Fatal("flush succeeded")
+	}
+	if stored("block") || stored("state") || buf.Buffered() != 3 {
+		t.Fatalf("failed flush left block=%v state=%v and %d buffered", stored("block"), stored("state"), buf.Buffered())
+	}
+
+	// A flush too big for one transaction falls back to a batch
+	base.err = ErrTxnTooBig
+	if err := buf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !stored("block") || !stored("tip") || buf.Buffered() != 0 {
+		t.Fatalf("retried flush left block=%v tip=%v and %d buffered", stored("block"), stored("tip"), buf.Buffered())
+	}
+}
+
+func TestBufferCommitAndDiscard(t *testing.T) {
+	mem, err := OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &failingKV{KV: mem, key: "tip"}
+	buf := NewBuffer(base)
+	defer buf.Close()
+	set := func(key string) {
+		buf.Update(func(txn Txn) error { return txn.Set([]byte(key), []byte("x")) })
+	}
+	has := func(db KV, key string) bool {
+		return db.View(func(txn Txn) error {
+			_, err := txn.Get([]byte(key))
+			return err
+		}) == nil
+	}
+
+	// Discarding an inner Begin drops only its writes
+	buf.Begin()
+	set("outer")
+	buf.Begin()
+	set("inner")
+	buf.Discard()
+	if !has(buf, "outer") || has(buf, "inner") {
+		t.Fatalf("discard left outer=%v inner=%v", has(buf, "outer"), has(buf, "inner"))
+	}
+	if err := buf.End(); err != nil || !has(mem, "outer") || has(mem, "inner") {
+		t.Fatalf("end wrote outer=%v inner=%v: %v", has(mem, "outer"), has(mem, "inner"), err)
+	}
+
+	// A failed Commit drops its writes instead of keeping them buffered
+	base.err = errors.New("disk full")
+	buf.Begin()
+	set("block")
+	set("tip")
+	if err := buf.Commit(); err == nil {
+		t.Fatal("commit succeeded")
+	}
+	if has(buf, "block") || buf.Buffered() != 0 {
+		t.Fatalf("failed commit kept block=%v and %d buffered", has(buf, "block"), buf.Buffered())
+	}
+	base.err = nil
+	buf.Begin()
+	set("block")
+	if err := buf.Commit(); err != nil || !has(mem, "block") || has(mem, "tip") {
+		t.Fatalf("commit wrote block=%v tip=%v: %v", has(mem, "block"), has(mem, "tip"), err)
+	}
+}
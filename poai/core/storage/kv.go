@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by Txn.Get for a missing key.
+var ErrNotFound = errors.New("key not found")
+
+// ErrTxnTooBig is returned by Txn writes when an Update holds more than the
+// engine can commit at once.
+var ErrTxnTooBig = errors.New("transaction too big")
+
+var errReadOnlyTxn = errors.New("write in a read-only transaction")
+
+// KV is the ordered key-value engine the chain keeps its blocks, state and
+// indexes in.
+type KV interface {
+	// View runs fn on a consistent, read-only view of the database.
+	View(fn func(Txn) error) error
+	// Update runs fn in a read-write transaction. Its writes are visible to
+	// its own reads and commit atomically if fn returns nil; otherwise they
+	// are discarded.
+	Update(fn func(Txn) error) error
+	// NewBatch starts a write-only batch for bulk writes. Unlike Update it
+	// has no size limit, but it is not guaranteed to commit atomically.
+	NewBatch() Batch
+	// Sync flushes written data to stable storage.
+	Sync() error
+	// Compact rewrites the database to reclaim the space of deleted and
+	// overwritten keys. It can take a while on a large database.
+	Compact() error
+	Close() error
+}
+
+// GarbageCollector is implemented by engines that leave stale data behind
+// until asked to reclaim it, such as Badger's value log.
+type GarbageCollector interface {
+	// CollectGarbage rewrites the files at least discardRatio stale and
+	// returns how many it rewrote.
+	CollectGarbage(discardRatio float64) (int, error)
+}
+
+// DefaultDiscardRatio is the share of stale data that makes a file worth
+// rewriting.
+const DefaultDiscardRatio = 0.5
+
+// Txn reads and writes keys inside View or Update. Writing in a View
+// fails.
+type Txn interface {
+	// Get returns a copy of the value stored under key, or ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) error
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(key []byte) error
+	// Iterate calls fn for every key starting with prefix, in ascending
+	// order or descending if reverse, until fn returns false. key and value
+	// are only valid during the call.
+	Iterate(prefix []byte, reverse bool, fn func(key, value []byte) bool) error
+}
+
+// Batch collects writes until Flush. Cancel releases an unflushed batch and
+// is a no-op after Flush.
+type Batch interface {
+	Set(key, value []byte) error
+	Delete(key []byte) error
+	Flush() error
+	Cancel()
+}
+
+// Storage engines. Each keeps its files in a subdirectory of the data
+// directory named after it.
+const (
+	EngineBadger  = "badger"
+	EnginePebble  = "pebble"
+	EngineLevelDB = "leveldb"
+)
+
+// Engines lists the supported engines, the default first.
+var Engines = []string{EngineBadger, EnginePebble, EngineLevelDB}
+
+// Open opens the database of the given engine in dataDir. An empty engine
+// picks the one whose subdirectory exists, or Badger for a new data
+// directory.
+func Open(dataDir, engine string) (KV, error) {
+	if engine == "" {
+		engine = Detect(dataDir)
+	}
+	dir := filepath.Join(dataDir, engine)
+	switch engine {
+	case EngineBadger:
+		return OpenBadger(dir)
+	case EnginePebble:
+		return OpenPebble(dir)
+	case EngineLevelDB:
+		return OpenLevelDB(dir)
+	}
+	return nil, fmt.Errorf("unknown storage engine %q (want one of %v)", engine, Engines)
+}
+
+// OpenReadOnly opens the existing database in dataDir for reading. Writes
+// fail, and nothing is created or migrated. Every engine locks its
+// directory, so this still fails while a node has the database open.
+func OpenReadOnly(dataDir string) (KV, error) {
+	engine := Detect(dataDir)
+	dir := filepath.Join(dataDir, engine)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("no database in %s: %w", dataDir, err)
+	}
+	switch engine {
+	case EnginePebble:
+		return OpenPebbleReadOnly(dir)
+	case EngineLevelDB:
+		return OpenLevelDBReadOnly(dir)
+	}
+	return OpenBadgerReadOnly(dir)
+}
+
+// Detect returns the engine whose database exists in dataDir, Badger if
+// none does.
+func Detect(dataDir string) string {
+	for _, engine := range Engines {
+		if _, err := os.Stat(filepath.Join(dataDir, engine)); err == nil {
+			return engine
+		}
+	}
+	return EngineBadger
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, or nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
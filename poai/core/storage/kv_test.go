@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEngines(t *testing.T) {
+	for _, engine := range Engines {
+		t.Run(engine, func(t *testing.T) {
+			dir := t.TempDir()
+			db, err := Open(dir, engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testKV(t, db)
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := Detect(dir); got != engine {
+				t.Fatalf("detected %s", got)
+			}
+			// Writes survive a reopen
+			if db, err = Open(dir, ""); err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			err = db.View(func(txn Txn) error {
+				val, err := txn.Get([]byte("a:2"))
+				if string(val) != "two" {
+					t.Errorf("after reopen a:2 = %q", val)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	t.Run("memory", func(t *testing.T) {
+		db, err := OpenBadgerMemory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		testKV(t, db)
+	})
+}
+
+func testKV(t *testing.T, db KV) {
+	err := db.Update(func(txn Txn) error {
+		for _, kv := range [][2]string{{"a:1", "one"}, {"a:2", "two"}, {"a:3", "three"}, {"a;", "next"}, {"a", "short"}, {"gone", "x"}} {
+			if err := txn.Set([]byte(kv[0]), []byte(kv[1])); err != nil {
+				return err
+			}
+		}
+		if err := txn.Delete([]byte("gone")); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte("never-set")); err != nil {
+			return err
+		}
+		// Reads see the transaction's own writes
+		if val, err := txn.Get([]byte("a:2")); err != nil || string(val) != "two" {
+			t.Errorf("read own write: %q, %v", val, err)
+		}
+		if _, err := txn.Get([]byte("gone")); err != ErrNotFound {
+			t.Errorf("read own delete: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed update leaves nothing behind
+	failed := errors.New("abort")
+	err = db.Update(func(txn Txn) error {
+		txn.Set([]byte("a:4"), []byte("four"))
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("update returned %v", err)
+	}
+
+	scan := func(prefix string, reverse bool, max int) []string {
+		var keys []string
+		err := db.View(func(txn Txn) error {
+			return txn.Iterate([]byte(prefix), reverse, func(key, _ []byte) bool {
+				keys = append(keys, string(key))
+				return len(keys) < max
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	if got := scan("a:", false, 10); !reflect.DeepEqual(got, []string{"a:1", "a:2", "a:3"}) {
+		t.Errorf("forward scan = %v", got)
+	}
+	if got := scan("a:", true, 10); !reflect.DeepEqual(got, []string{"a:3", "a:2", "a:1"}) {
+		t.Errorf("reverse scan = %v", got)
+	}
+	if got := scan("a:", true, 2); !reflect.DeepEqual(got, []string{"a:3", "a:2"}) {
+		t.Errorf("stopped scan = %v", got)
+	}
+	if got := scan("", false, 10); !reflect.DeepEqual(got, []string{"a", "a:1", "a:2", "a:3", "a;"}) {
+		t.Errorf("full scan = %v", got)
+	}
+	if got := scan("", true, 1); !reflect.DeepEqual(got, []string{"a;"}) {
+		t.Errorf("full reverse scan = %v", got)
+	}
+
+	b := db.NewBatch()
+	b.Set([]byte("b:1"), []byte("batched"))
+	b.Delete([]byte("a:1"))
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b.Cancel()
+	err = db.View(func(txn Txn) error {
+		if val, err := txn.Get([]byte("b:1")); err != nil || string(val) != "batched" {
+			t.Errorf("batched write: %q, %v", val, err)
+		}
+		if _, err := txn.Get([]byte("a:1")); err != ErrNotFound {
+			t.Errorf("batched delete: %v", err)
+		}
+		if _, err := txn.Get([]byte("a:4")); err != ErrNotFound {
+			t.Errorf("aborted write: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compacting keeps the data
+	if err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if gc, ok := db.(GarbageCollector); ok {
+		if _, err := gc.CollectGarbage(DefaultDiscardRatio); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := scan("a:", false, 10); !reflect.DeepEqual(got, []string{"a:2", "a:3"}) {
+		t.Errorf("scan after compaction = %v", got)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	if _, err := OpenReadOnly(t.TempDir()); err == nil {
+		t.Fatal("opened a missing database")
+	}
+	for _, engine := range Engines {
+		dir := t.TempDir()
+		db, err := Open(dir, engine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Update(func(txn Txn) error { return txn.Set([]byte("k"), []byte("v")) })
+		db.Close()
+
+		ro, err := OpenReadOnly(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		err = ro.View(func(txn Txn) error {
+			val, err := txn.Get([]byte("k"))
+			if string(val) != "v" {
+				t.Errorf("%s: k = %q", engine, val)
+			}
+			return err
+		})
+		if err != nil {
+			t.Errorf("%s: %v", engine, err)
+		}
+		if err := ro.Update(func(txn Txn) error { return txn.Set([]byte("k"), []byte("w")) }); err == nil {
+			t.Errorf("%s: wrote to a read-only database", engine)
+		}
+		ro.Close()
+	}
+}
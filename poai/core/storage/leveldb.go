@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDB stores the chain in a goleveldb database, readable by the usual
+// LevelDB tooling.
+//
+// goleveldb's own transactions are meant for large imports, so Update
+// collects its writes in memory, with its reads seeing them first, and
+// writes them as one batch at the end. Updates are serialised to stay
+// atomic.
+type LevelDB struct {
+	db *leveldb.DB
+	mu sync.Mutex // serialises Update
+}
+
+// OpenLevelDB opens or creates a LevelDB database in dir.
+func OpenLevelDB(dir string) (*LevelDB, error) {
+	return openLevelDB(dir, nil)
+}
+
+// OpenLevelDBReadOnly opens an existing LevelDB database in dir for reading.
+func OpenLevelDBReadOnly(dir string) (*LevelDB, error) {
+	return openLevelDB(dir, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+}
+
+func openLevelDB(dir string, o *opt.Options) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(dir, o)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDB{db: db}, nil
+}
+
+func (l *LevelDB) View(fn func(Txn) error) error {
+	snap, err := l.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	return fn(levelReadTxn{snap})
+}
+
+func (l *LevelDB) Update(fn func(Txn) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	writes := newWriteSet()
+	err := fn(&overlayTxn{base: levelReadTxn{l.db}, layers: []*writeSet{writes}, writes: writes})
+	if err != nil || len(writes.keys) == 0 {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	for _, k := range writes.keys {
+		if v := writes.vals[k]; v == nil {
+			batch.Delete([]byte(k))
+		} else {
+			batch.Put([]byte(k), v)
+		}
+	}
+	return l.db.Write(batch, nil)
+}
+
+func (l *LevelDB) NewBatch() Batch {
+	return &levelBatch{db: l.db, batch: new(leveldb.Batch)}
+}
+
+// Sync is a no-op: goleveldb appends every write to its journal and
+// syncs it on Close.
+func (l *LevelDB) Sync() error {
+	return nil
+}
+
+// Compact compacts the whole key space.
+func (l *LevelDB) Compact() error {
+	return l.db.CompactRange(util.Range{})
+}
+
+func (l *LevelDB) Close() error {
+	return l.db.Close()
+}
+
+// levelReader is what a snapshot and the database have in common.
+type levelReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelReadTxn struct {
+	r levelReader
+}
+
+func (t levelReadTxn) Get(key []byte) ([]byte, error) {
+	val, err := t.r.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return val, err
+}
+
+func (levelReadTxn) Set(key, value []byte) error {
+	return errReadOnlyTxn
+}
+
+func (levelReadTxn) Delete(key []byte) error {
+	return errReadOnlyTxn
+}
+
+func (t levelReadTxn) Iterate(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	it := t.r.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+	if reverse {
+		for ok := it.Last(); ok && fn(it.Key(), it.Value()); ok = it.Prev() {
+		}
+	} else {
+		for it.Next() && fn(it.Key(), it.Value()) {
+		}
+	}
+	return it.Error()
+}
+
+type levelBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelBatch) Set(key, value []byte) error {
+	b.batch.Put(key, value)
+	return nil
+}
+
+func (b *levelBatch) Delete(key []byte) error {
+	b.batch.Delete(key)
+	return nil
+}
+
+func (b *levelBatch) Flush() error {
+	err := b.db.Write(b.batch, nil)
+	b.batch.Reset()
+	return err
+}
+
+func (b *levelBatch) Cancel() {
+	b.batch.Reset()
+}
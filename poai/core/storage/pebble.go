@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Pebble stores the chain in a Pebble database, which needs less memory
+// than Badger for the same data.
+//
+// Update runs on an indexed batch, so it reads its own writes; Updates are
+// serialised to stay atomic.
+type Pebble struct {
+	db       *pebble.DB
+	mu       sync.Mutex // serialises Update
+	readOnly bool
+}
+
+// OpenPebble opens or creates a Pebble database in dir.
+func OpenPebble(dir string) (*Pebble, error) {
+	return openPebble(dir, &pebble.Options{Logger: pebbleLogger{}})
+}
+
+// OpenPebbleReadOnly opens an existing Pebble database in dir for reading.
+func OpenPebbleReadOnly(dir string) (*Pebble, error) {
+	return openPebble(dir, &pebble.Options{Logger: pebbleLogger{}, ReadOnly: true, ErrorIfNotExists: true})
+}
+
+func openPebble(dir string, opts *pebble.Options) (*Pebble, error) {
+	db, err := pebble.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Pebble{db: db, readOnly: opts.ReadOnly}, nil
+}
+
+// pebbleLogger drops Pebble's informational messages.
+type pebbleLogger struct{}
+
+func (pebbleLogger) Infof(format string, args ...interface{}) {}
+
+func (pebbleLogger) Fatalf(format string, args ...interface{}) {
+	log.Fatalf("[PEBBLE] "+format, args...)
+}
+
+func (p *Pebble) View(fn func(Txn) error) error {
+	snap := p.db.NewSnapshot()
+	defer snap.Close()
+	return fn(pebbleTxn{r: snap})
+}
+
+func (p *Pebble) Update(fn func(Txn) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	batch := p.db.NewIndexedBatch()
+	defer batch.Close()
+	if err := fn(pebbleTxn{r: batch, w: batch}); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.NoSync)
+}
+
+func (p *Pebble) NewBatch() Batch {
+	return &pebbleBatch{b: p.db.NewBatch()}
+}
+
+// Sync syncs the write-ahead log.
+func (p *Pebble) Sync() error {
+	if p.readOnly {
+		return nil
+	}
+	return p.db.LogData(nil, pebble.Sync)
+}
+
+// Compact compacts the whole key space.
+func (p *Pebble) Compact() error {
+	if p.readOnly {
+		return nil
+	}
+	first, last := []byte{}, []byte{0xff}
+	it, err := p.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	if it.Last() {
+		last = append(append([]byte{}, it.Key()...), 0)
+	}
+	if err := it.Close(); err != nil {
+		return err
+	}
+	return p.db.Compact(first, last, true)
+}
+
+func (p *Pebble) Close() error {
+	return p.db.Close()
+}
+
+// pebbleReader is what a snapshot and an indexed batch have in common.
+type pebbleReader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+	NewIter(o *pebble.IterOptions) (*pebble.Iterator, error)
+}
+
+// pebbleTxn reads from r and writes to w, which is nil in a View.
+type pebbleTxn struct {
+	r pebbleReader
+	w *pebble.Batch
+}
+
+func (t pebbleTxn) Get(key []byte) ([]byte, error) {
+	val, closer, err := t.r.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return append([]byte{}, val...), nil
+}
+
+func (t pebbleTxn) Set(key, value []byte) error {
+	if t.w == nil {
+		return errReadOnlyTxn
+	}
+	return t.w.Set(key, value, nil)
+}
+
+func (t pebbleTxn) Delete(key []byte) error {
+	if t.w == nil {
+		return errReadOnlyTxn
+	}
+	return t.w.Delete(key, nil)
+}
+
+func (t pebbleTxn) Iterate(prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	it, err := t.r.NewIter(&pebble.IterOptions{LowerBound: prefix, UpperBound: prefixEnd(prefix)})
+	if err != nil {
+		return err
+	}
+	if reverse {
+		for ok := it.Last(); ok && fn(it.Key(), it.Value()); ok = it.Prev() {
+		}
+	} else {
+		for ok := it.First(); ok && fn(it.Key(), it.Value()); ok = it.Next() {
+		}
+	}
+	if err := it.Error(); err != nil {
+		it.Close()
+		return err
+	}
+	return it.Close()
+}
+
+type pebbleBatch struct {
+	b *pebble.Batch
+}
+
+func (b *pebbleBatch) Set(key, value []byte) error {
+	return b.b.Set(key, value, nil)
+}
+
+func (b *pebbleBatch) Delete(key []byte) error {
+	return b.b.Delete(key, nil)
+}
+
+func (b *pebbleBatch) Flush() error {
+	return b.b.Commit(pebble.NoSync)
+}
+
+func (b *pebbleBatch) Cancel() {
+	b.b.Close()
+}
@@ -0,0 +1,437 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"poai/core/header"
+	"poai/core/storage"
+	"poai/dataset"
+)
+
+// Store keeps blocks, receipts, undo records and indexes in a key-value
+// engine (see storage.KV), and old blocks in an ancient store (see
+// Freeze).
+type Store struct {
+	db  storage.KV // buf, so writes can be batched
+	buf *storage.Buffer
+
+	ancient *storage.Ancient // nil for memory stores
+	frozen  uint64
+}
+
+// OpenStore opens the chain database in dataDir with the given engine. An
+// empty engine keeps the one the directory already holds, Badger for a new
+// one.
+func OpenStore(dataDir, engine string) (*Store, error) {
+	db, err := storage.Open(dataDir, engine)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewStore(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.openAncient(dataDir, false); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("open ancient store: %w", err)
+	}
+	return s, nil
+}
+
+func OpenBadgerStore(dataDir string) (*Store, error) {
+	return OpenStore(dataDir, storage.EngineBadger)
+}
+
+// OpenMemoryStore opens an empty store held in memory only. Nothing touches
+// disk, so it needs no data directory lock and is gone once closed; tests
+// and --ephemeral nodes use it.
+func OpenMemoryStore() (*Store, error) {
+	db, err := storage.OpenBadgerMemory()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(db)
+}
+
+// NewStore wraps an open database, migrating it to the current layout. The
+// database is closed if that fails.
+func NewStore(db storage.KV) (*Store, error) {
+	s := newStore(db)
+	if err := s.migrateBlockIndex(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate block index: %w", err)
+	}
+	if err := s.migrateBlockEncoding(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate block encoding: %w", err)
+	}
+	if err := s.migrateUndoStart(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("record undo start: %w", err)
+	}
+	return s, nil
+}
+
+// OpenStoreReadOnly opens the existing chain database in dataDir for
+// queries. It fails while a node has the database open.
+func OpenStoreReadOnly(dataDir string) (*Store, error) {
+	db, err := storage.OpenReadOnly(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	s := newStore(db)
+	if err := s.openAncient(dataDir, true); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("open ancient store: %w", err)
+	}
+	return s, nil
+}
+
+func newStore(db storage.KV) *Store {
+	buf := storage.NewBuffer(db)
+	return &Store{db: buf, buf: buf}
+}
+
+// BeginBatch keeps the store's writes in memory, where reads still see
+// them, until the matching EndBatch; FlushBatch writes them out early.
+// Bulk imports use it to replace many small transactions with a few large
+// batches.
+func (s *Store) BeginBatch() {
+	s.buf.Begin()
+}
+
+// FlushBatch writes the writes buffered so far.
+func (s *Store) FlushBatch() error {
+	return s.buf.Flush()
+}
+
+// EndBatch closes a BeginBatch, writing the buffered writes once the
+// outermost one ends.
+func (s *Store) EndBatch() error {
+	return s.buf.End()
+}
+
+// CommitBatch closes a BeginBatch like EndBatch, but drops the writes made
+// since it if they cannot be written, rather than retrying them with the
+// next write.
+func (s *Store) CommitBatch() error {
+	return s.buf.Commit()
+}
+
+// DiscardBatch closes a BeginBatch, dropping the writes made since it.
+func (s *Store) DiscardBatch() {
+	s.buf.Discard()
+}
+
+// Blocks are stored by hash under hash:<hash>; block:<height> maps each
+// canonical height to its block hash. Blocks from abandoned branches stay
+// retrievable by hash after a reorg.
+var blockIndexVersionKey = []byte("meta:blockindex")
+
+const blockIndexVersion = "2"
+
+// hashKey holds the encoded block with the given hash.
+func hashKey(h [32]byte) []byte {
+	return append([]byte("hash:"), h[:]...)
+}
+
+// canonKey holds the hash of the canonical block at height.
+func canonKey(height uint64) []byte {
+	return []byte("block:" + strconv.FormatUint(height, 10))
+}
+
+// migrateBlockIndex converts the old height-keyed layout (block:<height>
+// holding the encoded block) to hash-keyed storage.
+func (s *Store) migrateBlockIndex() error {
+	type entry struct {
+		key []byte
+		val []byte
+	}
+	var old []entry
+	done := false
+	err := s.db.View(func(txn storage.Txn) error {
+		if val, err := txn.Get(blockIndexVersionKey); err == nil {
+			done = string(val) == blockIndexVersion
+			return nil
+		}
+		return txn.Iterate([]byte("block:"), false, func(key, val []byte) bool {
+			old = append(old, entry{append([]byte{}, key...), append([]byte{}, val...)})
+			return true
+		})
+	})
+	if err != nil || done {
+		return err
+	}
+
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	for _, e := range old {
+		b, err := DecodeBlock(e.val)
+		if err != nil {
+			return fmt.Errorf("migrate %s: %w", e.key, err)
+		}
+		h := b.Hash()
+		if err := wb.Set(hashKey(h), e.val); err != nil {
+			return err
+		}
+		if err := wb.Set(e.key, h[:]); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set(blockIndexVersionKey, []byte(blockIndexVersion)); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	if len(old) > 0 {
+		log.Printf("🗄️  Migrated %d blocks to hash-keyed storage", len(old))
+	}
+	return nil
+}
+
+// blockEncodingKey records that stored blocks use the RLP encoding.
+var blockEncodingKey = []byte("meta:blockencoding")
+
+const blockEncoding = "rlp"
+
+// migrateBlockEncoding re-encodes blocks stored as JSON by older versions
+// into RLP. Block hashes do not depend on the encoding, so keys are kept.
+func (s *Store) migrateBlockEncoding() error {
+	type entry struct {
+		key []byte
+		val []byte
+	}
+	var legacy []entry
+	done := false
+	err := s.db.View(func(txn storage.Txn) error {
+		if val, err := txn.Get(blockEncodingKey); err == nil {
+			done = string(val) == blockEncoding
+			return nil
+		}
+		return txn.Iterate([]byte("hash:"), false, func(key, val []byte) bool {
+			if isLegacyJSON(val) {
+				legacy = append(legacy, entry{append([]byte{}, key...), append([]byte{}, val...)})
+			}
+			return true
+		})
+	})
+	if err != nil || done {
+		return err
+	}
+
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	for _, e := range legacy {
+		b, err := DecodeBlock(e.val)
+		if err != nil {
+			return fmt.Errorf("migrate %x: %w", e.key, err)
+		}
+		val, err := b.Encode()
+		if err != nil {
+			return fmt.Errorf("migrate %x: %w", e.key, err)
+		}
+		if err := wb.Set(e.key, val); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set(blockEncodingKey, []byte(blockEncoding)); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	if len(legacy) > 0 {
+		log.Printf("🗄️  Re-encoded %d blocks from JSON to RLP", len(legacy))
+	}
+	return nil
+}
+
+// PutBlock stores block and makes it the canonical block at height.
+func (s *Store) PutBlock(height uint64, block *Block) error {
+	val, err := block.Encode()
+	if err != nil {
+		return err
+	}
+	h := block.Hash()
+	return s.db.Update(func(txn storage.Txn) error {
+		if err := txn.Set(hashKey(h), val); err != nil {
+			return err
+		}
+		if err := txn.Set(canonKey(height), h[:]); err != nil {
+			return err
+		}
+		if err := txn.Delete(sideKey(h)); err != nil { // adopted by a reorg
+			return err
+		}
+		// Update tip
+		tipKey := []byte("chain:tip")
+		tipVal := []byte(strconv.FormatUint(height, 10))
+		return txn.Set(tipKey, tipVal)
+	})
+}
+
+// GetCanonicalHash returns the hash of the canonical block at height.
+func (s *Store) GetCanonicalHash(height uint64) ([32]byte, error) {
+	var h [32]byte
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(canonKey(height))
+		copy(h[:], val)
+		return err
+	})
+	return h, err
+}
+
+// GetBlock returns the canonical block at height.
+func (s *Store) GetBlock(height uint64) (*Block, error) {
+	h, err := s.GetCanonicalHash(height)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlockByHash(h)
+}
+
+// GetBlockByHash returns any stored block, canonical or not.
+func (s *Store) GetBlockByHash(h [32]byte) (*Block, error) {
+	var val []byte
+	err := s.db.View(func(txn storage.Txn) error {
+		var err error
+		val, err = txn.Get(hashKey(h))
+		return err
+	})
+	if err == storage.ErrNotFound {
+		val, err = s.getAncient(h)
+	}
+	if err != nil {
+		return nil, err
+	}
+	blk, err := DecodeBlock(val)
+	if err != nil {
+		return nil, err
+	}
+	// Blocks stored before headers carried bits get the default target
+	if blk.Header.Bits == 0 {
+		blk.Header.Bits = header.BigToCompact(big.NewInt(dataset.DefaultTarget))
+	}
+	return blk, nil
+}
+
+// deleteCanonical removes the canonical block at height and its data.
+func deleteCanonical(txn storage.Txn, height uint64) error {
+	val, err := txn.Get(canonKey(height))
+	if err == storage.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var h [32]byte
+	copy(h[:], val)
+	if err := txn.Delete(hashKey(h)); err != nil {
+		return err
+	}
+	if err := txn.Delete(ancientKey(h)); err != nil {
+		return err
+	}
+	return txn.Delete(canonKey(height))
+}
+
+func (s *Store) DeleteBlock(height uint64) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		return deleteCanonical(txn, height)
+	})
+}
+
+// Rewind makes height the tip, deleting the canonical blocks above it up to
+// tip. Frozen blocks above it are dropped from the ancient store by the
+// next Freeze or open.
+func (s *Store) Rewind(height, tip uint64) error {
+	frozen := min(s.frozen, height+1)
+	err := s.db.Update(func(txn storage.Txn) error {
+		for h := tip; h > height; h-- {
+			if err := deleteCanonical(txn, h); err != nil {
+				return err
+			}
+		}
+		if frozen < s.frozen {
+			if err := txn.Set(frozenKey, []byte(strconv.FormatUint(frozen, 10))); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte("chain:tip"), []byte(strconv.FormatUint(height, 10)))
+	})
+	if err == nil {
+		s.frozen = frozen
+	}
+	return err
+}
+
+func (s *Store) GetTipHeight() (uint64, error) {
+	var height uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get([]byte("chain:tip"))
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseUint(string(val), 10, 64)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+func (s *Store) PruneBlocks(keepN uint64, tip uint64) error {
+	minKeep := uint64(0)
+	if tip >= keepN {
+		minKeep = tip - keepN + 1
+	}
+	return s.db.Update(func(txn storage.Txn) error {
+		for h := uint64(0); h < minKeep; h++ {
+			if err := deleteCanonical(txn, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Compact reclaims the space of deleted and overwritten keys.
+func (s *Store) Compact() error {
+	return s.db.Compact()
+}
+
+// CollectGarbage reclaims stale data of engines that keep it until asked
+// (Badger's value log) and returns how many files it rewrote. Other
+// engines reclaim space as they compact and report 0.
+func (s *Store) CollectGarbage(discardRatio float64) (int, error) {
+	gc, ok := s.buf.Base().(storage.GarbageCollector)
+	if !ok {
+		return 0, nil
+	}
+	return gc.CollectGarbage(discardRatio)
+}
+
+// Sync flushes written data to disk.
+func (s *Store) Sync() error {
+	return s.db.Sync()
+}
+
+func (s *Store) Close() error {
+	err := s.db.Close()
+	if s.ancient != nil {
+		if aerr := s.ancient.Close(); err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
+
+// KV returns the underlying database.
+func (s *Store) KV() storage.KV {
+	return s.db
+}
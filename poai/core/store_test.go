@@ -0,0 +1,210 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"poai/core/storage"
+)
+
+func TestStoreKeepsReorgedBlocks(t *testing.T) {
+	s, err := OpenBadgerStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	a := NewBlock(1, [32]byte{}, 1, big.NewInt(10), nil, 1)
+	b := NewBlock(1, [32]byte{}, 1, big.NewInt(10), nil, 2)
+	if err := s.PutBlock(1, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutBlock(1, b); err != nil { // reorg replaces a
+		t.Fatal(err)
+	}
+	if got, err := s.GetBlock(1); err != nil || got.Hash() != b.Hash() {
+		t.Fatalf("canonical block at 1 = %v, %v; want b", got, err)
+	}
+	if got, err := s.GetBlockByHash(a.Hash()); err != nil || got.Header.Nonce != 1 {
+		t.Fatalf("abandoned block lost: %v, %v", got, err)
+	}
+}
+
+func TestStoreMigratesHeightKeyedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk := NewBlock(1, [32]byte{}, 1, big.NewInt(10), nil, 7)
+	val, _ := blk.Encode()
+	// Write the legacy layout: block:<height> holding the encoded block
+	err = s.db.Update(func(txn storage.Txn) error {
+		if err := txn.Delete(blockIndexVersionKey); err != nil {
+			return err
+		}
+		return txn.Set(canonKey(1), val)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if s, err = OpenBadgerStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, err := s.GetBlock(1); err != nil || got.Hash() != blk.Hash() {
+		t.Fatalf("migrated block = %v, %v", got, err)
+	}
+}
+
+func TestStoreMigratesJSONBlocks(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk := signedTestBlock(t)
+	legacy, _ := json.Marshal(blk)
+	h := blk.Hash()
+	err = s.db.Update(func(txn storage.Txn) error {
+		if err := txn.Delete(blockEncodingKey); err != nil {
+			return err
+		}
+		if err := txn.Set(hashKey(h), legacy); err != nil {
+			return err
+		}
+		return txn.Set(canonKey(1), h[:])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if s, err = OpenBadgerStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	err = s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(hashKey(h))
+		if isLegacyJSON(val) {
+			t.Error("block still stored as JSON")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetBlock(1); err != nil || got.Hash() != h || len(got.Transactions) != 2 {
+		t.Fatalf("migrated block = %v, %v", got, err)
+	}
+}
+
+func TestMemoryChain(t *testing.T) {
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := c.HeaderByHeight(0)
+	b := NewBlock(1, parent.Hash(), -1, parent.Target(), nil, 1)
+	b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(nil)
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.store.GetBlock(1); err != nil || got.Hash() != b.Hash() {
+		t.Fatalf("stored block 1 = %v, %v", got, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second in-memory chain starts empty
+	c, err = NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Height() != 0 {
+		t.Fatalf("fresh in-memory chain at height %d", c.Height())
+	}
+}
+
+// failingKV fails every Update while fail is set.
+type failingKV struct {
+	storage.KV
+	fail bool
+}
+
+func (f *failingKV) Update(fn func(storage.Txn) error) error {
+	if f.fail {
+		return errors.New("disk full")
+	}
+	return f.KV.Update(fn)
+}
+
+func TestImportRollsBackUnwrittenBlock(t *testing.T) {
+	mem, err := storage.OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &failingKV{KV: mem}
+	s, err := NewStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := openChain(s, "", DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	parent := c.HeaderByHeight(0)
+	b := NewBlock(1, parent.Hash(), -1, parent.Target(), nil, 1)
+	b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(nil)
+	db.fail = true
+	if err := c.ImportTrustedBlock(b); err == nil {
+		t.Fatal("imported a block that was not written")
+	}
+	if c.Height() != 0 || c.blocks.get(1) != nil || s.buf.Buffered() != 0 {
+		t.Fatalf("failed import left head %d, block cached %v, %d writes buffered",
+			c.Height(), c.blocks.get(1) != nil, s.buf.Buffered())
+	}
+
+	db.fail = false
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetBlock(1); err != nil || got.Hash() != b.Hash() || c.Height() != 1 {
+		t.Fatalf("stored block 1 = %v, %v at height %d", got, err, c.Height())
+	}
+}
+
+func TestStoreEngines(t *testing.T) {
+	for _, engine := range storage.Engines {
+		dir := t.TempDir()
+		s, err := OpenStore(dir, engine)
+		if err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		blk := NewBlock(1, [32]byte{}, 1, big.NewInt(10), nil, 3)
+		if err := s.PutBlock(1, blk); err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		s.Close()
+
+		// Reopening without naming the engine finds it
+		if s, err = OpenStore(dir, ""); err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if got, err := s.GetBlock(1); err != nil || got.Hash() != blk.Hash() {
+			t.Errorf("%s: block 1 = %v, %v", engine, got, err)
+		}
+		if tip, err := s.GetTipHeight(); err != nil || tip != 1 {
+			t.Errorf("%s: tip = %d, %v", engine, tip, err)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what happens when a subscriber's buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest skips the notification; head changes coalesce, so the
+	// consumer still sees that the head moved. This is the default.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest queued notification to make room.
+	DropOldest
+	// Disconnect closes the subscription of a consumer that falls behind.
+	Disconnect
+)
+
+// HeadSubscription delivers head-change notifications on C until
+// Unsubscribe is called. C is closed when the subscription ends.
+type HeadSubscription struct {
+	C <-chan struct{}
+
+	ch      chan struct{}
+	policy  OverflowPolicy
+	chain   *Chain
+	once    sync.Once
+	dropped uint64
+}
+
+// Unsubscribe removes the subscription and closes C. It is safe to call more than once.
+func (s *HeadSubscription) Unsubscribe() {
+	s.chain.subMu.Lock()
+	defer s.chain.subMu.Unlock()
+	s.closeLocked()
+}
+
+// Dropped returns how many notifications were discarded because the consumer was slow.
+func (s *HeadSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// closeLocked must be called with chain.subMu held.
+func (s *HeadSubscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.chain.subscribers, s)
+		close(s.ch)
+	})
+}
+
+// SubscribeToHeadChanges returns a subscription that receives notifications
+// when the chain head changes, with a one-slot buffer and DropNewest policy.
+func (c *Chain) SubscribeToHeadChanges() *HeadSubscription {
+	return c.SubscribeToHeadChangesWith(1, DropNewest)
+}
+
+// SubscribeToHeadChangesWith returns a head-change subscription with the
+// given buffer size and overflow policy.
+func (c *Chain) SubscribeToHeadChangesWith(buffer int, policy OverflowPolicy) *HeadSubscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	ch := make(chan struct{}, buffer)
+	sub := &HeadSubscription{C: ch, ch: ch, policy: policy, chain: c}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// notifyHeadChange notifies all subscribers that the head has changed.
+func (c *Chain) notifyHeadChange() {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	for sub := range c.subscribers {
+		select {
+		case sub.ch <- struct{}{}:
+			continue
+		default:
+		}
+		atomic.AddUint64(&sub.dropped, 1)
+		switch sub.policy {
+		case DropOldest:
+			select {
+			case <-sub.ch:
+			default:
+			}
+			select {
+			case sub.ch <- struct{}{}:
+			default:
+			}
+		case Disconnect:
+			log.Printf("[CHAIN] Closing slow head subscriber (%d notifications dropped)", sub.Dropped())
+			sub.closeLocked()
+		}
+	}
+}
+
+// SubscriberCount returns the number of live head subscriptions.
+func (c *Chain) SubscriberCount() int {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+	return len(c.subscribers)
+}
@@ -0,0 +1,48 @@
+package core
+
+import "testing"
+
+func TestHeadSubscriptionUnsubscribe(t *testing.T) {
+	c := &Chain{subscribers: make(map[*HeadSubscription]struct{})}
+	sub := c.SubscribeToHeadChanges()
+	c.notifyHeadChange()
+	if _, ok := <-sub.C; !ok {
+		t.Fatal("expected a notification")
+	}
+	sub.Unsubscribe()
+	sub.Unsubscribe() // idempotent
+	if c.SubscriberCount() != 0 {
+		t.Fatalf("subscriber not removed")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Fatal("channel should be closed after Unsubscribe")
+	}
+	c.notifyHeadChange() // must not panic on the closed channel
+}
+
+func TestHeadSubscriptionDisconnectsSlowConsumer(t *testing.T) {
+	c := &Chain{subscribers: make(map[*HeadSubscription]struct{})}
+	sub := c.SubscribeToHeadChangesWith(1, Disconnect)
+	c.notifyHeadChange()
+	c.notifyHeadChange() // buffer full -> disconnected
+	if c.SubscriberCount() != 0 {
+		t.Fatal("slow consumer should have been disconnected")
+	}
+	if sub.Dropped() != 1 {
+		t.Fatalf("dropped = %d, want 1", sub.Dropped())
+	}
+}
+
+func TestChainEventSubscriptionDisconnectsSlowConsumer(t *testing.T) {
+	c := &Chain{}
+	sub := c.SubscribeChainEvents(1)
+	c.publishEvents(ChainEvent{Type: EventBlockAdded}, ChainEvent{Type: EventBlockAdded})
+	if !sub.Overflowed() {
+		t.Fatal("slow consumer should have been disconnected")
+	}
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Fatal("channel should be closed")
+	}
+	sub.Unsubscribe() // after the disconnect, must not panic
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"math/big"
+
+	"poai/core/storage"
+)
+
+// Cumulative issuance counters, kept next to the balances so undo records
+// revert them with the rest of a block's state.
+var (
+	supplyIssuedKey = []byte("supply:issued")
+	supplyBurnedKey = []byte("supply:burned")
+)
+
+// Emission is what one block added to and removed from the supply.
+// Coinbase pays at most Subsidy plus Fees; the fees leave the senders
+// either way, so whatever the coinbase leaves unclaimed is Burned.
+type Emission struct {
+	Height  uint64   `json:"height"`
+	Subsidy *big.Int `json:"subsidy"`
+	Fees    *big.Int `json:"fees"`
+	Reward  *big.Int `json:"reward"` // paid by the coinbase
+	Burned  *big.Int `json:"burned"`
+}
+
+// blockEmission computes the emission of block.
+func blockEmission(block *Block) *Emission {
+	e := &Emission{
+		Height:  block.Header.Height,
+		Subsidy: GetSubsidy(block.Header.Height),
+		Fees:    new(big.Int),
+		Reward:  new(big.Int),
+	}
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			if tx.Amount != nil {
+				e.Reward.Add(e.Reward, tx.Amount)
+			}
+			continue
+		}
+		e.Fees.Add(e.Fees, tx.Fee())
+	}
+	e.Burned = new(big.Int).Add(e.Subsidy, e.Fees)
+	e.Burned.Sub(e.Burned, e.Reward)
+	return e
+}
+
+// Supply summarises the coins in existence at Height. Issued counts the
+// genesis allocation and every subsidy since; Burned the subsidies and fees
+// coinbases left unclaimed. TotalSupply is the sum of all balances, so it
+// equals Issued minus Burned.
+type Supply struct {
+	Height      uint64   `json:"height"`
+	TotalSupply *big.Int `json:"totalSupply"`
+	Issued      *big.Int `json:"issued"`
+	Burned      *big.Int `json:"burned"`
+}
+
+// recordEmission adds e to the cumulative counters.
+func (s *State) recordEmission(e *Emission) error {
+	return s.addSupply(e.Subsidy, e.Burned)
+}
+
+// addSupply adds to the cumulative issued and burned counters.
+func (s *State) addSupply(issued, burned *big.Int) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		for _, c := range []struct {
+			key   []byte
+			delta *big.Int
+		}{{supplyIssuedKey, issued}, {supplyBurnedKey, burned}} {
+			total := new(big.Int)
+			val, err := txn.Get(c.key)
+			switch err {
+			case nil:
+				total.SetBytes(val)
+			case storage.ErrNotFound:
+			default:
+				return err
+			}
+			if err := txn.Set(c.key, total.Add(total, c.delta).Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setSupply overwrites the cumulative counters, as rebuilt by a reindex.
+func (s *State) setSupply(issued, burned *big.Int) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		if err := txn.Set(supplyIssuedKey, issued.Bytes()); err != nil {
+			return err
+		}
+		return txn.Set(supplyBurnedKey, burned.Bytes())
+	})
+}
+
+// supply reads the counters and sums all balances. Databases created
+// before supply tracking, or restored from a state snapshot, count
+// issuance and burns only from the blocks applied since, unless the node
+// was reindexed (see ReindexFromDB).
+func (s *State) supply() (*Supply, error) {
+	sup := &Supply{TotalSupply: new(big.Int), Issued: new(big.Int), Burned: new(big.Int)}
+	err := s.db.View(func(txn storage.Txn) error {
+		for key, total := range map[string]*big.Int{string(supplyIssuedKey): sup.Issued, string(supplyBurnedKey): sup.Burned} {
+			val, err := txn.Get([]byte(key))
+			switch err {
+			case nil:
+				total.SetBytes(val)
+			case storage.ErrNotFound:
+			default:
+				return err
+			}
+		}
+		return txn.Iterate([]byte("balance:"), false, func(_, val []byte) bool {
+			sup.TotalSupply.Add(sup.TotalSupply, new(big.Int).SetBytes(val))
+			return true
+		})
+	})
+	return sup, err
+}
+
+// Supply returns the total supply and cumulative issuance at the head.
+func (c *Chain) Supply() (*Supply, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sup, err := c.state.supply()
+	if err != nil {
+		return nil, err
+	}
+	sup.Height = c.head
+	return sup, nil
+}
+
+// Emission returns the emission of the canonical block at height, or nil
+// if the chain has no such block.
+func (c *Chain) Emission(height uint64) *Emission {
+	blk := c.BlockByHeight(height)
+	if blk == nil {
+		return nil
+	}
+	if height == 0 {
+		// Genesis mints its allocation instead of a subsidy
+		alloc := c.genesis.AllocTotal()
+		return &Emission{Subsidy: alloc, Fees: new(big.Int), Reward: alloc, Burned: new(big.Int)}
+	}
+	return blockEmission(blk)
+}
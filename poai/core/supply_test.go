@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSupplyTracksIssuanceAndBurns(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	c, err := NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Block 1 claims its fee, block 2 only the subsidy, burning the fee
+	miner := bytes.Repeat([]byte{1}, 20)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := NewTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(500), nonce)
+		if err := tx.Sign(priv); err != nil {
+			t.Fatal(err)
+		}
+		if nonce == 0 {
+			mineTestBlock(t, c, miner, 1, tx)
+			continue
+		}
+		parent := c.HeaderByHeight(c.CurrentHeight())
+		txs := []*Transaction{NewCoinbaseTx(miner, GetSubsidy(2)), tx}
+		b := NewBlock(2, parent.Hash(), -1, parent.Target(), txs, 2)
+		if b.Header.StateRoot, b.Header.ReceiptsRoot, err = c.ComputeRoots(txs); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ImportTrustedBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fee := NewTx(from, nil, nil, 0).Fee()
+	if e := c.Emission(1); e.Burned.Sign() != 0 || e.Reward.Cmp(new(big.Int).Add(GetSubsidy(1), fee)) != 0 {
+		t.Fatalf("block 1 emission: %+v", e)
+	}
+	if e := c.Emission(2); e.Burned.Cmp(fee) != 0 {
+		t.Fatalf("block 2 burned %v, want the fee %v", e.Burned, fee)
+	}
+	if e := c.Emission(0); e.Reward.Int64() != 1000000 {
+		t.Fatalf("genesis emission %v, want the allocation", e.Reward)
+	}
+
+	sup, err := c.Supply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := new(big.Int).Add(GetSubsidy(1), GetSubsidy(2))
+	issued.Add(issued, big.NewInt(1000000))
+	if sup.Height != 2 || sup.Issued.Cmp(issued) != 0 || sup.Burned.Cmp(fee) != 0 {
+		t.Fatalf("supply %+v, want issued %v and burned %v", sup, issued, fee)
+	}
+	if net := new(big.Int).Sub(sup.Issued, sup.Burned); sup.TotalSupply.Cmp(net) != 0 {
+		t.Fatalf("total supply %v, issued minus burned %v", sup.TotalSupply, net)
+	}
+
+	// Reverting block 2 reverts its counters with the balances
+	c.mu.Lock()
+	err = c.revertBlockState(2)
+	c.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sup, _ := c.state.supply(); sup.Burned.Sign() != 0 {
+		t.Fatalf("burned %v after revert, want 0", sup.Burned)
+	}
+}
@@ -0,0 +1,6 @@
+package core
+
+import "poai/tracing"
+
+// tracer records import, verification and reorg spans.
+var tracer = tracing.Tracer("poai/core")
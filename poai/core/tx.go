@@ -4,24 +4,35 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Transaction types
+const (
+	TxTransfer     uint8 = 0 // plain value transfer
+	TxBridgeLock   uint8 = 1 // lock funds in the bridge escrow for minting on another chain
+	TxBridgeUnlock uint8 = 2 // release escrowed funds after a burn on another chain
 )
 
 // Transaction represents a value transfer on the PoAI blockchain
 type Transaction struct {
-	From      []byte   `json:"from"`      // Sender address (pubkey hash)
-	To        []byte   `json:"to"`        // Recipient address
-	Amount    *big.Int `json:"amount"`    // Value to transfer
-	Nonce     uint64   `json:"nonce"`     // Replay protection
-	GasLimit  uint64   `json:"gasLimit"`  // Fixed for now (21000)
-	GasPrice  *big.Int `json:"gasPrice"`  // For priority; stub
-	Signature []byte   `json:"signature"` // ECDSA signature
-	Hash      []byte   `json:"hash"`      // Cached hash
+	Type      uint8    `json:"type,omitempty"` // TxTransfer, TxBridgeLock, ...
+	Data      []byte   `json:"data,omitempty"` // type-specific payload
+	From      []byte   `json:"from"`           // Sender address (pubkey hash)
+	To        []byte   `json:"to"`             // Recipient address
+	Amount    *big.Int `json:"amount"`         // Value to transfer
+	Nonce     uint64   `json:"nonce"`          // Replay protection
+	GasLimit  uint64   `json:"gasLimit"`       // Fixed for now (21000)
+	GasPrice  *big.Int `json:"gasPrice"`       // For priority; stub
+	Signature []byte   `json:"signature"`      // ECDSA signature
+	Hash      []byte   `json:"hash"`           // Cached hash
+
+	verified []byte // hash||signature that already passed Verify
 }
 
 // NewCoinbaseTx creates a coinbase transaction for block subsidies
@@ -48,32 +59,24 @@ func NewTx(from, to []byte, amount *big.Int, nonce uint64) *Transaction {
 	}
 }
 
-// CalculateHash computes the transaction hash (keccak256 for EVM compatibility)
+// CalculateHash computes the transaction hash: keccak256 of the RLP list of
+// the signed fields
 func (tx *Transaction) CalculateHash() []byte {
-	// Create a deterministic representation for hashing
-	data := struct {
-		From     []byte   `json:"from"`
-		To       []byte   `json:"to"`
-		Amount   *big.Int `json:"amount"`
-		Nonce    uint64   `json:"nonce"`
-		GasLimit uint64   `json:"gasLimit"`
-		GasPrice *big.Int `json:"gasPrice"`
-	}{
+	data, err := rlp.EncodeToBytes(&txSigningFields{
+		Type:     tx.Type,
+		Data:     tx.Data,
 		From:     tx.From,
 		To:       tx.To,
 		Amount:   tx.Amount,
 		Nonce:    tx.Nonce,
 		GasLimit: tx.GasLimit,
 		GasPrice: tx.GasPrice,
-	}
-
-	// Serialize to JSON for consistent hashing
-	jsonData, err := json.Marshal(data)
+	})
 	if err != nil {
-		panic(fmt.Sprintf("Failed to marshal transaction: %v", err))
+		panic(fmt.Sprintf("Failed to encode transaction: %v", err))
 	}
 
-	return crypto.Keccak256(jsonData)
+	return crypto.Keccak256(data)
 }
 
 // Sign signs the transaction with the provided private key
@@ -100,6 +103,11 @@ func (tx *Transaction) Verify() error {
 	}
 
 	hash := tx.CalculateHash()
+	checked := append(append([]byte{}, hash...), tx.Signature...)
+	if bytes.Equal(tx.verified, checked) {
+		// Already recovered for these exact contents (e.g. parallel pre-verification)
+		return nil
+	}
 	pubKey, err := crypto.SigToPub(hash, tx.Signature)
 	if err != nil {
 		return fmt.Errorf("invalid signature: %v", err)
@@ -110,6 +118,7 @@ func (tx *Transaction) Verify() error {
 		return errors.New("signature does not match sender address")
 	}
 
+	tx.verified = checked
 	return nil
 }
 
@@ -129,16 +138,22 @@ func (tx *Transaction) String() string {
 		from, to, tx.Amount.String(), tx.Nonce)
 }
 
-// Encode serializes the transaction to JSON
-func (tx *Transaction) Encode() ([]byte, error) {
-	return json.Marshal(tx)
+// Fee returns the gas the sender pays, gas limit times gas price. It goes
+// to the miner through the coinbase.
+func (tx *Transaction) Fee() *big.Int {
+	if tx.IsCoinbase() || tx.GasPrice == nil {
+		return new(big.Int)
+	}
+	fee := new(big.Int).SetUint64(tx.GasLimit)
+	return fee.Mul(fee, tx.GasPrice)
 }
 
-// Decode deserializes the transaction from JSON
-func DecodeTransaction(data []byte) (*Transaction, error) {
-	var tx Transaction
-	if err := json.Unmarshal(data, &tx); err != nil {
-		return nil, fmt.Errorf("failed to decode transaction: %v", err)
+// Cost returns what the sender pays: the amount plus the fee. Bridge
+// unlocks pay only the fee; their amount comes from escrow.
+func (tx *Transaction) Cost() *big.Int {
+	cost := tx.Fee()
+	if tx.Type != TxBridgeUnlock && tx.Amount != nil {
+		cost.Add(cost, tx.Amount)
 	}
-	return &tx, nil
+	return cost
 }
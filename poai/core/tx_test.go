@@ -125,3 +125,28 @@ func TestGetSubsidy(t *testing.T) {
 
 	t.Logf("Subsidy calculation working correctly")
 }
+
+func TestCoinbaseClaimsFees(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	tx := NewTx(from, make([]byte, 20), big.NewInt(100), 0)
+	tx.GasPrice = big.NewInt(3)
+	if want := big.NewInt(3 * 21000); tx.Fee().Cmp(want) != 0 {
+		t.Fatalf("fee = %v, want %v", tx.Fee(), want)
+	}
+
+	reward := BlockReward(1, []*Transaction{tx})
+	if want := new(big.Int).Add(GetSubsidy(1), tx.Fee()); reward.Cmp(want) != 0 {
+		t.Fatalf("reward = %v, want %v", reward, want)
+	}
+	coinbase := NewCoinbaseTx(make([]byte, 20), reward)
+	block := &Block{Transactions: []*Transaction{coinbase, tx}}
+	block.Header.Height = 1
+	if err := validateCoinbase(block); err != nil {
+		t.Fatalf("coinbase claiming subsidy plus fees: %v", err)
+	}
+	coinbase.Amount = new(big.Int).Add(reward, big.NewInt(1))
+	if err := validateCoinbase(block); err == nil {
+		t.Fatal("coinbase claiming more than subsidy plus fees accepted")
+	}
+}
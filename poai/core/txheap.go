@@ -0,0 +1,106 @@
+package core
+
+import (
+	"container/heap"
+	"encoding/hex"
+	"math/big"
+	"sort"
+)
+
+// pricedTx is a mempool entry in the gas price priority queue.
+type pricedTx struct {
+	tx    *Transaction
+	seq   uint64 // arrival order, breaks price ties first-come first-served
+	index int    // position in the heap, maintained by txPriceHeap
+}
+
+// gasPrice returns the effective gas price of tx (zero if unset).
+func gasPrice(tx *Transaction) *big.Int {
+	if tx.GasPrice == nil {
+		return new(big.Int)
+	}
+	return tx.GasPrice
+}
+
+// higherPriority reports whether a should be mined before b.
+func higherPriority(a, b *pricedTx) bool {
+	if c := gasPrice(a.tx).Cmp(gasPrice(b.tx)); c != 0 {
+		return c > 0
+	}
+	return a.seq < b.seq
+}
+
+// txPriceHeap is a max-heap of transactions by effective gas price.
+type txPriceHeap []*pricedTx
+
+func (h txPriceHeap) Len() int           { return len(h) }
+func (h txPriceHeap) Less(i, j int) bool { return higherPriority(h[i], h[j]) }
+func (h txPriceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *txPriceHeap) Push(x interface{}) {
+	e := x.(*pricedTx)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *txPriceHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
+
+// sorted returns the entries from highest to lowest priority without
+// modifying the heap.
+func (h txPriceHeap) sorted() []*pricedTx {
+	out := append([]*pricedTx(nil), h...)
+	sort.Slice(out, func(i, j int) bool { return higherPriority(out[i], out[j]) })
+	return out
+}
+
+// selectByPrice returns up to max transactions, highest gas price first,
+// while keeping each sender's transactions in nonce order: a sender's next
+// transaction competes only once its predecessor has been selected.
+func selectByPrice(entries []*pricedTx, max int) []*Transaction {
+	bySender := make(map[string][]*pricedTx)
+	for _, e := range entries {
+		c := *e // the selection heap rewrites index; leave the pool's entries alone
+		from := hex.EncodeToString(e.tx.From)
+		bySender[from] = append(bySender[from], &c)
+	}
+	heads := make(txPriceHeap, 0, len(bySender))
+	for from, list := range bySender {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].tx.Nonce != list[j].tx.Nonce {
+				return list[i].tx.Nonce < list[j].tx.Nonce
+			}
+			return higherPriority(list[i], list[j])
+		})
+		bySender[from] = list
+		heads = append(heads, list[0])
+	}
+	heap.Init(&heads)
+
+	txs := make([]*Transaction, 0, max)
+	for heads.Len() > 0 && len(txs) < max {
+		best := heap.Pop(&heads).(*pricedTx)
+		txs = append(txs, best.tx)
+		from := hex.EncodeToString(best.tx.From)
+		rest := bySender[from][1:]
+		// Skip same-nonce replacements of the transaction just taken
+		for len(rest) > 0 && rest[0].tx.Nonce == best.tx.Nonce {
+			rest = rest[1:]
+		}
+		bySender[from] = rest
+		if len(rest) > 0 {
+			heap.Push(&heads, rest[0])
+		}
+	}
+	return txs
+}
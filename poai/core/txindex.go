@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"poai/core/storage"
+)
+
+// TxLocation is where a transaction sits in the canonical chain.
+type TxLocation struct {
+	TxHash    []byte   `json:"transactionHash"`
+	BlockHash [32]byte `json:"blockHash"`
+	Height    uint64   `json:"blockNumber"`
+	Index     uint32   `json:"transactionIndex"`
+}
+
+// Index keys. An address entry sorts by height and position, so a prefix
+// scan lists an account's history in chain order.
+//
+//	txindex:<txhash hex>                          -> blockhash || u64be height || u32be index
+//	addrtx:<address hex>:<u64be height><u32be index> -> txhash
+func txIndexKey(txHash []byte) []byte {
+	return []byte("txindex:" + hex.EncodeToString(txHash))
+}
+
+func addrTxPrefix(addr []byte) []byte {
+	return []byte("addrtx:" + hex.EncodeToString(addr) + ":")
+}
+
+func addrTxKey(addr []byte, height uint64, index uint32) []byte {
+	key := addrTxPrefix(addr)
+	key = binary.BigEndian.AppendUint64(key, height)
+	return binary.BigEndian.AppendUint32(key, index)
+}
+
+// txAddresses lists the accounts a transaction touches, sender first.
+func txAddresses(tx *Transaction) [][]byte {
+	if tx.IsCoinbase() || bytes.Equal(tx.From, tx.To) {
+		return [][]byte{tx.To}
+	}
+	return [][]byte{tx.From, tx.To}
+}
+
+// IndexBlockTxs records the block's transactions by hash and address.
+func (s *Store) IndexBlockTxs(block *Block) error {
+	hash := block.Hash()
+	height := block.Header.Height
+	return s.db.Update(func(txn storage.Txn) error {
+		for i, tx := range block.Transactions {
+			if len(tx.Hash) == 0 {
+				tx.Hash = tx.CalculateHash()
+			}
+			val := make([]byte, 0, 44)
+			val = append(val, hash[:]...)
+			val = binary.BigEndian.AppendUint64(val, height)
+			val = binary.BigEndian.AppendUint32(val, uint32(i))
+			if err := txn.Set(txIndexKey(tx.Hash), val); err != nil {
+				return err
+			}
+			for _, addr := range txAddresses(tx) {
+				if err := txn.Set(addrTxKey(addr, height, uint32(i)), tx.Hash); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// dropTxIndex deletes every entry of the transaction and address indexes.
+func (s *Store) dropTxIndex() error {
+	var keys [][]byte
+	err := s.db.View(func(txn storage.Txn) error {
+		for _, prefix := range []string{"txindex:", "addrtx:"} {
+			err := txn.Iterate([]byte(prefix), false, func(key, _ []byte) bool {
+				keys = append(keys, append([]byte{}, key...))
+				return true
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// UnindexBlockTxs removes the entries IndexBlockTxs wrote for block, when
+// it leaves the canonical chain.
+func (s *Store) UnindexBlockTxs(block *Block) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		for i, tx := range block.Transactions {
+			if len(tx.Hash) == 0 {
+				tx.Hash = tx.CalculateHash()
+			}
+			if err := txn.Delete(txIndexKey(tx.Hash)); err != nil {
+				return err
+			}
+			for _, addr := range txAddresses(tx) {
+				if err := txn.Delete(addrTxKey(addr, block.Header.Height, uint32(i))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetTxLocation looks up a transaction in the hash index.
+func (s *Store) GetTxLocation(txHash []byte) (*TxLocation, error) {
+	loc := &TxLocation{TxHash: txHash}
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(txIndexKey(txHash))
+		if err != nil {
+			return err
+		}
+		if len(val) != 44 {
+			return fmt.Errorf("corrupt tx index entry for %x", txHash)
+		}
+		copy(loc.BlockHash[:], val[:32])
+		loc.Height = binary.BigEndian.Uint64(val[32:40])
+		loc.Index = binary.BigEndian.Uint32(val[40:])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// AddressTxs returns up to limit transactions touching addr, newest first,
+// after skipping the newest offset.
+func (s *Store) AddressTxs(addr []byte, offset, limit int) ([]*TxLocation, error) {
+	prefix := addrTxPrefix(addr)
+	var out []*TxLocation
+	err := s.db.View(func(txn storage.Txn) error {
+		skipped := 0
+		return txn.Iterate(prefix, true, func(key, val []byte) bool {
+			if len(out) >= limit {
+				return false
+			}
+			if skipped < offset {
+				skipped++
+				return true
+			}
+			key = key[len(prefix):]
+			if len(key) == 12 {
+				out = append(out, &TxLocation{
+					TxHash: append([]byte{}, val...),
+					Height: binary.BigEndian.Uint64(key[:8]),
+					Index:  binary.BigEndian.Uint32(key[8:]),
+				})
+			}
+			return true
+		})
+	})
+	return out, err
+}
+
+// TransactionByHash returns a canonical transaction and its location from
+// the hash index.
+func (c *Chain) TransactionByHash(txHash []byte) (*Transaction, *TxLocation, error) {
+	loc, err := c.store.GetTxLocation(txHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("transaction %x not found: %w", txHash, err)
+	}
+	blk := c.BlockByHeight(loc.Height)
+	if blk == nil {
+		if blk, err = c.store.GetBlock(loc.Height); err != nil {
+			return nil, nil, fmt.Errorf("block %d of transaction %x unavailable: %w", loc.Height, txHash, err)
+		}
+	}
+	if blk.Hash() != loc.BlockHash || int(loc.Index) >= len(blk.Transactions) {
+		return nil, nil, fmt.Errorf("transaction %x is not in the canonical chain", txHash)
+	}
+	return blk.Transactions[loc.Index], loc, nil
+}
+
+// AddressTransactions lists the canonical transactions sent or received by
+// addr, newest first, paginated by offset and limit.
+func (c *Chain) AddressTransactions(addr []byte, offset, limit int) ([]*TxLocation, error) {
+	locs, err := c.store.AddressTxs(addr, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, loc := range locs {
+		if hash, err := c.store.GetCanonicalHash(loc.Height); err == nil {
+			loc.BlockHash = hash
+		}
+	}
+	return locs, nil
+}
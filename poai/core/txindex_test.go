@@ -0,0 +1,65 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTxIndex(t *testing.T) {
+	c := NewChain(t.TempDir(), 1000)
+	defer c.Close()
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	to := bytes.Repeat([]byte{7}, 20)
+	miner := bytes.Repeat([]byte{9}, 20)
+	c.state.SetBalance(from, big.NewInt(1_000_000))
+
+	tx := NewTx(from, to, big.NewInt(500), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	txs := []*Transaction{NewCoinbaseTx(miner, BlockReward(1, []*Transaction{tx})), tx}
+	parent := c.HeaderByHeight(0)
+	b := NewBlock(1, parent.Hash(), -1, parent.Target(), txs, 1)
+	b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(txs)
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	got, loc, err := c.TransactionByHash(tx.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Hash, tx.Hash) || loc.Height != 1 || loc.Index != 1 || loc.BlockHash != b.Hash() {
+		t.Fatalf("location: %+v", loc)
+	}
+	for _, addr := range [][]byte{from, to} {
+		hist, err := c.AddressTransactions(addr, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hist) != 1 || !bytes.Equal(hist[0].TxHash, tx.Hash) {
+			t.Fatalf("history of %x: %+v", addr, hist)
+		}
+	}
+	if hist, _ := c.AddressTransactions(miner, 0, 10); len(hist) != 1 || hist[0].Index != 0 {
+		t.Fatalf("coinbase history: %+v", hist)
+	}
+	if hist, _ := c.AddressTransactions(from, 1, 10); len(hist) != 0 {
+		t.Fatalf("offset past the end returned %d entries", len(hist))
+	}
+
+	// Reverting the block drops its entries
+	if err := c.revertBlockState(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.TransactionByHash(tx.Hash); err == nil {
+		t.Fatal("reverted transaction still indexed")
+	}
+	if hist, _ := c.AddressTransactions(to, 0, 10); len(hist) != 0 {
+		t.Fatalf("reverted history: %+v", hist)
+	}
+}
@@ -0,0 +1,394 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"poai/core/config"
+	"poai/core/storage"
+)
+
+// UndoEntry records the value a state key had before a block was applied.
+type UndoEntry struct {
+	Key     []byte `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Existed bool   `json:"existed"`
+}
+
+// touchedKeys lists every state key the transactions may modify, and the
+// supply counters every block updates.
+func touchedKeys(txs []*Transaction) [][]byte {
+	seen := make(map[string]bool)
+	keys := [][]byte{supplyIssuedKey, supplyBurnedKey}
+	add := func(k []byte) {
+		if !seen[string(k)] {
+			seen[string(k)] = true
+			keys = append(keys, k)
+		}
+	}
+	for _, tx := range txs {
+		add(append([]byte("balance:"), tx.To...))
+		if tx.IsCoinbase() {
+			continue
+		}
+		add(append([]byte("balance:"), tx.From...))
+		add(append([]byte("nonce:"), tx.From...))
+		if tx.Type == TxBridgeUnlock {
+			add(append([]byte("balance:"), BridgeEscrowAddress...))
+			add(bridgeUnlockKey(tx.Data))
+		}
+	}
+	return keys
+}
+
+// captureUndo reads the current values of all keys the transactions touch.
+func (s *State) captureUndo(txs []*Transaction) ([]UndoEntry, error) {
+	keys := touchedKeys(txs)
+	undo := make([]UndoEntry, 0, len(keys))
+	err := s.db.View(func(txn storage.Txn) error {
+		for _, k := range keys {
+			entry := UndoEntry{Key: k}
+			val, err := txn.Get(k)
+			switch err {
+			case nil:
+				entry.Value, entry.Existed = val, true
+			case storage.ErrNotFound:
+			default:
+				return err
+			}
+			undo = append(undo, entry)
+		}
+		return nil
+	})
+	return undo, err
+}
+
+// applyUndo restores the recorded values.
+func (s *State) applyUndo(undo []UndoEntry) error {
+	return s.db.Update(func(txn storage.Txn) error {
+		for _, e := range undo {
+			var err error
+			if e.Existed {
+				err = txn.Set(e.Key, e.Value)
+			} else {
+				err = txn.Delete(e.Key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func undoKey(height uint64) []byte {
+	return []byte("undo:" + strconv.FormatUint(height, 10))
+}
+
+// PutUndo stores the undo record for the block at height.
+func (s *Store) PutUndo(height uint64, undo []UndoEntry) error {
+	val, err := json.Marshal(undo)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn storage.Txn) error {
+		return txn.Set(undoKey(height), val)
+	})
+}
+
+// GetUndo loads the undo record for the block at height.
+func (s *Store) GetUndo(height uint64) ([]UndoEntry, error) {
+	var undo []UndoEntry
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(undoKey(height))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(val, &undo)
+	})
+	return undo, err
+}
+
+// stateHistoryKey holds the lowest height whose undo record may still be
+// stored; the ones below it were pruned.
+var stateHistoryKey = []byte("meta:statehistory")
+
+// ErrStateHistoryPruned is returned when reverting a block whose undo record
+// was pruned.
+var ErrStateHistoryPruned = errors.New("state history pruned")
+
+// StateHistoryStart returns the lowest height whose undo record has not
+// been pruned.
+func (s *Store) StateHistoryStart() (uint64, error) {
+	var height uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(stateHistoryKey)
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseUint(string(val), 10, 64)
+		return err
+	})
+	if err == storage.ErrNotFound {
+		return 0, nil
+	}
+	return height, err
+}
+
+// undoStartKey holds the first height whose block was stored with an undo
+// record. Blocks below it predate undo records and changed state no
+// reverting can restore.
+var undoStartKey = []byte("meta:undostart")
+
+// ErrUndoMissing is returned when reverting a block whose undo record should
+// exist but does not.
+var ErrUndoMissing = errors.New("undo record missing")
+
+// UndoStart returns the first height whose block was stored with an undo
+// record.
+func (s *Store) UndoStart() (uint64, error) {
+	var height uint64
+	err := s.db.View(func(txn storage.Txn) error {
+		val, err := txn.Get(undoStartKey)
+		if err != nil {
+			return err
+		}
+		height, err = strconv.ParseUint(string(val), 10, 64)
+		return err
+	})
+	if err == storage.ErrNotFound {
+		return 1, nil
+	}
+	return height, err
+}
+
+// migrateUndoStart records where undo records start: after the genesis
+// for a new database, after the tip for one written by a version that kept
+// none.
+func (s *Store) migrateUndoStart() error {
+	return s.db.Update(func(txn storage.Txn) error {
+		if _, err := txn.Get(undoStartKey); err != storage.ErrNotFound {
+			return err
+		}
+		start := uint64(1)
+		if val, err := txn.Get([]byte("chain:tip")); err == nil {
+			tip, err := strconv.ParseUint(string(val), 10, 64)
+			if err != nil {
+				return err
+			}
+			start = tip + 1
+		} else if err != storage.ErrNotFound {
+			return err
+		}
+		return txn.Set(undoStartKey, []byte(strconv.FormatUint(start, 10)))
+	})
+}
+
+// PruneUndo deletes the undo records below height.
+func (s *Store) PruneUndo(height uint64) error {
+	start, err := s.StateHistoryStart()
+	if err != nil || start >= height {
+		return err
+	}
+	wb := s.db.NewBatch()
+	defer wb.Cancel()
+	for h := start; h < height; h++ {
+		if err := wb.Delete(undoKey(h)); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set(stateHistoryKey, []byte(strconv.FormatUint(height, 10))); err != nil {
+		return err
+	}
+	return wb.Flush()
+}
+
+// pruneState drops the undo records of blocks more than StateHistory below
+// the head. Blocks above the finalized checkpoint keep theirs, since a
+// reorg may still revert them.
+func (c *Chain) pruneState() {
+	depth := config.StateHistory
+	if depth == 0 || c.head < depth {
+		return
+	}
+	below := c.head - depth + 1
+	if config.FinalityInterval() > 0 && below > c.finalized+1 {
+		below = c.finalized + 1
+	}
+	if err := c.store.PruneUndo(below); err != nil {
+		log.Printf("[STATE] Failed to prune state history below #%d: %v", below, err)
+	}
+}
+
+// validateCoinbase checks that a block has at most one coinbase, placed
+// first, paying no more than the subsidy for its height plus the block's
+// fees.
+func validateCoinbase(block *Block) error {
+	for i, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			continue
+		}
+		if i != 0 {
+			return fmt.Errorf("coinbase at index %d, must be first", i)
+		}
+		if reward := BlockReward(block.Header.Height, block.Transactions); tx.Amount == nil || tx.Amount.Cmp(reward) > 0 {
+			return fmt.Errorf("coinbase pays %v, subsidy plus fees is %v", tx.Amount, reward)
+		}
+	}
+	return nil
+}
+
+// checkTxRoot checks the header's TxRoot against the block's transactions
+// and sets MerkleRoot, which is not hashed, to match.
+func checkTxRoot(block *Block) error {
+	var root [32]byte
+	merkle := block.CalculateMerkleRoot()
+	copy(root[:], merkle)
+	if block.Header.TxRoot != root {
+		return fmt.Errorf("tx root mismatch: header %x, computed %x", block.Header.TxRoot[:8], root[:8])
+	}
+	block.MerkleRoot = merkle
+	return nil
+}
+
+// applyBlockState executes a block's transactions against state (coinbase
+// first), records an undo record so the block can be reverted on reorg and
+// checks the header's StateRoot and ReceiptsRoot against the results. The
+// header hash commits to both, so they are never filled in. On failure,
+// state is left exactly as it was before the call.
+func (c *Chain) applyBlockState(block *Block) error {
+	if err := validateCoinbase(block); err != nil {
+		return err
+	}
+	if err := CheckBlockLimits(block); err != nil {
+		return err
+	}
+	if err := checkTxRoot(block); err != nil {
+		return err
+	}
+	undo, err := c.state.captureUndo(block.Transactions)
+	if err != nil {
+		return fmt.Errorf("capture undo: %w", err)
+	}
+	receipts := make([]*Receipt, 0, len(block.Transactions))
+	var gasUsed uint64
+	for i, tx := range block.Transactions {
+		if err := c.state.ExecuteTransaction(tx); err != nil {
+			if rerr := c.state.applyUndo(undo); rerr != nil {
+				log.Printf("[STATE][ERROR] Failed to roll back partial block #%d: %v", block.Header.Height, rerr)
+			}
+			return fmt.Errorf("transaction %d execution failed: %w", i, err)
+		}
+		r := newReceipt(tx, gasUsed)
+		gasUsed = r.CumulativeGasUsed
+		receipts = append(receipts, r)
+	}
+	if err := c.state.recordEmission(blockEmission(block)); err != nil {
+		c.state.applyUndo(undo)
+		return fmt.Errorf("record emission: %w", err)
+	}
+	receiptsRoot := ReceiptsRoot(receipts)
+	if block.Header.ReceiptsRoot != receiptsRoot {
+		c.state.applyUndo(undo)
+		return fmt.Errorf("receipts root mismatch: header %x, computed %x", block.Header.ReceiptsRoot[:8], receiptsRoot[:8])
+	}
+	root, err := c.state.Root()
+	if err != nil {
+		c.state.applyUndo(undo)
+		return fmt.Errorf("compute state root: %w", err)
+	}
+	if block.Header.StateRoot != root {
+		c.state.applyUndo(undo)
+		return fmt.Errorf("state root mismatch: header %x, computed %x", block.Header.StateRoot[:8], root[:8])
+	}
+	if err := c.store.PutUndo(block.Header.Height, undo); err != nil {
+		c.state.applyUndo(undo)
+		return fmt.Errorf("persist undo: %w", err)
+	}
+	if err := c.store.PutReceipts(block, receipts); err != nil {
+		log.Printf("[STATE] Failed to store receipts for block #%d: %v", block.Header.Height, err)
+	}
+	if err := c.store.IndexBlockTxs(block); err != nil {
+		log.Printf("[STATE] Failed to index transactions of block #%d: %v", block.Header.Height, err)
+	}
+	if len(block.Transactions) > 0 {
+		c.Mempool.RemoveTransactions(block.Transactions)
+		if err := c.store.recordBridgeEvents(block); err != nil {
+			log.Printf("[BRIDGE] Failed to record lock events for block #%d: %v", block.Header.Height, err)
+		}
+	}
+	return nil
+}
+
+// ComputeRoots returns the state and receipts roots that result from
+// applying txs on top of the current head, without changing state. Miners
+// use it to fill in a new block's StateRoot and ReceiptsRoot.
+func (c *Chain) ComputeRoots(txs []*Transaction) (stateRoot, receiptsRoot [32]byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	undo, err := c.state.captureUndo(txs)
+	if err != nil {
+		return stateRoot, receiptsRoot, err
+	}
+	defer func() {
+		if err := c.state.applyUndo(undo); err != nil {
+			log.Printf("[STATE][ERROR] Failed to roll back state root preview: %v", err)
+		}
+	}()
+	receipts := make([]*Receipt, 0, len(txs))
+	var gasUsed uint64
+	for i, tx := range txs {
+		if err := c.state.ExecuteTransaction(tx); err != nil {
+			return stateRoot, receiptsRoot, fmt.Errorf("transaction %d execution failed: %w", i, err)
+		}
+		r := newReceipt(tx, gasUsed)
+		gasUsed = r.CumulativeGasUsed
+		receipts = append(receipts, r)
+	}
+	stateRoot, err = c.state.Root()
+	return stateRoot, ReceiptsRoot(receipts), err
+}
+
+// AccountProof returns a proof of addr's account against the current head's
+// StateRoot.
+func (c *Chain) AccountProof(addr []byte) (*AccountProof, [32]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snap, err := c.state.Snapshot(c.head)
+	if err != nil {
+		return nil, [32]byte{}, err
+	}
+	p, err := snap.AccountProof(addr)
+	return p, snap.Root(), err
+}
+
+// revertBlockState undoes the state changes of the canonical block at height
+// and drops its transactions from the tx index and its bridge lock events.
+func (c *Chain) revertBlockState(height uint64) error {
+	if blk := c.blockAt(height); blk != nil {
+		if err := c.store.UnindexBlockTxs(blk); err != nil {
+			log.Printf("[STATE] Failed to unindex transactions of block #%d: %v", height, err)
+		}
+		// A relayer must not mint for a lock that is no longer in the chain
+		if err := c.store.removeBridgeEvents(blk); err != nil {
+			return fmt.Errorf("block #%d: remove bridge lock events: %w", height, err)
+		}
+	}
+	undo, err := c.store.GetUndo(height)
+	if err == storage.ErrNotFound {
+		if start, serr := c.store.StateHistoryStart(); serr == nil && height < start {
+			return fmt.Errorf("block #%d: %w", height, ErrStateHistoryPruned)
+		}
+		if start, serr := c.store.UndoStart(); serr == nil && height < start {
+			return nil // the genesis, or a block from before undo records
+		}
+		return fmt.Errorf("block #%d: %w", height, ErrUndoMissing)
+	}
+	if err != nil {
+		return err
+	}
+	return c.state.applyUndo(undo)
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"poai/core/config"
+	"poai/core/storage"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestUndoRestoresState(t *testing.T) {
+	db, err := storage.OpenBadgerMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewState(db)
+
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	to := make([]byte, 20)
+	to[0] = 1
+	s.SetBalance(from, big.NewInt(100000))
+
+	tx := NewTx(from, to, big.NewInt(100), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	txs := []*Transaction{tx}
+	undo, err := s.captureUndo(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExecuteTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	if s.GetNonce(from) != 1 || s.GetBalance(to).Sign() == 0 {
+		t.Fatal("transaction did not apply")
+	}
+
+	if err := s.applyUndo(undo); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetBalance(from); got.Cmp(big.NewInt(100000)) != 0 {
+		t.Errorf("sender balance = %v, want 100000", got)
+	}
+	if s.GetNonce(from) != 0 {
+		t.Errorf("sender nonce = %d, want 0", s.GetNonce(from))
+	}
+	if s.GetBalance(to).Sign() != 0 {
+		t.Errorf("recipient balance = %v, want 0", s.GetBalance(to))
+	}
+}
+
+func TestPruneStateHistory(t *testing.T) {
+	defer func(depth, epochs uint64) {
+		config.StateHistory, config.FinalityEpochs = depth, epochs
+	}(config.StateHistory, config.FinalityEpochs)
+	config.StateHistory = 2
+	config.FinalityEpochs = 0
+
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	importCoinbaseBlocks(t, c, bytes.Repeat([]byte{9}, 20), 5)
+
+	for h := uint64(1); h <= 5; h++ {
+		_, err := c.store.GetUndo(h)
+		if kept := err == nil; kept != (h >= 4) {
+			t.Errorf("undo record #%d kept = %v", h, kept)
+		}
+	}
+	if err := c.revertBlockState(3); !errors.Is(err, ErrStateHistoryPruned) {
+		t.Fatalf("reverting a pruned block: %v", err)
+	}
+}
+
+func TestRevertNeedsUndoRecord(t *testing.T) {
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	importCoinbaseBlocks(t, c, bytes.Repeat([]byte{9}, 20), 2)
+
+	c.store.db.Update(func(txn storage.Txn) error { return txn.Delete(undoKey(2)) })
+	if err := c.revertBlockState(2); !errors.Is(err, ErrUndoMissing) {
+		t.Fatalf("reverting a block without its undo record: %v", err)
+	}
+
+	// Blocks stored before undo records existed changed state no record
+	// can restore, and are reverted as before
+	c.store.db.Update(func(txn storage.Txn) error { return txn.Delete(undoStartKey) })
+	if err := c.store.migrateUndoStart(); err != nil {
+		t.Fatal(err)
+	}
+	if start, err := c.store.UndoStart(); err != nil || start != 3 {
+		t.Fatalf("undo start %d (%v), want 3", start, err)
+	}
+	if err := c.revertBlockState(2); err != nil {
+		t.Fatal(err)
+	}
+}
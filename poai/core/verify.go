@@ -0,0 +1,290 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"runtime"
+	"sort"
+	"time"
+
+	"poai/core/config"
+	"poai/core/header"
+	"poai/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidBlock marks blocks that fail signature or PoAI verification, as
+// opposed to blocks that merely do not fit the local chain yet.
+var ErrInvalidBlock = errors.New("invalid block")
+
+// ErrProofUnavailable is returned by a ProofVerifier that cannot check a
+// block yet, e.g. while the corpus it needs is still being fetched. Such
+// blocks are refused without being marked invalid.
+var ErrProofUnavailable = errors.New("proof cannot be checked yet")
+
+// proofError classifies a ProofVerifier failure.
+func proofError(err error) error {
+	if errors.Is(err, ErrProofUnavailable) {
+		return err
+	}
+	return fmt.Errorf("%w: proof: %v", ErrInvalidBlock, err)
+}
+
+// ErrFutureBlock is returned for blocks stamped more than
+// config.MaxFutureBlockTimeSec ahead of the local clock. Such a block may
+// become valid later, so it does not mark the sender as misbehaving.
+var ErrFutureBlock = errors.New("block timestamp too far in the future")
+
+// ProofVerifier checks a block's PoAI work. It is optional; when set on the
+// chain it runs in ImportBlock and as part of batch pre-verification.
+type ProofVerifier func(*Block) error
+
+// MeetsTarget reports whether loss is at or below target. Targets are
+// compared as big.Int, never truncated to int64.
+func MeetsTarget(loss int64, target *big.Int) bool {
+	return target != nil && big.NewInt(loss).Cmp(target) <= 0
+}
+
+// VerifyHeaderLink checks that hdr extends parent and that its claimed loss
+// meets the target it commits to. It cannot replay the PoAI work itself;
+// that happens when the full block is imported.
+func VerifyHeaderLink(hdr, parent *header.Header) error {
+	if parent == nil {
+		return fmt.Errorf("missing parent header")
+	}
+	if hdr.Height != parent.Height+1 {
+		return fmt.Errorf("height %d does not follow parent %d", hdr.Height, parent.Height)
+	}
+	if hdr.ParentHash != parent.Hash() {
+		return fmt.Errorf("parent hash mismatch")
+	}
+	if !MeetsTarget(hdr.Lhat, hdr.Target()) {
+		return fmt.Errorf("loss %d does not meet target %v", hdr.Lhat, hdr.Target())
+	}
+	return nil
+}
+
+// VerifyHeaderTarget checks that hdr carries the target NextTarget
+// prescribes after parent, with r reading parent's ancestors. A header that
+// does not is ErrInvalidBlock; other errors mean the target could not be
+// computed from r.
+func VerifyHeaderTarget(r ChainReader, hdr, parent *header.Header) error {
+	want, err := NextTarget(r, parent)
+	if err != nil {
+		return err
+	}
+	if hdr.Bits != header.BigToCompact(want) {
+		return fmt.Errorf("%w: target %v, expected %v", ErrInvalidBlock, hdr.Target(), want)
+	}
+	return nil
+}
+
+// verifyWorkers returns the size of the batch verification pool.
+func (c *Chain) verifyWorkers() int {
+	if c.VerifyWorkers > 0 {
+		return c.VerifyWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// DefaultFlushEvery is how many imported blocks ImportBlocks buffers before
+// writing them to the database.
+const DefaultFlushEvery = 128
+
+func (c *Chain) flushEvery() int {
+	if c.FlushEvery > 0 {
+		return c.FlushEvery
+	}
+	return DefaultFlushEvery
+}
+
+// verifyPipeline verifies transaction signatures (and PoAI proofs, if a
+// verifier is configured) of blocks on a bounded worker pool, dispatching
+// them in order. Each block's result arrives on its own channel, so the
+// caller can apply block i while later blocks are still being checked.
+// Closing stop abandons blocks that have not been dispatched yet; their
+// channels never receive.
+func (c *Chain) verifyPipeline(blocks []*Block, stop <-chan struct{}) []chan error {
+	results := make([]chan error, len(blocks))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+	workers := c.verifyWorkers()
+	if workers > len(blocks) {
+		workers = len(blocks)
+	}
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- c.preverifyBlock(blocks[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range blocks {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// medianTimePast returns the median timestamp of the last
+// config.MedianTimeBlocks blocks of r up to and including height.
+func medianTimePast(r ChainReader, height uint64) time.Time {
+	times := make([]time.Time, 0, config.MedianTimeBlocks)
+	for i := 0; i < config.MedianTimeBlocks; i++ {
+		if hdr := r.HeaderByHeight(height); hdr != nil {
+			times = append(times, hdr.Timestamp)
+		}
+		if height == 0 {
+			break
+		}
+		height--
+	}
+	if len(times) == 0 {
+		return time.Time{}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times[len(times)/2]
+}
+
+// MedianTimePast returns the median timestamp of the canonical blocks
+// ending at height. A block on top of height must be stamped later.
+func (c *Chain) MedianTimePast(height uint64) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return medianTimePast(lockedReader{c}, height)
+}
+
+// checkTimestamp checks that a block extending the chain r is stamped
+// after the median time past of its parent and not too far in the future.
+func checkTimestamp(r ChainReader, h *header.Header) error {
+	limit := time.Now().Add(config.MaxFutureBlockTimeSec * time.Second)
+	if h.Timestamp.After(limit) {
+		return fmt.Errorf("%w: %s is %s ahead of local time", ErrFutureBlock,
+			h.Timestamp.UTC().Format(time.RFC3339), time.Until(h.Timestamp).Round(time.Second))
+	}
+	if mtp := medianTimePast(r, h.Height-1); !h.Timestamp.After(mtp) {
+		return fmt.Errorf("%w: timestamp %s not after median time past %s", ErrInvalidBlock,
+			h.Timestamp.UTC().Format(time.RFC3339Nano), mtp.UTC().Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// CheckBlockLimits checks a block against the consensus gas and size
+// limits.
+func CheckBlockLimits(b *Block) error {
+	var gas uint64
+	for _, tx := range b.Transactions {
+		if tx.GasLimit > config.MaxBlockGas-gas {
+			return fmt.Errorf("block gas exceeds limit %d", config.MaxBlockGas)
+		}
+		gas += tx.GasLimit
+	}
+	data, err := b.Encode()
+	if err != nil {
+		return fmt.Errorf("encode block: %v", err)
+	}
+	if len(data) > config.MaxBlockSize {
+		return fmt.Errorf("block is %d bytes, limit %d", len(data), config.MaxBlockSize)
+	}
+	return nil
+}
+
+func (c *Chain) preverifyBlock(b *Block) error {
+	if err := CheckBlockLimits(b); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlock, err)
+	}
+	for i, tx := range b.Transactions {
+		if err := tx.Verify(); err != nil {
+			return fmt.Errorf("%w: transaction %d: %v", ErrInvalidBlock, i, err)
+		}
+	}
+	if c.VerifyProof != nil {
+		if err := c.VerifyProof(b); err != nil {
+			return proofError(err)
+		}
+	}
+	return nil
+}
+
+// ImportBlocks imports a batch of blocks (e.g. a sync response). Header
+// links within the batch are checked sequentially first, which is cheap;
+// signatures and proofs of the linked prefix are then verified in parallel
+// while blocks are applied in order as soon as their own checks pass. It
+// returns the number of blocks imported and the first hard error
+// encountered. Database writes are buffered and written every FlushEvery
+// blocks.
+func (c *Chain) ImportBlocks(blocks []*Block) (imported int, err error) {
+	hashes, err := c.ImportBlocksContext(context.Background(), blocks)
+	return len(hashes), err
+}
+
+// ImportBlocksContext is ImportBlocks with a context for tracing; the batch
+// is traced as a child of the span in ctx. It returns the hashes of the
+// blocks imported, which need not be a prefix of blocks: one that fails to
+// import is skipped.
+func (c *Chain) ImportBlocksContext(ctx context.Context, blocks []*Block) (imported [][32]byte, err error) {
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	ctx, span := tracer.Start(ctx, "chain.ImportBlocks", trace.WithAttributes(
+		attribute.Int64("batch.from", int64(blocks[0].Header.Height)),
+		attribute.Int("batch.size", len(blocks)),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("batch.imported", len(imported)))
+		tracing.End(span, err)
+	}()
+
+	// Blocks after a broken link cannot be verified, so only the prefix is used
+	linked := len(blocks)
+	var linkErr error
+	for i := 1; i < len(blocks); i++ {
+		if err := VerifyHeaderLink(&blocks[i].Header, &blocks[i-1].Header); err != nil {
+			linked = i
+			linkErr = fmt.Errorf("%w: block #%d: %v", ErrInvalidBlock, blocks[i].Header.Height, err)
+			break
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	results := c.verifyPipeline(blocks[:linked], stop)
+
+	c.store.BeginBatch()
+	defer func() {
+		if ferr := c.store.EndBatch(); ferr != nil && err == nil {
+			err = fmt.Errorf("write imported blocks: %w", ferr)
+		}
+	}()
+	for i, blk := range blocks[:linked] {
+		if err := <-results[i]; err != nil {
+			// Later blocks build on this one, so stop here
+			return imported, fmt.Errorf("block #%d failed verification: %w", blk.Header.Height, err)
+		}
+		if err := c.importBlockInternal(ctx, blk, true); err != nil { // verified above
+			log.Printf("[SYNC] Failed to import block #%d: %v", blk.Header.Height, err)
+			continue
+		}
+		imported = append(imported, blk.Hash())
+		if len(imported)%c.flushEvery() == 0 {
+			if err := c.store.FlushBatch(); err != nil {
+				return imported, fmt.Errorf("write imported blocks: %w", err)
+			}
+		}
+	}
+	return imported, linkErr
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"poai/core/header"
+)
+
+// VerifyChainOptions selects what Store.VerifyChain checks.
+type VerifyChainOptions struct {
+	From, To uint64 // blocks to walk; To 0 means the tip
+	// VerifyProof, if set, replays the PoAI work of a ProofSample share
+	// of the blocks, picked by block hash so a rerun checks the same ones
+	VerifyProof ProofVerifier
+	ProofSample float64
+	Progress    func(height uint64) // called after each verified block
+}
+
+// VerifyChainResult counts what Store.VerifyChain checked.
+type VerifyChainResult struct {
+	From, To      uint64
+	Blocks        int
+	Proofs        int
+	SkippedTarget int // blocks whose retarget window is pruned
+}
+
+// ChainInconsistency is the first problem Store.VerifyChain finds.
+type ChainInconsistency struct {
+	Height uint64
+	Err    error
+}
+
+func (e *ChainInconsistency) Error() string {
+	return fmt.Sprintf("block #%d: %v", e.Height, e.Err)
+}
+
+func (e *ChainInconsistency) Unwrap() error { return e.Err }
+
+// storeReader is a ChainReader over the stored canonical chain.
+type storeReader struct {
+	s   *Store
+	tip uint64
+}
+
+func (r storeReader) HeaderByHeight(height uint64) *header.Header {
+	blk, err := r.s.GetBlock(height)
+	if err != nil {
+		return nil
+	}
+	return &blk.Header
+}
+
+func (r storeReader) Height() uint64 { return r.tip }
+
+// VerifyChain walks the stored canonical blocks From..To and checks that
+// each is stored under its own hash, links to its parent, commits to its
+// transactions, carries the target the difficulty rule prescribes and
+// has a loss meeting it, replaying the AI work of sampled blocks if
+// asked. It does not execute transactions. The first problem is returned
+// as a *ChainInconsistency, with the blocks checked before it.
+func (s *Store) VerifyChain(opts VerifyChainOptions) (*VerifyChainResult, error) {
+	tip, err := s.GetTipHeight()
+	if err != nil {
+		return nil, fmt.Errorf("read tip: %w", err)
+	}
+	to := opts.To
+	if to == 0 {
+		to = tip
+	}
+	if to > tip || opts.From > to {
+		return nil, fmt.Errorf("cannot verify blocks #%d-#%d of a chain at #%d", opts.From, to, tip)
+	}
+	from := opts.From
+	for from < to {
+		if _, err := s.GetCanonicalHash(from); err == nil {
+			break
+		}
+		from++ // pruned
+	}
+	res := &VerifyChainResult{From: from, To: to}
+	reader := storeReader{s: s, tip: tip}
+	sampled := func(hash [32]byte) bool {
+		return opts.ProofSample >= 1 || float64(binary.BigEndian.Uint64(hash[:8])) < opts.ProofSample*math.MaxUint64
+	}
+
+	var parent *Block
+	if from > 0 {
+		if parent, err = s.GetBlock(from - 1); err != nil {
+			parent = nil // the walk starts at the lowest stored block
+		}
+	}
+	for h := from; h <= to; h++ {
+		fail := func(format string, args ...interface{}) (*VerifyChainResult, error) {
+			return res, &ChainInconsistency{Height: h, Err: fmt.Errorf(format, args...)}
+		}
+		hash, err := s.GetCanonicalHash(h)
+		if err != nil {
+			return fail("missing from the database: %v", err)
+		}
+		blk, err := s.GetBlockByHash(hash)
+		if err != nil {
+			return fail("stored block %x unreadable: %v", hash[:8], err)
+		}
+		if got := blk.Hash(); got != hash {
+			return fail("stored under %x but hashes to %x", hash[:8], got[:8])
+		}
+		if blk.Header.Height != h {
+			return fail("header says height %d", blk.Header.Height)
+		}
+		if err := checkTxRoot(blk); err != nil {
+			return fail("%v", err)
+		}
+		if err := CheckBlockLimits(blk); err != nil {
+			return fail("%v", err)
+		}
+		if parent != nil {
+			if err := validateCoinbase(blk); err != nil {
+				return fail("%v", err)
+			}
+			if err := VerifyHeaderLink(&blk.Header, &parent.Header); err != nil {
+				return fail("%v", err)
+			}
+			want, err := NextTarget(reader, &parent.Header)
+			switch {
+			case err != nil && from > 0:
+				res.SkippedTarget++
+			case err != nil:
+				return fail("difficulty: %v", err)
+			case blk.Header.Bits != header.BigToCompact(want):
+				return fail("carries target %v, difficulty rule gives %v", blk.Header.Target(), want)
+			}
+		}
+		if opts.VerifyProof != nil && h > 0 && sampled(hash) {
+			if err := opts.VerifyProof(blk); err != nil {
+				return fail("proof: %v", err)
+			}
+			res.Proofs++
+		}
+		res.Blocks++
+		parent = blk
+		if opts.Progress != nil {
+			opts.Progress(h)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyChainFindsTamperedBlock(t *testing.T) {
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	miner := bytes.Repeat([]byte{1}, 20)
+	for i := uint64(1); i <= 3; i++ {
+		mineTestBlock(t, c, miner, i)
+	}
+
+	replayed := 0
+	res, err := c.store.VerifyChain(VerifyChainOptions{
+		VerifyProof: func(*Block) error { replayed++; return nil },
+		ProofSample: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 4 || res.Proofs != 3 || replayed != 3 {
+		t.Fatalf("clean chain: %+v, %d replayed", res, replayed)
+	}
+
+	// Inflate the coinbase of block #2 behind its header's back
+	blk, err := c.store.GetBlock(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk.Transactions[0].Amount = new(big.Int).Add(blk.Transactions[0].Amount, big.NewInt(1))
+	if err := c.store.PutBlock(2, blk); err != nil {
+		t.Fatal(err)
+	}
+	res, err = c.store.VerifyChain(VerifyChainOptions{})
+	var bad *ChainInconsistency
+	if !errors.As(err, &bad) || bad.Height != 2 || res.Blocks != 2 {
+		t.Fatalf("tampered chain: %v, %+v", err, res)
+	}
+}
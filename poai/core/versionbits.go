@@ -0,0 +1,191 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"poai/core/header"
+)
+
+// Version bits: a header's Version signals readiness for soft forks, one
+// bit per deployment, in the style of Bitcoin's BIP9. Only versions whose
+// top three bits are VersionBitsTopBits signal.
+const (
+	VersionBitsTopBits uint32 = 0x20000000
+	VersionBitsTopMask uint32 = 0xe0000000
+	VersionBitsMaxBit         = 28 // highest bit below the top bits
+)
+
+// Deployment is a soft fork activated by miner signalling. Each window of
+// Window blocks starting at a multiple of Window is counted on its own;
+// once Threshold blocks of one window signal, the deployment locks in and
+// becomes active a window later. It is signalled for from StartHeight on
+// and fails if it has not locked in by TimeoutHeight.
+type Deployment struct {
+	Name          string `json:"name"`
+	Bit           uint8  `json:"bit"`
+	StartHeight   uint64 `json:"startHeight"`
+	TimeoutHeight uint64 `json:"timeoutHeight"`
+	Window        uint64 `json:"window"`
+	Threshold     uint64 `json:"threshold"`
+}
+
+// Signals reports whether version signals for d.
+func (d *Deployment) Signals(version uint32) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits && version&(1<<d.Bit) != 0
+}
+
+// testDummy exercises the signalling machinery on test networks, as
+// Bitcoin's deployment of the same name does; it changes no rule.
+func testDummy(window, threshold uint64) Deployment {
+	return Deployment{Name: "testdummy", Bit: 28, TimeoutHeight: math.MaxUint64, Window: window, Threshold: threshold}
+}
+
+// Deployments are the soft forks this node tallies and signals for, set at
+// startup from the network preset.
+var Deployments = []Deployment{testDummy(144, 108)}
+
+// ThresholdState is where a deployment stands for a block.
+type ThresholdState string
+
+const (
+	ThresholdDefined  ThresholdState = "defined"  // before StartHeight
+	ThresholdStarted  ThresholdState = "started"  // signalling counted
+	ThresholdLockedIn ThresholdState = "lockedIn" // active from the next window
+	ThresholdActive   ThresholdState = "active"
+	ThresholdFailed   ThresholdState = "failed" // timed out before locking in
+)
+
+// versionBitsKey identifies a deployment's state for the window following
+// the block with the given hash.
+type versionBitsKey struct {
+	deployment string
+	prev       [32]byte
+}
+
+// countSignals returns how many of the blocks from..to (inclusive) signal
+// for d.
+func countSignals(r ChainReader, d *Deployment, from, to uint64) (uint64, error) {
+	var n uint64
+	for h := from; h <= to; h++ {
+		hdr := r.HeaderByHeight(h)
+		if hdr == nil {
+			return 0, fmt.Errorf("header #%d not available", h)
+		}
+		if d.Signals(hdr.Version) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// deploymentState returns the state of d for the block at height, whose
+// ancestors r reads. States are cached per window by the hash of the block
+// before it, so only windows since the last call are counted; a pruned
+// node that never counted a window it no longer has reports an error.
+func (c *Chain) deploymentState(r ChainReader, d *Deployment, height uint64) (ThresholdState, error) {
+	if d.Window == 0 {
+		return "", fmt.Errorf("deployment %s has no window", d.Name)
+	}
+	c.vbMu.Lock()
+	defer c.vbMu.Unlock()
+
+	// Walk back to the newest window whose state is known
+	type window struct {
+		start uint64
+		key   versionBitsKey
+	}
+	var pending []window
+	state := ThresholdDefined
+	for start := height - height%d.Window; start > 0; start -= d.Window {
+		prev := r.HeaderByHeight(start - 1)
+		if prev == nil {
+			return "", fmt.Errorf("header #%d not available", start-1)
+		}
+		key := versionBitsKey{deployment: d.Name, prev: prev.Hash()}
+		if s, ok := c.vbCache[key]; ok {
+			state = s
+			break
+		}
+		pending = append(pending, window{start, key})
+	}
+
+	// and step forward from it
+	for i := len(pending) - 1; i >= 0; i-- {
+		w := pending[i]
+		switch state {
+		case ThresholdDefined:
+			if w.start >= d.TimeoutHeight {
+				state = ThresholdFailed
+			} else if w.start >= d.StartHeight {
+				state = ThresholdStarted
+			}
+		case ThresholdStarted:
+			n, err := countSignals(r, d, w.start-d.Window, w.start-1)
+			if err != nil {
+				return "", err
+			}
+			if n >= d.Threshold {
+				state = ThresholdLockedIn
+			} else if w.start >= d.TimeoutHeight {
+				state = ThresholdFailed
+			}
+		case ThresholdLockedIn:
+			state = ThresholdActive
+		}
+		c.vbCache[w.key] = state
+	}
+	return state, nil
+}
+
+// BlockVersion returns the version of a block extending parent: the top
+// bits and the bit of every deployment in its signalling or locked-in
+// window.
+func (c *Chain) BlockVersion(parent *header.Header) uint32 {
+	version := VersionBitsTopBits
+	for i := range Deployments {
+		d := &Deployments[i]
+		state, err := c.deploymentState(c, d, parent.Height+1)
+		if err == nil && (state == ThresholdStarted || state == ThresholdLockedIn) {
+			version |= 1 << d.Bit
+		}
+	}
+	return version
+}
+
+// DeploymentStatus is a deployment's state at the head and the signalling
+// in the window the next block belongs to.
+type DeploymentStatus struct {
+	Deployment
+	State       ThresholdState `json:"state"`
+	WindowStart uint64         `json:"windowStart"`
+	Elapsed     uint64         `json:"elapsed"`  // blocks of the window mined so far
+	Signaled    uint64         `json:"signaled"` // how many of them signal
+	Percent     float64        `json:"percent"`  // Signaled / Elapsed, 0 to 100
+	// Possible reports whether Threshold can still be reached this window
+	Possible bool `json:"possible"`
+}
+
+// DeploymentStatuses returns the status of every deployment for the block
+// after the head.
+func (c *Chain) DeploymentStatuses() ([]DeploymentStatus, error) {
+	next := c.Height() + 1
+	statuses := make([]DeploymentStatus, 0, len(Deployments))
+	for _, d := range Deployments {
+		state, err := c.deploymentState(c, &d, next)
+		if err != nil {
+			return nil, fmt.Errorf("deployment %s: %v", d.Name, err)
+		}
+		st := DeploymentStatus{Deployment: d, State: state, WindowStart: next - next%d.Window}
+		st.Elapsed = next - st.WindowStart
+		if st.Elapsed > 0 {
+			if st.Signaled, err = countSignals(c, &d, st.WindowStart, next-1); err != nil {
+				return nil, fmt.Errorf("deployment %s: %v", d.Name, err)
+			}
+			st.Percent = float64(st.Signaled) * 100 / float64(st.Elapsed)
+		}
+		st.Possible = state == ThresholdStarted && st.Signaled+d.Window-st.Elapsed >= d.Threshold
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"testing"
+
+	"poai/core/header"
+)
+
+func TestDeploymentStateMachine(t *testing.T) {
+	bit1 := Deployment{Name: "one", Bit: 1, StartHeight: 4, TimeoutHeight: 16, Window: 4, Threshold: 3}
+	bit2 := Deployment{Name: "two", Bit: 2, StartHeight: 4, TimeoutHeight: 12, Window: 4, Threshold: 3}
+	// Blocks 4-7 signal bit 1 twice, blocks 8-11 three times; block 6 sets
+	// the bit without the top bits, which does not count
+	versions := map[uint64]uint32{
+		4: VersionBitsTopBits | 1<<1, 5: VersionBitsTopBits | 1<<1, 6: 1 << 1,
+		8: VersionBitsTopBits | 1<<1, 9: VersionBitsTopBits | 1<<1, 11: VersionBitsTopBits | 1<<1,
+	}
+	chain := &mockChain{headers: make(map[uint64]*header.Header), height: 19}
+	for h := uint64(0); h <= chain.height; h++ {
+		chain.headers[h] = &header.Header{Height: h, Nonce: h, Version: versions[h]}
+	}
+
+	c := &Chain{vbCache: make(map[versionBitsKey]ThresholdState)}
+	want := []struct {
+		height   uint64
+		one, two ThresholdState
+	}{
+		{3, ThresholdDefined, ThresholdDefined},
+		{4, ThresholdStarted, ThresholdStarted},
+		{8, ThresholdStarted, ThresholdStarted},
+		{12, ThresholdLockedIn, ThresholdFailed},
+		{16, ThresholdActive, ThresholdFailed},
+		{19, ThresholdActive, ThresholdFailed},
+	}
+	for _, w := range want {
+		if got, err := c.deploymentState(chain, &bit1, w.height); err != nil || got != w.one {
+			t.Errorf("%s at #%d: %s (%v), want %s", bit1.Name, w.height, got, err, w.one)
+		}
+		if got, err := c.deploymentState(chain, &bit2, w.height); err != nil || got != w.two {
+			t.Errorf("%s at #%d: %s (%v), want %s", bit2.Name, w.height, got, err, w.two)
+		}
+	}
+
+	// Cached states answer without the headers they were counted from
+	delete(chain.headers, 9)
+	if got, err := c.deploymentState(chain, &bit1, 17); err != nil || got != ThresholdActive {
+		t.Fatalf("cached state at #17: %s (%v)", got, err)
+	}
+	if _, err := (&Chain{vbCache: make(map[versionBitsKey]ThresholdState)}).deploymentState(chain, &bit1, 17); err == nil {
+		t.Fatal("state counted over a missing header")
+	}
+}
+
+func TestMinersSignalStartedDeployments(t *testing.T) {
+	defer func(d []Deployment) { Deployments = d }(Deployments)
+	Deployments = []Deployment{{Name: "soon", Bit: 5, TimeoutHeight: 1000, Window: 4, Threshold: 3}}
+	c, err := NewMemoryChain(DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := uint64(1); i <= 5; i++ {
+		parent := c.HeaderByHeight(c.CurrentHeight())
+		txs := []*Transaction{NewCoinbaseTx(make([]byte, 20), BlockReward(i, nil))}
+		b := NewBlock(i, parent.Hash(), -1, parent.Target(), txs, i)
+		b.Header.Version = c.BlockVersion(parent)
+		if b.Header.StateRoot, b.Header.ReceiptsRoot, err = c.ComputeRoots(txs); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ImportTrustedBlock(b); err != nil {
+			t.Fatal(err)
+		}
+		if signals := Deployments[0].Signals(b.Header.Version); signals != (i >= 4) {
+			t.Fatalf("block #%d version %#x signals: %v", i, b.Header.Version, signals)
+		}
+	}
+
+	statuses, err := c.DeploymentStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := statuses[0]
+	if st.State != ThresholdStarted || st.WindowStart != 4 || st.Elapsed != 2 || st.Signaled != 2 || st.Percent != 100 || !st.Possible {
+		t.Fatalf("status %+v", st)
+	}
+}
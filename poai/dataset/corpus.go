@@ -0,0 +1,356 @@
+package dataset
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+
+	"poai/core/config"
+)
+
+// Corpus file names inside a corpus directory.
+const (
+	IndexFile = "Σ.idx"
+	DataFile  = "Σ.bin"
+)
+
+// indexMagic starts Σ.idx; a little-endian uint64 record count follows,
+// then one (offset, size, hash) entry of indexEntrySize bytes per record.
+var indexMagic = []byte("POAIIDX1")
+
+const (
+	indexEntrySize = 8 + 8 + 32
+	gcmNonceSize   = 12
+)
+
+// Corpus is the encrypted dataset: Σ.bin holds each record as a 12-byte
+// AES-GCM nonce followed by the ciphertext, and Σ.idx locates every record
+// and commits to its SHA3-256. Records are sealed under the key of epoch 0
+// (keyschedule.EpochKey), which binds a corpus to the chain's genesis.
+type Corpus struct {
+	f     *os.File
+	data  []byte // Σ.bin, memory mapped
+	index []IndexEntry
+	key   [32]byte
+}
+
+// ReadIndex parses a Σ.idx file.
+func ReadIndex(r io.Reader) ([]IndexEntry, error) {
+	var head [16]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("read index header: %v", err)
+	}
+	if !bytes.Equal(head[:8], indexMagic) {
+		return nil, errors.New("not a corpus index")
+	}
+	count := binary.LittleEndian.Uint64(head[8:])
+	entries := make([]IndexEntry, 0, min(count, 1<<20))
+	var buf [indexEntrySize]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, fmt.Errorf("read index entry %d: %v", i, err)
+		}
+		e := IndexEntry{
+			Offset: int64(binary.LittleEndian.Uint64(buf[0:8])),
+			Size:   int64(binary.LittleEndian.Uint64(buf[8:16])),
+		}
+		copy(e.Hash[:], buf[16:])
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// WriteIndex writes entries in the Σ.idx format.
+func WriteIndex(w io.Writer, entries []IndexEntry) error {
+	var head [16]byte
+	copy(head[:8], indexMagic)
+	binary.LittleEndian.PutUint64(head[8:], uint64(len(entries)))
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+	var buf [indexEntrySize]byte
+	for _, e := range entries {
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(e.Offset))
+		binary.LittleEndian.PutUint64(buf[8:16], uint64(e.Size))
+		copy(buf[16:], e.Hash[:])
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SealCorpus encrypts records under key and writes Σ.bin and Σ.idx to dir.
+func SealCorpus(dir string, records [][]byte, key [32]byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	entries := make([]IndexEntry, len(records))
+	for i, rec := range records {
+		nonce := make([]byte, gcmNonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed, err := aesgcmEncrypt(key[:], rec, nonce)
+		if err != nil {
+			return err
+		}
+		stored := append(nonce, sealed...)
+		entries[i] = IndexEntry{Offset: int64(data.Len()), Size: int64(len(stored)), Hash: sha3.Sum256(stored)}
+		data.Write(stored)
+	}
+	if err := os.WriteFile(filepath.Join(dir, DataFile), data.Bytes(), 0o644); err != nil {
+		return err
+	}
+	var idx bytes.Buffer
+	if err := WriteIndex(&idx, entries); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, IndexFile), idx.Bytes(), 0o644)
+}
+
+// OpenCorpus maps the corpus in dir. key is the epoch 0 key the records
+// were sealed under.
+func OpenCorpus(dir string, key [32]byte) (*Corpus, error) {
+	idx, err := os.Open(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+	index, err := ReadIndex(idx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", IndexFile, err)
+	}
+	if len(index) == 0 {
+		return nil, fmt.Errorf("%s: empty corpus", IndexFile)
+	}
+
+	f, err := os.Open(filepath.Join(dir, DataFile))
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for i, e := range index {
+		if e.Offset < 0 || e.Size <= gcmNonceSize || e.Offset+e.Size > st.Size() {
+			f.Close()
+			return nil, fmt.Errorf("%s: record %d lies outside %s", IndexFile, i, DataFile)
+		}
+	}
+	data, err := mmapSlice(f, 0, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("map %s: %v", DataFile, err)
+	}
+	return &Corpus{f: f, data: data, index: index, key: key}, nil
+}
+
+// Close unmaps the corpus.
+func (c *Corpus) Close() error {
+	munmap(c.data)
+	return c.f.Close()
+}
+
+// Len returns the number of records.
+func (c *Corpus) Len() uint64 { return uint64(len(c.index)) }
+
+// Index returns the index table.
+func (c *Corpus) Index() []IndexEntry { return c.index }
+
+// Record returns record i, checked against its index hash and decrypted.
+func (c *Corpus) Record(i uint64) ([]byte, error) {
+	if i >= c.Len() {
+		return nil, fmt.Errorf("record %d out of range (%d records)", i, c.Len())
+	}
+	e := c.index[i]
+	stored := c.data[e.Offset : e.Offset+e.Size]
+	if !verifySHA256(stored, e.Hash[:]) {
+		return nil, fmt.Errorf("record %d does not match its index hash", i)
+	}
+	plain, err := aesgcmDecrypt(c.key[:], stored[gcmNonceSize:], stored[:gcmNonceSize])
+	if err != nil {
+		return nil, fmt.Errorf("decrypt record %d: %v", i, err)
+	}
+	return plain, nil
+}
+
+var (
+	corpusMu sync.RWMutex
+	corpus   *Corpus
+)
+
+// SetCorpus installs the corpus mining and validation read from, and its
+// index table. nil returns to procedural quizzes only.
+func SetCorpus(c *Corpus) {
+	corpusMu.Lock()
+	defer corpusMu.Unlock()
+	corpus = c
+	if c == nil {
+		SetIndexTable(nil)
+		config.CorpusSize = 0
+		return
+	}
+	SetIndexTable(c.index)
+	config.CorpusSize = c.Len()
+}
+
+// ErrNoCorpus is returned for blocks that use corpus records when no
+// corpus is loaded.
+var ErrNoCorpus = errors.New("no corpus loaded (start with --corpus)")
+
+// Indexes selects the config.BatchSize records for the block on parent:
+// sha3(parent || counter) reduced modulo the corpus size. It returns nil
+// without a corpus.
+func Indexes(parent [32]byte) []uint64 {
+	n := uint64(len(indexTable))
+	if n == 0 || config.BatchSize <= 0 {
+		return nil
+	}
+	out := make([]uint64, config.BatchSize)
+	var buf [40]byte
+	copy(buf[:32], parent[:])
+	for i := range out {
+		binary.LittleEndian.PutUint64(buf[32:], uint64(i))
+		h := sha3.Sum256(buf[:])
+		out[i] = binary.LittleEndian.Uint64(h[:8]) % n
+	}
+	return out
+}
+
+// Context returns the decrypted records at indices as the prompt context.
+func Context(indices []uint64) (string, error) {
+	if len(indices) == 0 {
+		return "", nil
+	}
+	corpusMu.RLock()
+	c := corpus
+	corpusMu.RUnlock()
+	if c == nil {
+		return "", ErrNoCorpus
+	}
+	var b strings.Builder
+	b.WriteString("Context:\n")
+	for _, i := range indices {
+		rec, err := c.Record(i)
+		if err != nil {
+			return "", err
+		}
+		b.Write(bytes.TrimSpace(rec))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// IndexHash is the SHA3-256 of an encoded Σ.idx, the value a chain
+// commits to with --corpus-hash.
+func IndexHash(indexData []byte) [32]byte {
+	return sha3.Sum256(indexData)
+}
+
+// EncodeIndex returns the corpus index in the Σ.idx format.
+func (c *Corpus) EncodeIndex() []byte {
+	var buf bytes.Buffer
+	WriteIndex(&buf, c.index)
+	return buf.Bytes()
+}
+
+// Stored returns record i as stored, still encrypted, for serving to peers.
+func (c *Corpus) Stored(i uint64) ([]byte, error) {
+	if i >= c.Len() {
+		return nil, fmt.Errorf("record %d out of range (%d records)", i, c.Len())
+	}
+	e := c.index[i]
+	return c.data[e.Offset : e.Offset+e.Size], nil
+}
+
+// Assembler rebuilds a corpus directory from records fetched out of order,
+// checking each against the index.
+type Assembler struct {
+	f     *os.File
+	index []IndexEntry
+}
+
+// NewAssembler writes indexData as Σ.idx in dir and opens Σ.bin for
+// filling in, keeping records already present.
+func NewAssembler(dir string, indexData []byte) (*Assembler, error) {
+	index, err := ReadIndex(bytes.NewReader(indexData))
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	for _, e := range index {
+		if e.Offset < 0 || e.Size <= gcmNonceSize {
+			return nil, fmt.Errorf("corrupt index entry at offset %d", e.Offset)
+		}
+		size = max(size, e.Offset+e.Size)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, IndexFile), indexData, 0o644); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, DataFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Assembler{f: f, index: index}, nil
+}
+
+// Len returns the number of records in the index.
+func (a *Assembler) Len() uint64 { return uint64(len(a.index)) }
+
+// Missing returns the records that are absent or fail their index hash.
+func (a *Assembler) Missing() ([]uint64, error) {
+	var missing []uint64
+	for i, e := range a.index {
+		buf := make([]byte, e.Size)
+		if _, err := a.f.ReadAt(buf, e.Offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		if !verifySHA256(buf, e.Hash[:]) {
+			missing = append(missing, uint64(i))
+		}
+	}
+	return missing, nil
+}
+
+// Put stores record i as fetched from a peer if it matches the index.
+func (a *Assembler) Put(i uint64, stored []byte) error {
+	if i >= a.Len() {
+		return fmt.Errorf("record %d out of range (%d records)", i, a.Len())
+	}
+	e := a.index[i]
+	if int64(len(stored)) != e.Size || !verifySHA256(stored, e.Hash[:]) {
+		return fmt.Errorf("record %d does not match its index hash", i)
+	}
+	_, err := a.f.WriteAt(stored, e.Offset)
+	return err
+}
+
+// Close flushes and closes Σ.bin.
+func (a *Assembler) Close() error {
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,131 @@
+package dataset
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCorpusRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := [32]byte{7}
+	records := [][]byte{[]byte("The sky is blue."), []byte("Water boils at 100 degrees."), []byte("Cats purr.")}
+	if err := SealCorpus(dir, records, key); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenCorpus(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetCorpus(c)
+	defer func() {
+		SetCorpus(nil)
+		c.Close()
+	}()
+
+	parent := [32]byte{1, 2, 3}
+	idx := Indexes(parent)
+	if len(idx) == 0 || !slices.Equal(idx, Indexes(parent)) {
+		t.Fatalf("Indexes not deterministic: %v", idx)
+	}
+	text, err := Context(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range idx {
+		if !strings.Contains(text, string(records[i])) {
+			t.Fatalf("context %q lacks record %d", text, i)
+		}
+	}
+	if got := ParseQuizPrompt(text + QuizPrompt([]string{"What is 1 + 1?"})); len(got) != 1 {
+		t.Fatalf("quiz not recovered behind context: %v", got)
+	}
+
+	// A wrong key must not decrypt
+	wrong, err := OpenCorpus(dir, [32]byte{8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrong.Close()
+	if _, err := wrong.Record(0); err == nil {
+		t.Fatal("record decrypted with the wrong key")
+	}
+}
+
+func TestCorpusRejectsTamperedRecord(t *testing.T) {
+	dir := t.TempDir()
+	key := [32]byte{7}
+	if err := SealCorpus(dir, [][]byte{[]byte("one"), []byte("two")}, key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, DataFile)
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 1
+	os.WriteFile(path, data, 0o644)
+
+	c, err := OpenCorpus(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Record(0); err != nil {
+		t.Fatalf("untouched record: %v", err)
+	}
+	if _, err := c.Record(1); err == nil || !strings.Contains(err.Error(), "index hash") {
+		t.Fatalf("tampered record: %v", err)
+	}
+}
+
+func TestAssemblerRebuildsCorpus(t *testing.T) {
+	src := t.TempDir()
+	key := [32]byte{7}
+	records := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	if err := SealCorpus(src, records, key); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenCorpus(src, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dst := t.TempDir()
+	a, err := NewAssembler(dst, c.EncodeIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing, _ := a.Missing(); len(missing) != len(records) {
+		t.Fatalf("fresh assembler missing %v", missing)
+	}
+	stored, _ := c.Stored(1)
+	if err := a.Put(0, stored); err == nil {
+		t.Fatal("record accepted at the wrong index")
+	}
+	for i := uint64(2); i < 3; i-- {
+		stored, _ := c.Stored(i)
+		if err := a.Put(i, stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if missing, _ := a.Missing(); len(missing) != 0 {
+		t.Fatalf("still missing %v", missing)
+	}
+	a.Close()
+
+	rebuilt, err := OpenCorpus(dst, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rebuilt.Close()
+	if IndexHash(rebuilt.EncodeIndex()) != IndexHash(c.EncodeIndex()) {
+		t.Fatal("index hash changed")
+	}
+	for i, want := range records {
+		got, err := rebuilt.Record(uint64(i))
+		if err != nil || string(got) != string(want) {
+			t.Fatalf("record %d: %q, %v", i, got, err)
+		}
+	}
+}
@@ -22,3 +22,15 @@ func aesgcmDecrypt(key, cipherText, nonce []byte) ([]byte, error) {
 	}
 	return plain, nil
 }
+
+func aesgcmEncrypt(key, plain, nonce []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nil, nonce, plain, nil), nil
+}
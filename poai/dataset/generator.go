@@ -1,15 +1,92 @@
 package dataset
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"math/big"
 	"math/rand"
+	"strings"
 )
 
-// ProceduralQuiz generates deterministic quizzes based on block height and nonce
-// This ensures each nonce produces unique, verifiable input to the LLM
-func ProceduralQuiz(blockHeight uint64, nonce uint64) []string {
-	// Create a deterministic seed from block height and nonce
-	seed := int64(blockHeight) + int64(nonce)
+// fruits are the words of the alphabetical-order questions, sorted.
+var fruits = []string{"apple", "banana", "cherry", "date", "elderberry"}
+
+// conversions are the unit conversion questions: how many small units make
+// one big unit.
+var conversions = []struct {
+	big, small string
+	factor     int
+}{
+	{"meters", "centimeters", 100},
+	{"kilometers", "meters", 1000},
+	{"hours", "minutes", 60},
+	{"minutes", "seconds", 60},
+	{"kilograms", "grams", 1000},
+	{"days", "hours", 24},
+}
+
+// names and items fill the reading comprehension questions.
+var (
+	names = []string{"Ana", "Ben", "Chen", "Dara", "Eli", "Fatima", "Goran", "Hana"}
+	items = []string{"apples", "pears", "books", "coins", "marbles", "stamps"}
+)
+
+// MaxTier is the hardest quiz difficulty tier.
+const MaxTier = 3
+
+// familiesPerTier is how many question families each tier draws from:
+// tier 0 the basic four, tier 1 adds multi-step arithmetic and unit
+// conversion, tier 2 and up add reading comprehension and sequences.
+var familiesPerTier = [MaxTier + 1]int{4, 6, 8, 8}
+
+// DifficultyTier maps a block's loss target to a quiz tier: one tier per
+// two decades the target sits below LossScale, so harder chains pose harder
+// quizzes. Targets at or above LossScale are tier 0.
+func DifficultyTier(target *big.Int) int {
+	if target == nil || target.Sign() <= 0 {
+		return MaxTier
+	}
+	decades := 0
+	limit, ten := big.NewInt(LossScale/10), big.NewInt(10)
+	for t := new(big.Int).Set(target); t.Cmp(limit) < 0 && decades < 2*MaxTier; t.Mul(t, ten) {
+		decades++
+	}
+	return decades / 2
+}
+
+// QuizSeed derives the quiz seed from the parent block hash, the block's
+// transaction root, version, height and nonce. Including the parent hash
+// means no quiz for a block can be known, let alone answered, before its
+// parent exists; including the transaction root (and with it the coinbase)
+// and version means the work cannot be reused for a block paying someone
+// else or carrying other transactions.
+func QuizSeed(parent, txRoot [32]byte, version uint32, blockHeight, nonce uint64) int64 {
+	var buf [84]byte
+	copy(buf[:32], parent[:])
+	copy(buf[32:64], txRoot[:])
+	binary.LittleEndian.PutUint32(buf[64:68], version)
+	binary.LittleEndian.PutUint64(buf[68:76], blockHeight)
+	binary.LittleEndian.PutUint64(buf[76:], nonce)
+	h := sha256.Sum256(buf[:])
+	return int64(binary.LittleEndian.Uint64(h[:8]))
+}
+
+// ProceduralQuiz generates deterministic quizzes for a block on parent at
+// blockHeight with the given transaction root and version (see QuizSeed).
+// Each nonce produces unique, verifiable input to the LLM; tier (see
+// DifficultyTier) widens the question families and number ranges.
+func ProceduralQuiz(parent, txRoot [32]byte, version uint32, blockHeight uint64, nonce uint64, tier int) []string {
+	if tier < 0 {
+		tier = 0
+	} else if tier > MaxTier {
+		tier = MaxTier
+	}
+	scale := 1 // number ranges grow tenfold per tier
+	for i := 0; i < tier; i++ {
+		scale *= 10
+	}
+	seed := QuizSeed(parent, txRoot, version, blockHeight, nonce)
 	rng := rand.New(rand.NewSource(seed))
 
 	// Generate 3-5 quiz questions per block
@@ -22,27 +99,88 @@ func ProceduralQuiz(blockHeight uint64, nonce uint64) []string {
 		qRng := rand.New(rand.NewSource(questionSeed))
 
 		// Generate different types of questions
-		questionType := qRng.Intn(4)
+		questionType := qRng.Intn(familiesPerTier[tier])
 
 		switch questionType {
 		case 0: // Math addition
-			x := 1 + qRng.Intn(1000)
-			y := 1 + qRng.Intn(1000)
+			x := 1 + qRng.Intn(1000*scale)
+			y := 1 + qRng.Intn(1000*scale)
 			quizzes[i] = fmt.Sprintf("What is %d + %d?", x, y)
 		case 1: // Math multiplication
-			x := 1 + qRng.Intn(50)
-			y := 1 + qRng.Intn(50)
+			x := 1 + qRng.Intn(50*(tier+1))
+			y := 1 + qRng.Intn(50*(tier+1))
 			quizzes[i] = fmt.Sprintf("What is %d × %d?", x, y)
 		case 2: // Pattern completion
-			start := 1 + qRng.Intn(10)
-			step := 1 + qRng.Intn(5)
+			start := 1 + qRng.Intn(10*scale)
+			step := 1 + qRng.Intn(5*scale)
 			quizzes[i] = fmt.Sprintf("Complete the pattern: %d, %d, %d, ?", start, start+step, start+2*step)
 		case 3: // Logic puzzle
-			items := []string{"apple", "banana", "cherry", "date", "elderberry"}
-			idx := qRng.Intn(len(items))
-			quizzes[i] = fmt.Sprintf("What fruit comes after %s in alphabetical order?", items[idx])
+			idx := qRng.Intn(len(fruits) - 1) // the last fruit has no successor
+			quizzes[i] = fmt.Sprintf("What fruit comes after %s in alphabetical order?", fruits[idx])
+		case 4: // Multi-step arithmetic
+			a := 1 + qRng.Intn(20*scale)
+			b := 1 + qRng.Intn(20*scale)
+			c := 2 + qRng.Intn(8)
+			d := qRng.Intn((a + b) * c)
+			quizzes[i] = fmt.Sprintf("What is (%d + %d) × %d - %d?", a, b, c, d)
+		case 5: // Unit conversion
+			conv := conversions[qRng.Intn(len(conversions))]
+			n := 1 + qRng.Intn(10*scale)
+			quizzes[i] = fmt.Sprintf("How many %s are in %d %s?", conv.small, n, conv.big)
+		case 6: // Reading comprehension
+			name := names[qRng.Intn(len(names))]
+			perm := qRng.Perm(len(items))
+			first, second := items[perm[0]], items[perm[1]]
+			n1 := 2 + qRng.Intn(20*scale)
+			n2 := 2 + qRng.Intn(20*scale)
+			given, asked := first, first
+			if qRng.Intn(2) == 1 {
+				given = second
+			}
+			if qRng.Intn(2) == 1 {
+				asked = second
+			}
+			gave := 1 + qRng.Intn(min(n1, n2)-1)
+			quizzes[i] = fmt.Sprintf("%s has %d %s and %d %s. %s gives away %d %s. How many %s does %s have now?",
+				name, n1, first, n2, second, name, gave, given, asked, name)
+		case 7: // Sequence reasoning: geometric, or each term the sum of the two before
+			a := 1 + qRng.Intn(5*scale)
+			if qRng.Intn(2) == 0 {
+				r := 2 + qRng.Intn(2)
+				quizzes[i] = fmt.Sprintf("Complete the sequence: %d, %d, %d, %d, ?", a, a*r, a*r*r, a*r*r*r)
+			} else {
+				b := a + 1 + qRng.Intn(5*scale)
+				quizzes[i] = fmt.Sprintf("Complete the sequence: %d, %d, %d, %d, ?", a, b, a+b, a+2*b)
+			}
 		}
 	}
 
 	return quizzes
 }
+
+// QuizPrompt builds the LLM prompt from a set of quizzes. Miners and
+// validators must both use it so replayed inference sees identical input.
+func QuizPrompt(quizzes []string) string {
+	if len(quizzes) == 0 {
+		return ""
+	}
+	prompt := "Please answer these questions:\n"
+	for _, quiz := range quizzes {
+		prompt += quiz + "\n"
+	}
+	return prompt + "Answers:\n"
+}
+
+// ParseQuizPrompt recovers the quizzes from a prompt built by QuizPrompt,
+// with or without corpus context in front.
+func ParseQuizPrompt(prompt string) []string {
+	_, body, ok := strings.Cut(prompt, "Please answer these questions:\n")
+	if !ok {
+		return nil
+	}
+	body, ok = strings.CutSuffix(body, "Answers:\n")
+	if !ok {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+}
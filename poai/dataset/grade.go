@@ -0,0 +1,193 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LossScale is the fixed-point unit of a quiz score: a question answered
+// wrong costs LossScale, a right one 0. The block loss is
+//
+//	Lhat = Grade(quizzes, output)*LossScale + tiebreak
+//
+// with tiebreak in [0, LossScale), so any target below LossScale accepts
+// only fully correct answers and the tiebreak sets the odds among them.
+const LossScale = 1_000_000
+
+// DefaultTarget accepts any fully correct answer sheet.
+const DefaultTarget = LossScale - 1
+
+var (
+	additionRe = regexp.MustCompile(`^What is (\d+) \+ (\d+)\?$`)
+	productRe  = regexp.MustCompile(`^What is (\d+) × (\d+)\?$`)
+	patternRe  = regexp.MustCompile(`^Complete the pattern: (\d+), (\d+), (\d+), \?$`)
+	fruitRe    = regexp.MustCompile(`^What fruit comes after (\w+) in alphabetical order\?$`)
+	multiRe    = regexp.MustCompile(`^What is \((\d+) \+ (\d+)\) × (\d+) - (\d+)\?$`)
+	unitRe     = regexp.MustCompile(`^How many (\w+) are in (\d+) (\w+)\?$`)
+	readingRe  = regexp.MustCompile(`^(\w+) has (\d+) (\w+) and (\d+) (\w+)\. (\w+) gives away (\d+) (\w+)\. How many (\w+) does (\w+) have now\?$`)
+	sequenceRe = regexp.MustCompile(`^Complete the sequence: (\d+), (\d+), (\d+), (\d+), \?$`)
+
+	integerRe = regexp.MustCompile(`-?\d+`)
+	wordRe    = regexp.MustCompile(`[A-Za-z]+`)
+	// numberingRe strips list markers such as "1.", "2)" or "-".
+	numberingRe = regexp.MustCompile(`^\s*(?:\d+[.):]|[-*])\s*`)
+)
+
+// Solve answers a question produced by ProceduralQuiz. It is the answer key
+// Grade scores against; ok is false for text it did not generate.
+func Solve(question string) (answer string, numeric bool, ok bool) {
+	atoi := func(s string) int { n, _ := strconv.Atoi(s); return n }
+	if m := additionRe.FindStringSubmatch(question); m != nil {
+		return strconv.Itoa(atoi(m[1]) + atoi(m[2])), true, true
+	}
+	if m := productRe.FindStringSubmatch(question); m != nil {
+		return strconv.Itoa(atoi(m[1]) * atoi(m[2])), true, true
+	}
+	if m := patternRe.FindStringSubmatch(question); m != nil {
+		a, b, c := atoi(m[1]), atoi(m[2]), atoi(m[3])
+		if b-a != c-b {
+			return "", false, false
+		}
+		return strconv.Itoa(c + (c - b)), true, true
+	}
+	if m := fruitRe.FindStringSubmatch(question); m != nil {
+		for i, f := range fruits[:len(fruits)-1] {
+			if f == m[1] {
+				return fruits[i+1], false, true
+			}
+		}
+	}
+	if m := multiRe.FindStringSubmatch(question); m != nil {
+		return strconv.Itoa((atoi(m[1])+atoi(m[2]))*atoi(m[3]) - atoi(m[4])), true, true
+	}
+	if m := unitRe.FindStringSubmatch(question); m != nil {
+		for _, c := range conversions {
+			if c.small == m[1] && c.big == m[3] {
+				return strconv.Itoa(atoi(m[2]) * c.factor), true, true
+			}
+		}
+	}
+	if m := readingRe.FindStringSubmatch(question); m != nil {
+		have := map[string]int{m[3]: atoi(m[2]), m[5]: atoi(m[4])}
+		if _, ok := have[m[8]]; !ok || m[3] == m[5] {
+			return "", false, false
+		}
+		have[m[8]] -= atoi(m[7])
+		if n, ok := have[m[9]]; ok {
+			return strconv.Itoa(n), true, true
+		}
+	}
+	if m := sequenceRe.FindStringSubmatch(question); m != nil {
+		a, b, c, d := atoi(m[1]), atoi(m[2]), atoi(m[3]), atoi(m[4])
+		switch {
+		case a > 0 && b%a == 0 && b*(b/a) == c && c*(b/a) == d:
+			return strconv.Itoa(d * (b / a)), true, true
+		case a+b == c && b+c == d:
+			return strconv.Itoa(c + d), true, true
+		}
+	}
+	return "", false, false
+}
+
+// Grade scores the model's output against the quiz, returning the mean loss
+// per question in [0, LossScale]. The output is read one answer per
+// non-empty line, in question order, after any list marker:
+//
+//   - numeric questions take the line's last integer g and cost
+//     min(LossScale, |g-want|*LossScale / max(1, want)), rounded down;
+//   - word questions take the line's last word, compared case-insensitively,
+//     and cost 0 or LossScale;
+//   - a missing or unparsable answer costs LossScale.
+//
+// The mean is rounded down. Everything is integer arithmetic so every node
+// computes the same score.
+func Grade(quizzes []string, output string) int64 {
+	if len(quizzes) == 0 {
+		return LossScale
+	}
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(numberingRe.ReplaceAllString(line, "")); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	var total int64
+	for i, q := range quizzes {
+		if i >= len(lines) {
+			total += LossScale
+			continue
+		}
+		total += gradeAnswer(q, lines[i])
+	}
+	return total / int64(len(quizzes))
+}
+
+// gradeAnswer scores one answer line.
+func gradeAnswer(question, line string) int64 {
+	want, numeric, ok := Solve(question)
+	if !ok {
+		return LossScale
+	}
+	if !numeric {
+		words := wordRe.FindAllString(line, -1)
+		if len(words) == 0 || !strings.EqualFold(words[len(words)-1], want) {
+			return LossScale
+		}
+		return 0
+	}
+	nums := integerRe.FindAllString(line, -1)
+	if len(nums) == 0 {
+		return LossScale
+	}
+	got, err := strconv.ParseInt(nums[len(nums)-1], 10, 64)
+	if err != nil {
+		return LossScale
+	}
+	w, _ := strconv.ParseInt(want, 10, 64)
+	diff := got - w
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff < 0 || diff > LossScale { // overflow, or wrong by more than 100%
+		return LossScale
+	}
+	denom := w
+	if denom < 1 {
+		denom = 1
+	}
+	if loss := diff * LossScale / denom; loss < LossScale {
+		return loss
+	}
+	return LossScale
+}
+
+// Loss is the consensus loss of a block whose quiz prompt the model
+// answered with output; lower is better. See LossScale.
+func Loss(quizzes []string, prompt, output string) int64 {
+	h := sha256.Sum256([]byte(prompt + output))
+	tiebreak := int64(binary.LittleEndian.Uint64(h[:8]) % LossScale)
+	return Grade(quizzes, output)*LossScale + tiebreak
+}
+
+// AnswerSheet formats correct answers the way Grade reads them. wrong
+// reports, per question, whether to answer it incorrectly instead.
+func AnswerSheet(quizzes []string, wrong func(i int) bool) string {
+	var b strings.Builder
+	for i, q := range quizzes {
+		answer, numeric, _ := Solve(q)
+		if wrong != nil && wrong(i) {
+			if numeric {
+				n, _ := strconv.Atoi(answer)
+				answer = strconv.Itoa(n + 1)
+			} else {
+				answer = "unknown"
+			}
+		}
+		fmt.Fprintf(&b, "%d. %s\n", i+1, answer)
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package dataset
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestGrade(t *testing.T) {
+	quiz := []string{
+		"What is 12 + 30?",
+		"What is 6 × 7?",
+		"Complete the pattern: 2, 5, 8, ?",
+		"What fruit comes after banana in alphabetical order?",
+	}
+	cases := []struct {
+		name   string
+		output string
+		want   int64
+	}{
+		{"all right", "1. 42\n2) The answer is 42\n- 11\n4. Cherry.\n", 0},
+		{"one wrong word", "42\n42\n11\ndate\n", LossScale / 4},
+		{"off by a fraction", "1. 21\n42\n11\ncherry\n", LossScale / 2 / 4},
+		{"missing answers", "42\n", 3 * LossScale / 4},
+		{"garbage", "I don't know", LossScale},
+	}
+	for _, c := range cases {
+		if got := Grade(quiz, c.output); got != c.want {
+			t.Errorf("%s: Grade = %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	// Consensus loss: perfect sheets stay below the default target
+	prompt := QuizPrompt(quiz)
+	if loss := Loss(quiz, prompt, AnswerSheet(quiz, nil)); loss > DefaultTarget {
+		t.Errorf("perfect answers lost %d > %d", loss, DefaultTarget)
+	}
+	if loss := Loss(quiz, prompt, "garbage"); loss < LossScale*LossScale {
+		t.Errorf("garbage answers lost only %d", loss)
+	}
+}
+
+func TestSolveGeneratedQuizzes(t *testing.T) {
+	for nonce := uint64(0); nonce < 400; nonce++ {
+		tier := int(nonce % (MaxTier + 1))
+		quizzes := ProceduralQuiz([32]byte{1}, [32]byte{}, 0, 7, nonce, tier)
+		for _, q := range quizzes {
+			if _, _, ok := Solve(q); !ok {
+				t.Fatalf("tier %d: no answer for generated question %q", tier, q)
+			}
+		}
+		other := QuizPrompt(ProceduralQuiz([32]byte{2}, [32]byte{}, 0, 7, nonce, tier))
+		if other == QuizPrompt(quizzes) {
+			t.Fatalf("nonce %d: quiz does not depend on the parent hash", nonce)
+		}
+		if got := ParseQuizPrompt(QuizPrompt(quizzes)); len(got) != len(quizzes) {
+			t.Fatalf("ParseQuizPrompt recovered %d of %d questions", len(got), len(quizzes))
+		}
+	}
+}
+
+func TestSolveFamilies(t *testing.T) {
+	cases := map[string]string{
+		"What is (3 + 4) × 5 - 6?":              "29",
+		"How many centimeters are in 7 meters?": "700",
+		"Ana has 9 apples and 4 pears. Ana gives away 2 apples. How many apples does Ana have now?": "7",
+		"Ana has 9 apples and 4 pears. Ana gives away 2 apples. How many pears does Ana have now?":  "4",
+		"Complete the sequence: 3, 6, 12, 24, ?":                                                    "48",
+		"Complete the sequence: 2, 5, 7, 12, ?":                                                     "19",
+	}
+	for q, want := range cases {
+		if got, _, ok := Solve(q); !ok || got != want {
+			t.Errorf("Solve(%q) = %q, %v; want %q", q, got, ok, want)
+		}
+	}
+}
+
+func TestDifficultyTier(t *testing.T) {
+	for target, want := range map[int64]int{
+		LossScale * 5: 0, DefaultTarget: 0, 500: 1, 5: 2, 0: MaxTier, -1: MaxTier,
+	} {
+		if got := DifficultyTier(big.NewInt(target)); got != want {
+			t.Errorf("DifficultyTier(%d) = %d, want %d", target, got, want)
+		}
+	}
+}
@@ -12,3 +12,9 @@ func mmapSlice(f *os.File, off int64, size int64) ([]byte, error) {
 	}
 	return data, nil
 }
+
+func munmap(data []byte) {
+	if data != nil {
+		syscall.Munmap(data)
+	}
+}
@@ -0,0 +1,8 @@
+package inference
+
+// Engine runs deterministic inference: the same prompt and seed must always
+// produce the same output. *LLM runs the model in-process; remote.Client
+// farms the work out to inference workers.
+type Engine interface {
+	Infer(prompt string, seed int) (string, error)
+}
@@ -3,11 +3,7 @@
 
 package inference
 
-import (
-	"crypto/sha256"
-	"fmt"
-	"os"
-)
+import "os"
 
 func init() {
 	// Disable llama.cpp debug logs to prevent log file creation
@@ -21,14 +17,7 @@ func NewLLM(modelPath string, gpuLayers int) (*LLM, error) {
 	return &LLM{}, nil
 }
 
-// Infer runs a stub inference that returns a deterministic hash-based response
+// Infer runs the stub inference (see Stub).
 func (l *LLM) Infer(prompt string, seed int) (string, error) {
-	if prompt == "" {
-		return "", fmt.Errorf("empty prompt")
-	}
-
-	// Create a deterministic response based on prompt and seed
-	h := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", prompt, seed)))
-	response := fmt.Sprintf("stub_response_%x", h[:8])
-	return response, nil
+	return Stub{}.Infer(prompt, seed)
 }
@@ -0,0 +1,38 @@
+package inference
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// HashModel returns the hex SHA-256 of the model file at path.
+func HashModel(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash model %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyModel checks that the model file at path hashes to want, a hex
+// SHA-256 as committed in the chain config.
+func VerifyModel(path, want string) error {
+	want = strings.ToLower(strings.TrimPrefix(want, "0x"))
+	got, err := HashModel(path)
+	if err != nil {
+		return fmt.Errorf("cannot verify model: %v", err)
+	}
+	if got != want {
+		return fmt.Errorf("model %s has SHA-256 %s, chain is committed to %s", path, got, want)
+	}
+	return nil
+}
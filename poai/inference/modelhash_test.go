@@ -0,0 +1,27 @@
+package inference
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const sum = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got, err := HashModel(path); err != nil || got != sum {
+		t.Fatalf("HashModel = %s, %v; want %s", got, err, sum)
+	}
+	if err := VerifyModel(path, "0x"+sum); err != nil {
+		t.Fatalf("matching model rejected: %v", err)
+	}
+	if err := VerifyModel(path, sum[:63]+"0"); err == nil {
+		t.Fatal("mismatched model accepted")
+	}
+	if err := VerifyModel(path+".missing", sum); err == nil {
+		t.Fatal("missing model accepted")
+	}
+}
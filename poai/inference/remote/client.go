@@ -0,0 +1,232 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultTimeout bounds one remote inference.
+	DefaultTimeout = 2 * time.Minute
+	// minBackoff and maxBackoff bound how long a failing worker is skipped.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// backend is one remote worker.
+type backend struct {
+	addr     string
+	conn     *grpc.ClientConn
+	inflight atomic.Int64
+
+	mu        sync.Mutex
+	failures  int       // consecutive
+	downUntil time.Time // skipped while other workers are healthy
+	checked   bool      // model checked since the last failure
+}
+
+// due returns when the worker may be used again after failing.
+func (b *backend) due() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.downUntil
+}
+
+// failed backs the worker off exponentially.
+func (b *backend) failed() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	backoff := maxBackoff
+	if b.failures < 6 {
+		backoff = minBackoff << b.failures
+	}
+	b.failures++
+	b.downUntil = time.Now().Add(backoff)
+	b.checked = false // it may have restarted with another model
+	return backoff
+}
+
+func (b *backend) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures > 0 {
+		log.Printf("[INFER] Worker %s recovered", b.addr)
+	}
+	b.failures = 0
+	b.downUntil = time.Time{}
+}
+
+// Client balances inference across remote workers. Each request goes to the
+// healthy worker with the fewest requests in flight; a worker that fails is
+// backed off and the request retried on the next one. Before a worker gets
+// work, and again after it fails, it must report the model the chain is
+// committed to.
+type Client struct {
+	backends    []*backend
+	timeout     time.Duration
+	modelSHA256 string
+}
+
+// Config says how a Client reaches its workers.
+type Config struct {
+	Timeout     time.Duration // bounds each inference (0 = DefaultTimeout)
+	ModelSHA256 string        // hex SHA-256 every worker must report ("" = not checked)
+
+	// CAFile holds the PEM CA worker certificates are checked against.
+	// Without it workers are reached in plaintext, which only those on
+	// this machine may be. CertFile and KeyFile are the node's client
+	// certificate for workers that require one.
+	CAFile, CertFile, KeyFile string
+}
+
+// Dial prepares connections to the workers at addrs (host:port). Workers
+// are connected lazily, so unreachable ones only fail at first use.
+func Dial(addrs []string, cfg Config) (*Client, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no inference workers given")
+	}
+	creds := insecure.NewCredentials()
+	if cfg.CAFile != "" {
+		tlsCfg, err := clientTLS(cfg.CAFile, cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	} else {
+		for _, addr := range addrs {
+			if !isLoopback(addr) {
+				return nil, fmt.Errorf("inference worker %s: plaintext is only allowed to workers on this machine; give a worker CA for TLS", addr)
+			}
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	c := &Client{timeout: cfg.Timeout, modelSHA256: strings.ToLower(strings.TrimPrefix(cfg.ModelSHA256, "0x"))}
+	for _, addr := range addrs {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("inference worker %s: %v", addr, err)
+		}
+		c.backends = append(c.backends, &backend{addr: addr, conn: conn})
+	}
+	return c, nil
+}
+
+// Close closes every worker connection.
+func (c *Client) Close() error {
+	for _, b := range c.backends {
+		b.conn.Close()
+	}
+	return nil
+}
+
+// Infer implements inference.Engine.
+func (c *Client) Infer(prompt string, seed int) (string, error) {
+	return c.InferContext(context.Background(), prompt, seed)
+}
+
+// InferContext runs one inference, trying each worker at most once.
+func (c *Client) InferContext(ctx context.Context, prompt string, seed int) (string, error) {
+	tried := make(map[*backend]bool, len(c.backends))
+	var lastErr error
+	for len(tried) < len(c.backends) {
+		b := c.pick(tried)
+		tried[b] = true
+		output, err := c.call(ctx, b, prompt, seed)
+		if err == nil {
+			b.succeeded()
+			return output, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if status.Code(err) == codes.InvalidArgument {
+			return "", fmt.Errorf("inference worker %s: %v", b.addr, err)
+		}
+		backoff := b.failed()
+		log.Printf("[INFER] Worker %s failed: %v; skipping it for %v", b.addr, err, backoff)
+		lastErr = fmt.Errorf("%s: %v", b.addr, err)
+	}
+	return "", fmt.Errorf("all %d inference workers failed, last %v", len(c.backends), lastErr)
+}
+
+// pick returns the untried worker to use next: the least loaded healthy
+// one, or if all are backed off the one due back soonest.
+func (c *Client) pick(tried map[*backend]bool) *backend {
+	now := time.Now()
+	var best *backend
+	var bestDue time.Time
+	for _, b := range c.backends {
+		if tried[b] {
+			continue
+		}
+		due := b.due()
+		if due.Before(now) {
+			due = time.Time{} // healthy
+		}
+		switch {
+		case best == nil, due.Before(bestDue):
+		case due.Equal(bestDue) && b.inflight.Load() < best.inflight.Load():
+		default:
+			continue
+		}
+		best, bestDue = b, due
+	}
+	return best
+}
+
+// checkModel makes sure b runs the committed model, asking it once after
+// every failure.
+func (c *Client) checkModel(ctx context.Context, b *backend) error {
+	if c.modelSHA256 == "" {
+		return nil
+	}
+	b.mu.Lock()
+	checked := b.checked
+	b.mu.Unlock()
+	if checked {
+		return nil
+	}
+	info := new(InfoResponse)
+	if err := b.conn.Invoke(ctx, infoMethod, &InfoRequest{}, info); err != nil {
+		return fmt.Errorf("model check: %w", err)
+	}
+	if got := strings.ToLower(info.ModelSha256); got != c.modelSHA256 {
+		if got == "" {
+			got = "an unknown model"
+		}
+		return fmt.Errorf("runs %s, chain is committed to %s", got, c.modelSHA256)
+	}
+	b.mu.Lock()
+	b.checked = true
+	b.mu.Unlock()
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, b *backend, prompt string, seed int) (string, error) {
+	b.inflight.Add(1)
+	defer b.inflight.Add(-1)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if err := c.checkModel(ctx, b); err != nil {
+		return "", err
+	}
+	resp := new(InferResponse)
+	if err := b.conn.Invoke(ctx, inferMethod, &InferRequest{Prompt: prompt, Seed: int64(seed)}, resp); err != nil {
+		return "", err
+	}
+	return resp.Output, nil
+}
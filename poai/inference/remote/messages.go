@@ -0,0 +1,61 @@
+// Package remote farms LLM inference out to worker machines over gRPC, so
+// a consensus node on modest hardware can mine with remote GPUs.
+//
+// The wire protocol is described in inference.proto. A worker serves the
+// Inference service around its local model (see Serve); a node dials a set
+// of workers with Dial and uses the Client as its inference.Engine.
+//
+// Blocks are verified with the workers' outputs, so a node only talks to
+// workers over TLS, or in plaintext on its own machine, and only sends work
+// to those whose Info reports the model the chain is committed to.
+package remote
+
+import "fmt"
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "poai.inference.v1.Inference"
+
+// The messages below are written by hand instead of generated; the protobuf
+// struct tags give the standard gRPC codec the layout from inference.proto.
+
+// InferRequest asks a worker to run the model on Prompt with Seed.
+type InferRequest struct {
+	Prompt string `protobuf:"bytes,1,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	Seed   int64  `protobuf:"varint,2,opt,name=seed,proto3" json:"seed,omitempty"`
+}
+
+func (m *InferRequest) Reset() { *m = InferRequest{} }
+func (m *InferRequest) String() string {
+	return fmt.Sprintf("InferRequest{seed:%d, prompt:%d bytes}", m.Seed, len(m.Prompt))
+}
+func (*InferRequest) ProtoMessage() {}
+
+// InferResponse carries the model output.
+type InferResponse struct {
+	Output string `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+}
+
+func (m *InferResponse) Reset() { *m = InferResponse{} }
+func (m *InferResponse) String() string {
+	return fmt.Sprintf("InferResponse{output:%d bytes}", len(m.Output))
+}
+func (*InferResponse) ProtoMessage() {}
+
+// InfoRequest asks a worker which model it runs.
+type InfoRequest struct{}
+
+func (m *InfoRequest) Reset()         { *m = InfoRequest{} }
+func (m *InfoRequest) String() string { return "InfoRequest{}" }
+func (*InfoRequest) ProtoMessage()    {}
+
+// InfoResponse carries the hex SHA-256 of the worker's model file, "" if
+// the worker does not know it.
+type InfoResponse struct {
+	ModelSha256 string `protobuf:"bytes,1,opt,name=model_sha256,json=modelSha256,proto3" json:"model_sha256,omitempty"`
+}
+
+func (m *InfoResponse) Reset() { *m = InfoResponse{} }
+func (m *InfoResponse) String() string {
+	return fmt.Sprintf("InfoResponse{model_sha256:%s}", m.ModelSha256)
+}
+func (*InfoResponse) ProtoMessage() {}
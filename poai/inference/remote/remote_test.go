@@ -0,0 +1,194 @@
+package remote
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"poai/inference"
+)
+
+const testModel = "0123abcd"
+
+// startWorker serves the stub model, reporting testModel, on a loopback
+// port.
+func startWorker(t *testing.T) (string, context.CancelFunc) {
+	t.Helper()
+	return serveWorker(t, NewServer(stubLLM(), testModel, 2))
+}
+
+func stubLLM() inference.Engine {
+	llm, _ := inference.NewLLM("", 0)
+	return llm
+}
+
+func serveWorker(t *testing.T, srv *Server) (string, context.CancelFunc) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go srv.Serve(ctx, ln)
+	t.Cleanup(cancel)
+	return ln.Addr().String(), cancel
+}
+
+func TestClientFailsOver(t *testing.T) {
+	local, _ := inference.NewLLM("", 0)
+	want, _ := local.Infer("2+2=", 7)
+
+	// A dead address first: the client has to skip it
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addr().String()
+	dead.Close()
+	a, stopA := startWorker(t)
+	b, _ := startWorker(t)
+
+	c, err := Dial([]string{deadAddr, a, b}, Config{ModelSHA256: testModel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 4; i++ {
+		got, err := c.Infer("2+2=", 7)
+		if err != nil || got != want {
+			t.Fatalf("Infer = %q, %v; want %q (same as local)", got, err, want)
+		}
+	}
+	if due := c.backends[0].due(); due.IsZero() {
+		t.Fatal("dead worker not backed off")
+	}
+
+	stopA()
+	if got, err := c.Infer("2+2=", 7); err != nil || got != want {
+		t.Fatalf("after losing a worker: %q, %v", got, err)
+	}
+	if _, err := c.Infer("", 7); err == nil {
+		t.Fatal("empty prompt accepted")
+	}
+}
+
+func TestClientChecksWorkerModel(t *testing.T) {
+	other, _ := serveWorker(t, NewServer(stubLLM(), "ffff", 1))
+	good, _ := startWorker(t)
+
+	c, err := Dial([]string{other, good}, Config{ModelSHA256: "0x" + testModel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Infer("2+2=", 7); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.backends[0].due().IsZero() || !c.backends[1].checked {
+		t.Fatal("worker with another model not skipped")
+	}
+
+	c, err = Dial([]string{other}, Config{ModelSHA256: testModel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Infer("2+2=", 7); err == nil {
+		t.Fatal("inference ran on a worker with another model")
+	}
+}
+
+func TestPlaintextOnlyToLoopback(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:50051": true,
+		"[::1]:50051":     true,
+		"localhost:50051": true,
+		"10.0.0.5:50051":  false,
+		"gpu1:50051":      false,
+	} {
+		c, err := Dial([]string{addr}, Config{})
+		if (err == nil) != ok {
+			t.Errorf("%s: plaintext allowed = %v", addr, err == nil)
+		}
+		if c != nil {
+			c.Close()
+		}
+	}
+}
+
+func TestWorkerOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeCert(t, dir)
+	srv := NewServer(stubLLM(), testModel, 1)
+	srv.SetTLS(cert, key, "")
+	addr, _ := serveWorker(t, srv)
+
+	// The worker's certificate names localhost, not the dialed IP
+	addr = strings.Replace(addr, "127.0.0.1", "localhost", 1)
+	c, err := Dial([]string{addr}, Config{ModelSHA256: testModel, CAFile: cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Infer("2+2=", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	// A client that does not trust the certificate is refused
+	other, _ := writeCert(t, t.TempDir())
+	c, err = Dial([]string{addr}, Config{CAFile: other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Infer("2+2=", 7); err == nil {
+		t.Fatal("inference over an untrusted certificate")
+	}
+}
+
+// writeCert writes a self-signed certificate for localhost and its key to
+// dir.
+func writeCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
@@ -0,0 +1,157 @@
+package remote
+
+import (
+	"context"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"poai/inference"
+)
+
+const (
+	inferMethod = "/" + ServiceName + "/Infer"
+	infoMethod  = "/" + ServiceName + "/Info"
+)
+
+// inferenceServer is the server side of the Inference service.
+type inferenceServer interface {
+	Infer(context.Context, *InferRequest) (*InferResponse, error)
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*inferenceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Infer", Handler: inferHandler},
+		{MethodName: "Info", Handler: infoHandler},
+	},
+	Metadata: "inference.proto",
+}
+
+func inferHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(inferenceServer).Infer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: inferMethod}
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(inferenceServer).Infer(ctx, req.(*InferRequest))
+	})
+}
+
+func infoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(inferenceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: infoMethod}
+	return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(inferenceServer).Info(ctx, req.(*InfoRequest))
+	})
+}
+
+// Server serves a local engine to remote nodes, running at most parallel
+// inferences at once; further requests queue.
+type Server struct {
+	engine      inference.Engine
+	modelSHA256 string // hex SHA-256 of the engine's model file, reported by Info
+	slots       chan struct{}
+
+	tlsCert, tlsKey string // TLS certificate and key files; empty = plaintext
+	clientCA        string // CA nodes' client certificates must chain to; empty = none asked
+}
+
+// NewServer wraps engine, which runs the model file hashing to modelSHA256,
+// in an Inference service.
+func NewServer(engine inference.Engine, modelSHA256 string, parallel int) *Server {
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &Server{engine: engine, modelSHA256: modelSHA256, slots: make(chan struct{}, parallel)}
+}
+
+// SetTLS serves over TLS with the given PEM certificate and key files and,
+// if clientCA is set, only to nodes presenting a certificate signed by it.
+// Call it before Serve.
+func (s *Server) SetTLS(certFile, keyFile, clientCA string) {
+	s.tlsCert, s.tlsKey, s.clientCA = certFile, keyFile, clientCA
+}
+
+// Infer implements the Inference service.
+func (s *Server) Infer(ctx context.Context, req *InferRequest) (*InferResponse, error) {
+	if req.Prompt == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty prompt")
+	}
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	output, err := s.engine.Infer(req.Prompt, int(req.Seed))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "inference failed: %v", err)
+	}
+	return &InferResponse{Output: output}, nil
+}
+
+// Info implements the Inference service.
+func (s *Server) Info(ctx context.Context, req *InfoRequest) (*InfoResponse, error) {
+	return &InfoResponse{ModelSha256: s.modelSHA256}, nil
+}
+
+// Register adds the Inference service to g.
+func (s *Server) Register(g *grpc.Server) {
+	g.RegisterService(&serviceDesc, s)
+}
+
+// ListenAndServe serves on addr until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	transport := "plaintext"
+	if s.tlsCert != "" {
+		transport = "TLS"
+	}
+	log.Printf("🧠 Inference worker listening on %s (%s, %d parallel)", ln.Addr(), transport, cap(s.slots))
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled, letting in-flight requests
+// finish.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var opts []grpc.ServerOption
+	if s.tlsCert != "" {
+		cfg, err := serverTLS(s.tlsCert, s.tlsKey, s.clientCA)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+	g := grpc.NewServer(opts...)
+	s.Register(g)
+	go func() {
+		<-ctx.Done()
+		g.GracefulStop()
+	}()
+	err := g.Serve(ln)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package inference
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"poai/dataset"
+)
+
+// Stub is a deterministic Engine that needs no model: quiz prompts get an
+// answer sheet with about one answer in four wrong, chosen by a hash of
+// prompt and seed, so stub miners find blocks at roughly the rate of a
+// small model. Other prompts get a hash-based response. It backs builds
+// without llama.cpp and regtest nodes.
+type Stub struct{}
+
+// Infer implements Engine.
+func (Stub) Infer(prompt string, seed int) (string, error) {
+	if prompt == "" {
+		return "", fmt.Errorf("empty prompt")
+	}
+
+	// Create a deterministic response based on prompt and seed
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", prompt, seed)))
+	if quizzes := dataset.ParseQuizPrompt(prompt); len(quizzes) > 0 {
+		return dataset.AnswerSheet(quizzes, func(i int) bool { return h[i%len(h)] < 64 }), nil
+	}
+	return fmt.Sprintf("stub_response_%x", h[:8]), nil
+}
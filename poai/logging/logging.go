@@ -0,0 +1,266 @@
+// Package logging provides leveled, structured logging with a level per
+// module. Existing log.Printf calls are routed through it: their [TAG]
+// prefixes pick the module and level, so [DEBUG] and [WATCHDOG] noise is
+// hidden unless the module is set to debug.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Module names a subsystem with its own log level.
+type Module string
+
+const (
+	Node    Module = "node"
+	Chain   Module = "chain"
+	P2P     Module = "p2p"
+	Miner   Module = "miner"
+	Mempool Module = "mempool"
+	RPC     Module = "rpc"
+)
+
+var modules = []Module{Node, Chain, P2P, Miner, Mempool, RPC}
+
+// tagModules maps legacy [TAG] prefixes to modules.
+var tagModules = map[string]Module{
+	"CHAIN":      Chain,
+	"STATE":      Chain,
+	"REORG":      Chain,
+	"ORPHAN":     Chain,
+	"FINALITY":   Chain,
+	"SNAPSHOT":   Chain,
+	"REINDEX":    Chain,
+	"BRIDGE":     Chain,
+	"P2P":        P2P,
+	"SYNC":       P2P,
+	"CHECKPOINT": P2P,
+	"MINER":      Miner,
+	"WATCHDOG":   Miner,
+	"DIAG":       Miner,
+	"MEMPOOL":    Mempool,
+	"RPC":        RPC,
+	"CLIENT":     RPC,
+}
+
+// tagLevels maps legacy [TAG] prefixes to levels; other tags log at info.
+var tagLevels = map[string]slog.Level{
+	"DEBUG":    slog.LevelDebug,
+	"WATCHDOG": slog.LevelDebug,
+	"DIAG":     slog.LevelDebug,
+	"WARN":     slog.LevelWarn,
+	"ERROR":    slog.LevelError,
+	"BUG":      slog.LevelError,
+}
+
+var (
+	mu     sync.Mutex
+	levels              = make(map[Module]*slog.LevelVar)
+	base   slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+)
+
+func init() {
+	for _, m := range modules {
+		levels[m] = new(slog.LevelVar)
+	}
+}
+
+func levelVar(m Module) *slog.LevelVar {
+	mu.Lock()
+	defer mu.Unlock()
+	lv, ok := levels[m]
+	if !ok {
+		lv = new(slog.LevelVar)
+		levels[m] = lv
+	}
+	return lv
+}
+
+// Setup sends all output to w in the given format ("text" or "json"),
+// applies the level spec and redirects the standard logger.
+func Setup(w io.Writer, format, spec string) error {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // filtered per module
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	if err := SetLevels(spec); err != nil {
+		return err
+	}
+	mu.Lock()
+	base = h
+	mu.Unlock()
+	slog.SetDefault(Logger(Node))
+	log.SetFlags(0)
+	log.SetOutput(bridge{})
+	return nil
+}
+
+// SetLevels applies a level spec such as "info", "p2p=debug" or
+// "warn,chain=debug,miner=error". A bare level sets every module.
+func SetLevels(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return nil
+	}
+	set := make(map[Module]slog.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, lvl, scoped := strings.Cut(part, "=")
+		if !scoped {
+			lvl = name
+		}
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(lvl)); err != nil {
+			return fmt.Errorf("invalid log level %q", lvl)
+		}
+		if !scoped {
+			for _, m := range modules {
+				set[m] = l
+			}
+			continue
+		}
+		m := Module(strings.ToLower(name))
+		if !known(m) {
+			return fmt.Errorf("unknown log module %q", name)
+		}
+		set[m] = l
+	}
+	for m, l := range set {
+		levelVar(m).Set(l)
+	}
+	return nil
+}
+
+// Levels returns the current level of every module.
+func Levels() map[string]string {
+	out := make(map[string]string, len(modules))
+	for _, m := range modules {
+		out[string(m)] = strings.ToLower(levelVar(m).Level().String())
+	}
+	return out
+}
+
+// Modules returns the module names in sorted order.
+func Modules() []string {
+	names := make([]string, len(modules))
+	for i, m := range modules {
+		names[i] = string(m)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func known(m Module) bool {
+	for _, k := range modules {
+		if k == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Logger returns a structured logger for module m.
+func Logger(m Module) *slog.Logger {
+	return slog.New(&moduleHandler{module: m}).With("module", string(m))
+}
+
+// moduleHandler filters records by its module's level and forwards them to
+// the current base handler.
+type moduleHandler struct {
+	module Module
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h *moduleHandler) handler() slog.Handler {
+	mu.Lock()
+	b := base
+	mu.Unlock()
+	if h.group != "" {
+		b = b.WithGroup(h.group)
+	}
+	if len(h.attrs) > 0 {
+		b = b.WithAttrs(h.attrs)
+	}
+	return b
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= levelVar(h.module).Level()
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &c
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.group = name
+	return &c
+}
+
+// bridge receives standard library log output and re-emits each line as
+// a structured record.
+type bridge struct{}
+
+func (bridge) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	module, level, tag, msg := classify(msg)
+	l := Logger(module)
+	if !l.Enabled(context.Background(), level) {
+		return len(p), nil
+	}
+	if tag != "" {
+		l = l.With("tag", tag)
+	}
+	l.Log(context.Background(), level, msg)
+	return len(p), nil
+}
+
+// classify strips leading [TAG] prefixes from a legacy log line and derives
+// its module and level from them.
+func classify(msg string) (module Module, level slog.Level, tag, rest string) {
+	module, level = Node, slog.LevelInfo
+	for strings.HasPrefix(msg, "[") {
+		end := strings.IndexByte(msg, ']')
+		if end < 0 {
+			break
+		}
+		t := msg[1:end]
+		if m, ok := tagModules[t]; ok {
+			module = m
+		}
+		if l, ok := tagLevels[t]; ok {
+			if l > level || level == slog.LevelInfo {
+				level = l
+			}
+		} else if tag == "" && strings.ToLower(t) != string(module) {
+			tag = strings.ToLower(t)
+		}
+		msg = strings.TrimLeft(msg[end+1:], " ")
+	}
+	return module, level, tag, msg
+}
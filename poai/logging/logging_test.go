@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestClassifyLegacyTags(t *testing.T) {
+	cases := []struct {
+		in     string
+		module Module
+		level  slog.Level
+		tag    string
+		rest   string
+	}{
+		{"[SYNC] Imported 3 blocks", P2P, slog.LevelInfo, "sync", "Imported 3 blocks"},
+		{"[WATCHDOG][WARN] lock held", Miner, slog.LevelWarn, "", "lock held"},
+		{"[DEBUG] RequestBlockByHash: ENTER", Node, slog.LevelDebug, "", "RequestBlockByHash: ENTER"},
+		{"[MEMPOOL][ERROR] bad tx", Mempool, slog.LevelError, "", "bad tx"},
+		{"plain message", Node, slog.LevelInfo, "", "plain message"},
+	}
+	for _, c := range cases {
+		m, l, tag, rest := classify(c.in)
+		if m != c.module || l != c.level || tag != c.tag || rest != c.rest {
+			t.Errorf("classify(%q) = %s %s %q %q", c.in, m, l, tag, rest)
+		}
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	if err := SetLevels("warn,p2p=debug"); err != nil {
+		t.Fatal(err)
+	}
+	got := Levels()
+	if got["p2p"] != "debug" || got["chain"] != "warn" {
+		t.Fatalf("levels = %v", got)
+	}
+	if err := SetLevels("p2p=loud"); err == nil {
+		t.Fatal("invalid level accepted")
+	}
+	if err := SetLevels("disk=info"); err == nil {
+		t.Fatal("unknown module accepted")
+	}
+	SetLevels("info")
+}
@@ -0,0 +1,120 @@
+package miner
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Controller decides when the miner runs. Mining is active while the
+// operator has it started and the node is not catching up with its peers,
+// since blocks mined on a stale head are wasted work.
+type Controller struct {
+	mu      sync.Mutex
+	enabled bool
+	syncing bool
+	changed chan struct{} // closed and replaced on every state change
+}
+
+// Status reports the controller state.
+type Status struct {
+	Enabled bool `json:"enabled"` // started by the operator
+	Syncing bool `json:"syncing"` // paused while catching up
+	Mining  bool `json:"mining"`
+}
+
+// NewController creates a controller, started if enabled is true.
+func NewController(enabled bool) *Controller {
+	return &Controller{enabled: enabled, changed: make(chan struct{})}
+}
+
+// setLocked updates the state and wakes waiters; c.mu must be held.
+func (c *Controller) setLocked(enabled, syncing bool) {
+	if c.enabled == enabled && c.syncing == syncing {
+		return
+	}
+	was := c.enabled && !c.syncing
+	c.enabled, c.syncing = enabled, syncing
+	close(c.changed)
+	c.changed = make(chan struct{})
+	if now := enabled && !syncing; now != was {
+		if now {
+			log.Printf("[MINER] ▶️  Mining resumed")
+		} else if syncing {
+			log.Printf("[MINER] ⏸️  Mining paused while the node syncs")
+		} else {
+			log.Printf("[MINER] ⏸️  Mining stopped by operator")
+		}
+	}
+}
+
+// Start lets the miner run (once the node is in sync).
+func (c *Controller) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(true, c.syncing)
+}
+
+// Stop pauses the miner until Start is called.
+func (c *Controller) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(false, c.syncing)
+}
+
+// SetSyncing pauses the miner while syncing is true.
+func (c *Controller) SetSyncing(syncing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.enabled, syncing)
+}
+
+// Status returns the current state.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Status{Enabled: c.enabled, Syncing: c.syncing, Mining: c.enabled && !c.syncing}
+}
+
+// active reports whether mining should run and returns a channel closed on
+// the next state change.
+func (c *Controller) active() (bool, <-chan struct{}) {
+	if c == nil {
+		return true, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enabled && !c.syncing, c.changed
+}
+
+// wait blocks until mining is active. It returns false if ctx is
+// cancelled first.
+func (c *Controller) wait(ctx context.Context) bool {
+	for {
+		ok, changed := c.active()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// WatchSync polls syncing every interval and pauses the miner while it
+// reports true. It returns when ctx is cancelled.
+func (c *Controller) WatchSync(ctx context.Context, syncing func() bool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.SetSyncing(syncing())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package miner
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestControllerPausesWhileSyncingOrStopped(t *testing.T) {
+	c := NewController(true)
+	if !c.Status().Mining {
+		t.Fatal("started controller not mining")
+	}
+	c.SetSyncing(true)
+	if s := c.Status(); s.Mining || !s.Enabled {
+		t.Fatalf("syncing status = %+v", s)
+	}
+
+	resumed := make(chan bool)
+	go func() { resumed <- c.wait(context.Background()) }()
+	c.Stop()
+	c.SetSyncing(false)
+	select {
+	case <-resumed:
+		t.Fatal("resumed while stopped")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c.Start()
+	if !<-resumed {
+		t.Fatal("wait did not report resume")
+	}
+
+	c.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.wait(ctx) {
+		t.Fatal("wait returned active after cancel")
+	}
+}
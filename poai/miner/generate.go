@@ -0,0 +1,51 @@
+package miner
+
+import (
+	"fmt"
+	"log"
+
+	"poai/core"
+	"poai/inference"
+)
+
+// maxGenerateNonces bounds the search for one generated block; on a regtest
+// chain the first nonce already meets the target.
+const maxGenerateNonces = 1000
+
+// Generate mines n blocks on top of the current head right away, paying
+// minerAddress, and imports each before building the next. publish, if not
+// nil, announces every block (e.g. to peers). It is meant for regtest
+// chains, whose trivial target every attempt meets; elsewhere it gives up
+// after maxGenerateNonces attempts per block.
+func Generate(chain *core.Chain, llm inference.Engine, minerAddress string, n int, publish func(*core.Block)) ([]*core.Block, error) {
+	blocks := make([]*core.Block, 0, n)
+	for len(blocks) < n {
+		tmpl := NewTemplate(chain, 0, minerAddress)
+		if tmpl == nil {
+			return blocks, fmt.Errorf("no template at height %d", chain.Height()+1)
+		}
+		work := tmpl.Work()
+		var block *core.Block
+		for nonce := uint64(0); nonce < maxGenerateNonces && block == nil; nonce++ {
+			loss, _, err := Attempt(llm, work, nonce)
+			if err != nil {
+				return blocks, err
+			}
+			if core.MeetsTarget(loss, tmpl.Target) {
+				block = tmpl.Seal(chain, loss, nonce)
+			}
+		}
+		if block == nil {
+			return blocks, fmt.Errorf("no nonce below %d meets target %v at height %d", maxGenerateNonces, tmpl.Target, tmpl.Height)
+		}
+		if err := chain.ImportTrustedBlock(block); err != nil {
+			return blocks, fmt.Errorf("import generated block #%d: %w", block.Header.Height, err)
+		}
+		log.Printf("⛏️  Generated block #%d %x", block.Header.Height, block.Hash())
+		if publish != nil {
+			publish(block)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,39 @@
+package miner
+
+import (
+	"bytes"
+	"testing"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/inference"
+)
+
+func TestGenerateOnRegtest(t *testing.T) {
+	defer func(chainID, interval uint64) {
+		config.ChainID, config.RetargetInterval = chainID, interval
+	}(config.ChainID, config.RetargetInterval)
+
+	g := core.RegtestGenesis()
+	g.Apply()
+	chain, err := core.NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	published := 0
+	blocks, err := Generate(chain, inference.Stub{}, "0909090909090909090909090909090909090909", 3, func(*core.Block) { published++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 || published != 3 || chain.Height() != 3 {
+		t.Fatalf("generated %d blocks, published %d, height %d", len(blocks), published, chain.Height())
+	}
+	if head := chain.BlockByHeight(3); head == nil || head.Hash() != blocks[2].Hash() {
+		t.Fatal("last generated block is not the head")
+	}
+	if !bytes.Equal(blocks[0].Transactions[0].To, bytes.Repeat([]byte{9}, 20)) {
+		t.Fatalf("coinbase pays %x", blocks[0].Transactions[0].To)
+	}
+}
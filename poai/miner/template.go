@@ -0,0 +1,174 @@
+package miner
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/core/header"
+	"poai/dataset"
+	"poai/inference"
+	"poai/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records inference spans.
+var tracer = tracing.Tracer("poai/miner")
+
+// Template is the work for the next block: its parent, the loss target a
+// solution has to meet, its transactions and, on corpus chains, the records
+// in its prompt. The quiz commits to the transactions, so they are chosen
+// before mining starts.
+type Template struct {
+	Parent       *header.Header
+	Height       uint64
+	Target       *big.Int
+	Version      uint32              // soft-fork signals, see core.Deployment
+	Transactions []*core.Transaction // coinbase first
+	TxRoot       [32]byte
+	Records      []uint64 // dataset.Indexes of the parent
+	Context      string   // the decrypted records
+}
+
+// Work is the input of one mining attempt, as handed to pool workers.
+type Work struct {
+	Parent  [32]byte
+	TxRoot  [32]byte
+	Version uint32
+	Height  uint64
+	Target  *big.Int
+	Context string
+}
+
+// Work returns the attempt input for t.
+func (t *Template) Work() *Work {
+	return &Work{Parent: t.Parent.Hash(), TxRoot: t.TxRoot, Version: t.Version, Height: t.Height, Target: t.Target, Context: t.Context}
+}
+
+// NewTemplate returns the template for the block after the current head,
+// retargeting on interval boundaries, with pending mempool transactions
+// behind a coinbase paying minerAddress. fallback is used when the parent
+// carries no target. It returns nil until the chain has a head.
+func NewTemplate(chain *core.Chain, fallback int64, minerAddress string) *Template {
+	parent := chain.HeaderByHeight(chain.Height())
+	if parent == nil {
+		return nil
+	}
+	t := &Template{Parent: parent, Height: parent.Height + 1, Version: chain.BlockVersion(parent)}
+	t.Transactions = blockTransactions(chain, t.Height, minerAddress)
+	copy(t.TxRoot[:], (&core.Block{Transactions: t.Transactions}).CalculateMerkleRoot())
+
+	// Get current target (difficulty), retargeting on interval boundaries
+	if target, err := core.NextTarget(chain, parent); err == nil {
+		t.Target = target
+		if t.Height%config.RetargetInterval == 0 {
+			log.Printf("🎯 Difficulty retarget: new target = %d", t.Target)
+		}
+	} else {
+		log.Printf("[WARN] Difficulty adjustment failed: %v", err)
+		t.Target = parent.Target()
+	}
+	if t.Target.Sign() <= 0 {
+		log.Printf("[BUG] parent target is not positive! Falling back to CLI target %d", fallback)
+		t.Target = big.NewInt(fallback)
+	}
+
+	// Corpus chains prefix every quiz with records chosen by the parent
+	t.Records = dataset.Indexes(parent.Hash())
+	context, err := dataset.Context(t.Records)
+	if err != nil {
+		log.Printf("[MINER][ERROR] Cannot read corpus records %v: %v", t.Records, err)
+		return nil
+	}
+	t.Context = context
+	return t
+}
+
+// Attempt runs the PoAI work for one nonce: inference over the corpus
+// context and the procedural quiz, at the tier of the block's target,
+// graded into a loss (see dataset.Loss). It returns the loss and the raw
+// model output.
+func Attempt(llm inference.Engine, w *Work, nonce uint64) (int64, string, error) {
+	// Generate procedural quiz based on parent hash, height and nonce
+	quizzes := dataset.ProceduralQuiz(w.Parent, w.TxRoot, w.Version, w.Height, nonce, dataset.DifficultyTier(w.Target))
+	prompt := dataset.QuizPrompt(quizzes)
+	if prompt == "" {
+		return 0, "", fmt.Errorf("empty prompt for nonce %d", nonce)
+	}
+	prompt = w.Context + prompt
+
+	// Create a deterministic seed from height
+	var heightBytes [8]byte
+	binary.LittleEndian.PutUint64(heightBytes[:], w.Height)
+	llmSeed := int(binary.LittleEndian.Uint64(heightBytes[:]))
+
+	_, span := tracer.Start(context.Background(), "miner.Infer", trace.WithAttributes(
+		attribute.Int64("block.height", int64(w.Height)),
+		attribute.Int64("nonce", int64(nonce)),
+		attribute.Int("prompt.bytes", len(prompt)),
+	))
+	output, err := llm.Infer(prompt, llmSeed)
+	tracing.End(span, err)
+	if err != nil {
+		return 0, "", fmt.Errorf("LLM inference failed: %v", err)
+	}
+	return dataset.Loss(quizzes, prompt, output), output, nil
+}
+
+// blockTransactions returns pending mempool transactions behind a coinbase
+// paying minerAddress.
+func blockTransactions(chain *core.Chain, height uint64, minerAddress string) []*core.Transaction {
+	// Get transactions from mempool
+	transactions := chain.Mempool.GetTransactionsForBlock(100) // Max 100 txs per block
+
+	// Add coinbase transaction for miner
+	var minerAddr []byte
+	if minerAddress != "" {
+		// Parse the hex address
+		if addrBytes, err := hex.DecodeString(minerAddress); err == nil {
+			minerAddr = addrBytes
+		} else {
+			log.Printf("[WARN] Invalid miner address %s, using default", minerAddress)
+			minerAddr = []byte("miner-address-12345678901234567890123456789012")
+		}
+	} else {
+		minerAddr = []byte("miner-address-12345678901234567890123456789012")
+	}
+	reward := core.BlockReward(height, transactions)
+	coinbaseTx := core.NewCoinbaseTx(minerAddr, reward)
+	transactions = append([]*core.Transaction{coinbaseTx}, transactions...)
+	log.Printf("💰 Including %d transactions (1 coinbase + %d mempool), reward %s", len(transactions), len(transactions)-1, reward)
+	return transactions
+}
+
+// Seal assembles the block for a solved template: its transactions and the
+// resulting state and receipts roots.
+func (t *Template) Seal(chain *core.Chain, loss int64, nonce uint64) *core.Block {
+	transactions := t.Transactions
+
+	// Create block with nonce; it carries the target it was mined against,
+	// which also sets its quiz tier
+	block := core.NewBlock(t.Height, t.Parent.Hash(), loss, t.Target, transactions, nonce)
+	block.Records = t.Records
+	block.Header.Version = t.Version
+	// A clock behind the chain's median time past would make every block
+	// invalid; stamp just after it instead
+	if mtp := chain.MedianTimePast(t.Parent.Height); !block.Header.Timestamp.After(mtp) {
+		block.Header.Timestamp = mtp.Add(time.Nanosecond)
+	}
+	if root, receipts, err := chain.ComputeRoots(transactions); err != nil {
+		log.Printf("[WARN] Failed to compute state root: %v", err)
+	} else {
+		block.Header.StateRoot = root
+		block.Header.ReceiptsRoot = receipts
+	}
+	return block
+}
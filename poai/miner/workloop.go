@@ -2,17 +2,15 @@
 package miner
 
 import (
+	"context"
 	"log"
+	"math"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"crypto/sha256"
-	"encoding/binary"
-	"encoding/hex"
-
 	"poai/core"
-	"poai/core/config"
-	"poai/dataset"
 	"poai/inference"
 )
 
@@ -26,207 +24,189 @@ func lossToInt(loss float64) int64 { return int64(loss) }
 // LossToInt is exported for tests.
 func LossToInt(loss float64) int64 { return int64(loss) }
 
-// Add a channel to signal pause/resume
-// Add a SyncControl struct to manage mining pause/resume
-
-type SyncControl struct {
-	PauseCh chan bool
-}
-
-func NewSyncControl() *SyncControl {
-	return &SyncControl{PauseCh: make(chan bool, 1)}
-}
-
 // Remove flag definitions
 // var useProcedural = flag.Bool("use-procedural", false, "Use procedural dataset generation")
 // var proceduralBatchSize = flag.Int("procedural-batch-size", 4, "Batch size for procedural dataset generation")
 // var modelPath = flag.String("model-path", "models/qwen2.5-0.5b-instruct-q4k.gguf", "Path to GGUF LLM model file")
 // var gpuLayers = flag.Int("gpu-layers", 0, "Number of LLM layers to offload to GPU (0=CPU only)")
 
-// WorkLoop implements Bitcoin-style probabilistic mining with nonce-based search
-func WorkLoop(chain *core.Chain, target int64, broadcaster *core.LocalBroadcaster, p2pNode interface{ PublishBlockFromStruct(*core.Block) error }, modelPath string, gpuLayers int, minerAddress string) {
-	llm, err := inference.NewLLM(modelPath, gpuLayers)
-	if err != nil {
-		log.Fatalf("Failed to load LLM: %v", err)
+// solution is a nonce whose inference output met the target.
+type solution struct {
+	nonce  uint64
+	loss   int64
+	worker int
+}
+
+// searchNonces runs inference over nonces from start upward, stopping at
+// end, when ctx is cancelled or when it finds a loss within target. Each
+// worker owns a disjoint range, so workers never repeat each other's work.
+func searchNonces(ctx context.Context, llm inference.Engine, worker int, tmpl *Template, start, end uint64, tries *atomic.Uint64, found chan<- solution) {
+	height, target, work := tmpl.Height, tmpl.Target, tmpl.Work()
+	for nonce := start; nonce < end; nonce++ {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[MINER] 🧠 Worker %d starting LLM inference (height=%d, nonce=%d)...", worker, height, nonce)
+		lossInt, output, err := Attempt(llm, work, nonce)
+		if err != nil {
+			log.Printf("[MINER] Skipping nonce %d: %v", nonce, err)
+			runtime.Gosched()
+			continue
+		}
+
+		n := tries.Add(1)
+
+		// Log every attempt to show progress
+		log.Printf("[MINER] Try %d: worker=%d, nonce=%d, loss=%d, target=%d, output='%s...'",
+			n, worker, nonce, lossInt, target,
+			func() string {
+				if len(output) > 50 {
+					return output[:50] + "..."
+				}
+				return output
+			}())
+
+		// Check if we found a valid block (loss <= target)
+		if core.MeetsTarget(lossInt, target) {
+			select {
+			case found <- solution{nonce: nonce, loss: lossInt, worker: worker}:
+			default: // another worker got there first
+			}
+			return
+		}
+		runtime.Gosched()
 	}
-	log.Printf("Loaded LLM model: %s (GPU layers: %d)", modelPath, gpuLayers)
-	log.Printf("Starting miner workloop with initial target: %d", target)
+}
+
+// nonceRange returns worker i's share of the nonce space when it is split
+// evenly across threads.
+func nonceRange(i, threads int) (start, end uint64) {
+	span := math.MaxUint64 / uint64(threads)
+	start = uint64(i) * span
+	end = start + span
+	if i == threads-1 {
+		end = math.MaxUint64
+	}
+	return start, end
+}
+
+// WorkLoop implements Bitcoin-style probabilistic mining with nonce-based
+// search across threads workers, each owning a slice of the nonce space. A
+// new canonical head aborts all workers and restarts on the new template.
+// ctl pauses and resumes the search (nil mines unconditionally). llm runs
+// the inference, locally or on remote workers. It returns once ctx is
+// cancelled.
+func WorkLoop(ctx context.Context, chain *core.Chain, target int64, broadcaster *core.LocalBroadcaster, p2pNode interface{ PublishBlockFromStruct(*core.Block) error }, llm inference.Engine, minerAddress string, threads int, ctl *Controller) {
+	if threads < 1 {
+		threads = 1
+	}
+	log.Printf("Starting miner workloop with initial target: %d (%d threads)", target, threads)
 
 	// Subscribe to head changes
-	headChangeCh := chain.SubscribeToHeadChanges()
+	headSub := chain.SubscribeToHeadChanges()
+	defer headSub.Unsubscribe()
+	headChangeCh := headSub.C
 
+mining:
 	for {
-		parent := chain.HeaderByHeight(chain.Height())
-		if parent == nil {
+		if ctx.Err() != nil || !ctl.wait(ctx) {
+			log.Printf("[MINER] Mining stopped")
+			return
+		}
+		_, ctlChanged := ctl.active()
+		tmpl := NewTemplate(chain, target, minerAddress)
+		if tmpl == nil {
 			log.Printf("[MINER][WARN] No chain head found yet (chain may be initializing). Waiting...")
 			time.Sleep(500 * time.Millisecond)
 			continue
 		}
-
-		height := parent.Height + 1
+		parent, height, currentTarget := tmpl.Parent, tmpl.Height, tmpl.Target
 		log.Printf("⛏️  Starting mining at height %d", height)
 
-		// Get current target (difficulty)
-		currentTarget := parent.Bits.Int64()
-		if currentTarget <= 0 {
-			log.Printf("[BUG] parent.Bits is nil or zero! Falling back to CLI target %d", target)
-			currentTarget = target
-		}
-
-		// Check if we need to retarget difficulty
-		if height%config.RetargetInterval == 0 && parent.Height > 0 {
-			// Use the parent header for difficulty adjustment
-			if t, err := core.Adjust(chain, parent); err == nil {
-				currentTarget = t.Int64()
-				log.Printf("🎯 Difficulty retarget: new target = %d", currentTarget)
-			} else {
-				log.Printf("[WARN] Difficulty adjustment failed: %v", err)
-			}
-		}
-
 		// Start probabilistic search with nonce
-		nonce := uint64(0)
-		tries := 0
-		lastLog := time.Now()
+		var tries atomic.Uint64
 		startTime := time.Now()
+		found := make(chan solution, 1)
+		searchCtx, cancelSearch := context.WithCancel(ctx)
+		var workers sync.WaitGroup
+		for i := 0; i < threads; i++ {
+			start, end := nonceRange(i, threads)
+			workers.Add(1)
+			go func(i int) {
+				defer workers.Done()
+				searchNonces(searchCtx, llm, i, tmpl, start, end, &tries, found)
+			}(i)
+		}
+		// stopSearch aborts every worker and waits for them to exit.
+		stopSearch := func() {
+			cancelSearch()
+			workers.Wait()
+		}
 
+		progress := time.NewTicker(5 * time.Second)
+		var sol solution
+	wait:
 		for {
-			// Generate procedural quiz based on block height and nonce
-			quizzes := dataset.ProceduralQuiz(height, nonce)
-
-			// Create prompt from quizzes - ask for answers
-			prompt := "Please answer these questions:\n"
-			for _, quiz := range quizzes {
-				prompt += quiz + "\n"
-			}
-			prompt += "Answers:\n"
-
-			if prompt == "" {
-				log.Printf("Skipping LLM inference: prompt is empty")
-				nonce++
-				runtime.Gosched()
-				continue
-			}
-
-			// Log the quiz being solved on every attempt
-			log.Printf("[MINER] Solving quiz: %s", func() string {
-				if len(quizzes) > 0 {
-					return quizzes[0]
-				}
-				return "empty quiz"
-			}())
-
-			// Run LLM inference (the "work")
-			// Create a deterministic seed from height
-			var heightBytes [8]byte
-			binary.LittleEndian.PutUint64(heightBytes[:], height)
-			llmSeed := int(binary.LittleEndian.Uint64(heightBytes[:]))
-
-			// Log LLM inference start on every attempt
-			log.Printf("[MINER] 🧠 Starting LLM inference (seed=%d, nonce=%d)...", llmSeed, nonce)
-
-			output, err := llm.Infer(prompt, llmSeed)
-			if err != nil {
-				log.Printf("LLM inference failed: %v", err)
-				nonce++
-				runtime.Gosched()
-				continue
-			}
-
-			// Calculate loss from LLM output (like hash in Bitcoin)
-			hash := sha256.Sum256([]byte(output))
-			lossInt := int64(binary.LittleEndian.Uint64(hash[:8]))
-
-			tries++
-
-			// Log every attempt to show progress
-			log.Printf("[MINER] Try %d: nonce=%d, loss=%d, target=%d, output='%s...'",
-				tries, nonce, lossInt, currentTarget,
-				func() string {
-					if len(output) > 50 {
-						return output[:50] + "..."
-					}
-					return output
-				}())
-
-			if tries%100 == 0 && time.Since(lastLog) > 5*time.Second {
+			select {
+			case <-ctx.Done():
+				stopSearch()
+				progress.Stop()
+				log.Printf("[MINER] Mining stopped")
+				return
+			case sol = <-found:
+				break wait
+			case <-progress.C:
 				elapsed := time.Since(startTime)
-				rate := float64(tries) / elapsed.Seconds()
-				log.Printf("[MINER] %.1f attempts/sec, %d tries, elapsed: %v", rate, tries, elapsed)
-				lastLog = time.Now()
-			}
-
-			// Check if we found a valid block (loss <= target)
-			if lossInt <= currentTarget {
-				log.Printf("🎉 BLOCK FOUND! Loss: %d <= Target: %d after %d tries", lossInt, currentTarget, tries)
-				log.Printf("⏱️  Mining time: %v", time.Since(startTime))
-
-				// Get transactions from mempool
-				transactions := chain.Mempool.GetTransactionsForBlock(100) // Max 100 txs per block
-
-				// Add coinbase transaction for miner
-				var minerAddr []byte
-				if minerAddress != "" {
-					// Parse the hex address
-					if addrBytes, err := hex.DecodeString(minerAddress); err == nil {
-						minerAddr = addrBytes
-					} else {
-						log.Printf("[WARN] Invalid miner address %s, using default", minerAddress)
-						minerAddr = []byte("miner-address-12345678901234567890123456789012")
-					}
-				} else {
-					minerAddr = []byte("miner-address-12345678901234567890123456789012")
+				n := tries.Load()
+				log.Printf("[MINER] %.1f attempts/sec, %d tries, elapsed: %v", float64(n)/elapsed.Seconds(), n, elapsed)
+			case <-ctlChanged:
+				var active bool
+				if active, ctlChanged = ctl.active(); !active {
+					stopSearch()
+					progress.Stop()
+					continue mining
 				}
-				subsidy := core.GetSubsidy(height)
-				coinbaseTx := core.NewCoinbaseTx(minerAddr, subsidy)
-				transactions = append([]*core.Transaction{coinbaseTx}, transactions...)
-
-				log.Printf("💰 Including %d transactions (1 coinbase + %d mempool)", len(transactions), len(transactions)-1)
-
-				// Create block with nonce
-				block := core.NewBlock(height, parent.Hash(), lossInt, parent.Bits, transactions, nonce)
-				if err := broadcaster.BroadcastBlock(block); err != nil {
-					log.Printf("Failed to broadcast block: %v", err)
-				}
-				if p2pNode != nil {
-					_ = p2pNode.PublishBlockFromStruct(block)
-				}
-
-				// Wait for head to advance to at least this block's height
-				for {
-					<-headChangeCh
-					for len(headChangeCh) > 0 {
-						<-headChangeCh
-					} // drain
-					newHead := chain.HeaderByHeight(chain.Height())
-					if newHead != nil && newHead.Height >= block.Header.Height {
-						parent = newHead
-						break
-					}
+			case <-headChangeCh:
+				// Got a new canonical head -> abort the template and start fresh
+				newParent := chain.HeaderByHeight(chain.Height())
+				if newParent != nil && newParent.Height > parent.Height {
+					stopSearch()
+					progress.Stop()
+					log.Printf("📈 Chain advanced to height %d, mining template invalidated, starting fresh", newParent.Height)
+					continue mining
 				}
-				break // break out of nonce loop, restart mining with new parent
 			}
+		}
+		stopSearch()
+		progress.Stop()
 
-			// Increment nonce for next attempt
-			nonce++
-			runtime.Gosched()
+		lossInt, nonce := sol.loss, sol.nonce
+		log.Printf("🎉 BLOCK FOUND! Loss: %d <= Target: %d after %d tries (worker %d)", lossInt, currentTarget, tries.Load(), sol.worker)
+		log.Printf("⏱️  Mining time: %v", time.Since(startTime))
 
-			// Check for head changes (other miners found blocks)
+		block := tmpl.Seal(chain, lossInt, nonce)
+		if err := broadcaster.BroadcastBlock(block); err != nil {
+			log.Printf("Failed to broadcast block: %v", err)
+		}
+		if p2pNode != nil {
+			_ = p2pNode.PublishBlockFromStruct(block)
+		}
+
+		// Wait for head to advance to at least this block's height
+		for {
 			select {
 			case <-headChangeCh:
-				// Got a new canonical head -> update parent and start fresh
-				newParent := chain.HeaderByHeight(chain.Height())
-				if newParent != nil && newParent.Height > parent.Height {
-					parent = newParent
-					log.Printf("📈 Chain advanced to height %d, mining template invalidated, starting fresh", parent.Height)
-					goto restart_mining
-				}
-			default:
-				// No head change, continue mining
+			case <-ctx.Done():
+				log.Printf("[MINER] Mining stopped")
+				return
+			}
+			for len(headChangeCh) > 0 {
+				<-headChangeCh
+			} // drain
+			newHead := chain.HeaderByHeight(chain.Height())
+			if newHead != nil && newHead.Height >= block.Header.Height {
+				break
 			}
 		}
-
-	restart_mining:
-		continue
 	}
 }
@@ -0,0 +1,196 @@
+// Package mobile exposes a POAI light client for iOS and Android.
+//
+// The API sticks to types gomobile can bind (string, int64, bool, []byte,
+// error and pointers to exported structs). Build with:
+//
+//	gomobile bind -target=android ./mobile
+//	gomobile bind -target=ios ./mobile
+//
+// Nothing here links the LLM: the light client syncs headers only and
+// checks their linkage and claimed work against the committed target.
+package mobile
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"poai/client"
+	"poai/core"
+	"poai/core/header"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Key is a secp256k1 account key.
+type Key struct {
+	priv *ecdsa.PrivateKey
+}
+
+// NewKey generates a fresh random key.
+func NewKey() (*Key, error) {
+	priv, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &Key{priv: priv}, nil
+}
+
+// ImportKey loads a key from its hex-encoded private key.
+func ImportKey(privHex string) (*Key, error) {
+	priv, err := crypto.HexToECDSA(privHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return &Key{priv: priv}, nil
+}
+
+// Address returns the hex address derived from the key.
+func (k *Key) Address() string {
+	return hex.EncodeToString(crypto.PubkeyToAddress(k.priv.PublicKey).Bytes())
+}
+
+// PrivateKeyHex exports the private key; store it in the platform keychain.
+func (k *Key) PrivateKeyHex() string {
+	return hex.EncodeToString(crypto.FromECDSA(k.priv))
+}
+
+// SignTransfer builds and signs a transfer and returns it as JSON, ready for
+// LightClient.SendSignedTransaction.
+func (k *Key) SignTransfer(to string, amount string, nonce int64) (string, error) {
+	toBytes, err := hex.DecodeString(to)
+	if err != nil {
+		return "", fmt.Errorf("invalid recipient address: %v", err)
+	}
+	amt, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid amount: %s", amount)
+	}
+	from := crypto.PubkeyToAddress(k.priv.PublicKey).Bytes()
+	tx := core.NewTx(from, toBytes, amt, uint64(nonce))
+	if err := tx.Sign(k.priv); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(tx)
+	return string(data), err
+}
+
+// LightClient follows the chain by headers only, via a full node's RPC.
+type LightClient struct {
+	rpc *client.Client
+
+	mu      sync.RWMutex
+	headers map[uint64]*header.Header
+	head    uint64
+}
+
+// NewLightClient creates a light client backed by the node at endpoint.
+func NewLightClient(endpoint string) *LightClient {
+	return &LightClient{
+		rpc:     client.New(endpoint),
+		headers: make(map[uint64]*header.Header),
+	}
+}
+
+// Head returns the height of the highest verified header.
+func (lc *LightClient) Head() int64 {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return int64(lc.head)
+}
+
+// SyncHeaders fetches headers up to the node's head, verifying each one
+// against its parent, and returns the new verified head height.
+func (lc *LightClient) SyncHeaders(timeoutSec int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	tip, err := lc.rpc.BlockNumber(ctx)
+	if err != nil {
+		return lc.Head(), err
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	start := lc.head + 1
+	if len(lc.headers) == 0 {
+		start = 0
+	}
+	for h := start; h <= tip; h++ {
+		hdr, err := lc.rpc.GetHeader(ctx, h)
+		if err != nil {
+			return int64(lc.head), err
+		}
+		if h > 0 {
+			if err := VerifyHeader(hdr, lc.headers[h-1]); err != nil {
+				return int64(lc.head), fmt.Errorf("header %d: %v", h, err)
+			}
+		}
+		lc.headers[h] = hdr
+		lc.head = h
+	}
+	return int64(lc.head), nil
+}
+
+// HeaderHash returns the hex hash of a verified header, or "" if unknown.
+func (lc *LightClient) HeaderHash(height int64) string {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	hdr, ok := lc.headers[uint64(height)]
+	if !ok {
+		return ""
+	}
+	h := hdr.Hash()
+	return hex.EncodeToString(h[:])
+}
+
+// Balance returns the decimal balance of addr as reported by the node.
+func (lc *LightClient) Balance(addr string) (string, error) {
+	a, err := hex.DecodeString(addr)
+	if err != nil {
+		return "", err
+	}
+	bal, err := lc.rpc.GetBalance(context.Background(), a)
+	if err != nil {
+		return "", err
+	}
+	return bal.String(), nil
+}
+
+// Nonce returns the next nonce the node expects from addr.
+func (lc *LightClient) Nonce(addr string) (int64, error) {
+	a, err := hex.DecodeString(addr)
+	if err != nil {
+		return 0, err
+	}
+	n, err := lc.rpc.GetNonce(context.Background(), a)
+	return int64(n), err
+}
+
+// SendSignedTransaction submits a transaction produced by Key.SignTransfer
+// and returns its hex hash.
+func (lc *LightClient) SendSignedTransaction(txJSON string) (string, error) {
+	var tx core.Transaction
+	if err := json.Unmarshal([]byte(txJSON), &tx); err != nil {
+		return "", fmt.Errorf("invalid transaction: %v", err)
+	}
+	if err := tx.Verify(); err != nil {
+		return "", err
+	}
+	h, err := lc.rpc.SendTransaction(context.Background(), &tx)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h), nil
+}
+
+// VerifyHeader checks that hdr links to parent and that its claimed loss
+// meets the target it commits to. It cannot replay the AI work itself.
+func VerifyHeader(hdr, parent *header.Header) error {
+	return core.VerifyHeaderLink(hdr, parent)
+}
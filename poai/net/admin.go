@@ -0,0 +1,164 @@
+package net
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"poai/core"
+	"poai/core/config"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerInfo describes one connected peer.
+type PeerInfo struct {
+	ID        string   `json:"id"`
+	Addrs     []string `json:"addrs"`
+	Direction string   `json:"direction"` // "inbound" or "outbound" (first connection)
+	LatencyMs float64  `json:"latencyMs"` // round-trip time, 0 if not measured yet
+	Agent     string   `json:"agent,omitempty"`
+	Static    bool     `json:"static"`
+	Source    string   `json:"source,omitempty"` // how an outbound peer was found: bootstrap, mdns, inbound or static
+	NetGroup  string   `json:"netGroup,omitempty"`
+	// Protocol version, height and head from the handshake; Version is 0
+	// if the peer did not handshake.
+	Version uint32 `json:"version"`
+	Height  uint64 `json:"height"`
+	Head    string `json:"head,omitempty"`
+}
+
+// NodeInfo describes the local node.
+type NodeInfo struct {
+	ID      string   `json:"id"`
+	Addrs   []string `json:"addrs"` // full multiaddrs, including /p2p/<id>
+	Agent   string   `json:"agent"`
+	Version uint32   `json:"version"`
+	ChainID uint64   `json:"chainId"`
+	Genesis string   `json:"genesis"`
+	Height  uint64   `json:"height"`
+	Head    string   `json:"head"`
+	Peers   int      `json:"peers"`
+	// Syncing is set while the node catches up to BestKnown, the highest
+	// head announced by peers.
+	Syncing   bool   `json:"syncing"`
+	BestKnown uint64 `json:"bestKnownHeight"`
+	// ReorgAlert is the last reorg refused for exceeding --max-reorg-depth
+	ReorgAlert *core.ReorgAlert `json:"reorgAlert,omitempty"`
+}
+
+// Peers returns the connected peers, sorted by ID.
+func (n *P2PNode) Peers() []PeerInfo {
+	ps := n.Host.Peerstore()
+	ids := n.Host.Network().Peers()
+	out := make([]PeerInfo, 0, len(ids))
+	for _, p := range ids {
+		conns := n.Host.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		info := PeerInfo{
+			ID:        p.String(),
+			Direction: "outbound",
+			LatencyMs: float64(ps.LatencyEWMA(p).Microseconds()) / 1000,
+			Static:    n.static.isStatic(p),
+		}
+		if conns[0].Stat().Direction == network.DirInbound {
+			info.Direction = "inbound"
+		} else {
+			info.Source = n.outbound.source(p)
+		}
+		info.NetGroup = netGroup(conns[0].RemoteMultiaddr())
+		for _, c := range conns {
+			info.Addrs = append(info.Addrs, c.RemoteMultiaddr().String())
+		}
+		if v, err := ps.Get(p, "AgentVersion"); err == nil {
+			info.Agent, _ = v.(string)
+		}
+		if st := n.PeerStatus(p); st != nil {
+			info.Version, info.Height = st.Version, st.Height
+			info.Head = hex.EncodeToString(st.Head[:])
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// NodeInfo returns the identity, addresses and head of the local node.
+func (n *P2PNode) NodeInfo() NodeInfo {
+	st := n.localStatus()
+	info := NodeInfo{
+		ID:      n.Host.ID().String(),
+		Agent:   agentPrefix + string(config.Role),
+		Version: st.Version,
+		ChainID: st.ChainID,
+		Genesis: hex.EncodeToString(st.Genesis[:]),
+		Height:  st.Height,
+		Head:    hex.EncodeToString(st.Head[:]),
+		Peers:   len(n.Host.Network().Peers()),
+
+		Syncing:    n.Syncing(),
+		BestKnown:  n.BestKnownHeight(),
+		ReorgAlert: n.Chain.ReorgAlert(),
+	}
+	for _, a := range n.Host.Addrs() {
+		info.Addrs = append(info.Addrs, fmt.Sprintf("%s/p2p/%s", a, n.Host.ID()))
+	}
+	return info
+}
+
+// CheckHost reports whether the P2P host is up and listening.
+func (n *P2PNode) CheckHost() error {
+	if n.ctx.Err() != nil {
+		return fmt.Errorf("p2p node stopped")
+	}
+	if len(n.Host.Network().ListenAddresses()) == 0 {
+		return fmt.Errorf("p2p host not listening")
+	}
+	return nil
+}
+
+// CheckSynced reports whether the local head is within maxLag blocks of the
+// best head announced by peers.
+func (n *P2PNode) CheckSynced(maxLag uint64) error {
+	height, best := n.Chain.CurrentHeight(), n.BestKnownHeight()
+	if best > height+maxLag {
+		return fmt.Errorf("at #%d, %d blocks behind best known head #%d", height, best-height, best)
+	}
+	return nil
+}
+
+// AddPeer dials addr, a multiaddr ending in /p2p/<id>, and keeps the peer
+// connected like a --static-peers entry.
+func (n *P2PNode) AddPeer(addr string) error {
+	infos, err := ParsePeerAddrs([]string{addr})
+	if err != nil {
+		return err
+	}
+	if n.scores.banned(infos[0].ID) {
+		return fmt.Errorf("peer %s is banned", infos[0].ID)
+	}
+	n.AddStaticPeers(n.ctx, infos)
+	return nil
+}
+
+// RemovePeer disconnects a peer and stops redialing it if it was added
+// with AddPeer or --static-peers. It reports whether the peer was
+// connected or static.
+func (n *P2PNode) RemovePeer(id string) (bool, error) {
+	p, err := peer.Decode(id)
+	if err != nil {
+		return false, err
+	}
+	static := n.static.remove(p)
+	if static {
+		n.Host.ConnManager().Unprotect(p, tagStatic)
+	}
+	connected := n.Host.Network().Connectedness(p) == network.Connected
+	if err := n.Host.Network().ClosePeer(p); err != nil {
+		return false, err
+	}
+	return static || connected, nil
+}
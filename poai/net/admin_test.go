@@ -0,0 +1,71 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"poai/core"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+func newTestNode(t *testing.T, ctx context.Context) *P2PNode {
+	t.Helper()
+	return newTestNodeWithGenesis(t, ctx, core.DefaultGenesis(1000))
+}
+
+func newTestNodeWithGenesis(t *testing.T, ctx context.Context, g *core.Genesis) *P2PNode {
+	t.Helper()
+	chain, err := core.NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+	n, err := NewP2PNode(ctx, P2PConfig{ListenAddrs: []string{"/ip4/127.0.0.1/tcp/0"}}, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestAdminPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := newTestNode(t, ctx), newTestNode(t, ctx)
+
+	info := b.NodeInfo()
+	if info.ID != b.Host.ID().String() || len(info.Addrs) == 0 || info.Version != ProtocolVersion {
+		t.Fatalf("node info %+v", info)
+	}
+	if err := a.AddPeer(info.Addrs[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "handshake", func() bool { return a.PeerStatus(b.Host.ID()) != nil })
+	peers := a.Peers()
+	if len(peers) != 1 || peers[0].ID != info.ID || !peers[0].Static || peers[0].Direction != "outbound" || peers[0].Version != ProtocolVersion {
+		t.Fatalf("peers %+v", peers)
+	}
+
+	if ok, err := a.RemovePeer(info.ID); err != nil || !ok {
+		t.Fatalf("remove: %v, %v", ok, err)
+	}
+	waitFor(t, "disconnect", func() bool { return a.Host.Network().Connectedness(b.Host.ID()) != network.Connected })
+	// Removed static peers are not redialed
+	time.Sleep(2 * bootstrapMinBackoff)
+	if len(a.Peers()) != 0 {
+		t.Fatalf("peer redialed: %+v", a.Peers())
+	}
+}
@@ -0,0 +1,117 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+// minBurst lets a single large sync response through a slow limiter.
+const minBurst = 4 << 20
+
+// BandwidthLimits caps P2P traffic in bytes per second; 0 means unlimited.
+type BandwidthLimits struct {
+	UploadBps       int64 // all peers combined
+	DownloadBps     int64
+	PeerUploadBps   int64 // each peer
+	PeerDownloadBps int64
+}
+
+// bandwidthLimiter enforces BandwidthLimits for gossip and sync serving.
+// Uploads wait for budget; downloads over budget are dropped, since gossip
+// will deliver them again from another peer or a later sync.
+type bandwidthLimiter struct {
+	limits BandwidthLimits
+	up     *rate.Limiter
+	down   *rate.Limiter
+
+	mu    sync.Mutex
+	peers map[peer.ID]*peerBandwidth
+}
+
+type peerBandwidth struct {
+	up   *rate.Limiter
+	down *rate.Limiter
+}
+
+func newLimiter(bps int64) *rate.Limiter {
+	if bps <= 0 {
+		return nil
+	}
+	burst := int(bps)
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return rate.NewLimiter(rate.Limit(bps), burst)
+}
+
+func newBandwidthLimiter(l BandwidthLimits) *bandwidthLimiter {
+	return &bandwidthLimiter{
+		limits: l,
+		up:     newLimiter(l.UploadBps),
+		down:   newLimiter(l.DownloadBps),
+		peers:  make(map[peer.ID]*peerBandwidth),
+	}
+}
+
+func (b *bandwidthLimiter) peer(p peer.ID) *peerBandwidth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pb, ok := b.peers[p]
+	if !ok {
+		pb = &peerBandwidth{up: newLimiter(b.limits.PeerUploadBps), down: newLimiter(b.limits.PeerDownloadBps)}
+		b.peers[p] = pb
+	}
+	return pb
+}
+
+// allowDownload reports whether n bytes received from p fit the download budget.
+func (b *bandwidthLimiter) allowDownload(p peer.ID, n int) bool {
+	if pl := b.peer(p).down; pl != nil && !pl.AllowN(time.Now(), n) {
+		return false
+	}
+	if b.down != nil && !b.down.AllowN(time.Now(), n) {
+		return false
+	}
+	return true
+}
+
+// waitUpload blocks until n bytes may be sent to p ("" = broadcast, global budget only).
+func (b *bandwidthLimiter) waitUpload(ctx context.Context, p peer.ID, n int) error {
+	if p != "" {
+		if pl := b.peer(p).up; pl != nil {
+			if err := waitN(ctx, pl, n); err != nil {
+				return err
+			}
+		}
+	}
+	if b.up != nil {
+		return waitN(ctx, b.up, n)
+	}
+	return nil
+}
+
+// waitN waits for n tokens, splitting requests larger than the burst.
+func waitN(ctx context.Context, l *rate.Limiter, n int) error {
+	for n > 0 {
+		chunk := n
+		if chunk > l.Burst() {
+			chunk = l.Burst()
+		}
+		if err := l.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// forget drops per-peer state when a peer disconnects.
+func (b *bandwidthLimiter) forget(p peer.ID) {
+	b.mu.Lock()
+	delete(b.peers, p)
+	b.mu.Unlock()
+}
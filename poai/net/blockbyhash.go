@@ -0,0 +1,169 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// BlockByHashProtocol fetches one block by its hash, which is all an
+// orphan says about its missing parent.
+const BlockByHashProtocol = protocol.ID("/poai/getblockbyhash/1")
+
+const (
+	// blockByHashFanout is how many peers are asked, one after another,
+	// before falling back to a height range.
+	blockByHashFanout = 3
+	// maxParentChase is how far above the head a fetched parent may be
+	// before its own ancestors are fetched as a range instead of one at a
+	// time.
+	maxParentChase = 64
+)
+
+// BlockByHashRequest asks for the block with the given hash.
+type BlockByHashRequest struct {
+	Hash [32]byte
+}
+
+// BlockByHashResponse carries the block, or an error if the peer does not
+// have it.
+type BlockByHashResponse struct {
+	Block *core.Block `rlp:"nil"`
+	Error string
+}
+
+// handleBlockByHashStream serves one request on an inbound block-by-hash
+// stream.
+func (n *P2PNode) handleBlockByHashStream(s network.Stream) {
+	defer s.Close()
+	p := s.Conn().RemotePeer()
+	if n.scores.banned(p) || !config.Role.ServesBlocks() {
+		s.Reset()
+		return
+	}
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+
+	var resp BlockByHashResponse
+	var req BlockByHashRequest
+	if !n.syncLimits.allowRequest(p) {
+		resp.Error = errRateLimited
+	} else if _, err := readMsg(bufio.NewReader(s), &req, maxSyncRequest); err != nil {
+		n.scores.penalize(p, MisbehaviourMalformed)
+		s.Reset()
+		return
+	} else if resp.Block = n.Chain.BlockByHash(req.Hash); resp.Block == nil {
+		resp.Error = "block not found"
+	}
+
+	data, err := rlp.EncodeToBytes(&resp)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if !n.syncLimits.waitResponse(n.ctx, p, len(data)) {
+		if data, err = rlp.EncodeToBytes(&BlockByHashResponse{Error: errRateLimited}); err != nil {
+			s.Reset()
+			return
+		}
+	}
+	if err := n.bandwidth.waitUpload(n.ctx, p, len(data)); err != nil {
+		s.Reset()
+		return
+	}
+	if _, err := writeFrame(s, data); err != nil {
+		s.Reset()
+	}
+}
+
+// blockByHashCall asks p for the block with hash h.
+func (n *P2PNode) blockByHashCall(p peer.ID, h [32]byte) (*core.Block, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, syncStreamTimeout)
+	defer cancel()
+	s, err := n.Host.NewStream(ctx, p, BlockByHashProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+	if _, err := writeMsg(s, BlockByHashRequest{Hash: h}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite()
+
+	var resp BlockByHashResponse
+	size, err := readMsg(bufio.NewReader(s), &resp, maxSyncMessage)
+	if err != nil {
+		if errors.Is(err, errMalformedMsg) {
+			n.scores.penalize(p, MisbehaviourMalformed)
+		}
+		s.Reset()
+		return nil, err
+	}
+	if !n.bandwidth.allowDownload(p, size) {
+		return nil, fmt.Errorf("download budget exceeded")
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("peer error: %s", resp.Error)
+	}
+	if resp.Block == nil || resp.Block.Hash() != h {
+		n.scores.penalize(p, MisbehaviourInvalidBlock)
+		return nil, fmt.Errorf("peer sent another block")
+	}
+	return resp.Block, nil
+}
+
+// blockByHashPeers returns up to max connected, unbanned peers that serve
+// BlockByHashProtocol.
+func (n *P2PNode) blockByHashPeers(max int) []peer.ID {
+	var out []peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if len(out) == max {
+			break
+		}
+		if n.scores.banned(p) {
+			continue
+		}
+		if protos, err := n.Host.Peerstore().SupportsProtocols(p, BlockByHashProtocol); err == nil && len(protos) > 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// fetchBlockByHash asks peers for the block with hash h until one has it,
+// and imports it. A block that is itself an orphan requests its parent in
+// turn, unless it is far above the head: then the gap is fetched as a
+// range. It reports whether a peer sent the block.
+func (n *P2PNode) fetchBlockByHash(h [32]byte) bool {
+	for _, p := range n.blockByHashPeers(blockByHashFanout) {
+		blk, err := n.blockByHashCall(p, h)
+		if err != nil {
+			log.Printf("[SYNC] Block %x from %s failed: %v", h[:8], p, err)
+			continue
+		}
+		log.Printf("[SYNC] Fetched block #%d (%x) by hash from %s", blk.Header.Height, h[:8], p)
+		if head := n.Chain.CurrentHeight(); blk.Header.Height > head+maxParentChase {
+			n.fetchBlocksAny(head+1, blk.Header.Height)
+			return true
+		}
+		n.seen.add(h)
+		if err := n.Chain.ImportBlockContext(core.WithPeer(context.Background(), p.String()), blk); err != nil {
+			log.Printf("[SYNC] Failed to import block #%d fetched by hash: %v", blk.Header.Height, err)
+			n.penalizeInvalid(p, err)
+		}
+		return true
+	}
+	return false
+}
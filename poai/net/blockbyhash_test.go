@@ -0,0 +1,38 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"math/big"
+	"testing"
+
+	"poai/core"
+)
+
+func TestBlockByHashFrames(t *testing.T) {
+	blk := core.NewBlock(3, [32]byte{1}, 7, big.NewInt(100), nil, 9)
+	var buf bytes.Buffer
+	if _, err := writeMsg(&buf, BlockByHashRequest{Hash: blk.Hash()}); err != nil {
+		t.Fatal(err)
+	}
+	var req BlockByHashRequest
+	if _, err := readMsg(bufio.NewReader(&buf), &req, maxSyncRequest); err != nil || req.Hash != blk.Hash() {
+		t.Fatalf("request %x, %v", req.Hash, err)
+	}
+
+	for _, resp := range []*BlockByHashResponse{{Block: blk}, {Error: "block not found"}} {
+		if _, err := writeMsg(&buf, resp); err != nil {
+			t.Fatal(err)
+		}
+		var got BlockByHashResponse
+		if _, err := readMsg(bufio.NewReader(&buf), &got, maxSyncMessage); err != nil {
+			t.Fatal(err)
+		}
+		if (got.Block == nil) != (resp.Block == nil) || got.Error != resp.Error {
+			t.Fatalf("response %+v, want %+v", got, resp)
+		}
+		if got.Block != nil && got.Block.Hash() != blk.Hash() {
+			t.Fatal("block changed in transit")
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const (
+	bootstrapMinBackoff = time.Second
+	bootstrapMaxBackoff = 5 * time.Minute
+	// staticStableAfter is how long a static peer connection must last for
+	// the redial backoff to start over after it drops.
+	staticStableAfter = time.Minute
+)
+
+// ParsePeerAddrs converts /p2p multiaddrs into AddrInfos, merging addresses
+// of the same peer.
+func ParsePeerAddrs(addrs []string) ([]peer.AddrInfo, error) {
+	maddrs := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		m, err := ma.NewMultiaddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiaddr %q: %w", a, err)
+		}
+		maddrs = append(maddrs, m)
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(maddrs...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address: %w", err)
+	}
+	return infos, nil
+}
+
+// LoadPeerFile reads multiaddrs from a file, one per line. Blank lines and
+// lines starting with # are ignored.
+func LoadPeerFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var addrs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	return addrs, sc.Err()
+}
+
+// ConnectBootstrapPeers dials every peer in the background, retrying with
+// exponential backoff until it connects or ctx is done. The peers also
+// become outbound candidates, redialed if their connection drops.
+func (n *P2PNode) ConnectBootstrapPeers(ctx context.Context, peers []peer.AddrInfo) {
+	for _, pi := range peers {
+		n.outbound.addCandidate(pi, sourceBootstrap, time.Now())
+		go n.dialWithBackoff(ctx, pi)
+	}
+}
+
+func (n *P2PNode) dialWithBackoff(ctx context.Context, pi peer.AddrInfo) {
+	backoff := bootstrapMinBackoff
+	for attempt := 1; ; attempt++ {
+		dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := n.Host.Connect(dialCtx, pi)
+		cancel()
+		if err == nil {
+			n.outbound.noteSource(pi.ID, sourceBootstrap)
+			log.Printf("[P2P] Connected to bootstrap peer %s", pi.ID)
+			return
+		}
+		wait := jitter(backoff)
+		log.Printf("[P2P] Bootstrap peer %s unreachable (attempt %d): %v; retrying in %v", pi.ID, attempt, err, wait.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// jitter spreads d over [d/2, 3d/2), so peers that lost a common
+// connection do not all redial at the same moment.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + rand.N(d)
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	return min(2*d, bootstrapMaxBackoff)
+}
+
+// staticPeers are kept connected until removed: whenever the connection
+// to one drops, or a dial fails, it is redialed with exponential backoff
+// and jitter.
+type staticPeers struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*staticPeer
+}
+
+type staticPeer struct {
+	down   chan struct{} // signalled when the peer disconnects
+	cancel context.CancelFunc
+}
+
+// disconnected wakes the redial loop of p, if it is a static peer.
+func (s *staticPeers) disconnected(p peer.ID) {
+	s.mu.Lock()
+	sp := s.peers[p]
+	s.mu.Unlock()
+	if sp != nil {
+		select {
+		case sp.down <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// remove stops redialing p; it reports whether p was a static peer.
+func (s *staticPeers) remove(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, ok := s.peers[p]
+	if ok {
+		sp.cancel()
+		delete(s.peers, p)
+	}
+	return ok
+}
+
+// isStatic reports whether p is a static peer.
+func (s *staticPeers) isStatic(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.peers[p]
+	return ok
+}
+
+// AddStaticPeers keeps the peers connected until ctx is done or they are
+// removed with RemovePeer.
+func (n *P2PNode) AddStaticPeers(ctx context.Context, peers []peer.AddrInfo) {
+	n.static.mu.Lock()
+	defer n.static.mu.Unlock()
+	if n.static.peers == nil {
+		n.static.peers = make(map[peer.ID]*staticPeer)
+	}
+	for _, pi := range peers {
+		if _, ok := n.static.peers[pi.ID]; ok {
+			continue
+		}
+		peerCtx, cancel := context.WithCancel(ctx)
+		sp := &staticPeer{down: make(chan struct{}, 1), cancel: cancel}
+		n.static.peers[pi.ID] = sp
+		n.Host.ConnManager().Protect(pi.ID, tagStatic)
+		go n.keepConnected(peerCtx, pi, sp.down)
+	}
+}
+
+// keepConnected dials pi, and again each time down signals a disconnect.
+func (n *P2PNode) keepConnected(ctx context.Context, pi peer.AddrInfo, down <-chan struct{}) {
+	backoff := bootstrapMinBackoff
+	for attempt := 1; ; attempt++ {
+		if n.Host.Network().Connectedness(pi.ID) != network.Connected {
+			dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			err := n.Host.Connect(dialCtx, pi)
+			cancel()
+			if err != nil {
+				wait := jitter(backoff)
+				log.Printf("[P2P] Static peer %s unreachable (attempt %d): %v; retrying in %v", pi.ID, attempt, err, wait.Round(time.Second))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+				backoff = nextBackoff(backoff)
+				continue
+			}
+			n.outbound.noteSource(pi.ID, sourceStatic)
+			log.Printf("[P2P] Connected to static peer %s", pi.ID)
+		}
+		connected := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-down:
+		}
+		if time.Since(connected) >= staticStableAfter {
+			backoff, attempt = bootstrapMinBackoff, 0
+		}
+		log.Printf("[P2P] Static peer %s disconnected; redialing", pi.ID)
+	}
+}
@@ -0,0 +1,24 @@
+package net
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffJitter(t *testing.T) {
+	d := bootstrapMinBackoff
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 50; j++ {
+			if w := jitter(d); w < d/2 || w >= d+d/2 {
+				t.Fatalf("jitter(%v) = %v", d, w)
+			}
+		}
+		d = nextBackoff(d)
+	}
+	if d != bootstrapMaxBackoff {
+		t.Fatalf("backoff grew to %v, want cap %v", d, bootstrapMaxBackoff)
+	}
+	if nextBackoff(3*time.Second) != 6*time.Second {
+		t.Fatal("backoff does not double")
+	}
+}
@@ -0,0 +1,163 @@
+package net
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// checkpointState tracks the newest trusted checkpoint and fast-bootstrap progress.
+type checkpointState struct {
+	mu        sync.Mutex
+	latest    *core.Checkpoint
+	bootstrap bool      // waiting to bootstrap from a checkpoint
+	deadline  time.Time // give up and sync from genesis after this
+}
+
+// bootstrapTimeout bounds how long a fast-bootstrap node waits for a checkpoint.
+const bootstrapTimeout = 2 * time.Minute
+
+// bootstrapPending reports whether block sync should wait for a checkpoint.
+func (n *P2PNode) bootstrapPending() bool {
+	n.checkpoints.mu.Lock()
+	defer n.checkpoints.mu.Unlock()
+	if n.checkpoints.bootstrap && time.Now().After(n.checkpoints.deadline) {
+		log.Printf("[CHECKPOINT] No usable checkpoint within %v, syncing from genesis", bootstrapTimeout)
+		n.checkpoints.bootstrap = false
+	}
+	return n.checkpoints.bootstrap
+}
+
+func (n *P2PNode) startCheckpointHandlers(ctx context.Context, ps *pubsub.PubSub) error {
+	cpSub, err := ps.Subscribe(TopicCheckpoint)
+	if err != nil {
+		return err
+	}
+	go n.handleCheckpoint(ctx, cpSub)
+	return nil
+}
+
+// LatestCheckpoint returns the newest trusted checkpoint seen, or nil.
+func (n *P2PNode) LatestCheckpoint() *core.Checkpoint {
+	n.checkpoints.mu.Lock()
+	defer n.checkpoints.mu.Unlock()
+	return n.checkpoints.latest
+}
+
+// PublishCheckpoint gossips a signed checkpoint.
+func (n *P2PNode) PublishCheckpoint(cp *core.Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	log.Printf("[CHECKPOINT] Publishing checkpoint #%d (%x)", cp.Height, cp.BlockHash[:8])
+	return n.PubSub.Publish(TopicCheckpoint, data)
+}
+
+// StartCheckpointSigner signs a checkpoint every CheckpointInterval blocks and
+// re-announces the latest one periodically for nodes that join later.
+func (n *P2PNode) StartCheckpointSigner(ctx context.Context, key *ecdsa.PrivateKey) {
+	headSub := n.Chain.SubscribeToHeadChanges()
+	go func() {
+		defer headSub.Unsubscribe()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		var latest *core.Checkpoint
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if latest != nil {
+					n.PublishCheckpoint(latest)
+				}
+			case <-headSub.C:
+				h := n.Chain.CurrentHeight()
+				if h == 0 || h%config.CheckpointInterval != 0 || (latest != nil && latest.Height == h) {
+					continue
+				}
+				cp, err := n.Chain.NewCheckpoint(h)
+				if err != nil {
+					log.Printf("[CHECKPOINT] Cannot build checkpoint at #%d: %v", h, err)
+					continue
+				}
+				if err := cp.Sign(key); err != nil {
+					log.Printf("[CHECKPOINT] Signing failed: %v", err)
+					continue
+				}
+				latest = cp
+				n.PublishCheckpoint(cp)
+			}
+		}
+	}()
+}
+
+// handleCheckpoint verifies incoming checkpoints and, in fast-bootstrap
+// mode, requests the matching snapshot.
+func (n *P2PNode) handleCheckpoint(ctx context.Context, sub *pubsub.Subscription) {
+	for {
+		raw, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		var cp core.Checkpoint
+		if err := json.Unmarshal(raw.Data, &cp); err != nil {
+			continue
+		}
+		if err := core.VerifyCheckpoint(&cp); err != nil {
+			log.Printf("[CHECKPOINT] Rejected checkpoint #%d from %s: %v", cp.Height, raw.ReceivedFrom, err)
+			continue
+		}
+		n.checkpoints.mu.Lock()
+		if n.checkpoints.latest != nil && n.checkpoints.latest.Height >= cp.Height {
+			n.checkpoints.mu.Unlock()
+			continue
+		}
+		n.checkpoints.latest = &cp
+		wantSnapshot := n.checkpoints.bootstrap && n.Chain.CurrentHeight() < cp.Height
+		n.checkpoints.mu.Unlock()
+		log.Printf("[CHECKPOINT] Trusted checkpoint #%d (%x)", cp.Height, cp.BlockHash[:8])
+
+		if wantSnapshot {
+			log.Printf("[CHECKPOINT] Requesting state snapshot at #%d", cp.Height)
+			n.requestSnapshot(cp.Height)
+		}
+	}
+}
+
+// onSnapshotResponse bootstraps the chain from a snapshot that matches the
+// fast-sync pivot header or the trusted checkpoint.
+func (n *P2PNode) onSnapshotResponse(from peer.ID, resp *SnapshotResponse) {
+	if resp.Block == nil || resp.Snapshot == nil {
+		return
+	}
+	if n.onSyncSnapshot(from, resp) {
+		return // fast sync, validated against the header chain
+	}
+	n.checkpoints.mu.Lock()
+	waiting, cp := n.checkpoints.bootstrap, n.checkpoints.latest
+	n.checkpoints.mu.Unlock()
+	if !waiting || cp == nil {
+		return
+	}
+	if resp.Snapshot.Height != cp.Height {
+		return
+	}
+	// Always validate against our own trusted checkpoint, not the peer's
+	if err := n.Chain.BootstrapFromCheckpoint(cp, resp.Block, resp.Snapshot); err != nil {
+		log.Printf("[CHECKPOINT] Snapshot from %s rejected: %v", from, err)
+		return
+	}
+	n.checkpoints.mu.Lock()
+	n.checkpoints.bootstrap = false
+	n.checkpoints.mu.Unlock()
+}
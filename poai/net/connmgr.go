@@ -0,0 +1,61 @@
+package net
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+)
+
+// Default peer count watermarks; see PeerLimits.
+const (
+	DefaultPeersLow  = 32
+	DefaultPeersHigh = 64
+	// peerGracePeriod is how long a new connection is exempt from trimming,
+	// so peers get to finish identify and show what they are good for.
+	peerGracePeriod = time.Minute
+)
+
+// Protection tags: connections to peers holding one are never trimmed.
+const (
+	tagStatic = "poai-static" // configured static peers
+	tagSync   = "poai-sync"   // peers serving the chain being synced
+)
+
+// PeerLimits bounds the number of connected peers. Once more than High are
+// connected, the least useful unprotected ones are closed until Low remain.
+type PeerLimits struct {
+	Low  int
+	High int
+}
+
+// options returns the libp2p connection manager option for the limits.
+func (l PeerLimits) options() ([]libp2p.Option, error) {
+	if l.Low == 0 && l.High == 0 {
+		l = PeerLimits{Low: DefaultPeersLow, High: DefaultPeersHigh}
+	}
+	if l.Low < 0 || l.High <= 0 || l.Low > l.High {
+		return nil, fmt.Errorf("invalid peer limits: low %d, high %d", l.Low, l.High)
+	}
+	cm, err := connmgr.NewConnManager(l.Low, l.High, connmgr.WithGracePeriod(peerGracePeriod))
+	if err != nil {
+		return nil, err
+	}
+	return []libp2p.Option{libp2p.ConnectionManager(cm)}, nil
+}
+
+// protectSyncPeersLocked makes peers the ones serving the selected chain,
+// moving the sync protection over from the previous ones; hsync.mu must be
+// held.
+func (n *P2PNode) protectSyncPeersLocked(peers []peer.ID) {
+	cm := n.Host.ConnManager()
+	for _, p := range n.hsync.bestPeers {
+		cm.Unprotect(p, tagSync)
+	}
+	for _, p := range peers {
+		cm.Protect(p, tagSync)
+	}
+	n.hsync.bestPeers = peers
+}
@@ -0,0 +1,16 @@
+package net
+
+import "testing"
+
+func TestPeerLimits(t *testing.T) {
+	for _, l := range []PeerLimits{{}, {Low: 4, High: 8}, {Low: 0, High: 1}} {
+		if _, err := l.options(); err != nil {
+			t.Errorf("%+v: %v", l, err)
+		}
+	}
+	for _, l := range []PeerLimits{{Low: 8, High: 4}, {Low: -1, High: 4}, {Low: 4}} {
+		if _, err := l.options(); err == nil {
+			t.Errorf("%+v accepted", l)
+		}
+	}
+}
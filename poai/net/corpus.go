@@ -0,0 +1,270 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"poai/dataset"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// CorpusProtocol serves the encrypted corpus to peers bootstrapping it.
+// Only nodes holding a corpus register it, so identify doubles as the
+// advertisement.
+const CorpusProtocol = protocol.ID("/poai/corpus/1.0.0")
+
+const (
+	// ShardSize is the number of records in one shard request.
+	ShardSize = 256
+	// corpusFanout is how many shards are fetched in parallel.
+	corpusFanout = 4
+	// corpusRetryDelay is the wait before retrying when no peer could help.
+	corpusRetryDelay = 5 * time.Second
+)
+
+// CorpusRequest asks for the index, or for one shard of records.
+type CorpusRequest struct {
+	Index bool
+	Shard uint64
+}
+
+// CorpusResponse answers a CorpusRequest with the encoded Σ.idx or the
+// shard's records as stored (encrypted).
+type CorpusResponse struct {
+	Index   []byte
+	Records [][]byte
+	Error   string
+}
+
+// ServeCorpus starts serving c to peers.
+func (n *P2PNode) ServeCorpus(c *dataset.Corpus) {
+	n.corpus.Store(c)
+	n.Host.SetStreamHandler(CorpusProtocol, n.handleCorpusStream)
+}
+
+// handleCorpusStream serves one request on an inbound corpus stream.
+func (n *P2PNode) handleCorpusStream(s network.Stream) {
+	defer s.Close()
+	p := s.Conn().RemotePeer()
+	c := n.corpus.Load()
+	if n.scores.banned(p) || c == nil {
+		s.Reset()
+		return
+	}
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+
+	var req CorpusRequest
+	if _, err := readMsg(bufio.NewReader(s), &req, maxSyncRequest); err != nil {
+		n.scores.penalize(p, MisbehaviourMalformed)
+		s.Reset()
+		return
+	}
+	var resp CorpusResponse
+	if req.Index {
+		resp.Index = c.EncodeIndex()
+	} else if first := req.Shard * ShardSize; first >= c.Len() {
+		resp.Error = fmt.Sprintf("shard %d out of range", req.Shard)
+	} else {
+		for i := first; i < first+ShardSize && i < c.Len(); i++ {
+			rec, _ := c.Stored(i)
+			resp.Records = append(resp.Records, rec)
+		}
+	}
+
+	data, err := rlp.EncodeToBytes(&resp)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if err := n.bandwidth.waitUpload(n.ctx, p, len(data)); err != nil {
+		s.Reset()
+		return
+	}
+	if _, err := writeFrame(s, data); err != nil {
+		s.Reset()
+	}
+}
+
+// corpusCall sends one request to p and waits for the response.
+func (n *P2PNode) corpusCall(ctx context.Context, p peer.ID, req CorpusRequest) (*CorpusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, syncStreamTimeout)
+	defer cancel()
+	s, err := n.Host.NewStream(ctx, p, CorpusProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+	if _, err := writeMsg(s, req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite()
+
+	var resp CorpusResponse
+	size, err := readMsg(bufio.NewReader(s), &resp, maxSyncMessage)
+	if err != nil {
+		if errors.Is(err, errMalformedMsg) {
+			n.scores.penalize(p, MisbehaviourMalformed)
+		}
+		s.Reset()
+		return nil, err
+	}
+	if !n.bandwidth.allowDownload(p, size) {
+		return nil, fmt.Errorf("download budget exceeded")
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("peer error: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// corpusPeers returns the connected, unbanned peers serving the corpus.
+func (n *P2PNode) corpusPeers() []peer.ID {
+	var out []peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if n.scores.banned(p) {
+			continue
+		}
+		if protos, err := n.Host.Peerstore().SupportsProtocols(p, CorpusProtocol); err == nil && len(protos) > 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// FetchCorpus makes the corpus in dir complete: an index whose SHA3-256 is
+// indexHash and every record matching it. Whatever is missing or corrupt
+// is fetched from peers, retrying until done or ctx is cancelled.
+func (n *P2PNode) FetchCorpus(ctx context.Context, dir string, indexHash [32]byte) error {
+	for {
+		done, err := n.fetchCorpusOnce(ctx, dir, indexHash)
+		if done || ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			log.Printf("[CORPUS] %v; retrying in %v", err, corpusRetryDelay)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(corpusRetryDelay):
+		}
+	}
+}
+
+// fetchCorpusOnce runs one pass over the corpus; done reports completion.
+func (n *P2PNode) fetchCorpusOnce(ctx context.Context, dir string, indexHash [32]byte) (done bool, err error) {
+	indexData, err := os.ReadFile(filepath.Join(dir, dataset.IndexFile))
+	if err != nil || dataset.IndexHash(indexData) != indexHash {
+		if indexData, err = n.fetchIndex(ctx, indexHash); err != nil {
+			return false, err
+		}
+	}
+	asm, err := dataset.NewAssembler(dir, indexData)
+	if err != nil {
+		return true, fmt.Errorf("corpus index: %v", err)
+	}
+	defer asm.Close()
+	missing, err := asm.Missing()
+	if err != nil {
+		return true, err
+	}
+	if len(missing) == 0 {
+		return true, nil
+	}
+
+	shards := make(map[uint64]bool)
+	for _, i := range missing {
+		shards[i/ShardSize] = true
+	}
+	peers := n.corpusPeers()
+	if len(peers) == 0 {
+		return false, fmt.Errorf("%d of %d records missing and no peer serves the corpus", len(missing), asm.Len())
+	}
+	log.Printf("[CORPUS] Fetching %d records in %d shards from %d peers", len(missing), len(shards), len(peers))
+
+	jobs := make(chan uint64)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for w := 0; w < corpusFanout; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for shard := range jobs {
+				if !n.fetchShard(ctx, asm, shard, peers, w) {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	for shard := range shards {
+		jobs <- shard
+	}
+	close(jobs)
+	wg.Wait()
+	if failed > 0 {
+		return false, fmt.Errorf("%d shards could not be fetched", failed)
+	}
+	return false, nil // the next pass re-checks every record
+}
+
+// fetchIndex asks corpus peers for Σ.idx until one matches indexHash.
+func (n *P2PNode) fetchIndex(ctx context.Context, indexHash [32]byte) ([]byte, error) {
+	peers := n.corpusPeers()
+	for _, p := range peers {
+		resp, err := n.corpusCall(ctx, p, CorpusRequest{Index: true})
+		if err != nil {
+			log.Printf("[CORPUS] Index request to %s failed: %v", p, err)
+			continue
+		}
+		if dataset.IndexHash(resp.Index) != indexHash {
+			log.Printf("[CORPUS] Peer %s served an index with the wrong hash", p)
+			n.scores.penalize(p, MisbehaviourInvalidRecord)
+			continue
+		}
+		return resp.Index, nil
+	}
+	return nil, fmt.Errorf("no matching corpus index from %d peers", len(peers))
+}
+
+// fetchShard fetches one shard, starting with peer w and moving on to the
+// next peer on failure. Records that fail their hash get the peer
+// penalised.
+func (n *P2PNode) fetchShard(ctx context.Context, asm *dataset.Assembler, shard uint64, peers []peer.ID, w int) bool {
+	for k := range peers {
+		p := peers[(w+k)%len(peers)]
+		resp, err := n.corpusCall(ctx, p, CorpusRequest{Shard: shard})
+		if err != nil {
+			log.Printf("[CORPUS] Shard %d request to %s failed: %v", shard, p, err)
+			continue
+		}
+		ok := len(resp.Records) > 0
+		for j, rec := range resp.Records {
+			if err := asm.Put(shard*ShardSize+uint64(j), rec); err != nil {
+				log.Printf("[CORPUS] Peer %s: %v", p, err)
+				n.scores.penalize(p, MisbehaviourInvalidRecord)
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
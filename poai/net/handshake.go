@@ -0,0 +1,143 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"poai/core/config"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// HandshakeProtocol exchanges a Status when two nodes connect, so nodes of
+// another network or an incompatible version are dropped before their
+// blocks reach gossip and sync.
+const HandshakeProtocol = protocol.ID("/poai/handshake/1")
+
+// ProtocolVersion is the version of the node-to-node protocols; peers must
+// run the same one.
+const ProtocolVersion = 1
+
+const (
+	// handshakeTimeout bounds one status exchange.
+	handshakeTimeout = 10 * time.Second
+	// incompatibleBanDuration keeps incompatible peers from redialing in a
+	// loop; they will not become compatible any time soon.
+	incompatibleBanDuration = 24 * time.Hour
+)
+
+// Status describes a node's network and head.
+type Status struct {
+	Version uint32
+	ChainID uint64
+	Genesis [32]byte
+	Height  uint64
+	Head    [32]byte
+}
+
+// localStatus returns this node's status.
+func (n *P2PNode) localStatus() Status {
+	st := Status{
+		Version: ProtocolVersion,
+		ChainID: config.ChainID,
+		Genesis: n.Chain.Genesis().Hash(),
+		Height:  n.Chain.CurrentHeight(),
+	}
+	if blk := n.Chain.BlockByHeight(st.Height); blk != nil {
+		st.Head = blk.Hash()
+	}
+	return st
+}
+
+// compatible returns why a peer with status st cannot join this node's
+// network, or nil if it can.
+func (n *P2PNode) compatible(st *Status) error {
+	local := n.localStatus()
+	switch {
+	case st.Version != local.Version:
+		return fmt.Errorf("protocol version %d, want %d", st.Version, local.Version)
+	case st.ChainID != local.ChainID:
+		return fmt.Errorf("chain ID %d, want %d", st.ChainID, local.ChainID)
+	case st.Genesis != local.Genesis:
+		return fmt.Errorf("genesis %x, want %x", st.Genesis[:8], local.Genesis[:8])
+	}
+	return nil
+}
+
+// handleHandshakeStream answers a peer's status with ours.
+func (n *P2PNode) handleHandshakeStream(s network.Stream) {
+	defer s.Close()
+	p := s.Conn().RemotePeer()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+	var st Status
+	if _, err := readMsg(bufio.NewReader(s), &st, maxSyncRequest); err != nil {
+		n.scores.penalize(p, MisbehaviourMalformed)
+		s.Reset()
+		return
+	}
+	// Answer even an incompatible peer, so it can tell why it is dropped
+	if _, err := writeMsg(s, n.localStatus()); err != nil {
+		s.Reset()
+		return
+	}
+	n.onStatus(p, &st)
+}
+
+// handshake sends our status to p over a new connection and checks the
+// answer. Peers that do not speak HandshakeProtocol are kept.
+func (n *P2PNode) handshake(p peer.ID) {
+	ctx, cancel := context.WithTimeout(n.ctx, handshakeTimeout)
+	defer cancel()
+	s, err := n.Host.NewStream(ctx, p, HandshakeProtocol)
+	if err != nil {
+		if protos, perr := n.Host.Peerstore().SupportsProtocols(p, HandshakeProtocol); perr == nil && len(protos) == 0 {
+			log.Printf("[P2P] Peer %s does not support the handshake; keeping it", p)
+		} else {
+			log.Printf("[P2P] Handshake with %s failed: %v", p, err)
+		}
+		return
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+	if _, err := writeMsg(s, n.localStatus()); err != nil {
+		s.Reset()
+		return
+	}
+	s.CloseWrite()
+	var st Status
+	if _, err := readMsg(bufio.NewReader(s), &st, maxSyncRequest); err != nil {
+		log.Printf("[P2P] Handshake with %s failed: %v", p, err)
+		n.scores.penalize(p, MisbehaviourMalformed)
+		s.Reset()
+		return
+	}
+	n.onStatus(p, &st)
+}
+
+// onStatus drops p if st is incompatible, and otherwise records it and
+// catches up with p's head.
+func (n *P2PNode) onStatus(p peer.ID, st *Status) {
+	if err := n.compatible(st); err != nil {
+		log.Printf("[P2P] Dropping incompatible peer %s: %v", p, err)
+		n.scores.ban(p, incompatibleBanDuration, "incompatible: "+err.Error())
+		return
+	}
+	n.statuses.Store(p, st)
+	log.Printf("[P2P] Handshake with %s: head #%d (%x)", p, st.Height, st.Head[:8])
+	if st.Height > 0 {
+		n.onPeerHead(p, st.Height)
+	}
+}
+
+// PeerStatus returns the status p sent in the handshake, or nil.
+func (n *P2PNode) PeerStatus(p peer.ID) *Status {
+	if v, ok := n.statuses.Load(p); ok {
+		return v.(*Status)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"poai/core"
+)
+
+func TestHandshakeCompatible(t *testing.T) {
+	chain, err := core.NewMemoryChain(core.DefaultGenesis(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	n := &P2PNode{Chain: chain}
+
+	local := n.localStatus()
+	var buf bytes.Buffer
+	if _, err := writeMsg(&buf, local); err != nil {
+		t.Fatal(err)
+	}
+	var st Status
+	if _, err := readMsg(bufio.NewReader(&buf), &st, maxSyncRequest); err != nil {
+		t.Fatal(err)
+	}
+	if st != local {
+		t.Fatalf("status %+v, want %+v", st, local)
+	}
+	if err := n.compatible(&st); err != nil {
+		t.Fatalf("own status incompatible: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*Status){
+		"version":  func(s *Status) { s.Version++ },
+		"chain ID": func(s *Status) { s.ChainID++ },
+		"genesis":  func(s *Status) { s.Genesis[0] ^= 1 },
+	} {
+		other := local
+		mutate(&other)
+		if err := n.compatible(&other); err == nil {
+			t.Errorf("other %s accepted", name)
+		}
+	}
+	other := local
+	other.Height += 10
+	if err := n.compatible(&other); err != nil {
+		t.Errorf("peer ahead rejected: %v", err)
+	}
+}
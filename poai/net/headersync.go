@@ -0,0 +1,521 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/core/header"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// headersFirstGap is how far behind a node must be before it syncs
+	// headers first instead of requesting blocks straight away.
+	headersFirstGap = 64
+	// maxHeadersPerResp caps one header response.
+	maxHeadersPerResp = 2000
+	// bodyBatch is the number of blocks asked for in one body request.
+	bodyBatch = 64
+	// maxBodyRequests is how many body requests may be in flight at once.
+	maxBodyRequests = 8
+	// syncRequestTimeout is how long a header or body request may go
+	// unanswered before it is sent again (to another peer if possible).
+	syncRequestTimeout = 15 * time.Second
+	// maxSyncStalls is how many timeouts in a row abandon headers-first
+	// sync in favour of plain block requests.
+	maxSyncStalls = 4
+	// pivotQuorum is how many network groups (see netGroup) must serve the
+	// same fast-sync pivot header before its snapshot is trusted without a
+	// signed checkpoint. Peer IDs cost nothing, so they are not counted.
+	pivotQuorum = 3
+)
+
+// headerSync drives headers-first sync: header chains are fetched in
+// batches and validated per peer, the longest one is selected, and block
+// bodies for it are downloaded in parallel from the peers that follow it.
+type headerSync struct {
+	mu     sync.Mutex
+	active bool
+	target uint64 // highest height announced by peers
+	base   uint64 // local head when sync started
+
+	chains    map[peer.ID][]*header.Header // validated headers base+1.. per peer
+	groups    map[peer.ID]string           // network group each chain came from
+	best      []*header.Header             // selected chain
+	bestPeers []peer.ID                    // peers serving the selected chain
+	nextPeer  int
+
+	headerReqAt time.Time
+	stalls      int
+
+	nextBody uint64               // next height to request a body for
+	inflight map[uint64]time.Time // body batch start -> request time
+	bodies   map[uint64]*core.Block
+
+	// Fast sync: restore state at a pivot instead of replaying history
+	pivotPending bool
+	pivot        uint64
+	snapshotAt   time.Time
+}
+
+// startHeaderSync runs the sync retry ticker.
+func (n *P2PNode) startHeaderSync(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(syncRequestTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n.retrySync()
+			}
+		}
+	}()
+}
+
+// syncing reports whether headers-first sync is running.
+func (n *P2PNode) syncing() bool {
+	n.hsync.mu.Lock()
+	defer n.hsync.mu.Unlock()
+	return n.hsync.active
+}
+
+// SyncLag is how many blocks a peer's announced head may be ahead of ours
+// before the node counts as catching up.
+const SyncLag = 3
+
+// Syncing reports whether the node is catching up with its peers:
+// headers-first sync is running or a peer has announced a head more than
+// SyncLag blocks ahead.
+func (n *P2PNode) Syncing() bool {
+	return n.syncing() || n.BestKnownHeight() > n.Chain.Height()+SyncLag
+}
+
+// beginHeaderSync starts (or extends) headers-first sync towards target.
+func (n *P2PNode) beginHeaderSync(target uint64) {
+	s := &n.hsync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		if target > s.target {
+			s.target = target
+		}
+		return
+	}
+	base := n.Chain.CurrentHeight()
+	*s = headerSync{
+		active:   true,
+		target:   target,
+		base:     base,
+		chains:   make(map[peer.ID][]*header.Header),
+		groups:   make(map[peer.ID]string),
+		nextBody: base + 1,
+		inflight: make(map[uint64]time.Time),
+		bodies:   make(map[uint64]*core.Block),
+		// Only an empty node can adopt a snapshot
+		pivotPending: n.fastSync && base == 0,
+	}
+	log.Printf("[SYNC] Headers-first sync from #%d towards #%d", base, target)
+	n.requestHeadersLocked(base+1, "")
+}
+
+// requestHeadersLocked asks server (or several peers if "") for the next
+// header batch; s.mu must be held.
+func (n *P2PNode) requestHeadersLocked(from uint64, server peer.ID) {
+	s := &n.hsync
+	to := from + maxHeadersPerResp - 1
+	if to > s.target {
+		to = s.target
+	}
+	s.headerReqAt = time.Now()
+	peers := []peer.ID{server}
+	if server == "" {
+		peers = n.syncPeers(headerFanout)
+	}
+	for _, p := range peers {
+		go n.fetchHeaders(p, from, to)
+	}
+}
+
+// onHeaders extends from's header chain with a validated batch.
+func (n *P2PNode) onHeaders(from peer.ID, headers []*header.Header) {
+	s := &n.hsync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain := s.chains[from]
+	next := s.base + uint64(len(chain)) + 1
+	if headers[0].Height != next {
+		return // not the batch we expect from this peer
+	}
+	parent := n.Chain.HeaderByHeight(s.base)
+	if len(chain) > 0 {
+		parent = chain[len(chain)-1]
+	}
+	for _, hdr := range headers {
+		err := core.VerifyHeaderLink(hdr, parent)
+		if err == nil {
+			// Targets follow the difficulty rule along the peer's own chain
+			err = core.VerifyHeaderTarget(&peerHeaders{local: n.Chain, base: s.base, chain: chain}, hdr, parent)
+			if err != nil && !errors.Is(err, core.ErrInvalidBlock) {
+				log.Printf("[SYNC] Cannot check the target of header #%d from %s: %v", hdr.Height, from, err)
+				return
+			}
+		}
+		if err != nil {
+			log.Printf("[SYNC] Invalid header #%d from %s: %v; dropping its chain", hdr.Height, from, err)
+			delete(s.chains, from)
+			delete(s.groups, from)
+			n.scores.penalize(from, MisbehaviourInvalidHeader)
+			return
+		}
+		chain = append(chain, hdr)
+		parent = hdr
+	}
+	s.chains[from] = chain
+	s.groups[from] = n.peerNetGroup(from)
+	s.stalls = 0
+
+	tip := parent.Height
+	if tip > s.target {
+		s.target = tip
+	}
+	if len(headers) == maxHeadersPerResp && tip < s.target {
+		n.requestHeadersLocked(tip+1, from)
+	}
+	n.selectBestChainLocked()
+	if s.pivotPending && tip >= s.target {
+		n.choosePivotLocked()
+	}
+	n.requestBodiesLocked()
+}
+
+// peerHeaders reads a peer's header chain above base and the local chain
+// at and below it, so difficulty can be checked along the peer's chain.
+type peerHeaders struct {
+	local core.ChainReader
+	base  uint64
+	chain []*header.Header // headers base+1..
+}
+
+func (r *peerHeaders) HeaderByHeight(h uint64) *header.Header {
+	if h <= r.base {
+		return r.local.HeaderByHeight(h)
+	}
+	if i := h - r.base - 1; i < uint64(len(r.chain)) {
+		return r.chain[i]
+	}
+	return nil
+}
+
+func (r *peerHeaders) Height() uint64 {
+	return r.base + uint64(len(r.chain))
+}
+
+// choosePivotLocked picks the newest snapshot height on the selected chain
+// and, once the pivot header is trusted, asks peers for the state there.
+func (n *P2PNode) choosePivotLocked() {
+	s := &n.hsync
+	tip := s.base + uint64(len(s.best))
+	pivot := tip / config.CheckpointInterval * config.CheckpointInterval
+	if config.CheckpointInterval == 0 || pivot == 0 {
+		s.pivotPending = false // chain too short for a snapshot
+		return
+	}
+	if s.pivot == pivot {
+		return
+	}
+	if !s.pivotTrusted(s.expected(pivot), n.LatestCheckpoint()) {
+		return // wait for more peers' headers or a checkpoint
+	}
+	s.pivot = pivot
+	n.requestSnapshotLocked()
+}
+
+// pivotTrusted reports whether the state at hdr may be taken from a
+// snapshot: a trusted checkpoint vouches for hdr and its state root, or
+// peers from pivotQuorum network groups serve hdr on their validated header
+// chains. Header validation cannot replay PoAI work, so one adversary could
+// otherwise fabricate a chain and a state to go with it. Peers on private
+// addresses count as a single group.
+func (s *headerSync) pivotTrusted(hdr *header.Header, cp *core.Checkpoint) bool {
+	if hdr == nil {
+		return false
+	}
+	hash := hdr.Hash()
+	if cp != nil && cp.Height == hdr.Height {
+		return cp.BlockHash == hash && cp.StateRoot == hdr.StateRoot
+	}
+	i := hdr.Height - s.base - 1
+	agree := make(map[string]bool)
+	for p, chain := range s.chains {
+		if i < uint64(len(chain)) && chain[i].Hash() == hash {
+			agree[s.groups[p]] = true
+		}
+	}
+	return len(agree) >= pivotQuorum
+}
+
+func (n *P2PNode) requestSnapshotLocked() {
+	s := &n.hsync
+	s.snapshotAt = time.Now()
+	log.Printf("[SYNC] Fast sync: requesting state snapshot at pivot #%d", s.pivot)
+	n.requestSnapshot(s.pivot)
+}
+
+// onSyncSnapshot adopts a snapshot from peer from that matches the
+// selected header chain's StateRoot at the pivot. It reports whether the
+// response was consumed.
+func (n *P2PNode) onSyncSnapshot(from peer.ID, resp *SnapshotResponse) bool {
+	s := &n.hsync
+	s.mu.Lock()
+	if !s.active || !s.pivotPending || s.pivot == 0 || resp.Snapshot.Height != s.pivot {
+		s.mu.Unlock()
+		return false
+	}
+	pivot := s.pivot
+	hdr := s.expected(pivot)
+	s.mu.Unlock()
+	if hdr == nil || resp.Block.Hash() != hdr.Hash() || resp.Block.Header.StateRoot != hdr.StateRoot {
+		log.Printf("[SYNC] Snapshot block #%d is not on the selected chain", pivot)
+		return true
+	}
+	// Replaying the pivot's PoAI work may take a while; sync goes on meanwhile
+	if err := n.Chain.BootstrapFromSnapshot(resp.Block, resp.Snapshot); err != nil {
+		log.Printf("[SYNC] Snapshot at #%d from %s rejected: %v", pivot, from, err)
+		if errors.Is(err, core.ErrInvalidBlock) {
+			n.scores.penalize(from, MisbehaviourInvalidBlock)
+		}
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pivotPending = false
+	s.stalls = 0
+	s.nextBody = pivot + 1
+	n.requestBodiesLocked()
+	return true
+}
+
+// selectBestChainLocked picks the longest validated header chain and the
+// peers that agree with it.
+func (n *P2PNode) selectBestChainLocked() {
+	s := &n.hsync
+	var best []*header.Header
+	for _, chain := range s.chains {
+		if len(chain) > len(best) {
+			best = chain
+		}
+	}
+	if len(best) == 0 {
+		return
+	}
+	tipHash := best[len(best)-1].Hash()
+	var peers []peer.ID
+	for p, chain := range s.chains {
+		if len(chain) == len(best) && chain[len(chain)-1].Hash() == tipHash {
+			peers = append(peers, p)
+		}
+	}
+	for i := 0; i < len(s.best) && i < len(best); i++ {
+		if best[i].Hash() == s.best[i].Hash() {
+			continue
+		}
+		// A different fork won from here; drop bodies fetched for the old one
+		fork := s.base + uint64(i) + 1
+		for h := range s.bodies {
+			if h >= fork {
+				delete(s.bodies, h)
+			}
+		}
+		s.inflight = make(map[uint64]time.Time)
+		if s.nextBody > fork {
+			s.nextBody = fork
+		}
+		break
+	}
+	s.best = best
+	n.protectSyncPeersLocked(peers)
+}
+
+// expected returns the selected header at height h, or nil.
+func (s *headerSync) expected(h uint64) *header.Header {
+	if h <= s.base || h > s.base+uint64(len(s.best)) {
+		return nil
+	}
+	return s.best[h-s.base-1]
+}
+
+// bodyPeerLocked returns the next peer to ask for bodies, round robin.
+func (n *P2PNode) bodyPeerLocked() peer.ID {
+	s := &n.hsync
+	if len(s.bestPeers) == 0 {
+		return ""
+	}
+	p := s.bestPeers[s.nextPeer%len(s.bestPeers)]
+	s.nextPeer++
+	return p
+}
+
+// requestBodiesLocked keeps up to maxBodyRequests body batches in flight.
+func (n *P2PNode) requestBodiesLocked() {
+	s := &n.hsync
+	if s.pivotPending {
+		return // bodies start after the snapshot pivot
+	}
+	tip := s.base + uint64(len(s.best))
+	for len(s.inflight) < maxBodyRequests && s.nextBody <= tip {
+		n.sendBodyRequestLocked(s.nextBody)
+		s.nextBody += bodyBatch
+	}
+}
+
+func (n *P2PNode) sendBodyRequestLocked(start uint64) {
+	s := &n.hsync
+	end := start + bodyBatch - 1
+	if tip := s.base + uint64(len(s.best)); end > tip {
+		end = tip
+	}
+	s.inflight[start] = time.Now()
+	p := n.bodyPeerLocked()
+	if p == "" {
+		peers := n.syncPeers(1)
+		if len(peers) == 0 {
+			return // retried by the sync ticker
+		}
+		p = peers[0]
+	}
+	go n.fetchBlocks(p, start, end)
+}
+
+// onBodies keeps blocks that match the selected header chain and returns the
+// ones that can be imported now, in order.
+func (n *P2PNode) onBodies(blocks []*core.Block) []*core.Block {
+	s := &n.hsync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, blk := range blocks {
+		if hdr := s.expected(blk.Header.Height); hdr != nil && hdr.Hash() == blk.Hash() {
+			s.bodies[blk.Header.Height] = blk
+		}
+	}
+
+	head := n.Chain.CurrentHeight()
+	var ready []*core.Block
+	for h := head + 1; ; h++ {
+		blk, ok := s.bodies[h]
+		if !ok {
+			break
+		}
+		ready = append(ready, blk)
+		delete(s.bodies, h)
+	}
+	have := func(h uint64) bool {
+		_, buffered := s.bodies[h]
+		return buffered || h <= head+uint64(len(ready))
+	}
+	// Batches whose blocks have all arrived are no longer in flight
+	for start := range s.inflight {
+		done := true
+		for h := start; h < start+bodyBatch && s.expected(h) != nil; h++ {
+			if !have(h) {
+				done = false
+				break
+			}
+		}
+		if done {
+			delete(s.inflight, start)
+		}
+	}
+	if len(ready) > 0 {
+		s.stalls = 0
+	}
+	return ready
+}
+
+// afterBodiesImported requests more bodies or finishes the sync.
+func (n *P2PNode) afterBodiesImported() {
+	s := &n.hsync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	height := n.Chain.CurrentHeight()
+	if height >= s.target {
+		log.Printf("[SYNC] Headers-first sync complete at #%d", height)
+		s.active = false
+		n.protectSyncPeersLocked(nil)
+		return
+	}
+	n.requestBodiesLocked()
+}
+
+// retrySync re-sends timed out requests and abandons a stalled sync.
+func (n *P2PNode) retrySync() {
+	s := &n.hsync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active {
+		return
+	}
+	now := time.Now()
+	if s.pivotPending && s.pivot == 0 && len(s.best) > 0 && s.base+uint64(len(s.best)) >= s.target {
+		// Headers are complete but no pivot is trusted yet
+		if now.Sub(s.headerReqAt) <= syncRequestTimeout {
+			return
+		}
+		s.headerReqAt = now
+		if s.stalls++; s.stalls >= maxSyncStalls {
+			log.Printf("[SYNC] Fast sync: no trusted pivot, syncing full history")
+			s.pivotPending = false
+			s.stalls = 0
+			n.requestBodiesLocked()
+			return
+		}
+		n.requestHeadersLocked(s.base+1, "")
+		return
+	}
+	if s.pivotPending && s.pivot > 0 {
+		if now.Sub(s.snapshotAt) <= syncRequestTimeout {
+			return
+		}
+		if s.stalls++; s.stalls >= maxSyncStalls {
+			log.Printf("[SYNC] Fast sync: no snapshot for pivot #%d, syncing full history", s.pivot)
+			s.pivotPending = false
+			s.stalls = 0
+			n.requestBodiesLocked()
+			return
+		}
+		n.requestSnapshotLocked()
+		return
+	}
+	timedOut := false
+	if len(s.best) == 0 || s.base+uint64(len(s.best)) < s.target {
+		if now.Sub(s.headerReqAt) > syncRequestTimeout {
+			timedOut = true
+			next := s.base + uint64(len(s.best)) + 1
+			n.requestHeadersLocked(next, "")
+		}
+	}
+	for start, at := range s.inflight {
+		if now.Sub(at) > syncRequestTimeout {
+			timedOut = true
+			n.sendBodyRequestLocked(start) // next peer in rotation
+		}
+	}
+	if !timedOut {
+		return
+	}
+	s.stalls++
+	if s.stalls >= maxSyncStalls {
+		log.Printf("[SYNC] Headers-first sync stalled at #%d, falling back to block requests", n.Chain.CurrentHeight())
+		s.active = false
+		n.protectSyncPeersLocked(nil)
+	}
+}
@@ -0,0 +1,48 @@
+package net
+
+import (
+	"fmt"
+	"testing"
+
+	"poai/core"
+	"poai/core/header"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPivotNeedsQuorumOrCheckpoint(t *testing.T) {
+	honest := []*header.Header{{Height: 1}, {Height: 2, Nonce: 1}}
+	forged := []*header.Header{{Height: 1}, {Height: 2, Nonce: 2}}
+	s := &headerSync{
+		chains: map[peer.ID][]*header.Header{"a": honest, "b": forged},
+		groups: map[peer.ID]string{"a": "1.2.0.0/16", "b": "5.6.0.0/16"},
+	}
+	pivot := honest[1]
+	if s.pivotTrusted(pivot, nil) {
+		t.Fatal("pivot served by one peer trusted")
+	}
+
+	// Peers from one subnet are one vote however many there are
+	for i := 1; i < pivotQuorum; i++ {
+		id := peer.ID(rune('c' + i))
+		s.chains[id], s.groups[id] = honest, "1.2.0.0/16"
+	}
+	if s.pivotTrusted(pivot, nil) {
+		t.Fatalf("pivot served by %d peers of one subnet trusted", pivotQuorum)
+	}
+	for i := 1; i < pivotQuorum; i++ {
+		s.groups[peer.ID(rune('c'+i))] = fmt.Sprintf("%d.0.0.0/16", 10+i)
+	}
+	if !s.pivotTrusted(pivot, nil) {
+		t.Fatalf("pivot served by %d subnets not trusted", pivotQuorum)
+	}
+
+	// A checkpoint at the pivot height decides on its own
+	cp := &core.Checkpoint{Height: 2, BlockHash: forged[1].Hash(), StateRoot: forged[1].StateRoot}
+	if s.pivotTrusted(pivot, cp) {
+		t.Fatal("pivot trusted against the checkpoint")
+	}
+	if !s.pivotTrusted(forged[1], cp) {
+		t.Fatal("checkpointed pivot not trusted")
+	}
+}
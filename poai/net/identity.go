@@ -0,0 +1,55 @@
+package net
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// identityFile holds the node's libp2p private key inside the data dir.
+const identityFile = "p2p_identity.key"
+
+// LoadOrCreateIdentity returns the libp2p key persisted under dataDir,
+// generating and saving a new Ed25519 key on first start or when fresh is
+// set. A stable key keeps the Peer ID, and so peer books and bans, valid
+// across restarts.
+func LoadOrCreateIdentity(dataDir string, fresh bool) (crypto.PrivKey, error) {
+	path := filepath.Join(dataDir, identityFile)
+	if !fresh {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			priv, err := crypto.UnmarshalPrivateKey(data)
+			if err != nil {
+				return nil, fmt.Errorf("decode identity %s: %w", path, err)
+			}
+			return priv, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("save identity: %w", err)
+	}
+	if id, err := peer.IDFromPrivateKey(priv); err == nil {
+		log.Printf("[P2P] Generated new node identity %s", id)
+	}
+	return priv, nil
+}
@@ -0,0 +1,29 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestIdentityPersists(t *testing.T) {
+	dir := t.TempDir()
+	idOf := func(fresh bool) peer.ID {
+		priv, err := LoadOrCreateIdentity(dir, fresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first := idOf(false)
+	if again := idOf(false); again != first {
+		t.Fatalf("identity changed across loads: %s != %s", again, first)
+	}
+	if fresh := idOf(true); fresh == first {
+		t.Fatal("--new-identity reused the old key")
+	}
+}
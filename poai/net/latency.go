@@ -0,0 +1,122 @@
+package net
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// slowPropagation is the receipt delay above which a block is logged as slow.
+const slowPropagation = 5 * time.Second
+
+// latencySamples is how many recent samples are kept per peer.
+const latencySamples = 256
+
+var (
+	blockReceiptDelay = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "poai_block_receipt_delay_seconds",
+		Help:       "Delay between a block's timestamp and its local receipt via gossip.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	})
+	blockImportDelay = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "poai_block_import_delay_seconds",
+		Help:       "Delay between a block's timestamp and its successful local import.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	})
+)
+
+func init() {
+	prometheus.MustRegister(blockReceiptDelay, blockImportDelay)
+}
+
+// LatencyStats summarises propagation delays observed from one peer.
+type LatencyStats struct {
+	Samples int
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+}
+
+// latencyTracker keeps a ring buffer of receipt delays per peer.
+type latencyTracker struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*latencyRing
+}
+
+type latencyRing struct {
+	buf  [latencySamples]time.Duration
+	next int
+	n    int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{peers: make(map[peer.ID]*latencyRing)}
+}
+
+// observeReceipt records how long after its timestamp a block arrived from p.
+func (t *latencyTracker) observeReceipt(p peer.ID, height uint64, blockTime time.Time) {
+	d := time.Since(blockTime)
+	if d < 0 {
+		d = 0 // clock skew; timestamp validation handles the far future
+	}
+	blockReceiptDelay.Observe(d.Seconds())
+	if d > slowPropagation {
+		log.Printf("[P2P] Slow propagation: block #%d from %s arrived %v after its timestamp", height, p, d.Round(time.Millisecond))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.peers[p]
+	if !ok {
+		r = &latencyRing{}
+		t.peers[p] = r
+	}
+	r.buf[r.next] = d
+	r.next = (r.next + 1) % latencySamples
+	if r.n < latencySamples {
+		r.n++
+	}
+}
+
+// observeImport records how long after its timestamp a block was imported.
+func (t *latencyTracker) observeImport(blockTime time.Time) {
+	if d := time.Since(blockTime); d >= 0 {
+		blockImportDelay.Observe(d.Seconds())
+	}
+}
+
+// stats returns per-peer percentiles.
+func (t *latencyTracker) stats() map[peer.ID]LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[peer.ID]LatencyStats, len(t.peers))
+	for p, r := range t.peers {
+		samples := make([]time.Duration, r.n)
+		copy(samples, r.buf[:r.n])
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		out[p] = LatencyStats{
+			Samples: r.n,
+			P50:     percentile(samples, 0.50),
+			P90:     percentile(samples, 0.90),
+			P99:     percentile(samples, 0.99),
+		}
+	}
+	return out
+}
+
+// percentile expects sorted input.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+// PropagationStats returns block propagation delay percentiles per peer.
+func (n *P2PNode) PropagationStats() map[peer.ID]LatencyStats {
+	return n.latency.stats()
+}
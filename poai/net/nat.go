@@ -0,0 +1,41 @@
+package net
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/libp2p/go-libp2p"
+)
+
+// NATConfig controls how a node behind NAT becomes reachable.
+type NATConfig struct {
+	PortMap      bool     // open ports on the router via UPnP/NAT-PMP and run AutoNAT
+	HolePunching bool     // upgrade relayed connections to direct ones (DCUtR)
+	RelayPeers   []string // circuit relays to reserve a slot on when not publicly reachable
+	RelayService bool     // serve as a circuit relay for NATed peers
+}
+
+// options returns the libp2p options for the NAT settings.
+func (c NATConfig) options() ([]libp2p.Option, error) {
+	var opts []libp2p.Option
+	if c.PortMap {
+		// AutoNAT probes tell us (and our peers) whether we are reachable
+		opts = append(opts, libp2p.NATPortMap(), libp2p.EnableNATService(), libp2p.EnableAutoNATv2())
+	}
+	if c.HolePunching {
+		opts = append(opts, libp2p.EnableHolePunching())
+	}
+	if len(c.RelayPeers) > 0 {
+		relays, err := ParsePeerAddrs(c.RelayPeers)
+		if err != nil {
+			return nil, fmt.Errorf("relay peers: %w", err)
+		}
+		opts = append(opts, libp2p.EnableAutoRelayWithStaticRelays(relays))
+		log.Printf("[P2P] Auto-relay enabled with %d static relays", len(relays))
+	}
+	if c.RelayService {
+		opts = append(opts, libp2p.EnableRelayService())
+		log.Printf("[P2P] Circuit relay service enabled")
+	}
+	return opts, nil
+}
@@ -0,0 +1,359 @@
+package net
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	gonet "net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Discovery sources of outbound peers. The candidates the node dials on
+// its own come from the first three; static peers are dialed regardless.
+const (
+	sourceBootstrap = "bootstrap" // --bootstrap-peers and the network preset
+	sourceMDNS      = "mdns"      // the local network
+	sourceInbound   = "inbound"   // listen addresses of peers that dialed us
+	sourceStatic    = "static"    // --static-peers and admin_addPeer
+)
+
+const (
+	// DefaultOutboundPeers is how many outbound connections a node keeps.
+	DefaultOutboundPeers = 8
+	// DefaultOutboundRotation is how often one outbound connection is
+	// replaced.
+	DefaultOutboundRotation = 30 * time.Minute
+
+	outboundInterval = 30 * time.Second // how often missing connections are dialed
+	candidateRetry   = 10 * time.Minute // before a candidate that failed is dialed again
+	maxCandidates    = 1000
+)
+
+// OutboundConfig controls the outbound connections the node makes on its
+// own. To make it hard for one adversary to become a node's whole view of
+// the network, outbound peers must be in distinct network groups (/16 for
+// IPv4, /32 for IPv6), the discovery source with the fewest outbound peers
+// is dialed from first, and one outbound connection is replaced every
+// Rotation.
+type OutboundConfig struct {
+	Target   int           // outbound connections to keep (0 = no automatic dialing)
+	Rotation time.Duration // how often one is replaced (0 = never)
+}
+
+// outboundPeers is the address book outbound connections are made from.
+type outboundPeers struct {
+	mu         sync.Mutex
+	cfg        OutboundConfig
+	candidates map[peer.ID]*candidate
+	sources    map[peer.ID]string // how connected outbound peers were found
+}
+
+type candidate struct {
+	info   peer.AddrInfo
+	source string
+	added  time.Time
+	failed time.Time // last failed dial
+}
+
+// outboundPeer is a connected outbound peer as peer selection sees it.
+type outboundPeer struct {
+	id        peer.ID
+	group     string
+	source    string
+	since     time.Time
+	protected bool // static or serving the synced chain; never rotated
+}
+
+// netGroup returns the network group of addr: the /16 of a public IPv4
+// address or the /32 of a public IPv6 one. Private and loopback addresses
+// and DNS names are not grouped ("").
+func netGroup(addr ma.Multiaddr) string {
+	first, _ := ma.SplitFirst(addr)
+	if first == nil {
+		return ""
+	}
+	var ip gonet.IP
+	switch first.Protocol().Code {
+	case ma.P_IP4, ma.P_IP6:
+		ip = gonet.IP(first.RawValue())
+	default:
+		return ""
+	}
+	if !manet.IsPublicAddr(addr) {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(gonet.CIDRMask(16, 32)).String() + "/16"
+	}
+	return ip.Mask(gonet.CIDRMask(32, 128)).String() + "/32"
+}
+
+// peerGroup returns the network group of the first grouped address.
+func peerGroup(addrs []ma.Multiaddr) string {
+	for _, a := range addrs {
+		if g := netGroup(a); g != "" {
+			return g
+		}
+	}
+	return ""
+}
+
+// peerNetGroup returns the network group p is connected from, "" if it is
+// not connected or not grouped.
+func (n *P2PNode) peerNetGroup(p peer.ID) string {
+	conns := n.Host.Network().ConnsToPeer(p)
+	if len(conns) == 0 {
+		return ""
+	}
+	return netGroup(conns[0].RemoteMultiaddr())
+}
+
+// addCandidate records pi as a peer to dial, found through source. A known
+// candidate keeps its first source. A full address book drops the oldest
+// candidate of the source holding the most, so one source cannot crowd out
+// the others.
+func (o *outboundPeers) addCandidate(pi peer.AddrInfo, source string, now time.Time) {
+	if len(pi.Addrs) == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.candidates == nil {
+		o.candidates = make(map[peer.ID]*candidate)
+	}
+	if c, ok := o.candidates[pi.ID]; ok {
+		c.info.Addrs = pi.Addrs
+		return
+	}
+	if len(o.candidates) >= maxCandidates {
+		held := make(map[string]int)
+		for _, c := range o.candidates {
+			held[c.source]++
+		}
+		var victim *candidate
+		for _, c := range o.candidates {
+			if victim == nil || held[c.source] > held[victim.source] ||
+				held[c.source] == held[victim.source] && c.added.Before(victim.added) {
+				victim = c
+			}
+		}
+		delete(o.candidates, victim.info.ID)
+	}
+	o.candidates[pi.ID] = &candidate{info: pi, source: source, added: now}
+}
+
+// noteSource records how the outbound peer p was found.
+func (o *outboundPeers) noteSource(p peer.ID, source string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.sources == nil {
+		o.sources = make(map[peer.ID]string)
+	}
+	o.sources[p] = source
+}
+
+func (o *outboundPeers) source(p peer.ID) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sources[p]
+}
+
+func (o *outboundPeers) disconnected(p peer.ID) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.sources, p)
+}
+
+// pickCandidate returns a candidate to dial next, or nil: one not
+// connected, not recently failed and outside the groups of peers, from the
+// source fewest of peers came from; among those, a random one, so that
+// which candidate is dialed cannot be predicted. The caller holds o.mu.
+func (o *outboundPeers) pickCandidate(peers []outboundPeer, connected func(peer.ID) bool, now time.Time) *candidate {
+	groups := make(map[string]bool)
+	fromSource := make(map[string]int)
+	for _, p := range peers {
+		groups[p.group] = true
+		fromSource[p.source]++
+	}
+	var best []*candidate
+	for _, c := range o.candidates {
+		if connected(c.info.ID) || now.Sub(c.failed) < candidateRetry {
+			continue
+		}
+		if g := peerGroup(c.info.Addrs); g != "" && groups[g] {
+			continue
+		}
+		switch {
+		case len(best) == 0 || fromSource[c.source] < fromSource[best[0].source]:
+			best = []*candidate{c}
+		case fromSource[c.source] == fromSource[best[0].source]:
+			best = append(best, c)
+		}
+	}
+	if len(best) == 0 {
+		return nil
+	}
+	return best[rand.N(len(best))]
+}
+
+// pickRotation returns the outbound peer to replace: an unprotected one
+// sharing its network group with another outbound peer if there is one,
+// else one from the source most outbound peers came from, the longest
+// connected first.
+func pickRotation(peers []outboundPeer) (outboundPeer, bool) {
+	inGroup := make(map[string]int)
+	fromSource := make(map[string]int)
+	for _, p := range peers {
+		if p.group != "" {
+			inGroup[p.group]++
+		}
+		fromSource[p.source]++
+	}
+	var victim outboundPeer
+	found := false
+	rank := func(p outboundPeer) (bool, int) { return inGroup[p.group] > 1, fromSource[p.source] }
+	for _, p := range peers {
+		if p.protected {
+			continue
+		}
+		if !found {
+			victim, found = p, true
+			continue
+		}
+		dup, n := rank(p)
+		vdup, vn := rank(victim)
+		if dup != vdup {
+			if dup {
+				victim = p
+			}
+			continue
+		}
+		if n > vn || n == vn && p.since.Before(victim.since) {
+			victim = p
+		}
+	}
+	return victim, found
+}
+
+// outboundList returns the connected outbound peers.
+func (n *P2PNode) outboundList() []outboundPeer {
+	cm := n.Host.ConnManager()
+	var peers []outboundPeer
+	for _, p := range n.Host.Network().Peers() {
+		conns := n.Host.Network().ConnsToPeer(p)
+		if len(conns) == 0 || conns[0].Stat().Direction != network.DirOutbound {
+			continue
+		}
+		peers = append(peers, outboundPeer{
+			id:        p,
+			group:     netGroup(conns[0].RemoteMultiaddr()),
+			source:    n.outbound.source(p),
+			since:     conns[0].Stat().Opened,
+			protected: cm.IsProtected(p, ""),
+		})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].id < peers[j].id })
+	return peers
+}
+
+// learnInboundPeers adds the listen addresses of peers that dialed us to
+// the candidates.
+func (n *P2PNode) learnInboundPeers(now time.Time) {
+	for _, p := range n.Host.Network().Peers() {
+		conns := n.Host.Network().ConnsToPeer(p)
+		if len(conns) == 0 || conns[0].Stat().Direction != network.DirInbound {
+			continue
+		}
+		n.outbound.addCandidate(peer.AddrInfo{ID: p, Addrs: n.Host.Peerstore().Addrs(p)}, sourceInbound, now)
+	}
+}
+
+// dialCandidate dials c, recording its source if it connects.
+func (n *P2PNode) dialCandidate(ctx context.Context, c *candidate) bool {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	err := n.Host.Connect(dialCtx, c.info)
+	cancel()
+	if err != nil {
+		n.outbound.mu.Lock()
+		c.failed = time.Now()
+		n.outbound.mu.Unlock()
+		return false
+	}
+	n.outbound.noteSource(c.info.ID, c.source)
+	return true
+}
+
+// nextCandidate picks a candidate under o.mu, skipping banned peers.
+func (n *P2PNode) nextCandidate(peers []outboundPeer) *candidate {
+	n.outbound.mu.Lock()
+	defer n.outbound.mu.Unlock()
+	connected := func(p peer.ID) bool {
+		return p == n.Host.ID() || n.scores.banned(p) || n.Host.Network().Connectedness(p) == network.Connected
+	}
+	return n.outbound.pickCandidate(peers, connected, time.Now())
+}
+
+// runOutbound keeps cfg.Target outbound connections and rotates one every
+// cfg.Rotation until ctx is done.
+func (n *P2PNode) runOutbound(ctx context.Context) {
+	cfg := n.outbound.cfg
+	ticker := time.NewTicker(outboundInterval)
+	defer ticker.Stop()
+	lastRotation := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n.learnInboundPeers(time.Now())
+
+		peers := n.outboundList()
+		for missing := cfg.Target - len(peers); missing > 0; missing-- {
+			c := n.nextCandidate(peers)
+			if c == nil {
+				break
+			}
+			if n.dialCandidate(ctx, c) {
+				log.Printf("[P2P] Outbound peer %s (%s, %s)", c.info.ID, c.source, groupName(peerGroup(c.info.Addrs)))
+				peers = n.outboundList()
+			}
+		}
+
+		if cfg.Rotation <= 0 || time.Since(lastRotation) < cfg.Rotation || len(peers) < cfg.Target {
+			continue
+		}
+		lastRotation = time.Now()
+		victim, ok := pickRotation(peers)
+		if !ok {
+			continue
+		}
+		rest := make([]outboundPeer, 0, len(peers)-1)
+		for _, p := range peers {
+			if p.id != victim.id {
+				rest = append(rest, p)
+			}
+		}
+		// Connect the replacement before dropping the peer it replaces
+		c := n.nextCandidate(rest)
+		if c == nil || !n.dialCandidate(ctx, c) {
+			continue
+		}
+		n.Host.Network().ClosePeer(victim.id)
+		log.Printf("[P2P] Rotated outbound peer %s (%s) to %s (%s)", victim.id, victim.source, c.info.ID, c.source)
+	}
+}
+
+func groupName(g string) string {
+	if g == "" {
+		return "ungrouped"
+	}
+	return g
+}
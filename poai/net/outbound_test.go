@@ -0,0 +1,100 @@
+package net
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func testCandidate(id, addr string) peer.AddrInfo {
+	return peer.AddrInfo{ID: peer.ID(id), Addrs: []ma.Multiaddr{ma.StringCast(addr)}}
+}
+
+func TestNetGroup(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/ip4/8.8.8.8/tcp/4001":           "8.8.0.0/16",
+		"/ip4/8.8.4.4/udp/4001/quic-v1":   "8.8.0.0/16",
+		"/ip4/1.2.3.4/tcp/4001":           "1.2.0.0/16",
+		"/ip6/2606:4700::1111/tcp/4001":   "2606:4700::/32",
+		"/ip4/127.0.0.1/tcp/4001":         "",
+		"/ip4/192.168.1.20/tcp/4001":      "",
+		"/dns4/seed.example.com/tcp/4001": "",
+	} {
+		if got := netGroup(ma.StringCast(addr)); got != want {
+			t.Errorf("%s: group %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestPickCandidateSpreadsGroupsAndSources(t *testing.T) {
+	now := time.Now()
+	var o outboundPeers
+	o.addCandidate(testCandidate("boot", "/ip4/1.1.1.1/tcp/4001"), sourceBootstrap, now)
+	o.addCandidate(testCandidate("sameGroup", "/ip4/8.8.4.4/tcp/4001"), sourceInbound, now)
+	o.addCandidate(testCandidate("inbound", "/ip4/9.9.9.9/tcp/4001"), sourceInbound, now)
+	o.addCandidate(testCandidate("lan", "/ip4/192.168.1.5/tcp/4001"), sourceMDNS, now)
+	o.candidates["lan"].failed = now.Add(-time.Minute)
+
+	// One bootstrap peer in 8.8.0.0/16 is connected already
+	peers := []outboundPeer{{id: "up", group: "8.8.0.0/16", source: sourceBootstrap}}
+	none := func(peer.ID) bool { return false }
+	for i := 0; i < 20; i++ {
+		if c := o.pickCandidate(peers, none, now); c == nil || c.info.ID != "inbound" {
+			t.Fatalf("picked %v, want the inbound candidate outside the connected group", c)
+		}
+	}
+	onlyInbound := func(p peer.ID) bool { return p == "inbound" }
+	if c := o.pickCandidate(peers, onlyInbound, now); c == nil || c.info.ID != "boot" {
+		t.Fatalf("picked %v, want the bootstrap candidate", c)
+	}
+	// A failed candidate is retried once candidateRetry has passed
+	all := func(p peer.ID) bool { return p != "lan" }
+	if c := o.pickCandidate(peers, all, now); c != nil {
+		t.Fatalf("picked %s, failed a minute ago", c.info.ID)
+	}
+	if c := o.pickCandidate(peers, all, now.Add(candidateRetry)); c == nil || c.info.ID != "lan" {
+		t.Fatalf("picked %v after the retry delay", c)
+	}
+}
+
+func TestPickRotation(t *testing.T) {
+	now := time.Now()
+	peers := []outboundPeer{
+		{id: "static", group: "1.1.0.0/16", source: sourceStatic, since: now.Add(-time.Hour), protected: true},
+		{id: "a", group: "8.8.0.0/16", source: sourceInbound, since: now.Add(-time.Minute)},
+		{id: "b", group: "8.8.0.0/16", source: sourceBootstrap, since: now.Add(-2 * time.Minute)},
+		{id: "c", group: "9.9.0.0/16", source: sourceInbound, since: now.Add(-30 * time.Minute)},
+	}
+	if v, ok := pickRotation(peers); !ok || v.id != "a" {
+		t.Fatalf("rotated %v, want the inbound peer sharing a group", v.id)
+	}
+	peers[1].group = "4.4.0.0/16"
+	if v, ok := pickRotation(peers); !ok || v.id != "c" {
+		t.Fatalf("rotated %v, want the oldest peer of the busiest source", v.id)
+	}
+	if _, ok := pickRotation(peers[:1]); ok {
+		t.Fatal("rotated a protected peer")
+	}
+}
+
+func TestFullAddressBookEvictsFromBusiestSource(t *testing.T) {
+	now := time.Now()
+	var o outboundPeers
+	o.addCandidate(testCandidate("boot", "/ip4/1.1.1.1/tcp/4001"), sourceBootstrap, now)
+	for i := 0; i < maxCandidates+10; i++ {
+		addr := fmt.Sprintf("/ip4/10.%d.%d.1/tcp/4001", i/256, i%256)
+		o.addCandidate(testCandidate(fmt.Sprint("in", i), addr), sourceInbound, now.Add(time.Duration(i)))
+	}
+	if len(o.candidates) != maxCandidates {
+		t.Fatalf("%d candidates, want %d", len(o.candidates), maxCandidates)
+	}
+	if _, ok := o.candidates["boot"]; !ok {
+		t.Fatal("flooded source evicted the bootstrap candidate")
+	}
+	if _, ok := o.candidates["in0"]; ok {
+		t.Fatal("oldest inbound candidate kept")
+	}
+}
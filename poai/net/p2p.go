@@ -3,25 +3,39 @@ package net
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"encoding/json"
 	"poai/core"
+	"poai/core/config"
+	"poai/dataset"
+	"strings"
 
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
 
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	mdns "github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
-const BlockTopic = "poai-blocks"
-const maxWireBlock = 256 * 1024 // 256 KB, adjust as needed
+// BlockTopic carries RLP-encoded blocks; the suffix is bumped with the
+// encoding so nodes on the old JSON topic do not see undecodable messages.
+const BlockTopic = "poai-blocks/2"
+const maxWireBlock = config.MaxBlockSize
+
+// agentPrefix prefixes the libp2p identify agent string; the node role follows it.
+const agentPrefix = "poai/"
 
 // Add Chain reference to P2PNode for sync
 // P2PNode represents a minimal libp2p node for block gossip and sync.
@@ -32,13 +46,101 @@ type P2PNode struct {
 	Chain    *core.Chain
 
 	bestKnownHeight uint64 // Track best known height from peers (atomic)
+
+	latency     *latencyTracker   // block propagation delays per peer
+	bandwidth   *bandwidthLimiter // upload/download rate limits
+	syncLimits  *syncLimiter      // per-peer sync request and response budgets
+	hashFetches sync.Map          // block hashes being fetched by RequestBlockByHash
+	static      staticPeers       // peers redialed whenever they drop
+	statuses    sync.Map          // peer.ID -> *Status from the handshake
+	seen        *seenBlocks       // recently handled block hashes
+
+	checkpoints checkpointState
+	hsync       headerSync
+	fastSync    bool
+	scores      *peerScorer                    // misbehaviour scores and bans
+	outbound    outboundPeers                  // address book outbound peers are picked from
+	corpus      atomic.Pointer[dataset.Corpus] // served over CorpusProtocol once set
+
+	ctx context.Context // node lifetime, bounds sync streams
+}
+
+// P2PConfig holds the listen and transport settings for NewP2PNode.
+type P2PConfig struct {
+	ListenAddrs      []string // explicit listen multiaddrs; overrides ListenPort when set
+	AnnounceAddrs    []string // addresses advertised to peers instead of the detected ones (static NAT)
+	ListenPort       int      // TCP port for node-to-node traffic
+	QUIC             bool     // also listen for QUIC on UDP ListenPort (ignored with ListenAddrs)
+	WSPort           int      // WebSocket port for browser clients (0 = disabled)
+	WebTransportPort int      // UDP port for WebTransport browser clients (0 = disabled)
+	Bandwidth        BandwidthLimits
+	FastBootstrap    bool // bootstrap from a trusted checkpoint instead of syncing from genesis
+	FastSync         bool // restore a peer snapshot matching the header chain's StateRoot instead of replaying history
+	NAT              NATConfig
+	Peers            PeerLimits     // connected peer watermarks (zero = defaults)
+	Outbound         OutboundConfig // outbound peer selection and rotation
+	Identity         crypto.PrivKey // persistent host key; a random one is used if nil
+}
+
+// listenAddrs returns the multiaddrs the host should listen on.
+func (c P2PConfig) listenAddrs() []string {
+	addrs := c.ListenAddrs
+	if len(addrs) == 0 {
+		addrs = []string{
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", c.ListenPort),
+			fmt.Sprintf("/ip6/::/tcp/%d", c.ListenPort),
+		}
+		if c.QUIC {
+			addrs = append(addrs,
+				fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1", c.ListenPort),
+				fmt.Sprintf("/ip6/::/udp/%d/quic-v1", c.ListenPort),
+			)
+		}
+	}
+	if c.WSPort > 0 {
+		addrs = append(addrs, fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", c.WSPort))
+	}
+	if c.WebTransportPort > 0 {
+		addrs = append(addrs, fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic-v1/webtransport", c.WebTransportPort))
+	}
+	return addrs
 }
 
 // NewP2PNode creates a new libp2p node, joins the block gossip topic, and enables mDNS discovery.
-func NewP2PNode(ctx context.Context, listenPort int, chain *core.Chain) (*P2PNode, error) {
-	h, err := libp2p.New(libp2p.ListenAddrStrings(
-		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort),
-	))
+func NewP2PNode(ctx context.Context, cfg P2PConfig, chain *core.Chain) (*P2PNode, error) {
+	scores := newPeerScorer()
+	opts := []libp2p.Option{
+		libp2p.ListenAddrStrings(cfg.listenAddrs()...),
+		// Advertise our role in the identify handshake
+		libp2p.UserAgent(agentPrefix + string(config.Role)),
+		// Refuse connections from banned peers
+		libp2p.ConnectionGater(scores),
+	}
+	if len(cfg.AnnounceAddrs) > 0 {
+		announce := make([]ma.Multiaddr, 0, len(cfg.AnnounceAddrs))
+		for _, a := range cfg.AnnounceAddrs {
+			addr, err := ma.NewMultiaddr(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid announce address %q: %w", a, err)
+			}
+			announce = append(announce, addr)
+		}
+		opts = append(opts, libp2p.AddrsFactory(func([]ma.Multiaddr) []ma.Multiaddr { return announce }))
+	}
+	if cfg.Identity != nil {
+		opts = append(opts, libp2p.Identity(cfg.Identity))
+	}
+	natOpts, err := cfg.NAT.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, natOpts...)
+	peerOpts, err := cfg.Peers.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, peerOpts...)
+	h, err := libp2p.New(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,28 +156,61 @@ func NewP2PNode(ctx context.Context, listenPort int, chain *core.Chain) (*P2PNod
 	}
 
 	n := &P2PNode{
-		Host:     h,
-		PubSub:   ps,
-		BlockSub: blockSub,
-		Chain:    chain,
+		Host:       h,
+		PubSub:     ps,
+		BlockSub:   blockSub,
+		Chain:      chain,
+		latency:    newLatencyTracker(),
+		bandwidth:  newBandwidthLimiter(cfg.Bandwidth),
+		syncLimits: newSyncLimiter(),
+		seen:       newSeenBlocks(),
+		fastSync:   cfg.FastSync,
+		scores:     scores,
+		ctx:        ctx,
 	}
+	n.outbound.cfg = cfg.Outbound
+	scores.disconnect = func(p peer.ID) { h.Network().ClosePeer(p) }
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(nw network.Network, c network.Conn) {
+			// The dialer starts the handshake, once per peer
+			if c.Stat().Direction == network.DirOutbound && len(nw.ConnsToPeer(c.RemotePeer())) == 1 {
+				go n.handshake(c.RemotePeer())
+			}
+		},
+		DisconnectedF: func(nw network.Network, c network.Conn) {
+			if len(nw.ConnsToPeer(c.RemotePeer())) == 0 {
+				n.statuses.Delete(c.RemotePeer())
+				n.bandwidth.forget(c.RemotePeer())
+				n.syncLimits.forget(c.RemotePeer())
+				n.static.disconnected(c.RemotePeer())
+				n.outbound.disconnected(c.RemotePeer())
+			}
+		},
+	})
 
 	// mDNS for local peer discovery
-	notifee := &mdnsNotifee{}
+	notifee := &mdnsNotifee{node: n}
 	mdns.NewMdnsService(h, "poai-mdns", notifee)
 	log.Printf("[P2P] mDNS peer discovery enabled")
+	if cfg.Outbound.Target > 0 {
+		go n.runOutbound(ctx)
+	}
 
-	// Periodically log connected peers
+	// Log the peer count when it changes; admin_peers lists the peers
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
 		defer ticker.Stop()
-		for range ticker.C {
-			peers := h.Network().Peers()
-			ids := make([]string, 0, len(peers))
-			for _, p := range peers {
-				ids = append(ids, p.String())
+		last := -1
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if count := len(h.Network().Peers()); count != last {
+				log.Printf("[P2P] %d peers connected", count)
+				last = count
 			}
-			log.Printf("[P2P] Connected peers: %v", ids)
 		}
 	}()
 
@@ -84,7 +219,12 @@ func NewP2PNode(ctx context.Context, listenPort int, chain *core.Chain) (*P2PNod
 		ticker := time.NewTicker(2 * time.Second)
 		defer ticker.Stop()
 		var lastHeight uint64 = 0
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 			h := chain.CurrentHeight()
 			if h == lastHeight {
 				continue
@@ -104,12 +244,21 @@ func NewP2PNode(ctx context.Context, listenPort int, chain *core.Chain) (*P2PNod
 		log.Fatal(err)
 	}
 	go n.handleNewHead(ctx, newHeadSub)
+	if err := n.startTxGossip(ctx, ps); err != nil {
+		return nil, err
+	}
 
-	subReq, _ := ps.Subscribe(TopicBlockReq)
-	go n.handleBlockReq(ctx, subReq)
+	h.SetStreamHandler(HandshakeProtocol, n.handleHandshakeStream)
+	// Blocks, headers and snapshots are fetched over direct streams
+	h.SetStreamHandler(SyncProtocol, n.handleSyncStream)
+	h.SetStreamHandler(BlockByHashProtocol, n.handleBlockByHashStream)
 
-	subResp, _ := ps.Subscribe(TopicBlockResp)
-	go n.handleBlockResp(ctx, subResp)
+	n.checkpoints.bootstrap = cfg.FastBootstrap && chain.CurrentHeight() == 0
+	n.checkpoints.deadline = time.Now().Add(bootstrapTimeout)
+	if err := n.startCheckpointHandlers(ctx, ps); err != nil {
+		return nil, err
+	}
+	n.startHeaderSync(ctx)
 
 	n.HandleBlockMessages(ctx)
 
@@ -132,28 +281,50 @@ func (n *P2PNode) HandleBlockMessages(ctx context.Context) {
 			}
 			log.Printf("[P2P] BlockSub message from %s (self: %v)", msg.ReceivedFrom, msg.ReceivedFrom == n.Host.ID())
 			// Ignore messages from self
-			if msg.ReceivedFrom == n.Host.ID() {
+			if msg.ReceivedFrom == n.Host.ID() || n.scores.banned(msg.GetFrom()) {
 				continue
 			}
 			if len(msg.Data) > maxWireBlock {
 				log.Printf("[P2P] oversized block msg (%d bytes) from %s", len(msg.Data), msg.ReceivedFrom)
+				n.scores.penalize(msg.GetFrom(), MisbehaviourOversized)
+				continue
+			}
+			if !n.bandwidth.allowDownload(msg.ReceivedFrom, len(msg.Data)) {
+				log.Printf("[P2P] download budget exceeded, dropping block msg from %s", msg.ReceivedFrom)
 				continue
 			}
 			var blk core.Block
-			if err := json.Unmarshal(msg.Data, &blk); err != nil {
+			if err := rlp.DecodeBytes(msg.Data, &blk); err != nil {
 				log.Printf("[P2P] Failed to decode block: %v", err)
+				n.scores.penalize(msg.GetFrom(), MisbehaviourMalformed)
 				continue
 			}
 			log.Printf("[P2P] Received block #%d from peer", blk.Header.Height)
-			if err := n.Chain.ImportBlock(&blk); err != nil {
+			n.latency.observeReceipt(msg.ReceivedFrom, blk.Header.Height, blk.Header.Timestamp)
+			if !n.seen.firstSeen(blk.Hash()) {
+				continue // already imported or being imported
+			}
+			if err := n.Chain.ImportBlockContext(core.WithPeer(ctx, msg.ReceivedFrom.String()), &blk); err != nil {
 				log.Printf("[P2P] Failed to import block #%d: %v", blk.Header.Height, err)
+				if errors.Is(err, core.ErrInvalidBlock) {
+					n.scores.penalize(msg.GetFrom(), MisbehaviourInvalidBlock)
+				}
 			} else {
+				n.latency.observeImport(blk.Header.Timestamp)
 				log.Printf("[P2P] Imported block #%d from peer", blk.Header.Height)
 			}
 		}
 	}()
 }
 
+// Close shuts down the libp2p host, dropping all peer connections. The
+// context passed to NewP2PNode should be cancelled first to stop the
+// background loops.
+func (n *P2PNode) Close() error {
+	log.Printf("[P2P] Closing host %s", n.Host.ID())
+	return n.Host.Close()
+}
+
 // AnnounceHead publishes a NewHeadMsg for a freshly-minted block header.
 func (n *P2PNode) AnnounceHead(b *core.Block) {
 	msg := NewHeadMsg{
@@ -180,65 +351,81 @@ func (n *P2PNode) handleNewHead(ctx context.Context, sub *pubsub.Subscription) {
 		if msg.Height == 0 {
 			continue
 		}
-		best := n.Chain.CurrentHeight()
-		if msg.Height > atomic.LoadUint64(&n.bestKnownHeight) {
-			atomic.StoreUint64(&n.bestKnownHeight, msg.Height)
-		}
-		if msg.Height <= best {
-			continue
+		n.onPeerHead(raw.GetFrom(), msg.Height)
+	}
+}
+
+// onPeerHead catches up with a head at height announced by from.
+func (n *P2PNode) onPeerHead(from peer.ID, height uint64) {
+	best := n.Chain.CurrentHeight()
+	if height > atomic.LoadUint64(&n.bestKnownHeight) {
+		atomic.StoreUint64(&n.bestKnownHeight, height)
+	}
+	if height <= best || n.bootstrapPending() {
+		return
+	}
+	if n.syncing() || height-best > headersFirstGap {
+		// Far behind: fetch and validate headers before any bodies
+		n.beginHeaderSync(height)
+		return
+	}
+	log.Printf("[SYNC] NewHead %d > local %d, requesting blocks %d-%d", height, best, best+1, height)
+	server := from
+	if height-best > config.DefaultPrunedDepth {
+		// Deep history: pruned peers won't have it, ask an archive/full node
+		if p := n.historyPeer(); p != "" {
+			server = p
 		}
-		log.Printf("[SYNC] NewHead %d > local %d, requesting blocks %d-%d", msg.Height, best, best+1, msg.Height)
-		req := BlockRequest{From: best + 1, To: msg.Height}
-		payload, _ := json.Marshal(req)
-		n.PubSub.Publish(TopicBlockReq, payload)
 	}
+	go n.fetchBlocks(server, best+1, height)
 }
 
-// BestKnownHeight returns the highest height seen from peers (atomic).
-func (n *P2PNode) BestKnownHeight() uint64 {
-	return atomic.LoadUint64(&n.bestKnownHeight)
+// PeerRole returns the role a peer advertised during identify, or "" if unknown.
+func (n *P2PNode) PeerRole(p peer.ID) config.NodeRole {
+	v, err := n.Host.Peerstore().Get(p, "AgentVersion")
+	if err != nil {
+		return ""
+	}
+	agent, _ := v.(string)
+	if !strings.HasPrefix(agent, agentPrefix) {
+		return ""
+	}
+	role, err := config.ParseNodeRole(strings.TrimPrefix(agent, agentPrefix))
+	if err != nil {
+		return ""
+	}
+	return role
 }
 
-// handleBlockReq serves block requests from peers.
-func (n *P2PNode) handleBlockReq(ctx context.Context, sub *pubsub.Subscription) {
-	for {
-		raw, _ := sub.Next(ctx)
-		var req BlockRequest
-		_ = json.Unmarshal(raw.Data, &req)
-		if req.To-req.From > 512 {
-			req.To = req.From + 512
+// historyPeer picks a connected peer to serve deep history, preferring
+// archive nodes over full nodes. It returns "" if none is known.
+func (n *P2PNode) historyPeer() peer.ID {
+	var full peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if n.scores.banned(p) {
+			continue
 		}
-		log.Printf("[SYNC] Serving block request for %d-%d", req.From, req.To)
-		blocks := make([]*core.Block, 0, req.To-req.From+1)
-		for h := req.From; h <= req.To; h++ {
-			if blk := n.Chain.BlockByHeight(h); blk != nil {
-				blocks = append(blocks, blk)
-				log.Printf("[SYNC] Sending block #%d in response to request", h)
-			} else {
-				log.Printf("[SYNC] Block #%d not found for request", h)
+		switch n.PeerRole(p) {
+		case config.RoleArchive:
+			return p
+		case config.RoleFull:
+			if full == "" {
+				full = p
 			}
 		}
-		resp := BlockResponse{Blocks: blocks}
-		data, _ := json.Marshal(resp)
-		n.PubSub.Publish(TopicBlockResp, data)
 	}
+	return full
 }
 
-// handleBlockResp consumes block responses and applies them to the chain.
-func (n *P2PNode) handleBlockResp(ctx context.Context, sub *pubsub.Subscription) {
-	for {
-		raw, _ := sub.Next(ctx)
-		var resp BlockResponse
-		_ = json.Unmarshal(raw.Data, &resp)
-		for _, blk := range resp.Blocks {
-			log.Printf("[SYNC] Received block #%d in response", blk.Header.Height)
-			log.Printf("[SYNC] Importing block #%d from peer", blk.Header.Height)
-			if err := n.Chain.ImportBlock(blk); err != nil {
-				log.Printf("[SYNC] Failed to import block #%d: %v", blk.Header.Height, err)
-				continue
-			}
-			// Optionally: state transition, fork-choice, etc.
-		}
+// BestKnownHeight returns the highest height seen from peers (atomic).
+func (n *P2PNode) BestKnownHeight() uint64 {
+	return atomic.LoadUint64(&n.bestKnownHeight)
+}
+
+// penalizeInvalid penalises p if err reports an invalid block.
+func (n *P2PNode) penalizeInvalid(p peer.ID, err error) {
+	if errors.Is(err, core.ErrInvalidBlock) {
+		n.scores.penalize(p, MisbehaviourInvalidBlock)
 	}
 }
 
@@ -249,34 +436,42 @@ func (n *P2PNode) PublishBlockFromStruct(b *core.Block) error {
 		log.Printf("[P2P] No peers connected, skipping block publication.")
 		return nil
 	}
-	data, err := json.Marshal(b)
+	data, err := b.Encode()
 	if err != nil {
 		return err
 	}
+	if err := n.bandwidth.waitUpload(context.Background(), "", len(data)); err != nil {
+		return err
+	}
 	log.Printf("[P2P] Publishing block #%d to network", b.Header.Height)
 	return n.PublishBlock(context.Background(), data)
 }
 
-// RequestBlockByHash requests a block with the given parent hash from peers.
+// RequestBlockByHash fetches the missing parent of an orphan by its hash,
+// from peers serving BlockByHashProtocol, or else guesses a height range
+// that contains it.
 func (n *P2PNode) RequestBlockByHash(parentHash [32]byte) {
-	log.Printf("[DEBUG] RequestBlockByHash: ENTER parentHash=%x", parentHash[:8])
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("[ERROR] RequestBlockByHash: PANIC: %v", r)
 			log.Printf("[ERROR] RequestBlockByHash: stack trace:\n%s", debugStack())
 		}
-		log.Printf("[DEBUG] RequestBlockByHash: EXIT parentHash=%x", parentHash[:8])
 	}()
+	if n.Chain.BlockByHash(parentHash) != nil {
+		return // already have it
+	}
+	// One fetch per hash at a time; orphans often share a parent
+	if _, busy := n.hashFetches.LoadOrStore(parentHash, struct{}{}); busy {
+		return
+	}
+	defer n.hashFetches.Delete(parentHash)
+	if n.fetchBlockByHash(parentHash) {
+		return
+	}
+
 	// Try to find the height of the missing parent
 	var orphanHeight uint64 = 0
 	found := false
-	for h := uint64(0); h <= n.Chain.CurrentHeight(); h++ {
-		blk := n.Chain.BlockByHeight(h)
-		if blk != nil && blk.Hash() == parentHash {
-			// Already have it
-			return
-		}
-	}
 	// Try to find the orphan that references this parent
 	n.Chain.OrphanMu.RLock()
 	for _, orphans := range n.Chain.OrphanPool {
@@ -295,9 +490,7 @@ func (n *P2PNode) RequestBlockByHash(parentHash [32]byte) {
 	if found && orphanHeight > 1 {
 		from := uint64(1)
 		to := orphanHeight
-		req := BlockRequest{From: from, To: to}
-		payload, _ := json.Marshal(req)
-		n.PubSub.Publish(TopicBlockReq, payload)
+		n.fetchBlocksAny(from, to)
 		log.Printf("[SYNC] Requested parent block %x (range %d-%d)", parentHash[:8], from, to)
 		return
 	}
@@ -308,17 +501,19 @@ func (n *P2PNode) RequestBlockByHash(parentHash [32]byte) {
 		from = best - 100
 	}
 	to := best
-	req := BlockRequest{From: from, To: to}
-	payload, _ := json.Marshal(req)
-	n.PubSub.Publish(TopicBlockReq, payload)
+	n.fetchBlocksAny(from, to)
 	log.Printf("[SYNC] Requested parent block %x (range %d-%d)", parentHash[:8], from, to)
 }
 
-// mDNS Notifee for peer discovery
-type mdnsNotifee struct{}
+// mDNS Notifee for peer discovery; found peers become outbound candidates
+type mdnsNotifee struct{ node *P2PNode }
 
 func (n *mdnsNotifee) HandlePeerFound(info peer.AddrInfo) {
+	if info.ID == n.node.Host.ID() {
+		return
+	}
 	log.Printf("[P2P] mDNS discovered peer: %s", info.ID.String())
+	n.node.outbound.addCandidate(info, sourceMDNS, time.Now())
 }
 
 // debugStack returns the current stack trace as a string.
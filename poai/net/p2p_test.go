@@ -0,0 +1,32 @@
+package net
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+)
+
+func TestListenAddrs(t *testing.T) {
+	cfg := P2PConfig{ListenPort: 4001, QUIC: true}
+	addrs := cfg.listenAddrs()
+	for _, want := range []string{"/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001", "/ip4/0.0.0.0/udp/4001/quic-v1", "/ip6/::/udp/4001/quic-v1"} {
+		if !slices.Contains(addrs, want) {
+			t.Errorf("missing %s in %v", want, addrs)
+		}
+	}
+	cfg.ListenAddrs = []string{"/ip4/127.0.0.1/udp/0/quic-v1"}
+	if addrs := cfg.listenAddrs(); !slices.Equal(addrs, cfg.ListenAddrs) {
+		t.Errorf("explicit listen addresses replaced: %v", addrs)
+	}
+
+	// The default transports accept QUIC listen addresses
+	h, err := libp2p.New(libp2p.ListenAddrStrings(cfg.ListenAddrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if len(h.Addrs()) == 0 {
+		t.Fatal("not listening")
+	}
+}
@@ -0,0 +1,101 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+// Limits on what one peer may ask of the sync protocol. A request is a few
+// bytes and a block response up to maxSyncResponse, so without them any
+// peer could make the node upload far more than it sends.
+const (
+	// syncRequestRate and syncRequestBurst bound the requests a peer makes;
+	// requests beyond them are answered with errRateLimited.
+	syncRequestRate  = 20 // per second
+	syncRequestBurst = 40
+	// syncServeRate and syncServeBurst bound the response bytes a peer is
+	// served; the burst lets the largest message through. A response over
+	// budget waits for it, up to maxServeDelay, and is refused with
+	// errRateLimited beyond that.
+	syncServeRate  = 8 << 20 // bytes per second
+	syncServeBurst = maxSyncMessage
+	maxServeDelay  = 10 * time.Second
+	// maxSyncResponse caps the blocks in one response by encoded size; at
+	// least one block is always sent.
+	maxSyncResponse = 16 << 20
+)
+
+// errRateLimited is the response error for requests over a peer's limits.
+const errRateLimited = "rate limited"
+
+// syncLimiter keeps the request and response budgets of each peer.
+type syncLimiter struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*syncBudget
+}
+
+type syncBudget struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+}
+
+func newSyncLimiter() *syncLimiter {
+	return &syncLimiter{peers: make(map[peer.ID]*syncBudget)}
+}
+
+func (l *syncLimiter) peer(p peer.ID) *syncBudget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.peers[p]
+	if !ok {
+		b = &syncBudget{
+			requests: rate.NewLimiter(syncRequestRate, syncRequestBurst),
+			bytes:    rate.NewLimiter(syncServeRate, syncServeBurst),
+		}
+		l.peers[p] = b
+	}
+	return b
+}
+
+// allowRequest reports whether p may make another request now.
+func (l *syncLimiter) allowRequest(p peer.ID) bool {
+	return l.peer(p).requests.Allow()
+}
+
+// waitResponse waits until n response bytes fit p's budget and takes them
+// from it. It reports false, taking nothing, if that would take longer
+// than maxServeDelay.
+func (l *syncLimiter) waitResponse(ctx context.Context, p peer.ID, n int) bool {
+	res := l.peer(p).bytes.ReserveN(time.Now(), n)
+	if !res.OK() {
+		return false
+	}
+	delay := res.Delay()
+	if delay > maxServeDelay {
+		res.Cancel()
+		return false
+	}
+	if delay == 0 {
+		return true
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		res.Cancel()
+		return false
+	}
+}
+
+// forget drops per-peer state when a peer disconnects.
+func (l *syncLimiter) forget(p peer.ID) {
+	l.mu.Lock()
+	delete(l.peers, p)
+	l.mu.Unlock()
+}
@@ -0,0 +1,37 @@
+package net
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSyncLimiter(t *testing.T) {
+	l := newSyncLimiter()
+	p, q := peer.ID("flooder"), peer.ID("other")
+	for i := 0; i < syncRequestBurst; i++ {
+		if !l.allowRequest(p) {
+			t.Fatalf("request %d refused within the burst", i)
+		}
+	}
+	if l.allowRequest(p) {
+		t.Fatal("request past the burst allowed")
+	}
+	if !l.allowRequest(q) {
+		t.Fatal("another peer's request refused")
+	}
+
+	ctx := context.Background()
+	if !l.waitResponse(ctx, p, syncServeBurst) {
+		t.Fatal("response within the budget refused")
+	}
+	// Refilling this much takes longer than maxServeDelay
+	if l.waitResponse(ctx, p, syncServeRate*int(maxServeDelay.Seconds()+1)) {
+		t.Fatal("response past the budget allowed")
+	}
+	l.forget(p)
+	if !l.allowRequest(p) {
+		t.Fatal("limits kept after forget")
+	}
+}
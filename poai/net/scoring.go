@@ -0,0 +1,212 @@
+package net
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Misbehaviour is a penalised peer action.
+type Misbehaviour int
+
+const (
+	MisbehaviourMalformed     Misbehaviour = iota // undecodable message
+	MisbehaviourOversized                         // message over the size cap
+	MisbehaviourInvalidBlock                      // bad signatures or invalid AI work
+	MisbehaviourInvalidHeader                     // header chain that does not link or meet its target
+	MisbehaviourInvalidRecord                     // corpus record or index that fails its hash
+)
+
+// penalties per misbehaviour; a peer is banned when its score reaches banThreshold.
+var penalties = map[Misbehaviour]int{
+	MisbehaviourMalformed:     10,
+	MisbehaviourOversized:     20,
+	MisbehaviourInvalidBlock:  50,
+	MisbehaviourInvalidHeader: 50,
+	MisbehaviourInvalidRecord: 50,
+}
+
+func (m Misbehaviour) String() string {
+	switch m {
+	case MisbehaviourMalformed:
+		return "malformed message"
+	case MisbehaviourOversized:
+		return "oversized message"
+	case MisbehaviourInvalidBlock:
+		return "invalid block"
+	case MisbehaviourInvalidHeader:
+		return "invalid header"
+	case MisbehaviourInvalidRecord:
+		return "invalid corpus record"
+	}
+	return "unknown"
+}
+
+const (
+	banThreshold = 100
+	// DefaultBanDuration is how long a peer stays banned after crossing the threshold.
+	DefaultBanDuration = time.Hour
+	// scoreHalfLife halves accumulated penalties so occasional faults fade.
+	scoreHalfLife = 10 * time.Minute
+)
+
+// BanInfo describes one banned peer.
+type BanInfo struct {
+	Peer   string    `json:"peer"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+type peerScore struct {
+	score   float64
+	updated time.Time
+}
+
+// peerScorer tracks misbehaviour per peer and keeps the ban list. It also
+// acts as the host's connection gater so banned peers cannot reconnect.
+type peerScorer struct {
+	mu     sync.Mutex
+	scores map[peer.ID]*peerScore
+	bans   map[peer.ID]BanInfo
+
+	disconnect func(peer.ID) // closes all connections to a peer
+}
+
+func newPeerScorer() *peerScorer {
+	return &peerScorer{
+		scores: make(map[peer.ID]*peerScore),
+		bans:   make(map[peer.ID]BanInfo),
+	}
+}
+
+// penalize records misbehaviour by p and bans it once the score is too high.
+func (s *peerScorer) penalize(p peer.ID, m Misbehaviour) {
+	if p == "" {
+		return
+	}
+	s.mu.Lock()
+	ps, ok := s.scores[p]
+	now := time.Now()
+	if !ok {
+		ps = &peerScore{updated: now}
+		s.scores[p] = ps
+	}
+	// Exponential decay since the last update
+	halvings := now.Sub(ps.updated).Seconds() / scoreHalfLife.Seconds()
+	for ; halvings >= 1; halvings-- {
+		ps.score /= 2
+	}
+	ps.updated = now
+	ps.score += float64(penalties[m])
+	score := ps.score
+	s.mu.Unlock()
+
+	log.Printf("[P2P] Peer %s penalised for %s (score %.0f)", p, m, score)
+	if score >= banThreshold {
+		s.ban(p, DefaultBanDuration, m.String())
+	}
+}
+
+// ban bans p for d and drops its connections.
+func (s *peerScorer) ban(p peer.ID, d time.Duration, reason string) {
+	s.mu.Lock()
+	s.bans[p] = BanInfo{Peer: p.String(), Until: time.Now().Add(d), Reason: reason}
+	delete(s.scores, p)
+	disconnect := s.disconnect
+	s.mu.Unlock()
+
+	log.Printf("[P2P] Banned peer %s for %v: %s", p, d, reason)
+	if disconnect != nil {
+		disconnect(p)
+	}
+}
+
+// unban lifts a ban; it reports whether p was banned.
+func (s *peerScorer) unban(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.bans[p]
+	delete(s.bans, p)
+	return ok
+}
+
+// banned reports whether p is currently banned, expiring old bans.
+func (s *peerScorer) banned(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bans[p]
+	if ok && time.Now().After(b.Until) {
+		delete(s.bans, p)
+		return false
+	}
+	return ok
+}
+
+// list returns the active bans sorted by expiry.
+func (s *peerScorer) list() []BanInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	out := make([]BanInfo, 0, len(s.bans))
+	for p, b := range s.bans {
+		if now.After(b.Until) {
+			delete(s.bans, p)
+			continue
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Until.Before(out[j].Until) })
+	return out
+}
+
+// Connection gating: refuse banned peers at every stage.
+
+func (s *peerScorer) InterceptPeerDial(p peer.ID) bool { return !s.banned(p) }
+
+func (s *peerScorer) InterceptAddrDial(p peer.ID, _ ma.Multiaddr) bool { return !s.banned(p) }
+
+func (s *peerScorer) InterceptAccept(network.ConnMultiaddrs) bool { return true }
+
+func (s *peerScorer) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !s.banned(p)
+}
+
+func (s *peerScorer) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// BannedPeers returns the active ban list.
+func (n *P2PNode) BannedPeers() []BanInfo {
+	return n.scores.list()
+}
+
+// BanPeer bans a peer by ID for d (DefaultBanDuration if d <= 0).
+func (n *P2PNode) BanPeer(id string, d time.Duration, reason string) error {
+	p, err := peer.Decode(id)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		d = DefaultBanDuration
+	}
+	if reason == "" {
+		reason = "banned by operator"
+	}
+	n.scores.ban(p, d, reason)
+	return nil
+}
+
+// UnbanPeer lifts a ban; it reports whether the peer was banned.
+func (n *P2PNode) UnbanPeer(id string) (bool, error) {
+	p, err := peer.Decode(id)
+	if err != nil {
+		return false, err
+	}
+	return n.scores.unban(p), nil
+}
@@ -0,0 +1,32 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPeerScorerBansAtThreshold(t *testing.T) {
+	s := newPeerScorer()
+	var disconnected peer.ID
+	s.disconnect = func(p peer.ID) { disconnected = p }
+	p := peer.ID("offender")
+
+	s.penalize(p, MisbehaviourInvalidBlock)
+	if s.banned(p) {
+		t.Fatal("banned after a single invalid block")
+	}
+	s.penalize(p, MisbehaviourInvalidBlock)
+	if !s.banned(p) || disconnected != p {
+		t.Fatal("expected ban and disconnect at threshold")
+	}
+	if s.InterceptPeerDial(p) {
+		t.Fatal("gater allowed dialing a banned peer")
+	}
+	if len(s.list()) != 1 {
+		t.Fatalf("ban list = %v", s.list())
+	}
+	if !s.unban(p) || s.banned(p) {
+		t.Fatal("unban failed")
+	}
+}
@@ -0,0 +1,38 @@
+package net
+
+import (
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// seenBlockCacheSize is how many recent block hashes are remembered; a
+// block arrives several times at once (gossip from each mesh peer, sync
+// responses), not hours apart.
+const seenBlockCacheSize = 4096
+
+// seenBlocks remembers recently handled block hashes, so a block that
+// arrives again is dropped before it is verified and imported a second
+// time.
+type seenBlocks struct {
+	cache *lru.Cache[[32]byte, struct{}]
+}
+
+func newSeenBlocks() *seenBlocks {
+	cache, _ := lru.New[[32]byte, struct{}](seenBlockCacheSize) // size is positive
+	return &seenBlocks{cache: cache}
+}
+
+// firstSeen records h and reports whether it was not seen recently.
+func (s *seenBlocks) firstSeen(h [32]byte) bool {
+	seen, _ := s.cache.ContainsOrAdd(h, struct{}{})
+	return !seen
+}
+
+// add records h as seen.
+func (s *seenBlocks) add(h [32]byte) {
+	s.cache.Add(h, struct{}{})
+}
+
+// seen reports whether h was seen recently.
+func (s *seenBlocks) seen(h [32]byte) bool {
+	return s.cache.Contains(h)
+}
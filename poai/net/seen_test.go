@@ -0,0 +1,21 @@
+package net
+
+import "testing"
+
+func TestSeenBlocks(t *testing.T) {
+	s := newSeenBlocks()
+	if !s.firstSeen([32]byte{1}) || s.firstSeen([32]byte{1}) {
+		t.Fatal("duplicate not detected")
+	}
+	s.add([32]byte{2})
+	if !s.seen([32]byte{2}) || s.seen([32]byte{3}) {
+		t.Fatal("seen set wrong")
+	}
+	// The oldest hashes are evicted
+	for i := 0; i < seenBlockCacheSize; i++ {
+		s.add([32]byte{4, byte(i), byte(i >> 8)})
+	}
+	if s.seen([32]byte{1}) {
+		t.Fatal("old hash kept")
+	}
+}
@@ -0,0 +1,398 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/core/header"
+	"poai/tracing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SyncProtocol carries block, header and snapshot requests directly between
+// two peers. Pubsub is only used for announcements. Version 2 frames RLP
+// instead of JSON.
+const SyncProtocol = protocol.ID("/poai/sync/2.0.0")
+
+const (
+	// maxSyncRequest caps a framed request (and a handshake status); every
+	// request is a few small fields.
+	maxSyncRequest = 4 << 10
+	// maxSyncMessage caps a framed response.
+	maxSyncMessage = 128 << 20
+	// maxBlocksPerResp caps one block response.
+	maxBlocksPerResp = 512
+	// syncStreamTimeout bounds one request/response exchange.
+	syncStreamTimeout = 60 * time.Second
+	// headerFanout is how many peers are asked for headers in parallel.
+	headerFanout = 8
+	// snapshotFanout is how many peers are asked for a snapshot in parallel.
+	snapshotFanout = 3
+)
+
+// SyncRequest asks a peer for exactly one of blocks, headers or a snapshot.
+type SyncRequest struct {
+	Blocks   *BlockRequest    `rlp:"nil"`
+	Headers  *HeaderRequest   `rlp:"nil"`
+	Snapshot *SnapshotRequest `rlp:"nil"`
+}
+
+// SyncResponse answers a SyncRequest.
+type SyncResponse struct {
+	Blocks   []*core.Block
+	Headers  []*header.Header
+	Snapshot *SnapshotResponse `rlp:"nil"`
+	Error    string
+}
+
+// tracer records sync spans.
+var tracer = tracing.Tracer("poai/net")
+
+// errMalformedMsg marks a frame that arrived intact but does not decode.
+var errMalformedMsg = errors.New("malformed sync message")
+
+// writeMsg writes v as a uvarint length-prefixed RLP frame.
+func writeMsg(w io.Writer, v interface{}) (int, error) {
+	data, err := rlp.EncodeToBytes(v)
+	if err != nil {
+		return 0, err
+	}
+	return writeFrame(w, data)
+}
+
+// writeFrame writes data with its uvarint length prefix.
+func writeFrame(w io.Writer, data []byte) (int, error) {
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(data)))
+	if _, err := w.Write(prefix[:n]); err != nil {
+		return 0, err
+	}
+	_, err := w.Write(data)
+	return n + len(data), err
+}
+
+// readMsg reads one frame written by writeMsg into v and returns its size.
+// Frames over limit are refused. The body is buffered as it arrives, so a
+// peer announcing a large frame cannot make the node allocate it up front.
+func readMsg(r *bufio.Reader, v interface{}, limit uint64) (int, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if size > limit {
+		return 0, fmt.Errorf("sync message of %d bytes exceeds limit", size)
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+		return 0, err
+	}
+	if err := rlp.DecodeBytes(buf.Bytes(), v); err != nil {
+		return int(size), fmt.Errorf("%w: %v", errMalformedMsg, err)
+	}
+	return int(size), nil
+}
+
+// handleSyncStream serves one request on an inbound sync stream.
+func (n *P2PNode) handleSyncStream(s network.Stream) {
+	defer s.Close()
+	p := s.Conn().RemotePeer()
+	if n.scores.banned(p) || !config.Role.ServesBlocks() {
+		s.Reset()
+		return
+	}
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+
+	// Over its request budget the peer is answered without reading on
+	var resp SyncResponse
+	var req SyncRequest
+	if !n.syncLimits.allowRequest(p) {
+		resp.Error = errRateLimited
+	} else if _, err := readMsg(bufio.NewReader(s), &req, maxSyncRequest); err != nil {
+		n.scores.penalize(p, MisbehaviourMalformed)
+		s.Reset()
+		return
+	}
+	switch {
+	case resp.Error != "":
+	case req.Blocks != nil:
+		resp.Blocks = n.serveBlocks(*req.Blocks)
+	case req.Headers != nil:
+		resp.Headers = n.serveHeaders(*req.Headers)
+	case req.Snapshot != nil:
+		resp.Snapshot = n.serveSnapshot(*req.Snapshot)
+		if resp.Snapshot == nil {
+			resp.Error = "snapshot not available"
+		}
+	default:
+		resp.Error = "empty request"
+	}
+
+	data, err := rlp.EncodeToBytes(&resp)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if !n.syncLimits.waitResponse(n.ctx, p, len(data)) {
+		log.Printf("[SYNC] Refusing %d-byte response to %s: over its budget", len(data), p)
+		if data, err = rlp.EncodeToBytes(&SyncResponse{Error: errRateLimited}); err != nil {
+			s.Reset()
+			return
+		}
+	}
+	if err := n.bandwidth.waitUpload(n.ctx, p, len(data)); err != nil {
+		s.Reset()
+		return
+	}
+	if _, err := writeFrame(s, data); err != nil {
+		s.Reset()
+	}
+}
+
+// serveBlocks returns the canonical blocks in the requested range, as many
+// as fit maxSyncResponse.
+func (n *P2PNode) serveBlocks(req BlockRequest) []*core.Block {
+	if req.To < req.From {
+		return nil
+	}
+	if req.To-req.From >= maxBlocksPerResp {
+		req.To = req.From + maxBlocksPerResp - 1
+	}
+	log.Printf("[SYNC] Serving block request for %d-%d", req.From, req.To)
+	blocks := make([]*core.Block, 0, req.To-req.From+1)
+	size := 0
+	for h := req.From; h <= req.To; h++ {
+		blk := n.Chain.BlockByHeight(h)
+		if blk == nil {
+			log.Printf("[SYNC] Block #%d not found for request", h)
+			continue
+		}
+		if data, err := blk.Encode(); err == nil {
+			size += len(data)
+		}
+		if size > maxSyncResponse && len(blocks) > 0 {
+			break
+		}
+		blocks = append(blocks, blk)
+	}
+	return blocks
+}
+
+// serveHeaders returns the contiguous canonical headers in the range.
+func (n *P2PNode) serveHeaders(req HeaderRequest) []*header.Header {
+	if req.To < req.From {
+		return nil
+	}
+	if req.To-req.From >= maxHeadersPerResp {
+		req.To = req.From + maxHeadersPerResp - 1
+	}
+	headers := make([]*header.Header, 0, req.To-req.From+1)
+	for h := req.From; h <= req.To; h++ {
+		blk := n.Chain.BlockByHeight(h)
+		if blk == nil {
+			break // send the contiguous prefix we have
+		}
+		hdr := blk.Header
+		headers = append(headers, &hdr)
+	}
+	log.Printf("[SYNC] Serving %d headers from #%d", len(headers), req.From)
+	return headers
+}
+
+// serveSnapshot returns the stored snapshot at a checkpoint height, or nil.
+func (n *P2PNode) serveSnapshot(req SnapshotRequest) *SnapshotResponse {
+	snap, err := n.Chain.Snapshot(req.Height)
+	if err != nil {
+		return nil
+	}
+	blk := n.Chain.BlockByHeight(req.Height)
+	if blk == nil {
+		return nil
+	}
+	log.Printf("[SYNC] Serving snapshot #%d (%d accounts)", req.Height, len(snap.Accounts))
+	return &SnapshotResponse{Checkpoint: n.LatestCheckpoint(), Block: blk, Snapshot: snap}
+}
+
+// syncCall sends one request to p and waits for the response.
+func (n *P2PNode) syncCall(p peer.ID, req SyncRequest) (*SyncResponse, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, syncStreamTimeout)
+	defer cancel()
+	s, err := n.Host.NewStream(ctx, p, SyncProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(syncStreamTimeout))
+	if _, err := writeMsg(s, req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite()
+
+	var resp SyncResponse
+	size, err := readMsg(bufio.NewReader(s), &resp, maxSyncMessage)
+	if err != nil {
+		if errors.Is(err, errMalformedMsg) {
+			n.scores.penalize(p, MisbehaviourMalformed)
+		}
+		s.Reset()
+		return nil, err
+	}
+	if !n.bandwidth.allowDownload(p, size) {
+		return nil, fmt.Errorf("download budget exceeded")
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("peer error: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// syncPeers returns up to max connected, unbanned peers that speak the sync
+// protocol (or all connected peers if identify has not told us yet).
+func (n *P2PNode) syncPeers(max int) []peer.ID {
+	var speaking, others []peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if n.scores.banned(p) {
+			continue
+		}
+		if protos, err := n.Host.Peerstore().SupportsProtocols(p, SyncProtocol); err == nil && len(protos) > 0 {
+			speaking = append(speaking, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	if len(speaking) == 0 {
+		speaking = others
+	}
+	if len(speaking) > max {
+		speaking = speaking[:max]
+	}
+	return speaking
+}
+
+// fetchBlocks requests a block range from p and applies the response.
+func (n *P2PNode) fetchBlocks(p peer.ID, from, to uint64) {
+	ctx, span := tracer.Start(n.ctx, "sync.FetchBlocks", trace.WithAttributes(
+		attribute.String("peer", p.String()),
+		attribute.Int64("range.from", int64(from)),
+		attribute.Int64("range.to", int64(to)),
+	))
+	resp, err := n.syncCall(p, SyncRequest{Blocks: &BlockRequest{From: from, To: to}})
+	if err != nil {
+		log.Printf("[SYNC] Block request %d-%d to %s failed: %v", from, to, p, err)
+		tracing.End(span, err)
+		return
+	}
+	span.AddEvent("response", trace.WithAttributes(attribute.Int("blocks", len(resp.Blocks))))
+	n.deliverBlocks(ctx, p, resp.Blocks)
+	span.End()
+}
+
+// fetchBlocksAny requests a block range from a history-serving peer, or
+// any sync peer if none advertises a full/archive role.
+func (n *P2PNode) fetchBlocksAny(from, to uint64) {
+	p := n.historyPeer()
+	if p == "" {
+		peers := n.syncPeers(1)
+		if len(peers) == 0 {
+			return
+		}
+		p = peers[0]
+	}
+	go n.fetchBlocks(p, from, to)
+}
+
+// deliverBlocks applies blocks received from p, routing them through
+// headers-first sync when it is running.
+func (n *P2PNode) deliverBlocks(ctx context.Context, p peer.ID, blocks []*core.Block) {
+	if len(blocks) == 0 {
+		return
+	}
+	log.Printf("[SYNC] Received %d blocks from %s", len(blocks), p)
+	ctx = core.WithPeer(ctx, p.String())
+	// Skip blocks already handled, e.g. gossiped while the request was out
+	head := n.Chain.CurrentHeight()
+	for len(blocks) > 0 && blocks[0].Header.Height <= head && n.seen.seen(blocks[0].Hash()) {
+		blocks = blocks[1:]
+	}
+	if len(blocks) == 0 {
+		return
+	}
+	if n.syncing() {
+		// Only bodies matching the selected header chain, in order
+		ready := n.onBodies(blocks)
+		if len(ready) > 0 {
+			imported, err := n.Chain.ImportBlocksContext(ctx, ready)
+			if err != nil {
+				log.Printf("[SYNC] Batch import stopped: %v", err)
+				n.penalizeInvalid(p, err)
+			}
+			n.markSeen(imported)
+			log.Printf("[SYNC] Imported %d/%d synced blocks", len(imported), len(ready))
+		}
+		n.afterBodiesImported()
+		return
+	}
+	// Signatures/proofs are checked in parallel, then blocks apply in order
+	imported, err := n.Chain.ImportBlocksContext(ctx, blocks)
+	if err != nil {
+		log.Printf("[SYNC] Batch import stopped: %v", err)
+		n.penalizeInvalid(p, err)
+	}
+	n.markSeen(imported)
+	log.Printf("[SYNC] Imported %d/%d blocks from response", len(imported), len(blocks))
+}
+
+// markSeen records the imported blocks as seen, so gossip of the same
+// blocks is dropped. Blocks of a batch that failed to import are not, so a
+// good peer relaying them is still heard.
+func (n *P2PNode) markSeen(imported [][32]byte) {
+	for _, h := range imported {
+		n.seen.add(h)
+	}
+}
+
+// fetchHeaders requests a header range from p and feeds headers-first sync.
+func (n *P2PNode) fetchHeaders(p peer.ID, from, to uint64) {
+	if !n.syncing() {
+		return
+	}
+	resp, err := n.syncCall(p, SyncRequest{Headers: &HeaderRequest{From: from, To: to}})
+	if err != nil {
+		log.Printf("[SYNC] Header request %d-%d to %s failed: %v", from, to, p, err)
+		return
+	}
+	if len(resp.Headers) > 0 {
+		n.onHeaders(p, resp.Headers)
+	}
+}
+
+// requestSnapshot asks a few peers for the state snapshot at height.
+func (n *P2PNode) requestSnapshot(height uint64) {
+	for _, p := range n.syncPeers(snapshotFanout) {
+		go func(p peer.ID) {
+			resp, err := n.syncCall(p, SyncRequest{Snapshot: &SnapshotRequest{Height: height}})
+			if err != nil {
+				log.Printf("[SYNC] Snapshot request #%d to %s failed: %v", height, p, err)
+				return
+			}
+			if resp.Snapshot != nil {
+				n.onSnapshotResponse(p, resp.Snapshot)
+			}
+		}(p)
+	}
+}
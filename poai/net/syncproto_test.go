@@ -0,0 +1,71 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"poai/core"
+)
+
+func TestSyncFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	req := SyncRequest{Headers: &HeaderRequest{From: 5, To: 9}}
+	if _, err := writeMsg(&buf, req); err != nil {
+		t.Fatal(err)
+	}
+	var got SyncRequest
+	if _, err := readMsg(bufio.NewReader(&buf), &got, maxSyncRequest); err != nil {
+		t.Fatal(err)
+	}
+	if got.Headers == nil || got.Headers.From != 5 || got.Headers.To != 9 || got.Blocks != nil {
+		t.Fatalf("unexpected request %+v", got)
+	}
+}
+
+func TestSyncFrameRejectsOversized(t *testing.T) {
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], maxSyncMessage+1)
+	var got SyncResponse
+	if _, err := readMsg(bufio.NewReader(bytes.NewReader(prefix[:n])), &got, maxSyncMessage); err == nil {
+		t.Fatal("oversized frame accepted")
+	}
+
+	// Requests have a much smaller cap than responses
+	var buf bytes.Buffer
+	writeFrame(&buf, make([]byte, maxSyncRequest+1))
+	var req SyncRequest
+	if _, err := readMsg(bufio.NewReader(&buf), &req, maxSyncRequest); err == nil {
+		t.Fatal("oversized request accepted")
+	}
+}
+
+func TestSyncFrameTruncated(t *testing.T) {
+	// A frame announcing more than arrives fails without decoding
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], 64<<20)
+	r := io.MultiReader(bytes.NewReader(prefix[:n]), bytes.NewReader(make([]byte, 100)))
+	var got SyncResponse
+	if _, err := readMsg(bufio.NewReader(r), &got, maxSyncMessage); !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		t.Fatalf("truncated frame: %v", err)
+	}
+}
+
+func TestSyncResponseCarriesBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	blk := core.NewBlock(3, [32]byte{1}, 7, big.NewInt(100), nil, 9)
+	if _, err := writeMsg(&buf, &SyncResponse{Blocks: []*core.Block{blk}}); err != nil {
+		t.Fatal(err)
+	}
+	var got SyncResponse
+	if _, err := readMsg(bufio.NewReader(&buf), &got, maxSyncMessage); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Blocks) != 1 || got.Blocks[0].Hash() != blk.Hash() || got.Snapshot != nil {
+		t.Fatalf("unexpected response %+v", got)
+	}
+}
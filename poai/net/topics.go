@@ -1,24 +1,37 @@
 package net
 
-import "poai/core"
-
-const (
-	TopicNewHead   = "poai/newhead/1"
-	TopicBlockReq  = "poai/blockreq/1"
-	TopicBlockResp = "poai/blockresp/1"
+import (
+	"poai/core"
 )
 
+const TopicNewHead = "poai/newhead/1"
+
 type NewHeadMsg struct {
 	Height uint64
 	Hash   [32]byte
 	Parent [32]byte
 }
 
+// Requests below travel over SyncProtocol streams, not pubsub.
+
 type BlockRequest struct {
 	From uint64 // inclusive
-	To   uint64 // inclusive, max 512 for DOS safety
+	To   uint64 // inclusive, max maxBlocksPerResp for DOS safety
+}
+
+const TopicCheckpoint = "poai/checkpoint/1"
+
+type SnapshotRequest struct {
+	Height uint64
 }
 
-type BlockResponse struct {
-	Blocks []*core.Block // your canonical block type
+type SnapshotResponse struct {
+	Checkpoint *core.Checkpoint    `rlp:"nil"`
+	Block      *core.Block         `rlp:"nil"`
+	Snapshot   *core.StateSnapshot `rlp:"nil"`
+}
+
+type HeaderRequest struct {
+	From uint64 // inclusive
+	To   uint64 // inclusive, max maxHeadersPerResp
 }
@@ -0,0 +1,96 @@
+package net
+
+import (
+	"context"
+	"log"
+
+	"poai/core"
+	"poai/core/config"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// TxTopic carries RLP-encoded signed transactions between mempools.
+const TxTopic = "poai-txs/1"
+
+// maxWireTx caps a gossiped transaction; the mempool rejects anything that
+// could not fit in a block anyway.
+const maxWireTx = config.MaxBlockSize
+
+// txGossipBuffer is how many new mempool transactions may wait to be
+// published before further ones are skipped.
+const txGossipBuffer = 1024
+
+// startTxGossip relays transactions entering the local mempool to peers,
+// and adds valid transactions from peers to it. Transactions that arrived
+// over gossip are not published again; gossipsub already forwards them.
+func (n *P2PNode) startTxGossip(ctx context.Context, ps *pubsub.PubSub) error {
+	sub, err := ps.Subscribe(TxTopic)
+	if err != nil {
+		return err
+	}
+	seen := newSeenBlocks() // keyed by transaction hash
+	go n.handleTxMessages(ctx, sub, seen)
+
+	local := n.Chain.Mempool.SubscribeNewTransactions(txGossipBuffer)
+	go func() {
+		defer local.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case tx, ok := <-local.C:
+				if !ok {
+					return
+				}
+				if len(tx.Hash) != 32 || tx.IsCoinbase() || !seen.firstSeen([32]byte(tx.Hash)) {
+					continue
+				}
+				data, err := tx.Encode()
+				if err != nil {
+					continue
+				}
+				if err := n.bandwidth.waitUpload(ctx, "", len(data)); err != nil {
+					return
+				}
+				if err := ps.Publish(TxTopic, data); err != nil {
+					log.Printf("[P2P] Failed to publish transaction %x: %v", tx.Hash[:4], err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// handleTxMessages adds gossiped transactions to the mempool.
+func (n *P2PNode) handleTxMessages(ctx context.Context, sub *pubsub.Subscription, seen *seenBlocks) {
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		if msg.ReceivedFrom == n.Host.ID() || n.scores.banned(msg.GetFrom()) {
+			continue
+		}
+		if len(msg.Data) > maxWireTx {
+			n.scores.penalize(msg.GetFrom(), MisbehaviourOversized)
+			continue
+		}
+		if !n.bandwidth.allowDownload(msg.ReceivedFrom, len(msg.Data)) {
+			continue
+		}
+		tx, err := core.DecodeTransaction(msg.Data)
+		if err != nil || tx.IsCoinbase() {
+			n.scores.penalize(msg.GetFrom(), MisbehaviourMalformed)
+			continue
+		}
+		if !seen.firstSeen([32]byte(tx.Hash)) {
+			continue
+		}
+		// Rejections are expected (nonce races, full pool), so they cost
+		// the sender nothing
+		if err := n.Chain.Mempool.AddTransaction(tx); err != nil {
+			log.Printf("[P2P] Gossiped transaction %x rejected: %v", tx.Hash[:4], err)
+		}
+	}
+}
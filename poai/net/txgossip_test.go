@@ -0,0 +1,42 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"poai/core"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTxGossip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := core.DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	a, b := newTestNodeWithGenesis(t, ctx, g), newTestNodeWithGenesis(t, ctx, g)
+
+	if err := a.AddPeer(b.NodeInfo().Addrs[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "handshake", func() bool { return a.PeerStatus(b.Host.ID()) != nil })
+	waitFor(t, "topic peers", func() bool { return len(a.PubSub.ListPeers(TxTopic)) > 0 && len(b.PubSub.ListPeers(TxTopic)) > 0 })
+	// Messages published right after connecting can be lost before
+	// gossipsub's first heartbeats graft the mesh
+	time.Sleep(2 * time.Second)
+
+	tx := core.NewTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(5), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Chain.Mempool.AddTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "transaction at the peer", func() bool { return b.Chain.Mempool.GetTransaction(tx.Hash) != nil })
+}
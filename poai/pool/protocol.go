@@ -0,0 +1,74 @@
+// Package pool implements a Stratum-like protocol for pooled PoAI mining.
+//
+// Workers hold a TCP connection to the pool and exchange newline-delimited
+// JSON-RPC messages:
+//
+//	-> {"id":1,"method":"mining.subscribe","params":["rig-1"]}
+//	<- {"id":1,"result":{"worker":3}}
+//	<- {"id":null,"method":"mining.notify","params":[{job}]}
+//	-> {"id":2,"method":"mining.submit","params":["<job id>",12345]}
+//	<- {"id":2,"result":{"accepted":true,"block":false}}
+//
+// Each job gives the worker its own nonce range for the next block, so no
+// two workers repeat the same inference. Workers submit every nonce whose
+// loss meets the easier share target; the pool replays each share and seals
+// a block when one also meets the block target.
+package pool
+
+import (
+	"encoding/json"
+	"math/big"
+)
+
+// Protocol method names.
+const (
+	MethodSubscribe = "mining.subscribe"
+	MethodSubmit    = "mining.submit"
+	MethodNotify    = "mining.notify"
+)
+
+// maxLine caps one protocol message.
+const maxLine = 64 << 10
+
+// Message is one line on the wire: a request, a response or a notification
+// (a request without an ID).
+type Message struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method,omitempty"`
+	Params []json.RawMessage `json:"params,omitempty"`
+	Result interface{}       `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// Job is the work handed to one worker for the next block.
+type Job struct {
+	ID          string   `json:"id"`
+	Parent      string   `json:"parent"`  // hex hash of the block the job builds on; seeds the quiz
+	TxRoot      string   `json:"txRoot"`  // hex root of the block's transactions; seeds the quiz
+	Version     uint32   `json:"version"` // block version; seeds the quiz
+	Height      uint64   `json:"height"`
+	Target      *big.Int `json:"target"`            // block target
+	ShareTarget *big.Int `json:"shareTarget"`       // losses at or below this count as shares
+	NonceStart  uint64   `json:"nonceStart"`        // inclusive
+	NonceEnd    uint64   `json:"nonceEnd"`          // exclusive
+	Context     string   `json:"context,omitempty"` // corpus records preceding the quiz
+}
+
+// SubscribeResult answers mining.subscribe.
+type SubscribeResult struct {
+	Worker uint64 `json:"worker"`
+}
+
+// SubmitResult answers mining.submit.
+type SubmitResult struct {
+	Accepted bool `json:"accepted"`
+	Block    bool `json:"block"` // the share also solved the block
+}
+
+// shareTarget relaxes a block target by factor.
+func shareTarget(target *big.Int, factor int64) *big.Int {
+	if factor <= 1 || target.Sign() <= 0 {
+		return new(big.Int).Set(target)
+	}
+	return new(big.Int).Mul(target, big.NewInt(factor))
+}
@@ -0,0 +1,343 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"poai/core"
+	"poai/inference"
+	"poai/miner"
+)
+
+const (
+	// DefaultShareFactor makes shares this many times easier than blocks.
+	DefaultShareFactor = 16
+	// DefaultRangeSize is the number of nonces in one worker's job.
+	DefaultRangeSize = 1 << 32
+	// maxInvalidShares disconnects a worker whose submissions keep failing
+	// replay, since every check costs the pool an inference.
+	maxInvalidShares = 50
+)
+
+// Config configures a pool server.
+type Config struct {
+	Addr         string // TCP listen address, e.g. ":3333"
+	MinerAddress string // hex address paid by blocks the pool finds
+	Target       int64  // block target used when the parent carries none
+	ShareFactor  int64  // share target = block target * ShareFactor
+	RangeSize    uint64 // nonces handed to a worker per job
+}
+
+// WorkerStats reports one connected worker.
+type WorkerStats struct {
+	ID      uint64 `json:"id"`
+	Name    string `json:"name"`
+	Addr    string `json:"addr"`
+	Shares  uint64 `json:"shares"`
+	Blocks  uint64 `json:"blocks"`
+	Stale   uint64 `json:"stale"`
+	Invalid uint64 `json:"invalid"`
+}
+
+type workerConn struct {
+	conn net.Conn
+	wmu  sync.Mutex // serialises writes
+	enc  *json.Encoder
+
+	// guarded by Server.mu
+	stats WorkerStats
+	job   *Job
+}
+
+// send writes one message to the worker.
+func (w *workerConn) send(m *Message) error {
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	return w.enc.Encode(m)
+}
+
+// Server hands out jobs to pool workers and replays their shares.
+type Server struct {
+	cfg     Config
+	chain   *core.Chain
+	llm     inference.Engine
+	publish func(*core.Block)
+
+	mu        sync.Mutex
+	tmpl      *miner.Template
+	jobSeq    uint64
+	nextRange uint64              // next unassigned nonce range of tmpl
+	seen      map[uint64]struct{} // nonces already submitted for tmpl
+	workers   map[uint64]*workerConn
+	nextID    uint64
+}
+
+// NewServer creates a pool server. publish is called with every block the
+// pool solves.
+func NewServer(cfg Config, chain *core.Chain, llm inference.Engine, publish func(*core.Block)) *Server {
+	if cfg.ShareFactor <= 0 {
+		cfg.ShareFactor = DefaultShareFactor
+	}
+	if cfg.RangeSize == 0 {
+		cfg.RangeSize = DefaultRangeSize
+	}
+	return &Server{
+		cfg:     cfg,
+		chain:   chain,
+		llm:     llm,
+		publish: publish,
+		seen:    make(map[uint64]struct{}),
+		workers: make(map[uint64]*workerConn),
+	}
+}
+
+// ListenAndServe accepts workers until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("⛏️  Mining pool listening on %s (share factor %d)", ln.Addr(), s.cfg.ShareFactor)
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts workers on ln until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	headSub := s.chain.SubscribeToHeadChanges()
+	defer headSub.Unsubscribe()
+	s.refresh()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				ln.Close()
+				s.closeAll()
+				return
+			case <-headSub.C:
+				s.refresh()
+			}
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Workers returns the connected workers.
+func (s *Server) Workers() []WorkerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]WorkerStats, 0, len(s.workers))
+	for _, w := range s.workers {
+		out = append(out, w.stats)
+	}
+	return out
+}
+
+// refresh builds a template on the current head and, if it changed, sends
+// every worker a fresh job.
+func (s *Server) refresh() {
+	tmpl := miner.NewTemplate(s.chain, s.cfg.Target, s.cfg.MinerAddress)
+	if tmpl == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmpl != nil && s.tmpl.Parent.Hash() == tmpl.Parent.Hash() {
+		return
+	}
+	s.tmpl = tmpl
+	s.jobSeq++
+	s.nextRange = 0
+	s.seen = make(map[uint64]struct{})
+	log.Printf("[POOL] New job for height %d (target %d), %d workers", tmpl.Height, tmpl.Target, len(s.workers))
+	for _, w := range s.workers {
+		s.notifyLocked(w)
+	}
+}
+
+// notifyLocked assigns w the next nonce range of the current template and
+// sends it; s.mu must be held.
+func (s *Server) notifyLocked(w *workerConn) {
+	if s.tmpl == nil {
+		return
+	}
+	start := s.nextRange * s.cfg.RangeSize
+	parentHash := s.tmpl.Parent.Hash()
+	s.nextRange++
+	job := &Job{
+		ID:          fmt.Sprintf("%x-%x", s.jobSeq, w.stats.ID),
+		Parent:      hex.EncodeToString(parentHash[:]),
+		TxRoot:      hex.EncodeToString(s.tmpl.TxRoot[:]),
+		Version:     s.tmpl.Version,
+		Height:      s.tmpl.Height,
+		Target:      s.tmpl.Target,
+		ShareTarget: shareTarget(s.tmpl.Target, s.cfg.ShareFactor),
+		NonceStart:  start,
+		NonceEnd:    start + s.cfg.RangeSize,
+		Context:     s.tmpl.Context,
+	}
+	w.job = job
+	params, _ := json.Marshal(job)
+	go w.send(&Message{Method: MethodNotify, Params: []json.RawMessage{params}})
+}
+
+func (s *Server) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.workers {
+		w.conn.Close()
+	}
+}
+
+// handleConn serves one worker connection.
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	w := &workerConn{conn: conn, enc: json.NewEncoder(conn)}
+	w.stats.Addr = conn.RemoteAddr().String()
+	defer func() {
+		s.mu.Lock()
+		delete(s.workers, w.stats.ID)
+		s.mu.Unlock()
+		if w.stats.ID != 0 {
+			log.Printf("[POOL] Worker %d (%s) disconnected", w.stats.ID, w.stats.Name)
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	for scanner.Scan() {
+		var req Message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			log.Printf("[POOL] Malformed message from %s, disconnecting", w.stats.Addr)
+			return
+		}
+		resp := &Message{ID: req.ID}
+		result, err := s.dispatch(w, &req)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result = result
+		}
+		if err := w.send(resp); err != nil {
+			return
+		}
+		s.mu.Lock()
+		invalid := w.stats.Invalid
+		s.mu.Unlock()
+		if invalid >= maxInvalidShares {
+			log.Printf("[POOL] Worker %d (%s) sent %d invalid shares, disconnecting", w.stats.ID, w.stats.Name, invalid)
+			return
+		}
+	}
+}
+
+func (s *Server) dispatch(w *workerConn, req *Message) (interface{}, error) {
+	switch req.Method {
+	case MethodSubscribe:
+		var name string
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params[0], &name); err != nil {
+				return nil, fmt.Errorf("invalid worker name")
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.stats.ID != 0 {
+			return nil, fmt.Errorf("already subscribed")
+		}
+		s.nextID++
+		w.stats.ID = s.nextID
+		w.stats.Name = name
+		s.workers[w.stats.ID] = w
+		log.Printf("[POOL] Worker %d (%s) subscribed from %s", w.stats.ID, name, w.stats.Addr)
+		s.notifyLocked(w)
+		return SubscribeResult{Worker: w.stats.ID}, nil
+	case MethodSubmit:
+		var jobID string
+		var nonce uint64
+		if len(req.Params) != 2 || json.Unmarshal(req.Params[0], &jobID) != nil || json.Unmarshal(req.Params[1], &nonce) != nil {
+			return nil, fmt.Errorf("submit takes [job id, nonce]")
+		}
+		return s.submit(w, jobID, nonce)
+	}
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
+
+var (
+	errNotSubscribed = errors.New("not subscribed")
+	errStaleJob      = errors.New("stale job")
+	errOutOfRange    = errors.New("nonce outside the job's range")
+	errDuplicate     = errors.New("duplicate share")
+	errLowDifficulty = errors.New("share does not meet the share target")
+)
+
+// submit replays a worker's share and seals a block if it meets the block
+// target.
+func (s *Server) submit(w *workerConn, jobID string, nonce uint64) (*SubmitResult, error) {
+	s.mu.Lock()
+	if w.stats.ID == 0 {
+		s.mu.Unlock()
+		return nil, errNotSubscribed
+	}
+	job, tmpl := w.job, s.tmpl
+	if job == nil || job.ID != jobID {
+		w.stats.Stale++
+		s.mu.Unlock()
+		return &SubmitResult{}, errStaleJob
+	}
+	if nonce < job.NonceStart || nonce >= job.NonceEnd {
+		w.stats.Invalid++
+		s.mu.Unlock()
+		return &SubmitResult{}, errOutOfRange
+	}
+	if _, ok := s.seen[nonce]; ok {
+		w.stats.Invalid++
+		s.mu.Unlock()
+		return &SubmitResult{}, errDuplicate
+	}
+	s.seen[nonce] = struct{}{}
+	s.mu.Unlock()
+
+	// Replay the work outside the lock; inference is slow
+	loss, _, err := miner.Attempt(s.llm, tmpl.Work(), nonce)
+
+	s.mu.Lock()
+	if err != nil || !core.MeetsTarget(loss, job.ShareTarget) {
+		w.stats.Invalid++
+		s.mu.Unlock()
+		if err != nil {
+			return &SubmitResult{}, err
+		}
+		return &SubmitResult{}, errLowDifficulty
+	}
+	w.stats.Shares++
+	// A block only if the head has not moved while we replayed
+	solved := core.MeetsTarget(loss, job.Target) && s.tmpl == tmpl
+	if solved {
+		w.stats.Blocks++
+	}
+	s.mu.Unlock()
+	if !solved {
+		return &SubmitResult{Accepted: true}, nil
+	}
+
+	log.Printf("🎉 POOL BLOCK FOUND by worker %d (%s)! Loss: %d <= Target: %d at height %d", w.stats.ID, w.stats.Name, loss, job.Target, job.Height)
+	s.publish(tmpl.Seal(s.chain, loss, nonce))
+	return &SubmitResult{Accepted: true, Block: true}, nil
+}
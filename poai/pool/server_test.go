@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"poai/core"
+	"poai/inference"
+)
+
+func TestPoolWorkerSolvesBlock(t *testing.T) {
+	chain := core.NewChain(t.TempDir(), math.MaxInt64/2)
+	defer chain.Close()
+	llm, _ := inference.NewLLM("", 0)
+
+	blocks := make(chan *core.Block, 4)
+	srv := NewServer(Config{ShareFactor: 2, RangeSize: 1000}, chain, llm, func(b *core.Block) { blocks <- b })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, ln)
+
+	w := &Worker{Addr: ln.Addr().String(), Name: "test", Threads: 2, LLM: llm}
+	go w.Run(ctx)
+
+	select {
+	case b := <-blocks:
+		if b.Header.Height != 1 || b.Header.Nonce >= 1000 {
+			t.Fatalf("block at height %d with nonce %d outside the first job range", b.Header.Height, b.Header.Nonce)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no block from pool worker")
+	}
+	stats := srv.Workers()
+	if len(stats) != 1 || stats[0].Shares == 0 || stats[0].Blocks == 0 {
+		t.Fatalf("worker stats = %+v", stats)
+	}
+}
+
+func TestPoolRejectsBadShares(t *testing.T) {
+	chain := core.NewChain(t.TempDir(), 1000)
+	defer chain.Close()
+	llm, _ := inference.NewLLM("", 0)
+	srv := NewServer(Config{RangeSize: 10}, chain, llm, func(*core.Block) {})
+	srv.refresh()
+
+	w := &workerConn{}
+	if _, err := srv.submit(w, "x", 0); err != errNotSubscribed {
+		t.Fatalf("unsubscribed submit: %v", err)
+	}
+	srv.mu.Lock()
+	srv.nextID++
+	w.stats.ID = srv.nextID
+	w.job = &Job{ID: "j", Height: 1, Target: big.NewInt(1000), ShareTarget: big.NewInt(math.MaxInt64), NonceStart: 10, NonceEnd: 20}
+	srv.mu.Unlock()
+
+	if _, err := srv.submit(w, "old", 10); err != errStaleJob {
+		t.Errorf("stale job: %v", err)
+	}
+	if _, err := srv.submit(w, "j", 20); err != errOutOfRange {
+		t.Errorf("out of range: %v", err)
+	}
+	if res, err := srv.submit(w, "j", 10); err != nil || !res.Accepted {
+		t.Errorf("valid share: %v, %v", res, err)
+	}
+	if _, err := srv.submit(w, "j", 10); err != errDuplicate {
+		t.Errorf("duplicate: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"poai/core"
+	"poai/inference"
+	"poai/miner"
+)
+
+// Worker mines jobs from a pool server.
+type Worker struct {
+	Addr    string // pool address, host:port
+	Name    string // reported to the pool
+	Threads int    // parallel inferences
+	LLM     inference.Engine
+
+	conn   net.Conn
+	wmu    sync.Mutex
+	enc    *json.Encoder
+	nextID atomic.Uint64
+
+	current                    atomic.Pointer[Job] // threads stop once it changes
+	accepted, rejected, blocks atomic.Uint64
+}
+
+// call sends a request; the response is handled by the read loop.
+func (w *Worker) call(method string, params ...interface{}) error {
+	id := w.nextID.Add(1)
+	m := &Message{ID: &id, Method: method}
+	for _, p := range params {
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		m.Params = append(m.Params, raw)
+	}
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+	return w.enc.Encode(m)
+}
+
+// Run connects to the pool and mines until ctx is cancelled or the
+// connection drops.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.Threads < 1 {
+		w.Threads = 1
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", w.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	w.conn, w.enc = conn, json.NewEncoder(conn)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if err := w.call(MethodSubscribe, w.Name); err != nil {
+		return err
+	}
+	log.Printf("[POOL] Connected to pool %s as %q with %d threads", w.Addr, w.Name, w.Threads)
+
+	var jobs sync.WaitGroup
+	defer func() {
+		w.current.Store(nil)
+		jobs.Wait()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	for scanner.Scan() {
+		var m Message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return fmt.Errorf("malformed message from pool: %v", err)
+		}
+		switch {
+		case m.Method == MethodNotify && len(m.Params) == 1:
+			var job Job
+			if err := json.Unmarshal(m.Params[0], &job); err != nil {
+				return fmt.Errorf("malformed job: %v", err)
+			}
+			parent, err := hex.DecodeString(job.Parent)
+			if err != nil || len(parent) != 32 {
+				return fmt.Errorf("malformed job: bad parent hash %q", job.Parent)
+			}
+			txRoot, err := hex.DecodeString(job.TxRoot)
+			if err != nil || len(txRoot) != 32 {
+				return fmt.Errorf("malformed job: bad tx root %q", job.TxRoot)
+			}
+			// A new job always supersedes the old one
+			w.current.Store(&job)
+			jobs.Wait()
+			log.Printf("[POOL] Job %s: height %d, nonces %d-%d, share target %d", job.ID, job.Height, job.NonceStart, job.NonceEnd, job.ShareTarget)
+			for i := 0; i < w.Threads; i++ {
+				jobs.Add(1)
+				go func(i int) {
+					defer jobs.Done()
+					w.mine(ctx, &job, &miner.Work{Parent: [32]byte(parent), TxRoot: [32]byte(txRoot), Version: job.Version, Height: job.Height, Target: job.Target, Context: job.Context}, i)
+				}(i)
+			}
+		case m.ID != nil && m.Error != "":
+			w.rejected.Add(1)
+			log.Printf("[POOL] Pool rejected request %d: %s", *m.ID, m.Error)
+		case m.ID != nil:
+			w.logResult(m.Result)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("pool closed the connection")
+}
+
+// logResult records a submit acknowledgement.
+func (w *Worker) logResult(result interface{}) {
+	raw, _ := json.Marshal(result)
+	var res SubmitResult
+	if json.Unmarshal(raw, &res) != nil || !res.Accepted {
+		return // subscribe result or not a share
+	}
+	w.accepted.Add(1)
+	if res.Block {
+		w.blocks.Add(1)
+		log.Printf("🎉 Share solved a block! (%d accepted, %d rejected, %d blocks)", w.accepted.Load(), w.rejected.Load(), w.blocks.Load())
+	}
+}
+
+// mine searches thread i's slice of the job's range, submitting every
+// nonce that meets the share target.
+func (w *Worker) mine(ctx context.Context, job *Job, work *miner.Work, i int) {
+	span := (job.NonceEnd - job.NonceStart) / uint64(w.Threads)
+	start := job.NonceStart + uint64(i)*span
+	end := start + span
+	if i == w.Threads-1 {
+		end = job.NonceEnd
+	}
+	for nonce := start; nonce < end && ctx.Err() == nil && w.current.Load() == job; nonce++ {
+		loss, _, err := miner.Attempt(w.LLM, work, nonce)
+		if err != nil {
+			log.Printf("[POOL] Skipping nonce %d: %v", nonce, err)
+			continue
+		}
+		if !core.MeetsTarget(loss, job.ShareTarget) {
+			continue
+		}
+		log.Printf("[POOL] Share found: nonce=%d loss=%d (block target %d)", nonce, loss, job.Target)
+		if err := w.call(MethodSubmit, job.ID, nonce); err != nil {
+			log.Printf("[POOL] Submit failed: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"encoding/json"
+	"time"
+
+	"poai/logging"
+	"poai/net"
+)
+
+// PeerAdmin is the peer management surface of the P2P node.
+type PeerAdmin interface {
+	Peers() []net.PeerInfo
+	NodeInfo() net.NodeInfo
+	AddPeer(addr string) error
+	RemovePeer(id string) (bool, error)
+	BannedPeers() []net.BanInfo
+	BanPeer(id string, d time.Duration, reason string) error
+	UnbanPeer(id string) (bool, error)
+}
+
+// RegisterAdmin exposes peer management methods. Only call it on servers
+// bound to a trusted interface.
+func (s *Server) RegisterAdmin(p PeerAdmin) {
+	s.Register("admin_peers", func(params []json.RawMessage) (interface{}, error) {
+		return p.Peers(), nil
+	})
+	s.Register("admin_nodeInfo", func(params []json.RawMessage) (interface{}, error) {
+		return p.NodeInfo(), nil
+	})
+	s.Register("admin_addPeer", func(params []json.RawMessage) (interface{}, error) {
+		var addr string
+		if err := paramAt(params, 0, &addr); err != nil {
+			return nil, err
+		}
+		if err := p.AddPeer(addr); err != nil {
+			return nil, Errorf(ErrCodeInvalidParams, "%v", err)
+		}
+		return true, nil
+	})
+	s.Register("admin_removePeer", func(params []json.RawMessage) (interface{}, error) {
+		var id string
+		if err := paramAt(params, 0, &id); err != nil {
+			return nil, err
+		}
+		ok, err := p.RemovePeer(id)
+		if err != nil {
+			return nil, Errorf(ErrCodeInvalidParams, "invalid peer ID: %v", err)
+		}
+		return ok, nil
+	})
+	s.Register("admin_bannedPeers", func(params []json.RawMessage) (interface{}, error) {
+		return p.BannedPeers(), nil
+	})
+	s.Register("admin_banPeer", func(params []json.RawMessage) (interface{}, error) {
+		var id string
+		if err := paramAt(params, 0, &id); err != nil {
+			return nil, err
+		}
+		var seconds int64
+		if len(params) > 1 {
+			if err := paramAt(params, 1, &seconds); err != nil {
+				return nil, err
+			}
+		}
+		var reason string
+		if len(params) > 2 {
+			if err := paramAt(params, 2, &reason); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.BanPeer(id, time.Duration(seconds)*time.Second, reason); err != nil {
+			return nil, Errorf(ErrCodeInvalidParams, "invalid peer ID: %v", err)
+		}
+		return true, nil
+	})
+	s.Register("admin_unbanPeer", func(params []json.RawMessage) (interface{}, error) {
+		var id string
+		if err := paramAt(params, 0, &id); err != nil {
+			return nil, err
+		}
+		ok, err := p.UnbanPeer(id)
+		if err != nil {
+			return nil, Errorf(ErrCodeInvalidParams, "invalid peer ID: %v", err)
+		}
+		return ok, nil
+	})
+	s.Register("admin_logLevels", func(params []json.RawMessage) (interface{}, error) {
+		return logging.Levels(), nil
+	})
+	s.Register("admin_setLogLevel", func(params []json.RawMessage) (interface{}, error) {
+		var spec string
+		if err := paramAt(params, 0, &spec); err != nil {
+			return nil, err
+		}
+		if err := logging.SetLevels(spec); err != nil {
+			return nil, Errorf(ErrCodeInvalidParams, "%v", err)
+		}
+		return logging.Levels(), nil
+	})
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"poai/rpc/jwt"
+)
+
+// AuthConfig restricts who may call the API. With neither Token nor
+// JWTSecret set, every caller is trusted.
+type AuthConfig struct {
+	Token     string // static bearer token
+	JWTSecret []byte // HS256 key of short-lived bearer JWTs, see package jwt
+	// PublicReadOnly lets unauthenticated callers use the read-only
+	// methods; others must authenticate for every method.
+	PublicReadOnly bool
+}
+
+func (a *AuthConfig) enabled() bool {
+	return a != nil && (a.Token != "" || len(a.JWTSecret) > 0)
+}
+
+// authorized reports whether r carries valid credentials, or none are
+// required.
+func (a *AuthConfig) authorized(r *http.Request) bool {
+	if !a.enabled() {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	if a.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1 {
+		return true
+	}
+	return len(a.JWTSecret) > 0 && jwt.Verify(a.JWTSecret, token, time.Now()) == nil
+}
+
+// LoadToken reads a bearer token from path, ignoring surrounding
+// whitespace.
+func LoadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"poai/rpc/jwt"
+)
+
+func TestAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	s := &Server{methods: make(map[string]Handler)}
+	ok := func(params []json.RawMessage) (interface{}, error) { return true, nil }
+	s.RegisterReadOnly("test_read", ok)
+	s.Register("test_write", ok)
+
+	call := func(method, token string) (int, *Error) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8545/", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		var resp response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rec.Code, resp.Error
+	}
+
+	if code, rpcErr := call("test_write", ""); code != http.StatusOK || rpcErr != nil {
+		t.Fatalf("no auth configured: %d %+v", code, rpcErr)
+	}
+
+	s.SetAuth(AuthConfig{Token: "sesame", JWTSecret: secret})
+	if code, rpcErr := call("test_read", ""); code != http.StatusUnauthorized || rpcErr == nil || rpcErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("no credentials: %d %+v", code, rpcErr)
+	}
+	if code, _ := call("test_write", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: %d", code)
+	}
+	if _, rpcErr := call("test_write", "sesame"); rpcErr != nil {
+		t.Fatalf("token: %+v", rpcErr)
+	}
+	if _, rpcErr := call("test_write", jwt.New(secret)); rpcErr != nil {
+		t.Fatalf("JWT: %+v", rpcErr)
+	}
+
+	s.SetAuth(AuthConfig{Token: "sesame", PublicReadOnly: true})
+	if _, rpcErr := call("test_read", ""); rpcErr != nil {
+		t.Fatalf("public read: %+v", rpcErr)
+	}
+	if code, rpcErr := call("test_write", ""); code != http.StatusOK || rpcErr == nil || rpcErr.Code != ErrCodeUnauthorized {
+		t.Fatalf("public write: %d %+v", code, rpcErr)
+	}
+	if _, rpcErr := call("test_write", "sesame"); rpcErr != nil {
+		t.Fatalf("authenticated write: %+v", rpcErr)
+	}
+}
@@ -0,0 +1,287 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"poai/core"
+	"poai/core/config"
+)
+
+func (s *Server) registerChainAPI() {
+	s.RegisterReadOnly("poai_chainId", s.chainID)
+	s.RegisterReadOnly("poai_blockNumber", s.blockNumber)
+	s.RegisterReadOnly("poai_getBlockByNumber", s.getBlockByNumber)
+	s.RegisterReadOnly("poai_getBlockByHash", s.getBlockByHash)
+	s.RegisterReadOnly("poai_getHeaderByNumber", s.getHeaderByNumber)
+	s.RegisterReadOnly("poai_getBalance", s.getBalance)
+	s.RegisterReadOnly("poai_getNonce", s.getNonce)
+	s.Register("poai_sendTransaction", s.sendTransaction)
+	s.Register("poai_sendRawTransaction", s.sendRawTransaction)
+	s.RegisterReadOnly("poai_mempoolStats", s.mempoolStats)
+	s.RegisterReadOnly("poai_getDepositProof", s.getDepositProof)
+	s.RegisterReadOnly("poai_getTransactionReceipt", s.getTransactionReceipt)
+	s.RegisterReadOnly("poai_getTransactionByHash", s.getTransactionByHash)
+	s.RegisterReadOnly("poai_getAddressTransactions", s.getAddressTransactions)
+	s.RegisterReadOnly("poai_getSupply", s.getSupply)
+	s.RegisterReadOnly("poai_getEmission", s.getEmission)
+	s.RegisterReadOnly("poai_getRichList", s.getRichList)
+	s.RegisterReadOnly("poai_getDeployments", s.getDeployments)
+}
+
+// Page sizes of poai_getAddressTransactions and poai_getRichList.
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 1000
+
+	defaultRichListLimit = 100
+	maxRichListLimit     = 1000
+)
+
+func (s *Server) chainID(params []json.RawMessage) (interface{}, error) {
+	genesis := s.chain.BlockByHeight(0)
+	if genesis == nil {
+		return nil, Errorf(ErrCodeNotFound, "genesis block not found")
+	}
+	hash := genesis.Hash()
+	return map[string]interface{}{
+		"chainId":     config.ChainID,
+		"genesisHash": hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+func (s *Server) blockNumber(params []json.RawMessage) (interface{}, error) {
+	return s.chain.CurrentHeight(), nil
+}
+
+// blockResult is a block with its hash, which the block itself does not
+// carry.
+type blockResult struct {
+	Hash string `json:"hash"`
+	*core.Block
+}
+
+func newBlockResult(blk *core.Block) *blockResult {
+	hash := blk.Hash()
+	return &blockResult{Hash: hex.EncodeToString(hash[:]), Block: blk}
+}
+
+// txResult is a transaction with where it was included; the location
+// fields are null while it waits in the mempool.
+type txResult struct {
+	*core.Transaction
+	BlockHash   *string `json:"blockHash"`
+	BlockNumber *uint64 `json:"blockNumber"`
+	TxIndex     *uint32 `json:"transactionIndex"`
+}
+
+func newTxResult(tx *core.Transaction, loc *core.TxLocation) *txResult {
+	blockHash := hex.EncodeToString(loc.BlockHash[:])
+	return &txResult{Transaction: tx, BlockHash: &blockHash, BlockNumber: &loc.Height, TxIndex: &loc.Index}
+}
+
+func (s *Server) blockAt(params []json.RawMessage) (*core.Block, error) {
+	var height uint64
+	if err := paramAt(params, 0, &height); err != nil {
+		return nil, err
+	}
+	blk := s.chain.BlockByHeight(height)
+	if blk == nil {
+		return nil, Errorf(ErrCodeNotFound, "block %d not found", height)
+	}
+	return blk, nil
+}
+
+func (s *Server) getBlockByNumber(params []json.RawMessage) (interface{}, error) {
+	blk, err := s.blockAt(params)
+	if err != nil {
+		return nil, err
+	}
+	return newBlockResult(blk), nil
+}
+
+func (s *Server) getBlockByHash(params []json.RawMessage) (interface{}, error) {
+	b, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 32 {
+		return nil, Errorf(ErrCodeInvalidParams, "block hash must be 32 bytes, got %d", len(b))
+	}
+	blk := s.chain.BlockByHash([32]byte(b))
+	if blk == nil {
+		return nil, Errorf(ErrCodeNotFound, "block %x not found", b)
+	}
+	return newBlockResult(blk), nil
+}
+
+func (s *Server) getHeaderByNumber(params []json.RawMessage) (interface{}, error) {
+	blk, err := s.blockAt(params)
+	if err != nil {
+		return nil, err
+	}
+	return &blk.Header, nil
+}
+
+func (s *Server) getBalance(params []json.RawMessage) (interface{}, error) {
+	addr, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.chain.GetBalance(addr).String(), nil
+}
+
+func (s *Server) getNonce(params []json.RawMessage) (interface{}, error) {
+	addr, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Pending by default so back-to-back sends get consecutive nonces
+	var tag string
+	if len(params) > 1 {
+		if err := paramAt(params, 1, &tag); err != nil {
+			return nil, err
+		}
+	}
+	switch tag {
+	case "", "pending":
+		return s.chain.GetPendingNonce(addr), nil
+	case "latest":
+		return s.chain.GetNonce(addr), nil
+	}
+	return nil, Errorf(ErrCodeInvalidParams, "unknown nonce tag %q", tag)
+}
+
+func (s *Server) sendTransaction(params []json.RawMessage) (interface{}, error) {
+	var tx core.Transaction
+	if err := paramAt(params, 0, &tx); err != nil {
+		return nil, err
+	}
+	// Negative values have no canonical encoding and cannot be hashed
+	if (tx.Amount != nil && tx.Amount.Sign() < 0) || (tx.GasPrice != nil && tx.GasPrice.Sign() < 0) {
+		return nil, Errorf(ErrCodeInvalidParams, "negative amount or gas price")
+	}
+	tx.Hash = tx.CalculateHash()
+	return s.submitTx(&tx)
+}
+
+// sendRawTransaction accepts a transaction signed elsewhere, as the hex of
+// its RLP encoding.
+func (s *Server) sendRawTransaction(params []json.RawMessage) (interface{}, error) {
+	raw, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := core.DecodeTransaction(raw)
+	if err != nil {
+		return nil, Errorf(ErrCodeInvalidParams, "%v", err)
+	}
+	if tx.IsCoinbase() {
+		return nil, Errorf(ErrCodeInvalidParams, "transaction has no sender")
+	}
+	return s.submitTx(tx)
+}
+
+// submitTx adds tx to the mempool, from where it is gossiped to peers, and
+// returns its hash.
+func (s *Server) submitTx(tx *core.Transaction) (interface{}, error) {
+	if err := s.chain.Mempool.AddTransaction(tx); err != nil {
+		return nil, Errorf(ErrCodeRejected, "%v", err)
+	}
+	return hex.EncodeToString(tx.Hash), nil
+}
+
+func (s *Server) mempoolStats(params []json.RawMessage) (interface{}, error) {
+	return s.chain.Mempool.GetStats(), nil
+}
+
+func (s *Server) getDepositProof(params []json.RawMessage) (interface{}, error) {
+	txHash, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := s.chain.BridgeDepositProof(txHash)
+	if err != nil {
+		return nil, Errorf(ErrCodeNotFound, "%v", err)
+	}
+	return proof, nil
+}
+
+func (s *Server) getTransactionReceipt(params []json.RawMessage) (interface{}, error) {
+	txHash, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.chain.TransactionReceipt(txHash)
+	if err != nil {
+		return nil, Errorf(ErrCodeNotFound, "%v", err)
+	}
+	return r, nil
+}
+
+func (s *Server) getTransactionByHash(params []json.RawMessage) (interface{}, error) {
+	txHash, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if tx, loc, err := s.chain.TransactionByHash(txHash); err == nil {
+		return newTxResult(tx, loc), nil
+	}
+	if tx := s.chain.Mempool.GetTransaction(txHash); tx != nil {
+		return &txResult{Transaction: tx}, nil
+	}
+	return nil, Errorf(ErrCodeNotFound, "transaction %x not found", txHash)
+}
+
+func (s *Server) getAddressTransactions(params []json.RawMessage) (interface{}, error) {
+	addr, err := hexParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	offset, limit, err := pageParams(params, 1, defaultHistoryLimit, maxHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	locs, err := s.chain.AddressTransactions(addr, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*txResult, 0, len(locs))
+	for _, loc := range locs {
+		tx, full, err := s.chain.TransactionByHash(loc.TxHash)
+		if err != nil {
+			// Pruned block, or the entry raced a reorg
+			continue
+		}
+		out = append(out, newTxResult(tx, full))
+	}
+	return out, nil
+}
+
+func (s *Server) getSupply(params []json.RawMessage) (interface{}, error) {
+	return s.chain.Supply()
+}
+
+func (s *Server) getEmission(params []json.RawMessage) (interface{}, error) {
+	var height uint64
+	if err := paramAt(params, 0, &height); err != nil {
+		return nil, err
+	}
+	e := s.chain.Emission(height)
+	if e == nil {
+		return nil, Errorf(ErrCodeNotFound, "block %d not found", height)
+	}
+	return e, nil
+}
+
+func (s *Server) getRichList(params []json.RawMessage) (interface{}, error) {
+	offset, limit, err := pageParams(params, 0, defaultRichListLimit, maxRichListLimit)
+	if err != nil {
+		return nil, err
+	}
+	return s.chain.RichList(offset, limit)
+}
+
+func (s *Server) getDeployments(params []json.RawMessage) (interface{}, error) {
+	return s.chain.DeploymentStatuses()
+}
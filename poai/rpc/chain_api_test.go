@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"poai/core"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestLookupByHash(t *testing.T) {
+	c := core.NewChain(t.TempDir(), 1000)
+	defer c.Close()
+	txs := []*core.Transaction{core.NewCoinbaseTx(bytes.Repeat([]byte{9}, 20), core.BlockReward(1, nil))}
+	parent := c.HeaderByHeight(0)
+	b := core.NewBlock(1, parent.Hash(), -1, parent.Target(), txs, 42)
+	b.Header.StateRoot, b.Header.ReceiptsRoot, _ = c.ComputeRoots(txs)
+	if err := c.ImportTrustedBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c)
+	hash := b.Hash()
+	blockHash := hex.EncodeToString(hash[:])
+
+	var blk struct {
+		Hash   string `json:"hash"`
+		Header struct {
+			Height uint64
+			Lhat   int64
+			Bits   uint32 `json:"bits"`
+			Nonce  uint64 `json:"nonce"`
+		} `json:"header"`
+		Transactions []json.RawMessage `json:"transactions"`
+	}
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"poai_getBlockByHash","params":["` + blockHash + `"]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"poai_getBlockByNumber","params":[1]}`,
+	} {
+		resp := post(t, s, body)
+		if resp.Error != nil {
+			t.Fatalf("%s: %+v", body, resp.Error)
+		}
+		raw, _ := json.Marshal(resp.Result)
+		json.Unmarshal(raw, &blk)
+		if blk.Hash != blockHash || blk.Header.Height != 1 || blk.Header.Lhat != -1 || blk.Header.Bits != b.Header.Bits || blk.Header.Nonce != 42 || len(blk.Transactions) != 1 {
+			t.Fatalf("%s: got %s", body, raw)
+		}
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"poai_getBlockByHash","params":["`+hex.EncodeToString(make([]byte, 32))+`"]}`); resp.Error == nil || resp.Error.Code != ErrCodeNotFound {
+		t.Fatalf("unknown block: %+v", resp)
+	}
+
+	resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"poai_getTransactionByHash","params":["`+hex.EncodeToString(txs[0].Hash)+`"]}`)
+	if resp.Error != nil {
+		t.Fatalf("getTransactionByHash: %+v", resp.Error)
+	}
+	var tx struct {
+		Hash        []byte  `json:"hash"`
+		BlockHash   string  `json:"blockHash"`
+		BlockNumber *uint64 `json:"blockNumber"`
+		TxIndex     uint32  `json:"transactionIndex"`
+	}
+	raw, _ := json.Marshal(resp.Result)
+	json.Unmarshal(raw, &tx)
+	if !bytes.Equal(tx.Hash, txs[0].Hash) || tx.BlockHash != blockHash || tx.BlockNumber == nil || *tx.BlockNumber != 1 || tx.TxIndex != 0 {
+		t.Fatalf("transaction: %s", raw)
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"poai_getTransactionByHash","params":["00"]}`); resp.Error == nil || resp.Error.Code != ErrCodeNotFound {
+		t.Fatalf("unknown transaction: %+v", resp)
+	}
+
+	miner := hex.EncodeToString(txs[0].To)
+	for body, want := range map[string]int{
+		`{"jsonrpc":"2.0","id":1,"method":"poai_getAddressTransactions","params":["` + miner + `"]}`:        1,
+		`{"jsonrpc":"2.0","id":1,"method":"poai_getAddressTransactions","params":["` + miner + `", 1, 10]}`: 0,
+	} {
+		resp := post(t, s, body)
+		hist, ok := resp.Result.([]interface{})
+		if resp.Error != nil || !ok || len(hist) != want {
+			t.Fatalf("%s: %+v", body, resp)
+		}
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"poai_getAddressTransactions","params":["`+miner+`", 0, 0]}`); resp.Error == nil || resp.Error.Code != ErrCodeInvalidParams {
+		t.Fatalf("zero limit: %+v", resp)
+	}
+}
+
+func TestSendRawTransaction(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priv.PublicKey).Bytes()
+	g := core.DefaultGenesis(1000)
+	g.Alloc = map[string]string{hex.EncodeToString(from): "1000000"}
+	c, err := core.NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := NewServer(c)
+
+	tx := core.NewTx(from, bytes.Repeat([]byte{7}, 20), big.NewInt(5), 0)
+	if err := tx.Sign(priv); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := tx.Encode()
+	resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"poai_sendRawTransaction","params":["0x`+hex.EncodeToString(raw)+`"]}`)
+	if resp.Error != nil || resp.Result != hex.EncodeToString(tx.Hash) {
+		t.Fatalf("send: %+v", resp)
+	}
+	if c.Mempool.GetTransaction(tx.Hash) == nil {
+		t.Fatal("transaction not in the mempool")
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":2,"method":"poai_sendRawTransaction","params":["`+hex.EncodeToString(raw)+`"]}`); resp.Error == nil || resp.Error.Code != ErrCodeRejected {
+		t.Fatalf("duplicate: %+v", resp)
+	}
+
+	coinbase, _ := core.NewCoinbaseTx(from, big.NewInt(1)).Encode()
+	for _, param := range []string{hex.EncodeToString(coinbase), "c0ffee"} {
+		if resp := post(t, s, `{"jsonrpc":"2.0","id":3,"method":"poai_sendRawTransaction","params":["`+param+`"]}`); resp.Error == nil || resp.Error.Code != ErrCodeInvalidParams {
+			t.Fatalf("%s: %+v", param, resp)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Health serves the /healthz (liveness) and /readyz (readiness) probes.
+// Each answers 200 when all its checks pass and 503 otherwise, with a JSON
+// body naming the failed checks. Readiness includes the liveness checks.
+type Health struct {
+	mu    sync.RWMutex
+	live  []healthCheck
+	ready []healthCheck
+}
+
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// HealthReport is the body of a probe response.
+type HealthReport struct {
+	Status string            `json:"status"` // "ok" or "fail"
+	Checks map[string]string `json:"checks"` // check name -> "ok" or the failure
+}
+
+// NewHealth returns probes with no checks.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// AddLiveness adds a check that fails both probes: the node is broken and
+// should be restarted.
+func (h *Health) AddLiveness(name string, check func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.live = append(h.live, healthCheck{name, check})
+}
+
+// AddReadiness adds a check that fails /readyz only: the node works but
+// should not get traffic yet.
+func (h *Health) AddReadiness(name string, check func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = append(h.ready, healthCheck{name, check})
+}
+
+// Register adds the probe handlers to mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.serveLive)
+	mux.HandleFunc("/readyz", h.serveReady)
+}
+
+func (h *Health) serveLive(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	checks := h.live
+	h.mu.RUnlock()
+	serveHealth(w, checks)
+}
+
+func (h *Health) serveReady(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	checks := append(append([]healthCheck{}, h.live...), h.ready...)
+	h.mu.RUnlock()
+	serveHealth(w, checks)
+}
+
+func serveHealth(w http.ResponseWriter, checks []healthCheck) {
+	report := HealthReport{Status: "ok", Checks: make(map[string]string, len(checks))}
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			report.Status = "fail"
+			report.Checks[c.name] = err.Error()
+		} else {
+			report.Checks[c.name] = "ok"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(&report)
+}
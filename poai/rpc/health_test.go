@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthProbes(t *testing.T) {
+	h := NewHealth()
+	var synced error
+	h.AddLiveness("db", func() error { return nil })
+	h.AddReadiness("sync", func() error { return synced })
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	probe := func(path string) (int, HealthReport) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return rec.Code, report
+	}
+	if code, report := probe("/readyz"); code != http.StatusOK || report.Status != "ok" || report.Checks["db"] != "ok" {
+		t.Fatalf("ready: %d %+v", code, report)
+	}
+	synced = errors.New("behind")
+	if code, report := probe("/readyz"); code != http.StatusServiceUnavailable || report.Checks["sync"] != "behind" {
+		t.Fatalf("lagging node ready: %d %+v", code, report)
+	}
+	// Lagging is not a liveness failure
+	if code, report := probe("/healthz"); code != http.StatusOK || len(report.Checks) != 1 {
+		t.Fatalf("lagging node not live: %d %+v", code, report)
+	}
+}
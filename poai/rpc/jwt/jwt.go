@@ -0,0 +1,113 @@
+// Package jwt creates and checks the short-lived HS256 tokens that
+// authenticate JSON-RPC callers holding the node's shared secret.
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// MaxSkew bounds how far the iat claim of a token may be from the
+// verifier's clock, so captured tokens soon stop working.
+const MaxSkew = 60 * time.Second
+
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// New returns a token issued now, signed with secret.
+func New(secret []byte) string {
+	claims, _ := json.Marshal(struct {
+		IssuedAt int64 `json:"iat"`
+	}{time.Now().Unix()})
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(secret, signed))
+}
+
+func sign(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// Verify checks the HS256 signature of token and that it was issued within
+// MaxSkew of now.
+func Verify(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed JWT")
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodePart(parts[0], &hdr); err != nil {
+		return fmt.Errorf("JWT header: %w", err)
+	}
+	if hdr.Alg != "HS256" {
+		return fmt.Errorf("unsupported JWT algorithm %q", hdr.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(secret, parts[0]+"."+parts[1])) {
+		return errors.New("invalid JWT signature")
+	}
+	var claims struct {
+		IssuedAt *int64 `json:"iat"`
+	}
+	if err := decodePart(parts[1], &claims); err != nil {
+		return fmt.Errorf("JWT claims: %w", err)
+	}
+	if claims.IssuedAt == nil {
+		return errors.New("JWT has no iat claim")
+	}
+	if skew := now.Sub(time.Unix(*claims.IssuedAt, 0)); skew > MaxSkew || skew < -MaxSkew {
+		return fmt.Errorf("JWT issued %v away from now", skew.Round(time.Second))
+	}
+	return nil
+}
+
+func decodePart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// LoadSecret reads the secret at path like ReadSecret. If the file does not
+// exist, a random 32-byte secret is written to it.
+func LoadSecret(path string) ([]byte, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("write JWT secret: %w", err)
+		}
+		return secret, nil
+	}
+	return ReadSecret(path)
+}
+
+// ReadSecret reads a hex-encoded secret of at least 32 bytes from path.
+func ReadSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("JWT secret %s is not hex: %w", path, err)
+	}
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("JWT secret %s has %d bytes, want at least 32", path, len(secret))
+	}
+	return secret, nil
+}
@@ -0,0 +1,47 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	token := New(secret)
+	if err := Verify(secret, token, time.Now()); err != nil {
+		t.Fatalf("fresh token: %v", err)
+	}
+	if err := Verify(secret, token, time.Now().Add(2*MaxSkew)); err == nil {
+		t.Fatal("stale token accepted")
+	}
+	if err := Verify([]byte("another secret, just as long...."), token, time.Now()); err == nil {
+		t.Fatal("token signed with another secret accepted")
+	}
+	parts := strings.Split(token, ".")
+	if err := Verify(secret, parts[0]+"."+parts[1]+".", time.Now()); err == nil {
+		t.Fatal("unsigned token accepted")
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt.hex")
+	created, err := LoadSecret(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(created) != 32 {
+		t.Fatalf("created %d bytes, want 32", len(created))
+	}
+	read, err := ReadSecret(path)
+	if err != nil || string(read) != string(created) {
+		t.Fatalf("read back %x, %v; want %x", read, err, created)
+	}
+
+	os.WriteFile(path, []byte("abcd\n"), 0o600)
+	if _, err := LoadSecret(path); err == nil {
+		t.Fatal("short secret accepted")
+	}
+}
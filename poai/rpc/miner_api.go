@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"poai/core"
+	"poai/miner"
+)
+
+// maxGenerate caps the blocks one miner_generate call mines.
+const maxGenerate = 1000
+
+// MinerControl is the start/stop switch of the local miner.
+type MinerControl interface {
+	Start()
+	Stop()
+	Status() miner.Status
+}
+
+// Generator mines n blocks right away, paying address (hex; empty = the
+// node's miner address), and returns those it imported.
+type Generator func(n int, address string) ([]*core.Block, error)
+
+// RegisterMiner exposes miner control methods. Only call it on servers
+// bound to a trusted interface.
+func (s *Server) RegisterMiner(m MinerControl) {
+	s.Register("miner_start", func(params []json.RawMessage) (interface{}, error) {
+		m.Start()
+		return m.Status(), nil
+	})
+	s.Register("miner_stop", func(params []json.RawMessage) (interface{}, error) {
+		m.Stop()
+		return m.Status(), nil
+	})
+	s.Register("miner_status", func(params []json.RawMessage) (interface{}, error) {
+		return m.Status(), nil
+	})
+}
+
+// RegisterGenerate exposes miner_generate, which mines blocks on demand.
+// Only regtest nodes register it.
+func (s *Server) RegisterGenerate(gen Generator) {
+	s.Register("miner_generate", func(params []json.RawMessage) (interface{}, error) {
+		var n int
+		if err := paramAt(params, 0, &n); err != nil {
+			return nil, err
+		}
+		if n < 1 || n > maxGenerate {
+			return nil, Errorf(ErrCodeInvalidParams, "block count must be between 1 and %d", maxGenerate)
+		}
+		var address string
+		if len(params) > 1 {
+			addr, err := hexParam(params, 1)
+			if err != nil {
+				return nil, err
+			}
+			address = hex.EncodeToString(addr)
+		}
+		blocks, err := gen(n, address)
+		if err != nil {
+			return nil, Errorf(ErrCodeInternal, "generated %d of %d blocks: %v", len(blocks), n, err)
+		}
+		hashes := make([]string, len(blocks))
+		for i, b := range blocks {
+			hash := b.Hash()
+			hashes[i] = hex.EncodeToString(hash[:])
+		}
+		return hashes, nil
+	})
+}
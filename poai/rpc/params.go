@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// paramAt decodes positional parameter i into out.
+func paramAt(params []json.RawMessage, i int, out interface{}) error {
+	if i >= len(params) {
+		return Errorf(ErrCodeInvalidParams, "missing parameter %d", i)
+	}
+	if err := json.Unmarshal(params[i], out); err != nil {
+		return Errorf(ErrCodeInvalidParams, "invalid parameter %d: %v", i, err)
+	}
+	return nil
+}
+
+// hexParam decodes positional parameter i as a hex string (optional 0x prefix).
+func hexParam(params []json.RawMessage, i int) ([]byte, error) {
+	var s string
+	if err := paramAt(params, i, &s); err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, Errorf(ErrCodeInvalidParams, "parameter %d is not hex: %v", i, err)
+	}
+	return b, nil
+}
+
+// pageParams decodes the optional offset and limit parameters at i and
+// i+1.
+func pageParams(params []json.RawMessage, i, defaultLimit, maxLimit int) (offset, limit int, err error) {
+	limit = defaultLimit
+	if len(params) > i {
+		if err := paramAt(params, i, &offset); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(params) > i+1 {
+		if err := paramAt(params, i+1, &limit); err != nil {
+			return 0, 0, err
+		}
+	}
+	if offset < 0 || limit <= 0 || limit > maxLimit {
+		return 0, 0, Errorf(ErrCodeInvalidParams, "offset must be >= 0 and limit between 1 and %d", maxLimit)
+	}
+	return offset, limit, nil
+}
@@ -0,0 +1,321 @@
+// Package rpc implements the poaid JSON-RPC 2.0 API over HTTP.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"poai/core"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+	ErrCodeNotFound       = -32001 // requested object does not exist
+	ErrCodeRejected       = -32002 // transaction rejected by the mempool
+	ErrCodeUnauthorized   = -32003 // method requires authentication
+)
+
+// maxRequestBody caps the size of a single HTTP request.
+const maxRequestBody = 1 << 20
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Errorf builds an *Error with the given code.
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler serves one RPC method. params holds the raw positional parameters.
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server dispatches JSON-RPC requests to registered handlers.
+type Server struct {
+	chain *core.Chain
+
+	mu       sync.RWMutex
+	methods  map[string]Handler
+	readOnly map[string]bool // methods open to unauthenticated callers
+	auth     *AuthConfig
+	tlsCert  string // TLS certificate and key files; empty = plain HTTP
+	tlsKey   string
+	origins  []string // browser origins allowed besides the API's own; "*" = any
+	vhosts   []string // Host header values served; nil = localhost and loopback IPs, "*" = any
+	health   *Health  // served next to the API when set
+	httpSrv  *http.Server
+}
+
+// NewServer creates a server exposing the chain API.
+func NewServer(chain *core.Chain) *Server {
+	s := &Server{chain: chain, methods: make(map[string]Handler), readOnly: make(map[string]bool)}
+	s.registerChainAPI()
+	return s
+}
+
+// Register adds or replaces a method handler.
+func (s *Server) Register(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = h
+	delete(s.readOnly, method)
+}
+
+// RegisterReadOnly adds or replaces a method that does not change node
+// state. With AuthConfig.PublicReadOnly, callers need no credentials for it.
+func (s *Server) RegisterReadOnly(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = h
+	if s.readOnly == nil {
+		s.readOnly = make(map[string]bool)
+	}
+	s.readOnly[method] = true
+}
+
+// SetAuth requires callers to authenticate as configured by a. Call it
+// before ListenAndServe.
+func (s *Server) SetAuth(a AuthConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = &a
+}
+
+// SetTLS serves the API over HTTPS with the given PEM certificate and key
+// files. Call it before ListenAndServe.
+func (s *Server) SetTLS(certFile, keyFile string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsCert, s.tlsKey = certFile, keyFile
+}
+
+// SetAllowedOrigins lets web pages from origins (scheme://host[:port], or
+// "*" for any) call the API. Browsers from other origins are refused, so a
+// page the user visits cannot drive a node on localhost. Call it before
+// ListenAndServe.
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.origins = origins
+}
+
+// SetVirtualHosts serves only requests whose Host header names one of hosts
+// (without port, or "*" for any) rather than localhost or a loopback IP.
+// Checking the Host keeps a page whose domain was rebound to 127.0.0.1 from
+// passing as the API's own origin. Call it before ListenAndServe.
+func (s *Server) SetVirtualHosts(hosts []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vhosts = hosts
+}
+
+// hostAllowed reports whether the Host header of r names a served host.
+func (s *Server) hostAllowed(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.vhosts == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return ip.IsLoopback()
+		}
+		return strings.EqualFold(host, "localhost")
+	}
+	for _, h := range s.vhosts {
+		if h == "*" || strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed reports whether r may be served: it has no Origin header
+// (it does not come from a browser), comes from the API's own origin or
+// from an allowed one.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, o := range s.origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks the credentials of r. It reports whether r may call
+// every method, and whether it may call any method at all.
+func (s *Server) authorize(r *http.Request) (full, allowed bool) {
+	s.mu.RLock()
+	auth := s.auth
+	s.mu.RUnlock()
+	if auth.authorized(r) {
+		return true, true
+	}
+	return false, auth.PublicReadOnly
+}
+
+// call runs a single request and builds its response. Unless authorized,
+// only read-only methods may be called.
+func (s *Server) call(req *request, authorized bool) *response {
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = Errorf(ErrCodeInvalidRequest, "invalid request")
+		return resp
+	}
+	s.mu.RLock()
+	h, ok := s.methods[req.Method]
+	readOnly := s.readOnly[req.Method]
+	s.mu.RUnlock()
+	if !ok {
+		resp.Error = Errorf(ErrCodeMethodNotFound, "method %s not found", req.Method)
+		return resp
+	}
+	if !authorized && !readOnly {
+		resp.Error = Errorf(ErrCodeUnauthorized, "method %s requires authentication", req.Method)
+		return resp
+	}
+	result, err := h(req.Params)
+	if err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			resp.Error = rpcErr
+		} else {
+			resp.Error = Errorf(ErrCodeInternal, "%v", err)
+		}
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// ServeHTTP handles POSTed JSON-RPC requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.hostAllowed(r) {
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	if !s.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	// Browsers send text/plain and form posts cross-site without asking,
+	// application/json only after a CORS preflight this server never grants
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		http.Error(w, "JSON-RPC requires Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	full, allowed := s.authorize(r)
+	if !allowed {
+		writeUnauthorized(w)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		json.NewEncoder(w).Encode(&response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: Errorf(ErrCodeParse, "parse error: %v", err)})
+		return
+	}
+	json.NewEncoder(w).Encode(s.call(&req, full))
+}
+
+// writeUnauthorized answers 401 with a JSON-RPC error body, so clients
+// report it like any other RPC error.
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="poaid"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(&response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: Errorf(ErrCodeUnauthorized, "authentication required")})
+}
+
+// SetHealth serves h's probes on the API address. Call it before
+// ListenAndServe.
+func (s *Server) SetHealth(h *Health) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health = h
+}
+
+// ListenAndServe serves the API on addr until the listener fails or
+// Shutdown is called, in which case it returns http.ErrServerClosed.
+func (s *Server) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	mux.HandleFunc("/ws", s.ServeWS)
+	s.mu.Lock()
+	if s.health != nil {
+		s.health.Register(mux)
+	}
+	srv := &http.Server{Addr: addr, Handler: mux}
+	s.httpSrv = srv
+	cert, key := s.tlsCert, s.tlsKey
+	s.mu.Unlock()
+	if cert != "" {
+		log.Printf("[RPC] JSON-RPC server listening on https://%s", addr)
+		return srv.ListenAndServeTLS(cert, key)
+	}
+	log.Printf("[RPC] JSON-RPC server listening on http://%s", addr)
+	return srv.ListenAndServe()
+}
+
+// Shutdown stops accepting requests and waits for active ones until ctx
+// expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.RLock()
+	srv := s.httpSrv
+	s.mu.RUnlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
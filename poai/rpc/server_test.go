@@ -0,0 +1,126 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func post(t *testing.T, s *Server, body string) response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8545/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	s.ServeHTTP(rec, req)
+	var resp response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestServerDispatch(t *testing.T) {
+	s := &Server{methods: make(map[string]Handler)}
+	s.Register("test_echo", func(params []json.RawMessage) (interface{}, error) {
+		var v string
+		if err := paramAt(params, 0, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["hi"]}`); resp.Error != nil || resp.Result != "hi" {
+		t.Fatalf("echo: %+v", resp)
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":2,"method":"nope","params":[]}`); resp.Error == nil || resp.Error.Code != ErrCodeMethodNotFound {
+		t.Fatalf("expected method not found, got %+v", resp)
+	}
+	if resp := post(t, s, `{"jsonrpc":"2.0","id":3,"method":"test_echo","params":[]}`); resp.Error == nil || resp.Error.Code != ErrCodeInvalidParams {
+		t.Fatalf("expected invalid params, got %+v", resp)
+	}
+	if resp := post(t, s, `{not json`); resp.Error == nil || resp.Error.Code != ErrCodeParse {
+		t.Fatalf("expected parse error, got %+v", resp)
+	}
+}
+
+func TestServerRefusesCrossSiteRequests(t *testing.T) {
+	s := &Server{methods: make(map[string]Handler)}
+	s.Register("admin_stop", func([]json.RawMessage) (interface{}, error) { return true, nil })
+	body := `{"jsonrpc":"2.0","id":1,"method":"admin_stop","params":[]}`
+	serve := func(contentType, origin string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8545/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("application/json; charset=utf-8", ""); code != http.StatusOK {
+		t.Fatalf("JSON without origin: %d", code)
+	}
+	if code := serve("text/plain", ""); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("text/plain post: %d", code)
+	}
+	if code := serve("application/json", "http://127.0.0.1:8545"); code != http.StatusOK {
+		t.Fatalf("same origin: %d", code)
+	}
+	if code := serve("application/json", "https://evil.example"); code != http.StatusForbidden {
+		t.Fatalf("foreign origin: %d", code)
+	}
+	s.SetAllowedOrigins([]string{"https://wallet.example"})
+	if code := serve("application/json", "https://wallet.example"); code != http.StatusOK {
+		t.Fatalf("allowed origin: %d", code)
+	}
+
+	// WebSocket upgrades from foreign pages are refused before upgrading
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:8545/ws", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	s.ServeWS(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign WebSocket upgrade: %d", rec.Code)
+	}
+}
+
+func TestServerRefusesForeignHosts(t *testing.T) {
+	s := &Server{methods: make(map[string]Handler)}
+	s.Register("admin_stop", func([]json.RawMessage) (interface{}, error) { return true, nil })
+	body := `{"jsonrpc":"2.0","id":1,"method":"admin_stop","params":[]}`
+	serve := func(host string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		// A rebound page is same-origin as far as the browser knows
+		req.Header.Set("Origin", "http://"+host)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, host := range []string{"127.0.0.1:8545", "localhost:8545", "[::1]:8545", "127.0.0.2"} {
+		if code := serve(host); code != http.StatusOK {
+			t.Fatalf("%s: %d", host, code)
+		}
+	}
+	if code := serve("rebind.evil.example:8545"); code != http.StatusForbidden {
+		t.Fatalf("rebound host: %d", code)
+	}
+	s.SetVirtualHosts([]string{"node.example"})
+	if code := serve("node.example:8545"); code != http.StatusOK {
+		t.Fatalf("listed host: %d", code)
+	}
+	if code := serve("localhost:8545"); code != http.StatusForbidden {
+		t.Fatalf("unlisted localhost: %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://rebind.evil.example:8545/ws", nil)
+	rec := httptest.NewRecorder()
+	s.ServeWS(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("WebSocket upgrade for a foreign host: %d", rec.Code)
+	}
+}
@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"poai/core"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsSendBuffer is the number of outgoing messages queued per connection
+// before a slow client is disconnected.
+const wsSendBuffer = 256
+
+const wsBufferSize = 4096
+
+// HeadEvent is the payload of a newHeads notification.
+type HeadEvent struct {
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+	Parent string `json:"parent"`
+}
+
+// ChainEvent is the payload of a chainEvents notification: Block is set
+// for blockAdded and blockRemoved, the rest for reorgStarted and
+// reorgFinished (OldTip is nil if the branch replaced no blocks).
+type ChainEvent struct {
+	Type       core.ChainEventType `json:"type"`
+	Block      *HeadEvent          `json:"block,omitempty"`
+	ForkHeight *uint64             `json:"forkHeight,omitempty"`
+	OldTip     *HeadEvent          `json:"oldTip,omitempty"`
+	NewTip     *HeadEvent          `json:"newTip,omitempty"`
+}
+
+func headEvent(blk *core.Block) *HeadEvent {
+	if blk == nil {
+		return nil
+	}
+	hash := blk.Hash()
+	return &HeadEvent{Height: blk.Header.Height, Hash: hex.EncodeToString(hash[:]), Parent: hex.EncodeToString(blk.Header.ParentHash[:])}
+}
+
+type notification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  notificationParams `json:"params"`
+}
+
+type notificationParams struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// wsConn is one WebSocket client with its active subscriptions.
+type wsConn struct {
+	s          *Server
+	conn       *websocket.Conn
+	send       chan interface{}
+	done       chan struct{}
+	authorized bool // may call every method, not only read-only ones
+
+	mu     sync.Mutex
+	subs   map[string]func() // subscription ID -> cancel
+	nextID uint64
+	closed sync.Once
+}
+
+// ServeWS upgrades the request and serves calls and subscriptions on it.
+// The origin and credentials are checked once, on the upgrade request;
+// subscriptions are read-only.
+func (s *Server) ServeWS(w http.ResponseWriter, r *http.Request) {
+	if !s.hostAllowed(r) {
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	if !s.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	full, allowed := s.authorize(r)
+	if !allowed {
+		writeUnauthorized(w)
+		return
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  wsBufferSize,
+		WriteBufferSize: wsBufferSize,
+		CheckOrigin: func(r *http.Request) bool {
+			return s.hostAllowed(r) && s.originAllowed(r)
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{
+		s:          s,
+		conn:       conn,
+		send:       make(chan interface{}, wsSendBuffer),
+		done:       make(chan struct{}),
+		authorized: full,
+		subs:       make(map[string]func()),
+	}
+	go c.writeLoop()
+	c.readLoop()
+}
+
+func (c *wsConn) close() {
+	c.closed.Do(func() {
+		close(c.done)
+		c.conn.Close()
+		c.mu.Lock()
+		for id, cancel := range c.subs {
+			cancel()
+			delete(c.subs, id)
+		}
+		c.mu.Unlock()
+	})
+}
+
+// enqueue queues a message, dropping the connection if the client can't keep up.
+func (c *wsConn) enqueue(msg interface{}) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		log.Printf("[RPC] WebSocket client too slow, disconnecting")
+		c.close()
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *wsConn) readLoop() {
+	defer c.close()
+	c.conn.SetReadLimit(maxRequestBody)
+	for {
+		var req request
+		if err := c.conn.ReadJSON(&req); err != nil {
+			return
+		}
+		switch req.Method {
+		case "poai_subscribe":
+			c.enqueue(c.subscribe(&req))
+		case "poai_unsubscribe":
+			c.enqueue(c.unsubscribe(&req))
+		default:
+			c.enqueue(c.s.call(&req, c.authorized))
+		}
+	}
+}
+
+func (c *wsConn) subscribe(req *request) *response {
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	var topic string
+	if err := paramAt(req.Params, 0, &topic); err != nil {
+		resp.Error = err.(*Error)
+		return resp
+	}
+	id := fmt.Sprintf("0x%x", atomic.AddUint64(&c.nextID, 1))
+	var cancel func()
+	switch topic {
+	case "newHeads":
+		cancel = c.streamHeads(id)
+	case "pendingTransactions":
+		cancel = c.streamPendingTxs(id)
+	case "chainEvents":
+		cancel = c.streamChainEvents(id)
+	default:
+		resp.Error = Errorf(ErrCodeInvalidParams, "unknown subscription %q", topic)
+		return resp
+	}
+	c.mu.Lock()
+	c.subs[id] = cancel
+	c.mu.Unlock()
+	resp.Result = id
+	return resp
+}
+
+func (c *wsConn) unsubscribe(req *request) *response {
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	var id string
+	if err := paramAt(req.Params, 0, &id); err != nil {
+		resp.Error = err.(*Error)
+		return resp
+	}
+	c.mu.Lock()
+	cancel, ok := c.subs[id]
+	delete(c.subs, id)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	resp.Result = ok
+	return resp
+}
+
+func (c *wsConn) notify(id string, result interface{}) {
+	c.enqueue(&notification{JSONRPC: "2.0", Method: "poai_subscription", Params: notificationParams{Subscription: id, Result: result}})
+}
+
+func (c *wsConn) streamHeads(id string) func() {
+	sub := c.s.chain.SubscribeToHeadChanges()
+	go func() {
+		var last uint64
+		for range sub.C {
+			h := c.s.chain.CurrentHeight()
+			if h == last {
+				continue
+			}
+			last = h
+			if ev := headEvent(c.s.chain.BlockByHeight(h)); ev != nil {
+				c.notify(id, ev)
+			}
+		}
+	}()
+	return sub.Unsubscribe
+}
+
+func (c *wsConn) streamPendingTxs(id string) func() {
+	sub := c.s.chain.Mempool.SubscribeNewTransactions(wsSendBuffer)
+	go func() {
+		for tx := range sub.C {
+			c.notify(id, tx)
+		}
+	}()
+	return sub.Unsubscribe
+}
+
+func (c *wsConn) streamChainEvents(id string) func() {
+	sub := c.s.chain.SubscribeChainEvents(wsSendBuffer)
+	go func() {
+		for ev := range sub.C {
+			out := &ChainEvent{Type: ev.Type, Block: headEvent(ev.Block)}
+			if ev.Type == core.EventReorgStarted || ev.Type == core.EventReorgFinished {
+				fork := ev.ForkHeight
+				out.ForkHeight, out.OldTip, out.NewTip = &fork, headEvent(ev.OldTip), headEvent(ev.NewTip)
+			}
+			c.notify(id, out)
+		}
+		if sub.Overflowed() {
+			// Events were dropped; end the connection so the client
+			// resubscribes and resyncs
+			c.close()
+		}
+	}()
+	return sub.Unsubscribe
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupDisabled(t *testing.T) {
+	stop, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Setup(context.Background(), Config{Endpoint: "127.0.0.1:4317", SampleRatio: 2}); err == nil {
+		t.Fatal("sample ratio 2 accepted")
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	End(failed, errors.New("boom"))
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended", len(spans))
+	}
+	if spans[0].Status().Code != codes.Unset || spans[1].Status().Code != codes.Error || spans[1].Status().Description != "boom" {
+		t.Fatalf("statuses %v, %v", spans[0].Status(), spans[1].Status())
+	}
+}
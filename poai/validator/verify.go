@@ -2,11 +2,12 @@
 package validator
 
 import (
-	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"slices"
 
 	"poai/core"
+	"poai/core/config"
 	"poai/core/storage"
 	"poai/dataset"
 	"poai/inference"
@@ -25,11 +26,40 @@ func lossToInt(loss float64) int64 { return int64(loss) }
 // LossToInt is exported for tests.
 func LossToInt(loss float64) int64 { return int64(loss) }
 
+// Verifier replays blocks with a model loaded once, so it can be installed
+// as the chain's ProofVerifier.
+type Verifier struct {
+	llm inference.Engine
+}
+
+// NewVerifier loads the model used to replay block proofs.
+func NewVerifier(modelPath string, gpuLayers int) (*Verifier, error) {
+	llm, err := inference.NewLLM(modelPath, gpuLayers)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load LLM: %v", err)
+	}
+	return &Verifier{llm: llm}, nil
+}
+
+// NewEngineVerifier replays block proofs on an already loaded engine, such
+// as remote inference workers.
+func NewEngineVerifier(llm inference.Engine) *Verifier {
+	return &Verifier{llm: llm}
+}
+
 // VerifyBlock validates a block using the new nonce-based approach
 func VerifyBlock(b *core.Block, st storage.Reader, modelPath string, gpuLayers int) error {
-	llm, err := inference.NewLLM(modelPath, gpuLayers)
+	v, err := NewVerifier(modelPath, gpuLayers)
 	if err != nil {
-		return fmt.Errorf("Failed to load LLM: %v", err)
+		return err
+	}
+	return v.Verify(b)
+}
+
+// Verify replays the block's quiz and checks the claimed loss and target.
+func (v *Verifier) Verify(b *core.Block) error {
+	if b.Header.Height == 0 {
+		return nil // genesis carries no work
 	}
 
 	// Validate transactions first
@@ -43,31 +73,52 @@ func VerifyBlock(b *core.Block, st storage.Reader, modelPath string, gpuLayers i
 		}
 	}
 
-	// Reconstruct the procedural quiz using the block's nonce
-	quizzes := dataset.ProceduralQuiz(b.Header.Height, b.Header.Nonce)
+	if b.Header.Bits == 0 {
+		return fmt.Errorf("block %d carries no target", b.Header.Height)
+	}
+
+	// Reconstruct the procedural quiz from the parent hash, transaction
+	// root, version and nonce, at the tier of the block's target. The
+	// transaction root is checked first, since the work commits to it
+	var txRoot [32]byte
+	copy(txRoot[:], b.CalculateMerkleRoot())
+	if txRoot != b.Header.TxRoot {
+		return fmt.Errorf("tx root mismatch: header %x, computed %x", b.Header.TxRoot[:8], txRoot[:8])
+	}
+	tier := dataset.DifficultyTier(b.Header.Target())
+	quizzes := dataset.ProceduralQuiz(b.Header.ParentHash, b.Header.TxRoot, b.Header.Version, b.Header.Height, b.Header.Nonce, tier)
 
 	// Create prompt from quizzes (same as mining)
-	prompt := ""
-	for _, quiz := range quizzes {
-		prompt += quiz + "\n"
-	}
+	prompt := dataset.QuizPrompt(quizzes)
 
 	if prompt == "" {
 		return fmt.Errorf("empty prompt generated from nonce %d", b.Header.Nonce)
 	}
 
+	// Corpus chains prefix the quiz with the records the parent selects
+	if len(b.Records) > 0 && config.CorpusSize == 0 {
+		return fmt.Errorf("%w: block %d uses corpus records: %w", core.ErrProofUnavailable, b.Header.Height, dataset.ErrNoCorpus)
+	}
+	if want := dataset.Indexes(b.Header.ParentHash); !slices.Equal(b.Records, want) {
+		return fmt.Errorf("block records %v, expected %v", b.Records, want)
+	}
+	context, err := dataset.Context(b.Records)
+	if err != nil {
+		return fmt.Errorf("corpus: %v", err)
+	}
+	prompt = context + prompt
+
 	// Run LLM inference with same seed as mining
 	var heightBytes [8]byte
 	binary.LittleEndian.PutUint64(heightBytes[:], b.Header.Height)
 	llmSeed := int(binary.LittleEndian.Uint64(heightBytes[:]))
-	output, err := llm.Infer(prompt, llmSeed)
+	output, err := v.llm.Infer(prompt, llmSeed)
 	if err != nil {
 		return fmt.Errorf("LLM inference failed: %v", err)
 	}
 
-	// Calculate loss from LLM output (same as mining)
-	hash := sha256.Sum256([]byte(output))
-	lossInt := int64(binary.LittleEndian.Uint64(hash[:8]))
+	// Grade the answers into the loss (same as mining)
+	lossInt := dataset.Loss(quizzes, prompt, output)
 
 	// Verify the loss matches the block header
 	if lossInt != b.Header.Lhat {
@@ -75,8 +126,8 @@ func VerifyBlock(b *core.Block, st storage.Reader, modelPath string, gpuLayers i
 	}
 
 	// Verify the loss meets the difficulty target
-	if lossInt > b.Header.Bits.Int64() {
-		return fmt.Errorf("loss %d does not meet target %d", lossInt, b.Header.Bits.Int64())
+	if !core.MeetsTarget(lossInt, b.Header.Target()) {
+		return fmt.Errorf("loss %d does not meet target %v", lossInt, b.Header.Target())
 	}
 
 	return nil
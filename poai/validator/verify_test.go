@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"bytes"
+	"testing"
+
+	"poai/core"
+	"poai/core/config"
+	"poai/inference"
+	"poai/miner"
+)
+
+func TestVerifyRejectsChangedCoinbase(t *testing.T) {
+	defer func(chainID, interval uint64) {
+		config.ChainID, config.RetargetInterval = chainID, interval
+	}(config.ChainID, config.RetargetInterval)
+	g := core.RegtestGenesis()
+	g.Apply()
+	chain, err := core.NewMemoryChain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	blocks, err := miner.Generate(chain, inference.Stub{}, "0909090909090909090909090909090909090909", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := blocks[0]
+	v := NewEngineVerifier(inference.Stub{})
+	if err := v.Verify(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same work relayed with the reward redirected
+	txs := []*core.Transaction{core.NewCoinbaseTx(bytes.Repeat([]byte{6}, 20), b.Transactions[0].Amount)}
+	stolen := core.NewBlock(b.Header.Height, b.Header.ParentHash, b.Header.Lhat, b.Header.Target(), txs, b.Header.Nonce)
+	stolen.Header.Version = b.Header.Version
+	stolen.Records = b.Records
+	if err := v.Verify(stolen); err == nil {
+		t.Fatal("work accepted for a block with another coinbase")
+	}
+
+	// and with another version
+	bumped := *b
+	bumped.Header.Version ^= 1
+	if err := v.Verify(&bumped); err == nil {
+		t.Fatal("work accepted for a block with another version")
+	}
+}
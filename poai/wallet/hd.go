@@ -0,0 +1,144 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultBasePath is the BIP-44 account path; address i is DefaultBasePath/i.
+// PoAI addresses are Ethereum-style, so the Ethereum coin type is used and
+// the same seed yields the same addresses as common Ethereum wallets.
+const DefaultBasePath = "m/44'/60'/0'/0"
+
+// HardenedOffset marks a hardened child index.
+const HardenedOffset = 0x80000000
+
+var errInvalidChild = errors.New("invalid child key; use the next index")
+
+// NewMnemonic returns a BIP-39 mnemonic with the given entropy size in bits
+// (128 for 12 words, 256 for 24 words).
+func NewMnemonic(bits int) (string, error) {
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic validates mnemonic and returns its BIP-39 seed.
+func SeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+}
+
+// ExtendedKey is a BIP-32 extended private key.
+type ExtendedKey struct {
+	key       []byte // 32-byte private key
+	chainCode []byte
+}
+
+// NewMasterKey derives the BIP-32 master key from a seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errors.New("invalid seed")
+	}
+	return &ExtendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child derives child key i; indexes >= HardenedOffset are hardened.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	var data []byte
+	if i >= HardenedOffset {
+		data = append([]byte{0}, k.key...)
+	} else {
+		priv, err := crypto.ToECDSA(k.key)
+		if err != nil {
+			return nil, err
+		}
+		data = crypto.CompressPubkey(&priv.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+	child := il.Add(il, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, errInvalidChild
+	}
+	key := make([]byte, 32)
+	child.FillBytes(key)
+	return &ExtendedKey{key: key, chainCode: sum[32:]}, nil
+}
+
+// PrivateKey returns the key as an ECDSA private key.
+func (k *ExtendedKey) PrivateKey() (*ecdsa.PrivateKey, error) {
+	return crypto.ToECDSA(k.key)
+}
+
+// ParsePath parses a derivation path such as m/44'/60'/0'/0/0.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m/", path)
+	}
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		hardened := strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h")
+		p = strings.TrimRight(p, "'h")
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || v >= HardenedOffset {
+			return nil, fmt.Errorf("invalid path component %q in %q", p, path)
+		}
+		i := uint32(v)
+		if hardened {
+			i += HardenedOffset
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// DeriveKey derives the private key at path from a seed.
+func DeriveKey(seed []byte, path string) (*ecdsa.PrivateKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	k, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range indexes {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k.PrivateKey()
+}
+
+// AccountPath returns the default derivation path of account index.
+func AccountPath(index uint32) string {
+	return fmt.Sprintf("%s/%d", DefaultBasePath, index)
+}
@@ -0,0 +1,266 @@
+// Package wallet stores account keys in password-encrypted keystore files
+// compatible with geth's Web3 Secret Storage (version 3) format.
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt parameters; the standard ones match geth's defaults.
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// ErrDecrypt is returned for a wrong passphrase or a tampered key file.
+var ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+// ErrNoKey is returned when the keystore has no key for an address.
+var ErrNoKey = errors.New("no key for address in keystore")
+
+type keyFile struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherParams           `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+// EncryptKey encrypts key with passphrase using scrypt and AES-128-CTR.
+func EncryptKey(key *ecdsa.PrivateKey, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	id := make([]byte, 16)
+	for _, b := range [][]byte{salt, iv, id} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, err
+		}
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	plain := crypto.FromECDSA(key)
+	cipherText, err := aesCTR(derived[:16], iv, plain)
+	if err != nil {
+		return nil, err
+	}
+	mac := crypto.Keccak256(derived[16:32], cipherText)
+
+	id[6] = id[6]&0x0f | 0x40 // UUID version 4
+	id[8] = id[8]&0x3f | 0x80
+	return json.Marshal(keyFile{
+		Address: hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+		ID:      fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Version: 3,
+	})
+}
+
+// DecryptKey decrypts a version 3 key file.
+func DecryptKey(data []byte, passphrase string) (*ecdsa.PrivateKey, error) {
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+	if kf.Version != 3 {
+		return nil, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	c := kf.Crypto
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher %q", c.Cipher)
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := deriveKey(c, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(crypto.Keccak256(derived[16:32], cipherText), mac) {
+		return nil, ErrDecrypt
+	}
+	plain, err := aesCTR(derived[:16], iv, cipherText)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ToECDSA(plain)
+	if err != nil {
+		return nil, err
+	}
+	if addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes()); kf.Address != "" && !strings.EqualFold(addr, kf.Address) {
+		return nil, fmt.Errorf("key file address %s does not match key %s", kf.Address, addr)
+	}
+	return key, nil
+}
+
+func deriveKey(c cryptoJSON, passphrase string) ([]byte, error) {
+	param := func(name string) int {
+		v, _ := c.KDFParams[name].(float64)
+		return int(v)
+	}
+	saltHex, _ := c.KDFParams["salt"].(string)
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return nil, err
+	}
+	switch c.KDF {
+	case "scrypt":
+		return scrypt.Key([]byte(passphrase), salt, param("n"), param("r"), param("p"), param("dklen"))
+	}
+	return nil, fmt.Errorf("unsupported KDF %q", c.KDF)
+}
+
+func aesCTR(key, iv, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// StoreKey encrypts key into a new file in dir, named like geth's
+// UTC--<timestamp>--<address>, and returns its path.
+func StoreKey(dir string, key *ecdsa.PrivateKey, passphrase string) (string, error) {
+	data, err := EncryptKey(key, passphrase, StandardScryptN, StandardScryptP)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	ts := time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z")
+	path := filepath.Join(dir, fmt.Sprintf("UTC--%s--%s", ts, addr))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Accounts returns the hex addresses of all key files in dir.
+func Accounts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var kf keyFile
+		if json.Unmarshal(data, &kf) != nil || kf.Address == "" {
+			continue
+		}
+		addrs = append(addrs, strings.ToLower(kf.Address))
+	}
+	return addrs, nil
+}
+
+// Unlock decrypts the key for address (hex, with or without 0x) in dir.
+func Unlock(dir, address, passphrase string) (*ecdsa.PrivateKey, error) {
+	want := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var kf keyFile
+		if json.Unmarshal(data, &kf) != nil || strings.ToLower(kf.Address) != want {
+			continue
+		}
+		return DecryptKey(data, passphrase)
+	}
+	return nil, fmt.Errorf("%w %s", ErrNoKey, address)
+}
+
+// ReadPassphrase returns the passphrase from file if set, else from the
+// POAI_PASSWORD environment variable, else prompts for it on stdin.
+func ReadPassphrase(file, prompt string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("read password file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if pw, ok := os.LookupEnv("POAI_PASSWORD"); ok {
+		return pw, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEncryptDecryptKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncryptKey(key, "hunter2", LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecryptKey(data, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(key.D) != 0 {
+		t.Fatal("decrypted key differs")
+	}
+	if _, err := DecryptKey(data, "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+}
+
+// Key file from the Web3 Secret Storage spec test vectors.
+const specKeyFile = `{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"83dbcc02d8ccb40e466191a123791e0e"},"ciphertext":"d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c","kdf":"scrypt","kdfparams":{"dklen":32,"n":262144,"p":8,"r":1,"salt":"ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},"mac":"2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"},"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`
+
+func TestDecryptSpecVector(t *testing.T) {
+	key, err := DecryptKey([]byte(specKeyFile), "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	if got := hex.EncodeToString(crypto.FromECDSA(key)); got != want {
+		t.Fatalf("key = %s, want %s", got, want)
+	}
+}
+
+func TestStoreAndUnlock(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := crypto.GenerateKey()
+	data, err := EncryptKey(key, "pw", LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	if err := os.WriteFile(filepath.Join(dir, "key.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	accts, err := Accounts(dir)
+	if err != nil || len(accts) != 1 || accts[0] != addr {
+		t.Fatalf("accounts = %v, %v", accts, err)
+	}
+	if _, err := Unlock(dir, "0x"+addr, "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unlock(dir, "00", "pw"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("missing key: got %v", err)
+	}
+}